@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+	"github.com/alexalbu001/greenops/pkg/client"
+)
+
+// apiErrorHints tells the user what to do about the API errors they can fix themselves
+var apiErrorHints = map[string]string{
+	pkg.CodeInvalidPayload:   "Inspect the request with --dry-run",
+	pkg.CodeInvalidResources: "Inspect the request with --dry-run",
+	pkg.CodeNoResources:      "Check --resources and the tag filters, or inspect the request with --dry-run",
+	pkg.CodeTooManyItems:     "Scan fewer resources with --limit",
+	pkg.CodeUnauthorized:     "Set the API key with --api-key, GREENOPS_API_KEY or api.key in the config file",
+	pkg.CodeForbidden:        "Use the API key the job was submitted with, or for requeue-dlq one of ADMIN_API_KEYS",
+	pkg.CodeJobNotFound:      "Jobs expire 7 days after they are submitted by default; check the job ID or run the scan again",
+	pkg.CodeRequestNotStored: "The API keeps requests of up to 5 MB when RESULTS_BUCKET is set; save scans yourself with --save-scan",
+	pkg.CodeJobExpired:       "Jobs and their results are kept for 7 days by default; run the scan again to analyze the resources afresh",
+	pkg.CodeRequeueRunning:   "Wait for the earlier requeue to finish, then run requeue-dlq again",
+	pkg.CodeDLQNotConfigured: "Deploy the work queue with a dead-letter queue and pass its ARN to the API as DLQ_ARN",
+}
+
+// newAPIClient creates the GreenOps API client for the configured URL, timeout and API key.
+// Job results come with their embeddings only when the report keeps or groups by them.
+func newAPIClient(cfg *pkg.Config) *client.Client {
+	return client.New(cfg.API.URL, client.Options{
+		APIKey:            cfg.API.Key,
+		Timeout:           time.Duration(cfg.API.Timeout) * time.Second,
+		UserAgent:         "GreenOps-CLI/" + version,
+		IncludeEmbeddings: withEmbeddings || groupSimilar,
+	})
+}
+
+// explainAPIError adds guidance to the API errors the user can fix. Errors with codes the
+// CLI does not know are shown as the raw response body.
+func explainAPIError(err error) error {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code == "" {
+		return err
+	}
+	hint, known := apiErrorHints[apiErr.Code]
+	if !known {
+		return fmt.Errorf("API returned error status %d: %s", apiErr.StatusCode, apiErr.Body)
+	}
+	return fmt.Errorf("%w\n%s", err, hint)
+}
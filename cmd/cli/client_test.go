@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+	"github.com/alexalbu001/greenops/pkg/client"
+)
+
+func TestExplainAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "known code gets a hint",
+			err:  &client.APIError{StatusCode: 413, Code: pkg.CodeTooManyItems, Message: "request contains 300 resources"},
+			want: "request contains 300 resources (413 too_many_items)\nScan fewer resources with --limit",
+		},
+		{
+			name: "unknown code shows the body",
+			err:  &client.APIError{StatusCode: 500, Code: pkg.CodeInternal, Message: "failed to create job", Body: `{"error":"failed to create job","code":"internal_error"}`},
+			want: `API returned error status 500: {"error":"failed to create job","code":"internal_error"}`,
+		},
+		{
+			name: "unstructured response",
+			err:  &client.APIError{StatusCode: 502, Body: "Bad Gateway"},
+			want: "API returned error status 502: Bad Gateway",
+		},
+		{
+			name: "not an API error",
+			err:  errors.New("connection refused"),
+			want: "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainAPIError(tt.err).Error(); got != tt.want {
+				t.Errorf("explainAPIError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
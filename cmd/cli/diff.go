@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// runDiffCommand handles `greenops diff <old.json> <new.json>`, comparing two reports written
+// with --format json. The comparison is printed as text, or as JSON with --format json.
+func runDiffCommand(cfg *pkg.Config, args []string) {
+	if len(args) != 2 {
+		pkg.Fatalf("Usage: greenops diff <old.json> <new.json>")
+	}
+
+	oldReport, err := pkg.LoadJSONReport(args[0])
+	if err != nil {
+		pkg.Fatalf("Failed to load old report: %v", err)
+	}
+	newReport, err := pkg.LoadJSONReport(args[1])
+	if err != nil {
+		pkg.Fatalf("Failed to load new report: %v", err)
+	}
+
+	diff, err := pkg.DiffReports(oldReport, newReport)
+	if err != nil {
+		pkg.Fatalf("Failed to compare reports: %v", err)
+	}
+
+	w := os.Stdout
+	colorize := isTerminal(os.Stdout) && cfg.Output.Colors
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			pkg.Fatalf("Failed to create output file: %v", err)
+		}
+		defer file.Close()
+
+		w = file
+		colorize = false
+	}
+
+	switch cfg.Output.Format {
+	case "json":
+		if err := pkg.FormatReportDiffJSON(w, diff); err != nil {
+			pkg.Fatalf("Failed to write JSON comparison: %v", err)
+		}
+	case "", "text":
+		pkg.FormatReportDiff(w, diff, colorize)
+	default:
+		pkg.Fatalf("greenops diff writes text or json, not %s", cfg.Output.Format)
+	}
+
+	if outputFile != "" {
+		pkg.Infof("Comparison saved to %s", outputFile)
+	}
+}
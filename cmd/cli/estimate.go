@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// observedItemSeconds returns the analysis time per item of the runs recorded in the history,
+// or estimate.item_seconds with no runs when none were timed
+func observedItemSeconds(cfg *pkg.Config) (float64, int) {
+	if path := historyFile(); path != "" {
+		records, err := pkg.ReadHistory(path)
+		if err != nil {
+			pkg.Debugf("Estimating without run history: %v", err)
+		} else if seconds, runs, ok := pkg.AverageItemSeconds(records); ok {
+			return seconds, runs
+		}
+	}
+	return cfg.Estimate.ItemSeconds, 0
+}
+
+// overAnalysisBudget reports whether an estimate exceeds estimate.max_cost, when one is set
+func overAnalysisBudget(cfg *pkg.Config, estimate pkg.AnalysisEstimate) bool {
+	return cfg.Estimate.MaxCost > 0 && estimate.CostUSD > cfg.Estimate.MaxCost
+}
+
+// printAnalysisEstimate prints what analyzing the payload should cost and take, on stderr
+// so reports and dry-run payloads on stdout stay clean. Quiet runs only print an estimate
+// over the budget.
+func printAnalysisEstimate(cfg *pkg.Config, payload pkg.ScanPayload) pkg.AnalysisEstimate {
+	itemSeconds, runs := observedItemSeconds(cfg)
+	estimate := pkg.EstimateAnalysis(payload, cfg.Bedrock.Model, itemSeconds, runs)
+	if cfg.Output.Verbosity != pkg.VerbosityQuiet || overAnalysisBudget(cfg, estimate) {
+		pkg.FormatAnalysisEstimate(os.Stderr, estimate)
+		fmt.Fprintln(os.Stderr)
+	}
+	return estimate
+}
+
+// confirmAnalysisCost asks before an analysis whose estimate exceeds estimate.max_cost,
+// unless --yes is set, and returns false when the user declines
+func confirmAnalysisCost(cfg *pkg.Config, estimate pkg.AnalysisEstimate) bool {
+	if !overAnalysisBudget(cfg, estimate) {
+		return true
+	}
+	maxCost := cfg.Estimate.MaxCost
+	if assumeYes {
+		pkg.Infof("Estimated analysis cost $%.2f exceeds $%.2f; continuing because of --yes", estimate.CostUSD, maxCost)
+		return true
+	}
+	if !isTerminal(os.Stdin) {
+		pkg.Fatalf("Estimated analysis cost $%.2f exceeds --max-analysis-cost $%.2f; pass --yes to analyze anyway", estimate.CostUSD, maxCost)
+	}
+	fmt.Fprintf(os.Stderr, "Estimated analysis cost $%.2f exceeds $%.2f. Analyze anyway? [y/N] ", estimate.CostUSD, maxCost)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
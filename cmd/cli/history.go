@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// defaultHistoryRuns is how many runs `greenops history` shows unless told otherwise
+const defaultHistoryRuns = 20
+
+// historyFile returns the history file named by --history, ~/.greenops/history.jsonl by
+// default, or "" when --history is off
+func historyFile() string {
+	switch historyPath {
+	case "off":
+		return ""
+	case "":
+		path, err := pkg.DefaultHistoryPath()
+		if err != nil {
+			pkg.Warnf("Run history disabled: %v", err)
+			return ""
+		}
+		return path
+	}
+	return historyPath
+}
+
+// analysisStarted is when this run started analyzing its scan, zero for runs that fetch the
+// results of an earlier job
+var analysisStarted time.Time
+
+// recordRun appends the totals of a report to the history file, with the analysis time per
+// item when this run analyzed it. The history is only a record, so failing to write it is a
+// warning rather than an error.
+func recordRun(report []pkg.ReportItem, cfg *pkg.Config) {
+	path := historyFile()
+	if path == "" {
+		return
+	}
+	record := pkg.NewHistoryRecord(report, cfg.ScanHash())
+	if !analysisStarted.IsZero() && len(report) > 0 {
+		record.ItemSeconds = time.Since(analysisStarted).Seconds() / float64(len(report))
+	}
+	if err := pkg.AppendHistory(path, record); err != nil {
+		pkg.Warnf("Failed to record run history: %v", err)
+		return
+	}
+	pkg.Debugf("Run recorded in %s", path)
+}
+
+// runHistoryCommand handles `greenops history [N]`, printing the last N runs (20 by default)
+// as a table with trends, or as JSON with --json or --format json
+func runHistoryCommand(cfg *pkg.Config, args []string) {
+	runs := defaultHistoryRuns
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 || len(args) > 1 {
+			pkg.Fatalf("Usage: greenops history [number of runs]")
+		}
+		runs = n
+	}
+
+	path := historyFile()
+	if path == "" {
+		pkg.Fatalf("Run history is turned off; name a history file with --history")
+	}
+	records, err := pkg.ReadHistory(path)
+	if err != nil {
+		pkg.Fatalf("%v", err)
+	}
+	records = records[max(len(records)-runs, 0):]
+
+	if jsonOutput || cfg.Output.Format == "json" {
+		if err := pkg.FormatHistoryJSON(os.Stdout, records); err != nil {
+			pkg.Fatalf("Failed to write history: %v", err)
+		}
+		return
+	}
+	pkg.FormatHistory(os.Stdout, records, isTerminal(os.Stdout) && cfg.Output.Colors)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// regionPattern matches an AWS region code such as eu-west-1 or us-gov-west-1
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-\d+$`)
+
+// initConfig writes a configuration file for --init. The defaults are seeded with the
+// flags given, e.g. --api, --region, --profile, --resources or --limit; on a terminal a
+// wizard then asks for each of them, elsewhere the seeded file is written as it is.
+func initConfig() {
+	// --format picks the file format here; a .yaml or .yml --config path implies YAML
+	format := pkg.ConfigFileFormat(configFile)
+	switch outputFormat {
+	case "":
+	case pkg.ConfigFormatJSON, pkg.ConfigFormatYAML:
+		format = outputFormat
+	default:
+		pkg.Fatalf("Unsupported config format %q (expected json or yaml)", outputFormat)
+	}
+
+	// Determine output path
+	outputPath := configFile
+	if outputPath == "" {
+		var err error
+		if outputPath, err = pkg.DefaultConfigPath(format); err != nil {
+			pkg.Fatalf("%v", err)
+		}
+	}
+
+	cfg := pkg.DefaultConfig()
+	if err := applyFlags(cfg); err != nil {
+		pkg.Fatalf("%v", err)
+	}
+	// With --init, --format is the format of the file rather than of reports
+	cfg.Output.Format = pkg.DefaultConfig().Output.Format
+	if isTerminal(os.Stdin) {
+		if err := runInitWizard(cfg, bufio.NewReader(os.Stdin), os.Stderr); err != nil {
+			pkg.Fatalf("Configuration not written: %v", err)
+		}
+	}
+	var problems pkg.ValidationErrors
+	if errors.As(cfg.Validate(), &problems) {
+		fatalInvalidConfig(problems)
+	}
+
+	// Create directory if needed
+	configDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		pkg.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	data, err := pkg.MarshalConfig(cfg, format)
+	if err != nil {
+		pkg.Fatalf("Failed to generate config: %v", err)
+	}
+
+	// Write to file
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		pkg.Fatalf("Failed to write config file: %v", err)
+	}
+
+	fmt.Printf("Configuration file generated at: %s\n", outputPath)
+	fmt.Printf("Try it with: %s\n", sampleCommand(configFile))
+}
+
+// sampleCommand is the command printed after --init, naming the file when it is not one
+// greenops finds by itself
+func sampleCommand(configPath string) string {
+	if configPath == "" {
+		return "greenops --dry-run"
+	}
+	return fmt.Sprintf("greenops --config %s --dry-run", configPath)
+}
+
+// runInitWizard asks for the settings a first run needs, showing the current value of each
+// as its default, and asks again until the answer is valid. It fails when the input ends
+// before every question is answered.
+func runInitWizard(cfg *pkg.Config, in *bufio.Reader, out io.Writer) error {
+	fmt.Fprintln(out, "GreenOps configuration. Press Enter to keep the value in brackets.")
+
+	err := askSetting(in, out, "GreenOps API URL", cfg.API.URL, func(answer string) error {
+		cfg.API.URL = answer
+		return fieldProblem(cfg, "api.url")
+	})
+	if err != nil {
+		return err
+	}
+
+	err = askSetting(in, out, "Default AWS region (empty for the AWS profile's region)", cfg.AWS.Region, func(answer string) error {
+		if answer != "" && !regionPattern.MatchString(answer) {
+			return fmt.Errorf("%q is not an AWS region such as eu-west-1", answer)
+		}
+		cfg.AWS.Region = answer
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = askSetting(in, out, "AWS profile (empty for the default credentials)", cfg.AWS.Profile, func(answer string) error {
+		if strings.ContainsAny(answer, " \t") {
+			return fmt.Errorf("%q contains whitespace", answer)
+		}
+		cfg.AWS.Profile = answer
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Resource types to scan:")
+	for i, resType := range pkg.ScanResourceTypes {
+		mark := " "
+		if slices.Contains(cfg.Scan.Resources, resType) {
+			mark = "x"
+		}
+		fmt.Fprintf(out, "  %2d. [%s] %s\n", i+1, mark, resType)
+	}
+	err = askSetting(in, out, "Numbers or names, comma-separated", strings.Join(cfg.Scan.Resources, ","), func(answer string) error {
+		selected, err := parseResourceSelection(answer)
+		if err != nil {
+			return err
+		}
+		cfg.Scan.Resources = selected
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = askSetting(in, out, "Resources to scan per run (0 for no limit)", strconv.Itoa(cfg.Scan.Limit), func(answer string) error {
+		limit, err := strconv.Atoi(answer)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", answer)
+		}
+		cfg.Scan.Limit = limit
+		return fieldProblem(cfg, "scan.limit")
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+// askSetting prints a question with its default and passes the answer, or the default on
+// an empty line, to apply, asking again while apply rejects it
+func askSetting(in *bufio.Reader, out io.Writer, question, current string, apply func(answer string) error) error {
+	for {
+		fmt.Fprintf(out, "%s [%s]: ", question, current)
+		line, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(out)
+			return errors.New("input ended before the configuration was complete")
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = current
+		}
+		if err := apply(answer); err != nil {
+			fmt.Fprintf(out, "  %v\n", err)
+			continue
+		}
+		return nil
+	}
+}
+
+// parseResourceSelection reads the resource types picked in the wizard, as numbers of the
+// list it shows or as names, in the order ScanResourceTypes lists them
+func parseResourceSelection(answer string) ([]string, error) {
+	picked := make(map[string]bool)
+	for _, entry := range pkg.SplitList(answer) {
+		if n, err := strconv.Atoi(entry); err == nil {
+			if n < 1 || n > len(pkg.ScanResourceTypes) {
+				return nil, fmt.Errorf("%d is not in the list (1-%d)", n, len(pkg.ScanResourceTypes))
+			}
+			picked[pkg.ScanResourceTypes[n-1]] = true
+			continue
+		}
+		if !slices.Contains(pkg.ScanResourceTypes, entry) {
+			return nil, fmt.Errorf("unknown resource type %q", entry)
+		}
+		picked[entry] = true
+	}
+	if len(picked) == 0 {
+		return nil, errors.New("pick at least one resource type")
+	}
+
+	var selected []string
+	for _, resType := range pkg.ScanResourceTypes {
+		if picked[resType] {
+			selected = append(selected, resType)
+		}
+	}
+	return selected, nil
+}
+
+// fieldProblem validates cfg and returns the first problem with one config key, or nil
+func fieldProblem(cfg *pkg.Config, field string) error {
+	var problems pkg.ValidationErrors
+	if errors.As(cfg.Validate(), &problems) {
+		for _, problem := range problems {
+			if problem.Field == field {
+				return errors.New(problem.Message)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/briandowns/spinner"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+	"github.com/alexalbu001/greenops/pkg/client"
+)
+
+// pollForJobResults waits for a job, showing its progress on stderr: in a spinner on a
+// terminal, or as one plain line per poll otherwise so CI logs stay free of control
+// characters. Polls start --poll-interval seconds apart and back off while nothing
+// completes, until --poll-timeout passes or, without it, --poll-max polls. A failed job
+// returns *client.JobFailedError and running out of time *client.PollTimeoutError; with
+// --partial the results gathered so far are returned alongside either error. The job's last
+// status is returned too, for its account-level recommendations and report metadata.
+func pollForJobResults(ctx context.Context, jobID string, api *client.Client) ([]pkg.ReportItem, pkg.JobStatusResponse, error) {
+	start := time.Now()
+	var s *spinner.Spinner
+	if isTerminal(os.Stderr) {
+		s = spinner.New(spinner.CharSets[14], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+		s.Prefix = "⠋ Waiting for analysis… "
+		s.Start()
+	}
+
+	var last pkg.JobStatusResponse
+	opts := client.PollOptions{
+		Interval: time.Duration(pollInterval) * time.Second,
+		Timeout:  pollTimeout,
+		Partial:  partialResults,
+		Progress: func(st pkg.JobStatusResponse) {
+			last = st
+			progress := fmt.Sprintf("%d/%d analyzed (failed: %d)", st.CompletedItems, st.TotalItems, st.FailedItems)
+			if s == nil {
+				pkg.Infof("Job %s %s: %s", st.JobID, st.Status, progress)
+				return
+			}
+			s.Lock()
+			s.Suffix = " " + progress
+			s.Unlock()
+		},
+	}
+	if pollTimeout <= 0 {
+		opts.MaxAttempts = maxPollRetry
+	}
+	report, err := api.WaitForJob(ctx, jobID, opts)
+
+	if s != nil {
+		s.Stop()
+	}
+	pkg.Infof("Stopped polling job %s after %s", jobID, time.Since(start).Round(time.Second))
+	warnJobExpiry(jobID, last.ExpiresAt)
+	if last.Status.IsTerminal() {
+		for _, item := range pkg.FailedJobItems(last.Items) {
+			pkg.Warnf("Item %d failed: %s %s: %s", item.ItemIndex, item.ItemType, item.ResourceID, item.Error)
+		}
+	}
+	return report, last, err
+}
+
+// handlePolledReport writes the results of a polled job, explaining why polling stopped
+// when it did not complete, with the account-level recommendations and metadata of its last
+// status. Failures exit non-zero after any partial results are shown.
+func handlePolledReport(ctx context.Context, cfg *pkg.Config, jobID string, report []pkg.ReportItem, st pkg.JobStatusResponse, err error) {
+	if err == nil {
+		writeReport(ctx, report, st.AccountRecommendations, st.Metadata, cfg)
+		enforceThresholds(report, cfg)
+		return
+	}
+
+	if errors.Is(err, client.ErrJobCancelled) {
+		pkg.Infof("Job %s was cancelled", jobID)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Job %s still running — resume with: greenops jobs results %s\n", jobID, jobID)
+		os.Exit(exitInterrupted)
+	}
+
+	var failedErr *client.JobFailedError
+	var timeoutErr *client.PollTimeoutError
+	if (errors.As(err, &failedErr) || errors.As(err, &timeoutErr)) && len(report) > 0 {
+		pkg.Infof("Showing %d partial results", len(report))
+		writeReport(ctx, report, st.AccountRecommendations, st.Metadata, cfg)
+	}
+	if timeoutErr != nil {
+		pkg.Fatalf("Failed to get job results: %v; check later with: greenops jobs results %s", err, jobID)
+	}
+
+	pkg.Fatalf("Failed to get job results: %v", explainAPIError(err))
+}
+
+// runJobsCommand handles `greenops jobs list`, `greenops jobs requeue-dlq` and
+// `greenops jobs <status|results|inspect|retry|cancel> <id>`
+func runJobsCommand(ctx context.Context, cfg *pkg.Config, args []string) {
+	if len(args) == 1 && args[0] == "list" {
+		runJobsList(ctx, cfg)
+		return
+	}
+	if len(args) == 1 && args[0] == "requeue-dlq" {
+		requeue, err := newAPIClient(cfg).RequeueDLQ(ctx)
+		if err != nil {
+			pkg.Fatalf("Failed to requeue the dead-letter queue: %v", explainAPIError(err))
+		}
+		fmt.Printf("Moving the messages of %s back to the work queue (task %s)\n", requeue.SourceARN, requeue.TaskHandle)
+		return
+	}
+	if len(args) < 2 {
+		pkg.Fatalf("Usage: greenops jobs <list|requeue-dlq>, or greenops jobs <status|results|inspect|retry|cancel> <job-id>")
+	}
+	action, jobID := args[0], args[1]
+
+	api := newAPIClient(cfg)
+
+	switch action {
+	case "status":
+		st, err := api.GetJobStatus(ctx, jobID)
+		if err != nil {
+			pkg.Fatalf("Failed to get job status: %v", explainAPIError(err))
+		}
+		printJobStatus(os.Stdout, &st, cfg.Output.Format)
+		warnJobExpiry(jobID, st.ExpiresAt)
+
+	case "results":
+		var report []pkg.ReportItem
+		var st pkg.JobStatusResponse
+		var err error
+		if noWait {
+			report, err = api.GetJobResults(ctx, jobID)
+		} else {
+			report, st, err = pollForJobResults(ctx, jobID, api)
+		}
+		handlePolledReport(ctx, cfg, jobID, report, st, err)
+
+	case "inspect":
+		payload, err := api.GetJobRequest(ctx, jobID)
+		if err != nil {
+			pkg.Fatalf("Failed to get job request: %v", explainAPIError(err))
+		}
+		pkg.Infof("Job %s was submitted with %d resources", jobID, payload.Count())
+		data, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			pkg.Fatalf("Failed to write job request: %v", err)
+		}
+		fmt.Println(string(data))
+
+	case "retry":
+		retry, err := api.RetryJob(ctx, jobID)
+		if err != nil {
+			pkg.Fatalf("Failed to retry job: %v", explainAPIError(err))
+		}
+		fmt.Printf("Job %s: queued %d failed items again (retry %d of %d); follow it with: greenops jobs results %s\n",
+			jobID, retry.RetriedItems, retry.RetryCount, retry.MaxRetries, jobID)
+
+	case "cancel":
+		if err := api.CancelJob(ctx, jobID); err != nil {
+			pkg.Fatalf("Failed to cancel job: %v", explainAPIError(err))
+		}
+		fmt.Printf("Job %s cancelled\n", jobID)
+
+	default:
+		pkg.Fatalf("Unknown jobs command %q (expected list, requeue-dlq, status, results, inspect, retry or cancel)", action)
+	}
+}
+
+// runJobsList prints one page of the caller's jobs, filtered by --status and continuing from
+// --next-token, with the command for the next page when there is one
+func runJobsList(ctx context.Context, cfg *pkg.Config) {
+	list, err := newAPIClient(cfg).ListJobs(ctx, pkg.JobStatus(jobsStatus), 0, jobsNextToken)
+	if err != nil {
+		pkg.Fatalf("Failed to list jobs: %v", explainAPIError(err))
+	}
+
+	if cfg.Output.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(list); err != nil {
+			pkg.Fatalf("Failed to write jobs: %v", err)
+		}
+		return
+	}
+
+	printJobList(os.Stdout, list.Jobs)
+	if list.NextToken != "" {
+		next := "greenops jobs list --next-token " + list.NextToken
+		if jobsStatus != "" {
+			next += " --status " + jobsStatus
+		}
+		fmt.Printf("\nMore jobs: %s\n", next)
+	}
+}
+
+// printJobList writes jobs as a table, one row per job
+func printJobList(w io.Writer, jobs []pkg.JobSummary) {
+	if len(jobs) == 0 {
+		fmt.Fprintln(w, "No jobs found.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB ID\tCREATED\tSTATUS\tITEMS\tCOMPLETED\tFAILED")
+	for _, job := range jobs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\n", job.JobID, time.Unix(job.CreatedAt, 0).Local().Format("2006-01-02 15:04"),
+			job.Status, job.TotalItems, job.CompletedItems, job.FailedItems)
+	}
+	tw.Flush()
+}
+
+// jobExpiryWarning is how close to its expiry a fetched job gets a warning
+const jobExpiryWarning = 24 * time.Hour
+
+// warnJobExpiry warns when a job expires, in Unix seconds, within jobExpiryWarning, so its
+// results can be saved before they are deleted. Jobs from APIs that do not say are skipped.
+func warnJobExpiry(jobID string, expiresAt int64) {
+	if expiresAt <= 0 {
+		return
+	}
+	if left := time.Until(time.Unix(expiresAt, 0)); left < jobExpiryWarning {
+		pkg.Warnf("Job %s and its results expire in %s, at %s; save the report now if you need it",
+			jobID, left.Round(time.Minute), formatExpiry(expiresAt))
+	}
+}
+
+// formatExpiry formats a job expiry in Unix seconds in local time
+func formatExpiry(expiresAt int64) string {
+	return time.Unix(expiresAt, 0).Local().Format("2006-01-02 15:04 MST")
+}
+
+// printJobStatus writes a job status either as JSON or as a short human-readable block
+func printJobStatus(w io.Writer, st *pkg.JobStatusResponse, format string) {
+	if format == "json" {
+		// Progress only; `jobs results` prints the results
+		progress := *st
+		progress.Results = nil
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(progress)
+		return
+	}
+
+	fmt.Fprintf(w, "Job:       %s\n", st.JobID)
+	fmt.Fprintf(w, "Status:    %s\n", st.Status)
+	fmt.Fprintf(w, "Progress:  %d/%d completed, %d failed, %d skipped\n", st.CompletedItems, st.TotalItems, st.FailedItems, st.SkippedItems)
+	if st.RetryCount > 0 {
+		fmt.Fprintf(w, "Retries:   %d of %d\n", st.RetryCount, pkg.MaxJobRetries)
+	}
+	if st.ExpiresAt > 0 {
+		fmt.Fprintf(w, "Expires:   %s\n", formatExpiry(st.ExpiresAt))
+	}
+	if !st.AnalysisUsage.IsZero() {
+		fmt.Fprintf(w, "Bedrock:   $%.2f for %d prompt and %d completion tokens\n", st.AnalysisCostUSD, st.PromptTokens, st.CompletionTokens)
+	}
+	if failed := pkg.FailedJobItems(st.Items); len(failed) > 0 {
+		fmt.Fprintln(w, "Failed:")
+		for _, item := range failed {
+			fmt.Fprintf(w, "  %d. %s %s: %s\n", item.ItemIndex, item.ItemType, item.ResourceID, item.Error)
+		}
+	}
+}
+
+// submitJobPerAccount submits one async job with the resources of each account of a payload,
+// then, unless --no-wait, polls the jobs in turn and writes a single report of their results.
+// A job that cannot be submitted or does not complete is reported without stopping the
+// others; the run exits non-zero after the report when any did not complete.
+func submitJobPerAccount(ctx context.Context, cfg *pkg.Config, api *client.Client, payload pkg.ScanPayload) {
+	byAccount := payload.SplitByAccount()
+	accountIDs := slices.Sorted(maps.Keys(byAccount))
+	label := func(accountID string) string {
+		if accountID == "" {
+			return "(no account)"
+		}
+		return accountID
+	}
+
+	var submitted []string
+	jobIDs := make(map[string]string, len(accountIDs))
+	for _, accountID := range accountIDs {
+		jobResponse, err := api.SubmitAnalysis(ctx, byAccount[accountID])
+		exitIfInterrupted(ctx, "Interrupted while submitting jobs; the jobs already submitted keep running")
+		if err != nil {
+			pkg.Errorf("Failed to submit the job for account %s: %v", label(accountID), explainAPIError(err))
+			continue
+		}
+		pkg.Infof("Job submitted for account %s: ID=%s, Status=%s, Items=%d",
+			label(accountID), jobResponse.JobID, jobResponse.Status, jobResponse.TotalItems)
+		submitted = append(submitted, accountID)
+		jobIDs[accountID] = jobResponse.JobID
+	}
+	if len(submitted) == 0 {
+		pkg.Fatalf("Failed to submit any of the %d jobs", len(accountIDs))
+	}
+
+	if noWait {
+		for _, accountID := range submitted {
+			fmt.Println(jobIDs[accountID])
+		}
+		pkg.Infof("Retrieve results later with: greenops jobs results <job-id>")
+		return
+	}
+
+	var report []pkg.ReportItem
+	incomplete := len(accountIDs) - len(submitted)
+	for i, accountID := range submitted {
+		jobID := jobIDs[accountID]
+		pkg.Infof("Waiting for the job of account %s (%d of %d)", label(accountID), i+1, len(submitted))
+		accountReport, _, err := pollForJobResults(ctx, jobID, api)
+		if errors.Is(err, context.Canceled) {
+			for _, pending := range submitted[i:] {
+				fmt.Fprintf(os.Stderr, "Job %s still running — resume with: greenops jobs results %s\n", jobIDs[pending], jobIDs[pending])
+			}
+			os.Exit(exitInterrupted)
+		}
+		if err != nil {
+			pkg.Errorf("Job %s for account %s did not complete: %v", jobID, label(accountID), explainAPIError(err))
+			incomplete++
+		}
+		report = append(report, accountReport...)
+	}
+
+	if len(report) > 0 {
+		writeReport(ctx, report, nil, payload.Metadata, cfg)
+		enforceThresholds(report, cfg)
+	}
+	if incomplete > 0 {
+		pkg.Fatalf("%d of %d account jobs did not complete", incomplete, len(accountIDs))
+	}
+}
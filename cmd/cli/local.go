@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+	"github.com/alexalbu001/greenops/pkg/prompts"
+)
+
+// localItemTimeout bounds the Bedrock calls for a single resource in --local mode
+const localItemTimeout = 3 * time.Minute
+
+// configureLocalAnalysis applies the prompt templates, focus areas and analysis format of the
+// configuration. It runs before anything is scanned, so a broken template is reported early.
+func configureLocalAnalysis(cfg *pkg.Config) {
+	if err := prompts.SetDir(cfg.Bedrock.PromptsDir); err != nil {
+		pkg.Fatalf("Invalid prompt templates: %v", err)
+	}
+	if cfg.Bedrock.PromptsDir != "" {
+		pkg.Infof("Using prompt templates from %s", cfg.Bedrock.PromptsDir)
+	}
+	prompts.SetFocusAreas(cfg.Bedrock.FocusAreas)
+	pkg.SetAnalysisFormat(cfg.Bedrock.AnalysisFormat)
+}
+
+// analyzeLocally runs the Bedrock analysis for every resource in the payload from this
+// machine instead of the GreenOps API, then one more for the account-level recommendations
+// of the whole report. A failed resource is logged and left out of the report rather than
+// aborting the run, and failed account-level recommendations are left out of it too.
+func analyzeLocally(ctx context.Context, awsCfg aws.Config, cfg *pkg.Config, payload pkg.ScanPayload) ([]pkg.ReportItem, *pkg.AccountRecommendations) {
+	client := bedrockruntime.NewFromConfig(awsCfg)
+	items := payload.WorkItems("", 0)
+	pkg.Infof("Analyzing %d resources locally with %s", len(items), cfg.Bedrock.Model)
+
+	bar := newProgressBar(os.Stderr)
+	report, errs := pkg.AnalyzeResources(ctx, client, items, pkg.AnalyzeOptions{
+		GenModel:    cfg.Bedrock.Model,
+		EmbedModel:  cfg.Bedrock.EmbedModel,
+		ItemTimeout: localItemTimeout,
+		Progress:    bar.Update,
+	})
+	bar.Finish()
+
+	for _, err := range errs {
+		pkg.Warnf("Failed to analyze %v", err)
+	}
+	if len(errs) > 0 {
+		pkg.Infof("Analyzed %d of %d resources (%d failed)", len(report), len(items), len(errs))
+	}
+	if len(report) == 0 {
+		return report, nil
+	}
+
+	accountCtx, cancel := context.WithTimeout(ctx, localItemTimeout)
+	defer cancel()
+	account, err := pkg.AnalyzeAccount(accountCtx, client, cfg.Bedrock.Model, report)
+	if err != nil {
+		pkg.Warnf("Failed to write the account-level recommendations: %v", err)
+	}
+	return report, account
+}
+
+// progressBar renders "[#####.....] N/M resources, elapsed" on a terminal
+type progressBar struct {
+	out     *os.File
+	enabled bool
+	start   time.Time
+}
+
+const progressBarWidth = 30
+
+func newProgressBar(out *os.File) *progressBar {
+	return &progressBar{out: out, enabled: isTerminal(out), start: time.Now()}
+}
+
+// Update redraws the bar; it is used as the AnalyzeResources progress callback
+func (p *progressBar) Update(done, total int) {
+	if !p.enabled || total == 0 {
+		return
+	}
+	filled := done * progressBarWidth / total
+	elapsed := time.Since(p.start).Round(time.Second)
+	fmt.Fprintf(p.out, "\r[%s%s] %d/%d resources, %s elapsed ",
+		strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), done, total, elapsed)
+}
+
+// Finish ends the bar's line so later log output starts on a fresh line
+func (p *progressBar) Finish() {
+	if p.enabled {
+		fmt.Fprintln(p.out)
+	}
+}
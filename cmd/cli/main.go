@@ -4,65 +4,179 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"maps"
+	"net"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/briandowns/spinner"
-
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 
 	pkg "github.com/alexalbu001/greenops/pkg"
 )
 
+// Process exit codes
+const (
+	exitOK                = 0
+	exitError             = 1   // scan, API or output failure (also used by pkg.Fatalf)
+	exitThresholdExceeded = 2   // potential savings above --fail-on-savings or --fail-on-co2, or idle instances reaching --fail-on-idle
+	exitInterrupted       = 130 // stopped by Ctrl-C or SIGTERM, as shells report SIGINT
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=v1.2.0 -X main.commit=1a2b3c4 -X main.date=2025-05-01T12:00:00Z"
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
 // Command-line flags
 var (
-	apiURL       string
-	region       string
-	profile      string
-	outputFile   string
-	debug        bool
-	timeout      int
-	resourceCap  int
-	noColor      bool
-	configFile   string
-	generateConf bool
-	asyncMode    bool
-	pollInterval int
-	maxPollRetry int
-	resources    string
-	pdfOutput    string
-	verbose      bool
+	showVersion     bool
+	apiURL          string
+	apiKey          string
+	region          string
+	regions         string
+	profile         string
+	outputFile      string
+	debug           bool
+	timeout         int
+	resourceCap     int
+	noColor         bool
+	configFile      string
+	generateConf    bool
+	asyncMode       bool
+	pollInterval    int
+	maxPollRetry    int
+	pollTimeout     time.Duration
+	resources       string
+	pdfOutput       string
+	emitScripts     string
+	maxAnalysisCost float64
+	assumeYes       bool
+	verbose         bool
+	outputFormat    string
+	verbosity       string
+	sortBy          string
+	noWait          bool
+	noCache         bool
+	ttlDays         int
+	groupSimilar    bool
+	searchTop       int
+	withEmbeddings  bool
+	historyPath     string
+	jsonOutput      bool
+	dryRun          bool
+	inputFile       string
+	saveScan        string
+	localMode       bool
+	genModel        string
+	embedModel      string
+	promptsDir      string
+	partialResults  bool
+	failOnSavings   float64
+	failOnCO2       float64
+	failOnIdle      int
+	metricsDays     int
+	snapshotAge     int
+	livePricing     bool
+	costExplorer    bool
+	commitments     bool
+	orgMode         bool
+	assumeRole      string
+	accountList     string
+	jobPerAccount   bool
+	includeTags     stringList
+	excludeTags     stringList
+	typeLimits      string
+	jobsStatus      string
+	jobsNextToken   string
 )
 
-// ServerResponse represents the API response format
-type ServerResponse struct {
-	Report []pkg.ReportItem `json:"report"`
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func init() {
 	// Define command-line flags
-	flag.StringVar(&configFile, "config", "", "Path to configuration file")
-	flag.BoolVar(&generateConf, "init", false, "Generate a default configuration file")
-	flag.StringVar(&apiURL, "api", "https://8tse26l4fi.execute-api.eu-west-1.amazonaws.com/analyze", "GreenOps API URL")
+	flag.StringVar(&configFile, "config", "", "Path to configuration file (defaults to $GREENOPS_CONFIG, ./greenops.json or ~/.greenops/config.json)")
+	flag.BoolVar(&showVersion, "version", false, "Print the version, commit and build date and exit")
+	flag.BoolVar(&generateConf, "init", false, "Write a configuration file, asking for the main settings on a terminal (JSON, or commented YAML with --format yaml)")
+	flag.StringVar(&apiURL, "api", pkg.DefaultAPIURL, "GreenOps API URL")
+	flag.StringVar(&apiKey, "api-key", "", "GreenOps API key, sent as x-api-key (defaults to GREENOPS_API_KEY env var or config file)")
 	flag.StringVar(&region, "region", "", "AWS Region (defaults to AWS_REGION env var or config file)")
+	flag.StringVar(&regions, "regions", "", "Comma-separated list of AWS regions to scan, or \"all\" for every enabled region")
+	flag.BoolVar(&orgMode, "org", false, "Scan several accounts by assuming --assume-role in each: --accounts, or every active account of the organization")
+	flag.StringVar(&assumeRole, "assume-role", "", "Role name to assume in each account with --org, e.g. GreenOpsScanner")
+	flag.StringVar(&accountList, "accounts", "", "Comma-separated account IDs to scan with --org (defaults to every active account of the organization)")
+	flag.BoolVar(&jobPerAccount, "job-per-account", false, "With --org, submit one async job per account instead of one combined job")
 	flag.StringVar(&profile, "profile", "", "AWS Profile (defaults to AWS_PROFILE env var or default profile)")
 	flag.StringVar(&outputFile, "output", "", "Save results to file (default outputs to stdout)")
-	flag.BoolVar(&debug, "debug", false, "Enable debug logging")
-	flag.IntVar(&timeout, "timeout", 60, "API request timeout in seconds")
-	flag.IntVar(&resourceCap, "limit", 10, "Maximum number of resources to scan")
+	flag.BoolVar(&debug, "debug", false, "Enable debug logging with timestamps and source locations")
+	flag.IntVar(&timeout, "timeout", pkg.DefaultAPITimeout, "API request timeout in seconds")
+	flag.IntVar(&resourceCap, "limit", pkg.DefaultScanLimit, "Maximum number of resources to scan, shared fairly across resource types")
+	flag.StringVar(&typeLimits, "limit-per-type", "", "Per-type resource limits within --limit, e.g. ec2=20,s3=5")
 	flag.BoolVar(&noColor, "no-color", false, "Disable colorized output")
 	flag.BoolVar(&asyncMode, "async", true, "Use asynchronous processing mode")
-	flag.IntVar(&pollInterval, "poll-interval", 5, "Polling interval in seconds for async mode")
-	flag.IntVar(&maxPollRetry, "poll-max", 60, "Maximum number of polling attempts")
-	flag.StringVar(&resources, "resources", "ec2,s3,rds", "Comma-separated list of resources to scan (ec2,s3,rds)")
-	flag.BoolVar(&verbose, "verbose", false, "Show debug and scan logs (stderr)")
+	flag.IntVar(&pollInterval, "poll-interval", 2, "Shortest polling interval in seconds for async mode; it backs off to 30s while a job makes no progress")
+	flag.IntVar(&maxPollRetry, "poll-max", 60, "Maximum number of polling attempts (ignored when --poll-timeout is set)")
+	flag.DurationVar(&pollTimeout, "poll-timeout", 0, "Stop polling after this long, e.g. 10m, instead of after --poll-max attempts")
+	flag.StringVar(&resources, "resources", strings.Join(pkg.DefaultScanResources, ","), "Comma-separated list of resources to scan (ec2,s3,rds,ebs,lambda,elb,network,dynamodb,elasticache,snapshots)")
+	flag.BoolVar(&verbose, "verbose", false, "Show debug logs, including raw API requests and responses (stderr)")
+	flag.StringVar(&pdfOutput, "pdf", "", "Also export the report as a PDF to this path")
+	flag.StringVar(&emitScripts, "emit-scripts", "", "Write AWS CLI and Terraform remediation scripts for the deterministic findings into this directory, without running them")
+	flag.Float64Var(&maxAnalysisCost, "max-analysis-cost", 0, "Ask for confirmation before an analysis whose estimated Bedrock cost exceeds this many dollars")
+	flag.BoolVar(&assumeYes, "yes", false, "Analyze without asking for confirmation when the estimate exceeds --max-analysis-cost")
+	flag.Float64Var(&failOnSavings, "fail-on-savings", 0, "Exit with code 2 when potential savings exceed this many dollars per month")
+	flag.Float64Var(&failOnCO2, "fail-on-co2", 0, "Exit with code 2 when potential CO2 savings exceed this many kg per month")
+	flag.IntVar(&failOnIdle, "fail-on-idle", 0, "Exit with code 2 when at least this many EC2 instances are idle")
+	flag.BoolVar(&partialResults, "partial", false, "Show the results gathered so far when an async job fails or polling times out")
+	flag.BoolVar(&noWait, "no-wait", false, "Submit the async job, print its ID and exit without polling")
+	flag.StringVar(&historyPath, "history", "", "Append each run's totals to this file (defaults to ~/.greenops/history.jsonl; \"off\" disables)")
+	flag.BoolVar(&jsonOutput, "json", false, "Print the output of greenops history and greenops search as JSON")
+	flag.BoolVar(&withEmbeddings, "include-embeddings", false, "Keep each resource's embedding in --format json reports, for greenops search")
+	flag.StringVar(&jobsStatus, "status", "", "Only list jobs in this status with greenops jobs list: pending, processing, completed, failed or cancelled")
+	flag.StringVar(&jobsNextToken, "next-token", "", "Continue greenops jobs list from the token printed after the previous page")
+	flag.IntVar(&searchTop, "top", defaultSearchMatches, "Number of matches greenops search prints")
+	flag.BoolVar(&noCache, "no-cache", false, "Have the API analyze every resource again instead of reusing analyses cached in the last days")
+	flag.IntVar(&ttlDays, "ttl-days", 0, fmt.Sprintf("Days the API keeps the job and its results, %d to %d (defaults to the API's setting)", pkg.MinJobTTLDays, pkg.MaxJobTTLDays))
+	flag.StringVar(&inputFile, "input", "", "Analyze resources from a saved scan file instead of scanning AWS")
+	flag.StringVar(&saveScan, "save-scan", "", "Save the scanned resources to this file for later use with --input")
+	flag.BoolVar(&localMode, "local", false, "Analyze resources with Bedrock from this machine instead of the GreenOps API")
+	flag.StringVar(&genModel, "model", "", "Bedrock model or inference profile for --local (defaults to config file or "+pkg.DefaultGenModelID+"), or one the API allows for this request")
+	flag.StringVar(&embedModel, "embed-model", "", "Bedrock embedding model for --local and greenops search (defaults to config file or "+pkg.DefaultEmbedModelID+")")
+	flag.StringVar(&promptsDir, "prompts-dir", "", "Directory whose ec2.tmpl, s3.tmpl and rds.tmpl replace the built-in prompts for --local (defaults to config file)")
+	flag.BoolVar(&dryRun, "dry-run", false, "Scan and print the payload that would be sent to the API, without sending it")
+	flag.StringVar(&outputFormat, "format", "", "Output format: text, json, csv, html or markdown (defaults to config file or text); with --init, json or yaml")
+	flag.StringVar(&verbosity, "verbosity", "", "Text report detail: quiet, normal or detailed (defaults to config file or normal)")
+	flag.BoolVar(&groupSimilar, "group-similar", false, "Add a Resource Groups section to the text report that groups near-duplicate resources by their embeddings")
+	flag.StringVar(&sortBy, "sort", "", "Order resources by savings, co2, cost or name (defaults to config file or savings)")
+	flag.IntVar(&metricsDays, "metrics-days", 0, "CloudWatch lookback window in days (defaults to config file or 7)")
+	flag.IntVar(&snapshotAge, "snapshot-age", 0, "Report orphaned EBS snapshots older than this many days (defaults to config file or 90)")
+	flag.BoolVar(&livePricing, "live-pricing", false, "Look up EC2 and RDS prices with the AWS Pricing API instead of the bundled price table")
+	flag.BoolVar(&costExplorer, "with-cost-explorer", false, "Attach the last 30 days of actual spend from Cost Explorer to EC2, RDS and S3 resources (Cost Explorer charges per request)")
+	flag.BoolVar(&commitments, "with-commitments", false, "Add Savings Plans and reservation coverage from Cost Explorer to the sustainability summary of the text report (Cost Explorer charges per request)")
+	flag.Var(&includeTags, "include-tag", "Only scan resources with this tag, as key=value or key (repeatable)")
+	flag.Var(&excludeTags, "exclude-tag", "Skip resources with this tag, as key=value or key (repeatable)")
 }
 
 // isTerminal detects if the output is going to a terminal
@@ -74,467 +188,826 @@ func isTerminal(f *os.File) bool {
 	return (fileInfo.Mode() & os.ModeCharDevice) != 0
 }
 
-// printUsageInfo prints detailed usage information
-func printUsageInfo() {
-	fmt.Printf(`GreenOps CLI
-A tool for optimizing AWS resource usage and reducing carbon footprint.
+// configKeyFlags names the flag that sets a config key, for validation messages
+var configKeyFlags = map[string]string{
+	"api.url":                     "--api",
+	"api.timeout":                 "--timeout",
+	"scan.resources":              "--resources",
+	"scan.limit":                  "--limit",
+	"scan.limits":                 "--limit-per-type",
+	"scan.metrics.period_days":    "--metrics-days",
+	"scan.snapshots.min_age_days": "--snapshot-age",
+	"scan.tag_filters":            "--include-tag/--exclude-tag",
+	"bedrock.prompts_dir":         "--prompts-dir",
+	"estimate.max_cost":           "--max-analysis-cost",
+	"ci.fail_on_savings":          "--fail-on-savings",
+	"ci.fail_on_co2":              "--fail-on-co2",
+	"ci.fail_on_idle":             "--fail-on-idle",
+	"output.format":               "--format",
+	"output.verbosity":            "--verbosity",
+	"output.sort":                 "--sort",
+}
 
-Basic Usage:
-  greenops [options]
+// scanOnlyFlags only affect scanning, so they have no effect when --input replays a saved scan
+var scanOnlyFlags = []string{"resources", "regions", "org", "assume-role", "accounts", "limit", "limit-per-type", "include-tag", "exclude-tag", "metrics-days", "snapshot-age", "save-scan"}
 
-Operating Modes:
-  - Synchronous (default): Directly analyze resources and wait for results
-  - Asynchronous (--async): Submit jobs for background processing
+// validateFlags checks the flags that are not part of the configuration, and combinations
+// of flags that contradict each other or the resolved configuration
+func validateFlags(cfg *pkg.Config) pkg.ValidationErrors {
+	var problems pkg.ValidationErrors
+	add := func(field, message string) {
+		problems = append(problems, pkg.FieldError{Field: field, Message: message})
+	}
 
-Examples:
-  greenops --limit 10                     # Analyze up to 10 EC2 instances synchronously
-  greenops --async --limit 50             # Analyze up to 50 EC2 instances asynchronously
-  greenops --output results.json          # Save results to a file
-  greenops --region eu-west-1             # Specify AWS region
-  greenops --profile prod                 # Use specific AWS profile
-  greenops --debug                        # Enable debug logging
+	if pollInterval <= 0 {
+		add("--poll-interval", fmt.Sprintf("must be a positive number of seconds, got %d", pollInterval))
+	}
+	if maxPollRetry <= 0 {
+		add("--poll-max", fmt.Sprintf("must be a positive number of attempts, got %d", maxPollRetry))
+	}
+	if pollTimeout < 0 {
+		add("--poll-timeout", fmt.Sprintf("must not be negative, got %s", pollTimeout))
+	}
 
-`)
-	flag.PrintDefaults()
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if inputFile != "" {
+		for _, name := range scanOnlyFlags {
+			if set[name] {
+				add("--"+name, "cannot be combined with --input, which analyzes a saved scan instead of scanning")
+			}
+		}
+	}
+	if noWait && localMode {
+		add("--no-wait", "cannot be combined with --local, which analyzes without submitting a job")
+	}
+	if noCache && localMode {
+		add("--no-cache", "cannot be combined with --local, which does not use the API's analysis cache")
+	}
+	if set["prompts-dir"] && !localMode {
+		add("--prompts-dir", "requires --local; the API uses the prompts configured on its worker")
+	}
+	if ttlDays != 0 {
+		if ttlDays < pkg.MinJobTTLDays || ttlDays > pkg.MaxJobTTLDays {
+			add("--ttl-days", fmt.Sprintf("must be between %d and %d days, got %d", pkg.MinJobTTLDays, pkg.MaxJobTTLDays, ttlDays))
+		}
+		if localMode {
+			add("--ttl-days", "cannot be combined with --local, which analyzes without submitting a job")
+		}
+	}
+	if noWait && !asyncMode {
+		add("--no-wait", "requires async mode; remove --async=false")
+	}
+	if orgMode && cfg.Organization.RoleName == "" {
+		add("--org", "requires --assume-role (or organization.role_name), the role to assume in each account")
+	}
+	if jobPerAccount {
+		if !orgMode && inputFile == "" {
+			add("--job-per-account", "requires --org, or an --input scan of several accounts")
+		}
+		if localMode || !asyncMode {
+			add("--job-per-account", "requires async mode without --local")
+		}
+	}
+	if withEmbeddings && cfg.Output.Format != "json" {
+		add("--include-embeddings", "only applies to --format json reports")
+	}
+	if searchTop <= 0 {
+		add("--top", fmt.Sprintf("must be a positive number of matches, got %d", searchTop))
+	}
+	if jobsStatus != "" {
+		if _, err := pkg.ParseJobStatus(jobsStatus); err != nil {
+			add("--status", err.Error())
+		}
+	}
+	return problems
 }
 
-// pollForJobResults polls the API for job results until completed or max attempts reached
-func pollForJobResults(ctx context.Context, jobID string, cfg *pkg.Config, client *http.Client) ([]pkg.ReportItem, error) {
-	// Construct URLs
-	baseURL := cfg.API.URL
-	if strings.HasSuffix(baseURL, "/analyze") {
-		baseURL = baseURL[:len(baseURL)-len("/analyze")]
+// fatalInvalidConfig exits listing every problem, each with the flag that sets its key
+func fatalInvalidConfig(problems pkg.ValidationErrors) {
+	lines := make([]string, len(problems))
+	for i, problem := range problems {
+		if name, ok := configKeyFlags[problem.Field]; ok {
+			problem.Field += " (" + name + ")"
+		}
+		lines[i] = problem.Error()
 	}
-	jobURL := fmt.Sprintf("%s/jobs/%s", baseURL, jobID)
-	resultsURL := fmt.Sprintf("%s/jobs/%s/results", baseURL, jobID)
-
-	// Start spinner on stderr
-	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
-	s.Prefix = "⠋ Waiting for analysis… "
-	s.Start()
+	pkg.Fatalf("Invalid configuration:\n  %s", strings.Join(lines, "\n  "))
+}
 
-	var lastCompleted int
-	var noProgress int
+// applyFlags copies the flags given on the command line into the configuration. Flags left
+// at their defaults are skipped so they do not override the config file or environment.
+func applyFlags(cfg *pkg.Config) error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "api":
+			cfg.API.URL = apiURL
+		case "api-key":
+			cfg.API.Key = apiKey
+		case "timeout":
+			cfg.API.Timeout = timeout
+		case "region":
+			cfg.AWS.Region = region
+		case "regions":
+			cfg.AWS.Regions = pkg.SplitList(regions)
+		case "profile":
+			cfg.AWS.Profile = profile
+		case "assume-role":
+			cfg.Organization.RoleName = assumeRole
+		case "accounts":
+			cfg.Organization.Accounts = pkg.SplitList(accountList)
+		case "resources":
+			cfg.Scan.Resources = pkg.SplitList(resources)
+		case "limit":
+			cfg.Scan.Limit = resourceCap
+		case "limit-per-type":
+			limits, parseErr := pkg.ParseTypeLimits(typeLimits)
+			if parseErr != nil {
+				err = fmt.Errorf("invalid --limit-per-type: %w", parseErr)
+				return
+			}
+			if cfg.Scan.Limits == nil {
+				cfg.Scan.Limits = make(map[string]int)
+			}
+			for resType, limit := range limits {
+				cfg.Scan.Limits[resType] = limit
+			}
+		case "metrics-days":
+			cfg.Scan.Metrics.PeriodDays = metricsDays
+		case "snapshot-age":
+			cfg.Scan.Snapshots.MinAgeDays = snapshotAge
+		case "include-tag":
+			cfg.Scan.TagFilters.Include = append(cfg.Scan.TagFilters.Include, includeTags...)
+		case "exclude-tag":
+			cfg.Scan.TagFilters.Exclude = append(cfg.Scan.TagFilters.Exclude, excludeTags...)
+		case "model":
+			cfg.Bedrock.Model = genModel
+		case "embed-model":
+			cfg.Bedrock.EmbedModel = embedModel
+		case "prompts-dir":
+			cfg.Bedrock.PromptsDir = promptsDir
+		case "live-pricing":
+			if livePricing {
+				cfg.Pricing.Mode = pkg.PricingModeLive
+			}
+		case "with-cost-explorer":
+			cfg.CostExplorer.Enabled = costExplorer
+		case "with-commitments":
+			cfg.Commitments.Enabled = commitments
+		case "max-analysis-cost":
+			cfg.Estimate.MaxCost = maxAnalysisCost
+		case "fail-on-savings":
+			cfg.CI.FailOnSavings = failOnSavings
+		case "fail-on-co2":
+			cfg.CI.FailOnCO2 = failOnCO2
+		case "fail-on-idle":
+			cfg.CI.FailOnIdle = failOnIdle
+		case "no-color":
+			cfg.Output.Colors = !noColor
+		case "format":
+			cfg.Output.Format = outputFormat
+		case "verbosity":
+			cfg.Output.Verbosity = verbosity
+		case "sort":
+			cfg.Output.Sort = sortBy
+		}
+	})
+	return err
+}
 
-	for attempt := 0; attempt < maxPollRetry; attempt++ {
-		// Update spinner
-		// s.Suffix = fmt.Sprintf("", lastCompleted, cfg.Scan.Limit)
+// writeReport renders the report in the configured format to --output or stdout, with the
+// metadata of the scan in its header when there is any. The
+// account-level recommendations, when there are any, head the text report.
+func writeReport(ctx context.Context, report []pkg.ReportItem, account *pkg.AccountRecommendations, meta *pkg.ReportMetadata, cfg *pkg.Config) {
+	w := os.Stdout
+	colorize := isTerminal(os.Stdout) && cfg.Output.Colors
+	report = pkg.RankReport(report, cfg.Output.Sort)
 
-		// Fetch status
-		req, err := http.NewRequestWithContext(ctx, "GET", jobURL, nil)
-		if err != nil {
-			s.Stop()
-			return nil, fmt.Errorf("failed to create job status request: %v", err)
-		}
-		resp, err := client.Do(req)
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
 		if err != nil {
-			s.Stop()
-			return nil, fmt.Errorf("failed to get job status: %v", err)
+			pkg.Fatalf("Failed to create output file: %v", err)
 		}
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		defer file.Close()
 
-		var st struct {
-			Status         string `json:"status"`
-			TotalItems     int    `json:"total_items"`
-			CompletedItems int    `json:"completed_items"`
-			FailedItems    int    `json:"failed_items"`
+		w = file
+		colorize = false // No colors in file output
+	}
+
+	if groupSimilar && (cfg.Output.Format != "text" || cfg.Output.Verbosity == pkg.VerbosityQuiet) {
+		pkg.Warnf("--group-similar only applies to the text report at normal or detailed verbosity; ignoring it")
+	}
+
+	switch cfg.Output.Format {
+	case "json":
+		if !withEmbeddings {
+			report = pkg.StripEmbeddings(report)
 		}
-		if err := json.Unmarshal(body, &st); err != nil {
-			// transient parse error; retry
-			time.Sleep(time.Duration(pollInterval) * time.Second)
-			continue
+		if err := pkg.FormatAnalysisReportJSON(w, report, meta); err != nil {
+			pkg.Fatalf("Failed to write JSON report: %v", err)
+		}
+	case "csv":
+		if err := pkg.FormatAnalysisReportCSV(w, report); err != nil {
+			pkg.Fatalf("Failed to write CSV report: %v", err)
+		}
+	case "html":
+		if err := pkg.FormatAnalysisReportHTML(w, report, meta); err != nil {
+			pkg.Fatalf("Failed to write HTML report: %v", err)
+		}
+	case "markdown":
+		if err := pkg.FormatAnalysisReportMarkdown(w, report); err != nil {
+			pkg.Fatalf("Failed to write markdown report: %v", err)
 		}
+	default:
+		opts := pkg.ReportOptions{
+			Colorize:  colorize,
+			Verbosity: cfg.Output.Verbosity,
+			SortBy:    cfg.Output.Sort,
+			Account:   account,
+			Metadata:  meta,
+		}
+		if cfg.Commitments.Enabled {
+			opts.Commitments = fetchCommitmentCoverage(ctx, report, cfg)
+		}
+		if groupSimilar {
+			opts.GroupSimilarity = pkg.DefaultGroupSimilarity
+		}
+		pkg.FormatAnalysisReport(w, report, opts)
+	}
 
-		// Progress tracking
-		if st.CompletedItems > lastCompleted {
-			lastCompleted = st.CompletedItems
-			noProgress = 0
+	if outputFile != "" {
+		pkg.Infof("Results saved to %s", outputFile)
+	}
+
+	// PDF export is additive; a failure here must not discard the report above
+	if pdfOutput != "" {
+		if err := pkg.ExportReportToPDF(report, meta, pdfOutput); err != nil {
+			pkg.Warnf("Failed to export PDF report: %v", err)
 		} else {
-			noProgress++
+			pkg.Infof("PDF report saved to %s", pdfOutput)
 		}
+	}
 
-		// Done?
-		if st.Status == "completed" || st.Status == "failed" ||
-			(st.CompletedItems+st.FailedItems >= st.TotalItems && noProgress >= 3) {
-			break
+	// Scripts are only written for review; nothing here runs them
+	if emitScripts != "" {
+		scripts := pkg.GenerateRemediations(report)
+		if err := pkg.WriteRemediations(emitScripts, scripts); err != nil {
+			pkg.Warnf("Failed to write remediation scripts: %v", err)
+		} else {
+			pkg.Infof("%d remediation scripts written to %s for review", len(scripts), emitScripts)
 		}
-
-		time.Sleep(time.Duration(pollInterval) * time.Second)
 	}
 
-	// Stop spinner and fetch results
-	s.Stop()
-	// fmt.Fprintln(os.Stderr, "Getting results directly from", resultsURL)
-	return getResultsDirectly(ctx, resultsURL, client)
+	recordRun(report, cfg)
 }
 
-// getResultsDirectly retrieves results from the direct results endpoint
-func getResultsDirectly(ctx context.Context, resultsURL string, client *http.Client) ([]pkg.ReportItem, error) {
-	log.Printf("Getting results directly from %s", resultsURL)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", resultsURL, nil)
+// fetchCommitmentCoverage reads the Savings Plans and reservation coverage for the text
+// report; without Cost Explorer access the report simply leaves the block out
+func fetchCommitmentCoverage(ctx context.Context, report []pkg.ReportItem, cfg *pkg.Config) *pkg.CommitmentCoverage {
+	pkg.Infof("Fetching Savings Plans and reservation coverage from Cost Explorer...")
+	coverage, err := pkg.FetchCommitmentCoverage(ctx, pkg.NewCostExplorerClientFromConfig(loadAWSConfig(ctx, cfg)), report)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create results request: %v", err)
+		pkg.Warnf("Unable to get commitment coverage from Cost Explorer, leaving it out of the report: %v", err)
+		return nil
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get results: %v", err)
+	for _, query := range coverage.Unavailable {
+		pkg.Warnf("Commitment coverage is partial; unavailable: %s", query)
 	}
+	return coverage
+}
 
-	body, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read results response: %v", err)
-	}
+// loadAWSConfig loads the AWS configuration for the configured region and profile
+func loadAWSConfig(ctx context.Context, cfg *pkg.Config) aws.Config {
+	var awsConfigOpts []func(*awsconfig.LoadOptions) error
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("results API returned error status %d: %s", resp.StatusCode, body)
+	if cfg.AWS.Region != "" {
+		awsConfigOpts = append(awsConfigOpts, awsconfig.WithRegion(cfg.AWS.Region))
 	}
-
-	// Parse the response
-	var resultsResp struct {
-		Results []pkg.ReportItem `json:"results"`
+	if cfg.AWS.Profile != "" {
+		awsConfigOpts = append(awsConfigOpts, awsconfig.WithSharedConfigProfile(cfg.AWS.Profile))
 	}
 
-	err = json.Unmarshal(body, &resultsResp)
+	pkg.Infof("Loading AWS configuration...")
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsConfigOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse results: %v", err)
+		pkg.Fatalf("Failed to load AWS configuration: %v", err)
 	}
-
-	log.Printf("Successfully retrieved %d report items directly", len(resultsResp.Results))
-	return resultsResp.Results, nil
+	return awsCfg
 }
 
-func main() {
-	// Parse command-line flags
-	flag.Parse()
-	if !verbose {
-		// send the default logger to stderr only
-		log.SetOutput(os.Stderr)
-	} else {
-		// include file/line info for debug
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
+// scanOptions returns the scan settings of the configuration
+func scanOptions(cfg *pkg.Config, tagFilters pkg.TagFilterSet) pkg.ScanOptions {
+	return pkg.ScanOptions{
+		MaxItems:           cfg.Scan.Limit,
+		TypeLimits:         cfg.Scan.Limits,
+		DaysBack:           cfg.Scan.Metrics.PeriodDays,
+		TagFilters:         tagFilters,
+		Regions:            cfg.AWS.Regions,
+		SnapshotMinAgeDays: cfg.Scan.Snapshots.MinAgeDays,
+		IdleThresholds:     cfg.Scan.Thresholds,
 	}
+}
 
-	// Show help only if explicitly requested with -h or --help
-	if len(os.Args) == 2 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
-		printUsageInfo()
-		return
-	}
-	// Setup logger
-	if debug {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
-	} else {
-		log.SetFlags(0)
+// scanAccount scans the configured resource types
+func scanAccount(ctx context.Context, awsCfg aws.Config, cfg *pkg.Config, tagFilters pkg.TagFilterSet) pkg.ScanPayload {
+	scanResults, err := pkg.ScanResources(ctx, awsCfg, cfg.Scan.Resources, scanOptions(cfg, tagFilters))
+	exitIfInterrupted(ctx, "Scan interrupted; nothing was sent to the GreenOps API")
+	if err != nil {
+		pkg.Fatalf("Failed to scan resources: %v", err)
 	}
 
-	// Handle configuration
-	if generateConf {
-		// Get default configuration
-		defaultConfig := &pkg.Config{}
-		defaultConfig.API.URL = "https://8tse26l4fi.execute-api.eu-west-1.amazonaws.com/analyze"
-		defaultConfig.API.Timeout = 60
-		defaultConfig.Scan.Limit = 10
-		defaultConfig.Scan.Resources = []string{"ec2", "s3"}
-		defaultConfig.Scan.Metrics.PeriodDays = 7
-		defaultConfig.Output.Colors = true
-		defaultConfig.Output.Format = "text"
-		defaultConfig.Output.Verbosity = "normal"
-
-		// Determine output path
-		outputPath := configFile
-		if outputPath == "" {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				log.Fatalf("Failed to get user home directory: %v", err)
-			}
-			outputPath = filepath.Join(home, ".greenops", "config.json")
-		}
-
-		// Create directory if needed
-		configDir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(configDir, 0755); err != nil {
-			log.Fatalf("Failed to create config directory: %v", err)
-		}
+	return pkg.NewScanPayload(scanResults)
+}
 
-		// Marshal config to JSON
-		data, err := json.MarshalIndent(defaultConfig, "", "  ")
+// scanOrganization scans the configured accounts, or every active account of the
+// organization, by assuming the configured role in each. Accounts that cannot be scanned are
+// reported and left out; the run only stops when none could be.
+func scanOrganization(ctx context.Context, awsCfg aws.Config, cfg *pkg.Config, tagFilters pkg.TagFilterSet) pkg.ScanPayload {
+	accountIDs := cfg.Organization.Accounts
+	if len(accountIDs) == 0 {
+		pkg.Infof("Listing the accounts of the organization...")
+		accounts, err := pkg.NewOrganizationsClientFromConfig(awsCfg).ListAccounts(ctx)
+		exitIfInterrupted(ctx, "Scan interrupted; nothing was sent to the GreenOps API")
 		if err != nil {
-			log.Fatalf("Failed to generate config: %v", err)
+			pkg.Fatalf("Failed to list the accounts of the organization; name them with --accounts instead: %v", err)
 		}
-
-		// Write to file
-		if err := os.WriteFile(outputPath, data, 0644); err != nil {
-			log.Fatalf("Failed to write config file: %v", err)
+		for _, account := range accounts {
+			accountIDs = append(accountIDs, account.ID)
+		}
+		if len(accountIDs) == 0 {
+			pkg.Fatalf("The organization has no active accounts")
 		}
-
-		fmt.Printf("Configuration file generated at: %s\n", outputPath)
-		return
 	}
 
-	// Load or create configuration
-	var cfg *pkg.Config
+	scans := pkg.ScanOrganization(ctx, awsCfg, accountIDs, cfg.Organization.RoleName, cfg.Scan.Resources,
+		scanOptions(cfg, tagFilters), cfg.Organization.MaxConcurrent)
+	exitIfInterrupted(ctx, "Scan interrupted; nothing was sent to the GreenOps API")
 
-	// If config file is specified, try to load it
-	if configFile != "" {
-		if data, err := os.ReadFile(configFile); err == nil {
-			cfg = &pkg.Config{}
-			if err := json.Unmarshal(data, cfg); err != nil {
-				log.Fatalf("Failed to parse config file: %v", err)
-			}
-		} else {
-			log.Fatalf("Failed to read config file: %v", err)
+	var payload pkg.ScanPayload
+	failed := 0
+	for _, scan := range scans {
+		if scan.Err != nil {
+			pkg.Errorf("Failed to scan account %s: %v", scan.AccountID, scan.Err)
+			failed++
+			continue
 		}
-	} else {
-		// Use default configuration
-		cfg = &pkg.Config{}
-		cfg.API.URL = apiURL
-		cfg.API.Timeout = timeout
-		cfg.AWS.Region = region
-		cfg.AWS.Profile = profile
-		cfg.Scan.Limit = resourceCap
-		cfg.Scan.Resources = []string{"ec2", "s3", "rds"}
-		cfg.Scan.Resources = strings.Split(resources, ",")
-		cfg.Scan.Metrics.PeriodDays = 7
-		cfg.Output.Colors = !noColor
-		cfg.Output.Format = "text"
-		cfg.Output.Verbosity = "normal"
+		payload.Merge(scan.Payload)
+	}
+	if failed == len(scans) {
+		pkg.Fatalf("Failed to scan any of the %d accounts", len(scans))
+	}
+	if failed > 0 {
+		pkg.Warnf("Scanned %d of %d accounts; the report leaves out the %d that failed", len(scans)-failed, len(scans), failed)
 	}
+	return payload
+}
 
-	// Override config with command line arguments if provided
-	if apiURL != "" {
-		cfg.API.URL = apiURL
+// reportMetadata describes a scan for the report headers: the account and caller, the
+// regions and the scan parameters. Without STS the account and caller are left out.
+func reportMetadata(ctx context.Context, awsCfg aws.Config, cfg *pkg.Config, payload pkg.ScanPayload) *pkg.ReportMetadata {
+	meta := &pkg.ReportMetadata{
+		Regions:       cfg.AWS.Regions,
+		ResourceTypes: cfg.Scan.Resources,
+		Limit:         cfg.Scan.Limit,
+		MetricsDays:   cfg.Scan.Metrics.PeriodDays,
 	}
-	if region != "" {
-		cfg.AWS.Region = region
+	if len(meta.Regions) == 0 && awsCfg.Region != "" {
+		meta.Regions = []string{awsCfg.Region}
 	}
-	if profile != "" {
-		cfg.AWS.Profile = profile
+	if orgMode {
+		meta.Accounts = slices.Sorted(maps.Keys(payload.SplitByAccount()))
 	}
-	if timeout > 0 {
-		cfg.API.Timeout = timeout
+	if err := meta.ResolveCaller(ctx, pkg.NewSTSClientFromConfig(awsCfg)); err != nil {
+		pkg.Warnf("Unable to identify the AWS caller, leaving the account out of the report: %v", err)
 	}
-	if resourceCap > 0 {
-		cfg.Scan.Limit = resourceCap
+	return meta
+}
+
+// exitIfInterrupted exits with exitInterrupted after printing msg once ctx has been cancelled
+// by Ctrl-C or SIGTERM. The message bypasses the logger so it shows at every log level.
+func exitIfInterrupted(ctx context.Context, msg string) {
+	if ctx.Err() == nil {
+		return
 	}
-	if noColor {
-		cfg.Output.Colors = false
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(exitInterrupted)
+}
+
+// writeDryRun prints the request payload that would be sent to the API to --output or stdout,
+// followed by a per-type resource count and the payload size on stderr
+func writeDryRun(requestBody []byte) {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, requestBody, "", "  "); err != nil {
+		pkg.Fatalf("Failed to format payload: %v", err)
 	}
+	pretty.WriteString("\n")
 
-	// Set up AWS context
-	ctx := context.Background()
-	var awsConfigOpts []func(*awsconfig.LoadOptions) error
+	if outputFile != "" {
+		if err := os.WriteFile(outputFile, pretty.Bytes(), 0644); err != nil {
+			pkg.Fatalf("Failed to write payload: %v", err)
+		}
+		pkg.Infof("Payload saved to %s", outputFile)
+	} else {
+		os.Stdout.Write(pretty.Bytes())
+	}
 
-	if cfg.AWS.Region != "" {
-		awsConfigOpts = append(awsConfigOpts, awsconfig.WithRegion(cfg.AWS.Region))
+	// Count what was actually serialized rather than what was scanned
+	var sections map[string][]json.RawMessage
+	if err := json.Unmarshal(requestBody, &sections); err != nil {
+		pkg.Fatalf("Failed to parse payload: %v", err)
 	}
-	if cfg.AWS.Profile != "" {
-		awsConfigOpts = append(awsConfigOpts, awsconfig.WithSharedConfigProfile(cfg.AWS.Profile))
+	keys := make([]string, 0, len(sections))
+	for key := range sections {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	log.Println("Loading AWS configuration...")
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsConfigOpts...)
-	if err != nil {
-		log.Fatalf("Failed to load AWS configuration: %v", err)
+	fmt.Fprintln(os.Stderr, "Dry run: nothing was sent to the GreenOps API")
+	for _, key := range keys {
+		fmt.Fprintf(os.Stderr, "  %-18s %d\n", key+":", len(sections[key]))
 	}
+	fmt.Fprintf(os.Stderr, "  %-18s %d bytes\n", "payload size:", len(requestBody))
+}
 
-	// Scan resources
-	scanResults, err := pkg.ScanResources(ctx, awsCfg, cfg.Scan.Resources, cfg.Scan.Limit, cfg.Scan.Metrics.PeriodDays)
-	if err != nil {
-		log.Fatalf("Failed to scan resources: %v", err)
+// enforceThresholds prints a one-line CI summary and exits with exitThresholdExceeded
+// when the report's potential savings exceed a configured threshold, or its idle instances
+// reach one
+func enforceThresholds(report []pkg.ReportItem, cfg *pkg.Config) {
+	if cfg.CI.FailOnSavings <= 0 && cfg.CI.FailOnCO2 <= 0 && cfg.CI.FailOnIdle <= 0 {
+		return
+	}
+
+	summary := pkg.SummarizeReport(report)
+	var exceeded []string
+	if cfg.CI.FailOnSavings > 0 && summary.PotentialCostSavings > cfg.CI.FailOnSavings {
+		exceeded = append(exceeded, fmt.Sprintf("savings $%.2f > $%.2f", summary.PotentialCostSavings, cfg.CI.FailOnSavings))
+	}
+	if cfg.CI.FailOnCO2 > 0 && summary.PotentialCO2Savings > cfg.CI.FailOnCO2 {
+		exceeded = append(exceeded, fmt.Sprintf("CO2 %.2f kg > %.2f kg", summary.PotentialCO2Savings, cfg.CI.FailOnCO2))
 	}
+	if cfg.CI.FailOnIdle > 0 && summary.IdleResources >= cfg.CI.FailOnIdle {
+		exceeded = append(exceeded, fmt.Sprintf("%d idle instances >= %d", summary.IdleResources, cfg.CI.FailOnIdle))
+	}
+
+	if len(exceeded) == 0 {
+		fmt.Fprintf(os.Stderr, "greenops: PASS potential monthly savings $%.2f, %.2f kg CO2e, %d idle across %d resources\n",
+			summary.PotentialCostSavings, summary.PotentialCO2Savings, summary.IdleResources, summary.TotalResources)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "greenops: FAIL threshold exceeded (%s) across %d resources\n",
+		strings.Join(exceeded, ", "), summary.TotalResources)
+	os.Exit(exitThresholdExceeded)
+}
+
+// printUsageInfo prints detailed usage information
+func printUsageInfo() {
+	fmt.Printf(`GreenOps CLI
+A tool for optimizing AWS resource usage and reducing carbon footprint.
+
+Basic Usage:
+  greenops [options]
+
+Operating Modes:
+  - Synchronous (default): Directly analyze resources and wait for results
+  - Asynchronous (--async): Submit jobs for background processing
+  - Local (--local): Analyze with Bedrock in your own account; nothing is sent to the API
+
+Examples:
+  greenops --limit 10                     # Analyze up to 10 EC2 instances synchronously
+  greenops --async --limit 50             # Analyze up to 50 EC2 instances asynchronously
+  greenops --resources ec2,s3,rds --limit 30 --limit-per-type s3=5
+                                          # At most 5 buckets, the rest split between EC2 and RDS
+  greenops --output results.json          # Save results to a file
+  greenops --format html --output r.html  # Write an HTML report for Confluence or email
+  greenops --region eu-west-1             # Specify AWS region
+  greenops --regions eu-west-1,us-east-1  # Scan several regions in one run
+  greenops --regions all                  # Scan every enabled region
+  greenops --org --assume-role GreenOpsScanner
+                                          # Scan every account of the organization
+  greenops --org --assume-role GreenOpsScanner --accounts 111111111111,222222222222 --job-per-account
+                                          # Scan two accounts and submit one job for each
+  greenops --metrics-days 30              # Base recommendations on 30 days of metrics
+  greenops --profile prod                 # Use specific AWS profile
+  greenops --debug                        # Enable debug logging
+  greenops --include-tag team=payments    # Only scan resources owned by one team
+  greenops --exclude-tag env=dev          # Skip development resources
+  greenops --no-wait                      # Submit a job and print its ID
+  greenops --no-cache                     # Re-analyze resources the API analyzed recently
+  greenops --ttl-days 30                  # Keep the job and its results for 30 days
+  greenops --group-similar                # Summarize fleets of near-identical resources once
+  greenops --dry-run                      # Show exactly what would be sent, without sending it
+  greenops --emit-scripts remediation/    # Write reviewable scripts that act on the findings
+  greenops --max-analysis-cost 2          # Ask before an analysis estimated above $2 of Bedrock calls
+  greenops --save-scan scan.json          # Keep the scanned resources for later runs
+  greenops --input scan.json              # Analyze a saved or exported scan without calling AWS
+  greenops --local                        # Call Bedrock in your own account instead of the API
+  greenops --local --prompts-dir prompts  # Analyze with your own prompt templates
+  greenops jobs list --status failed      # List your recent jobs, here only the failed ones
+  greenops jobs status <job-id>           # Show progress of a submitted job
+  greenops jobs results <job-id>          # Wait for and display a job's results
+  greenops jobs inspect <job-id>          # Print the resources a job was submitted with
+  greenops jobs retry <job-id>            # Queue a finished job's failed items again
+  greenops jobs cancel <job-id>           # Stop processing a submitted job
+  greenops jobs requeue-dlq               # Move dead-lettered work items back to the queue (admin key)
+  greenops diff old.json new.json         # Compare two --format json reports, e.g. month over month
+  greenops history 10                     # Show the last 10 runs with CO2 and cost trends
+  greenops search "idle dev instances" --input report.json
+                                          # Find the resources whose analyses match a question
+  greenops --fail-on-savings 500          # CI gate: exit 2 if over $500/month could be saved
+  greenops --fail-on-idle 1               # CI gate: exit 2 if any EC2 instance is idle
+  greenops --version                      # Show which build is installed
+
+Exit Codes:
+  0  Success (and no threshold exceeded)
+  1  Scan, API or output error, or an async job that failed or timed out
+  2  Potential savings exceeded --fail-on-savings or --fail-on-co2, or idle instances reached --fail-on-idle
+     (or the "ci" config section)
+  130  Interrupted by Ctrl-C or SIGTERM; an async job keeps running and can be resumed
 
-	// Initialize request payload
-	requestPayload := map[string]interface{}{}
-	totalResourceCount := 0
+`)
+	flag.PrintDefaults()
+}
 
-	// Process EC2 instances
-	if instances, ok := scanResults["ec2"].([]pkg.Instance); ok && len(instances) > 0 {
-		log.Printf("Found %d EC2 instances for analysis", len(instances))
-		requestPayload["instances"] = instances
-		totalResourceCount += len(instances)
+// parseSubcommandArgs parses flags interleaved with subcommand arguments and returns the positional arguments
+func parseSubcommandArgs(args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		// flag.CommandLine exits on parse errors, so no error handling is needed here
+		flag.CommandLine.Parse(args)
+		args = flag.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
 	}
+	return positional
+}
 
-	// Process S3 buckets
-	if buckets, ok := scanResults["s3"].([]pkg.S3Bucket); ok && len(buckets) > 0 {
-		log.Printf("Found %d S3 buckets for analysis", len(buckets))
-		requestPayload["s3_buckets"] = buckets
-		totalResourceCount += len(buckets)
+func main() {
+	// Parse command-line flags
+	flag.Parse()
+
+	// Subcommands (e.g. "jobs status <id>") may be followed by more flags
+	var command []string
+	if flag.NArg() > 0 {
+		command = parseSubcommandArgs(flag.Args())
 	}
 
-	// Process RDS instances
-	if rdsInstances, ok := scanResults["rds"].([]pkg.RDSInstance); ok && len(rdsInstances) > 0 {
-		log.Printf("Found %d RDS instances for analysis", len(rdsInstances))
-		requestPayload["rds_instances"] = rdsInstances
-		totalResourceCount += len(rdsInstances)
+	// The logger only writes to stderr, so stdout carries nothing but the report
+	logLevel, logFlags := pkg.LevelInfo, 0
+	if verbose {
+		logLevel = pkg.LevelDebug
+	}
+	if debug {
+		// include file/line info for debug
+		logLevel, logFlags = pkg.LevelDebug, log.LstdFlags|log.Lshortfile
+	}
+	pkg.SetLogger(pkg.NewLogger(logLevel, logFlags))
+
+	// Reports name the build that generated them
+	build := pkg.NewBuildInfo(version, commit, date)
+	pkg.SetBuildInfo(build)
+	if showVersion {
+		fmt.Printf("greenops %s\ncommit: %s\nbuilt: %s\ngo: %s %s/%s\n",
+			build.Version, build.Commit, build.Date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		return
 	}
 
-	if totalResourceCount == 0 {
-		log.Println("No resources found to analyze.")
+	// Show help only if explicitly requested with -h or --help
+	if len(os.Args) == 2 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
+		printUsageInfo()
+		return
+	}
+	// Handle configuration
+	if generateConf {
+		initConfig()
 		return
 	}
 
-	// Prepare request payload
-	requestBody, err := json.Marshal(requestPayload)
+	// Defaults, then the config file, then GREENOPS_* variables, then the flags given
+	cfg, cfgPath, err := pkg.LoadConfig(pkg.ConfigSources{Path: configFile, Flags: applyFlags})
 	if err != nil {
-		log.Fatalf("Failed to marshal request: %v", err)
+		pkg.Fatalf("%v", err)
 	}
-
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: time.Duration(cfg.API.Timeout) * time.Second,
+	if cfgPath != "" {
+		pkg.Debugf("Using configuration from %s", cfgPath)
 	}
 
-	// Process based on mode (sync or async)
-	if asyncMode {
-		// log.Printf("Using asynchronous mode for processing %d resources...", totalResourceCount)
+	// Report every invalid setting at once, before anything is scanned
+	problems := validateFlags(cfg)
+	var cfgProblems pkg.ValidationErrors
+	if errors.As(cfg.Validate(), &cfgProblems) {
+		problems = append(cfgProblems, problems...)
+	}
+	if len(problems) > 0 {
+		fatalInvalidConfig(problems)
+	}
+	tagFilters, err := pkg.ParseTagFilterSet(cfg.Scan.TagFilters.Include, cfg.Scan.TagFilters.Exclude)
+	if err != nil {
+		pkg.Fatalf("Invalid tag filter: %v", err)
+	}
+	if localMode {
+		configureLocalAnalysis(cfg)
+	}
 
-		// Send async request
-		req, err := http.NewRequestWithContext(ctx, "POST", cfg.API.URL, bytes.NewBuffer(requestBody))
-		if err != nil {
-			log.Fatalf("Failed to create HTTP request: %v", err)
+	// Set up AWS context
+	// Ctrl-C or SIGTERM cancels the context so scanning and polling stop immediately;
+	// once it has, a second Ctrl-C kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Dispatch subcommands, which talk to the GreenOps API or only read local reports
+	if len(command) > 0 {
+		switch command[0] {
+		case "jobs":
+			runJobsCommand(ctx, cfg, command[1:])
+		case "diff":
+			runDiffCommand(cfg, command[1:])
+		case "history":
+			runHistoryCommand(cfg, command[1:])
+		case "search":
+			runSearchCommand(ctx, cfg, command[1:])
+		default:
+			pkg.Fatalf("Unknown command %q", command[0])
 		}
-		req.Header.Set("Content-Type", "application/json")
+		return
+	}
 
-		// Send request
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatalf("Failed to send request: %v", err)
-		}
+	// AWS access is needed to scan, to call Bedrock locally and to look up prices and spend
+	var awsCfg aws.Config
+	if inputFile == "" || localMode || cfg.Pricing.Mode == pkg.PricingModeLive || cfg.CostExplorer.Enabled {
+		awsCfg = loadAWSConfig(ctx, cfg)
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+	// Either replay a saved scan or scan the account now
+	var payload pkg.ScanPayload
+	if inputFile != "" {
+		payload, err = pkg.LoadScanPayload(inputFile)
 		if err != nil {
-			log.Fatalf("Failed to read response: %v", err)
+			pkg.Fatalf("Invalid scan file: %v", err)
 		}
-
-		if resp.StatusCode != http.StatusAccepted {
-			log.Fatalf("API returned error status %d: %s", resp.StatusCode, body)
-		}
-
-		// Parse job ID from response
-		var jobResponse struct {
-			JobID      string `json:"job_id"`
-			Status     string `json:"status"`
-			TotalItems int    `json:"total_items"`
+		pkg.Infof("Loaded %d resources from %s", payload.Count(), inputFile)
+	} else {
+		if orgMode {
+			payload = scanOrganization(ctx, awsCfg, cfg, tagFilters)
+		} else {
+			payload = scanAccount(ctx, awsCfg, cfg, tagFilters)
 		}
-
-		err = json.Unmarshal(body, &jobResponse)
-		if err != nil {
-			log.Fatalf("Failed to parse job response: %v", err)
+		payload.Metadata = reportMetadata(ctx, awsCfg, cfg, payload)
+		if saveScan != "" {
+			if err := pkg.SaveScanPayload(saveScan, payload); err != nil {
+				pkg.Fatalf("Failed to save scan: %v", err)
+			}
+			pkg.Infof("Scan saved to %s", saveScan)
 		}
+	}
 
-		log.Printf("Job submitted: ID=%s, Status=%s, Items=%d",
-			jobResponse.JobID, jobResponse.Status, jobResponse.TotalItems)
+	// Live prices travel with the resources, so the API worker and --local use them alike
+	if cfg.Pricing.Mode == pkg.PricingModeLive {
+		pkg.Infof("Looking up EC2 and RDS prices with the AWS Pricing API...")
+		pkg.NewPricingClientFromConfig(awsCfg).ResolveLivePrices(ctx, &payload)
+	}
 
-		// Poll for results
-		report, err := pollForJobResults(ctx, jobResponse.JobID, cfg, client)
-		if err != nil {
-			log.Fatalf("Failed to get job results: %v", err)
+	// Actual spend is optional; accounts without Cost Explorer still get estimates
+	if cfg.CostExplorer.Enabled {
+		pkg.Infof("Fetching actual spend from Cost Explorer...")
+		if err := pkg.AttachActualCosts(ctx, pkg.NewCostExplorerClientFromConfig(awsCfg), &payload, cfg.CostExplorer.TagKey); err != nil {
+			pkg.Warnf("Unable to get actual spend from Cost Explorer, continuing with estimates only: %v", err)
 		}
+	}
 
-		// Display results
-		if outputFile != "" {
-			// Write to file
-			file, err := os.Create(outputFile)
-			if err != nil {
-				log.Fatalf("Failed to create output file: %v", err)
-			}
-			defer file.Close()
+	if len(payload.Instances) > 0 {
+		pkg.Infof("Found %d EC2 instances for analysis", len(payload.Instances))
+	}
+	if len(payload.S3Buckets) > 0 {
+		pkg.Infof("Found %d S3 buckets for analysis", len(payload.S3Buckets))
+	}
+	if len(payload.RDSInstances) > 0 {
+		pkg.Infof("Found %d RDS instances for analysis", len(payload.RDSInstances))
+	}
+	if len(payload.EBSVolumes) > 0 {
+		pkg.Infof("Found %d EBS volumes for analysis", len(payload.EBSVolumes))
+	}
+	if len(payload.LambdaFunctions) > 0 {
+		pkg.Infof("Found %d Lambda functions for analysis", len(payload.LambdaFunctions))
+	}
+	if len(payload.LoadBalancers) > 0 {
+		pkg.Infof("Found %d load balancers for analysis", len(payload.LoadBalancers))
+	}
+	if len(payload.NetworkResources) > 0 {
+		pkg.Infof("Found %d idle Elastic IPs and NAT gateways for analysis", len(payload.NetworkResources))
+	}
+	if len(payload.DynamoTables) > 0 {
+		pkg.Infof("Found %d DynamoDB tables for analysis", len(payload.DynamoTables))
+	}
+	if len(payload.ElastiCacheClusters) > 0 {
+		pkg.Infof("Found %d ElastiCache clusters for analysis", len(payload.ElastiCacheClusters))
+	}
+	if len(payload.Snapshots) > 0 {
+		pkg.Infof("Found %d stale EBS snapshots for analysis", len(payload.Snapshots))
+	}
 
-			// Use our formatter for better output
-			pkg.FormatAnalysisReport(file, report, false) // No colors in file output
-			log.Printf("Results saved to %s", outputFile)
-		} else {
-			// Use colors if stdout is a terminal and colors are enabled
-			useColors := isTerminal(os.Stdout) && cfg.Output.Colors
+	totalResourceCount := payload.Count()
+	if totalResourceCount == 0 {
+		pkg.Infof("No resources found to analyze.")
+		return
+	}
 
-			// Print to console using our formatter
-			pkg.FormatAnalysisReport(os.Stdout, report, useColors)
-		}
-	} else {
-		// Synchronous mode
-		log.Printf("Sending %d resources to GreenOps API for analysis with timeout of %d seconds...",
-			totalResourceCount, cfg.API.Timeout)
-		httpCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.API.Timeout)*time.Second)
-		defer cancel()
+	payload.NoCache = noCache
+	payload.TTLDays = ttlDays
+	if !localMode {
+		// Only an explicit --model asks the API for a model; its routing applies otherwise
+		payload.Model = genModel
+	}
+
+	// Estimate the analysis before anything is sent
+	estimate := printAnalysisEstimate(cfg, payload)
 
-		// Create HTTP request with timeout
-		req, err := http.NewRequestWithContext(httpCtx, "POST", cfg.API.URL, bytes.NewBuffer(requestBody))
+	// Show the payload instead of sending it
+	if dryRun {
+		requestBody, err := json.Marshal(payload)
 		if err != nil {
-			log.Fatalf("Failed to create HTTP request: %v", err)
+			pkg.Fatalf("Failed to marshal request: %v", err)
 		}
-		req.Header.Set("Content-Type", "application/json")
-
-		// Add retry logic for HTTP requests
-		maxRetries := 3
-		var resp *http.Response
+		writeDryRun(requestBody)
+		return
+	}
 
-		for attempt := 0; attempt < maxRetries; attempt++ {
-			if attempt > 0 {
-				log.Printf("Retry attempt %d/%d after waiting %d seconds", attempt+1, maxRetries, attempt*5)
-				time.Sleep(time.Duration(attempt*5) * time.Second) // Exponential backoff
-			}
+	if !confirmAnalysisCost(cfg, estimate) {
+		pkg.Infof("Analysis cancelled; nothing was sent")
+		return
+	}
+	analysisStarted = time.Now()
 
-			resp, err = client.Do(req)
-			if err == nil {
-				break // Success, exit retry loop
-			}
+	// Analyze with Bedrock from this machine; nothing is sent to the GreenOps API
+	if localMode {
+		report, account := analyzeLocally(ctx, awsCfg, cfg, payload)
+		writeReport(ctx, report, account, payload.Metadata, cfg)
+		enforceThresholds(report, cfg)
+		return
+	}
 
-			if attempt == maxRetries-1 || (!strings.Contains(err.Error(), "timeout") &&
-				!strings.Contains(err.Error(), "deadline exceeded")) {
-				// Last attempt or non-timeout error
-				if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "deadline exceeded") {
-					log.Fatalf("API request timed out after %d retries. Try increasing the timeout with --timeout or reduce the number of resources with --limit", maxRetries)
-				}
-				log.Fatalf("API request failed after %d retries: %v", attempt+1, err)
-			}
+	// Create API client
+	api := newAPIClient(cfg)
 
-			log.Printf("Request attempt %d failed: %v. Retrying...", attempt+1, err)
+	// Process based on mode (sync or async)
+	if jobPerAccount {
+		submitJobPerAccount(ctx, cfg, api, payload)
+	} else if asyncMode {
+		// Send async request
+		jobResponse, err := api.SubmitAnalysis(ctx, payload)
+		exitIfInterrupted(ctx, "Interrupted before the job was submitted")
+		if err != nil {
+			pkg.Fatalf("Failed to submit job: %v", explainAPIError(err))
 		}
 
-		defer resp.Body.Close()
-
-		// Read the response
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalf("Failed to read API response: %v", err)
+		pkg.Infof("Job submitted: ID=%s, Status=%s, Items=%d",
+			jobResponse.JobID, jobResponse.Status, jobResponse.TotalItems)
+		if jobResponse.ExpiresAt > 0 {
+			pkg.Infof("Results available until %s", formatExpiry(jobResponse.ExpiresAt))
+		}
+		if jobResponse.CachedItems > 0 {
+			pkg.Infof("%d of %d items were answered from the analysis cache; use --no-cache to analyze them again",
+				jobResponse.CachedItems, jobResponse.TotalItems)
 		}
 
-		// Check response status
-		if resp.StatusCode != http.StatusOK {
-			if resp.StatusCode == http.StatusServiceUnavailable {
-				log.Fatalf("API service unavailable (503). The service might be experiencing high load or temporary issues with the underlying models. Try again later or with fewer resources.")
-			} else {
-				log.Fatalf("API returned error status %d: %s", resp.StatusCode, respBody)
-			}
+		// Hand the job ID back to the caller instead of waiting for results
+		if noWait {
+			fmt.Println(jobResponse.JobID)
+			pkg.Infof("Retrieve results later with: greenops jobs results %s", jobResponse.JobID)
+			return
 		}
 
-		// Parse the response
-		var apiResponse ServerResponse
-		if err := json.Unmarshal(respBody, &apiResponse); err != nil {
-			log.Fatalf("Failed to parse API response: %v", err)
+		// Poll for results
+		report, st, err := pollForJobResults(ctx, jobResponse.JobID, api)
+		if st.Metadata == nil {
+			st.Metadata = payload.Metadata
 		}
 
-		// Output the analysis results
-		if outputFile != "" {
-			// Write to file
-			file, err := os.Create(outputFile)
-			if err != nil {
-				log.Fatalf("Failed to create output file: %v", err)
+		// Display results
+		handlePolledReport(ctx, cfg, jobResponse.JobID, report, st, err)
+	} else {
+		// Synchronous mode; the client retries timeouts and gateway errors
+		pkg.Infof("Sending %d resources to GreenOps API for analysis with timeout of %d seconds...",
+			totalResourceCount, cfg.API.Timeout)
+		report, err := api.Analyze(ctx, payload)
+		exitIfInterrupted(ctx, "Interrupted while waiting for the analysis")
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				pkg.Fatalf("API request timed out. Try increasing the timeout with --timeout or reduce the number of resources with --limit")
 			}
-			defer file.Close()
-
-			pkg.FormatAnalysisReport(file, apiResponse.Report, false) // No colors in file output
-			log.Printf("Results saved to %s", outputFile)
-		} else {
-			// Use colors if stdout is a terminal and colors are enabled
-			useColors := isTerminal(os.Stdout) && cfg.Output.Colors
-
-			// Print to console using our formatter
-			pkg.FormatAnalysisReport(os.Stdout, apiResponse.Report, useColors)
+			pkg.Fatalf("API request failed: %v", explainAPIError(err))
 		}
+
+		// Output the analysis results
+		writeReport(ctx, report, nil, payload.Metadata, cfg)
+		enforceThresholds(report, cfg)
 	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// defaultSearchMatches is how many matches `greenops search` prints unless --top says otherwise
+const defaultSearchMatches = 5
+
+// runSearchCommand handles `greenops search "<query>" --input report.json` and
+// `greenops search "<query>" <job-id>`. The query is embedded with Bedrock from this machine,
+// and the report's resources are ranked against it by the embeddings stored with their
+// analyses, so searching a report file needs no GreenOps API.
+func runSearchCommand(ctx context.Context, cfg *pkg.Config, args []string) {
+	usage := `Usage: greenops search "<query>" --input <report.json>, or greenops search "<query>" <job-id>`
+	if len(args) == 0 || len(args) > 2 || strings.TrimSpace(args[0]) == "" {
+		pkg.Fatalf("%s", usage)
+	}
+	query := args[0]
+
+	var report []pkg.ReportItem
+	var err error
+	switch {
+	case inputFile != "" && len(args) == 2:
+		pkg.Fatalf("Search either a report file with --input or a job, not both")
+	case inputFile != "":
+		report, err = pkg.LoadJSONReport(inputFile)
+		if err != nil {
+			pkg.Fatalf("Failed to load report: %v", err)
+		}
+	case len(args) == 2:
+		// The API leaves embeddings out of results unless they are asked for
+		withEmbeddings = true
+		report, err = newAPIClient(cfg).GetJobResults(ctx, args[1])
+		if err != nil {
+			pkg.Fatalf("Failed to get job results: %v", explainAPIError(err))
+		}
+	default:
+		pkg.Fatalf("%s", usage)
+	}
+
+	embedded, dimensions := 0, 0
+	for _, item := range report {
+		if len(item.Embedding) > 0 {
+			embedded++
+			dimensions = len(item.Embedding)
+		}
+	}
+	if embedded == 0 {
+		pkg.Fatalf("None of the %d resources in the report has an embedding to search; write the report with --format json --include-embeddings", len(report))
+	}
+
+	client := bedrockruntime.NewFromConfig(loadAWSConfig(ctx, cfg))
+	queryEmbedding, err := pkg.EmbedText(ctx, client, cfg.Bedrock.EmbedModel, query)
+	if err != nil {
+		pkg.Fatalf("Failed to embed the query: %v", err)
+	}
+
+	matches := pkg.TopKByEmbedding(queryEmbedding, report, searchTop)
+	if len(matches) == 0 {
+		pkg.Fatalf("The query embedding from %s has %d dimensions but the report's have %d; search with the --embed-model that analyzed the resources",
+			cfg.Bedrock.EmbedModel, len(queryEmbedding), dimensions)
+	}
+	pkg.Debugf("Ranked %d of %d resources against the query", embedded, len(report))
+
+	if jsonOutput || cfg.Output.Format == "json" {
+		if err := pkg.FormatSearchResultsJSON(os.Stdout, query, matches); err != nil {
+			pkg.Fatalf("Failed to write search results: %v", err)
+		}
+		return
+	}
+	pkg.FormatSearchResults(os.Stdout, query, matches, isTerminal(os.Stdout) && cfg.Output.Colors)
+}
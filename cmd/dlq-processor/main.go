@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// Handler consumes the dead-letter queue of the work queue. Each message is a work item the
+// worker never finished; it is recorded as permanently failed so its job can still finish.
+// Messages whose accounting fails are reported back as batch item failures and delivered
+// again, and messages that are not work items are dropped.
+func Handler(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	pkg.Debugf("DLQ handler invoked with %d records", len(sqsEvent.Records))
+	// Load AWS config
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		pkg.Errorf("unable to load AWS config: %v", err)
+		return events.SQSEventResponse{}, fmt.Errorf("unable to load AWS config: %v", err)
+	}
+
+	// Create DynamoDB client
+	dynamoClient := dynamodb.NewFromConfig(cfg)
+
+	var failures []events.SQSBatchItemFailure
+	for _, record := range sqsEvent.Records {
+		pkg.Debugf("Processing dead-lettered message: %s", record.MessageId)
+
+		var workItem pkg.WorkItem
+		if err := json.Unmarshal([]byte(record.Body), &workItem); err != nil || workItem.JobID == "" {
+			pkg.Errorf("Dropping dead-lettered message %s, which is not a work item: %v", record.MessageId, err)
+			continue
+		}
+
+		if err := pkg.RecordDeadLetteredItem(ctx, dynamoClient, workItem); err != nil {
+			pkg.Warnf("Failed to record dead-lettered item %d of job %s, leaving it for retry: %v", workItem.ItemIndex, workItem.JobID, err)
+			failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+
+	return events.SQSEventResponse{BatchItemFailures: failures}, nil
+}
+
+// Build information, set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+func main() {
+	pkg.SetLogger(pkg.NewLoggerFromEnv())
+	build := pkg.NewBuildInfo(version, commit, date)
+	pkg.SetBuildInfo(build)
+	pkg.Infof("GreenOps DLQ processor %s starting", build)
+
+	// Jobs finished by a dead-lettered item are announced on NOTIFY_TOPIC_ARN, when it is set
+	if topicARN := os.Getenv("NOTIFY_TOPIC_ARN"); topicARN != "" {
+		awsCfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			pkg.Fatalf("unable to load AWS config for job notifications: %v", err)
+		}
+		pkg.SetJobNotifier(pkg.NewSNSClient(awsCfg), topicARN)
+		pkg.Infof("Publishing job completions to %s", topicARN)
+	}
+	lambda.Start(Handler)
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
 	pkg "github.com/alexalbu001/greenops/pkg"
@@ -17,63 +21,260 @@ import (
 
 // ServerRequest represents incoming payload of resources to analyze
 type ServerRequest struct {
-	Instances    []pkg.Instance    `json:"instances"`
-	S3Buckets    []pkg.S3Bucket    `json:"s3_buckets"`
-	RDSInstances []pkg.RDSInstance `json:"rds_instances"`
+	Instances           []pkg.Instance           `json:"instances"`
+	S3Buckets           []pkg.S3Bucket           `json:"s3_buckets"`
+	RDSInstances        []pkg.RDSInstance        `json:"rds_instances"`
+	EBSVolumes          []pkg.EBSVolume          `json:"ebs_volumes"`
+	LambdaFunctions     []pkg.LambdaFunction     `json:"lambda_functions"`
+	LoadBalancers       []pkg.LoadBalancer       `json:"load_balancers"`
+	NetworkResources    []pkg.NetworkResource    `json:"network_resources"`
+	DynamoTables        []pkg.DynamoTable        `json:"dynamo_tables"`
+	ElastiCacheClusters []pkg.ElastiCacheCluster `json:"elasticache_clusters"`
+	Snapshots           []pkg.EBSSnapshot        `json:"snapshots"`
+	NoCache             bool                     `json:"no_cache"`
+	TTLDays             int                      `json:"ttl_days"`
+	Model               string                   `json:"model"`
+	Metadata            *pkg.ReportMetadata      `json:"metadata"`
+}
+
+// apiKeys are the keys accepted in the x-api-key header, from the API_KEYS variable.
+// When none are set every request is accepted.
+var apiKeys []string
+
+// adminKeys are the keys accepted on the /admin routes, from the ADMIN_API_KEYS variable. They
+// are accepted on every other route too. When none are set the /admin routes are disabled.
+var adminKeys []string
+
+// awsClients are the AWS service clients a request is handled with
+type awsClients struct {
+	dynamo pkg.DynamoJobStore
+	sqs    interface {
+		pkg.SQSQueueAPI
+		pkg.SQSRedriveAPI
+	}
+	s3 interface {
+		pkg.S3ResultStore
+		pkg.S3RequestStore
+	}
+}
+
+// newAWSClients creates the clients of a request from the default AWS config. Tests replace
+// it to serve requests from fakes.
+var newAWSClients = func(ctx context.Context) (*awsClients, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &awsClients{
+		dynamo: dynamodb.NewFromConfig(cfg),
+		sqs:    sqs.NewFromConfig(cfg),
+		s3:     s3.NewFromConfig(cfg),
+	}, nil
+}
+
+// callerIdentity identifies the caller of a request by its API key; anonymous callers have none
+func callerIdentity(apiReq events.APIGatewayV2HTTPRequest) string {
+	return pkg.CallerIdentity(pkg.HeaderValue(apiReq.Headers, pkg.APIKeyHeader))
+}
+
+// respondJSON encodes body as the JSON response with the given status code
+func respondJSON(statusCode int, body interface{}) events.APIGatewayV2HTTPResponse {
+	data, err := json.Marshal(body)
+	if err != nil {
+		pkg.Errorf("failed to encode response: %v", err)
+		statusCode = 500
+		data = []byte(`{"error":"failed to encode response","code":"` + pkg.CodeInternal + `"}`)
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: statusCode,
+		Body:       string(data),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+}
+
+// respondError answers with an APIError carrying a machine-readable code
+func respondError(statusCode int, code, message string) events.APIGatewayV2HTTPResponse {
+	return respondJSON(statusCode, pkg.APIError{Message: message, Code: code})
+}
+
+// validationErrorResponse answers a request that failed ScanPayload.ValidateRequest: 413 when it
+// carries too many resources, otherwise 400 with every invalid field
+func validationErrorResponse(err error) events.APIGatewayV2HTTPResponse {
+	var tooLarge *pkg.PayloadTooLargeError
+	if errors.As(err, &tooLarge) {
+		return respondJSON(413, pkg.APIError{Message: tooLarge.Error(), Code: pkg.CodeTooManyItems, MaxItems: tooLarge.Max})
+	}
+
+	var fieldErrs pkg.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		fieldErrs = pkg.ValidationErrors{{Field: "", Message: err.Error()}}
+	}
+	return respondJSON(400, pkg.APIError{Message: "invalid resources in request", Code: pkg.CodeInvalidResources, Errors: fieldErrs})
+}
+
+// jobErrorResponse answers a request for a job that could not be read: 404 when it does not
+// exist, 410 when it is past its TTL but not yet deleted, and 500 when DynamoDB failed
+func jobErrorResponse(err error) events.APIGatewayV2HTTPResponse {
+	switch {
+	case errors.Is(err, pkg.ErrJobNotFound):
+		return respondError(404, pkg.CodeJobNotFound, "job not found")
+	case errors.Is(err, pkg.ErrJobExpired):
+		// The error names the time the job expired at, which depends on its TTL
+		return respondError(410, pkg.CodeJobExpired, err.Error()+"; its results are no longer kept")
+	}
+	return respondError(500, pkg.CodeInternal, err.Error())
+}
+
+// respondNDJSON answers with one JSON-encoded result per line, and the job's total item count
+// in the X-Total-Items header
+func respondNDJSON(results []pkg.ReportItem, totalItems int) events.APIGatewayV2HTTPResponse {
+	var body strings.Builder
+	encoder := json.NewEncoder(&body)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			pkg.Errorf("failed to encode response: %v", err)
+			return respondError(500, pkg.CodeInternal, "failed to encode response")
+		}
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: 200,
+		Body:       body.String(),
+		Headers: map[string]string{
+			"Content-Type":  "application/x-ndjson",
+			"X-Total-Items": strconv.Itoa(totalItems),
+		},
+	}
+}
+
+// resultsPage reads the offset and limit query parameters of the results routes. A request
+// with neither is not paged; one with only an offset gets pkg.DefaultResultsPageSize items.
+func resultsPage(apiReq events.APIGatewayV2HTTPRequest) (offset, limit int, paged bool, err error) {
+	offsetValue := apiReq.QueryStringParameters[pkg.ResultsOffsetParam]
+	limitValue := apiReq.QueryStringParameters[pkg.ResultsLimitParam]
+	if offsetValue == "" && limitValue == "" {
+		return 0, 0, false, nil
+	}
+
+	limit = pkg.DefaultResultsPageSize
+	if offsetValue != "" {
+		if offset, err = strconv.Atoi(offsetValue); err != nil || offset < 0 {
+			return 0, 0, false, fmt.Errorf("%s must be a non-negative integer, got %q", pkg.ResultsOffsetParam, offsetValue)
+		}
+	}
+	if limitValue != "" {
+		if limit, err = strconv.Atoi(limitValue); err != nil || limit < 1 || limit > pkg.MaxResultsPageSize {
+			return 0, 0, false, fmt.Errorf("%s must be between 1 and %d, got %q", pkg.ResultsLimitParam, pkg.MaxResultsPageSize, limitValue)
+		}
+	}
+	return offset, limit, true, nil
+}
+
+// jobsListParams reads the status, limit and next_token query parameters of GET /jobs
+func jobsListParams(apiReq events.APIGatewayV2HTTPRequest) (status pkg.JobStatus, limit int, nextToken string, err error) {
+	if value := apiReq.QueryStringParameters[pkg.JobsStatusParam]; value != "" {
+		if status, err = pkg.ParseJobStatus(value); err != nil {
+			return "", 0, "", err
+		}
+	}
+	limit = pkg.DefaultJobsPageSize
+	if value := apiReq.QueryStringParameters[pkg.JobsLimitParam]; value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > pkg.MaxJobsPageSize {
+			return "", 0, "", fmt.Errorf("%s must be between 1 and %d, got %q", pkg.JobsLimitParam, pkg.MaxJobsPageSize, value)
+		}
+	}
+	return status, limit, apiReq.QueryStringParameters[pkg.JobsNextTokenParam], nil
+}
+
+// includeEmbeddings reads the include_embeddings query parameter; embeddings are left out of
+// results unless it is true
+func includeEmbeddings(apiReq events.APIGatewayV2HTTPRequest) (bool, error) {
+	value := apiReq.QueryStringParameters[pkg.IncludeEmbeddingsParam]
+	if value == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", pkg.IncludeEmbeddingsParam, value)
+	}
+	return include, nil
 }
 
 // Handler is the Lambda entrypoint
 func Handler(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	log.Printf("Received event: %s", apiReq.RawPath)
+	pkg.Debugf("Received event: %s", apiReq.RawPath)
+
+	// Every route, including job status and results, needs a key once keys are configured
+	apiKey := pkg.HeaderValue(apiReq.Headers, pkg.APIKeyHeader)
+	if len(apiKeys) > 0 && !pkg.ValidAPIKey(apiKeys, apiKey) && !pkg.ValidAPIKey(adminKeys, apiKey) {
+		pkg.Warnf("rejected %s request without a valid API key", apiReq.RouteKey)
+		return respondError(401, pkg.CodeUnauthorized, "missing or invalid API key"), nil
+	}
+
+	// Check if this is a request to requeue the dead-letter queue
+	if apiReq.RouteKey == "POST /admin/dlq/requeue" {
+		return HandleDLQRequeue(ctx, apiReq)
+	}
+
+	// Check if this is a request to list jobs
+	if apiReq.RouteKey == "GET /jobs" {
+		return HandleListJobs(ctx, apiReq)
+	}
 
 	// Check if this is a job status request
 	if apiReq.RouteKey == "GET /jobs/{id}" {
 		return HandleJobStatus(ctx, apiReq)
 	}
 
-	// Check if this is a job results request
-	if apiReq.RouteKey == "GET /jobs/{id}/results" {
+	// Check if this is a job results request, as one JSON document or one result per line
+	if apiReq.RouteKey == "GET /jobs/{id}/results" || apiReq.RouteKey == "GET /jobs/{id}/results/stream" {
 		return HandleJobResults(ctx, apiReq)
 	}
 
+	// Check if this is a request for the payload a job was submitted with
+	if apiReq.RouteKey == "GET /jobs/{id}/request" {
+		return HandleJobRequest(ctx, apiReq)
+	}
+
+	// Check if this is a request to queue a job's failed items again
+	if apiReq.RouteKey == "POST /jobs/{id}/retry" {
+		return HandleJobRetry(ctx, apiReq)
+	}
+
+	// Check if this is a job cancellation request
+	if apiReq.RouteKey == "DELETE /jobs/{id}" {
+		return HandleJobCancel(ctx, apiReq)
+	}
+
 	// Original analyze request
-	log.Printf("Received analyze request: %s", apiReq.Body)
+	pkg.Debugf("Received analyze request: %d bytes", len(apiReq.Body))
+	pkg.DebugPayload("Analyze request body", apiReq.Body)
 
 	var req ServerRequest
 	if err := json.Unmarshal([]byte(apiReq.Body), &req); err != nil {
-		log.Printf("invalid request payload: %v", err)
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 400,
-			Body:       `{"error":"invalid JSON payload"}`,
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		pkg.Warnf("invalid request payload: %v", err)
+		return respondError(400, pkg.CodeInvalidPayload, fmt.Sprintf("invalid JSON payload: %v", err)), nil
 	}
 
 	// Validate request
-	totalResources := len(req.Instances) + len(req.S3Buckets) + len(req.RDSInstances)
-	if totalResources == 0 {
-		log.Printf("request contained no resources to analyze")
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 400,
-			Body:       `{"error":"no resources provided in request"}`,
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+	payload := pkg.ScanPayload(req)
+	if payload.Count() == 0 {
+		pkg.Warnf("request contained no resources to analyze")
+		return respondError(400, pkg.CodeNoResources, "no resources provided in request"), nil
+	}
+	if err := payload.ValidateRequest(pkg.MaxRequestItems()); err != nil {
+		pkg.Warnf("rejected analyze request: %v", err)
+		return validationErrorResponse(err), nil
 	}
 
-	// Load AWS config for Bedrock, DynamoDB, and SQS
-	cfg, err := config.LoadDefaultConfig(ctx)
+	// Create the DynamoDB, SQS and S3 clients
+	clients, err := newAWSClients(ctx)
 	if err != nil {
-		log.Printf("unable to load AWS config: %v", err)
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       `{"error":"failed to initialize AWS client"}`,
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		pkg.Errorf("unable to load AWS config: %v", err)
+		return respondError(500, pkg.CodeInternal, "failed to initialize AWS client"), nil
 	}
 
-	// Create AWS service clients
-	dynamoClient := dynamodb.NewFromConfig(cfg)
-	sqsClient := sqs.NewFromConfig(cfg)
+	dynamoClient := clients.dynamo
+	sqsClient := clients.sqs
 
 	// Create job record with resource types
 	resourceTypes := []string{}
@@ -86,94 +287,113 @@ func Handler(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events
 	if len(req.RDSInstances) > 0 {
 		resourceTypes = append(resourceTypes, "rds")
 	}
+	if len(req.EBSVolumes) > 0 {
+		resourceTypes = append(resourceTypes, "ebs")
+	}
+	if len(req.LambdaFunctions) > 0 {
+		resourceTypes = append(resourceTypes, "lambda")
+	}
+	if len(req.LoadBalancers) > 0 {
+		resourceTypes = append(resourceTypes, "elb")
+	}
+	if len(req.NetworkResources) > 0 {
+		resourceTypes = append(resourceTypes, "network")
+	}
+	if len(req.DynamoTables) > 0 {
+		resourceTypes = append(resourceTypes, "dynamodb")
+	}
+	if len(req.ElastiCacheClusters) > 0 {
+		resourceTypes = append(resourceTypes, "elasticache")
+	}
+	if len(req.Snapshots) > 0 {
+		resourceTypes = append(resourceTypes, "snapshots")
+	}
 
-	jobID, err := pkg.CreateJob(ctx, dynamoClient, resourceTypes, totalResources)
+	// All snapshots share one work item, so the job tracks work items rather than resources
+	totalItems := payload.WorkItemCount()
+	job, err := pkg.CreateJob(ctx, dynamoClient, resourceTypes, totalItems, callerIdentity(apiReq), pkg.JobTTLDays(req.TTLDays), req.Metadata)
 	if err != nil {
-		log.Printf("failed to create job: %v", err)
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error":"failed to create job: %v"}`, err),
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
-	}
-
-	// Queue instances for processing
-	itemIndex := 0
-
-	// Queue EC2 instances
-	for i, instance := range req.Instances {
-		workItem := pkg.WorkItem{
-			JobID:     jobID,
-			ItemIndex: itemIndex + i,
-			ItemType:  "ec2",
-			Instance:  instance,
-		}
+		pkg.Errorf("failed to create job: %v", err)
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to create job: %v", err)), nil
+	}
+	jobID := job.JobID
 
-		err := pkg.QueueWorkItem(ctx, sqsClient, jobID, itemIndex+i, "ec2", workItem)
-		if err != nil {
-			log.Printf("failed to queue instance %s: %v", instance.InstanceID, err)
-			// Continue with other resources even if one fails
-		}
+	// Keep the payload so the resources the workers were given can be inspected later
+	if err := pkg.StoreJobRequest(ctx, dynamoClient, clients.s3, jobID, payload); err != nil {
+		pkg.Warnf("failed to store request: %v", err)
 	}
-	itemIndex += len(req.Instances)
 
-	// Queue S3 buckets
-	for i, bucket := range req.S3Buckets {
-		workItem := pkg.WorkItem{
-			JobID:     jobID,
-			ItemIndex: itemIndex + i,
-			ItemType:  "s3",
-			S3Bucket:  bucket,
-		}
+	// Build work items for every resource first so indices stay stable across types
+	workItems := payload.WorkItems(jobID, job.ExpirationTime)
 
-		err := pkg.QueueWorkItem(ctx, sqsClient, jobID, itemIndex+i, "s3", workItem)
-		if err != nil {
-			log.Printf("failed to queue bucket %s: %v", bucket.BucketName, err)
-			// Continue with other resources even if one fails
-		}
+	// Every item is tracked from the start, so GET /jobs/{id} can say which ones failed
+	if err := pkg.PutJobItems(ctx, dynamoClient, workItems); err != nil {
+		pkg.Warnf("%v", err)
 	}
-	itemIndex += len(req.S3Buckets)
 
-	for i, rdsInstance := range req.RDSInstances {
-		workItem := pkg.WorkItem{
-			JobID:       jobID,
-			ItemIndex:   itemIndex + i,
-			ItemType:    "rds",
-			RDSInstance: rdsInstance,
+	// Resources analyzed recently by the same model are completed from the analysis cache
+	// and never reach the queue
+	cachedItems := 0
+	if pkg.AnalysisCacheEnabled() {
+		workItems, cachedItems = pkg.ResolveCachedWorkItems(ctx, dynamoClient, workItems, req.NoCache)
+	}
+
+	// Queue in batches, retrying failed entries once
+	failures := pkg.QueueWorkItems(ctx, sqsClient, workItems)
+	byIndex := make(map[int]pkg.WorkItem, len(workItems))
+	for _, workItem := range workItems {
+		byIndex[workItem.ItemIndex] = workItem
+	}
+	if len(failures) > 0 {
+		pkg.Warnf("failed to queue %d work items, retrying", len(failures))
+		retry := make([]pkg.WorkItem, 0, len(failures))
+		for _, failure := range failures {
+			retry = append(retry, byIndex[failure.ItemIndex])
 		}
+		failures = pkg.QueueWorkItems(ctx, sqsClient, retry)
+	}
 
-		err := pkg.QueueWorkItem(ctx, sqsClient, jobID, itemIndex+i, "rds", workItem)
-		if err != nil {
-			log.Printf("failed to queue RDS instance %s: %v", rdsInstance.InstanceID, err)
-			// Continue with other resources even if one fails
+	// Items that never reached the queue would keep the job from finishing
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			pkg.Errorf("failed to queue work item %d: %v", failure.ItemIndex, failure.Err)
+			if err := pkg.UpdateJobItem(ctx, dynamoClient, byIndex[failure.ItemIndex], pkg.ItemFailed, "not queued: "+failure.Err.Error(), 0); err != nil {
+				pkg.Warnf("%v", err)
+			}
+		}
+		if err := pkg.ReduceJobTotal(ctx, dynamoClient, jobID, len(failures)); err != nil {
+			pkg.Errorf("failed to adjust job total: %v", err)
 		}
+		totalItems -= len(failures)
 	}
-	itemIndex += len(req.RDSInstances)
 
 	// Update job status to processing
-	err = pkg.UpdateJobStatus(ctx, dynamoClient, jobID, pkg.JobStatusProcessing)
+	// Only move on from pending: fast workers may already have finished the job
+	err = pkg.UpdateJobStatus(ctx, dynamoClient, jobID, pkg.JobStatusProcessing, pkg.JobStatusPending)
 	if err != nil {
-		log.Printf("failed to update job status: %v", err)
+		pkg.Errorf("failed to update job status: %v", err)
 		// Continue anyway, not critical
 	}
 
+	// With nothing queued no worker will finalize the job, e.g. when the cache answered every item
+	status := pkg.JobStatusProcessing
+	if len(workItems) == len(failures) {
+		if err := pkg.MaybeFinalizeJob(ctx, dynamoClient, jobID); err != nil {
+			pkg.Errorf("failed to finalize job: %v", err)
+		} else if current, err := pkg.GetJobStatus(ctx, dynamoClient, jobID); err == nil {
+			status = current
+		}
+	}
+
 	// Return job ID to client
-	return events.APIGatewayV2HTTPResponse{
-		StatusCode: 202, // Accepted
-		Body:       fmt.Sprintf(`{"job_id":"%s","status":"processing","total_items":%d}`, jobID, totalResources),
-		Headers:    map[string]string{"Content-Type": "application/json"},
-	}, nil
+	return respondJSON(202, pkg.JobAccepted{JobID: jobID, Status: status, TotalItems: totalItems, CachedItems: cachedItems, ExpiresAt: job.ExpirationTime}), nil
 }
 
 // HandleJobStatus handles GET /jobs/{id} requests
 func HandleJobStatus(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	jobID := apiReq.PathParameters["id"]
 	if jobID == "" {
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 400,
-			Body:       `{"error":"missing job ID"}`,
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		return respondError(400, pkg.CodeMissingJobID, "missing job ID"), nil
 	}
 
 	// Check for force_complete parameter
@@ -181,36 +401,31 @@ func HandleJobStatus(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest)
 	if apiReq.QueryStringParameters != nil {
 		_, forceComplete = apiReq.QueryStringParameters["force_complete"]
 	}
+	withEmbeddings, err := includeEmbeddings(apiReq)
+	if err != nil {
+		return respondError(400, pkg.CodeInvalidParameter, err.Error()), nil
+	}
 
-	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(ctx)
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
 	if err != nil {
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error":"failed to initialize AWS client: %v"}`, err),
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
 	}
 
-	// Create DynamoDB client
-	dynamoClient := dynamodb.NewFromConfig(cfg)
+	dynamoClient := clients.dynamo
+	s3Client := clients.s3
 
-	// Get job info
-	job, err := pkg.GetJob(ctx, dynamoClient, jobID)
+	// Get job info: the counters, read consistently so polls never see them go backwards, and
+	// without inline results, which are loaded below only when they are returned
+	job, err := pkg.GetJobWith(ctx, dynamoClient, jobID, pkg.JobReadOptions{WithoutResults: true, ConsistentRead: true})
 	if err != nil {
-		if err.Error() == "job not found" {
-			return events.APIGatewayV2HTTPResponse{
-				StatusCode: 404,
-				Body:       `{"error":"job not found"}`,
-				Headers:    map[string]string{"Content-Type": "application/json"},
-			}, nil
-		}
+		return jobErrorResponse(err), nil
+	}
 
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error":"failed to get job: %v"}`, err),
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+	// Only the caller who submitted the job may see it
+	if err := pkg.CheckJobAccess(job, callerIdentity(apiReq)); err != nil {
+		pkg.Warnf("denied access to job %s: %v", jobID, err)
+		return respondError(403, pkg.CodeForbidden, "job belongs to another caller"), nil
 	}
 
 	// If forceComplete=true and all items are processed, update status to completed
@@ -223,136 +438,341 @@ func HandleJobStatus(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest)
 			newStatus = pkg.JobStatusFailed
 		}
 
-		log.Printf("Forcing job %s status from %s to %s", jobID, job.Status, newStatus)
-		err = pkg.UpdateJobStatus(ctx, dynamoClient, jobID, newStatus)
+		pkg.Warnf("Forcing job %s status from %s to %s", jobID, job.Status, newStatus)
+		err = pkg.UpdateJobStatus(ctx, dynamoClient, jobID, newStatus, pkg.JobStatusProcessing)
 		if err != nil {
-			log.Printf("Warning: Failed to force update job status: %v", err)
+			pkg.Warnf("Failed to force update job status: %v", err)
 		} else {
 			// Update local job object to reflect new status
 			job.Status = newStatus
 		}
 	}
 
-	// Job is in a terminal state (completed or failed), return full result
-	if job.Status == pkg.JobStatusCompleted || job.Status == pkg.JobStatusFailed {
-		resultsJSON, err := json.Marshal(job.Results)
+	// Load the results only when they are going to be returned
+	if job.Status.IsTerminal() || (job.Status == pkg.JobStatusProcessing && job.CompletedItems+job.FailedItems >= job.TotalItems) {
+		job.Results, err = pkg.GetJobResults(ctx, dynamoClient, s3Client, job)
 		if err != nil {
-			return events.APIGatewayV2HTTPResponse{
-				StatusCode: 500,
-				Body:       fmt.Sprintf(`{"error":"failed to marshal results: %v"}`, err),
-				Headers:    map[string]string{"Content-Type": "application/json"},
-			}, nil
+			return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to get job results: %v", err)), nil
 		}
+		if !withEmbeddings {
+			job.Results = pkg.StripEmbeddings(job.Results)
+		}
+	}
+
+	status := pkg.NewJobStatusResponse(job)
+	if status.Items, err = pkg.QueryJobItems(ctx, dynamoClient, jobID); err != nil {
+		pkg.Warnf("%v", err)
+	}
 
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 200,
-			Body: fmt.Sprintf(
-				`{"job_id":"%s","status":"%s","total_items":%d,"completed_items":%d,"failed_items":%d,"results":%s}`,
-				job.JobID, job.Status, job.TotalItems, job.CompletedItems, job.FailedItems, string(resultsJSON),
-			),
-			Headers: map[string]string{"Content-Type": "application/json"},
-		}, nil
+	// Job is in a terminal state (completed or failed), return full result
+	if job.Status.IsTerminal() {
+		status.Results = job.Results
+		return respondJSON(200, status), nil
 	}
 
 	// Special case: if all items are processed but status is still "processing"
 	// Return HTTP 200 instead of 202 and include the results
 	if job.Status == pkg.JobStatusProcessing && (job.CompletedItems+job.FailedItems >= job.TotalItems) {
-		log.Printf("All items for job %s are processed but status is still %s. Returning results anyway.",
+		pkg.Warnf("All items for job %s are processed but status is still %s. Returning results anyway.",
 			job.JobID, job.Status)
 
-		resultsJSON, err := json.Marshal(job.Results)
-		if err != nil {
-			return events.APIGatewayV2HTTPResponse{
-				StatusCode: 500,
-				Body:       fmt.Sprintf(`{"error":"failed to marshal results: %v"}`, err),
-				Headers:    map[string]string{"Content-Type": "application/json"},
-			}, nil
-		}
-
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 200, // Return OK instead of Accepted in this case
-			Body: fmt.Sprintf(
-				`{"job_id":"%s","status":"%s","total_items":%d,"completed_items":%d,"failed_items":%d,"results":%s}`,
-				job.JobID, job.Status, job.TotalItems, job.CompletedItems, job.FailedItems, string(resultsJSON),
-			),
-			Headers: map[string]string{"Content-Type": "application/json"},
-		}, nil
+		status.Results = job.Results
+		return respondJSON(200, status), nil // Return OK instead of Accepted in this case
 	}
 
 	// Job is still processing, return progress
-	return events.APIGatewayV2HTTPResponse{
-		StatusCode: 202, // Accepted
-		Body: fmt.Sprintf(
-			`{"job_id":"%s","status":"%s","total_items":%d,"completed_items":%d,"failed_items":%d}`,
-			job.JobID, job.Status, job.TotalItems, job.CompletedItems, job.FailedItems,
-		),
-		Headers: map[string]string{"Content-Type": "application/json"},
-	}, nil
+	return respondJSON(202, status), nil
 }
 
-// New function to handle direct results access
+// HandleJobResults handles GET /jobs/{id}/results and GET /jobs/{id}/results/stream, returning
+// the results recorded so far as a JSON document or as NDJSON. Both take offset and limit to
+// return one page of the job's items.
 func HandleJobResults(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	jobID := apiReq.PathParameters["id"]
 	if jobID == "" {
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 400,
-			Body:       `{"error":"missing job ID"}`,
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		return respondError(400, pkg.CodeMissingJobID, "missing job ID"), nil
+	}
+	withEmbeddings, err := includeEmbeddings(apiReq)
+	if err != nil {
+		return respondError(400, pkg.CodeInvalidParameter, err.Error()), nil
+	}
+	offset, limit, paged, err := resultsPage(apiReq)
+	if err != nil {
+		return respondError(400, pkg.CodeInvalidParameter, err.Error()), nil
 	}
 
-	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(ctx)
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
 	if err != nil {
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error":"failed to initialize AWS client: %v"}`, err),
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
 	}
 
-	// Create DynamoDB client
-	dynamoClient := dynamodb.NewFromConfig(cfg)
+	dynamoClient := clients.dynamo
+	s3Client := clients.s3
 
-	// Get job directly from DynamoDB
-	log.Printf("Getting results for job %s", jobID)
-	job, err := pkg.GetJob(ctx, dynamoClient, jobID)
+	// Get job directly from DynamoDB; GetJobResultsRange loads inline results if there are any
+	pkg.Debugf("Getting results for job %s", jobID)
+	job, err := pkg.GetJobWith(ctx, dynamoClient, jobID, pkg.JobReadOptions{WithoutResults: true})
 	if err != nil {
-		if err.Error() == "job not found" {
-			return events.APIGatewayV2HTTPResponse{
-				StatusCode: 404,
-				Body:       `{"error":"job not found"}`,
-				Headers:    map[string]string{"Content-Type": "application/json"},
-			}, nil
-		}
+		return jobErrorResponse(err), nil
+	}
 
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error":"failed to get job: %v"}`, err),
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+	// Only the caller who submitted the job may see it
+	if err := pkg.CheckJobAccess(job, callerIdentity(apiReq)); err != nil {
+		pkg.Warnf("denied access to job %s: %v", jobID, err)
+		return respondError(403, pkg.CodeForbidden, "job belongs to another caller"), nil
 	}
 
-	// Return just the results array, even if job is not completed
-	resultsJSON, err := json.Marshal(job.Results)
+	// Results are stored per item for new jobs, and in S3 or inline on the job item for older
+	// ones; a page reads only its own items
+	job.Results, err = pkg.GetJobResultsRange(ctx, dynamoClient, s3Client, job, offset, limit)
 	if err != nil {
-		return events.APIGatewayV2HTTPResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error":"failed to marshal results: %v"}`, err),
-			Headers:    map[string]string{"Content-Type": "application/json"},
-		}, nil
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to get job results: %v", err)), nil
+	}
+
+	// Embeddings make up most of the response, so they are only sent on request
+	if !withEmbeddings {
+		job.Results = pkg.StripEmbeddings(job.Results)
 	}
 
 	// Log the number of results for debugging
-	log.Printf("Returning %d results for job %s", len(job.Results), jobID)
+	pkg.Infof("Returning %d results for job %s", len(job.Results), jobID)
 
-	return events.APIGatewayV2HTTPResponse{
-		StatusCode: 200,
-		Body:       fmt.Sprintf(`{"results":%s}`, string(resultsJSON)),
-		Headers:    map[string]string{"Content-Type": "application/json"},
-	}, nil
+	if apiReq.RouteKey == "GET /jobs/{id}/results/stream" {
+		return respondNDJSON(job.Results, job.TotalItems), nil
+	}
+
+	// Return just the results array, even if job is not completed
+	response := pkg.JobResultsResponse{Results: job.Results}
+	if paged {
+		response.Offset, response.Limit, response.TotalItems = offset, limit, job.TotalItems
+	}
+	return respondJSON(200, response), nil
+}
+
+// HandleListJobs handles GET /jobs requests, listing the jobs the caller may read a page at a time
+func HandleListJobs(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	status, limit, nextToken, err := jobsListParams(apiReq)
+	if err != nil {
+		return respondError(400, pkg.CodeInvalidParameter, err.Error()), nil
+	}
+
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
+	if err != nil {
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
+	}
+
+	dynamoClient := clients.dynamo
+
+	jobs, nextToken, err := pkg.ListJobs(ctx, dynamoClient, limit, status, callerIdentity(apiReq), nextToken)
+	if err != nil {
+		if errors.Is(err, pkg.ErrInvalidNextToken) {
+			return respondError(400, pkg.CodeInvalidParameter, fmt.Sprintf("%s is not a token returned by GET /jobs", pkg.JobsNextTokenParam)), nil
+		}
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to list jobs: %v", err)), nil
+	}
+
+	response := pkg.JobListResponse{Jobs: make([]pkg.JobSummary, 0, len(jobs)), NextToken: nextToken}
+	for i := range jobs {
+		response.Jobs = append(response.Jobs, pkg.NewJobSummary(&jobs[i]))
+	}
+	pkg.Infof("Returning %d jobs", len(response.Jobs))
+	return respondJSON(200, response), nil
+}
+
+// HandleJobRequest handles GET /jobs/{id}/request requests, returning the payload the job was submitted with
+func HandleJobRequest(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	jobID := apiReq.PathParameters["id"]
+	if jobID == "" {
+		return respondError(400, pkg.CodeMissingJobID, "missing job ID"), nil
+	}
+
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
+	if err != nil {
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
+	}
+
+	dynamoClient := clients.dynamo
+	s3Client := clients.s3
+
+	job, err := pkg.GetJobWith(ctx, dynamoClient, jobID, pkg.JobReadOptions{WithoutResults: true})
+	if err != nil {
+		return jobErrorResponse(err), nil
+	}
+
+	// The payload describes the submitter's resources, so only they may see it
+	if err := pkg.CheckJobAccess(job, callerIdentity(apiReq)); err != nil {
+		pkg.Warnf("denied access to job %s: %v", jobID, err)
+		return respondError(403, pkg.CodeForbidden, "job belongs to another caller"), nil
+	}
+
+	request, err := pkg.GetJobRequest(ctx, s3Client, job)
+	if err != nil {
+		if errors.Is(err, pkg.ErrJobRequestNotStored) {
+			return respondError(404, pkg.CodeRequestNotStored, err.Error()), nil
+		}
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to get job request: %v", err)), nil
+	}
+
+	pkg.Infof("Returning %d byte request of job %s", len(request), jobID)
+	return respondJSON(200, json.RawMessage(request)), nil
 }
 
+// HandleJobRetry handles POST /jobs/{id}/retry requests, queueing the failed items of a
+// finished job again
+func HandleJobRetry(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	jobID := apiReq.PathParameters["id"]
+	if jobID == "" {
+		return respondError(400, pkg.CodeMissingJobID, "missing job ID"), nil
+	}
+
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
+	if err != nil {
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
+	}
+
+	dynamoClient := clients.dynamo
+	sqsClient := clients.sqs
+	s3Client := clients.s3
+
+	// Read consistently, so a job that just finished can be retried
+	job, err := pkg.GetJobWith(ctx, dynamoClient, jobID, pkg.JobReadOptions{WithoutResults: true, ConsistentRead: true})
+	if err != nil {
+		return jobErrorResponse(err), nil
+	}
+
+	// Only the caller who submitted the job may retry it
+	if err := pkg.CheckJobAccess(job, callerIdentity(apiReq)); err != nil {
+		pkg.Warnf("denied access to job %s: %v", jobID, err)
+		return respondError(403, pkg.CodeForbidden, "job belongs to another caller"), nil
+	}
+
+	retried, err := pkg.RetryFailedItems(ctx, dynamoClient, sqsClient, s3Client, job)
+	switch {
+	case errors.Is(err, pkg.ErrJobExpired):
+		return respondError(410, pkg.CodeJobExpired, err.Error()), nil
+	case errors.Is(err, pkg.ErrJobNotRetryable):
+		return respondError(409, pkg.CodeJobNotRetryable, err.Error()), nil
+	case errors.Is(err, pkg.ErrJobRequestNotStored):
+		return respondError(409, pkg.CodeRequestNotStored, "the job's request was not stored, so its failed items cannot be rebuilt"), nil
+	case err != nil:
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to retry job: %v", err)), nil
+	}
+
+	status, err := pkg.GetJobStatus(ctx, dynamoClient, jobID)
+	if err != nil {
+		status = pkg.JobStatusProcessing
+	}
+	return respondJSON(202, pkg.JobRetryResponse{
+		JobID:        jobID,
+		Status:       status,
+		RetriedItems: retried,
+		RetryCount:   job.RetryCount + 1,
+		MaxRetries:   pkg.MaxJobRetries,
+	}), nil
+}
+
+// HandleDLQRequeue handles POST /admin/dlq/requeue requests, moving the messages in the
+// dead-letter queue back to the work queue. Only admin keys may call it.
+func HandleDLQRequeue(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if len(adminKeys) == 0 {
+		return respondError(403, pkg.CodeForbidden, "admin endpoints are disabled; set ADMIN_API_KEYS to enable them"), nil
+	}
+	if !pkg.ValidAPIKey(adminKeys, pkg.HeaderValue(apiReq.Headers, pkg.APIKeyHeader)) {
+		pkg.Warnf("rejected %s request without an admin key", apiReq.RouteKey)
+		return respondError(403, pkg.CodeForbidden, "an admin API key is required"), nil
+	}
+
+	dlqARN := os.Getenv("DLQ_ARN")
+	if dlqARN == "" {
+		return respondError(409, pkg.CodeDLQNotConfigured, "no dead-letter queue is configured; set DLQ_ARN"), nil
+	}
+
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
+	if err != nil {
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
+	}
+
+	taskHandle, err := pkg.RequeueDeadLetters(ctx, clients.sqs, dlqARN)
+	switch {
+	case errors.Is(err, pkg.ErrRequeueRunning):
+		return respondError(409, pkg.CodeRequeueRunning, err.Error()), nil
+	case err != nil:
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to requeue dead letters: %v", err)), nil
+	}
+
+	return respondJSON(202, pkg.DLQRequeueResponse{SourceARN: dlqARN, TaskHandle: taskHandle}), nil
+}
+
+// HandleJobCancel handles DELETE /jobs/{id} requests
+func HandleJobCancel(ctx context.Context, apiReq events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	jobID := apiReq.PathParameters["id"]
+	if jobID == "" {
+		return respondError(400, pkg.CodeMissingJobID, "missing job ID"), nil
+	}
+
+	// Create the AWS service clients
+	clients, err := newAWSClients(ctx)
+	if err != nil {
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to initialize AWS client: %v", err)), nil
+	}
+
+	dynamoClient := clients.dynamo
+
+	// Only the caller who submitted the job may cancel it
+	job, err := pkg.GetJobWith(ctx, dynamoClient, jobID, pkg.JobReadOptions{WithoutResults: true})
+	if err != nil {
+		return jobErrorResponse(err), nil
+	}
+	if err := pkg.CheckJobAccess(job, callerIdentity(apiReq)); err != nil {
+		pkg.Warnf("denied access to job %s: %v", jobID, err)
+		return respondError(403, pkg.CodeForbidden, "job belongs to another caller"), nil
+	}
+
+	pkg.Infof("Cancelling job %s", jobID)
+	err = pkg.CancelJob(ctx, dynamoClient, jobID)
+	switch {
+	case errors.Is(err, pkg.ErrJobNotFound):
+		return respondError(404, pkg.CodeJobNotFound, "job not found"), nil
+	case errors.Is(err, pkg.ErrJobAlreadyFinished):
+		return respondError(409, pkg.CodeJobAlreadyFinished, err.Error()), nil
+	case err != nil:
+		return respondError(500, pkg.CodeInternal, fmt.Sprintf("failed to cancel job: %v", err)), nil
+	}
+
+	return respondJSON(200, pkg.JobCancelResponse{JobID: jobID, Status: pkg.JobStatusCancelled}), nil
+}
+
+// Build information, set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
 func main() {
+	pkg.SetLogger(pkg.NewLoggerFromEnv())
+	build := pkg.NewBuildInfo(version, commit, date)
+	pkg.SetBuildInfo(build)
+	pkg.Infof("GreenOps API %s starting", build)
+	apiKeys = pkg.ParseAPIKeys(os.Getenv("API_KEYS"))
+	if len(apiKeys) == 0 {
+		pkg.Warnf("API_KEYS is not set; the API accepts unauthenticated requests")
+	}
+	adminKeys = pkg.ParseAPIKeys(os.Getenv("ADMIN_API_KEYS"))
+
+	// Jobs that finish are announced on NOTIFY_TOPIC_ARN, when it is set
+	if topicARN := os.Getenv("NOTIFY_TOPIC_ARN"); topicARN != "" {
+		awsCfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			pkg.Fatalf("unable to load AWS config for job notifications: %v", err)
+		}
+		pkg.SetJobNotifier(pkg.NewSNSClient(awsCfg), topicARN)
+		pkg.Infof("Publishing job completions to %s", topicARN)
+	}
 	lambda.Start(Handler)
 }
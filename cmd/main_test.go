@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// fakeJobTable answers GetItem from a set of stored jobs and fails every write, so a test
+// sees any handler that changes a job it should not
+type fakeJobTable struct {
+	mu     sync.Mutex
+	jobs   map[string]map[string]types.AttributeValue
+	writes int
+}
+
+var errUnexpectedWrite = errors.New("unexpected write")
+
+func newFakeJobTable(t *testing.T, jobs ...pkg.JobInfo) *fakeJobTable {
+	t.Helper()
+	table := &fakeJobTable{jobs: make(map[string]map[string]types.AttributeValue)}
+	for _, job := range jobs {
+		item, err := attributevalue.MarshalMap(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table.jobs[job.JobID] = item
+	}
+	return table
+}
+
+func (f *fakeJobTable) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var jobID string
+	attributevalue.Unmarshal(params.Key["job_id"], &jobID)
+	return &dynamodb.GetItemOutput{Item: f.jobs[jobID]}, nil
+}
+
+func (f *fakeJobTable) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (f *fakeJobTable) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (f *fakeJobTable) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return nil, f.write()
+}
+
+func (f *fakeJobTable) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return nil, f.write()
+}
+
+func (f *fakeJobTable) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return nil, f.write()
+}
+
+func (f *fakeJobTable) write() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes++
+	return errUnexpectedWrite
+}
+
+// useFakeClients serves the handlers' DynamoDB calls from dynamo and accepts keys as API keys
+func useFakeClients(t *testing.T, dynamo pkg.DynamoJobStore, keys ...string) {
+	t.Helper()
+	t.Setenv("JOBS_TABLE", "jobs")
+	t.Setenv("RESULTS_TABLE", "")
+	t.Setenv("ITEMS_TABLE", "")
+
+	previousClients, previousKeys := newAWSClients, apiKeys
+	t.Cleanup(func() { newAWSClients, apiKeys = previousClients, previousKeys })
+	newAWSClients = func(ctx context.Context) (*awsClients, error) {
+		return &awsClients{dynamo: dynamo}, nil
+	}
+	apiKeys = keys
+}
+
+// jobRequest is an API Gateway request for a job route, sent with apiKey unless it is empty
+func jobRequest(route, jobID, apiKey string) events.APIGatewayV2HTTPRequest {
+	req := events.APIGatewayV2HTTPRequest{RouteKey: route, PathParameters: map[string]string{"id": jobID}}
+	if apiKey != "" {
+		req.Headers = map[string]string{"x-api-key": apiKey}
+	}
+	return req
+}
+
+// testJob is a completed job of two items owned by the caller with apiKey, if any
+func testJob(jobID, apiKey string) pkg.JobInfo {
+	return pkg.JobInfo{
+		JobID:          jobID,
+		Status:         pkg.JobStatusCompleted,
+		TotalItems:     2,
+		CompletedItems: 2,
+		ExpirationTime: time.Now().Add(time.Hour).Unix(),
+		Owner:          pkg.CallerIdentity(apiKey),
+		Results: []pkg.ReportItem{
+			{ResourceType: pkg.ResourceTypeEC2, Instance: pkg.Instance{InstanceID: "i-1"}, Analysis: "ok"},
+			{ResourceType: pkg.ResourceTypeEC2, Instance: pkg.Instance{InstanceID: "i-2"}, Analysis: "ok"},
+		},
+	}
+}
+
+func TestJobOwnership(t *testing.T) {
+	routes := []string{"GET /jobs/{id}", "GET /jobs/{id}/results", "DELETE /jobs/{id}"}
+	tests := []struct {
+		name       string
+		owner      string // API key that submitted the job
+		caller     string // API key of the request
+		wantDenied bool
+	}{
+		{name: "owner", owner: "key-a", caller: "key-a"},
+		{name: "another caller", owner: "key-a", caller: "key-b", wantDenied: true},
+		{name: "anonymous job", caller: "key-b"},
+	}
+
+	for _, tt := range tests {
+		for _, route := range routes {
+			t.Run(tt.name+"/"+route, func(t *testing.T) {
+				dynamo := newFakeJobTable(t, testJob("job-1", tt.owner))
+				useFakeClients(t, dynamo, "key-a", "key-b")
+
+				resp, err := Handler(context.Background(), jobRequest(route, "job-1", tt.caller))
+				if err != nil {
+					t.Fatalf("Handler() error = %v", err)
+				}
+
+				if tt.wantDenied {
+					var apiErr pkg.APIError
+					if err := json.Unmarshal([]byte(resp.Body), &apiErr); resp.StatusCode != 403 || err != nil || apiErr.Code != pkg.CodeForbidden {
+						t.Errorf("response = %d %s, want a 403 %s error", resp.StatusCode, resp.Body, pkg.CodeForbidden)
+					}
+					if dynamo.writes != 0 {
+						t.Errorf("denied request wrote to the job %d times", dynamo.writes)
+					}
+					return
+				}
+				// The fake fails every write, so an allowed cancellation shows as the attempt
+				if route == "DELETE /jobs/{id}" {
+					if dynamo.writes == 0 {
+						t.Errorf("cancellation did not reach the job: %d %s", resp.StatusCode, resp.Body)
+					}
+				} else if resp.StatusCode != 200 {
+					t.Errorf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+				}
+			})
+		}
+	}
+}
+
+// Without API keys every caller is anonymous, and only anonymous jobs are readable
+func TestJobOwnershipWithoutKeys(t *testing.T) {
+	dynamo := newFakeJobTable(t, testJob("job-anon", ""), testJob("job-owned", "key-a"))
+	useFakeClients(t, dynamo)
+
+	for jobID, want := range map[string]int{"job-anon": 200, "job-owned": 403, "job-missing": 404} {
+		resp, err := Handler(context.Background(), jobRequest("GET /jobs/{id}/results", jobID, ""))
+		if err != nil {
+			t.Fatalf("Handler() error = %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s: status = %d, want %d", jobID, resp.StatusCode, want)
+		}
+	}
+}
+
+// A job past its TTL that DynamoDB has not deleted yet is gone, with the time it expired
+func TestExpiredJob(t *testing.T) {
+	job := testJob("job-1", "")
+	job.ExpirationTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Unix()
+	useFakeClients(t, newFakeJobTable(t, job))
+
+	resp, err := Handler(context.Background(), jobRequest("GET /jobs/{id}", "job-1", ""))
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+	var apiErr pkg.APIError
+	if err := json.Unmarshal([]byte(resp.Body), &apiErr); err != nil {
+		t.Fatalf("response body %q is not an API error: %v", resp.Body, err)
+	}
+	if resp.StatusCode != 410 || apiErr.Code != pkg.CodeJobExpired || !strings.Contains(apiErr.Message, "2026-01-02T03:04:05Z") {
+		t.Errorf("response %d %+v, want 410 %s with the expiry time", resp.StatusCode, apiErr, pkg.CodeJobExpired)
+	}
+}
+
+func TestHandlerRejectsUnknownKeys(t *testing.T) {
+	useFakeClients(t, newFakeJobTable(t, testJob("job-1", "key-a")), "key-a")
+
+	resp, err := Handler(context.Background(), jobRequest("GET /jobs/{id}", "job-1", "key-z"))
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestHandlerValidatesRequests(t *testing.T) {
+	instances := func(ids ...string) string {
+		var b strings.Builder
+		for i, id := range ids {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, `{"instanceId":%q,"cpuAvg7d":5}`, id)
+		}
+		return `{"instances":[` + b.String() + `]}`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		maxItems   string
+		wantStatus int
+		wantCode   string
+		wantFields []string
+	}{
+		{name: "not JSON", body: "{", wantStatus: 400, wantCode: pkg.CodeInvalidPayload},
+		{name: "no resources", body: `{}`, wantStatus: 400, wantCode: pkg.CodeNoResources},
+		{name: "too many", body: instances("i-1", "i-2", "i-3"), maxItems: "2", wantStatus: 413, wantCode: pkg.CodeTooManyItems},
+		{
+			name:       "invalid fields",
+			body:       `{"instances":[{"instanceId":"i-1"},{"instanceId":"i-1"},{"cpuAvg7d":120}]}`,
+			wantStatus: 400,
+			wantCode:   pkg.CodeInvalidResources,
+			wantFields: []string{"instances[1].instanceId", "instances[2].instanceId", "instances[2].cpuAvg7d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A request that fails validation never reaches AWS
+			useFakeClients(t, newFakeJobTable(t))
+			newAWSClients = func(ctx context.Context) (*awsClients, error) {
+				t.Fatal("invalid request created AWS clients")
+				return nil, nil
+			}
+			t.Setenv("MAX_ITEMS", tt.maxItems)
+
+			resp, err := Handler(context.Background(), events.APIGatewayV2HTTPRequest{RouteKey: "POST /analyze", Body: tt.body})
+			if err != nil {
+				t.Fatalf("Handler() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			var apiErr pkg.APIError
+			if err := json.Unmarshal([]byte(resp.Body), &apiErr); err != nil {
+				t.Fatalf("body is not an APIError: %s", resp.Body)
+			}
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", apiErr.Code, tt.wantCode)
+			}
+			if tt.wantStatus == 413 && apiErr.MaxItems != 2 {
+				t.Errorf("max_items = %d, want 2", apiErr.MaxItems)
+			}
+			var fields []string
+			for _, fieldErr := range apiErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if fmt.Sprint(fields) != fmt.Sprint(tt.wantFields) {
+				t.Errorf("field errors = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+// Results carry embeddings only when the caller asks for them with include_embeddings=true
+func TestJobResponsesOmitEmbeddings(t *testing.T) {
+	job := testJob("job-1", "")
+	for i := range job.Results {
+		job.Results[i].Embedding = []float64{0.25, -0.5, 0.75}
+	}
+
+	tests := []struct {
+		name           string
+		param          string
+		wantStatus     int
+		wantEmbeddings bool
+	}{
+		{name: "default", wantStatus: 200},
+		{name: "false", param: "false", wantStatus: 200},
+		{name: "true", param: "true", wantStatus: 200, wantEmbeddings: true},
+		{name: "invalid", param: "sometimes", wantStatus: 400},
+	}
+
+	for _, route := range []string{"GET /jobs/{id}", "GET /jobs/{id}/results"} {
+		for _, tt := range tests {
+			t.Run(route+"/"+tt.name, func(t *testing.T) {
+				useFakeClients(t, newFakeJobTable(t, job))
+				req := jobRequest(route, "job-1", "")
+				if tt.param != "" {
+					req.QueryStringParameters = map[string]string{pkg.IncludeEmbeddingsParam: tt.param}
+				}
+
+				resp, err := Handler(context.Background(), req)
+				if err != nil {
+					t.Fatalf("Handler() error = %v", err)
+				}
+				if resp.StatusCode != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, resp.Body)
+				}
+				if tt.wantStatus != 200 {
+					var apiErr pkg.APIError
+					if err := json.Unmarshal([]byte(resp.Body), &apiErr); err != nil || apiErr.Code != pkg.CodeInvalidParameter {
+						t.Errorf("body = %s, want a %s error", resp.Body, pkg.CodeInvalidParameter)
+					}
+					return
+				}
+
+				var body struct {
+					Results []pkg.ReportItem `json:"results"`
+				}
+				if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+					t.Fatalf("body does not decode: %v", err)
+				}
+				if len(body.Results) != 2 {
+					t.Fatalf("got %d results, want 2: %s", len(body.Results), resp.Body)
+				}
+				if got := strings.Contains(resp.Body, `"embedding"`); got != tt.wantEmbeddings {
+					t.Errorf("body has embeddings %v, want %v", got, tt.wantEmbeddings)
+				}
+				for _, result := range body.Results {
+					if result.Analysis != "ok" {
+						t.Errorf("result %s lost its analysis", result.Instance.InstanceID)
+					}
+				}
+			})
+		}
+	}
+}
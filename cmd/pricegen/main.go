@@ -0,0 +1,168 @@
+// Command pricegen refreshes the bundled pricing table from the AWS Pricing API.
+//
+// It reads the existing table, looks up the current on-demand or reserved price of every
+// entry and writes the table back. Entries the API does not return keep their previous price. Run it
+// with `go generate ./pkg` using credentials allowed to call pricing:GetProducts.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// rdsEngineNames maps the engine keys of the table to the Pricing API databaseEngine values
+var rdsEngineNames = map[string]string{
+	"mysql":    "MySQL",
+	"postgres": "PostgreSQL",
+}
+
+// rdsVolumeTypes maps RDS storage types to the Pricing API volumeType values
+var rdsVolumeTypes = map[string]string{
+	"gp2":      "General Purpose",
+	"gp3":      "General Purpose-GP3",
+	"io1":      "Provisioned IOPS",
+	"io2":      "Provisioned IOPS-IO2",
+	"standard": "Magnetic",
+}
+
+// s3VolumeTypes maps S3 storage classes to the Pricing API volumeType values
+var s3VolumeTypes = map[string]string{
+	"STANDARD":            "Standard",
+	"INTELLIGENT_TIERING": "Intelligent-Tiering Frequent Access",
+	"STANDARD_IA":         "Standard - Infrequent Access",
+	"ONEZONE_IA":          "One Zone - Infrequent Access",
+	"REDUCED_REDUNDANCY":  "Reduced Redundancy",
+	"GLACIER_IR":          "Glacier Instant Retrieval",
+	"GLACIER":             "Amazon Glacier",
+	"DEEP_ARCHIVE":        "Glacier Deep Archive",
+	"EXPRESS_ONEZONE":     "Express One Zone",
+}
+
+func main() {
+	output := flag.String("o", "pricing_data.json", "Pricing table to refresh")
+	profile := flag.String("profile", "", "AWS Profile (defaults to AWS_PROFILE env var or default profile)")
+	flag.Parse()
+
+	data, err := os.ReadFile(*output)
+	if err != nil {
+		pkg.Fatalf("Failed to read %s: %v", *output, err)
+	}
+	var table pkg.PriceTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		pkg.Fatalf("Failed to parse %s: %v", *output, err)
+	}
+
+	ctx := context.Background()
+
+	// The Pricing API is only served from a few regions
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion("us-east-1")}
+	if *profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(*profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		pkg.Fatalf("Failed to load AWS config: %v", err)
+	}
+	client := pricing.NewFromConfig(cfg)
+
+	for region, prices := range table.EC2 {
+		for instanceType := range prices {
+			refresh(ctx, client, pkg.LookupOnDemandPrice, prices, instanceType, "AmazonEC2", map[string]string{
+				"regionCode":      region,
+				"instanceType":    instanceType,
+				"operatingSystem": "Linux",
+				"tenancy":         "Shared",
+				"preInstalledSw":  "NA",
+				"capacitystatus":  "Used",
+			})
+		}
+	}
+
+	for region, engines := range table.RDS {
+		for engine, prices := range engines {
+			for instanceClass := range prices {
+				refresh(ctx, client, pkg.LookupOnDemandPrice, prices, instanceClass, "AmazonRDS", map[string]string{
+					"regionCode":       region,
+					"instanceType":     instanceClass,
+					"databaseEngine":   rdsEngineNames[engine],
+					"deploymentOption": "Single-AZ",
+				})
+			}
+		}
+	}
+
+	for region, engines := range table.RDSReserved {
+		for engine, prices := range engines {
+			for instanceClass := range prices {
+				refresh(ctx, client, pkg.LookupReservedPrice, prices, instanceClass, "AmazonRDS", map[string]string{
+					"regionCode":       region,
+					"instanceType":     instanceClass,
+					"databaseEngine":   rdsEngineNames[engine],
+					"deploymentOption": "Single-AZ",
+				})
+			}
+		}
+	}
+
+	for region := range table.AuroraServerless {
+		refresh(ctx, client, pkg.LookupOnDemandPrice, table.AuroraServerless, region, "AmazonRDS", map[string]string{
+			"regionCode":     region,
+			"productFamily":  "ServerlessV2",
+			"databaseEngine": "Aurora MySQL",
+		})
+	}
+
+	for region, prices := range table.RDSStorage {
+		for storageType := range prices {
+			refresh(ctx, client, pkg.LookupOnDemandPrice, prices, storageType, "AmazonRDS", map[string]string{
+				"regionCode":       region,
+				"productFamily":    "Database Storage",
+				"volumeType":       rdsVolumeTypes[storageType],
+				"deploymentOption": "Single-AZ",
+				"databaseEngine":   "MySQL",
+			})
+		}
+	}
+
+	for region, prices := range table.S3 {
+		for storageClass := range prices {
+			refresh(ctx, client, pkg.LookupOnDemandPrice, prices, storageClass, "AmazonS3", map[string]string{
+				"regionCode":    region,
+				"productFamily": "Storage",
+				"volumeType":    s3VolumeTypes[storageClass],
+			})
+		}
+	}
+
+	table.GeneratedAt = time.Now().UTC().Format(time.DateOnly)
+	out, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		pkg.Fatalf("Failed to encode pricing table: %v", err)
+	}
+	if err := os.WriteFile(*output, append(out, '\n'), 0o644); err != nil {
+		pkg.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	pkg.Infof("Wrote %s", *output)
+}
+
+// priceLookup looks up the price of the product of a service matching the filters
+type priceLookup func(ctx context.Context, client pkg.PricingAPI, serviceCode string, filters map[string]string) (float64, error)
+
+// refresh replaces prices[key] with the price lookup returns for the filters, keeping the
+// previous price when the lookup fails
+func refresh(ctx context.Context, client pkg.PricingAPI, lookup priceLookup, prices map[string]float64, key, serviceCode string, filters map[string]string) {
+	price, err := lookup(ctx, client, serviceCode, filters)
+	if err != nil {
+		pkg.Warnf("Keeping previous price for %s %s: %v", filters["regionCode"], key, err)
+		return
+	}
+	prices[key] = price
+}
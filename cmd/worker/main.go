@@ -3,9 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -13,137 +14,270 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
 
 	pkg "github.com/alexalbu001/greenops/pkg"
+	"github.com/alexalbu001/greenops/pkg/prompts"
 )
 
-func Handler(ctx context.Context, sqsEvent events.SQSEvent) error {
-	log.Printf("DEBUG: SQS Handler invoked—this is the *right* code!")
+// maxReceiveAttempts is how many times a message with a transient error is delivered
+// before the item is recorded as failed instead of being retried again
+const maxReceiveAttempts = 3
+
+// Handler processes a batch of work items. Messages that hit a transient error are
+// reported back as batch item failures so SQS redelivers them; everything else,
+// including items that permanently failed, is removed from the queue.
+func Handler(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	pkg.Debugf("SQS handler invoked with %d records", len(sqsEvent.Records))
 	// Load AWS config
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		log.Printf("unable to load AWS config: %v", err)
-		return fmt.Errorf("unable to load AWS config: %v", err)
+		pkg.Errorf("unable to load AWS config: %v", err)
+		return events.SQSEventResponse{}, fmt.Errorf("unable to load AWS config: %v", err)
 	}
 
 	// Create clients
 	dynamoClient := dynamodb.NewFromConfig(cfg)
-	brClient := bedrockruntime.NewFromConfig(cfg)
 
 	// Get model IDs
 	embedModel := os.Getenv("EMBED_MODEL_ID")
 	if embedModel == "" {
 		embedModel = "amazon.titan-embed-text-v2:0"
 	}
-	log.Printf("Using embedding model: %s", embedModel)
+	pkg.Infof("Using embedding model: %s", embedModel)
 
-	genID := os.Getenv("GEN_PROFILE_ARN")
-	if genID == "" {
-		genID = os.Getenv("GEN_MODEL_ID")
-		if genID == "" {
-			genID = "arn:aws:bedrock:eu-west-1:767048271788:inference-profile/eu.anthropic.claude-3-7-sonnet-20250219-v1:0"
-		}
+	// Bedrock calls are timed and their throttling retries counted for the EMF metrics
+	brClient := &meteredBedrock{embedModel: embedModel}
+	brClient.client = bedrockruntime.NewFromConfig(cfg, brClient.countThrottles())
+	emf := pkg.NewEMFLogger(os.Stdout, workerMetricsNamespace)
+
+	// The worker that finishes a job's last item writes its account-level recommendations
+	pkg.SetAccountAnalyzer(brClient, pkg.ResolveModelID(string(pkg.ResourceTypeAccount)))
+
+	// Live pricing fills in EC2 and RDS prices the CLI did not already look up
+	var pricingClient *pkg.PricingClient
+	if os.Getenv("PRICING_MODE") == pkg.PricingModeLive {
+		pricingClient = pkg.NewPricingClientFromConfig(cfg)
+		pkg.Infof("Using live pricing from the AWS Pricing API")
 	}
-	log.Printf("Using generation model/profile: %s", genID)
 
 	// Process each message in the batch
+	var failures []events.SQSBatchItemFailure
 	for _, record := range sqsEvent.Records {
-		log.Printf("Processing SQS message: %s", record.MessageId)
-		log.Printf("Raw SQS record body: %s", record.Body) //DEBUG
+		pkg.Debugf("Processing SQS message: %s", record.MessageId)
+		pkg.DebugPayload("Raw SQS record body", record.Body)
 
 		// Parse work item
 		var workItem pkg.WorkItem
 		if err := json.Unmarshal([]byte(record.Body), &workItem); err != nil {
-			log.Printf("Failed to parse work item: %v", err)
+			pkg.Errorf("Failed to parse work item: %v", err)
+			continue
+		}
+		pkg.Debugf("Parsed workItem.ItemType = %q", workItem.ItemType)
+
+		if pricingClient != nil {
+			pricingClient.ResolveWorkItemPrice(ctx, &workItem)
+		}
+
+		// Skip the expensive Bedrock calls for jobs that were cancelled
+		if status, err := pkg.GetJobStatus(ctx, dynamoClient, workItem.JobID); err == nil && status == pkg.JobStatusCancelled {
+			pkg.Infof("Job %s is cancelled, skipping item %d", workItem.JobID, workItem.ItemIndex)
+			if err := pkg.RecordSkippedItem(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex); err != nil {
+				pkg.Warnf("%v", err)
+			}
+			if err := pkg.UpdateJobItem(ctx, dynamoClient, workItem, pkg.ItemSkipped, "", 0); err != nil {
+				pkg.Warnf("%v", err)
+			}
 			continue
 		}
-		log.Printf("Parsed workItem.ItemType = %q", workItem.ItemType)
+
+		// SQS delivers at least once: a redelivered item that was already processed is dropped,
+		// and one another worker is still processing is left for a later delivery
+		if err := pkg.ClaimJobItem(ctx, dynamoClient, workItem); err != nil {
+			switch {
+			case errors.Is(err, pkg.ErrItemAlreadyProcessed):
+				pkg.Infof("Skipping redelivered message %s: %v", record.MessageId, err)
+				continue
+			case errors.Is(err, pkg.ErrItemInProgress):
+				pkg.Warnf("Leaving message %s for retry: %v", record.MessageId, err)
+				failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+				continue
+			}
+			// The job's counters still count the item once, so processing goes ahead
+			pkg.Warnf("%v", err)
+		}
+
+		// The API fingerprinted the item for the analysis cache with the same model
+		genID := workItem.GenerationModel()
+		pkg.Debugf("Analyzing item %d of job %s with %s", workItem.ItemIndex, workItem.JobID, genID)
 
 		// Dispatch based on item type
+		brClient.reset()
+		started := time.Now()
+		throttlesBefore := brClient.throttles.Load()
+		var processErr error
 		switch workItem.ItemType {
 		case "ec2":
-			if err := processEC2Instance(ctx, brClient, dynamoClient, embedModel, genID, workItem); err != nil {
-				log.Printf("Failed to process EC2 instance: %v", err)
-			}
+			processErr = processEC2Instance(ctx, brClient, dynamoClient, embedModel, genID, workItem)
 		case "s3":
-			if err := processS3Bucket(ctx, brClient, dynamoClient, embedModel, genID, workItem); err != nil {
-				log.Printf("Failed to process S3 bucket: %v", err)
-			}
+			processErr = processS3Bucket(ctx, brClient, dynamoClient, embedModel, genID, workItem)
 		case "rds":
-			if err := processRDSInstance(ctx, brClient, dynamoClient, embedModel, genID, workItem); err != nil {
-				log.Printf("Failed to process RDS instance: %v", err)
-			}
+			processErr = processRDSInstance(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "ebs":
+			processErr = processEBSVolume(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "lambda":
+			processErr = processLambdaFunction(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "elb":
+			processErr = processLoadBalancer(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "network":
+			processErr = processNetworkResource(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "dynamodb":
+			processErr = processDynamoTable(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "elasticache":
+			processErr = processElastiCacheCluster(ctx, brClient, dynamoClient, embedModel, genID, workItem)
+		case "snapshots":
+			processErr = processSnapshots(ctx, brClient, dynamoClient, genID, workItem)
 		default:
-			log.Printf("Unknown item type: %s", workItem.ItemType)
+			pkg.Errorf("Unknown item type: %s", workItem.ItemType)
+			continue
+		}
+
+		outcome := itemSucceeded
+		if processErr != nil {
+			outcome = itemFailed
+			if isTransientError(processErr) && receiveCount(record) < maxReceiveAttempts {
+				outcome = itemRetried
+			}
+		}
+		elapsed := time.Since(started)
+		emitItemMetrics(emf, workItem.ItemType, genID, elapsed, brClient, brClient.throttles.Load()-throttlesBefore, outcome)
+
+		// A retried item goes back to queued with the error that will be retried
+		itemStatus, itemError := pkg.ItemCompleted, ""
+		switch outcome {
+		case itemFailed:
+			itemStatus, itemError = pkg.ItemFailed, processErr.Error()
+		case itemRetried:
+			itemStatus, itemError = pkg.ItemQueued, processErr.Error()
+		}
+		if err := pkg.UpdateJobItem(ctx, dynamoClient, workItem, itemStatus, itemError, elapsed); err != nil {
+			pkg.Warnf("%v", err)
+		}
+
+		if processErr != nil {
+			// Transient errors go back to SQS for redelivery until the attempts run out
+			if outcome == itemRetried {
+				pkg.Warnf("Transient error on item %d of job %s, leaving it for retry: %v", workItem.ItemIndex, workItem.JobID, processErr)
+				failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+				continue
+			}
+
+			pkg.Errorf("Failed to process %s item %d of job %s: %v", workItem.ItemType, workItem.ItemIndex, workItem.JobID, processErr)
+			if err := pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex, false, pkg.ReportItem{}); err != nil {
+				pkg.Warnf("%v", err)
+			}
+		}
+
+		// Finalize job status once every item has been processed, including
+		// items that failed before reaching Bedrock
+		if err := pkg.MaybeFinalizeJob(ctx, dynamoClient, workItem.JobID); err != nil {
+			pkg.Warnf("Failed to finalize job %s: %v", workItem.JobID, err)
 		}
 	}
 
-	return nil
+	return events.SQSEventResponse{BatchItemFailures: failures}, nil
+}
+
+// transientErrorCodes are AWS error codes worth retrying, mostly Bedrock throttling and capacity errors
+var transientErrorCodes = map[string]bool{
+	"ThrottlingException":                    true,
+	"TooManyRequestsException":               true,
+	"ServiceUnavailableException":            true,
+	"ModelNotReadyException":                 true,
+	"ModelTimeoutException":                  true,
+	"InternalServerException":                true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+}
+
+// isTransientError reports whether processing may succeed if the message is delivered again
+func isTransientError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return transientErrorCodes[apiErr.ErrorCode()]
+	}
+	return false
+}
+
+// receiveCount returns how many times SQS has delivered the message, including this delivery
+func receiveCount(record events.SQSMessage) int {
+	count, err := strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+	if err != nil {
+		return 1
+	}
+	return count
 }
 
 func processEC2Instance(
 	ctx context.Context,
-	brClient *bedrockruntime.Client,
+	brClient pkg.BedrockInvoker,
 	dynamoClient *dynamodb.Client,
 	embedModel, genID string,
 	workItem pkg.WorkItem,
 ) error {
 	instance := workItem.Instance
-	log.Printf("Processing EC2 instance: %s", instance.InstanceID)
+	pkg.Infof("Processing EC2 instance: %s", instance.InstanceID)
 
 	// Marshal instance to JSON
 	data, err := json.Marshal(instance)
 	if err != nil {
-		pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, false, pkg.ReportItem{})
-		return fmt.Errorf("failed to marshal instance %s: %v", instance.InstanceID, err)
+		return fmt.Errorf("failed to marshal instance %s: %w", instance.InstanceID, err)
 	}
 	record := string(data)
 
 	// Embedding phase
 	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
 	if err != nil {
-		pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, false, pkg.ReportItem{})
-		return fmt.Errorf("embed error for %s: %v", instance.InstanceID, err)
+		return fmt.Errorf("embed error for %s: %w", instance.InstanceID, err)
 	}
 
-	analysis, err := pkg.AnalyzeInstance(ctx, brClient, genID, record, instance.CPUAvg7d)
+	result, err := pkg.AnalyzeInstance(ctx, brClient, genID, record, instance)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", instance.InstanceID, err)
+	}
 	if err != nil || analysis == "" {
-		log.Printf("Bedrock analysis failed for EC2 %s: %v", instance.InstanceID, err)
+		pkg.Warnf("Bedrock analysis failed for EC2 %s: %v", instance.InstanceID, err)
 		analysis = fmt.Sprintf("ERROR: Failed to analyze instance: %v", err)
 	}
 
 	// Update progress
 	reportItem := pkg.ReportItem{
-		ResourceType: pkg.ResourceTypeEC2,
-		Instance:     instance,
-		Embedding:    emb,
-		Analysis:     analysis,
-	}
-	pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, true, reportItem)
-
-	// Finalize job status if needed
-	job, err := pkg.GetJob(ctx, dynamoClient, workItem.JobID)
-	if err == nil && (job.CompletedItems+job.FailedItems >= job.TotalItems) &&
-		(job.Status != pkg.JobStatusCompleted && job.Status != pkg.JobStatusFailed) {
-		status := pkg.JobStatusCompleted
-		if job.FailedItems == job.TotalItems {
-			status = pkg.JobStatusFailed
-		}
-		pkg.UpdateJobStatus(ctx, dynamoClient, workItem.JobID, status)
+		ResourceType:       pkg.ResourceTypeEC2,
+		Instance:           instance,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
 	}
-
-	return nil
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
 }
 
 func processS3Bucket(
 	ctx context.Context,
-	brClient *bedrockruntime.Client,
+	brClient pkg.BedrockInvoker,
 	dynamoClient *dynamodb.Client,
 	embedModel, genID string,
 	workItem pkg.WorkItem,
 ) error {
 	bucket := workItem.S3Bucket
-	log.Printf("Processing S3 bucket: %s (region: %s)", bucket.BucketName, bucket.Region)
+	pkg.Infof("Processing S3 bucket: %s (region: %s)", bucket.BucketName, bucket.Region)
 
 	// Timeout context
 	processingCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
@@ -152,100 +286,446 @@ func processS3Bucket(
 	// Marshal bucket
 	data, err := json.Marshal(bucket)
 	if err != nil {
-		pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, false, pkg.ReportItem{})
-		return fmt.Errorf("failed to marshal bucket %s: %v", bucket.BucketName, err)
+		return fmt.Errorf("failed to marshal bucket %s: %w", bucket.BucketName, err)
 	}
 	record := string(data)
 
 	// Embedding
 	emb, err := pkg.EmbedText(processingCtx, brClient, embedModel, record)
 	if err != nil {
-		pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, false, pkg.ReportItem{})
-		return fmt.Errorf("embed error for bucket %s: %v", bucket.BucketName, err)
+		return fmt.Errorf("embed error for bucket %s: %w", bucket.BucketName, err)
 	}
 
-	analysis, err := pkg.AnalyzeS3BucketWithBedrock(ctx, brClient, genID, bucket, emb)
+	result, err := pkg.AnalyzeS3BucketWithBedrock(ctx, brClient, genID, bucket, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", bucket.BucketName, err)
+	}
 	if err != nil || analysis == "" {
-		log.Printf("Bedrock analysis failed for S3 %s: %v", bucket.BucketName, err)
+		pkg.Warnf("Bedrock analysis failed for S3 %s: %v", bucket.BucketName, err)
 	}
 
 	// Update progress
 	reportItem := pkg.ReportItem{
-		ResourceType: pkg.ResourceTypeS3,
-		S3Bucket:     bucket,
-		Embedding:    emb,
-		Analysis:     analysis,
-	}
-	pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, true, reportItem)
-
-	// Finalize job status
-	job, err := pkg.GetJob(ctx, dynamoClient, workItem.JobID)
-	if err == nil && (job.CompletedItems+job.FailedItems >= job.TotalItems) &&
-		(job.Status != pkg.JobStatusCompleted && job.Status != pkg.JobStatusFailed) {
-		status := pkg.JobStatusCompleted
-		if job.FailedItems == job.TotalItems {
-			status = pkg.JobStatusFailed
-		}
-		pkg.UpdateJobStatus(ctx, dynamoClient, workItem.JobID, status)
+		ResourceType:       pkg.ResourceTypeS3,
+		S3Bucket:           bucket,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
 	}
-
-	return nil
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
 }
 
 func processRDSInstance(
 	ctx context.Context,
-	brClient *bedrockruntime.Client,
+	brClient pkg.BedrockInvoker,
 	dynamoClient *dynamodb.Client,
 	embedModel, genID string,
 	workItem pkg.WorkItem,
 ) error {
 	instance := workItem.RDSInstance
-	log.Printf("Processing RDS instance: %s", instance.InstanceID)
+	pkg.Infof("Processing RDS instance: %s", instance.InstanceID)
 
 	// Marshal instance
 	data, err := json.Marshal(instance)
 	if err != nil {
-		pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, false, pkg.ReportItem{})
-		return fmt.Errorf("failed to marshal RDS instance %s: %v", instance.InstanceID, err)
+		return fmt.Errorf("failed to marshal RDS instance %s: %w", instance.InstanceID, err)
 	}
 	record := string(data)
 
 	// Embedding
 	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
 	if err != nil {
-		pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, false, pkg.ReportItem{})
-		return fmt.Errorf("embed error for RDS %s: %v", instance.InstanceID, err)
+		return fmt.Errorf("embed error for RDS %s: %w", instance.InstanceID, err)
 	}
 
-	analysis, err := pkg.AnalyzeRDSInstanceWithBedrock(ctx, brClient, genID, instance, emb)
+	result, err := pkg.AnalyzeRDSInstanceWithBedrock(ctx, brClient, genID, instance, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", instance.InstanceID, err)
+	}
 	if err != nil || analysis == "" {
-		log.Printf("Bedrock analysis failed for RDS %s: %v", instance.InstanceID, err)
+		pkg.Warnf("Bedrock analysis failed for RDS %s: %v", instance.InstanceID, err)
 		analysis = fmt.Sprintf("ERROR: Failed to analyze RDS instance: %v", err)
 	}
 
 	// Update progress
 	reportItem := pkg.ReportItem{
-		ResourceType: pkg.ResourceTypeRDS,
-		RDSInstance:  instance,
-		Embedding:    emb,
-		Analysis:     analysis,
-	}
-	pkg.UpdateJobProgress(ctx, dynamoClient, workItem.JobID, true, reportItem)
-
-	// Finalize job status
-	job, err := pkg.GetJob(ctx, dynamoClient, workItem.JobID)
-	if err == nil && (job.CompletedItems+job.FailedItems >= job.TotalItems) &&
-		(job.Status != pkg.JobStatusCompleted && job.Status != pkg.JobStatusFailed) {
-		status := pkg.JobStatusCompleted
-		if job.FailedItems == job.TotalItems {
-			status = pkg.JobStatusFailed
-		}
-		pkg.UpdateJobStatus(ctx, dynamoClient, workItem.JobID, status)
+		ResourceType:       pkg.ResourceTypeRDS,
+		RDSInstance:        instance,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
 	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
 
-	return nil
+func processEBSVolume(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	embedModel, genID string,
+	workItem pkg.WorkItem,
+) error {
+	volume := workItem.EBSVolume
+	pkg.Infof("Processing EBS volume: %s", volume.VolumeID)
+
+	// Marshal volume
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return fmt.Errorf("failed to marshal EBS volume %s: %w", volume.VolumeID, err)
+	}
+	record := string(data)
+
+	// Embedding
+	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
+	if err != nil {
+		return fmt.Errorf("embed error for EBS %s: %w", volume.VolumeID, err)
+	}
+
+	result, err := pkg.AnalyzeEBSVolumeWithBedrock(ctx, brClient, genID, volume, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", volume.VolumeID, err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for EBS %s: %v", volume.VolumeID, err)
+		analysis = fmt.Sprintf("ERROR: Failed to analyze EBS volume: %v", err)
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeEBS,
+		EBSVolume:          volume,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
 }
 
+func processLambdaFunction(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	embedModel, genID string,
+	workItem pkg.WorkItem,
+) error {
+	function := workItem.LambdaFunction
+	pkg.Infof("Processing Lambda function: %s", function.FunctionName)
+
+	// Marshal function
+	data, err := json.Marshal(function)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Lambda function %s: %w", function.FunctionName, err)
+	}
+	record := string(data)
+
+	// Embedding
+	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
+	if err != nil {
+		return fmt.Errorf("embed error for Lambda %s: %w", function.FunctionName, err)
+	}
+
+	result, err := pkg.AnalyzeLambdaWithBedrock(ctx, brClient, genID, function, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", function.FunctionName, err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for Lambda %s: %v", function.FunctionName, err)
+		analysis = fmt.Sprintf("ERROR: Failed to analyze Lambda function: %v", err)
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeLambda,
+		LambdaFunction:     function,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
+
+func processLoadBalancer(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	embedModel, genID string,
+	workItem pkg.WorkItem,
+) error {
+	loadBalancer := workItem.LoadBalancer
+	pkg.Infof("Processing load balancer: %s", loadBalancer.Name)
+
+	// Marshal load balancer
+	data, err := json.Marshal(loadBalancer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal load balancer %s: %w", loadBalancer.Name, err)
+	}
+	record := string(data)
+
+	// Embedding
+	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
+	if err != nil {
+		return fmt.Errorf("embed error for ELB %s: %w", loadBalancer.Name, err)
+	}
+
+	result, err := pkg.AnalyzeLoadBalancerWithBedrock(ctx, brClient, genID, loadBalancer, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", loadBalancer.Name, err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for ELB %s: %v", loadBalancer.Name, err)
+		analysis = fmt.Sprintf("ERROR: Failed to analyze load balancer: %v", err)
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeELB,
+		LoadBalancer:       loadBalancer,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
+
+func processNetworkResource(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	embedModel, genID string,
+	workItem pkg.WorkItem,
+) error {
+	resource := workItem.NetworkResource
+	pkg.Infof("Processing %s: %s", resource.Type, resource.ResourceID)
+
+	// Marshal resource
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("failed to marshal network resource %s: %w", resource.ResourceID, err)
+	}
+	record := string(data)
+
+	// Embedding; network findings are priced without the model, so only transient errors are retried
+	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("embed error for %s: %w", resource.ResourceID, err)
+	}
+	if err != nil {
+		pkg.Warnf("Embedding failed for %s: %v", resource.ResourceID, err)
+	}
+
+	result, err := pkg.AnalyzeNetworkResourceWithBedrock(ctx, brClient, genID, resource, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", resource.ResourceID, err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for %s, using fixed pricing: %v", resource.ResourceID, err)
+		analysis = pkg.AnalyzeNetworkResourceLocally(resource)
+		genID = ""
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeNetwork,
+		NetworkResource:    resource,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
+
+func processDynamoTable(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	embedModel, genID string,
+	workItem pkg.WorkItem,
+) error {
+	table := workItem.DynamoTable
+	pkg.Infof("Processing DynamoDB table: %s", table.TableName)
+
+	// Marshal table
+	data, err := json.Marshal(table)
+	if err != nil {
+		return fmt.Errorf("failed to marshal DynamoDB table %s: %w", table.TableName, err)
+	}
+	record := string(data)
+
+	// Embedding
+	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
+	if err != nil {
+		return fmt.Errorf("embed error for DynamoDB table %s: %w", table.TableName, err)
+	}
+
+	result, err := pkg.AnalyzeDynamoTableWithBedrock(ctx, brClient, genID, table, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", table.TableName, err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for DynamoDB table %s: %v", table.TableName, err)
+		analysis = fmt.Sprintf("ERROR: Failed to analyze DynamoDB table: %v", err)
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeDynamoDB,
+		DynamoTable:        table,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
+
+func processElastiCacheCluster(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	embedModel, genID string,
+	workItem pkg.WorkItem,
+) error {
+	cluster := workItem.ElastiCache
+	pkg.Infof("Processing ElastiCache cluster: %s", cluster.ClusterID)
+
+	// Marshal cluster
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ElastiCache cluster %s: %w", cluster.ClusterID, err)
+	}
+	record := string(data)
+
+	// Embedding
+	emb, err := pkg.EmbedText(ctx, brClient, embedModel, record)
+	if err != nil {
+		return fmt.Errorf("embed error for ElastiCache cluster %s: %w", cluster.ClusterID, err)
+	}
+
+	result, err := pkg.AnalyzeElastiCacheWithBedrock(ctx, brClient, genID, cluster, emb)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", cluster.ClusterID, err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for ElastiCache cluster %s: %v", cluster.ClusterID, err)
+		analysis = fmt.Sprintf("ERROR: Failed to analyze ElastiCache cluster: %v", err)
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeElastiCache,
+		ElastiCache:        cluster,
+		Embedding:          emb,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
+
+// processSnapshots analyzes all stale snapshots of a job with one summarizing Bedrock call
+func processSnapshots(
+	ctx context.Context,
+	brClient pkg.BedrockInvoker,
+	dynamoClient *dynamodb.Client,
+	genID string,
+	workItem pkg.WorkItem,
+) error {
+	pkg.Infof("Processing %s", workItem.ResourceID())
+
+	// Snapshots are priced without the model, so only transient errors are retried
+	result, err := pkg.AnalyzeSnapshotsWithBedrock(ctx, brClient, genID, workItem.Snapshots)
+	analysis := result.Text
+	if err != nil && isTransientError(err) {
+		return fmt.Errorf("analysis error for %s: %w", workItem.ResourceID(), err)
+	}
+	if err != nil || analysis == "" {
+		pkg.Warnf("Bedrock analysis failed for %s, using fixed pricing: %v", workItem.ResourceID(), err)
+		analysis = pkg.AnalyzeSnapshotsLocally(workItem.Snapshots)
+		genID = ""
+	}
+
+	// Update progress
+	reportItem := pkg.ReportItem{
+		ResourceType:       pkg.ResourceTypeSnapshots,
+		Snapshots:          workItem.Snapshots,
+		Analysis:           analysis,
+		ModelID:            genID,
+		AnalysisUsage:      result.Usage(),
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+	}
+	reportItem.ApplyAnalysisMetrics()
+	return pkg.RecordJobResult(ctx, dynamoClient, workItem, reportItem)
+}
+
+// Build information, set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
 func main() {
+	pkg.SetLogger(pkg.NewLoggerFromEnv())
+	build := pkg.NewBuildInfo(version, commit, date)
+	pkg.SetBuildInfo(build)
+	pkg.Infof("GreenOps worker %s starting", build)
+
+	// Templates in PROMPTS_DIR, e.g. a Lambda layer mounted under /opt, replace the built-in prompts
+	if dir := os.Getenv("PROMPTS_DIR"); dir != "" {
+		if err := prompts.SetDir(dir); err != nil {
+			pkg.Fatalf("invalid prompt templates: %v", err)
+		}
+		pkg.Infof("Using prompt templates from %s", dir)
+	}
+	prompts.SetFocusAreas(prompts.ParseFocusAreas(os.Getenv("PROMPT_FOCUS_AREAS")))
+
+	// Jobs that finish are announced on NOTIFY_TOPIC_ARN, when it is set
+	if topicARN := os.Getenv("NOTIFY_TOPIC_ARN"); topicARN != "" {
+		awsCfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			pkg.Fatalf("unable to load AWS config for job notifications: %v", err)
+		}
+		pkg.SetJobNotifier(pkg.NewSNSClient(awsCfg), topicARN)
+		pkg.Infof("Publishing job completions to %s", topicARN)
+	}
 	lambda.Start(Handler)
 }
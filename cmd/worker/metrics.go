@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// workerMetricsNamespace is the CloudWatch namespace of the worker's EMF metrics
+const workerMetricsNamespace = "GreenOps/Worker"
+
+// meteredBedrock wraps the Bedrock client to time the embedding and generation calls of
+// the item being processed and to count the throttled calls the SDK retried
+type meteredBedrock struct {
+	client     pkg.BedrockInvoker
+	embedModel string
+
+	embedDuration  time.Duration
+	invokeDuration time.Duration
+	throttles      atomic.Int64
+}
+
+// InvokeModel calls Bedrock, adding the call's duration to the embedding or generation total
+func (m *meteredBedrock) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	start := time.Now()
+	resp, err := m.client.InvokeModel(ctx, params, optFns...)
+	if aws.ToString(params.ModelId) == m.embedModel {
+		m.embedDuration += time.Since(start)
+	} else {
+		m.invokeDuration += time.Since(start)
+	}
+	return resp, err
+}
+
+// Converse calls Bedrock, adding the call's duration to the generation total
+func (m *meteredBedrock) Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	start := time.Now()
+	resp, err := m.client.Converse(ctx, params, optFns...)
+	m.invokeDuration += time.Since(start)
+	return resp, err
+}
+
+// reset clears the durations before the next item; throttles keep counting and are read as a delta
+func (m *meteredBedrock) reset() {
+	m.embedDuration = 0
+	m.invokeDuration = 0
+}
+
+// countThrottles returns a client option that counts every retry of a throttled Bedrock call
+func (m *meteredBedrock) countThrottles() func(*bedrockruntime.Options) {
+	return func(o *bedrockruntime.Options) {
+		o.Retryer = &throttleCountingRetryer{Retryer: o.Retryer, count: &m.throttles}
+	}
+}
+
+// throttleCountingRetryer counts the retries caused by throttling errors
+type throttleCountingRetryer struct {
+	aws.Retryer
+	count *atomic.Int64
+}
+
+// RetryDelay is called once per retry, so throttled attempts are counted here
+func (r *throttleCountingRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		r.count.Add(1)
+	}
+	return r.Retryer.RetryDelay(attempt, err)
+}
+
+// itemOutcome is how processing one work item ended
+type itemOutcome int
+
+const (
+	itemSucceeded itemOutcome = iota
+	itemFailed
+	itemRetried // left on the queue after a transient error
+)
+
+// emitItemMetrics writes the EMF record of one processed work item
+func emitItemMetrics(emf *pkg.EMFLogger, itemType, model string, duration time.Duration, bedrock *meteredBedrock, throttles int64, outcome itemOutcome) {
+	var succeeded, failed float64
+	switch outcome {
+	case itemSucceeded:
+		succeeded = 1
+	case itemFailed:
+		failed = 1
+	}
+
+	err := emf.Emit(map[string]string{"item_type": itemType, "model": model},
+		pkg.EMFMetric{Name: "ItemProcessingDuration", Unit: pkg.EMFUnitMilliseconds, Value: pkg.DurationMillis(duration)},
+		pkg.EMFMetric{Name: "BedrockInvokeDuration", Unit: pkg.EMFUnitMilliseconds, Value: pkg.DurationMillis(bedrock.invokeDuration)},
+		pkg.EMFMetric{Name: "EmbedDuration", Unit: pkg.EMFUnitMilliseconds, Value: pkg.DurationMillis(bedrock.embedDuration)},
+		pkg.EMFMetric{Name: "ItemsSucceeded", Unit: pkg.EMFUnitCount, Value: succeeded},
+		pkg.EMFMetric{Name: "ItemsFailed", Unit: pkg.EMFUnitCount, Value: failed},
+		pkg.EMFMetric{Name: "ThrottleRetries", Unit: pkg.EMFUnitCount, Value: float64(throttles)},
+	)
+	if err != nil {
+		pkg.Warnf("%v", err)
+	}
+}
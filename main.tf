@@ -53,18 +53,63 @@ data "aws_iam_policy_document" "dynamodb_sqs_access" {
       "dynamodb:PutItem",
       "dynamodb:UpdateItem",
       "dynamodb:Query",
+      "dynamodb:Scan",
+      "dynamodb:BatchWriteItem",
       "sqs:SendMessage",
       "sqs:ReceiveMessage",
       "sqs:DeleteMessage",
-      "sqs:GetQueueAttributes"
+      "sqs:GetQueueAttributes",
+      "sqs:StartMessageMoveTask",
+      "sqs:ListMessageMoveTasks"
     ]
     resources = [
       aws_dynamodb_table.greenops_jobs.arn,
-      aws_sqs_queue.greenops_queue.arn
+      aws_dynamodb_table.greenops_job_results.arn,
+      aws_dynamodb_table.greenops_job_items.arn,
+      aws_dynamodb_table.greenops_analysis_cache.arn,
+      aws_sqs_queue.greenops_queue.arn,
+      aws_sqs_queue.greenops_dlq.arn
     ]
   }
 }
 
+resource "aws_iam_role_policy" "lambda_results_s3" {
+  name   = "greenops_results_s3_access"
+  role   = aws_iam_role.lambda_exec.id
+  policy = data.aws_iam_policy_document.results_s3_access.json
+}
+
+data "aws_iam_policy_document" "results_s3_access" {
+  statement {
+    effect = "Allow"
+    actions   = ["s3:GetObject", "s3:PutObject"]
+    resources = ["${aws_s3_bucket.greenops_results.arn}/jobs/*"]
+  }
+
+  statement {
+    effect    = "Allow"
+    actions   = ["s3:ListBucket"]
+    resources = [aws_s3_bucket.greenops_results.arn]
+  }
+}
+
+resource "aws_iam_role_policy" "lambda_notify_sns" {
+  count  = var.notify_topic_arn == "" ? 0 : 1
+  name   = "greenops_notify_sns_publish"
+  role   = aws_iam_role.lambda_exec.id
+  policy = data.aws_iam_policy_document.notify_sns_publish[0].json
+}
+
+data "aws_iam_policy_document" "notify_sns_publish" {
+  count = var.notify_topic_arn == "" ? 0 : 1
+
+  statement {
+    effect    = "Allow"
+    actions   = ["sns:Publish"]
+    resources = [var.notify_topic_arn]
+  }
+}
+
 resource "aws_iam_role_policy_attachment" "lambda_basic_exec" {
   role       = aws_iam_role.lambda_exec.name
   policy_arn = "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"
@@ -84,6 +129,15 @@ data "aws_iam_policy_document" "bedrock_invoke" {
     ]
     resources = ["*"]
   }
+
+  # Live pricing (PRICING_MODE = "live") reads on-demand prices
+  statement {
+    effect = "Allow"
+    actions = [
+      "pricing:GetProducts"
+    ]
+    resources = ["*"]
+  }
 }
 # DynamoDB table for job tracking
 resource "aws_dynamodb_table" "greenops_jobs" {
@@ -102,6 +156,93 @@ resource "aws_dynamodb_table" "greenops_jobs" {
   }
 }
 
+# DynamoDB table with one result record per processed resource
+resource "aws_dynamodb_table" "greenops_job_results" {
+  name         = "greenops-job-results"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "job_id"
+  range_key    = "item_index"
+
+  attribute {
+    name = "job_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "item_index"
+    type = "N"
+  }
+
+  ttl {
+    attribute_name = "expiration_time"
+    enabled        = true
+  }
+}
+
+# DynamoDB table of the status of each work item, keyed like the results by job and item index
+resource "aws_dynamodb_table" "greenops_job_items" {
+  name         = "greenops-job-items"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "job_id"
+  range_key    = "item_index"
+
+  attribute {
+    name = "job_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "item_index"
+    type = "N"
+  }
+
+  ttl {
+    attribute_name = "expiration_time"
+    enabled        = true
+  }
+}
+
+# DynamoDB table of analyses reused for identical resources, keyed by resource and model fingerprint
+resource "aws_dynamodb_table" "greenops_analysis_cache" {
+  name         = "greenops-analysis-cache"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "fingerprint"
+
+  attribute {
+    name = "fingerprint"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiration_time"
+    enabled        = true
+  }
+}
+
+# S3 bucket holding results of jobs created before the results table, expired along with the job record
+resource "aws_s3_bucket" "greenops_results" {
+  bucket_prefix = "greenops-results-"
+  force_destroy = true
+}
+
+resource "aws_s3_bucket_lifecycle_configuration" "greenops_results" {
+  bucket = aws_s3_bucket.greenops_results.id
+
+  rule {
+    id     = "expire-job-results"
+    status = "Enabled"
+
+    filter {
+      prefix = "jobs/"
+    }
+
+    # Jobs are kept for 1 to 90 days; objects of shorter-lived jobs linger harmlessly
+    expiration {
+      days = 90
+    }
+  }
+}
+
 # SQS Queue for work items
 resource "aws_sqs_queue" "greenops_queue" {
   name                       = "greenops-tasks-queue"
@@ -109,6 +250,19 @@ resource "aws_sqs_queue" "greenops_queue" {
   max_message_size           = 262144
   message_retention_seconds  = 86400
   visibility_timeout_seconds = 300
+
+  # The worker records an item as failed on its 3rd delivery; messages delivered more often
+  # than this crashed or timed out the worker every time
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.greenops_dlq.arn
+    maxReceiveCount     = 5
+  })
+}
+
+# Dead-letter queue of the work queue, drained by the DLQ processor
+resource "aws_sqs_queue" "greenops_dlq" {
+  name                      = "greenops-tasks-dlq"
+  message_retention_seconds = 1209600
 }
 
 #-------------------------
@@ -129,10 +283,23 @@ resource "aws_lambda_function" "greenops_worker" {
 
   environment {
     variables = {
-      EMBED_MODEL_ID  = var.embed_model_id
-      GEN_MODEL_ID    = var.gen_model_id
-      GEN_PROFILE_ARN = var.gen_profile_arn
-      JOBS_TABLE      = aws_dynamodb_table.greenops_jobs.name
+      EMBED_MODEL_ID     = var.embed_model_id
+      GEN_MODEL_ID       = var.gen_model_id
+      GEN_PROFILE_ARN    = var.gen_profile_arn
+      GEN_MODEL_EC2      = var.gen_model_ec2
+      GEN_MODEL_S3       = var.gen_model_s3
+      GEN_MODEL_RDS      = var.gen_model_rds
+      ANALYSIS_FORMAT    = var.analysis_format
+      JOBS_TABLE         = aws_dynamodb_table.greenops_jobs.name
+      RESULTS_TABLE      = aws_dynamodb_table.greenops_job_results.name
+      ITEMS_TABLE        = aws_dynamodb_table.greenops_job_items.name
+      CACHE_TABLE        = aws_dynamodb_table.greenops_analysis_cache.name
+      CACHE_TTL_DAYS     = var.cache_ttl_days
+      PRICING_MODE       = var.pricing_mode
+      LOG_LEVEL          = var.log_level
+      NOTIFY_TOPIC_ARN   = var.notify_topic_arn
+      PROMPTS_DIR        = var.prompts_dir
+      PROMPT_FOCUS_AREAS = var.prompt_focus_areas
     }
   }
 }
@@ -141,6 +308,41 @@ resource "aws_lambda_event_source_mapping" "sqs_worker_trigger" {
   event_source_arn = aws_sqs_queue.greenops_queue.arn
   function_name    = aws_lambda_function.greenops_worker.function_name
   batch_size       = 1
+
+  # Lets the worker return only the messages that should be redelivered
+  function_response_types = ["ReportBatchItemFailures"]
+}
+
+# Records the items of dead-lettered messages as failed, so their jobs still finish
+resource "aws_lambda_function" "greenops_dlq_processor" {
+  function_name = "greenops-dlq-processor"
+  role          = aws_iam_role.lambda_exec.arn
+  handler       = "build/dlq-processor/bootstrap"
+  runtime       = "provided.al2"
+  timeout       = 60
+  memory_size   = 128
+
+  filename         = var.dlq_lambda_zip_path
+  source_code_hash = filebase64sha256(var.dlq_lambda_zip_path)
+
+  environment {
+    variables = {
+      JOBS_TABLE       = aws_dynamodb_table.greenops_jobs.name
+      RESULTS_TABLE    = aws_dynamodb_table.greenops_job_results.name
+      ITEMS_TABLE      = aws_dynamodb_table.greenops_job_items.name
+      LOG_LEVEL        = var.log_level
+      NOTIFY_TOPIC_ARN = var.notify_topic_arn
+    }
+  }
+}
+
+resource "aws_lambda_event_source_mapping" "sqs_dlq_trigger" {
+  event_source_arn = aws_sqs_queue.greenops_dlq.arn
+  function_name    = aws_lambda_function.greenops_dlq_processor.function_name
+  batch_size       = 10
+  enabled          = var.dlq_processor_enabled
+
+  function_response_types = ["ReportBatchItemFailures"]
 }
 
 resource "aws_lambda_function" "greenops_api" {
@@ -156,11 +358,28 @@ resource "aws_lambda_function" "greenops_api" {
 
   environment {
     variables = {
-      EMBED_MODEL_ID  = var.embed_model_id
-      GEN_PROFILE_ARN = var.gen_profile_arn
-      GEN_MODEL_ID    = var.gen_model_id
-      JOBS_TABLE      = aws_dynamodb_table.greenops_jobs.name
-      QUEUE_URL       = aws_sqs_queue.greenops_queue.url
+      EMBED_MODEL_ID     = var.embed_model_id
+      GEN_PROFILE_ARN    = var.gen_profile_arn
+      GEN_MODEL_ID       = var.gen_model_id
+      GEN_MODEL_EC2      = var.gen_model_ec2
+      GEN_MODEL_S3       = var.gen_model_s3
+      GEN_MODEL_RDS      = var.gen_model_rds
+      ALLOWED_GEN_MODELS = var.allowed_gen_models
+      ANALYSIS_FORMAT    = var.analysis_format
+      JOBS_TABLE         = aws_dynamodb_table.greenops_jobs.name
+      QUEUE_URL          = aws_sqs_queue.greenops_queue.url
+      DLQ_ARN            = aws_sqs_queue.greenops_dlq.arn
+      RESULTS_TABLE      = aws_dynamodb_table.greenops_job_results.name
+      ITEMS_TABLE        = aws_dynamodb_table.greenops_job_items.name
+      RESULTS_BUCKET     = aws_s3_bucket.greenops_results.bucket
+      CACHE_TABLE        = aws_dynamodb_table.greenops_analysis_cache.name
+      CACHE_TTL_DAYS     = var.cache_ttl_days
+      JOB_TTL_DAYS       = var.job_ttl_days
+      LOG_LEVEL          = var.log_level
+      API_KEYS           = var.api_keys
+      ADMIN_API_KEYS     = var.admin_api_keys
+      MAX_ITEMS          = var.max_items
+      NOTIFY_TOPIC_ARN   = var.notify_topic_arn
     }
   }
 }
@@ -194,6 +413,36 @@ resource "aws_apigatewayv2_route" "job_results_route" {
   target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
 }
 
+resource "aws_apigatewayv2_route" "job_results_stream_route" {
+  api_id    = aws_apigatewayv2_api.http_api.id
+  route_key = "GET /jobs/{id}/results/stream"
+  target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
+}
+
+
+resource "aws_apigatewayv2_route" "job_retry_route" {
+  api_id    = aws_apigatewayv2_api.http_api.id
+  route_key = "POST /jobs/{id}/retry"
+  target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
+}
+
+resource "aws_apigatewayv2_route" "job_request_route" {
+  api_id    = aws_apigatewayv2_api.http_api.id
+  route_key = "GET /jobs/{id}/request"
+  target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
+}
+
+resource "aws_apigatewayv2_route" "dlq_requeue_route" {
+  api_id    = aws_apigatewayv2_api.http_api.id
+  route_key = "POST /admin/dlq/requeue"
+  target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
+}
+
+resource "aws_apigatewayv2_route" "job_list_route" {
+  api_id    = aws_apigatewayv2_api.http_api.id
+  route_key = "GET /jobs"
+  target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
+}
 
 resource "aws_apigatewayv2_route" "job_status_route" {
   api_id    = aws_apigatewayv2_api.http_api.id
@@ -201,6 +450,12 @@ resource "aws_apigatewayv2_route" "job_status_route" {
   target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
 }
 
+resource "aws_apigatewayv2_route" "job_cancel_route" {
+  api_id    = aws_apigatewayv2_api.http_api.id
+  route_key = "DELETE /jobs/{id}"
+  target    = "integrations/${aws_apigatewayv2_integration.lambda_integ.id}"
+}
+
 # Default stage
 resource "aws_apigatewayv2_stage" "default" {
   api_id      = aws_apigatewayv2_api.http_api.id
@@ -238,6 +493,18 @@ variable "worker_lambda_zip_path" {
   default     = "./worker.zip"
 }
 
+variable "dlq_lambda_zip_path" {
+  description = "Path to the compiled DLQ processor Lambda zip file"
+  type        = string
+  default     = "./dlq-processor.zip"
+}
+
+variable "dlq_processor_enabled" {
+  description = "Whether the DLQ processor drains the dead-letter queue. Disable it to keep dead-lettered messages for greenops jobs requeue-dlq"
+  type        = bool
+  default     = true
+}
+
 variable "queue_url_output" {
   description = "Output the SQS queue URL"
   type        = bool
@@ -261,6 +528,100 @@ variable "gen_model_id" {
   default     = "amazon.titan-tg1-large"
 }
 
+# The API fingerprints cached analyses with the model that will analyze each resource, so
+# both Lambdas get the same routing
+variable "gen_model_ec2" {
+  description = "Bedrock model or inference profile for EC2 instances; empty uses the generic model"
+  type        = string
+  default     = ""
+}
+
+variable "gen_model_s3" {
+  description = "Bedrock model or inference profile for S3 buckets; empty uses the generic model"
+  type        = string
+  default     = ""
+}
+
+variable "gen_model_rds" {
+  description = "Bedrock model or inference profile for RDS instances; empty uses the generic model"
+  type        = string
+  default     = ""
+}
+
+variable "allowed_gen_models" {
+  description = "Comma-separated models an analyze request may choose with its model field; empty rejects requests that choose one"
+  type        = string
+  default     = ""
+}
+
+variable "analysis_format" {
+  description = "Format the worker asks the model for: \"markdown\", or \"json\" for a JSON object decoded into structured_analysis"
+  type        = string
+  default     = "markdown"
+}
+
+variable "prompts_dir" {
+  description = "Directory, e.g. in a Lambda layer under /opt, whose ec2.tmpl, s3.tmpl and rds.tmpl replace the worker's built-in prompts; empty uses the built-in ones"
+  type        = string
+  default     = ""
+}
+
+variable "prompt_focus_areas" {
+  description = "Comma-separated focus areas the analysis prompts ask the model to pay particular attention to"
+  type        = string
+  default     = ""
+}
+
+variable "pricing_mode" {
+  description = "Where the worker gets EC2 and RDS prices: \"bundled\" price table or \"live\" AWS Pricing API"
+  type        = string
+  default     = "bundled"
+}
+
+variable "log_level" {
+  description = "Lambda log level: debug, info, warn or error. Raw request bodies and model payloads are only logged at debug"
+  type        = string
+  default     = "info"
+}
+
+variable "api_keys" {
+  description = "Comma-separated API keys accepted in the x-api-key header. Empty leaves the API open"
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "admin_api_keys" {
+  description = "Comma-separated API keys that may call the /admin routes, such as POST /admin/dlq/requeue. Empty disables them"
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "cache_ttl_days" {
+  description = "Days an analysis is reused for an identical resource and model instead of calling Bedrock again. 0 turns the cache off"
+  type        = number
+  default     = 7
+}
+
+variable "job_ttl_days" {
+  description = "Days jobs and their results are kept unless an analyze request asks otherwise, 1 to 90"
+  type        = number
+  default     = 7
+}
+
+variable "max_items" {
+  description = "Most resources one analyze request may carry; larger requests get a 413"
+  type        = number
+  default     = 200
+}
+
+variable "notify_topic_arn" {
+  description = "ARN of an SNS topic told about every job that finishes. Empty publishes nothing"
+  type        = string
+  default     = ""
+}
+
 #-------------------------
 # Outputs
 #-------------------------
@@ -272,4 +633,9 @@ output "api_endpoint" {
 output "queue_url" {
   description = "URL of the SQS queue"
   value       = aws_sqs_queue.greenops_queue.url
+}
+
+output "dlq_url" {
+  description = "URL of the dead-letter queue of the SQS queue"
+  value       = aws_sqs_queue.greenops_dlq.url
 }
\ No newline at end of file
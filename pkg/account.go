@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alexalbu001/greenops/pkg/prompts"
+)
+
+// accountTopSavings is how many of the resources with the largest savings the account
+// prompt lists
+const accountTopSavings = 5
+
+// AccountSummary is the fleet-wide view of a report that the account-level recommendations
+// are written from. Costs are per month from the analyses of the resources.
+// - AvgCPUUtilization: the mean CPUAvg7d of the EC2 instances, RDS instances and ElastiCache clusters with a CPU metric, CPUResources of them
+// - UntaggedResources: resources without a single tag; the snapshots item is left out of it and of UntaggedPct
+// - IdleResources: EC2 instances the scan classified as idle
+// - BucketsWithoutLifecycle: S3 buckets with no enabled lifecycle rule, holding StorageWithoutLifecycleGB
+type AccountSummary struct {
+	Resources                 int            `json:"resources" dynamodbav:"resources"`
+	ResourceCounts            map[string]int `json:"resource_counts" dynamodbav:"resource_counts"`
+	AvgCPUUtilization         float64        `json:"avg_cpu_utilization" dynamodbav:"avg_cpu_utilization"`
+	CPUResources              int            `json:"cpu_resources" dynamodbav:"cpu_resources"`
+	UntaggedResources         int            `json:"untagged_resources" dynamodbav:"untagged_resources"`
+	UntaggedPct               float64        `json:"untagged_pct" dynamodbav:"untagged_pct"`
+	IdleResources             int            `json:"idle_resources" dynamodbav:"idle_resources"`
+	BucketsWithoutLifecycle   int            `json:"buckets_without_lifecycle" dynamodbav:"buckets_without_lifecycle"`
+	StorageWithoutLifecycleGB float64        `json:"storage_without_lifecycle_gb" dynamodbav:"storage_without_lifecycle_gb"`
+	MonthlyCost               float64        `json:"monthly_cost" dynamodbav:"monthly_cost"`
+	MonthlySavings            float64        `json:"monthly_savings" dynamodbav:"monthly_savings"`
+	CO2KgMonthly              float64        `json:"co2_kg_monthly" dynamodbav:"co2_kg_monthly"`
+}
+
+// AccountRecommendations is the "Account-Level Recommendations" section of a report: the
+// analysis the model wrote from the AccountSummary, with its completeness and usage
+type AccountRecommendations struct {
+	Summary            AccountSummary      `json:"summary" dynamodbav:"summary"`
+	Analysis           string              `json:"analysis" dynamodbav:"analysis"`
+	StructuredAnalysis *StructuredAnalysis `json:"structured_analysis,omitempty" dynamodbav:"structured_analysis,omitempty"`
+	AnalysisQuality    *AnalysisQuality    `json:"analysis_quality,omitempty" dynamodbav:"analysis_quality,omitempty"`
+	ModelID            string              `json:"model_id,omitempty" dynamodbav:"model_id,omitempty"`
+	AnalysisUsage
+}
+
+// reportItemTags returns the tags of an item's resource; ok is false for the snapshots item,
+// which carries many resources
+func reportItemTags(item ReportItem) (map[string]string, bool) {
+	switch item.GetResourceType() {
+	case ResourceTypeEC2:
+		return item.Instance.Tags, true
+	case ResourceTypeS3:
+		return item.S3Bucket.Tags, true
+	case ResourceTypeRDS:
+		return item.RDSInstance.Tags, true
+	case ResourceTypeEBS:
+		return item.EBSVolume.Tags, true
+	case ResourceTypeLambda:
+		return item.LambdaFunction.Tags, true
+	case ResourceTypeELB:
+		return item.LoadBalancer.Tags, true
+	case ResourceTypeNetwork:
+		return item.NetworkResource.Tags, true
+	case ResourceTypeDynamoDB:
+		return item.DynamoTable.Tags, true
+	case ResourceTypeElastiCache:
+		return item.ElastiCache.Tags, true
+	}
+	return nil, false
+}
+
+// reportItemCPU returns the average CPU utilization of an item's resource; ok is false for
+// resources without a CPU metric, and for a zero average, which is a missing metric
+func reportItemCPU(item ReportItem) (float64, bool) {
+	var cpu float64
+	switch item.GetResourceType() {
+	case ResourceTypeEC2:
+		cpu = item.Instance.CPUAvg7d
+	case ResourceTypeRDS:
+		cpu = item.RDSInstance.CPUAvg7d
+	case ResourceTypeElastiCache:
+		cpu = item.ElastiCache.CPUAvg7d
+	}
+	return cpu, cpu > 0
+}
+
+// hasEnabledLifecycleRule reports whether any lifecycle rule of a bucket is enabled
+func (b S3Bucket) hasEnabledLifecycleRule() bool {
+	for _, rule := range b.LifecycleRules {
+		if rule.Status == "Enabled" {
+			return true
+		}
+	}
+	return false
+}
+
+// SummarizeAccount computes the fleet-wide statistics of a report: average CPU utilization,
+// the share of untagged resources, the idle count, the S3 storage no lifecycle rule manages,
+// and the cost, savings and CO2 totals of SummarizeReport
+func SummarizeAccount(report []ReportItem) AccountSummary {
+	totals := SummarizeReport(report)
+	summary := AccountSummary{
+		Resources:      totals.TotalResources,
+		ResourceCounts: totals.ResourceCounts,
+		IdleResources:  totals.IdleResources,
+		MonthlyCost:    totals.TotalCost,
+		MonthlySavings: totals.PotentialCostSavings,
+		CO2KgMonthly:   totals.TotalCO2,
+	}
+
+	var cpuTotal float64
+	taggable := 0
+	for _, item := range report {
+		if cpu, ok := reportItemCPU(item); ok {
+			cpuTotal += cpu
+			summary.CPUResources++
+		}
+		if tags, ok := reportItemTags(item); ok {
+			taggable++
+			if len(tags) == 0 {
+				summary.UntaggedResources++
+			}
+		}
+		if item.GetResourceType() == ResourceTypeS3 && !item.S3Bucket.hasEnabledLifecycleRule() {
+			summary.BucketsWithoutLifecycle++
+			summary.StorageWithoutLifecycleGB += float64(item.S3Bucket.SizeBytes) / (1024 * 1024 * 1024)
+		}
+	}
+	if summary.CPUResources > 0 {
+		summary.AvgCPUUtilization = cpuTotal / float64(summary.CPUResources)
+	}
+	if taggable > 0 {
+		summary.UntaggedPct = float64(summary.UntaggedResources) / float64(taggable) * 100
+	}
+	return summary
+}
+
+// topSavingsNote lists the resources of a report with the largest savings for the account
+// prompt, one per line, or returns "" when none has any
+func topSavingsNote(report []ReportItem) string {
+	var sb strings.Builder
+	for i, row := range SummarizeResources(report) {
+		if i == accountTopSavings || row.MonthlySavings <= 0 {
+			break
+		}
+		fmt.Fprintf(&sb, "- %s %s: $%.2f of $%.2f per month\n", row.ResourceType, row.ResourceID, row.MonthlySavings, row.MonthlyCost)
+	}
+	return sb.String()
+}
+
+// AnalyzeAccount makes one more Bedrock call after the resources of a report are analyzed,
+// asking for recommendations on the account as a whole from its AccountSummary. The prompt
+// is the account template, and the reply is generated and validated like a resource analysis.
+func AnalyzeAccount(ctx context.Context, client BedrockInvoker, modelID string, report []ReportItem) (*AccountRecommendations, error) {
+	if len(report) == 0 {
+		return nil, errors.New("no analyzed resources to summarize")
+	}
+	summary := SummarizeAccount(report)
+	summaryJSON, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal account summary: %w", err)
+	}
+
+	prompt, err := prompts.Render(prompts.Account, prompts.Data{
+		Resource:   string(summaryJSON),
+		TopSavings: topSavingsNote(report),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := generateAnalysis(ctx, client, modelID, ResourceTypeAccount, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("account analysis failed: %w", err)
+	}
+	return &AccountRecommendations{
+		Summary:            summary,
+		Analysis:           result.Text,
+		StructuredAnalysis: result.Structured,
+		AnalysisQuality:    result.Quality,
+		ModelID:            modelID,
+		AnalysisUsage:      result.Usage(),
+	}, nil
+}
+
+// printAccountRecommendations prints the account-level recommendations at the top of the
+// text report, when there are any
+func printAccountRecommendations(w io.Writer, account *AccountRecommendations, opts ReportOptions) {
+	if account == nil {
+		return
+	}
+	labelColor, reset := "", ""
+	if opts.Colorize {
+		labelColor, reset = ColorCyan, ColorReset
+	}
+
+	fmt.Fprintln(w)
+	printHeader(w, "Account-Level Recommendations", opts.Colorize)
+	summary := account.Summary
+	fmt.Fprintf(w, "%sResources:%s %d, %d idle\n", labelColor, reset, summary.Resources, summary.IdleResources)
+	if summary.CPUResources > 0 {
+		fmt.Fprintf(w, "%sAverage CPU:%s %.1f%% across %d resources\n", labelColor, reset, summary.AvgCPUUtilization, summary.CPUResources)
+	}
+	fmt.Fprintf(w, "%sUntagged:%s %d (%.1f%%)\n", labelColor, reset, summary.UntaggedResources, summary.UntaggedPct)
+	if summary.BucketsWithoutLifecycle > 0 {
+		fmt.Fprintf(w, "%sWithout lifecycle rules:%s %d buckets holding %.2f GB\n", labelColor, reset, summary.BucketsWithoutLifecycle, summary.StorageWithoutLifecycleGB)
+	}
+	fmt.Fprintln(w)
+
+	// The analysis is printed like a resource's, flagged when it is incomplete
+	printItemAnalysis(w, ReportItem{
+		Analysis:           account.Analysis,
+		StructuredAnalysis: account.StructuredAnalysis,
+		AnalysisQuality:    account.AnalysisQuality,
+	}, opts)
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MinAnalysisScore is the completeness score below which an analysis is asked for again, and
+// flagged in reports when the second attempt is no better. It is met when every figure is
+// present and at most one section is missing.
+const MinAnalysisScore = 90
+
+// AnalysisQuality is how complete an analysis is: Score from 0 to 100, and the sections and
+// figures of the prompt template it lacks
+type AnalysisQuality struct {
+	Score   int      `json:"score" dynamodbav:"score"`
+	Missing []string `json:"missing,omitempty" dynamodbav:"missing,omitempty"`
+}
+
+// IsLow reports whether the figures of the analysis should not be trusted
+func (q *AnalysisQuality) IsLow() bool {
+	return q != nil && q.Score < MinAnalysisScore
+}
+
+// analysisFigures are the figures every prompt asks for in the Cost & Environmental Impact
+// section. They weigh twice as much as a section, since the summary is built from them.
+var analysisFigures = []struct {
+	name  string
+	regex *regexp.Regexp
+}{
+	{"Estimated Monthly Cost", monthlyCostRegex},
+	{"Potential Optimized Cost", optimizedCostRegex},
+	{"Monthly Savings Potential", monthlySavingsRegex},
+	{"CO2 Footprint", co2FootprintRegex},
+}
+
+const analysisFigureWeight = 2
+
+// requiredAnalysisSections returns the headings the prompt template of a resource type asks
+// for. Network findings and snapshots list no inefficiencies.
+func requiredAnalysisSections(resourceType ResourceType) []string {
+	sections := []string{"Recommendations", costImpactSectionName, "Sustainability Tips"}
+	if resourceType != ResourceTypeNetwork && resourceType != ResourceTypeSnapshots {
+		sections = append([]string{"Inefficiencies Identified"}, sections...)
+	}
+	return sections
+}
+
+// ValidateAnalysis scores how completely an analysis follows the prompt template of its
+// resource type: whether each required section is there, and whether each figure can be
+// read from the Cost & Environmental Impact section. Missing lists what was not found.
+func ValidateAnalysis(text string, resourceType ResourceType) AnalysisQuality {
+	var quality AnalysisQuality
+	total, found := 0, 0
+
+	for _, section := range requiredAnalysisSections(resourceType) {
+		total++
+		if strings.Contains(text, section) {
+			found++
+		} else {
+			quality.Missing = append(quality.Missing, section+" section")
+		}
+	}
+
+	costSection := ""
+	if index := strings.Index(text, costImpactSectionName); index != -1 {
+		costSection = text[index:]
+	}
+	for _, figure := range analysisFigures {
+		total += analysisFigureWeight
+		if figure.regex.MatchString(costSection) {
+			found += analysisFigureWeight
+		} else {
+			quality.Missing = append(quality.Missing, figure.name)
+		}
+	}
+
+	quality.Score = found * 100 / total
+	return quality
+}
+
+// analysisNudge is appended to a prompt whose analysis was incomplete, naming what it lacked
+func analysisNudge(quality AnalysisQuality) string {
+	return "\n\nIMPORTANT: a previous answer to this request left out: " + strings.Join(quality.Missing, ", ") +
+		". Follow the format above exactly, including every section, and give every figure in the " +
+		costImpactSectionName + " section as a plain number."
+}
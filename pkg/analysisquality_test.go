@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// wellFormedAnalysis is an analysis with every section and figure the EC2 template asks for
+var wellFormedAnalysis = "# EC2 Instance Analysis: i-0abc\n\n" +
+	"### Inefficiencies Identified\n1. Over-provisioned: 3% CPU\n\n" +
+	"### Optimization Recommendations\n1. Downsize to m5.large\n\n" +
+	costImpact("12.35", "140.16", "70.08", "70.08", "50.0") + "\n" +
+	"## Sustainability Tips\n1. Schedule shutdowns\n"
+
+func TestValidateAnalysis(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		resourceType ResourceType
+		wantScore    int
+		wantMissing  []string
+	}{
+		{
+			name:         "well-formed",
+			text:         wellFormedAnalysis,
+			resourceType: ResourceTypeEC2,
+			wantScore:    100,
+		},
+		{
+			name:         "missing a section",
+			text:         strings.Replace(wellFormedAnalysis, "## Sustainability Tips", "## Tips", 1),
+			resourceType: ResourceTypeEC2,
+			wantScore:    91,
+			wantMissing:  []string{"Sustainability Tips section"},
+		},
+		{
+			name:         "figure not a plain number",
+			text:         strings.Replace(wellFormedAnalysis, "Potential Optimized Cost: $70.08", "Potential Optimized Cost: about seventy dollars", 1),
+			resourceType: ResourceTypeEC2,
+			wantScore:    83,
+			wantMissing:  []string{"Potential Optimized Cost"},
+		},
+		{
+			// Figures only count inside the Cost & Environmental Impact section
+			name:         "figures outside their section",
+			text:         strings.Replace(wellFormedAnalysis, "## "+costImpactSectionName, "## Impact", 1),
+			resourceType: ResourceTypeEC2,
+			wantScore:    25,
+			wantMissing:  []string{"Cost & Environmental Impact section", "Estimated Monthly Cost", "Potential Optimized Cost", "Monthly Savings Potential", "CO2 Footprint"},
+		},
+		{
+			name:         "network findings list no inefficiencies",
+			text:         strings.Replace(wellFormedAnalysis, "### Inefficiencies Identified", "### Findings", 1),
+			resourceType: ResourceTypeNetwork,
+			wantScore:    100,
+		},
+		{
+			name:         "inefficiencies required of RDS",
+			text:         strings.Replace(wellFormedAnalysis, "### Inefficiencies Identified", "### Findings", 1),
+			resourceType: ResourceTypeRDS,
+			wantScore:    91,
+			wantMissing:  []string{"Inefficiencies Identified section"},
+		},
+		{
+			name:         "garbage",
+			text:         "I'm sorry, I can't help with that.",
+			resourceType: ResourceTypeS3,
+			wantScore:    0,
+			wantMissing:  []string{"Inefficiencies Identified section", "Recommendations section", "Cost & Environmental Impact section", "Sustainability Tips section", "Estimated Monthly Cost", "Potential Optimized Cost", "Monthly Savings Potential", "CO2 Footprint"},
+		},
+		{
+			name:         "empty",
+			resourceType: ResourceTypeSnapshots,
+			wantScore:    0,
+			wantMissing:  []string{"Recommendations section", "Cost & Environmental Impact section", "Sustainability Tips section", "Estimated Monthly Cost", "Potential Optimized Cost", "Monthly Savings Potential", "CO2 Footprint"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateAnalysis(tt.text, tt.resourceType)
+			if got.Score != tt.wantScore {
+				t.Errorf("Score = %d, want %d", got.Score, tt.wantScore)
+			}
+			if fmt.Sprint(got.Missing) != fmt.Sprint(tt.wantMissing) {
+				t.Errorf("Missing = %q, want %q", got.Missing, tt.wantMissing)
+			}
+			if low := got.Score < MinAnalysisScore; got.IsLow() != low {
+				t.Errorf("IsLow() = %v at score %d", got.IsLow(), got.Score)
+			}
+		})
+	}
+}
+
+// An incomplete analysis is asked for once more, naming what it lacked, and the more
+// complete of the two is kept with the tokens of both calls
+func TestGenerateMarkdownAnalysisRetries(t *testing.T) {
+	partial := strings.Replace(wellFormedAnalysis, "Estimated Monthly Cost: $140.16", "Estimated Monthly Cost: unknown", 1)
+	garbage := "No analysis."
+
+	tests := []struct {
+		name      string
+		replies   []string
+		wantText  string
+		wantScore int
+		wantCalls int
+	}{
+		{name: "complete", replies: []string{wellFormedAnalysis}, wantText: wellFormedAnalysis, wantScore: 100, wantCalls: 1},
+		{name: "retry is better", replies: []string{partial, wellFormedAnalysis}, wantText: wellFormedAnalysis, wantScore: 100, wantCalls: 2},
+		{name: "retry is worse", replies: []string{partial, garbage}, wantText: partial, wantScore: 83, wantCalls: 2},
+		{name: "garbage twice", replies: []string{garbage, garbage}, wantText: garbage, wantScore: 0, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts []string
+			bedrock := &fakeBedrock{converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+				prompts = append(prompts, prompt)
+				return converseText(tt.replies[len(prompts)-1], 100, 20), nil
+			}}
+
+			result, err := generateMarkdownAnalysis(context.Background(), bedrock, "quality.test-model", ResourceTypeEC2, "Analyze i-0abc")
+			if err != nil {
+				t.Fatalf("generateMarkdownAnalysis() error = %v", err)
+			}
+			if len(prompts) != tt.wantCalls {
+				t.Fatalf("model called %d times, want %d", len(prompts), tt.wantCalls)
+			}
+			if result.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", result.Text, tt.wantText)
+			}
+			if result.Quality == nil || result.Quality.Score != tt.wantScore {
+				t.Errorf("Quality = %+v, want score %d", result.Quality, tt.wantScore)
+			}
+			if result.InputTokens != 100*tt.wantCalls || result.OutputTokens != 20*tt.wantCalls {
+				t.Errorf("tokens = %d, %d, want those of %d calls", result.InputTokens, result.OutputTokens, tt.wantCalls)
+			}
+			if tt.wantCalls == 2 && !strings.Contains(prompts[1], "IMPORTANT: a previous answer to this request left out: ") {
+				t.Errorf("retry prompt does not name what was missing:\n%s", prompts[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultAnalyzeConcurrency matches the worker pool size used by the collectors
+const defaultAnalyzeConcurrency = 5
+
+// AnalyzeOptions configures AnalyzeResources
+type AnalyzeOptions struct {
+	GenModel    string        // generation model or inference profile
+	EmbedModel  string        // embedding model
+	Concurrency int           // resources analyzed at once, defaults to 5
+	ItemTimeout time.Duration // limit for a single resource, 0 for none
+	// Progress, if set, is called after every finished item. Calls never overlap.
+	Progress func(done, total int)
+}
+
+// ItemError records a resource that could not be analyzed
+type ItemError struct {
+	ItemIndex  int
+	ResourceID string
+	Err        error
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("item %d (%s): %v", e.ItemIndex, e.ResourceID, e.Err)
+}
+
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+// AnalyzeResources embeds and analyzes the work items with Bedrock using a bounded pool of
+// goroutines. Results are returned in item order with failed items left out; each failure
+// is reported as an ItemError instead. Cancelling ctx stops work that has not started yet.
+func AnalyzeResources(ctx context.Context, invoker BedrockInvoker, items []WorkItem, opts AnalyzeOptions) ([]ReportItem, []ItemError) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultAnalyzeConcurrency
+	}
+
+	results := make([]*ReportItem, len(items))
+	var errs []ItemError
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	done := 0
+
+	finish := func(i int, item *ReportItem, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, ItemError{ItemIndex: items[i].ItemIndex, ResourceID: items[i].ResourceID(), Err: err})
+		} else {
+			results[i] = item
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(done, len(items))
+		}
+	}
+
+	for i := range items {
+		// Wait for a free slot unless the run is cancelled
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			finish(i, nil, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			itemCtx := ctx
+			if opts.ItemTimeout > 0 {
+				var cancel context.CancelFunc
+				itemCtx, cancel = context.WithTimeout(ctx, opts.ItemTimeout)
+				defer cancel()
+			}
+
+			item, err := AnalyzeWorkItem(itemCtx, invoker, opts.GenModel, opts.EmbedModel, items[i])
+			if err != nil {
+				finish(i, nil, err)
+				return
+			}
+			finish(i, &item, nil)
+		}(i)
+	}
+	wg.Wait()
+	sort.Slice(errs, func(i, j int) bool { return errs[i].ItemIndex < errs[j].ItemIndex })
+
+	report := make([]ReportItem, 0, len(items))
+	for _, item := range results {
+		if item != nil {
+			report = append(report, *item)
+		}
+	}
+	return report, errs
+}
+
+// AnalyzeWorkItem embeds and analyzes a single resource, returning its report item with
+// the cost and CO2 figures parsed from the analysis
+func AnalyzeWorkItem(ctx context.Context, invoker BedrockInvoker, genModel, embedModel string, workItem WorkItem) (ReportItem, error) {
+	var resource interface{}
+	switch workItem.ItemType {
+	case "ec2":
+		resource = workItem.Instance
+	case "s3":
+		resource = workItem.S3Bucket
+	case "rds":
+		resource = workItem.RDSInstance
+	case "ebs":
+		resource = workItem.EBSVolume
+	case "lambda":
+		resource = workItem.LambdaFunction
+	case "elb":
+		resource = workItem.LoadBalancer
+	case "network":
+		resource = workItem.NetworkResource
+	case "dynamodb":
+		resource = workItem.DynamoTable
+	case "elasticache":
+		resource = workItem.ElastiCache
+	case "snapshots":
+		// Snapshots are summarized from their totals, so no embedding is needed
+		result, err := AnalyzeSnapshotsWithBedrock(ctx, invoker, genModel, workItem.Snapshots)
+		analysis, modelID := result.Text, genModel
+		if err != nil {
+			Warnf("Bedrock analysis failed for %s, using fixed pricing: %v", workItem.ResourceID(), err)
+			analysis, modelID = AnalyzeSnapshotsLocally(workItem.Snapshots), ""
+		}
+		item := ReportItem{ResourceType: ResourceTypeSnapshots, Snapshots: workItem.Snapshots, Analysis: analysis, ModelID: modelID, AnalysisUsage: result.Usage(), StructuredAnalysis: result.Structured, AnalysisQuality: result.Quality}
+		item.ApplyAnalysisMetrics()
+		return item, nil
+	default:
+		return ReportItem{}, fmt.Errorf("unknown item type: %s", workItem.ItemType)
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return ReportItem{}, fmt.Errorf("failed to marshal %s: %w", workItem.ResourceID(), err)
+	}
+	record := string(data)
+
+	// Network findings are priced without the model, so they do not depend on Bedrock
+	emb, err := EmbedText(ctx, invoker, embedModel, record)
+	if err != nil && workItem.ItemType != "network" {
+		return ReportItem{}, err
+	}
+
+	var item ReportItem
+	var result InvokeResult
+	modelID := genModel
+	switch workItem.ItemType {
+	case "ec2":
+		result, err = AnalyzeInstance(ctx, invoker, genModel, record, workItem.Instance)
+		item = ReportItem{ResourceType: ResourceTypeEC2, Instance: workItem.Instance}
+	case "s3":
+		result, err = AnalyzeS3BucketWithBedrock(ctx, invoker, genModel, workItem.S3Bucket, emb)
+		item = ReportItem{ResourceType: ResourceTypeS3, S3Bucket: workItem.S3Bucket}
+	case "rds":
+		result, err = AnalyzeRDSInstanceWithBedrock(ctx, invoker, genModel, workItem.RDSInstance, emb)
+		item = ReportItem{ResourceType: ResourceTypeRDS, RDSInstance: workItem.RDSInstance}
+	case "ebs":
+		result, err = AnalyzeEBSVolumeWithBedrock(ctx, invoker, genModel, workItem.EBSVolume, emb)
+		item = ReportItem{ResourceType: ResourceTypeEBS, EBSVolume: workItem.EBSVolume}
+	case "lambda":
+		result, err = AnalyzeLambdaWithBedrock(ctx, invoker, genModel, workItem.LambdaFunction, emb)
+		item = ReportItem{ResourceType: ResourceTypeLambda, LambdaFunction: workItem.LambdaFunction}
+	case "elb":
+		result, err = AnalyzeLoadBalancerWithBedrock(ctx, invoker, genModel, workItem.LoadBalancer, emb)
+		item = ReportItem{ResourceType: ResourceTypeELB, LoadBalancer: workItem.LoadBalancer}
+	case "network":
+		result, err = AnalyzeNetworkResourceWithBedrock(ctx, invoker, genModel, workItem.NetworkResource, emb)
+		if err != nil {
+			Warnf("Bedrock analysis failed for %s, using fixed pricing: %v", workItem.ResourceID(), err)
+			result.Text, err = AnalyzeNetworkResourceLocally(workItem.NetworkResource), nil
+			modelID = ""
+		}
+		item = ReportItem{ResourceType: ResourceTypeNetwork, NetworkResource: workItem.NetworkResource}
+	case "dynamodb":
+		result, err = AnalyzeDynamoTableWithBedrock(ctx, invoker, genModel, workItem.DynamoTable, emb)
+		item = ReportItem{ResourceType: ResourceTypeDynamoDB, DynamoTable: workItem.DynamoTable}
+	case "elasticache":
+		result, err = AnalyzeElastiCacheWithBedrock(ctx, invoker, genModel, workItem.ElastiCache, emb)
+		item = ReportItem{ResourceType: ResourceTypeElastiCache, ElastiCache: workItem.ElastiCache}
+	}
+	if err != nil {
+		return ReportItem{}, err
+	}
+
+	item.Embedding = emb
+	item.Analysis = result.Text
+	item.ModelID = modelID
+	item.AnalysisUsage = result.Usage()
+	item.StructuredAnalysis = result.Structured
+	item.AnalysisQuality = result.Quality
+	item.ApplyAnalysisMetrics()
+	return item, nil
+}
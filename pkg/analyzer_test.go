@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+const testGenModel = "eu.anthropic.claude-3-7-sonnet-20250219-v1:0"
+
+// analyzerBedrock is a fake Bedrock whose embeddings fail for instances named in failing
+// and whose analyses of instances named in slow take a second
+func analyzerBedrock(failing, slow []string) *fakeBedrock {
+	// The embedding request carries the record as an escaped JSON string
+	mentions := func(text string, ids []string) bool {
+		text = strings.ReplaceAll(text, `\"`, `"`)
+		for _, id := range ids {
+			if strings.Contains(text, `"`+id+`"`) {
+				return true
+			}
+		}
+		return false
+	}
+	return &fakeBedrock{
+		invoke: func(modelID string, body []byte) ([]byte, error) {
+			if mentions(string(body), failing) {
+				return nil, errors.New("ThrottlingException")
+			}
+			return []byte(`{"embedding":[0.1,0.2]}`), nil
+		},
+		converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+			return converseText("Downsize the instance.", 100, 10), nil
+		},
+		delay: func(prompt string) time.Duration {
+			if mentions(prompt, slow) {
+				return time.Second
+			}
+			return 5 * time.Millisecond
+		},
+	}
+}
+
+// analyzerItems returns n EC2 work items for instances i-0 to i-(n-1)
+func analyzerItems(n int) []WorkItem {
+	items := make([]WorkItem, n)
+	for i := range items {
+		items[i] = WorkItem{ItemIndex: i, ItemType: "ec2", Instance: Instance{InstanceID: fmt.Sprintf("i-%d", i), InstanceType: "m5.large", Region: "eu-west-1"}}
+	}
+	return items
+}
+
+func TestAnalyzeResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       int
+		failing     []string
+		slow        []string
+		opts        AnalyzeOptions
+		wantReport  string
+		wantErrs    []int
+		wantTimeout bool
+	}{
+		{
+			name:       "all succeed",
+			items:      8,
+			opts:       AnalyzeOptions{Concurrency: 3},
+			wantReport: "i-0,i-1,i-2,i-3,i-4,i-5,i-6,i-7",
+		},
+		{
+			name:       "failing items",
+			items:      6,
+			failing:    []string{"i-4", "i-1"},
+			opts:       AnalyzeOptions{Concurrency: 2},
+			wantReport: "i-0,i-2,i-3,i-5",
+			wantErrs:   []int{1, 4},
+		},
+		{
+			name:        "slow item times out",
+			items:       4,
+			slow:        []string{"i-2"},
+			opts:        AnalyzeOptions{Concurrency: 4, ItemTimeout: 100 * time.Millisecond},
+			wantReport:  "i-0,i-1,i-3",
+			wantErrs:    []int{2},
+			wantTimeout: true,
+		},
+		{
+			name:       "default concurrency",
+			items:      12,
+			wantReport: "i-0,i-1,i-2,i-3,i-4,i-5,i-6,i-7,i-8,i-9,i-10,i-11",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bedrock := analyzerBedrock(tt.failing, tt.slow)
+			var progress []int
+			opts := tt.opts
+			opts.GenModel, opts.EmbedModel = testGenModel, "amazon.titan-embed-text-v2:0"
+			opts.Progress = func(done, total int) {
+				if total != tt.items {
+					t.Errorf("progress total = %d, want %d", total, tt.items)
+				}
+				progress = append(progress, done)
+			}
+
+			report, errs := AnalyzeResources(context.Background(), bedrock, analyzerItems(tt.items), opts)
+
+			if got := resultIDs(report); got != tt.wantReport {
+				t.Errorf("report = %q, want %q", got, tt.wantReport)
+			}
+			var errIndices []int
+			for _, itemErr := range errs {
+				errIndices = append(errIndices, itemErr.ItemIndex)
+				if itemErr.ResourceID != fmt.Sprintf("i-%d", itemErr.ItemIndex) {
+					t.Errorf("error of item %d names %s", itemErr.ItemIndex, itemErr.ResourceID)
+				}
+				if tt.wantTimeout && !errors.Is(itemErr.Err, context.DeadlineExceeded) {
+					t.Errorf("item %d error = %v, want a timeout", itemErr.ItemIndex, itemErr.Err)
+				}
+			}
+			if fmt.Sprint(errIndices) != fmt.Sprint(tt.wantErrs) {
+				t.Errorf("failed items = %v, want %v", errIndices, tt.wantErrs)
+			}
+
+			// Progress counts every item once, in order
+			if len(progress) != tt.items {
+				t.Fatalf("progress called %d times, want %d", len(progress), tt.items)
+			}
+			for i, done := range progress {
+				if done != i+1 {
+					t.Fatalf("progress = %v", progress)
+				}
+			}
+
+			limit := opts.Concurrency
+			if limit <= 0 {
+				limit = defaultAnalyzeConcurrency
+			}
+			if bedrock.maxInFlight > limit {
+				t.Errorf("%d analyses ran at once, want at most %d", bedrock.maxInFlight, limit)
+			}
+		})
+	}
+}
+
+// Cancelling the run fails the items that have not finished instead of analyzing them
+func TestAnalyzeResourcesCancelled(t *testing.T) {
+	bedrock := analyzerBedrock(nil, []string{"i-2"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var once sync.Once
+	opts := AnalyzeOptions{
+		GenModel:    testGenModel,
+		EmbedModel:  "amazon.titan-embed-text-v2:0",
+		Concurrency: 1,
+		Progress: func(done, total int) {
+			if done == 2 {
+				once.Do(cancel)
+			}
+		},
+	}
+
+	start := time.Now()
+	report, errs := AnalyzeResources(ctx, bedrock, analyzerItems(10), opts)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("AnalyzeResources() took %s after cancellation", elapsed)
+	}
+	if got := resultIDs(report); got != "i-0,i-1" {
+		t.Errorf("report = %q, want the items finished before cancelling", got)
+	}
+	if len(errs) != 8 {
+		t.Fatalf("got %d errors, want 8", len(errs))
+	}
+	for i, itemErr := range errs {
+		if itemErr.ItemIndex != i+2 || !errors.Is(itemErr.Err, context.Canceled) {
+			t.Errorf("error %d = %v, want item %d cancelled", i, itemErr, i+2)
+		}
+	}
+}
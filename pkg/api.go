@@ -0,0 +1,187 @@
+package pkg
+
+// Machine-readable codes of API error responses. Clients switch on these rather than on
+// the wording of the error message, which may change.
+const (
+	CodeInvalidPayload     = "invalid_payload"      // the body is not valid JSON
+	CodeNoResources        = "no_resources"         // the payload holds no resources
+	CodeInvalidResources   = "invalid_resources"    // some resources failed validation; see errors
+	CodeTooManyItems       = "too_many_items"       // more resources than MAX_ITEMS allows
+	CodeUnauthorized       = "unauthorized"         // missing or invalid API key
+	CodeForbidden          = "forbidden"            // the job belongs to another caller, or the key is not an admin key
+	CodeMissingJobID       = "missing_job_id"       // the path holds no job ID
+	CodeInvalidParameter   = "invalid_parameter"    // a query parameter has an invalid value
+	CodeJobNotFound        = "job_not_found"        // unknown or expired job
+	CodeRequestNotStored   = "request_not_stored"   // the job's request payload was not kept
+	CodeJobAlreadyFinished = "job_already_finished" // the job can no longer be cancelled
+	CodeJobNotRetryable    = "job_not_retryable"    // the job is unfinished, has no failed items or used up its retries
+	CodeJobExpired         = "job_expired"          // the job is past its TTL
+	CodeDLQNotConfigured   = "dlq_not_configured"   // the API has no DLQ_ARN to requeue from
+	CodeRequeueRunning     = "requeue_running"      // an earlier requeue of the dead-letter queue is unfinished
+	CodeInternal           = "internal_error"       // an AWS call or encoding failed
+)
+
+// APIError is the body of every error response
+type APIError struct {
+	Message  string       `json:"error"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`    // invalid fields, with CodeInvalidResources
+	MaxItems int          `json:"max_items,omitempty"` // the request limit, with CodeTooManyItems
+}
+
+// IncludeEmbeddingsParam is the query parameter of GET /jobs/{id} and GET /jobs/{id}/results
+// that asks for the results' embeddings. They are left out unless it is true, since they make
+// up most of the response.
+const IncludeEmbeddingsParam = "include_embeddings"
+
+// StripEmbeddings returns a copy of the report without embeddings, for responses and reports
+// that have no use for them
+func StripEmbeddings(report []ReportItem) []ReportItem {
+	if report == nil {
+		return nil
+	}
+	stripped := make([]ReportItem, len(report))
+	for i, item := range report {
+		item.Embedding = nil
+		stripped[i] = item
+	}
+	return stripped
+}
+
+// JobAccepted is the body of the 202 response to POST /analyze
+type JobAccepted struct {
+	JobID       string    `json:"job_id"`
+	Status      JobStatus `json:"status"`
+	TotalItems  int       `json:"total_items"`
+	CachedItems int       `json:"cached_items,omitempty"` // items answered from the analysis cache, already completed
+	ExpiresAt   int64     `json:"expires_at"`             // when the job and its results expire, in Unix seconds
+}
+
+// JobStatusResponse is the body of GET /jobs/{id}. Results are included once every item
+// has been processed; until then the response is a 202 with progress and item statuses only.
+type JobStatusResponse struct {
+	JobID          string    `json:"job_id"`
+	Status         JobStatus `json:"status"`
+	TotalItems     int       `json:"total_items"`
+	CompletedItems int       `json:"completed_items"`
+	FailedItems    int       `json:"failed_items"`
+	SkippedItems   int       `json:"skipped_items"`
+	RetryCount     int       `json:"retry_count,omitempty"` // times the failed items were queued again
+	ExpiresAt      int64     `json:"expires_at"`            // when the job and its results expire, in Unix seconds
+	AnalysisUsage            // Bedrock usage of the items completed so far
+	// Items is the status of each work item, without analyses, when the API tracks them
+	Items   []JobItemRecord `json:"items,omitempty"`
+	Results []ReportItem    `json:"results,omitempty"`
+	// AccountRecommendations is set once the worker has written them, just before the job completes
+	AccountRecommendations *AccountRecommendations `json:"account_recommendations,omitempty"`
+	// Metadata describes the scan the job analyzes, when the client sent it
+	Metadata *ReportMetadata `json:"metadata,omitempty"`
+}
+
+// Paging of GET /jobs/{id}/results and its NDJSON variant GET /jobs/{id}/results/stream: the
+// offset and limit query parameters select items offset to offset+limit-1 of the job
+const (
+	ResultsOffsetParam     = "offset"
+	ResultsLimitParam      = "limit"
+	DefaultResultsPageSize = 100 // the limit when only an offset is given
+	MaxResultsPageSize     = 500
+)
+
+// JobResultsResponse is the body of GET /jobs/{id}/results. A paged request also gets the
+// page's position and the job's total item count; a page holds fewer results than its limit
+// where items failed or are still pending.
+type JobResultsResponse struct {
+	Results    []ReportItem `json:"results"`
+	Offset     int          `json:"offset,omitempty"`
+	Limit      int          `json:"limit,omitempty"`
+	TotalItems int          `json:"total_items,omitempty"`
+}
+
+// Query parameters of GET /jobs, which lists the caller's jobs a page at a time
+const (
+	JobsStatusParam     = "status"     // only jobs in this status
+	JobsLimitParam      = "limit"      // jobs per page
+	JobsNextTokenParam  = "next_token" // the next_token of the previous page
+	DefaultJobsPageSize = 20
+	MaxJobsPageSize     = 100
+)
+
+// JobSummary describes a job in GET /jobs, without its results. Times are Unix seconds.
+type JobSummary struct {
+	JobID          string    `json:"job_id"`
+	Status         JobStatus `json:"status"`
+	CreatedAt      int64     `json:"created_at"`
+	UpdatedAt      int64     `json:"updated_at"`
+	CompletedAt    int64     `json:"completed_at,omitempty"`
+	TotalItems     int       `json:"total_items"`
+	CompletedItems int       `json:"completed_items"`
+	FailedItems    int       `json:"failed_items"`
+	SkippedItems   int       `json:"skipped_items"`
+	ResourceTypes  []string  `json:"resource_types"`
+}
+
+// JobListResponse is the body of GET /jobs. Each page is sorted newest first; NextToken is set
+// while more jobs remain, even when this page holds fewer than the limit.
+type JobListResponse struct {
+	Jobs      []JobSummary `json:"jobs"`
+	NextToken string       `json:"next_token,omitempty"`
+}
+
+// NewJobSummary describes a job for GET /jobs
+func NewJobSummary(job *JobInfo) JobSummary {
+	resourceTypes := job.ResourceTypes
+	if resourceTypes == nil {
+		resourceTypes = []string{}
+	}
+	return JobSummary{
+		JobID:          job.JobID,
+		Status:         job.Status,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+		CompletedAt:    job.CompletedAt,
+		TotalItems:     job.TotalItems,
+		CompletedItems: job.CompletedItems,
+		FailedItems:    job.FailedItems,
+		SkippedItems:   job.SkippedItems,
+		ResourceTypes:  resourceTypes,
+	}
+}
+
+// JobRetryResponse is the body of the 202 response to POST /jobs/{id}/retry
+type JobRetryResponse struct {
+	JobID        string    `json:"job_id"`
+	Status       JobStatus `json:"status"`
+	RetriedItems int       `json:"retried_items"`
+	RetryCount   int       `json:"retry_count"`
+	MaxRetries   int       `json:"max_retries"`
+}
+
+// DLQRequeueResponse is the body of the 202 response to POST /admin/dlq/requeue. SQS moves
+// the messages in the background, under TaskHandle.
+type DLQRequeueResponse struct {
+	SourceARN  string `json:"source_arn"`
+	TaskHandle string `json:"task_handle"`
+}
+
+// JobCancelResponse is the body of DELETE /jobs/{id}
+type JobCancelResponse struct {
+	JobID  string    `json:"job_id"`
+	Status JobStatus `json:"status"`
+}
+
+// NewJobStatusResponse reports the progress of a job, without its results
+func NewJobStatusResponse(job *JobInfo) JobStatusResponse {
+	return JobStatusResponse{
+		JobID:                  job.JobID,
+		Status:                 job.Status,
+		TotalItems:             job.TotalItems,
+		CompletedItems:         job.CompletedItems,
+		FailedItems:            job.FailedItems,
+		SkippedItems:           job.SkippedItems,
+		RetryCount:             job.RetryCount,
+		ExpiresAt:              job.ExpirationTime,
+		AnalysisUsage:          job.AnalysisUsage,
+		AccountRecommendations: job.AccountRecommendations,
+		Metadata:               job.Metadata,
+	}
+}
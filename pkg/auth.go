@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyHeader carries the API key on requests to the GreenOps API
+const APIKeyHeader = "x-api-key"
+
+// ParseAPIKeys splits a comma-separated list of API keys, dropping empty entries.
+// An empty list leaves the API open, as it was before keys were supported.
+func ParseAPIKeys(s string) []string {
+	var keys []string
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ValidAPIKey reports whether presented matches one of keys. Every key is compared in
+// constant time so response timing does not reveal how much of a key was right.
+func ValidAPIKey(keys []string, presented string) bool {
+	valid := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(presented)) == 1 {
+			valid = true
+		}
+	}
+	return valid && presented != ""
+}
+
+// CallerIdentity derives the owner recorded on a job from the caller's API key. Only a
+// hash is kept, so the jobs table never holds a usable key. It is empty without a key.
+func CallerIdentity(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(sum[:8])
+}
+
+// HeaderValue looks up a request header by name, ignoring case. API Gateway lowercases
+// header names for HTTP APIs, but other callers of the handler may not.
+func HeaderValue(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// AnalysisUsage is the Bedrock usage of generating analyses: the tokens of the prompts and
+// completions, and what they cost at the prices in bedrockModelPrices. Report items carry
+// the usage of their own analysis, and jobs and report summaries the total of their items.
+type AnalysisUsage struct {
+	PromptTokens     int     `json:"prompt_tokens,omitempty" dynamodbav:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty" dynamodbav:"completion_tokens,omitempty"`
+	AnalysisCostUSD  float64 `json:"analysis_cost_usd,omitempty" dynamodbav:"analysis_cost_usd,omitempty"`
+}
+
+// IsZero reports whether no tokens were recorded
+func (u AnalysisUsage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0
+}
+
+// Add adds the usage of another analysis
+func (u *AnalysisUsage) Add(other AnalysisUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.AnalysisCostUSD += other.AnalysisCostUSD
+}
+
+// bedrockModelPrices are on-demand prices in USD per 1,000 input and output tokens. They
+// are matched in order against model IDs and inference profile ARNs, so more specific
+// names come first. Models missing here are recorded with their tokens but no cost.
+var bedrockModelPrices = []struct {
+	match         string
+	input, output float64
+}{
+	{"claude-opus-4", 0.015, 0.075},
+	{"claude-sonnet-4", 0.003, 0.015},
+	{"claude-3-7-sonnet", 0.003, 0.015},
+	{"claude-3-5-sonnet", 0.003, 0.015},
+	{"claude-3-5-haiku", 0.0008, 0.004},
+	{"claude-3-opus", 0.015, 0.075},
+	{"claude-3-sonnet", 0.003, 0.015},
+	{"claude-3-haiku", 0.00025, 0.00125},
+	{"titan-text-premier", 0.0005, 0.0015},
+	{"titan-text-express", 0.0002, 0.0006},
+	{"titan-text-lite", 0.00015, 0.0002},
+}
+
+// BedrockCostUSD returns what a call to modelID with the given token counts costs on
+// demand, or 0 for a model without a known price
+func BedrockCostUSD(modelID string, inputTokens, outputTokens int) float64 {
+	for _, price := range bedrockModelPrices {
+		if strings.Contains(modelID, price.match) {
+			return float64(inputTokens)/1000*price.input + float64(outputTokens)/1000*price.output
+		}
+	}
+	Debugf("No token price known for %s, recording its usage without a cost", modelID)
+	return 0
+}
+
+// estimateTokens approximates the tokens of text for models whose responses do not count
+// them, at the usual four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// extractUsageFromResponse returns the input and output token counts reported in an
+// InvokeModel response, in the shapes extractTextFromResponse handles: Claude's usage object
+// and Titan's inputTextTokenCount with a tokenCount per result. ok is false when the
+// response does not report them.
+func extractUsageFromResponse(responseData []byte) (inputTokens, outputTokens int, ok bool) {
+	type claudeUsage struct {
+		InputTokens  *int `json:"input_tokens"`
+		OutputTokens *int `json:"output_tokens"`
+	}
+	var resp struct {
+		Usage               *claudeUsage `json:"usage"`
+		InputTextTokenCount *int         `json:"inputTextTokenCount"`
+		Results             []struct {
+			TokenCount *int `json:"tokenCount"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(responseData, &resp); err != nil {
+		return 0, 0, false
+	}
+
+	if usage := resp.Usage; usage != nil && usage.InputTokens != nil && usage.OutputTokens != nil {
+		return *usage.InputTokens, *usage.OutputTokens, true
+	}
+
+	if resp.InputTextTokenCount != nil && len(resp.Results) > 0 {
+		for _, result := range resp.Results {
+			if result.TokenCount == nil {
+				return 0, 0, false
+			}
+			outputTokens += *result.TokenCount
+		}
+		return *resp.InputTextTokenCount, outputTokens, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestExtractUsageFromResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantInput  int
+		wantOutput int
+		wantOK     bool
+	}{
+		{
+			name:       "claude",
+			response:   `{"content":[{"type":"text","text":"Use gp3"}],"usage":{"input_tokens":812,"output_tokens":164}}`,
+			wantInput:  812,
+			wantOutput: 164,
+			wantOK:     true,
+		},
+		{
+			name:      "claude with zero completion tokens",
+			response:  `{"content":[],"usage":{"input_tokens":40,"output_tokens":0}}`,
+			wantInput: 40,
+			wantOK:    true,
+		},
+		{
+			name:     "claude without output tokens",
+			response: `{"content":[{"type":"text","text":"Use gp3"}],"usage":{"input_tokens":812}}`,
+		},
+		{
+			name:       "titan",
+			response:   `{"inputTextTokenCount":96,"results":[{"tokenCount":41,"outputText":"Stop it","completionReason":"FINISH"}]}`,
+			wantInput:  96,
+			wantOutput: 41,
+			wantOK:     true,
+		},
+		{
+			name:       "titan with several results",
+			response:   `{"inputTextTokenCount":96,"results":[{"tokenCount":41},{"tokenCount":9}]}`,
+			wantInput:  96,
+			wantOutput: 50,
+			wantOK:     true,
+		},
+		{
+			name:     "titan result without a token count",
+			response: `{"inputTextTokenCount":96,"results":[{"tokenCount":41},{"outputText":"more"}]}`,
+		},
+		{
+			name:     "titan without results",
+			response: `{"inputTextTokenCount":96,"results":[]}`,
+		},
+		{
+			name:     "no usage",
+			response: `{"completion":"Use gp3"}`,
+		},
+		{
+			name:     "not JSON",
+			response: `Use gp3`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, output, ok := extractUsageFromResponse([]byte(tt.response))
+			if input != tt.wantInput || output != tt.wantOutput || ok != tt.wantOK {
+				t.Errorf("extractUsageFromResponse() = %d, %d, %v, want %d, %d, %v", input, output, ok, tt.wantInput, tt.wantOutput, tt.wantOK)
+			}
+		})
+	}
+}
+
+// Responses that do not count their tokens are estimated from the prompt and completion
+func TestLegacyInvokerUsage(t *testing.T) {
+	const prompt = "Analyze this instance: m5.xlarge at 3% CPU"
+
+	tests := []struct {
+		name          string
+		modelID       string
+		response      string
+		wantTokens    [2]int
+		wantEstimated bool
+	}{
+		{
+			name:       "claude counts",
+			modelID:    "anthropic.claude-3-haiku-20240307-v1:0",
+			response:   `{"content":[{"type":"text","text":"Downsize"}],"usage":{"input_tokens":30,"output_tokens":2}}`,
+			wantTokens: [2]int{30, 2},
+		},
+		{
+			name:       "titan counts",
+			modelID:    "amazon.titan-text-express-v1",
+			response:   `{"inputTextTokenCount":14,"results":[{"tokenCount":3,"outputText":"Downsize it"}]}`,
+			wantTokens: [2]int{14, 3},
+		},
+		{
+			name:          "titan estimated",
+			modelID:       "amazon.titan-text-lite-v1",
+			response:      `{"results":[{"outputText":"Downsize it"}]}`,
+			wantTokens:    [2]int{estimateTokens(prompt), estimateTokens("Downsize it")},
+			wantEstimated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bedrock := &fakeBedrock{invoke: func(modelID string, body []byte) ([]byte, error) {
+				return []byte(tt.response), nil
+			}}
+
+			result, err := LegacyInvoker{Client: bedrock}.Invoke(context.Background(), tt.modelID, prompt)
+			if err != nil {
+				t.Fatalf("Invoke() error = %v", err)
+			}
+			if got := [2]int{result.InputTokens, result.OutputTokens}; got != tt.wantTokens {
+				t.Errorf("tokens = %v, want %v", got, tt.wantTokens)
+			}
+			if result.Estimated != tt.wantEstimated {
+				t.Errorf("Estimated = %v, want %v", result.Estimated, tt.wantEstimated)
+			}
+		})
+	}
+}
+
+func TestBedrockCostUSD(t *testing.T) {
+	tests := []struct {
+		modelID string
+		want    float64
+	}{
+		{modelID: "anthropic.claude-3-haiku-20240307-v1:0", want: 0.00025 + 0.00125},
+		{modelID: "anthropic.claude-3-5-haiku-20241022-v1:0", want: 0.0008 + 0.004},
+		{modelID: "anthropic.claude-opus-4-20250514-v1:0", want: 0.015 + 0.075},
+		{modelID: "arn:aws:bedrock:eu-west-1:123456789012:inference-profile/eu.anthropic.claude-3-7-sonnet-20250219-v1:0", want: 0.003 + 0.015},
+		{modelID: "amazon.titan-text-express-v1", want: 0.0002 + 0.0006},
+		{modelID: "amazon.titan-text-premier-v1:0", want: 0.0005 + 0.0015},
+		{modelID: "meta.llama3-70b-instruct-v1:0", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modelID, func(t *testing.T) {
+			if got := BedrockCostUSD(tt.modelID, 1000, 1000); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("BedrockCostUSD(%q, 1000, 1000) = %v, want %v", tt.modelID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalysisUsageAdd(t *testing.T) {
+	var total AnalysisUsage
+	if !total.IsZero() {
+		t.Fatal("IsZero() = false for no usage")
+	}
+	total.Add(InvokeResult{ModelID: "anthropic.claude-3-haiku-20240307-v1:0", InputTokens: 2000, OutputTokens: 400}.Usage())
+	total.Add(InvokeResult{ModelID: "amazon.titan-text-express-v1", InputTokens: 1000, OutputTokens: 1000}.Usage())
+
+	want := AnalysisUsage{PromptTokens: 3000, CompletionTokens: 1400, AnalysisCostUSD: 2*0.00025 + 0.4*0.00125 + 0.0002 + 0.0006}
+	if total.PromptTokens != want.PromptTokens || total.CompletionTokens != want.CompletionTokens || math.Abs(total.AnalysisCostUSD-want.AnalysisCostUSD) > 1e-12 {
+		t.Errorf("total usage = %+v, want %+v", total, want)
+	}
+}
@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultCacheTTLDays is how long a cached analysis is reused unless CACHE_TTL_DAYS says otherwise
+const DefaultCacheTTLDays = 7
+
+// cacheLookupConcurrency bounds the cache lookups the API runs at once for one request
+const cacheLookupConcurrency = 10
+
+// CacheTTL returns how long cached analyses are reused: CACHE_TTL_DAYS, or DefaultCacheTTLDays
+// when it is unset or invalid. A TTL of 0 turns the cache off.
+func CacheTTL() time.Duration {
+	days := DefaultCacheTTLDays
+	if value := os.Getenv("CACHE_TTL_DAYS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			days = n
+		} else {
+			Warnf("Ignoring invalid CACHE_TTL_DAYS %q, using %d days", value, DefaultCacheTTLDays)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// AnalysisCacheEnabled reports whether analyses are cached: CACHE_TABLE names the cache table
+// and CACHE_TTL_DAYS is not 0
+func AnalysisCacheEnabled() bool {
+	return os.Getenv("CACHE_TABLE") != "" && CacheTTL() > 0
+}
+
+// AnalysisCacheRecord is one cached analysis in the cache table, keyed by the fingerprint of
+// the resource and model it was produced for
+type AnalysisCacheRecord struct {
+	Fingerprint    string     `dynamodbav:"fingerprint"`
+	Result         ReportItem `dynamodbav:"result"`
+	CreatedAt      int64      `dynamodbav:"created_at"`
+	ExpirationTime int64      `dynamodbav:"expiration_time"`
+}
+
+// ResourceFingerprint identifies the resource a work item carries together with the model
+// analyzing it: the SHA-256 of the resource's canonical JSON followed by the model ID. The
+// same resource analyzed by the same model has the same fingerprint whichever job, or
+// position within it, it comes from. Analyses generated in AnalysisFormatJSON are kept apart
+// from markdown ones by appending the format.
+func ResourceFingerprint(workItem WorkItem, modelID string) (string, error) {
+	workItem.JobID, workItem.ItemIndex, workItem.Fingerprint, workItem.ExpiresAt = "", 0, "", 0
+	workItem.Model = "" // only modelID counts, whether the request picked it or not
+	data, err := canonicalJSON(workItem)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint %s %s: %w", workItem.ItemType, workItem.ResourceID(), err)
+	}
+
+	hash := sha256.New()
+	hash.Write(data)
+	hash.Write([]byte{0})
+	hash.Write([]byte(modelID))
+	if AnalysisFormat() == AnalysisFormatJSON {
+		hash.Write([]byte{0})
+		hash.Write([]byte(AnalysisFormatJSON))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// canonicalJSON encodes v with object keys sorted at every level, so equal values always
+// produce the same bytes
+func canonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Decoding into interface{} turns every object into a map, which json.Marshal writes in key order
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// GetCachedAnalysis returns the cached analysis for a fingerprint if one younger than CacheTTL exists
+func GetCachedAnalysis(ctx context.Context, dynamoClient DynamoJobStore, fingerprint string) (ReportItem, bool, error) {
+	out, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("CACHE_TABLE")),
+		Key:       map[string]types.AttributeValue{"fingerprint": &types.AttributeValueMemberS{Value: fingerprint}},
+	})
+	if err != nil {
+		return ReportItem{}, false, fmt.Errorf("failed to look up cached analysis %s: %w", fingerprint, err)
+	}
+	if out.Item == nil {
+		return ReportItem{}, false, nil
+	}
+
+	var record AnalysisCacheRecord
+	if err := attributevalue.UnmarshalMap(out.Item, &record); err != nil {
+		return ReportItem{}, false, fmt.Errorf("failed to unmarshal cached analysis %s: %w", fingerprint, err)
+	}
+	// DynamoDB deletes expired items lazily, and CACHE_TTL_DAYS may have been lowered since the entry was written
+	if time.Since(time.Unix(record.CreatedAt, 0)) >= CacheTTL() {
+		return ReportItem{}, false, nil
+	}
+	return record.Result, true, nil
+}
+
+// PutCachedAnalysis stores an analysis under its fingerprint, to be reused for CacheTTL
+func PutCachedAnalysis(ctx context.Context, dynamoClient DynamoJobStore, fingerprint string, result ReportItem) error {
+	now := time.Now()
+	result.Cached = false
+	record := AnalysisCacheRecord{
+		Fingerprint:    fingerprint,
+		Result:         result,
+		CreatedAt:      now.Unix(),
+		ExpirationTime: now.Add(CacheTTL()).Unix(),
+	}
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached analysis: %w", err)
+	}
+
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("CACHE_TABLE")),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache analysis %s: %w", fingerprint, err)
+	}
+	return nil
+}
+
+// ResolveCachedWorkItems fingerprints every work item, with the model that would analyze it,
+// for the analysis cache and records the items with a fresh cached analysis as completed
+// results of their job, so the job's total still counts them. It returns the items that
+// need to be queued for the worker and how many were answered from the cache. With bypass
+// set the cache is not read, but the items still carry their fingerprints so the worker
+// replaces the cached entries with fresh analyses. Items whose lookup fails are queued as
+// if they had missed.
+func ResolveCachedWorkItems(ctx context.Context, dynamoClient DynamoJobStore, workItems []WorkItem, bypass bool) ([]WorkItem, int) {
+	for i := range workItems {
+		fingerprint, err := ResourceFingerprint(workItems[i], workItems[i].GenerationModel())
+		if err != nil {
+			Warnf("%v", err)
+			continue
+		}
+		workItems[i].Fingerprint = fingerprint
+	}
+	if bypass {
+		Infof("Analysis cache bypassed at the caller's request")
+		return workItems, 0
+	}
+
+	hits := make([]bool, len(workItems))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cacheLookupConcurrency)
+	for i, workItem := range workItems {
+		if workItem.Fingerprint == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, workItem WorkItem) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, found, err := GetCachedAnalysis(ctx, dynamoClient, workItem.Fingerprint)
+			if err != nil {
+				Warnf("%v", err)
+				return
+			}
+			if !found {
+				return
+			}
+
+			result.Fingerprint = workItem.Fingerprint
+			result.Cached = true
+			result.AnalysisUsage = AnalysisUsage{} // nothing was spent on Bedrock for this job
+			if err := storeJobResult(ctx, dynamoClient, workItem, result); err != nil {
+				Warnf("Failed to record cached analysis for item %d of job %s, queueing it instead: %v", workItem.ItemIndex, workItem.JobID, err)
+				return
+			}
+			hits[i] = true
+			if err := UpdateJobItem(ctx, dynamoClient, workItem, ItemCompleted, "", 0); err != nil {
+				Warnf("%v", err)
+			}
+		}(i, workItem)
+	}
+	wg.Wait()
+
+	pending := make([]WorkItem, 0, len(workItems))
+	for i, workItem := range workItems {
+		if !hits[i] {
+			pending = append(pending, workItem)
+		}
+	}
+	if cached := len(workItems) - len(pending); cached > 0 {
+		Infof("Answered %d of %d work items from the analysis cache", cached, len(workItems))
+	}
+	return pending, len(workItems) - len(pending)
+}
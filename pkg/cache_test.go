@@ -0,0 +1,222 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// useCacheTable turns the analysis cache on, on top of useJobTables
+func useCacheTable(t *testing.T) {
+	t.Helper()
+	useJobTables(t)
+	t.Setenv("CACHE_TABLE", testCacheTable)
+	t.Setenv("CACHE_TTL_DAYS", "")
+}
+
+// cacheKey is the key of a fingerprint in the cache table
+func cacheKey(fingerprint string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"fingerprint": &types.AttributeValueMemberS{Value: fingerprint}}
+}
+
+// fingerprintOf fingerprints a work item with its generation model and fails the test on error
+func fingerprintOf(t *testing.T, workItem WorkItem) string {
+	t.Helper()
+	fingerprint, err := ResourceFingerprint(workItem, workItem.GenerationModel())
+	if err != nil {
+		t.Fatalf("ResourceFingerprint() error = %v", err)
+	}
+	return fingerprint
+}
+
+// cachedResult is the analysis stored in the cache for a work item
+func cachedResult(workItem WorkItem) ReportItem {
+	return ReportItem{
+		ResourceType:  ResourceTypeEC2,
+		Instance:      workItem.Instance,
+		Analysis:      "cached analysis of " + workItem.Instance.InstanceID,
+		AnalysisUsage: AnalysisUsage{PromptTokens: 500, CompletionTokens: 50, AnalysisCostUSD: 0.01},
+	}
+}
+
+func TestPutCachedAnalysis(t *testing.T) {
+	useCacheTable(t)
+	t.Setenv("CACHE_TTL_DAYS", "3")
+	dynamo := newFakeDynamo()
+	result := ReportItem{ResourceType: ResourceTypeEC2, Analysis: "Downsize", Cached: true}
+
+	before := time.Now().Unix()
+	if err := PutCachedAnalysis(context.Background(), dynamo, "fp-1", result); err != nil {
+		t.Fatalf("PutCachedAnalysis() error = %v", err)
+	}
+
+	var record AnalysisCacheRecord
+	if err := attributevalue.UnmarshalMap(dynamo.item(testCacheTable, cacheKey("fp-1")), &record); err != nil {
+		t.Fatalf("stored record does not unmarshal: %v", err)
+	}
+	if record.Result.Cached {
+		t.Error("the stored result is marked cached")
+	}
+	if record.CreatedAt < before || record.ExpirationTime-record.CreatedAt != 3*24*60*60 {
+		t.Errorf("created %d, expires %d; want expiry 3 days after creation", record.CreatedAt, record.ExpirationTime)
+	}
+
+	got, found, err := GetCachedAnalysis(context.Background(), dynamo, "fp-1")
+	if err != nil || !found {
+		t.Fatalf("GetCachedAnalysis() = %v, %v; want the stored analysis", found, err)
+	}
+	if got.Analysis != "Downsize" {
+		t.Errorf("GetCachedAnalysis() analysis = %q, want Downsize", got.Analysis)
+	}
+
+	putErr := errors.New("ProvisionedThroughputExceeded")
+	dynamo.fail("PutItem", putErr)
+	if err := PutCachedAnalysis(context.Background(), dynamo, "fp-2", result); !errors.Is(err, putErr) {
+		t.Errorf("PutCachedAnalysis() error = %v, want %v", err, putErr)
+	}
+}
+
+func TestGetCachedAnalysis(t *testing.T) {
+	tests := []struct {
+		name      string
+		age       time.Duration
+		ttlDays   string
+		wantFound bool
+	}{
+		{name: "fresh", age: time.Hour, wantFound: true},
+		{name: "older than the default TTL", age: 8 * 24 * time.Hour},
+		{name: "older than a lowered TTL", age: 2 * 24 * time.Hour, ttlDays: "1"},
+		{name: "within a raised TTL", age: 20 * 24 * time.Hour, ttlDays: "30", wantFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCacheTable(t)
+			t.Setenv("CACHE_TTL_DAYS", tt.ttlDays)
+			dynamo := newFakeDynamo()
+			item, err := attributevalue.MarshalMap(AnalysisCacheRecord{
+				Fingerprint: "fp-1",
+				Result:      ReportItem{Analysis: "Downsize"},
+				CreatedAt:   time.Now().Add(-tt.age).Unix(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dynamo.put(testCacheTable, item); err != nil {
+				t.Fatal(err)
+			}
+
+			_, found, err := GetCachedAnalysis(context.Background(), dynamo, "fp-1")
+			if err != nil {
+				t.Fatalf("GetCachedAnalysis() error = %v", err)
+			}
+			if found != tt.wantFound {
+				t.Errorf("GetCachedAnalysis() found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestResolveCachedWorkItems(t *testing.T) {
+	lookupErr := errors.New("ThrottlingException")
+
+	tests := []struct {
+		name       string
+		cached     []int // items with a fresh analysis in the cache
+		bypass     bool
+		failLookup []int // items whose cache lookup fails
+		failRecord bool  // recording the cached result in the job fails
+		wantQueued []int
+		wantGets   int
+	}{
+		{name: "all miss", wantQueued: []int{0, 1, 2}, wantGets: 3},
+		{name: "some hit", cached: []int{0, 2}, wantQueued: []int{1}, wantGets: 3},
+		{name: "all hit", cached: []int{0, 1, 2}, wantQueued: []int{}, wantGets: 3},
+		{name: "bypass", cached: []int{0, 1, 2}, bypass: true, wantQueued: []int{0, 1, 2}},
+		{name: "failed lookup is queued", cached: []int{0, 1, 2}, failLookup: []int{1}, wantQueued: []int{1}, wantGets: 3},
+		{name: "failed recording is queued", cached: []int{0}, failRecord: true, wantQueued: []int{0, 1, 2}, wantGets: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCacheTable(t)
+			dynamo := newFakeDynamo()
+			job := createTestJob(t, dynamo, 3)
+			workItems := testWorkItems(3)
+			for i := range workItems {
+				workItems[i].JobID = job.JobID
+			}
+
+			fingerprints := make(map[string]int)
+			for i, workItem := range workItems {
+				fingerprints[fingerprintOf(t, workItem)] = i
+			}
+			for _, i := range tt.cached {
+				if err := PutCachedAnalysis(context.Background(), dynamo, fingerprintOf(t, workItems[i]), cachedResult(workItems[i])); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// failGet runs under the fake's lock, so it can count the lookups
+			lookups := 0
+			dynamo.failGet = func(table string, key map[string]types.AttributeValue) error {
+				if table != testCacheTable {
+					return nil
+				}
+				lookups++
+				i := fingerprints[key["fingerprint"].(*types.AttributeValueMemberS).Value]
+				for _, failing := range tt.failLookup {
+					if i == failing {
+						return lookupErr
+					}
+				}
+				return nil
+			}
+			if tt.failRecord {
+				dynamo.fail("UpdateItem "+testJobsTable, errors.New("ConditionalCheckFailedException"))
+			}
+
+			queued, hits := ResolveCachedWorkItems(context.Background(), dynamo, workItems, tt.bypass)
+
+			var queuedIdx []int
+			for _, workItem := range queued {
+				queuedIdx = append(queuedIdx, workItem.ItemIndex)
+				if workItem.Fingerprint == "" {
+					t.Errorf("queued item %d has no fingerprint for the worker to cache its analysis under", workItem.ItemIndex)
+				}
+			}
+			if !slices.Equal(queuedIdx, tt.wantQueued) {
+				t.Errorf("queued items %v, want %v", queuedIdx, tt.wantQueued)
+			}
+			if hits != 3-len(tt.wantQueued) {
+				t.Errorf("ResolveCachedWorkItems() hits = %d, want %d", hits, 3-len(tt.wantQueued))
+			}
+			if lookups != tt.wantGets {
+				t.Errorf("cache lookups = %d, want %d", lookups, tt.wantGets)
+			}
+
+			got, err := GetJob(context.Background(), dynamo, job.JobID)
+			if err != nil {
+				t.Fatalf("GetJob() error = %v", err)
+			}
+			if got.CompletedItems != hits || len(got.Results) != hits {
+				t.Errorf("job has %d completed items and %d results, want %d of each", got.CompletedItems, len(got.Results), hits)
+			}
+			for _, result := range got.Results {
+				if !result.Cached || result.Fingerprint == "" {
+					t.Errorf("result %s is not marked as a cache hit: cached %v, fingerprint %q", result.Instance.InstanceID, result.Cached, result.Fingerprint)
+				}
+				if !result.AnalysisUsage.IsZero() || result.AnalysisCostUSD != 0 {
+					t.Errorf("cached result %s carries usage %+v, want none", result.Instance.InstanceID, result.AnalysisUsage)
+				}
+			}
+			if got.PromptTokens != 0 {
+				t.Errorf("job PromptTokens = %d, want 0 for cached results", got.PromptTokens)
+			}
+		})
+	}
+}
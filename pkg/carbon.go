@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// regionGridIntensity is the carbon intensity of the electricity grid behind each AWS region,
+// in grams CO2e per kWh. Figures follow the Cloud Carbon Footprint methodology.
+var regionGridIntensity = map[string]float64{
+	"us-east-1":      379.069,
+	"us-east-2":      410.608,
+	"us-west-1":      322.167,
+	"us-west-2":      322.167,
+	"us-gov-east-1":  379.069,
+	"us-gov-west-1":  322.167,
+	"af-south-1":     900.6,
+	"ap-east-1":      710,
+	"ap-south-1":     708,
+	"ap-northeast-1": 465.8,
+	"ap-northeast-2": 415.6,
+	"ap-northeast-3": 465.8,
+	"ap-southeast-1": 408,
+	"ap-southeast-2": 790,
+	"ca-central-1":   130,
+	"cn-north-1":     555.7,
+	"cn-northwest-1": 555.7,
+	"eu-central-1":   338,
+	"eu-west-1":      278.6,
+	"eu-west-2":      225,
+	"eu-west-3":      51.1,
+	"eu-south-1":     233,
+	"eu-north-1":     8.8,
+	"me-south-1":     505.9,
+	"sa-east-1":      61.7,
+}
+
+// defaultGridIntensity is the global average used for regions missing from the table
+const defaultGridIntensity = 475.0
+
+// dataCenterPUE is the power usage effectiveness applied on top of the server draw
+const dataCenterPUE = 1.135
+
+// processorPower is the power draw of one vCPU at idle and at full load, in watts
+type processorPower struct {
+	MinWatts float64
+	MaxWatts float64
+}
+
+// processorPowerPerVCPU holds the per-vCPU draw of each processor class
+var processorPowerPerVCPU = map[string]processorPower{
+	"graviton":     {MinWatts: 0.47, MaxWatts: 1.69},
+	"amd":          {MinWatts: 0.47, MaxWatts: 1.64},
+	"intel":        {MinWatts: 0.64, MaxWatts: 3.97},
+	"intel-legacy": {MinWatts: 0.71, MaxWatts: 3.69},
+}
+
+// legacyInstanceFamilies run on Broadwell or older Intel processors
+var legacyInstanceFamilies = map[string]bool{
+	"t2": true, "m3": true, "m4": true, "c3": true, "c4": true,
+	"r3": true, "r4": true, "i2": true, "i3": true, "d2": true,
+	"x1": true, "x1e": true, "p2": true, "p3": true, "g3": true,
+}
+
+// smallInstanceVCPUs holds the vCPU count of the sizes below xlarge
+var smallInstanceVCPUs = map[string]int{
+	"nano":   2,
+	"micro":  2,
+	"small":  2,
+	"medium": 2,
+	"large":  2,
+}
+
+const (
+	defaultInstanceVCPUs = 2
+	metalInstanceVCPUs   = 96
+)
+
+// CarbonBreakdown explains how a CO2 estimate was derived
+// - WattsPerVCPU: draw of one vCPU at the observed utilization
+// - EnergyKWhMonthly: monthly energy use including data centre overhead (PUE)
+// - GridIntensity: grams CO2e per kWh for the region
+// - RegionKnown: false when the region is missing from the table and the default was used
+type CarbonBreakdown struct {
+	InstanceType     string
+	Region           string
+	VCPUs            int
+	Processor        string
+	CPUUtilization   float64
+	WattsPerVCPU     float64
+	PUE              float64
+	EnergyKWhMonthly float64
+	GridIntensity    float64
+	RegionKnown      bool
+}
+
+// String summarizes the breakdown in one line for prompts and reports
+func (b CarbonBreakdown) String() string {
+	region := b.Region
+	if !b.RegionKnown {
+		region += " (unknown region, global average used)"
+	}
+	return fmt.Sprintf("%d vCPUs (%s) at %.1f%% CPU drawing %.2f W each, PUE %.3f, %.2f kWh per month at %.1f g CO2e/kWh in %s",
+		b.VCPUs, b.Processor, b.CPUUtilization, b.WattsPerVCPU, b.PUE, b.EnergyKWhMonthly, b.GridIntensity, region)
+}
+
+// GridIntensity returns the grid carbon intensity of a region in g CO2e/kWh and whether
+// the region is in the table
+func GridIntensity(region string) (float64, bool) {
+	if intensity, ok := regionGridIntensity[region]; ok {
+		return intensity, true
+	}
+	return defaultGridIntensity, false
+}
+
+// EstimateEC2CO2 estimates the monthly operational CO2 footprint of an EC2 instance from its
+// vCPU count, processor class, average CPU utilization and the grid intensity of its region
+func EstimateEC2CO2(instanceType, region string, cpuAvg float64) (kgPerMonth float64, breakdown CarbonBreakdown) {
+	cpuAvg = min(max(cpuAvg, 0), 100)
+	processor := instanceProcessor(instanceType)
+	power := processorPowerPerVCPU[processor]
+	intensity, known := GridIntensity(region)
+
+	breakdown = CarbonBreakdown{
+		InstanceType:   instanceType,
+		Region:         region,
+		VCPUs:          instanceVCPUs(instanceType),
+		Processor:      processor,
+		CPUUtilization: cpuAvg,
+		WattsPerVCPU:   power.MinWatts + cpuAvg/100*(power.MaxWatts-power.MinWatts),
+		PUE:            dataCenterPUE,
+		GridIntensity:  intensity,
+		RegionKnown:    known,
+	}
+	breakdown.EnergyKWhMonthly = breakdown.WattsPerVCPU * float64(breakdown.VCPUs) * hoursPerMonth / 1000 * dataCenterPUE
+
+	return breakdown.EnergyKWhMonthly * intensity / 1000, breakdown
+}
+
+// splitInstanceType splits an instance type such as "m6g.xlarge" into family and size
+func splitInstanceType(instanceType string) (string, string) {
+	family, size, _ := strings.Cut(strings.ToLower(instanceType), ".")
+	return family, size
+}
+
+// instanceVCPUs derives the vCPU count from the instance size
+func instanceVCPUs(instanceType string) int {
+	family, size := splitInstanceType(instanceType)
+
+	// Burstable t2 sizes below medium have a single vCPU
+	if family == "t2" && (size == "nano" || size == "micro" || size == "small") {
+		return 1
+	}
+	if vcpus, ok := smallInstanceVCPUs[size]; ok {
+		return vcpus
+	}
+	if size == "xlarge" {
+		return 4
+	}
+	if strings.HasPrefix(size, "metal") {
+		return metalInstanceVCPUs
+	}
+	if multiple, found := strings.CutSuffix(size, "xlarge"); found {
+		if n, err := strconv.Atoi(multiple); err == nil && n > 0 {
+			return n * 4
+		}
+	}
+	return defaultInstanceVCPUs
+}
+
+// instanceProcessor derives the processor class from the instance family: a "g" after the
+// generation digit marks Graviton and an "a" marks AMD
+func instanceProcessor(instanceType string) string {
+	family, _ := splitInstanceType(instanceType)
+	if family == "a1" {
+		return "graviton"
+	}
+	if legacyInstanceFamilies[family] {
+		return "intel-legacy"
+	}
+
+	// Attributes are the letters after the generation digit, e.g. "gd" in "m6gd"
+	digit := strings.IndexAny(family, "0123456789")
+	if digit == -1 {
+		return "intel"
+	}
+	attributes := strings.TrimLeft(family[digit:], "0123456789")
+	switch {
+	case strings.Contains(attributes, "g"):
+		return "graviton"
+	case strings.Contains(attributes, "a"):
+		return "amd"
+	default:
+		return "intel"
+	}
+}
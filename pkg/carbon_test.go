@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGridIntensity(t *testing.T) {
+	tests := []struct {
+		region    string
+		want      float64
+		wantKnown bool
+	}{
+		{region: "us-east-1", want: 379.069, wantKnown: true},
+		{region: "eu-west-1", want: 278.6, wantKnown: true},
+		{region: "eu-north-1", want: 8.8, wantKnown: true},
+		{region: "ap-southeast-2", want: 790, wantKnown: true},
+		{region: "eu-central-2", want: defaultGridIntensity},
+		{region: "", want: defaultGridIntensity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			got, known := GridIntensity(tt.region)
+			if got != tt.want || known != tt.wantKnown {
+				t.Errorf("GridIntensity(%q) = %v, %v, want %v, %v", tt.region, got, known, tt.want, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestEstimateEC2CO2(t *testing.T) {
+	tests := []struct {
+		name          string
+		instanceType  string
+		region        string
+		cpuAvg        float64
+		wantKg        float64
+		wantVCPUs     int
+		wantProcessor string
+		wantKnown     bool
+	}{
+		// 2 vCPUs at 2.305 W, 720 hours and PUE 1.135 use 3.767 kWh a month
+		{name: "intel in eu-west-1", instanceType: "m5.large", region: "eu-west-1", cpuAvg: 50, wantKg: 1.04957, wantVCPUs: 2, wantProcessor: "intel", wantKnown: true},
+		{name: "intel in eu-north-1", instanceType: "m5.large", region: "eu-north-1", cpuAvg: 50, wantKg: 0.03315, wantVCPUs: 2, wantProcessor: "intel", wantKnown: true},
+		{name: "unknown region uses the default", instanceType: "m5.large", region: "mars-east-1", cpuAvg: 50, wantKg: 1.78946, wantVCPUs: 2, wantProcessor: "intel"},
+		{name: "idle graviton", instanceType: "m6g.xlarge", region: "us-east-1", cpuAvg: 0, wantKg: 0.58238, wantVCPUs: 4, wantProcessor: "graviton", wantKnown: true},
+		{name: "utilization is clamped", instanceType: "m6g.xlarge", region: "us-east-1", cpuAvg: -20, wantKg: 0.58238, wantVCPUs: 4, wantProcessor: "graviton", wantKnown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kg, breakdown := EstimateEC2CO2(tt.instanceType, tt.region, tt.cpuAvg)
+			if math.Abs(kg-tt.wantKg) > 1e-4 {
+				t.Errorf("EstimateEC2CO2() = %.5f kg, want %.5f", kg, tt.wantKg)
+			}
+			if breakdown.VCPUs != tt.wantVCPUs || breakdown.Processor != tt.wantProcessor || breakdown.RegionKnown != tt.wantKnown {
+				t.Errorf("breakdown = %+v", breakdown)
+			}
+			if breakdown.PUE != dataCenterPUE {
+				t.Errorf("PUE = %v, want %v", breakdown.PUE, dataCenterPUE)
+			}
+		})
+	}
+}
+
+// The same instance in the cleanest and dirtiest regions differs by the ratio of their grids
+func TestEstimateEC2CO2ScalesWithRegion(t *testing.T) {
+	north, _ := EstimateEC2CO2("c5.2xlarge", "eu-north-1", 30)
+	africa, _ := EstimateEC2CO2("c5.2xlarge", "af-south-1", 30)
+	if ratio := africa / north; math.Abs(ratio-900.6/8.8) > 1e-6 {
+		t.Errorf("af-south-1 / eu-north-1 = %v, want %v", ratio, 900.6/8.8)
+	}
+}
+
+func TestInstanceVCPUsAndProcessor(t *testing.T) {
+	tests := []struct {
+		instanceType  string
+		wantVCPUs     int
+		wantProcessor string
+	}{
+		{instanceType: "t2.micro", wantVCPUs: 1, wantProcessor: "intel-legacy"},
+		{instanceType: "t3.micro", wantVCPUs: 2, wantProcessor: "intel"},
+		{instanceType: "m5.xlarge", wantVCPUs: 4, wantProcessor: "intel"},
+		{instanceType: "c6gd.4xlarge", wantVCPUs: 16, wantProcessor: "graviton"},
+		{instanceType: "m5a.24xlarge", wantVCPUs: 96, wantProcessor: "amd"},
+		{instanceType: "a1.medium", wantVCPUs: 2, wantProcessor: "graviton"},
+		{instanceType: "m4.large", wantVCPUs: 2, wantProcessor: "intel-legacy"},
+		{instanceType: "i3.metal", wantVCPUs: metalInstanceVCPUs, wantProcessor: "intel-legacy"},
+		{instanceType: "weird", wantVCPUs: defaultInstanceVCPUs, wantProcessor: "intel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instanceType, func(t *testing.T) {
+			if got := instanceVCPUs(tt.instanceType); got != tt.wantVCPUs {
+				t.Errorf("instanceVCPUs(%q) = %d, want %d", tt.instanceType, got, tt.wantVCPUs)
+			}
+			if got := instanceProcessor(tt.instanceType); got != tt.wantProcessor {
+				t.Errorf("instanceProcessor(%q) = %q, want %q", tt.instanceType, got, tt.wantProcessor)
+			}
+		})
+	}
+}
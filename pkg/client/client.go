@@ -0,0 +1,342 @@
+// Package client is a Go client for the GreenOps API: it submits scanned resources for
+// analysis, follows the resulting jobs and fetches their reports.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// JobSubmission is the API's answer to an asynchronous analysis request
+type JobSubmission = pkg.JobAccepted
+
+// RetryPolicy controls how requests that failed with a timeout, a 429 or a 502-504 are retried
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per request, including the first; 1 disables retries
+	Backoff     time.Duration // wait before the second attempt, doubled before each later one
+}
+
+// DefaultRetryPolicy is used when Options.Retry is left empty
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: 5 * time.Second}
+
+// DefaultTimeout bounds each HTTP request when Options.Timeout is not set
+const DefaultTimeout = 60 * time.Second
+
+// DefaultUserAgent identifies requests when Options.UserAgent is not set
+const DefaultUserAgent = "GreenOps-Go-Client"
+
+// Options configures a Client
+type Options struct {
+	APIKey     string        // sent as x-api-key when set
+	Timeout    time.Duration // per request; DefaultTimeout unless set
+	Retry      RetryPolicy   // DefaultRetryPolicy unless set
+	HTTPClient *http.Client  // replaces the client built from Timeout, e.g. to add a proxy
+	UserAgent  string        // DefaultUserAgent unless set, e.g. "GreenOps-CLI/v1.2.0"
+	// IncludeEmbeddings asks for the embeddings of job results, which the API leaves out by default
+	IncludeEmbeddings bool
+	// ResultsPageSize is how many items' results GetJobResults fetches per request;
+	// pkg.DefaultResultsPageSize unless set, and at most pkg.MaxResultsPageSize
+	ResultsPageSize int
+}
+
+// Client talks to one GreenOps API deployment
+type Client struct {
+	analyzeURL string
+	baseURL    string // analyzeURL without /analyze; the jobs routes hang off it
+	apiKey     string
+	userAgent  string
+	http       *http.Client
+	retry      RetryPolicy
+	embeddings bool
+	pageSize   int
+}
+
+// New creates a client for the API whose analyze endpoint is apiURL, e.g.
+// https://example.execute-api.eu-west-1.amazonaws.com/analyze
+func New(apiURL string, opts Options) *Client {
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+	retry := opts.Retry
+	if retry.MaxAttempts <= 0 {
+		retry = DefaultRetryPolicy
+	}
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	pageSize := opts.ResultsPageSize
+	if pageSize <= 0 {
+		pageSize = pkg.DefaultResultsPageSize
+	}
+	return &Client{
+		analyzeURL: apiURL,
+		baseURL:    strings.TrimSuffix(apiURL, "/analyze"),
+		apiKey:     opts.APIKey,
+		userAgent:  userAgent,
+		http:       httpClient,
+		retry:      retry,
+		embeddings: opts.IncludeEmbeddings,
+		pageSize:   min(pageSize, pkg.MaxResultsPageSize),
+	}
+}
+
+// APIError is a non-success response from the API
+type APIError struct {
+	StatusCode int
+	Code       string // one of the pkg.Code* constants; empty when the body was not a structured API error
+	Message    string
+	Fields     []pkg.FieldError // the invalid fields, with pkg.CodeInvalidResources
+	Body       string           // the raw response body
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		if e.StatusCode == http.StatusServiceUnavailable {
+			return "API service unavailable (503). The service might be experiencing high load or temporary issues with the underlying models. Try again later or with fewer resources."
+		}
+		return fmt.Sprintf("API returned error status %d: %s", e.StatusCode, e.Body)
+	}
+	msg := fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, e.Code)
+	for _, fieldErr := range e.Fields {
+		msg += "\n  " + fieldErr.Error()
+	}
+	return msg
+}
+
+// newAPIError parses an error response, keeping the raw body for responses that are not
+// structured API errors, such as those of API Gateway itself
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: strings.TrimSpace(string(body))}
+	var parsed pkg.APIError
+	if json.Unmarshal(body, &parsed) == nil && parsed.Code != "" {
+		apiErr.Code = parsed.Code
+		apiErr.Message = parsed.Message
+		apiErr.Fields = parsed.Errors
+	}
+	return apiErr
+}
+
+// Analyze sends a payload for synchronous analysis and returns the report
+func (c *Client) Analyze(ctx context.Context, payload pkg.ScanPayload) ([]pkg.ReportItem, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	var resp struct {
+		Report []pkg.ReportItem `json:"report"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.analyzeURL, body, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Report, nil
+}
+
+// SubmitAnalysis sends a payload for asynchronous analysis and returns the accepted job
+func (c *Client) SubmitAnalysis(ctx context.Context, payload pkg.ScanPayload) (JobSubmission, error) {
+	var job JobSubmission
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return job, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, c.analyzeURL, body, &job, http.StatusAccepted)
+	return job, err
+}
+
+// GetJobStatus retrieves the progress of a job
+func (c *Client) GetJobStatus(ctx context.Context, jobID string) (pkg.JobStatusResponse, error) {
+	var st pkg.JobStatusResponse
+	err := c.do(ctx, http.MethodGet, c.jobResultsURL(jobID, "", url.Values{}), nil, &st, http.StatusOK, http.StatusAccepted)
+	return st, err
+}
+
+// GetJobResults retrieves the results recorded so far for a job, finished or not. Jobs with
+// more items than the page size are fetched a page at a time, logging the progress. An API
+// that does not page answers the first request with every result.
+func (c *Client) GetJobResults(ctx context.Context, jobID string) ([]pkg.ReportItem, error) {
+	report := make([]pkg.ReportItem, 0)
+	for offset := 0; ; offset += c.pageSize {
+		page, err := c.GetJobResultsPage(ctx, jobID, offset, c.pageSize)
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, page.Results...)
+		if page.TotalItems == 0 || offset+c.pageSize >= page.TotalItems {
+			break
+		}
+		pkg.Infof("Fetched results for %d of %d items of job %s", offset+c.pageSize, page.TotalItems, jobID)
+	}
+	pkg.Debugf("Retrieved %d report items for job %s", len(report), jobID)
+	return report, nil
+}
+
+// GetJobResultsPage retrieves the results of a job's items offset to offset+limit-1, with the
+// job's total item count. The page holds fewer results than limit where items failed or are
+// still pending.
+func (c *Client) GetJobResultsPage(ctx context.Context, jobID string, offset, limit int) (pkg.JobResultsResponse, error) {
+	query := url.Values{}
+	query.Set(pkg.ResultsOffsetParam, strconv.Itoa(offset))
+	query.Set(pkg.ResultsLimitParam, strconv.Itoa(limit))
+
+	var page pkg.JobResultsResponse
+	err := c.do(ctx, http.MethodGet, c.jobResultsURL(jobID, "/results", query), nil, &page, http.StatusOK)
+	return page, err
+}
+
+// jobResultsURL returns the URL of a job route that can return results with the given query,
+// asking for their embeddings when the client wants them
+func (c *Client) jobResultsURL(jobID, suffix string, query url.Values) string {
+	if c.embeddings {
+		query.Set(pkg.IncludeEmbeddingsParam, "true")
+	}
+	u := c.baseURL + "/jobs/" + jobID + suffix
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// ListJobs retrieves a page of the jobs this client's API key may read, newest first within
+// the page, optionally only those in status. A limit of 0 lets the API pick the page size.
+// Pass the NextToken of the response to get the next page; it is empty after the last one.
+func (c *Client) ListJobs(ctx context.Context, status pkg.JobStatus, limit int, nextToken string) (pkg.JobListResponse, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set(pkg.JobsStatusParam, string(status))
+	}
+	if limit > 0 {
+		query.Set(pkg.JobsLimitParam, strconv.Itoa(limit))
+	}
+	if nextToken != "" {
+		query.Set(pkg.JobsNextTokenParam, nextToken)
+	}
+	u := c.baseURL + "/jobs"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var list pkg.JobListResponse
+	err := c.do(ctx, http.MethodGet, u, nil, &list, http.StatusOK)
+	return list, err
+}
+
+// GetJobRequest retrieves the payload a job was submitted with. Jobs whose payload the API
+// did not keep return an *APIError with pkg.CodeRequestNotStored.
+func (c *Client) GetJobRequest(ctx context.Context, jobID string) (pkg.ScanPayload, error) {
+	var payload pkg.ScanPayload
+	err := c.do(ctx, http.MethodGet, c.baseURL+"/jobs/"+jobID+"/request", nil, &payload, http.StatusOK)
+	return payload, err
+}
+
+// RetryJob asks the API to queue the failed items of a finished job again
+func (c *Client) RetryJob(ctx context.Context, jobID string) (pkg.JobRetryResponse, error) {
+	var retry pkg.JobRetryResponse
+	err := c.do(ctx, http.MethodPost, c.baseURL+"/jobs/"+jobID+"/retry", nil, &retry, http.StatusAccepted)
+	return retry, err
+}
+
+// RequeueDLQ asks the API to move the messages in the dead-letter queue back to the work
+// queue. It needs an admin API key.
+func (c *Client) RequeueDLQ(ctx context.Context) (pkg.DLQRequeueResponse, error) {
+	var requeue pkg.DLQRequeueResponse
+	err := c.do(ctx, http.MethodPost, c.baseURL+"/admin/dlq/requeue", nil, &requeue, http.StatusAccepted)
+	return requeue, err
+}
+
+// CancelJob asks the API to stop processing a job
+func (c *Client) CancelJob(ctx context.Context, jobID string) error {
+	return c.do(ctx, http.MethodDelete, c.baseURL+"/jobs/"+jobID, nil, nil, http.StatusOK)
+}
+
+// do sends a request, retrying it under the client's retry policy, and decodes the
+// response into out. Any status outside okStatuses is returned as *APIError.
+func (c *Client) do(ctx context.Context, method, url string, body []byte, out interface{}, okStatuses ...int) error {
+	backoff := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := c.doOnce(ctx, method, url, body, out, okStatuses)
+		if err == nil || attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		pkg.Warnf("%s %s failed (attempt %d/%d): %v; retrying in %s", method, url, attempt, c.retry.MaxAttempts, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// doOnce sends a single request
+func (c *Client) doOnce(ctx context.Context, method, url string, body []byte, out interface{}, okStatuses []int) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set(pkg.APIKeyHeader, c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	pkg.DebugPayload(fmt.Sprintf("%s %s response (%d)", method, url, resp.StatusCode), string(respBody))
+
+	if !slices.Contains(okStatuses, resp.StatusCode) {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
+
+// retryable reports whether a failed request may succeed if sent again: timeouts, throttling
+// and the gateway errors API Gateway returns while Lambda is busy or cold
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
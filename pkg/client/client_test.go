@@ -0,0 +1,312 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// scriptedAPI answers the nth request with the nth status of statuses, repeating the last
+type scriptedAPI struct {
+	mu       sync.Mutex
+	statuses []int
+	body     string
+	requests []*http.Request
+	delay    func(attempt int) time.Duration
+}
+
+func (s *scriptedAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	attempt := len(s.requests)
+	status := s.statuses[min(attempt, len(s.statuses))-1]
+	s.mu.Unlock()
+
+	if s.delay != nil {
+		select {
+		case <-time.After(s.delay(attempt)):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(status)
+	if status >= 400 {
+		json.NewEncoder(w).Encode(pkg.APIError{Code: "failure_" + strconv.Itoa(status), Message: "failed"})
+		return
+	}
+	w.Write([]byte(s.body))
+}
+
+func (s *scriptedAPI) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// newRetryingClient starts server and returns a client for it retrying quickly
+func newRetryingClient(t *testing.T, handler http.Handler, opts Options) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	if opts.Retry.MaxAttempts == 0 {
+		opts.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	}
+	return New(server.URL+"/analyze", opts)
+}
+
+func TestClientRetries(t *testing.T) {
+	accepted := `{"job_id":"job-1","status":"pending","total_items":2}`
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantStatus   int // of the *APIError returned, 0 for success
+	}{
+		{name: "first attempt", statuses: []int{202}, wantAttempts: 1},
+		{name: "throttled then accepted", statuses: []int{429, 202}, wantAttempts: 2},
+		{name: "gateway errors then accepted", statuses: []int{502, 504, 202}, wantAttempts: 3},
+		{name: "unavailable until the attempts run out", statuses: []int{503}, wantAttempts: 3, wantStatus: 503},
+		{name: "bad request is not retried", statuses: []int{400, 202}, wantAttempts: 1, wantStatus: 400},
+		{name: "internal error is not retried", statuses: []int{500, 202}, wantAttempts: 1, wantStatus: 500},
+		{name: "forbidden is not retried", statuses: []int{403, 202}, wantAttempts: 1, wantStatus: 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &scriptedAPI{statuses: tt.statuses, body: accepted}
+			c := newRetryingClient(t, api, Options{})
+
+			job, err := c.SubmitAnalysis(context.Background(), pkg.ScanPayload{Instances: []pkg.Instance{{InstanceID: "i-1"}}})
+			if api.attempts() != tt.wantAttempts {
+				t.Errorf("sent %d requests, want %d", api.attempts(), tt.wantAttempts)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("SubmitAnalysis() error = %v", err)
+				}
+				if job.JobID != "job-1" {
+					t.Errorf("job = %+v", job)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus {
+				t.Fatalf("SubmitAnalysis() error = %v, want a %d *APIError", err, tt.wantStatus)
+			}
+			if apiErr.Code != "failure_"+strconv.Itoa(tt.wantStatus) {
+				t.Errorf("code = %q", apiErr.Code)
+			}
+		})
+	}
+}
+
+// Every attempt resends the whole body
+func TestClientRetriesResendBody(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	attempt := 0
+	c := newRetryingClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var payload pkg.ScanPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		bodies = append(bodies, payload.Instances[0].InstanceID)
+		if attempt++; attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"job_id":"job-1"}`))
+	}), Options{})
+
+	if _, err := c.SubmitAnalysis(context.Background(), pkg.ScanPayload{Instances: []pkg.Instance{{InstanceID: "i-1"}}}); err != nil {
+		t.Fatalf("SubmitAnalysis() error = %v", err)
+	}
+	if strings.Join(bodies, ",") != "i-1,i-1" {
+		t.Errorf("bodies = %v, want the payload twice", bodies)
+	}
+}
+
+// A request that times out is retried, and the retry can succeed
+func TestClientRetriesTimeouts(t *testing.T) {
+	api := &scriptedAPI{
+		statuses: []int{200},
+		body:     `{"job_id":"job-1","status":"completed"}`,
+		delay: func(attempt int) time.Duration {
+			if attempt == 1 {
+				return time.Second
+			}
+			return 0
+		},
+	}
+	c := newRetryingClient(t, api, Options{Timeout: 50 * time.Millisecond})
+
+	st, err := c.GetJobStatus(context.Background(), "job-1")
+	if err != nil {
+		t.Fatalf("GetJobStatus() error = %v", err)
+	}
+	if st.Status != pkg.JobStatusCompleted || api.attempts() != 2 {
+		t.Errorf("status %q after %d attempts, want completed after 2", st.Status, api.attempts())
+	}
+}
+
+// Each call accepts only the statuses its route answers with on success
+func TestClientSuccessStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		call    func(c *Client) error
+		wantErr bool
+	}{
+		{name: "status of a finished job", status: 200, call: func(c *Client) error { _, err := c.GetJobStatus(context.Background(), "job-1"); return err }},
+		{name: "status of a running job", status: 202, call: func(c *Client) error { _, err := c.GetJobStatus(context.Background(), "job-1"); return err }},
+		{name: "submission accepted", status: 202, call: func(c *Client) error { _, err := c.SubmitAnalysis(context.Background(), pkg.ScanPayload{}); return err }},
+		{name: "submission answered synchronously", status: 200, call: func(c *Client) error { _, err := c.SubmitAnalysis(context.Background(), pkg.ScanPayload{}); return err }, wantErr: true},
+		{name: "synchronous analysis", status: 200, call: func(c *Client) error { _, err := c.Analyze(context.Background(), pkg.ScanPayload{}); return err }},
+		{name: "synchronous analysis accepted", status: 202, call: func(c *Client) error { _, err := c.Analyze(context.Background(), pkg.ScanPayload{}); return err }, wantErr: true},
+		{name: "results of a running job", status: 202, call: func(c *Client) error { _, err := c.GetJobResults(context.Background(), "job-1"); return err }, wantErr: true},
+		{name: "cancellation", status: 200, call: func(c *Client) error { return c.CancelJob(context.Background(), "job-1") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &scriptedAPI{statuses: []int{tt.status}, body: `{}`}
+			c := newRetryingClient(t, api, Options{})
+
+			err := tt.call(c)
+			var apiErr *APIError
+			if tt.wantErr != errors.As(err, &apiErr) || (!tt.wantErr && err != nil) {
+				t.Errorf("error = %v, want an *APIError %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientSendsHeaders(t *testing.T) {
+	api := &scriptedAPI{statuses: []int{202}, body: `{}`}
+	c := newRetryingClient(t, api, Options{APIKey: "secret", UserAgent: "GreenOps-CLI/test"})
+
+	if _, err := c.SubmitAnalysis(context.Background(), pkg.ScanPayload{}); err != nil {
+		t.Fatalf("SubmitAnalysis() error = %v", err)
+	}
+	req := api.requests[0]
+	if req.Method != http.MethodPost || req.URL.Path != "/analyze" {
+		t.Errorf("request = %s %s", req.Method, req.URL.Path)
+	}
+	for header, want := range map[string]string{pkg.APIKeyHeader: "secret", "User-Agent": "GreenOps-CLI/test", "Content-Type": "application/json"} {
+		if got := req.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+// Cancelling ctx during the backoff stops the retries at once
+func TestClientStopsRetryingWhenCancelled(t *testing.T) {
+	api := &scriptedAPI{statuses: []int{503}}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newRetryingClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.ServeHTTP(w, r)
+		cancel()
+	}), Options{Retry: RetryPolicy{MaxAttempts: 5, Backoff: time.Minute}})
+
+	start := time.Now()
+	_, err := c.GetJobStatus(ctx, "job-1")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetJobStatus() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetJobStatus() took %s after cancellation", elapsed)
+	}
+	if api.attempts() != 1 {
+		t.Errorf("sent %d requests, want 1", api.attempts())
+	}
+}
+
+// Cancelling ctx aborts a request in flight, and it is not retried
+func TestClientAbortsRequestWhenCancelled(t *testing.T) {
+	api := &scriptedAPI{statuses: []int{200}, delay: func(int) time.Duration { return time.Minute }}
+	c := newRetryingClient(t, api, Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetJobStatus(ctx, "job-1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetJobStatus() error = %v, want context.DeadlineExceeded", err)
+	}
+	if api.attempts() != 1 {
+		t.Errorf("sent %d requests, want 1", api.attempts())
+	}
+}
+
+func TestGetJobResultsPages(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	c := newRetryingClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+		offset, _ := strconv.Atoi(r.URL.Query().Get(pkg.ResultsOffsetParam))
+		limit, _ := strconv.Atoi(r.URL.Query().Get(pkg.ResultsLimitParam))
+		page := pkg.JobResultsResponse{Results: []pkg.ReportItem{}, Offset: offset, Limit: limit, TotalItems: 5}
+		for i := offset; i < min(offset+limit, 5); i++ {
+			page.Results = append(page.Results, pkg.ReportItem{Analysis: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(page)
+	}), Options{ResultsPageSize: 2, IncludeEmbeddings: true})
+
+	report, err := c.GetJobResults(context.Background(), "job-1")
+	if err != nil {
+		t.Fatalf("GetJobResults() error = %v", err)
+	}
+	var analyses []string
+	for _, item := range report {
+		analyses = append(analyses, item.Analysis)
+	}
+	if strings.Join(analyses, ",") != "0,1,2,3,4" {
+		t.Errorf("results = %v", analyses)
+	}
+	want := []string{
+		"include_embeddings=true&limit=2&offset=0",
+		"include_embeddings=true&limit=2&offset=2",
+		"include_embeddings=true&limit=2&offset=4",
+	}
+	if strings.Join(queries, " ") != strings.Join(want, " ") {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+}
+
+func TestAPIErrorMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{
+			name:   "structured",
+			status: 400,
+			body:   `{"error":"invalid resources in request","code":"invalid_resources","errors":[{"field":"instances[0].instanceId","message":"must not be empty"}]}`,
+			want:   "invalid resources in request (400 invalid_resources)\n  instances[0].instanceId: must not be empty",
+		},
+		{name: "gateway", status: 502, body: `{"message":"Internal server error"}`, want: `API returned error status 502: {"message":"Internal server error"}`},
+		{name: "unavailable", status: 503, body: "", want: "API service unavailable (503)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newAPIError(tt.status, []byte(tt.body)).Error(); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
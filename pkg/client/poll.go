@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// ErrJobCancelled is returned by WaitForJob when the job was cancelled server-side
+var ErrJobCancelled = errors.New("job cancelled")
+
+// JobFailedError is returned by WaitForJob when the job finished with status failed
+type JobFailedError struct {
+	JobID  string
+	Failed int
+	Total  int
+}
+
+func (e *JobFailedError) Error() string {
+	return fmt.Sprintf("job %s failed: %d of %d items failed", e.JobID, e.Failed, e.Total)
+}
+
+// PollTimeoutError is returned by WaitForJob when the job is still running after the last poll
+type PollTimeoutError struct {
+	JobID    string
+	Attempts int
+	Timeout  time.Duration // set when PollOptions.Timeout ran out rather than the attempts
+}
+
+func (e *PollTimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("job %s did not finish within %s", e.JobID, e.Timeout)
+	}
+	return fmt.Sprintf("job %s did not finish after %d polling attempts", e.JobID, e.Attempts)
+}
+
+// Polling defaults used when PollOptions leaves them unset
+const (
+	DefaultPollInterval    = 2 * time.Second
+	DefaultMaxPollInterval = 30 * time.Second
+	DefaultMaxPollAttempts = 60
+)
+
+// stalledPolls is how many polls without progress mark a job whose items are all processed
+// as done, even if its status was never moved on from processing
+const stalledPolls = 3
+
+// PollOptions configures WaitForJob
+type PollOptions struct {
+	Interval    time.Duration // wait after a poll that saw progress, and the floor of every wait
+	MaxInterval time.Duration // the wait doubles after each poll without progress, up to this
+	MaxAttempts int           // polls before giving up with *PollTimeoutError
+	Timeout     time.Duration // total time before giving up; when set, MaxAttempts only applies if set too
+
+	// Partial returns the results gathered so far alongside *JobFailedError or *PollTimeoutError
+	Partial bool
+
+	// Progress, if set, is called with the status seen by every poll
+	Progress func(pkg.JobStatusResponse)
+}
+
+// WaitForJob polls a job until it finishes and returns its results. A failed job returns
+// *JobFailedError, a cancelled one ErrJobCancelled and running out of attempts
+// *PollTimeoutError; with Partial the results so far come back with either error.
+// Cancelling ctx stops polling at once and returns ctx.Err().
+func (c *Client) WaitForJob(ctx context.Context, jobID string, opts PollOptions) ([]pkg.ReportItem, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPollInterval
+	}
+	if opts.MaxInterval < opts.Interval {
+		opts.MaxInterval = max(DefaultMaxPollInterval, opts.Interval)
+	}
+	if opts.MaxAttempts <= 0 && opts.Timeout <= 0 {
+		opts.MaxAttempts = DefaultMaxPollAttempts
+	}
+
+	var lastCompleted, noProgress int
+	var pollErr error = &PollTimeoutError{JobID: jobID, Attempts: opts.MaxAttempts}
+	interval := opts.Interval
+	start := time.Now()
+
+poll:
+	for attempt := 0; opts.MaxAttempts <= 0 || attempt < opts.MaxAttempts; attempt++ {
+		st, err := c.GetJobStatus(ctx, jobID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(st)
+		}
+
+		if st.Status == pkg.JobStatusCancelled {
+			return nil, ErrJobCancelled
+		}
+
+		// Poll sooner while items keep completing, back off while they do not
+		if st.CompletedItems > lastCompleted {
+			lastCompleted = st.CompletedItems
+			noProgress = 0
+			interval = opts.Interval
+		} else {
+			noProgress++
+			interval = min(interval*2, opts.MaxInterval)
+		}
+
+		if st.Status == pkg.JobStatusFailed {
+			pollErr = &JobFailedError{JobID: jobID, Failed: st.FailedItems, Total: st.TotalItems}
+			break
+		}
+		if st.Status == pkg.JobStatusCompleted ||
+			(st.CompletedItems+st.FailedItems >= st.TotalItems && noProgress >= stalledPolls) {
+			pollErr = nil
+			break
+		}
+
+		// The last wait is cut short so the final poll lands on the deadline
+		wait := min(jitter(interval), opts.MaxInterval)
+		if opts.Timeout > 0 {
+			remaining := opts.Timeout - time.Since(start)
+			if remaining <= 0 {
+				pollErr = &PollTimeoutError{JobID: jobID, Attempts: attempt + 1, Timeout: opts.Timeout}
+				break
+			}
+			wait = min(wait, remaining)
+		}
+
+		select {
+		case <-ctx.Done():
+			break poll
+		case <-time.After(wait):
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if pollErr != nil && !opts.Partial {
+		return nil, pollErr
+	}
+
+	report, err := c.GetJobResults(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return report, pollErr
+}
+
+// jitter lengthens a wait by up to 20%, never shortening it below the configured floor, so
+// clients that started together do not keep polling in step
+func jitter(d time.Duration) time.Duration {
+	spread := d / 5
+	if spread <= 0 {
+		return d
+	}
+	return d + rand.N(spread)
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkg "github.com/alexalbu001/greenops/pkg"
+)
+
+// fakeJobAPI serves GET /jobs/{id} from status, which is given the number of the poll
+// starting at 1, and GET /jobs/{id}/results from results
+type fakeJobAPI struct {
+	mu      sync.Mutex
+	polls   int
+	status  func(poll int) pkg.JobStatusResponse
+	results []pkg.ReportItem
+}
+
+func (f *fakeJobAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/results"):
+		json.NewEncoder(w).Encode(pkg.JobResultsResponse{Results: f.results, TotalItems: len(f.results)})
+	case strings.HasPrefix(r.URL.Path, "/jobs/"):
+		f.polls++
+		st := f.status(f.polls)
+		if st.Status == pkg.JobStatusCompleted {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		json.NewEncoder(w).Encode(st)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeJobAPI) pollCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.polls
+}
+
+// newTestClient starts server and returns a client for it that does not retry
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL+"/analyze", Options{Retry: RetryPolicy{MaxAttempts: 1}})
+}
+
+// jobStatus is a status response for a job of 4 items
+func jobStatus(status pkg.JobStatus, completed, failed int) pkg.JobStatusResponse {
+	return pkg.JobStatusResponse{JobID: "job-1", Status: status, TotalItems: 4, CompletedItems: completed, FailedItems: failed}
+}
+
+func TestWaitForJob(t *testing.T) {
+	results := []pkg.ReportItem{{Analysis: "first"}, {Analysis: "second"}}
+	fast := PollOptions{Interval: time.Millisecond, MaxInterval: 2 * time.Millisecond, MaxAttempts: 10}
+
+	tests := []struct {
+		name        string
+		status      func(poll int) pkg.JobStatusResponse
+		opts        PollOptions
+		wantErr     func(error) bool
+		wantResults int
+		wantPolls   int
+	}{
+		{
+			name: "completes",
+			status: func(poll int) pkg.JobStatusResponse {
+				if poll < 3 {
+					return jobStatus(pkg.JobStatusProcessing, poll, 0)
+				}
+				return jobStatus(pkg.JobStatusCompleted, 4, 0)
+			},
+			opts:        fast,
+			wantResults: 2,
+			wantPolls:   3,
+		},
+		{
+			name: "fails",
+			status: func(poll int) pkg.JobStatusResponse {
+				return jobStatus(pkg.JobStatusFailed, 1, 3)
+			},
+			opts: fast,
+			wantErr: func(err error) bool {
+				var failed *JobFailedError
+				return errors.As(err, &failed) && failed.Failed == 3 && failed.Total == 4
+			},
+			wantPolls: 1,
+		},
+		{
+			name: "fails with partial results",
+			status: func(poll int) pkg.JobStatusResponse {
+				return jobStatus(pkg.JobStatusFailed, 2, 2)
+			},
+			opts: PollOptions{Interval: time.Millisecond, MaxAttempts: 10, Partial: true},
+			wantErr: func(err error) bool {
+				var failed *JobFailedError
+				return errors.As(err, &failed)
+			},
+			wantResults: 2,
+			wantPolls:   1,
+		},
+		{
+			name: "stuck until the attempts run out",
+			status: func(poll int) pkg.JobStatusResponse {
+				return jobStatus(pkg.JobStatusProcessing, 1, 0)
+			},
+			opts: PollOptions{Interval: time.Millisecond, MaxInterval: 2 * time.Millisecond, MaxAttempts: 5},
+			wantErr: func(err error) bool {
+				var timeout *PollTimeoutError
+				return errors.As(err, &timeout) && timeout.Attempts == 5 && timeout.Timeout == 0
+			},
+			wantPolls: 5,
+		},
+		{
+			name: "stuck until the timeout runs out",
+			status: func(poll int) pkg.JobStatusResponse {
+				return jobStatus(pkg.JobStatusProcessing, 1, 0)
+			},
+			opts: PollOptions{Interval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Timeout: 30 * time.Millisecond},
+			wantErr: func(err error) bool {
+				var timeout *PollTimeoutError
+				return errors.As(err, &timeout) && timeout.Timeout == 30*time.Millisecond
+			},
+		},
+		{
+			name: "all items processed without the status moving on",
+			status: func(poll int) pkg.JobStatusResponse {
+				return jobStatus(pkg.JobStatusProcessing, 3, 1)
+			},
+			opts:        fast,
+			wantResults: 2,
+			wantPolls:   1 + stalledPolls,
+		},
+		{
+			name: "cancelled server-side",
+			status: func(poll int) pkg.JobStatusResponse {
+				return jobStatus(pkg.JobStatusCancelled, 1, 0)
+			},
+			opts:      fast,
+			wantErr:   func(err error) bool { return errors.Is(err, ErrJobCancelled) },
+			wantPolls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeJobAPI{status: tt.status, results: results}
+			c := newTestClient(t, api)
+
+			report, err := c.WaitForJob(context.Background(), "job-1", tt.opts)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("WaitForJob() error = %v", err)
+			}
+			if tt.wantErr != nil && !tt.wantErr(err) {
+				t.Fatalf("WaitForJob() error = %v (%T), not the one expected", err, err)
+			}
+			if len(report) != tt.wantResults {
+				t.Errorf("got %d results, want %d", len(report), tt.wantResults)
+			}
+			if tt.wantPolls > 0 && api.pollCount() != tt.wantPolls {
+				t.Errorf("polled %d times, want %d", api.pollCount(), tt.wantPolls)
+			}
+		})
+	}
+}
+
+// A slow job is abandoned as soon as ctx is cancelled, without sleeping out the interval
+func TestWaitForJobStopsWhenCancelled(t *testing.T) {
+	api := &fakeJobAPI{status: func(poll int) pkg.JobStatusResponse {
+		return jobStatus(pkg.JobStatusProcessing, 0, 0)
+	}}
+	c := newTestClient(t, api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := PollOptions{
+		Interval: time.Minute,
+		Progress: func(pkg.JobStatusResponse) { cancel() },
+	}
+
+	start := time.Now()
+	report, err := c.WaitForJob(ctx, "job-1", opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitForJob() error = %v, want context.Canceled", err)
+	}
+	if report != nil {
+		t.Errorf("got results %v from a cancelled wait", report)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("WaitForJob() took %s after cancellation", elapsed)
+	}
+	if api.pollCount() != 1 {
+		t.Errorf("polled %d times, want 1", api.pollCount())
+	}
+}
+
+func TestWaitForJobReturnsStatusErrors(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(pkg.APIError{Code: pkg.CodeJobNotFound, Message: "Job not found"})
+	}))
+
+	_, err := c.WaitForJob(context.Background(), "job-1", PollOptions{Interval: time.Millisecond})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != pkg.CodeJobNotFound {
+		t.Fatalf("WaitForJob() error = %v, want a 404 *APIError", err)
+	}
+}
@@ -2,7 +2,6 @@ package pkg
 
 import (
 	"context"
-	"log"
 	"time"
 
 	// AWS SDK v2 modules
@@ -11,29 +10,52 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
 // Instance holds metadata and computed metrics for an EC2 instance
 // - InstanceID: the unique identifier
 // - InstanceType: the EC2 flavor (e.g., t3.large)
+// - Region: the AWS region the instance runs in
 // - LaunchTime: when the instance was started
 // - Tags: key/value metadata attached to the instance
-// - CPUAvg7d: calculated 7-day average CPU utilization
+// - CPUAvg7d: average CPU utilization over the metrics window (7 days unless configured otherwise)
+// - NetworkInAvg7d/NetworkOutAvg7d: average network throughput in bytes per second
+// - MemAvg7d: average memory utilization, only set when the CloudWatch agent publishes it
+// - MetricsPeriodDays: length of the metrics window in days
+// - HourlyPrice/PriceSource: on-demand price fetched from the AWS Pricing API, when live pricing is on
+// - ActualMonthlyCost: spend over the last 30 days from Cost Explorer, when --with-cost-explorer matched it
 type Instance struct {
-	InstanceID   string            `json:"instanceId"`
-	InstanceType string            `json:"instanceType"`
-	LaunchTime   time.Time         `json:"launchTime"`
-	Tags         map[string]string `json:"tags"`
-	CPUAvg7d     float64           `json:"cpuAvg7d"`
+	InstanceID        string            `json:"instanceId"`
+	InstanceType      string            `json:"instanceType"`
+	Region            string            `json:"region"`
+	AccountID         string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	LaunchTime        time.Time         `json:"launchTime"`
+	Tags              map[string]string `json:"tags"`
+	CPUAvg7d          float64           `json:"cpuAvg7d"`
+	NetworkInAvg7d    float64           `json:"networkInAvg7d"`
+	NetworkOutAvg7d   float64           `json:"networkOutAvg7d"`
+	MemAvg7d          float64           `json:"memAvg7d,omitempty"`
+	MetricsPeriodDays int               `json:"metricsPeriodDays"`
+	HourlyPrice       float64           `json:"hourlyPrice,omitempty"`
+	PriceSource       string            `json:"priceSource,omitempty"`
+	ActualMonthlyCost float64           `json:"actualMonthlyCost,omitempty"`
+	// Idle detection from the collector: the busiest day's average CPU and the verdict against
+	// IdleThresholds, with IdleSince the earliest time the instance is known to have been idle
+	CPUDailyPeak float64    `json:"cpuDailyPeak,omitempty"`
+	IdleScore    int        `json:"idleScore,omitempty"`
+	Idle         bool       `json:"idle,omitempty"`
+	IdleSince    *time.Time `json:"idleSince,omitempty"`
 }
 
-// listInstances retrieves all running EC2 instances and calculates their 7-day avg CPU utilization
+// ListInstances retrieves all running EC2 instances, calculates their average CPU utilization over the last daysBack days
+// and classifies the ones below the idle thresholds as idle
 func ListInstances(
 	ctx context.Context,
-	ec2Client *ec2.Client,
-	cwClient *cloudwatch.Client,
+	ec2Client EC2DescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	daysBack int,
+	idleThresholds IdleThresholds,
 ) ([]Instance, error) {
 	// DescribeInstancesInput with filter: only "running" state
 	input := &ec2.DescribeInstancesInput{
@@ -51,30 +73,25 @@ func ListInstances(
 
 	var results []Instance
 
-	// Define time window for metrics: last 7 days
+	// Define time window for metrics: last daysBack days
+	daysBack = EffectivePeriodDays(daysBack)
 	endTime := time.Now().UTC()
-	startTime := endTime.AddDate(0, 0, -7)
+	startTime := endTime.AddDate(0, 0, -daysBack)
 
 	// Iterate over reservations (group of instances)
 	for _, reservation := range resp.Reservations {
 		for _, ec2Inst := range reservation.Instances {
-			// Fetch average CPU utilization for this instance
-			avgCPU, err := getCPUAvg(ctx, cwClient, *ec2Inst.InstanceId, startTime, endTime)
-			if err != nil {
-				// Log a warning and continue processing other instances
-				log.Printf("warning: unable to fetch CPU metrics for %s: %v", *ec2Inst.InstanceId, err)
-			}
-
 			// Convert AWS Tag slice to a simple map for easier lookup
 			tags := parseTags(ec2Inst.Tags)
 
 			// Assemble data into our Instance struct
 			instance := Instance{
-				InstanceID:   *ec2Inst.InstanceId,
-				InstanceType: string(ec2Inst.InstanceType),
-				LaunchTime:   *ec2Inst.LaunchTime,
-				Tags:         tags,
-				CPUAvg7d:     avgCPU,
+				InstanceID:        *ec2Inst.InstanceId,
+				InstanceType:      string(ec2Inst.InstanceType),
+				Region:            ec2Client.Options().Region,
+				LaunchTime:        *ec2Inst.LaunchTime,
+				Tags:              tags,
+				MetricsPeriodDays: daysBack,
 			}
 
 			// Add to results slice
@@ -82,50 +99,62 @@ func ListInstances(
 		}
 	}
 
+	// Fetch CPU, network and memory metrics for all instances in batched calls
+	if err := applyInstanceMetrics(ctx, cwClient, results, startTime, endTime); err != nil {
+		// Log a warning and return the instances without metrics
+		Warnf("unable to fetch instance metrics: %v", err)
+	}
+	for i := range results {
+		classifyIdle(&results[i], idleThresholds, startTime)
+	}
+
 	return results, nil
 }
 
-// getCPUAvg retrieves CPUUtilization datapoints from CloudWatch and computes an average value
-func getCPUAvg(
+// applyInstanceMetrics fills in CPU, network and memory averages using batched GetMetricData calls
+func applyInstanceMetrics(
 	ctx context.Context,
-	cwClient *cloudwatch.Client,
-	instanceID string,
+	cwClient CloudWatchMetricsAPI,
+	instances []Instance,
 	start, end time.Time,
-) (float64, error) {
-	// Prepare CloudWatch request: CPUUtilization metric, 1-hour period
-	input := &cloudwatch.GetMetricStatisticsInput{
-		Namespace:  aws.String("AWS/EC2"),        // Service namespace
-		MetricName: aws.String("CPUUtilization"), // Target metric
-		Dimensions: []cwTypes.Dimension{{ // Filter by InstanceId
-			Name:  aws.String("InstanceId"),
-			Value: aws.String(instanceID),
-		}},
-		StartTime:  &start,                                        // Start of time range
-		EndTime:    &end,                                          // End of time range
-		Period:     aws.Int32(3600),                               // 3600s = 1 hour granularity
-		Statistics: []cwTypes.Statistic{cwTypes.StatisticAverage}, // Fetch average values only
+) error {
+	if len(instances) == 0 {
+		return nil
 	}
 
-	// Execute the CloudWatch API call
-	resp, err := cwClient.GetMetricStatistics(ctx, input)
-	if err != nil {
-		return 0, err // Propagate error
+	queries := make([]metricQuery, 0, len(instances)*5)
+	for _, inst := range instances {
+		dims := []cwTypes.Dimension{{
+			Name:  aws.String("InstanceId"),
+			Value: aws.String(inst.InstanceID),
+		}}
+		queries = append(queries,
+			metricQuery{ResourceID: inst.InstanceID, Namespace: "AWS/EC2", MetricName: "CPUUtilization", Dimensions: dims, Stat: "Average", Period: 3600},
+			// The busiest day decides whether the instance was idle for the whole window
+			metricQuery{ResourceID: inst.InstanceID, Namespace: "AWS/EC2", MetricName: "CPUUtilization", Dimensions: dims, Stat: "Average", Period: 86400, Key: "CPUDailyPeak", Peak: true},
+			// Hourly sums are converted to bytes per second below
+			metricQuery{ResourceID: inst.InstanceID, Namespace: "AWS/EC2", MetricName: "NetworkIn", Dimensions: dims, Stat: "Sum", Period: 3600},
+			metricQuery{ResourceID: inst.InstanceID, Namespace: "AWS/EC2", MetricName: "NetworkOut", Dimensions: dims, Stat: "Sum", Period: 3600},
+			// Published by the CloudWatch agent, absent otherwise
+			metricQuery{ResourceID: inst.InstanceID, Namespace: "CWAgent", MetricName: "mem_used_percent", Dimensions: dims, Stat: "Average", Period: 3600},
+		)
 	}
 
-	// Sum up all average datapoints
-	var sum float64
-	for _, dp := range resp.Datapoints {
-		sum += *dp.Average
+	metrics, err := fetchMetricAverages(ctx, cwClient, queries, start, end)
+	if err != nil {
+		return err
 	}
 
-	// Avoid division by zero if no datapoints returned
-	count := float64(len(resp.Datapoints))
-	if count == 0 {
-		return 0, nil
+	for i := range instances {
+		m := metrics[instances[i].InstanceID]
+		instances[i].CPUAvg7d = m["CPUUtilization"]
+		instances[i].CPUDailyPeak = m["CPUDailyPeak"]
+		instances[i].NetworkInAvg7d = m["NetworkIn"] / 3600
+		instances[i].NetworkOutAvg7d = m["NetworkOut"] / 3600
+		instances[i].MemAvg7d = m["mem_used_percent"]
 	}
 
-	// Return computed average CPU utilization
-	return sum / count, nil
+	return nil
 }
 
 // parseTags converts AWS SDK Tag slice to a map[string]string for simpler access
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestListInstances(t *testing.T) {
+	metrics := func(namespace, metricName, stat string, dims map[string]string) []float64 {
+		switch metricName {
+		case "CPUUtilization":
+			return []float64{20, 40}
+		case "NetworkIn", "NetworkOut":
+			return []float64{7200}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name      string
+		ec2       *fakeEC2
+		cw        *fakeCloudWatch
+		wantErr   string
+		wantCount int
+		wantCPU   float64
+		wantNet   float64
+	}{
+		{
+			name:      "instances with metrics",
+			ec2:       &fakeEC2{region: "eu-west-1", reservations: testInstances(3, "m5.large")},
+			cw:        &fakeCloudWatch{values: metrics},
+			wantCount: 3,
+			wantCPU:   30,
+			wantNet:   2, // 7200 bytes an hour
+		},
+		{
+			name: "no instances",
+			ec2:  &fakeEC2{region: "eu-west-1"},
+			cw:   &fakeCloudWatch{values: metrics},
+		},
+		{
+			name:    "describe error is returned",
+			ec2:     &fakeEC2{region: "eu-west-1", err: errors.New("UnauthorizedOperation")},
+			cw:      &fakeCloudWatch{values: metrics},
+			wantErr: "UnauthorizedOperation",
+		},
+		{
+			name:      "metrics error leaves the instances without metrics",
+			ec2:       &fakeEC2{region: "eu-west-1", reservations: testInstances(2, "t3.micro")},
+			cw:        &fakeCloudWatch{err: errors.New("Throttling")},
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances, err := ListInstances(context.Background(), tt.ec2, tt.cw, 7, IdleThresholds{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ListInstances() error = %v, want %q", err, tt.wantErr)
+				}
+				if tt.cw.metricCalls() != 0 {
+					t.Errorf("CloudWatch was called after DescribeInstances failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListInstances() error = %v", err)
+			}
+			if len(instances) != tt.wantCount {
+				t.Fatalf("got %d instances, want %d", len(instances), tt.wantCount)
+			}
+			for _, inst := range instances {
+				if inst.Region != "eu-west-1" {
+					t.Errorf("%s region = %q, want eu-west-1", inst.InstanceID, inst.Region)
+				}
+				if inst.Tags["Name"] == "" {
+					t.Errorf("%s lost its Name tag", inst.InstanceID)
+				}
+				if inst.MetricsPeriodDays != 7 {
+					t.Errorf("%s MetricsPeriodDays = %d, want 7", inst.InstanceID, inst.MetricsPeriodDays)
+				}
+				if inst.CPUAvg7d != tt.wantCPU {
+					t.Errorf("%s CPUAvg7d = %v, want %v", inst.InstanceID, inst.CPUAvg7d, tt.wantCPU)
+				}
+				if inst.NetworkInAvg7d != tt.wantNet || inst.NetworkOutAvg7d != tt.wantNet {
+					t.Errorf("%s network = %v in, %v out, want %v bytes/s", inst.InstanceID, inst.NetworkInAvg7d, inst.NetworkOutAvg7d, tt.wantNet)
+				}
+			}
+		})
+	}
+}
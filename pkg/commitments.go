@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	ceTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/smithy-go"
+)
+
+// Rules of the commitment planner. Only EC2 instances running for commitmentMinAgeDays and
+// not idle count as steady-state usage, priced after the savings of their analyses so a
+// commitment is never sized on capacity that rightsizing removes. The planner commits to
+// commitmentSafetyMargin of the steady usage not covered yet, and to nothing while existing
+// Savings Plans are used below commitmentMinUtilizationPct.
+const (
+	commitmentMinAgeDays        = 30
+	commitmentSafetyMargin      = 0.8
+	commitmentMinUtilizationPct = 90.0
+	computeSavingsPlanDiscount  = 0.28 // 1-year no-upfront Compute Savings Plan against on-demand
+	commitmentMinMonthlySavings = 1.0  // dollars per month
+)
+
+// commitmentQueries is how many Cost Explorer queries FetchCommitmentCoverage makes
+const commitmentQueries = 4
+
+// CommitmentCoverage is how much of the account's compute spend Savings Plans and
+// reservations covered over the last 30 days, and the additional Savings Plan commitment
+// the steady-state usage of the scan would safely carry. Costs are per month.
+// - CoveragePct: the share of Savings Plans-eligible spend they covered, of OnDemandSpend plus CoveredSpend
+// - SavingsPlansCommitment and SavingsPlansUtilizationPct: what the existing Savings Plans commit to and how much of it was used
+// - ReservationCoveragePct and ReservationUtilizationPct: the share of running hours reservations covered, and of reserved hours used
+// - SteadyStateCost: the on-demand cost of the scan's steady-state EC2 usage after the savings of its analyses
+// - AdditionalHourlyCommitment: the Savings Plan commitment to add, in dollars per hour; zero when none is safe
+// - Unavailable: the queries that failed, e.g. for missing permissions; their figures are zero
+type CommitmentCoverage struct {
+	CoveragePct                float64
+	CoveredSpend               float64
+	OnDemandSpend              float64
+	SavingsPlansCommitment     float64
+	SavingsPlansUtilizationPct float64
+	ReservationCoveragePct     float64
+	ReservationUtilizationPct  float64
+	SteadyStateCost            float64
+	AdditionalHourlyCommitment float64
+	ProjectedMonthlySavings    float64
+	Unavailable                []string
+	// hasSavingsPlans is set when the account had Savings Plans during the window
+	hasSavingsPlans bool
+	// hasCoverage is set when the Savings Plans coverage query succeeded
+	hasCoverage bool
+}
+
+// ceAmount parses an amount or percentage of a Cost Explorer response, 0 when it is missing
+func ceAmount(value *string) float64 {
+	if value == nil {
+		return 0
+	}
+	amount, err := strconv.ParseFloat(*value, 64)
+	if err != nil {
+		Warnf("Unable to parse Cost Explorer amount %q: %v", *value, err)
+		return 0
+	}
+	return amount
+}
+
+// commitmentQueryError describes why a commitment query failed, naming the permission it
+// needs when it was denied
+func commitmentQueryError(action string, err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException" {
+		return fmt.Sprintf("ce:%s (permission denied)", action)
+	}
+	return fmt.Sprintf("ce:%s (%v)", action, err)
+}
+
+// isDataUnavailable reports whether Cost Explorer has nothing to report, as the utilization
+// queries do for accounts without Savings Plans or reservations
+func isDataUnavailable(err error) bool {
+	var unavailable *ceTypes.DataUnavailableException
+	return errors.As(err, &unavailable)
+}
+
+// FetchCommitmentCoverage reads the Savings Plans and reservation coverage and utilization of
+// the last 30 days from Cost Explorer, then plans the additional commitment for the report.
+// A query that fails is listed in Unavailable and the others still count; an error is only
+// returned when every query failed.
+func FetchCommitmentCoverage(ctx context.Context, client CommitmentsAPI, report []ReportItem) (*CommitmentCoverage, error) {
+	end := time.Now().UTC()
+	period := &ceTypes.DateInterval{
+		Start: aws.String(end.AddDate(0, 0, -costExplorerLookbackDays).Format(time.DateOnly)),
+		End:   aws.String(end.Format(time.DateOnly)),
+	}
+	coverage := &CommitmentCoverage{}
+
+	var nextToken *string
+	for {
+		resp, err := client.GetSavingsPlansCoverage(ctx, &costexplorer.GetSavingsPlansCoverageInput{
+			TimePeriod:  period,
+			Granularity: ceTypes.GranularityMonthly,
+			NextToken:   nextToken,
+		})
+		if err != nil {
+			coverage.Unavailable = append(coverage.Unavailable, commitmentQueryError("GetSavingsPlansCoverage", err))
+			coverage.CoveredSpend, coverage.OnDemandSpend = 0, 0
+			break
+		}
+		for _, month := range resp.SavingsPlansCoverages {
+			if month.Coverage == nil {
+				continue
+			}
+			coverage.CoveredSpend += ceAmount(month.Coverage.SpendCoveredBySavingsPlans)
+			coverage.OnDemandSpend += ceAmount(month.Coverage.OnDemandCost)
+		}
+		if resp.NextToken == nil {
+			coverage.hasCoverage = true
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	if eligible := coverage.CoveredSpend + coverage.OnDemandSpend; eligible > 0 {
+		coverage.CoveragePct = coverage.CoveredSpend / eligible * 100
+	}
+
+	utilization, err := client.GetSavingsPlansUtilization(ctx, &costexplorer.GetSavingsPlansUtilizationInput{TimePeriod: period})
+	switch {
+	case err == nil && utilization.Total != nil && utilization.Total.Utilization != nil:
+		coverage.SavingsPlansCommitment = ceAmount(utilization.Total.Utilization.TotalCommitment)
+		coverage.SavingsPlansUtilizationPct = ceAmount(utilization.Total.Utilization.UtilizationPercentage)
+		coverage.hasSavingsPlans = coverage.SavingsPlansCommitment > 0
+	case err != nil && !isDataUnavailable(err):
+		coverage.Unavailable = append(coverage.Unavailable, commitmentQueryError("GetSavingsPlansUtilization", err))
+	}
+
+	reservations, err := client.GetReservationCoverage(ctx, &costexplorer.GetReservationCoverageInput{TimePeriod: period})
+	switch {
+	case err == nil && reservations.Total != nil && reservations.Total.CoverageHours != nil:
+		coverage.ReservationCoveragePct = ceAmount(reservations.Total.CoverageHours.CoverageHoursPercentage)
+	case err != nil && !isDataUnavailable(err):
+		coverage.Unavailable = append(coverage.Unavailable, commitmentQueryError("GetReservationCoverage", err))
+	}
+
+	reserved, err := client.GetReservationUtilization(ctx, &costexplorer.GetReservationUtilizationInput{TimePeriod: period})
+	switch {
+	case err == nil && reserved.Total != nil:
+		coverage.ReservationUtilizationPct = ceAmount(reserved.Total.UtilizationPercentage)
+	case err != nil && !isDataUnavailable(err):
+		coverage.Unavailable = append(coverage.Unavailable, commitmentQueryError("GetReservationUtilization", err))
+	}
+
+	if len(coverage.Unavailable) == commitmentQueries {
+		return nil, fmt.Errorf("no commitment data from Cost Explorer: %s", strings.Join(coverage.Unavailable, ", "))
+	}
+	coverage.plan(report, time.Now())
+	return coverage, nil
+}
+
+// steadyStateCost sums the monthly on-demand cost, after the savings of their analyses, of
+// the EC2 instances of a report that are not idle and have run for commitmentMinAgeDays
+func steadyStateCost(report []ReportItem, now time.Time) float64 {
+	var total float64
+	for _, item := range report {
+		if item.GetResourceType() != ResourceTypeEC2 || item.IsIdle() {
+			continue
+		}
+		launched := item.Instance.LaunchTime
+		if launched.IsZero() || now.Sub(launched) < commitmentMinAgeDays*24*time.Hour {
+			continue
+		}
+		_, cost, savings := extractItemMetrics(item)
+		total += max(cost-savings, 0)
+	}
+	return total
+}
+
+// plan sizes the additional Savings Plan commitment: commitmentSafetyMargin of the steady
+// state the current coverage leaves on-demand, capped by the on-demand spend Cost Explorer
+// saw. Without the coverage query, or with existing Savings Plans underused, nothing is added.
+func (c *CommitmentCoverage) plan(report []ReportItem, now time.Time) {
+	c.SteadyStateCost = steadyStateCost(report, now)
+	if !c.hasCoverage || (c.hasSavingsPlans && c.SavingsPlansUtilizationPct < commitmentMinUtilizationPct) {
+		return
+	}
+	uncovered := min(c.SteadyStateCost*(1-c.CoveragePct/100), c.OnDemandSpend) * commitmentSafetyMargin
+	savings := uncovered * computeSavingsPlanDiscount
+	if savings < commitmentMinMonthlySavings {
+		return
+	}
+	c.AdditionalHourlyCommitment = uncovered * (1 - computeSavingsPlanDiscount) / hoursPerMonth
+	c.ProjectedMonthlySavings = savings
+}
+
+// printCommitmentCoverage prints the Commitment Coverage block of the sustainability
+// summary, when commitments were fetched
+func printCommitmentCoverage(w io.Writer, coverage *CommitmentCoverage, colorize bool) {
+	if coverage == nil {
+		return
+	}
+	if colorize {
+		fmt.Fprintf(w, "\n%sCOMMITMENT COVERAGE (last 30 days, Cost Explorer)%s\n", ColorBold, ColorReset)
+	} else {
+		fmt.Fprintf(w, "\nCOMMITMENT COVERAGE (last 30 days, Cost Explorer)\n")
+	}
+	fmt.Fprintf(w, "─────────────────────────────────────────────────\n")
+
+	if coverage.hasCoverage {
+		fmt.Fprintf(w, "• Compute spend covered by Savings Plans: %.1f%% ($%.2f of $%.2f)\n",
+			coverage.CoveragePct, coverage.CoveredSpend, coverage.CoveredSpend+coverage.OnDemandSpend)
+	}
+	if coverage.hasSavingsPlans {
+		fmt.Fprintf(w, "• Savings Plans utilization: %.1f%% of $%.2f committed\n", coverage.SavingsPlansUtilizationPct, coverage.SavingsPlansCommitment)
+	}
+	if coverage.ReservationCoveragePct > 0 || coverage.ReservationUtilizationPct > 0 {
+		fmt.Fprintf(w, "• Reserved instance hours: %.1f%% covered, %.1f%% of reservations used\n",
+			coverage.ReservationCoveragePct, coverage.ReservationUtilizationPct)
+	}
+	fmt.Fprintf(w, "• Steady-state EC2 usage in this scan: $%.2f per month after optimization\n", coverage.SteadyStateCost)
+
+	switch {
+	case coverage.AdditionalHourlyCommitment > 0:
+		fmt.Fprintf(w, "• Safe additional commitment: $%.3f/hour Compute Savings Plan, saving about $%.2f per month\n",
+			coverage.AdditionalHourlyCommitment, coverage.ProjectedMonthlySavings)
+	case coverage.hasSavingsPlans && coverage.SavingsPlansUtilizationPct < commitmentMinUtilizationPct:
+		fmt.Fprintf(w, "• No additional commitment: existing Savings Plans are only %.1f%% used\n", coverage.SavingsPlansUtilizationPct)
+	case coverage.hasCoverage:
+		fmt.Fprintf(w, "• No additional commitment: the steady-state usage is already covered\n")
+	}
+	if len(coverage.Unavailable) > 0 {
+		fmt.Fprintf(w, "• Unavailable: %s\n", strings.Join(coverage.Unavailable, ", "))
+	}
+}
@@ -0,0 +1,266 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	ceTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeCostExplorer answers the commitment queries with canned responses. coverage is served
+// one page per call; a query with an error set fails with it.
+type fakeCostExplorer struct {
+	coverage          []ceTypes.SavingsPlansCoverage
+	coverageErr       error
+	coverageErrOnPage int // the page that fails with coverageErr, 0 for the first
+	spCommitment      string
+	spUtilization     string
+	spUtilizationErr  error
+	riCoverage        string
+	riCoverageErr     error
+	riUtilization     string
+	riUtilizationErr  error
+	coveragePages     int
+}
+
+var _ CommitmentsAPI = (*fakeCostExplorer)(nil)
+
+func (f *fakeCostExplorer) GetSavingsPlansCoverage(ctx context.Context, params *costexplorer.GetSavingsPlansCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansCoverageOutput, error) {
+	page := f.coveragePages
+	f.coveragePages++
+	if f.coverageErr != nil && page >= f.coverageErrOnPage {
+		return nil, f.coverageErr
+	}
+	out := &costexplorer.GetSavingsPlansCoverageOutput{}
+	if page < len(f.coverage) {
+		out.SavingsPlansCoverages = f.coverage[page : page+1]
+	}
+	if page+1 < len(f.coverage) {
+		out.NextToken = aws.String(fmt.Sprint(page + 1))
+	}
+	return out, nil
+}
+
+func (f *fakeCostExplorer) GetSavingsPlansUtilization(ctx context.Context, params *costexplorer.GetSavingsPlansUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetSavingsPlansUtilizationOutput, error) {
+	if f.spUtilizationErr != nil {
+		return nil, f.spUtilizationErr
+	}
+	return &costexplorer.GetSavingsPlansUtilizationOutput{Total: &ceTypes.SavingsPlansUtilizationAggregates{
+		Utilization: &ceTypes.SavingsPlansUtilization{TotalCommitment: aws.String(f.spCommitment), UtilizationPercentage: aws.String(f.spUtilization)},
+	}}, nil
+}
+
+func (f *fakeCostExplorer) GetReservationCoverage(ctx context.Context, params *costexplorer.GetReservationCoverageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationCoverageOutput, error) {
+	if f.riCoverageErr != nil {
+		return nil, f.riCoverageErr
+	}
+	return &costexplorer.GetReservationCoverageOutput{Total: &ceTypes.Coverage{
+		CoverageHours: &ceTypes.CoverageHours{CoverageHoursPercentage: aws.String(f.riCoverage)},
+	}}, nil
+}
+
+func (f *fakeCostExplorer) GetReservationUtilization(ctx context.Context, params *costexplorer.GetReservationUtilizationInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetReservationUtilizationOutput, error) {
+	if f.riUtilizationErr != nil {
+		return nil, f.riUtilizationErr
+	}
+	return &costexplorer.GetReservationUtilizationOutput{Total: &ceTypes.ReservationAggregates{UtilizationPercentage: aws.String(f.riUtilization)}}, nil
+}
+
+// coverageMonth is a month of Savings Plans coverage
+func coverageMonth(covered, onDemand string) ceTypes.SavingsPlansCoverage {
+	return ceTypes.SavingsPlansCoverage{Coverage: &ceTypes.SavingsPlansCoverageData{
+		SpendCoveredBySavingsPlans: aws.String(covered),
+		OnDemandCost:               aws.String(onDemand),
+	}}
+}
+
+var (
+	errAccessDenied    = &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform ce:GetSavingsPlansCoverage"}
+	errDataUnavailable = &ceTypes.DataUnavailableException{Message: aws.String("no Savings Plans")}
+)
+
+// commitmentReport has $800 a month of steady-state EC2 usage after optimization, next to
+// an idle instance, a recent one and a bucket that do not count
+func commitmentReport() []ReportItem {
+	old := time.Now().AddDate(0, 0, -60)
+	return []ReportItem{
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-steady", LaunchTime: old}, MonthlyCost: 1000, MonthlySavings: 200},
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-idle", LaunchTime: old, Idle: true}, MonthlyCost: 300},
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-new", LaunchTime: time.Now().AddDate(0, 0, -10)}, MonthlyCost: 400},
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-unknown"}, MonthlyCost: 500},
+		{ResourceType: ResourceTypeS3, S3Bucket: S3Bucket{BucketName: "logs"}, MonthlyCost: 50},
+	}
+}
+
+func TestFetchCommitmentCoverage(t *testing.T) {
+	// Two months of coverage, $300 of $1000 eligible spend covered
+	twoMonths := []ceTypes.SavingsPlansCoverage{coverageMonth("100", "300"), coverageMonth("200", "400")}
+	// 70% of the $800 steady state is uncovered, and 80% of that is committed at a 28% discount
+	const uncovered = 800 * 0.7 * commitmentSafetyMargin
+
+	tests := []struct {
+		name            string
+		api             *fakeCostExplorer
+		wantCoveragePct float64
+		wantSPUsedPct   float64
+		wantRIPct       [2]float64
+		wantSavings     float64
+		wantUnavailable []string
+	}{
+		{
+			name:            "every query answers",
+			api:             &fakeCostExplorer{coverage: twoMonths, spCommitment: "216", spUtilization: "95", riCoverage: "40", riUtilization: "99.5"},
+			wantCoveragePct: 30,
+			wantSPUsedPct:   95,
+			wantRIPct:       [2]float64{40, 99.5},
+			wantSavings:     uncovered * computeSavingsPlanDiscount,
+		},
+		{
+			name:            "existing Savings Plans underused",
+			api:             &fakeCostExplorer{coverage: twoMonths, spCommitment: "216", spUtilization: "80", riCoverage: "40", riUtilization: "99.5"},
+			wantCoveragePct: 30,
+			wantSPUsedPct:   80,
+			wantRIPct:       [2]float64{40, 99.5},
+		},
+		{
+			name:        "no Savings Plans or reservations",
+			api:         &fakeCostExplorer{coverage: []ceTypes.SavingsPlansCoverage{coverageMonth("0", "700")}, spUtilizationErr: errDataUnavailable, riCoverageErr: errDataUnavailable, riUtilizationErr: errDataUnavailable},
+			wantSavings: 700 * commitmentSafetyMargin * computeSavingsPlanDiscount,
+		},
+		{
+			name:        "commitment capped by the on-demand spend Cost Explorer saw",
+			api:         &fakeCostExplorer{coverage: []ceTypes.SavingsPlansCoverage{coverageMonth("0", "100")}, spUtilizationErr: errDataUnavailable, riCoverage: "0", riUtilization: "0"},
+			wantSavings: 100 * commitmentSafetyMargin * computeSavingsPlanDiscount,
+		},
+		{
+			name: "savings under a dollar",
+			api:  &fakeCostExplorer{coverage: []ceTypes.SavingsPlansCoverage{coverageMonth("0", "3")}, spUtilizationErr: errDataUnavailable, riCoverage: "0", riUtilization: "0"},
+		},
+		{
+			name:            "coverage denied",
+			api:             &fakeCostExplorer{coverageErr: errAccessDenied, spCommitment: "216", spUtilization: "95", riCoverage: "40", riUtilization: "99.5"},
+			wantSPUsedPct:   95,
+			wantRIPct:       [2]float64{40, 99.5},
+			wantUnavailable: []string{"ce:GetSavingsPlansCoverage (permission denied)"},
+		},
+		{
+			// The first page does not count on its own
+			name:            "coverage fails on a later page",
+			api:             &fakeCostExplorer{coverage: twoMonths, coverageErr: errors.New("ThrottlingException"), coverageErrOnPage: 1, spCommitment: "216", spUtilization: "95", riCoverage: "40", riUtilization: "99.5"},
+			wantSPUsedPct:   95,
+			wantRIPct:       [2]float64{40, 99.5},
+			wantUnavailable: []string{"ce:GetSavingsPlansCoverage (ThrottlingException)"},
+		},
+		{
+			name:            "reservations denied",
+			api:             &fakeCostExplorer{coverage: twoMonths, spCommitment: "216", spUtilization: "95", riCoverageErr: errAccessDenied, riUtilizationErr: errAccessDenied},
+			wantCoveragePct: 30,
+			wantSPUsedPct:   95,
+			wantSavings:     uncovered * computeSavingsPlanDiscount,
+			wantUnavailable: []string{"ce:GetReservationCoverage (permission denied)", "ce:GetReservationUtilization (permission denied)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coverage, err := FetchCommitmentCoverage(context.Background(), tt.api, commitmentReport())
+			if err != nil {
+				t.Fatalf("FetchCommitmentCoverage() error = %v", err)
+			}
+			figures := []struct {
+				name      string
+				got, want float64
+			}{
+				{"CoveragePct", coverage.CoveragePct, tt.wantCoveragePct},
+				{"SavingsPlansUtilizationPct", coverage.SavingsPlansUtilizationPct, tt.wantSPUsedPct},
+				{"ReservationCoveragePct", coverage.ReservationCoveragePct, tt.wantRIPct[0]},
+				{"ReservationUtilizationPct", coverage.ReservationUtilizationPct, tt.wantRIPct[1]},
+				{"SteadyStateCost", coverage.SteadyStateCost, 800},
+				{"ProjectedMonthlySavings", coverage.ProjectedMonthlySavings, tt.wantSavings},
+				{"AdditionalHourlyCommitment", coverage.AdditionalHourlyCommitment, tt.wantSavings / computeSavingsPlanDiscount * (1 - computeSavingsPlanDiscount) / hoursPerMonth},
+			}
+			for _, figure := range figures {
+				if math.Abs(figure.got-figure.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", figure.name, figure.got, figure.want)
+				}
+			}
+			if fmt.Sprint(coverage.Unavailable) != fmt.Sprint(tt.wantUnavailable) {
+				t.Errorf("Unavailable = %q, want %q", coverage.Unavailable, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
+func TestFetchCommitmentCoverageUnavailable(t *testing.T) {
+	api := &fakeCostExplorer{coverageErr: errAccessDenied, spUtilizationErr: errAccessDenied, riCoverageErr: errAccessDenied, riUtilizationErr: errors.New("dial tcp: lookup ce.us-east-1.amazonaws.com: no such host")}
+
+	coverage, err := FetchCommitmentCoverage(context.Background(), api, commitmentReport())
+	if err == nil {
+		t.Fatalf("FetchCommitmentCoverage() = %+v, want an error when every query fails", coverage)
+	}
+	if !strings.Contains(err.Error(), "ce:GetSavingsPlansUtilization (permission denied)") || !strings.Contains(err.Error(), "no such host") {
+		t.Errorf("FetchCommitmentCoverage() error = %v, want every failed query named", err)
+	}
+}
+
+func TestPrintCommitmentCoverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		api     *fakeCostExplorer
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "commitment",
+			api:  &fakeCostExplorer{coverage: []ceTypes.SavingsPlansCoverage{coverageMonth("300", "700")}, spCommitment: "216", spUtilization: "95", riCoverage: "40", riUtilization: "99.5"},
+			want: []string{
+				"• Compute spend covered by Savings Plans: 30.0% ($300.00 of $1000.00)",
+				"• Savings Plans utilization: 95.0% of $216.00 committed",
+				"• Reserved instance hours: 40.0% covered, 99.5% of reservations used",
+				"• Steady-state EC2 usage in this scan: $800.00 per month after optimization",
+				"• Safe additional commitment: $0.448/hour Compute Savings Plan, saving about $125.44 per month",
+			},
+		},
+		{
+			name: "underused",
+			api:  &fakeCostExplorer{coverage: []ceTypes.SavingsPlansCoverage{coverageMonth("300", "700")}, spCommitment: "216", spUtilization: "80", riCoverageErr: errDataUnavailable, riUtilizationErr: errDataUnavailable},
+			want: []string{"• No additional commitment: existing Savings Plans are only 80.0% used"},
+		},
+		{
+			name:    "coverage denied",
+			api:     &fakeCostExplorer{coverageErr: errAccessDenied, spUtilizationErr: errDataUnavailable, riCoverageErr: errDataUnavailable, riUtilizationErr: errDataUnavailable},
+			want:    []string{"• Unavailable: ce:GetSavingsPlansCoverage (permission denied)"},
+			notWant: []string{"Compute spend covered", "No additional commitment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coverage, err := FetchCommitmentCoverage(context.Background(), tt.api, commitmentReport())
+			if err != nil {
+				t.Fatalf("FetchCommitmentCoverage() error = %v", err)
+			}
+			var buf bytes.Buffer
+			printCommitmentCoverage(&buf, coverage, false)
+			for _, line := range tt.want {
+				if !strings.Contains(buf.String(), line+"\n") {
+					t.Errorf("output is missing %q:\n%s", line, buf.String())
+				}
+			}
+			for _, text := range tt.notWant {
+				if strings.Contains(buf.String(), text) {
+					t.Errorf("output has %q:\n%s", text, buf.String())
+				}
+			}
+		})
+	}
+}
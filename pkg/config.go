@@ -1,28 +1,648 @@
 package pkg
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for the CLI settings a configuration file or flag may change
+const (
+	DefaultAPIURL     = "https://8tse26l4fi.execute-api.eu-west-1.amazonaws.com/analyze"
+	DefaultAPITimeout = 60 // seconds
+	DefaultScanLimit  = 10
+)
+
+// DefaultScanResources are the resource types scanned when none are configured
+var DefaultScanResources = []string{"ec2", "s3", "rds"}
+
+// Configuration file formats, chosen by file extension
+const (
+	ConfigFormatJSON = "json"
+	ConfigFormatYAML = "yaml" // .yaml or .yml
+)
+
+// localConfigFiles are looked for in the working directory when no configuration file is named
+var localConfigFiles = []string{"greenops.json", "greenops.yaml", "greenops.yml"}
+
+// DefaultMetricsPeriodDays is the CloudWatch lookback window used when none is configured
+const DefaultMetricsPeriodDays = 7
+
+// DefaultSnapshotMinAgeDays is the age after which an orphaned snapshot is reported when none is configured
+const DefaultSnapshotMinAgeDays = 90
+
+// Bedrock models used by --local analysis when none are configured
+const (
+	DefaultGenModelID   = "eu.anthropic.claude-3-7-sonnet-20250219-v1:0"
+	DefaultEmbedModelID = "amazon.titan-embed-text-v2:0"
+)
+
+// EffectivePeriodDays returns days, or DefaultMetricsPeriodDays when days is not set.
+// Payloads from older clients carry no period, so they fall back to the default too.
+func EffectivePeriodDays(days int) int {
+	if days <= 0 {
+		return DefaultMetricsPeriodDays
+	}
+	return days
+}
+
 // Config holds the application configuration
 type Config struct {
 	API struct {
-		URL     string `json:"url"`
-		Timeout int    `json:"timeout"`
-	} `json:"api"`
+		URL     string `json:"url" yaml:"url"`
+		Timeout int    `json:"timeout" yaml:"timeout"`
+		Key     string `json:"key,omitempty" yaml:"key,omitempty"` // sent as x-api-key; GREENOPS_API_KEY or --api-key override it
+	} `json:"api" yaml:"api"`
 
 	AWS struct {
-		Region  string `json:"region"`
-		Profile string `json:"profile"`
-	} `json:"aws"`
+		Region  string   `json:"region" yaml:"region"`
+		Regions []string `json:"regions" yaml:"regions"` // Scan these regions instead of Region; "all" enumerates every enabled region
+		Profile string   `json:"profile" yaml:"profile"`
+	} `json:"aws" yaml:"aws"`
 
 	Scan struct {
-		Resources []string `json:"resources"`
-		Limit     int      `json:"limit"`
+		Resources []string       `json:"resources" yaml:"resources"`
+		Limit     int            `json:"limit" yaml:"limit"`
+		Limits    map[string]int `json:"limits,omitempty" yaml:"limits,omitempty"` // caps single resource types within Limit, e.g. {"s3": 5}
 		Metrics   struct {
-			PeriodDays int `json:"period_days"`
-		} `json:"metrics"`
-	} `json:"scan"`
+			PeriodDays int `json:"period_days" yaml:"period_days"`
+		} `json:"metrics" yaml:"metrics"`
+		Snapshots struct {
+			MinAgeDays int `json:"min_age_days" yaml:"min_age_days"` // report orphaned snapshots older than this
+		} `json:"snapshots" yaml:"snapshots"`
+		// TagFilters entries are "key=value" or "key" (any value)
+		TagFilters struct {
+			Include []string `json:"include" yaml:"include"`
+			Exclude []string `json:"exclude" yaml:"exclude"`
+		} `json:"tag_filters" yaml:"tag_filters"`
+		Thresholds IdleThresholds `json:"thresholds" yaml:"thresholds"`
+	} `json:"scan" yaml:"scan"`
+
+	// Bedrock models and prompts for --local analysis, which calls Bedrock from the CLI instead of the API
+	Bedrock struct {
+		Model      string   `json:"model" yaml:"model"`             // generation model or inference profile ID/ARN
+		EmbedModel string   `json:"embed_model" yaml:"embed_model"` // embedding model ID
+		PromptsDir string   `json:"prompts_dir" yaml:"prompts_dir"` // prompt templates replacing the built-in ones
+		FocusAreas []string `json:"focus_areas" yaml:"focus_areas"` // what the analyses should pay particular attention to
+		// AnalysisFormat is AnalysisFormatMarkdown, or AnalysisFormatJSON to have the model return a JSON object
+		AnalysisFormat string `json:"analysis_format" yaml:"analysis_format"`
+	} `json:"bedrock" yaml:"bedrock"`
+
+	// Pricing selects where EC2 and RDS prices come from: "bundled" (default) or "live" for the AWS Pricing API
+	Pricing struct {
+		Mode string `json:"mode" yaml:"mode"`
+	} `json:"pricing" yaml:"pricing"`
+
+	// CostExplorer attaches the last 30 days of actual spend to EC2, RDS and S3 resources,
+	// matched by a cost allocation tag (Name unless set)
+	CostExplorer struct {
+		Enabled bool   `json:"enabled" yaml:"enabled"`
+		TagKey  string `json:"tag_key" yaml:"tag_key"`
+	} `json:"cost_explorer" yaml:"cost_explorer"`
+
+	// Commitments adds the Savings Plans and reservation coverage of the last 30 days, and the
+	// additional commitment the scan's steady-state usage would carry, to the text report
+	Commitments struct {
+		Enabled bool `json:"enabled" yaml:"enabled"`
+	} `json:"commitments" yaml:"commitments"`
+
+	// Organization scans several accounts with --org, assuming RoleName in each: Accounts, or
+	// every active account of the organization when empty, MaxConcurrent at a time
+	Organization struct {
+		RoleName      string   `json:"role_name" yaml:"role_name"`
+		Accounts      []string `json:"accounts" yaml:"accounts"`
+		MaxConcurrent int      `json:"max_concurrent" yaml:"max_concurrent"`
+	} `json:"organization" yaml:"organization"`
+
+	// Estimate is the analysis estimate shown before resources are analyzed: a run estimated
+	// to cost more than MaxCost asks for confirmation first, and ItemSeconds is the time per
+	// item the ETA assumes until runs recorded in the history say otherwise
+	Estimate struct {
+		MaxCost     float64 `json:"max_cost" yaml:"max_cost"`         // USD of Bedrock usage, 0 never asks
+		ItemSeconds float64 `json:"item_seconds" yaml:"item_seconds"` // seconds per analyzed item
+	} `json:"estimate" yaml:"estimate"`
+
+	// CI thresholds; a run whose potential monthly savings exceed either one, or with at least
+	// FailOnIdle idle EC2 instances, exits with code 2
+	CI struct {
+		FailOnSavings float64 `json:"fail_on_savings" yaml:"fail_on_savings"` // USD per month, 0 disables
+		FailOnCO2     float64 `json:"fail_on_co2" yaml:"fail_on_co2"`         // kg CO2e per month, 0 disables
+		FailOnIdle    int     `json:"fail_on_idle" yaml:"fail_on_idle"`       // idle instances, 0 disables
+	} `json:"ci" yaml:"ci"`
 
 	Output struct {
-		Colors    bool   `json:"colors"`
-		Format    string `json:"format"`
-		Verbosity string `json:"verbosity"`
-	} `json:"output"`
+		Colors    bool   `json:"colors" yaml:"colors"`
+		Format    string `json:"format" yaml:"format"`
+		Verbosity string `json:"verbosity" yaml:"verbosity"`
+		Sort      string `json:"sort" yaml:"sort"`
+	} `json:"output" yaml:"output"`
+}
+
+// DefaultConfig returns the configuration used for every setting no file, environment
+// variable or flag changes
+func DefaultConfig() *Config {
+	cfg := &Config{}
+	cfg.API.URL = DefaultAPIURL
+	cfg.API.Timeout = DefaultAPITimeout
+	// Empty lists rather than nil, so templates written by --init show [] in JSON as in YAML
+	cfg.AWS.Regions = []string{}
+	cfg.Scan.TagFilters.Include = []string{}
+	cfg.Scan.TagFilters.Exclude = []string{}
+	cfg.Bedrock.FocusAreas = []string{}
+	cfg.Organization.Accounts = []string{}
+	cfg.Scan.Limit = DefaultScanLimit
+	cfg.Scan.Resources = append([]string(nil), DefaultScanResources...)
+	cfg.Scan.Metrics.PeriodDays = DefaultMetricsPeriodDays
+	cfg.Scan.Snapshots.MinAgeDays = DefaultSnapshotMinAgeDays
+	cfg.Scan.Thresholds = IdleThresholds{}.withDefaults()
+	cfg.Bedrock.Model = DefaultGenModelID
+	cfg.Bedrock.EmbedModel = DefaultEmbedModelID
+	cfg.Bedrock.AnalysisFormat = AnalysisFormatMarkdown
+	cfg.Pricing.Mode = PricingModeBundled
+	cfg.Organization.MaxConcurrent = DefaultOrgMaxConcurrent
+	cfg.Estimate.ItemSeconds = DefaultEstimateItemSeconds
+	cfg.Output.Colors = true
+	cfg.Output.Format = "text"
+	cfg.Output.Verbosity = VerbosityNormal
+	cfg.Output.Sort = SortBySavings
+	return cfg
+}
+
+// DefaultConfigPath returns ~/.greenops/config.json, or config.yaml for ConfigFormatYAML,
+// where --init writes its file
+func DefaultConfigPath(format string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".greenops", "config."+format), nil
+}
+
+// ConfigFileFormat returns ConfigFormatYAML for .yaml and .yml paths and ConfigFormatJSON otherwise
+func ConfigFileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfigFormatYAML
+	}
+	return ConfigFormatJSON
+}
+
+// ConfigSources says where LoadConfig looks for settings
+type ConfigSources struct {
+	Path   string              // named with --config; empty searches the default locations
+	Getenv func(string) string // os.Getenv unless set
+	Flags  func(*Config) error // applies the command-line flags the user set, last
+}
+
+// FindConfigFile returns the configuration file to load: path if set, then $GREENOPS_CONFIG,
+// then ./greenops.json, .yaml or .yml, then ~/.greenops/config.json, .yaml or .yml. A file
+// named explicitly must exist; the default locations are skipped when they do not. It
+// returns "" when no file is found.
+func FindConfigFile(path string, getenv func(string) string) (string, error) {
+	if path == "" {
+		path = getenv("GREENOPS_CONFIG")
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("failed to read config file: %w", err)
+		}
+		return path, nil
+	}
+
+	candidates := append([]string(nil), localConfigFiles...)
+	if home, err := DefaultConfigPath(ConfigFormatJSON); err == nil {
+		base := strings.TrimSuffix(home, ".json")
+		candidates = append(candidates, home, base+".yaml", base+".yml")
+	}
+	for _, candidate := range candidates {
+		_, err := os.Stat(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	return "", nil
+}
+
+// LoadConfig resolves the configuration from, in increasing order of precedence: the
+// defaults, the configuration file found by FindConfigFile, GREENOPS_* environment variables
+// and the command-line flags. Settings a source leaves empty keep their defaults. It also
+// returns the path of the file that was loaded, or "" when none was.
+func LoadConfig(src ConfigSources) (*Config, string, error) {
+	getenv := src.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	path, err := FindConfigFile(src.Path, getenv)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Decoding over the defaults keeps them for every key the file leaves out
+		if err := decodeConfig(path, data, cfg); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := applyEnvOverrides(cfg, getenv); err != nil {
+		return nil, "", err
+	}
+	if src.Flags != nil {
+		if err := src.Flags(cfg); err != nil {
+			return nil, "", err
+		}
+	}
+
+	fillConfigDefaults(cfg)
+	return cfg, path, nil
+}
+
+// applyEnvOverrides sets the values given by GREENOPS_* environment variables
+func applyEnvOverrides(cfg *Config, getenv func(string) string) error {
+	stringVars := map[string]*string{
+		"GREENOPS_API_URL":         &cfg.API.URL,
+		"GREENOPS_API_KEY":         &cfg.API.Key,
+		"GREENOPS_REGION":          &cfg.AWS.Region,
+		"GREENOPS_PROFILE":         &cfg.AWS.Profile,
+		"GREENOPS_FORMAT":          &cfg.Output.Format,
+		"GREENOPS_SORT":            &cfg.Output.Sort,
+		"GREENOPS_MODEL":           &cfg.Bedrock.Model,
+		"GREENOPS_ANALYSIS_FORMAT": &cfg.Bedrock.AnalysisFormat,
+	}
+	for name, field := range stringVars {
+		if value := getenv(name); value != "" {
+			*field = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"GREENOPS_API_TIMEOUT":  &cfg.API.Timeout,
+		"GREENOPS_LIMIT":        &cfg.Scan.Limit,
+		"GREENOPS_METRICS_DAYS": &cfg.Scan.Metrics.PeriodDays,
+	}
+	for name, field := range intVars {
+		value := getenv(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: expected a whole number", name, value)
+		}
+		*field = n
+	}
+
+	listVars := map[string]*[]string{
+		"GREENOPS_REGIONS":   &cfg.AWS.Regions,
+		"GREENOPS_RESOURCES": &cfg.Scan.Resources,
+	}
+	for name, field := range listVars {
+		if value := getenv(name); value != "" {
+			*field = SplitList(value)
+		}
+	}
+	return nil
+}
+
+// fillConfigDefaults restores the defaults of settings a file or flag left empty. Negative
+// numbers are kept for Validate to report.
+func fillConfigDefaults(cfg *Config) {
+	defaults := DefaultConfig()
+	if cfg.API.URL == "" {
+		cfg.API.URL = defaults.API.URL
+	}
+	if cfg.API.Timeout == 0 {
+		cfg.API.Timeout = defaults.API.Timeout
+	}
+	if len(cfg.Scan.Resources) == 0 {
+		cfg.Scan.Resources = defaults.Scan.Resources
+	}
+	if cfg.Scan.Metrics.PeriodDays == 0 {
+		cfg.Scan.Metrics.PeriodDays = defaults.Scan.Metrics.PeriodDays
+	}
+	if cfg.Scan.Snapshots.MinAgeDays == 0 {
+		cfg.Scan.Snapshots.MinAgeDays = defaults.Scan.Snapshots.MinAgeDays
+	}
+	if cfg.Scan.Thresholds.CPUPct == 0 {
+		cfg.Scan.Thresholds.CPUPct = defaults.Scan.Thresholds.CPUPct
+	}
+	if cfg.Scan.Thresholds.NetworkBytesPerSec == 0 {
+		cfg.Scan.Thresholds.NetworkBytesPerSec = defaults.Scan.Thresholds.NetworkBytesPerSec
+	}
+	if cfg.Bedrock.Model == "" {
+		cfg.Bedrock.Model = defaults.Bedrock.Model
+	}
+	if cfg.Bedrock.EmbedModel == "" {
+		cfg.Bedrock.EmbedModel = defaults.Bedrock.EmbedModel
+	}
+	if cfg.Bedrock.AnalysisFormat == "" {
+		cfg.Bedrock.AnalysisFormat = defaults.Bedrock.AnalysisFormat
+	}
+	if cfg.Pricing.Mode == "" {
+		cfg.Pricing.Mode = defaults.Pricing.Mode
+	}
+	if cfg.Organization.MaxConcurrent == 0 {
+		cfg.Organization.MaxConcurrent = defaults.Organization.MaxConcurrent
+	}
+	if cfg.Estimate.ItemSeconds == 0 {
+		cfg.Estimate.ItemSeconds = defaults.Estimate.ItemSeconds
+	}
+	if cfg.Output.Format == "" {
+		cfg.Output.Format = defaults.Output.Format
+	}
+	if cfg.Output.Verbosity == "" {
+		cfg.Output.Verbosity = defaults.Output.Verbosity
+	}
+	if cfg.Output.Sort == "" {
+		cfg.Output.Sort = defaults.Output.Sort
+	}
+}
+
+// Validate checks the resolved configuration and returns ValidationErrors listing every
+// problem, keyed by config file key, or nil when it is valid
+func (c *Config) Validate() error {
+	var problems ValidationErrors
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if u, err := url.Parse(c.API.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("api.url", "%q is not an http or https URL", c.API.URL)
+	}
+	if c.API.Timeout <= 0 {
+		add("api.timeout", "must be a positive number of seconds, got %d", c.API.Timeout)
+	}
+
+	for _, resType := range c.Scan.Resources {
+		if !slices.Contains(ScanResourceTypes, resType) {
+			add("scan.resources", "unknown resource type %q (expected %s)", resType, strings.Join(ScanResourceTypes, ", "))
+		}
+	}
+	if c.Scan.Limit < 0 {
+		add("scan.limit", "must not be negative, got %d (0 means no limit)", c.Scan.Limit)
+	}
+	for _, resType := range slices.Sorted(maps.Keys(c.Scan.Limits)) {
+		limit := c.Scan.Limits[resType]
+		if !slices.Contains(ScanResourceTypes, resType) {
+			add("scan.limits", "unknown resource type %q", resType)
+		} else if limit <= 0 {
+			add("scan.limits", "limit for %s must be positive, got %d", resType, limit)
+		}
+	}
+	if c.Scan.Metrics.PeriodDays <= 0 {
+		add("scan.metrics.period_days", "must be a positive number of days, got %d", c.Scan.Metrics.PeriodDays)
+	}
+	if c.Scan.Snapshots.MinAgeDays <= 0 {
+		add("scan.snapshots.min_age_days", "must be a positive number of days, got %d", c.Scan.Snapshots.MinAgeDays)
+	}
+	if c.Scan.Thresholds.CPUPct <= 0 || c.Scan.Thresholds.CPUPct > 100 {
+		add("scan.thresholds.idle_cpu_pct", "must be a percentage above 0 and at most 100, got %g", c.Scan.Thresholds.CPUPct)
+	}
+	if c.Scan.Thresholds.NetworkBytesPerSec <= 0 {
+		add("scan.thresholds.idle_network_bytes_per_sec", "must be a positive number of bytes per second, got %g", c.Scan.Thresholds.NetworkBytesPerSec)
+	}
+	if _, err := ParseTagFilterSet(c.Scan.TagFilters.Include, c.Scan.TagFilters.Exclude); err != nil {
+		add("scan.tag_filters", "%v", err)
+	}
+
+	if c.Bedrock.AnalysisFormat != AnalysisFormatMarkdown && c.Bedrock.AnalysisFormat != AnalysisFormatJSON {
+		add("bedrock.analysis_format", "unsupported format %q (expected markdown or json)", c.Bedrock.AnalysisFormat)
+	}
+	if c.Pricing.Mode != PricingModeBundled && c.Pricing.Mode != PricingModeLive {
+		add("pricing.mode", "unsupported mode %q (expected bundled or live)", c.Pricing.Mode)
+	}
+	for _, accountID := range c.Organization.Accounts {
+		if !IsAccountID(accountID) {
+			add("organization.accounts", "%q is not a 12-digit AWS account ID", accountID)
+		}
+	}
+	if c.Organization.MaxConcurrent < 0 {
+		add("organization.max_concurrent", "must be a positive number of accounts, got %d", c.Organization.MaxConcurrent)
+	}
+	if c.Estimate.MaxCost < 0 {
+		add("estimate.max_cost", "must not be negative, got %g", c.Estimate.MaxCost)
+	}
+	if c.Estimate.ItemSeconds < 0 {
+		add("estimate.item_seconds", "must be a positive number of seconds, got %g", c.Estimate.ItemSeconds)
+	}
+	if c.CI.FailOnSavings < 0 {
+		add("ci.fail_on_savings", "must not be negative, got %g", c.CI.FailOnSavings)
+	}
+	if c.CI.FailOnCO2 < 0 {
+		add("ci.fail_on_co2", "must not be negative, got %g", c.CI.FailOnCO2)
+	}
+	if c.CI.FailOnIdle < 0 {
+		add("ci.fail_on_idle", "must not be negative, got %d", c.CI.FailOnIdle)
+	}
+
+	switch c.Output.Format {
+	case "text", "json", "csv", "html", "markdown":
+	default:
+		add("output.format", "unsupported format %q (expected text, json, csv, html or markdown)", c.Output.Format)
+	}
+	switch c.Output.Verbosity {
+	case VerbosityQuiet, VerbosityNormal, VerbosityDetailed:
+	default:
+		add("output.verbosity", "unsupported verbosity %q (expected quiet, normal or detailed)", c.Output.Verbosity)
+	}
+	switch c.Output.Sort {
+	case SortBySavings, SortByCO2, SortByCost, SortByName:
+	default:
+		add("output.sort", "unsupported sort order %q (expected savings, co2, cost or name)", c.Output.Sort)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// SplitList splits a comma-separated setting such as a resource or region list, lowercasing
+// and trimming whitespace and dropping empty entries so "EC2, rds" scans both types
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// decodeConfig decodes a JSON or YAML configuration file over cfg, naming the offending
+// key when a value has the wrong type
+func decodeConfig(path string, data []byte, cfg *Config) error {
+	if ConfigFileFormat(path) == ConfigFormatYAML {
+		return decodeConfigYAML(path, data, cfg)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return fmt.Errorf("invalid config file %s: key %s: expected %s, got %s", path, typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeConfigYAML decodes a YAML configuration file over cfg
+func decodeConfigYAML(path string, data []byte, cfg *Config) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // empty or comments only
+	}
+
+	err := doc.Decode(cfg)
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		return nil
+	}
+
+	// yaml.v3 reports the line of each bad value; name the key found there
+	problems := make([]string, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		problems[i] = msg
+		var line int
+		if _, scanErr := fmt.Sscanf(msg, "line %d:", &line); scanErr == nil {
+			if key := yamlKeyAtLine(doc.Content[0], "", line); key != "" {
+				problems[i] = "key " + key + ": " + msg
+			}
+		}
+	}
+	return fmt.Errorf("invalid config file %s: %s", path, strings.Join(problems, "; "))
+}
+
+// yamlKeyAtLine returns the dotted path of the key whose value is on line, or ""
+func yamlKeyAtLine(node *yaml.Node, prefix string, line int) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			path := node.Content[i].Value
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			if found := yamlKeyAtLine(node.Content[i+1], path, line); found != "" {
+				return found
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if found := yamlKeyAtLine(item, prefix, line); found != "" {
+				return found
+			}
+		}
+	default:
+		if node.Line == line {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// configComments document the keys of the YAML template written by --init
+var configComments = map[string]string{
+	"api":                     "GreenOps API the scanned resources are sent to",
+	"api.timeout":             "seconds per request",
+	"aws.region":              "region to scan; AWS_REGION or the profile's region when empty",
+	"aws.regions":             `scan these regions instead of region; ["all"] scans every enabled region`,
+	"aws.profile":             "shared config profile; AWS_PROFILE or default when empty",
+	"scan.resources":          "ec2, s3, rds, ebs, lambda, elb, network, dynamodb, elasticache, snapshots",
+	"scan.limit":              "resources analyzed in total, shared fairly across the types",
+	"scan.metrics":            "CloudWatch lookback window",
+	"scan.snapshots":          "report orphaned EBS snapshots older than this",
+	"scan.tag_filters":        `entries are "key=value" or "key" for any value`,
+	"scan.thresholds":         "EC2 instances below both are idle: CPU on every day, network in + out on average",
+	"bedrock":                 "models and prompts used by --local, which calls Bedrock from this machine",
+	"bedrock.prompts_dir":     "directory whose ec2.tmpl, s3.tmpl and rds.tmpl replace the built-in prompts",
+	"bedrock.focus_areas":     `e.g. ["graviton migration", "idle resources"]`,
+	"bedrock.analysis_format": "markdown, or json to have the model return a JSON object",
+	"pricing.mode":            "bundled, or live for the AWS Pricing API",
+	"cost_explorer":           "attach the last 30 days of actual spend, matched by tag_key (Name unless set)",
+	"commitments":             "Savings Plans and reservation coverage in the text report; needs ce:GetSavingsPlansCoverage, ce:GetSavingsPlansUtilization, ce:GetReservationCoverage and ce:GetReservationUtilization",
+	"organization":            "accounts scanned with --org by assuming role_name in each; every active account of the organization when accounts is empty",
+	"estimate":                "Bedrock cost and time estimate shown before analyzing",
+	"estimate.max_cost":       "ask for confirmation when the estimated cost exceeds this many USD; 0 never asks",
+	"estimate.item_seconds":   "seconds per item the estimate assumes until runs in the history were timed",
+	"ci":                      "exit with code 2 when potential monthly savings exceed these, or idle instances reach fail_on_idle; 0 disables",
+	"ci.fail_on_savings":      "USD per month",
+	"ci.fail_on_co2":          "kg CO2e per month",
+	"ci.fail_on_idle":         "idle EC2 instances",
+	"output.format":           "text, json, csv, html or markdown",
+	"output.verbosity":        "quiet, normal or detailed",
+	"output.sort":             "savings, co2, cost or name",
+}
+
+// MarshalConfig encodes a configuration as indented JSON or, for ConfigFormatYAML, as YAML
+// with a comment on the main keys
+func MarshalConfig(cfg *Config, format string) ([]byte, error) {
+	if format != ConfigFormatYAML {
+		return json.MarshalIndent(cfg, "", "  ")
+	}
+
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, err
+	}
+	commentYAMLKeys(&root, "")
+	doc := yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: "GreenOps CLI configuration. GREENOPS_* environment variables and flags override these values.",
+		Content:     []*yaml.Node{&root},
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// commentYAMLKeys attaches configComments to the keys of an encoded configuration
+func commentYAMLKeys(node *yaml.Node, prefix string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if comment, ok := configComments[path]; ok {
+			key.HeadComment = comment
+		}
+		commentYAMLKeys(node.Content[i+1], path)
+	}
 }
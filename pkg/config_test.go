@@ -0,0 +1,409 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes a configuration file named name to a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// envMap returns a Getenv reading from vars
+func envMap(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	// No file in the home directory may leak into the defaults
+	t.Setenv("HOME", t.TempDir())
+
+	file := writeConfigFile(t, "config.json", `{
+		"api": {"timeout": 30},
+		"aws": {"region": "eu-west-1"},
+		"scan": {"limit": 20, "resources": ["ec2"]},
+		"output": {"format": "json"}
+	}`)
+
+	tests := []struct {
+		name  string
+		path  string
+		env   map[string]string
+		flags func(*Config) error
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Scan.Limit != DefaultScanLimit || cfg.API.URL != DefaultAPIURL || cfg.Output.Format != "text" {
+					t.Errorf("limit %d, url %q, format %q; want the defaults", cfg.Scan.Limit, cfg.API.URL, cfg.Output.Format)
+				}
+			},
+		},
+		{
+			name: "file over defaults",
+			path: file,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Scan.Limit != 20 || cfg.AWS.Region != "eu-west-1" || cfg.Output.Format != "json" {
+					t.Errorf("limit %d, region %q, format %q; want the file's", cfg.Scan.Limit, cfg.AWS.Region, cfg.Output.Format)
+				}
+				if cfg.API.URL != DefaultAPIURL || cfg.Bedrock.Model != DefaultGenModelID {
+					t.Errorf("keys the file leaves out lost their defaults: url %q, model %q", cfg.API.URL, cfg.Bedrock.Model)
+				}
+			},
+		},
+		{
+			name: "file found through GREENOPS_CONFIG",
+			env:  map[string]string{"GREENOPS_CONFIG": file},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Scan.Limit != 20 {
+					t.Errorf("limit %d, want the file's 20", cfg.Scan.Limit)
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			path: file,
+			env:  map[string]string{"GREENOPS_LIMIT": "30", "GREENOPS_RESOURCES": "S3, rds", "GREENOPS_FORMAT": "csv"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Scan.Limit != 30 || cfg.Output.Format != "csv" {
+					t.Errorf("limit %d, format %q; want the environment's", cfg.Scan.Limit, cfg.Output.Format)
+				}
+				if fmt.Sprint(cfg.Scan.Resources) != "[s3 rds]" {
+					t.Errorf("resources %v, want [s3 rds]", cfg.Scan.Resources)
+				}
+				if cfg.AWS.Region != "eu-west-1" {
+					t.Errorf("region %q, want the file's where the environment sets none", cfg.AWS.Region)
+				}
+			},
+		},
+		{
+			name: "flags over environment",
+			path: file,
+			env:  map[string]string{"GREENOPS_LIMIT": "30", "GREENOPS_REGION": "us-east-1"},
+			flags: func(cfg *Config) error {
+				cfg.Scan.Limit = 40
+				return nil
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Scan.Limit != 40 {
+					t.Errorf("limit %d, want the flag's 40", cfg.Scan.Limit)
+				}
+				if cfg.AWS.Region != "us-east-1" {
+					t.Errorf("region %q, want the environment's where no flag is set", cfg.AWS.Region)
+				}
+			},
+		},
+		{
+			name: "flags cleared back to defaults",
+			path: file,
+			flags: func(cfg *Config) error {
+				cfg.Output.Format = ""
+				cfg.Scan.Resources = nil
+				return nil
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Output.Format != "text" || fmt.Sprint(cfg.Scan.Resources) != fmt.Sprint(DefaultScanResources) {
+					t.Errorf("format %q, resources %v; want the defaults", cfg.Output.Format, cfg.Scan.Resources)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, path, err := LoadConfig(ConfigSources{Path: tt.path, Getenv: envMap(tt.env), Flags: tt.flags})
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			wantPath := tt.path
+			if wantPath == "" {
+				wantPath = tt.env["GREENOPS_CONFIG"]
+			}
+			if path != wantPath {
+				t.Errorf("LoadConfig() path = %q, want %q", path, wantPath)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	flagErr := errors.New("bad flag")
+
+	tests := []struct {
+		name    string
+		path    string
+		env     map[string]string
+		flags   func(*Config) error
+		wantErr string
+	}{
+		{name: "missing named file", path: filepath.Join(t.TempDir(), "missing.json"), wantErr: "failed to read config file"},
+		{name: "missing GREENOPS_CONFIG file", env: map[string]string{"GREENOPS_CONFIG": filepath.Join(t.TempDir(), "missing.yaml")}, wantErr: "failed to read config file"},
+		{name: "malformed file", path: writeConfigFile(t, "bad.json", `{"scan": `), wantErr: "failed to parse config file"},
+		{name: "wrong type in file", path: writeConfigFile(t, "typed.json", `{"scan": {"limit": "ten"}}`), wantErr: "key scan.limit"},
+		{name: "non-numeric environment", env: map[string]string{"GREENOPS_API_TIMEOUT": "soon"}, wantErr: "invalid GREENOPS_API_TIMEOUT"},
+		{name: "flag error", flags: func(*Config) error { return flagErr }, wantErr: "bad flag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadConfig(ConfigSources{Path: tt.path, Getenv: envMap(tt.env), Flags: tt.flags})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The same settings written as JSON and as YAML must load into identical configurations
+func TestLoadConfigJSONAndYAMLAgree(t *testing.T) {
+	jsonFile := writeConfigFile(t, "config.json", `{
+		"api": {"url": "https://api.example.com/analyze", "timeout": 45, "key": "k-1"},
+		"aws": {"region": "eu-west-1", "regions": ["eu-west-1", "us-east-1"], "profile": "prod"},
+		"scan": {
+			"resources": ["ec2", "s3", "lambda"],
+			"limit": 25,
+			"limits": {"s3": 5},
+			"metrics": {"period_days": 14},
+			"snapshots": {"min_age_days": 30},
+			"tag_filters": {"include": ["env=prod"], "exclude": ["keep"]},
+			"thresholds": {"idle_cpu_pct": 3.5, "idle_network_bytes_per_sec": 2048}
+		},
+		"bedrock": {"model": "m-1", "embed_model": "e-1", "prompts_dir": "prompts", "focus_areas": ["graviton"], "analysis_format": "json"},
+		"pricing": {"mode": "live"},
+		"cost_explorer": {"enabled": true, "tag_key": "App"},
+		"commitments": {"enabled": true},
+		"organization": {"role_name": "Audit", "accounts": ["123456789012"], "max_concurrent": 2},
+		"estimate": {"max_cost": 1.5, "item_seconds": 4},
+		"ci": {"fail_on_savings": 100, "fail_on_co2": 20, "fail_on_idle": 3},
+		"output": {"colors": false, "format": "markdown", "verbosity": "detailed", "sort": "co2"}
+	}`)
+	yamlFile := writeConfigFile(t, "config.yaml", `
+api:
+  url: https://api.example.com/analyze
+  timeout: 45
+  key: k-1
+aws:
+  region: eu-west-1
+  regions: [eu-west-1, us-east-1]
+  profile: prod
+scan:
+  resources: [ec2, s3, lambda]
+  limit: 25
+  limits:
+    s3: 5
+  metrics:
+    period_days: 14
+  snapshots:
+    min_age_days: 30
+  tag_filters:
+    include: ["env=prod"]
+    exclude: [keep]
+  thresholds:
+    idle_cpu_pct: 3.5
+    idle_network_bytes_per_sec: 2048
+bedrock:
+  model: m-1
+  embed_model: e-1
+  prompts_dir: prompts
+  focus_areas: [graviton]
+  analysis_format: json
+pricing:
+  mode: live
+cost_explorer:
+  enabled: true
+  tag_key: App
+commitments:
+  enabled: true
+organization:
+  role_name: Audit
+  accounts: ["123456789012"]
+  max_concurrent: 2
+estimate:
+  max_cost: 1.5
+  item_seconds: 4
+ci:
+  fail_on_savings: 100
+  fail_on_co2: 20
+  fail_on_idle: 3
+output:
+  colors: false
+  format: markdown
+  verbosity: detailed
+  sort: co2
+`)
+
+	fromJSON, _, err := LoadConfig(ConfigSources{Path: jsonFile, Getenv: envMap(nil)})
+	if err != nil {
+		t.Fatalf("LoadConfig(%s) error = %v", jsonFile, err)
+	}
+	fromYAML, _, err := LoadConfig(ConfigSources{Path: yamlFile, Getenv: envMap(nil)})
+	if err != nil {
+		t.Fatalf("LoadConfig(%s) error = %v", yamlFile, err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("JSON and YAML configurations differ:\njson: %+v\nyaml: %+v", *fromJSON, *fromYAML)
+	}
+	if err := fromJSON.Validate(); err != nil {
+		t.Errorf("Validate() error = %v for the sample configuration", err)
+	}
+	if fromYAML.Output.Colors || fromYAML.Scan.Limits["s3"] != 5 {
+		t.Errorf("colors %v, s3 limit %d; want false and 5", fromYAML.Output.Colors, fromYAML.Scan.Limits["s3"])
+	}
+}
+
+// A configuration written by --init in either format loads back unchanged
+func TestMarshalConfigRoundTrip(t *testing.T) {
+	want := DefaultConfig()
+	want.AWS.Region = "eu-west-1"
+	want.Scan.Limits = map[string]int{"ec2": 3}
+	want.Bedrock.FocusAreas = []string{"idle resources"}
+
+	for _, format := range []string{ConfigFormatJSON, ConfigFormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			data, err := MarshalConfig(want, format)
+			if err != nil {
+				t.Fatalf("MarshalConfig() error = %v", err)
+			}
+			path := writeConfigFile(t, "config."+format, string(data))
+
+			got, _, err := LoadConfig(ConfigSources{Path: path, Getenv: envMap(nil)})
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("loaded %+v, want %+v", *got, *want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "wrong type names the key", content: "scan:\n  limit: ten\n", wantErr: "key scan.limit"},
+		{name: "nested key", content: "scan:\n  metrics:\n    period_days: [7]\n", wantErr: "key scan.metrics.period_days"},
+		{name: "malformed", content: "scan: [\n", wantErr: "failed to parse config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yml", tt.content)
+			_, _, err := LoadConfig(ConfigSources{Path: path, Getenv: envMap(nil)})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// An empty or comment-only file keeps the defaults
+	path := writeConfigFile(t, "empty.yaml", "# nothing set yet\n")
+	cfg, _, err := LoadConfig(ConfigSources{Path: path, Getenv: envMap(nil)})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v for a comment-only file", err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("comment-only file loaded %+v, want the defaults", *cfg)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(cfg *Config)
+		wantField string // "" when the configuration is valid
+	}{
+		{name: "defaults", change: func(cfg *Config) {}},
+		{name: "http URL", change: func(cfg *Config) { cfg.API.URL = "http://localhost:8080/analyze" }},
+		{name: "URL without scheme", change: func(cfg *Config) { cfg.API.URL = "api.example.com" }, wantField: "api.url"},
+		{name: "URL with other scheme", change: func(cfg *Config) { cfg.API.URL = "ftp://api.example.com" }, wantField: "api.url"},
+		{name: "URL without host", change: func(cfg *Config) { cfg.API.URL = "https://" }, wantField: "api.url"},
+		{name: "zero timeout", change: func(cfg *Config) { cfg.API.Timeout = 0 }, wantField: "api.timeout"},
+		{name: "unknown resource", change: func(cfg *Config) { cfg.Scan.Resources = []string{"ec2", "vpc"} }, wantField: "scan.resources"},
+		{name: "no limit", change: func(cfg *Config) { cfg.Scan.Limit = 0 }},
+		{name: "negative limit", change: func(cfg *Config) { cfg.Scan.Limit = -1 }, wantField: "scan.limit"},
+		{name: "type limit", change: func(cfg *Config) { cfg.Scan.Limits = map[string]int{"s3": 5} }},
+		{name: "type limit for unknown type", change: func(cfg *Config) { cfg.Scan.Limits = map[string]int{"vpc": 5} }, wantField: "scan.limits"},
+		{name: "zero type limit", change: func(cfg *Config) { cfg.Scan.Limits = map[string]int{"s3": 0} }, wantField: "scan.limits"},
+		{name: "zero metrics period", change: func(cfg *Config) { cfg.Scan.Metrics.PeriodDays = 0 }, wantField: "scan.metrics.period_days"},
+		{name: "negative snapshot age", change: func(cfg *Config) { cfg.Scan.Snapshots.MinAgeDays = -5 }, wantField: "scan.snapshots.min_age_days"},
+		{name: "CPU threshold of 100", change: func(cfg *Config) { cfg.Scan.Thresholds.CPUPct = 100 }},
+		{name: "CPU threshold above 100", change: func(cfg *Config) { cfg.Scan.Thresholds.CPUPct = 101 }, wantField: "scan.thresholds.idle_cpu_pct"},
+		{name: "zero CPU threshold", change: func(cfg *Config) { cfg.Scan.Thresholds.CPUPct = 0 }, wantField: "scan.thresholds.idle_cpu_pct"},
+		{name: "zero network threshold", change: func(cfg *Config) { cfg.Scan.Thresholds.NetworkBytesPerSec = 0 }, wantField: "scan.thresholds.idle_network_bytes_per_sec"},
+		{name: "tag filter without key", change: func(cfg *Config) { cfg.Scan.TagFilters.Exclude = []string{"=prod"} }, wantField: "scan.tag_filters"},
+		{name: "JSON analysis format", change: func(cfg *Config) { cfg.Bedrock.AnalysisFormat = AnalysisFormatJSON }},
+		{name: "unknown analysis format", change: func(cfg *Config) { cfg.Bedrock.AnalysisFormat = "html" }, wantField: "bedrock.analysis_format"},
+		{name: "live pricing", change: func(cfg *Config) { cfg.Pricing.Mode = PricingModeLive }},
+		{name: "unknown pricing mode", change: func(cfg *Config) { cfg.Pricing.Mode = "spot" }, wantField: "pricing.mode"},
+		{name: "account ID", change: func(cfg *Config) { cfg.Organization.Accounts = []string{"123456789012"} }},
+		{name: "short account ID", change: func(cfg *Config) { cfg.Organization.Accounts = []string{"12345"} }, wantField: "organization.accounts"},
+		{name: "negative concurrency", change: func(cfg *Config) { cfg.Organization.MaxConcurrent = -1 }, wantField: "organization.max_concurrent"},
+		{name: "negative max cost", change: func(cfg *Config) { cfg.Estimate.MaxCost = -0.5 }, wantField: "estimate.max_cost"},
+		{name: "negative item seconds", change: func(cfg *Config) { cfg.Estimate.ItemSeconds = -1 }, wantField: "estimate.item_seconds"},
+		{name: "negative savings threshold", change: func(cfg *Config) { cfg.CI.FailOnSavings = -1 }, wantField: "ci.fail_on_savings"},
+		{name: "negative CO2 threshold", change: func(cfg *Config) { cfg.CI.FailOnCO2 = -1 }, wantField: "ci.fail_on_co2"},
+		{name: "negative idle threshold", change: func(cfg *Config) { cfg.CI.FailOnIdle = -1 }, wantField: "ci.fail_on_idle"},
+		{name: "unknown output format", change: func(cfg *Config) { cfg.Output.Format = "xml" }, wantField: "output.format"},
+		{name: "unknown verbosity", change: func(cfg *Config) { cfg.Output.Verbosity = "loud" }, wantField: "output.verbosity"},
+		{name: "unknown sort", change: func(cfg *Config) { cfg.Output.Sort = "size" }, wantField: "output.sort"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.change(cfg)
+
+			err := cfg.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			var problems ValidationErrors
+			if !errors.As(err, &problems) {
+				t.Fatalf("Validate() error = %v (%T), want ValidationErrors", err, err)
+			}
+			if len(problems) != 1 || problems[0].Field != tt.wantField {
+				t.Errorf("Validate() = %v, want a single problem with %s", problems, tt.wantField)
+			}
+		})
+	}
+}
+
+// Every problem is reported at once rather than only the first
+func TestConfigValidateReportsAllProblems(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.API.Timeout = -1
+	cfg.Scan.Limit = -1
+	cfg.Output.Sort = "size"
+
+	var problems ValidationErrors
+	if err := cfg.Validate(); !errors.As(err, &problems) {
+		t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+	}
+	var fields []string
+	for _, p := range problems {
+		fields = append(fields, p.Field)
+	}
+	if want := "[api.timeout scan.limit output.sort]"; fmt.Sprint(fields) != want {
+		t.Errorf("Validate() reported %v, want %s", fields, want)
+	}
+}
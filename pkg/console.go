@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// consoleRegionPattern matches the region codes the AWS console serves, e.g. eu-west-1 or
+// us-gov-west-1
+var consoleRegionPattern = regexp.MustCompile(`^(us-gov|[a-z]{2})-[a-z]+-\d+$`)
+
+// consoleDomain returns the console domain of the partition a region belongs to
+func consoleDomain(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "console.amazonaws.cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "console.amazonaws-us-gov.com"
+	}
+	return "console.aws.amazon.com"
+}
+
+// ConsoleURL returns the AWS console page of an EC2 instance, S3 bucket or RDS instance in a
+// region, e.g. https://eu-west-1.console.aws.amazon.com/ec2/home?region=eu-west-1#InstanceDetails:instanceId=i-0abc.
+// It returns "" for other resource types, a missing ID and an unknown region, rather than
+// a link that leads nowhere.
+func ConsoleURL(resourceType ResourceType, region, id string) string {
+	if id == "" || !consoleRegionPattern.MatchString(region) {
+		return ""
+	}
+	domain := consoleDomain(region)
+	switch resourceType {
+	case ResourceTypeEC2:
+		return fmt.Sprintf("https://%s.%s/ec2/home?region=%s#InstanceDetails:instanceId=%s",
+			region, domain, region, url.QueryEscape(id))
+	case ResourceTypeS3:
+		// S3 has one console for every region of a partition
+		host := domain
+		if domain == "console.aws.amazon.com" {
+			host = "s3." + domain
+		}
+		return fmt.Sprintf("https://%s/s3/buckets/%s?region=%s", host, url.PathEscape(id), region)
+	case ResourceTypeRDS:
+		return fmt.Sprintf("https://%s.%s/rds/home?region=%s#database:id=%s;is-cluster=false",
+			region, domain, region, url.QueryEscape(id))
+	}
+	return ""
+}
+
+// itemConsoleURL returns the console page of an item's resource, "" when ConsoleURL has none
+func itemConsoleURL(item ReportItem) string {
+	switch item.GetResourceType() {
+	case ResourceTypeEC2:
+		return ConsoleURL(ResourceTypeEC2, item.Instance.Region, item.Instance.InstanceID)
+	case ResourceTypeS3:
+		return ConsoleURL(ResourceTypeS3, item.S3Bucket.Region, item.S3Bucket.BucketName)
+	case ResourceTypeRDS:
+		return ConsoleURL(ResourceTypeRDS, item.RDSInstance.Region, item.RDSInstance.InstanceID)
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestConsoleURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType ResourceType
+		region       string
+		id           string
+		want         string
+	}{
+		{
+			name:         "ec2",
+			resourceType: ResourceTypeEC2,
+			region:       "eu-west-1",
+			id:           "i-0abc123",
+			want:         "https://eu-west-1.console.aws.amazon.com/ec2/home?region=eu-west-1#InstanceDetails:instanceId=i-0abc123",
+		},
+		{
+			name:         "s3",
+			resourceType: ResourceTypeS3,
+			region:       "eu-west-1",
+			id:           "logs-archive",
+			want:         "https://s3.console.aws.amazon.com/s3/buckets/logs-archive?region=eu-west-1",
+		},
+		{
+			name:         "rds",
+			resourceType: ResourceTypeRDS,
+			region:       "us-east-2",
+			id:           "orders-db",
+			want:         "https://us-east-2.console.aws.amazon.com/rds/home?region=us-east-2#database:id=orders-db;is-cluster=false",
+		},
+		{
+			name:         "ec2 in GovCloud",
+			resourceType: ResourceTypeEC2,
+			region:       "us-gov-west-1",
+			id:           "i-0abc123",
+			want:         "https://us-gov-west-1.console.amazonaws-us-gov.com/ec2/home?region=us-gov-west-1#InstanceDetails:instanceId=i-0abc123",
+		},
+		{
+			name:         "s3 in China",
+			resourceType: ResourceTypeS3,
+			region:       "cn-north-1",
+			id:           "logs",
+			want:         "https://console.amazonaws.cn/s3/buckets/logs?region=cn-north-1",
+		},
+		{
+			name:         "s3 in GovCloud",
+			resourceType: ResourceTypeS3,
+			region:       "us-gov-east-1",
+			id:           "logs",
+			want:         "https://console.amazonaws-us-gov.com/s3/buckets/logs?region=us-gov-east-1",
+		},
+		{
+			name:         "rds in China",
+			resourceType: ResourceTypeRDS,
+			region:       "cn-northwest-1",
+			id:           "orders",
+			want:         "https://cn-northwest-1.console.amazonaws.cn/rds/home?region=cn-northwest-1#database:id=orders;is-cluster=false",
+		},
+		{
+			name:         "id escaped",
+			resourceType: ResourceTypeRDS,
+			region:       "eu-west-1",
+			id:           "a&b#c",
+			want:         "https://eu-west-1.console.aws.amazon.com/rds/home?region=eu-west-1#database:id=a%26b%23c;is-cluster=false",
+		},
+		{name: "missing id", resourceType: ResourceTypeEC2, region: "eu-west-1"},
+		{name: "missing region", resourceType: ResourceTypeEC2, id: "i-0abc123"},
+		{name: "availability zone", resourceType: ResourceTypeEC2, region: "eu-west-1a", id: "i-0abc123"},
+		{name: "uppercase region", resourceType: ResourceTypeS3, region: "EU-WEST-1", id: "logs"},
+		{name: "local endpoint", resourceType: ResourceTypeS3, region: "localhost", id: "logs"},
+		{name: "no console page", resourceType: ResourceTypeSnapshots, region: "eu-west-1", id: "snap-0abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConsoleURL(tt.resourceType, tt.region, tt.id)
+			if got != tt.want {
+				t.Errorf("ConsoleURL(%s, %q, %q) = %q, want %q", tt.resourceType, tt.region, tt.id, got, tt.want)
+			}
+			if got == "" {
+				return
+			}
+			if _, err := url.Parse(got); err != nil {
+				t.Errorf("ConsoleURL() = %q, which does not parse: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestItemConsoleURL(t *testing.T) {
+	tests := []struct {
+		name string
+		item ReportItem
+		want string
+	}{
+		{
+			name: "ec2",
+			item: ReportItem{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-0abc123", Region: "eu-west-1"}},
+			want: "https://eu-west-1.console.aws.amazon.com/ec2/home?region=eu-west-1#InstanceDetails:instanceId=i-0abc123",
+		},
+		{
+			name: "s3",
+			item: ReportItem{ResourceType: ResourceTypeS3, S3Bucket: S3Bucket{BucketName: "logs", Region: "us-east-1"}},
+			want: "https://s3.console.aws.amazon.com/s3/buckets/logs?region=us-east-1",
+		},
+		{
+			name: "rds",
+			item: ReportItem{ResourceType: ResourceTypeRDS, RDSInstance: RDSInstance{InstanceID: "orders", Region: "eu-west-1"}},
+			want: "https://eu-west-1.console.aws.amazon.com/rds/home?region=eu-west-1#database:id=orders;is-cluster=false",
+		},
+		{
+			name: "ec2 without a region",
+			item: ReportItem{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-0abc123"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := itemConsoleURL(tt.item); got != tt.want {
+				t.Errorf("itemConsoleURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	ceTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// DefaultCostTagKey is the cost allocation tag used to match Cost Explorer spend to resources
+const DefaultCostTagKey = "Name"
+
+// costExplorerLookbackDays is the spend window attached as ActualMonthlyCost
+const costExplorerLookbackDays = 30
+
+// costExplorerRegion is the region serving the Cost Explorer API for the whole account
+const costExplorerRegion = "us-east-1"
+
+// Cost Explorer SERVICE dimension values of the resource types that get actual spend
+const (
+	costExplorerServiceEC2 = "Amazon Elastic Compute Cloud - Compute"
+	costExplorerServiceRDS = "Amazon Relational Database Service"
+	costExplorerServiceS3  = "Amazon Simple Storage Service"
+)
+
+// tagRegionKey identifies the spend of one tag value in one region
+type tagRegionKey struct {
+	TagValue string
+	Region   string
+}
+
+// NewCostExplorerClientFromConfig creates a Cost Explorer client from an AWS config, pointing
+// it at the region that serves the API
+func NewCostExplorerClientFromConfig(cfg aws.Config) *costexplorer.Client {
+	cfg = cfg.Copy()
+	cfg.Region = costExplorerRegion
+	return costexplorer.NewFromConfig(cfg)
+}
+
+// AttachActualCosts sets ActualMonthlyCost on the EC2 instances, RDS instances and S3 buckets
+// of a scan from their Cost Explorer spend over the last 30 days. Spend is grouped by the
+// tagKey cost allocation tag and region, so a resource only gets a figure when no other
+// resource of its type in that region shares its tag value. An error means Cost Explorer
+// could not be queried, e.g. because it is not enabled for the account; resources already
+// matched keep their figures.
+func AttachActualCosts(ctx context.Context, client CostExplorerAPI, payload *ScanPayload, tagKey string) error {
+	if tagKey == "" {
+		tagKey = DefaultCostTagKey
+	}
+	end := time.Now().UTC()
+	start := end.AddDate(0, 0, -costExplorerLookbackDays)
+
+	if len(payload.Instances) > 0 {
+		spend, err := spendByTagAndRegion(ctx, client, costExplorerServiceEC2, tagKey, start, end)
+		if err != nil {
+			return err
+		}
+		instances := payload.Instances
+		matchSpend(spend, len(instances),
+			func(i int) (string, string) { return instances[i].Tags[tagKey], instances[i].Region },
+			func(i int, cost float64) { instances[i].ActualMonthlyCost = cost })
+	}
+
+	if len(payload.RDSInstances) > 0 {
+		spend, err := spendByTagAndRegion(ctx, client, costExplorerServiceRDS, tagKey, start, end)
+		if err != nil {
+			return err
+		}
+		instances := payload.RDSInstances
+		matchSpend(spend, len(instances),
+			func(i int) (string, string) { return instances[i].Tags[tagKey], instances[i].Region },
+			func(i int, cost float64) { instances[i].ActualMonthlyCost = cost })
+	}
+
+	if len(payload.S3Buckets) > 0 {
+		spend, err := spendByTagAndRegion(ctx, client, costExplorerServiceS3, tagKey, start, end)
+		if err != nil {
+			return err
+		}
+		buckets := payload.S3Buckets
+		matchSpend(spend, len(buckets),
+			func(i int) (string, string) { return buckets[i].Tags[tagKey], buckets[i].Region },
+			func(i int, cost float64) { buckets[i].ActualMonthlyCost = cost })
+	}
+
+	return nil
+}
+
+// matchSpend hands each tag value and region's spend to the one resource of n that has them.
+// Resources without the tag, or sharing their tag value in a region, get nothing.
+func matchSpend(spend map[tagRegionKey]float64, n int, keyOf func(i int) (string, string), set func(i int, cost float64)) {
+	owners := make(map[tagRegionKey][]int)
+	for i := 0; i < n; i++ {
+		value, region := keyOf(i)
+		if value == "" {
+			continue
+		}
+		key := tagRegionKey{TagValue: value, Region: region}
+		owners[key] = append(owners[key], i)
+	}
+	for key, idx := range owners {
+		if cost, ok := spend[key]; ok && len(idx) == 1 {
+			set(idx[0], cost)
+		}
+	}
+}
+
+// spendByTagAndRegion returns the unblended cost of a service between start and end, grouped
+// by the value of a cost allocation tag and by region. Spend without the tag is left out.
+func spendByTagAndRegion(
+	ctx context.Context,
+	client CostExplorerAPI,
+	service, tagKey string,
+	start, end time.Time,
+) (map[tagRegionKey]float64, error) {
+	spend := make(map[tagRegionKey]float64)
+	var nextToken *string
+
+	for {
+		resp, err := client.GetCostAndUsage(ctx, &costexplorer.GetCostAndUsageInput{
+			TimePeriod: &ceTypes.DateInterval{
+				Start: aws.String(start.Format(time.DateOnly)),
+				End:   aws.String(end.Format(time.DateOnly)),
+			},
+			Granularity: ceTypes.GranularityMonthly,
+			Metrics:     []string{"UnblendedCost"},
+			Filter: &ceTypes.Expression{
+				Dimensions: &ceTypes.DimensionValues{
+					Key:    ceTypes.DimensionService,
+					Values: []string{service},
+				},
+			},
+			GroupBy: []ceTypes.GroupDefinition{
+				{Type: ceTypes.GroupDefinitionTypeTag, Key: aws.String(tagKey)},
+				{Type: ceTypes.GroupDefinitionTypeDimension, Key: aws.String(string(ceTypes.DimensionRegion))},
+			},
+			NextPageToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cost explorer query for %s failed: %w", service, err)
+		}
+
+		// The window spans two calendar months, so each group appears once per month
+		for _, result := range resp.ResultsByTime {
+			for _, group := range result.Groups {
+				if len(group.Keys) < 2 {
+					continue
+				}
+				// Tag group keys look like "Name$web-1"; untagged spend has an empty value
+				_, value, _ := strings.Cut(group.Keys[0], "$")
+				if value == "" {
+					continue
+				}
+				metric, ok := group.Metrics["UnblendedCost"]
+				if !ok {
+					continue
+				}
+				amount, err := strconv.ParseFloat(aws.ToString(metric.Amount), 64)
+				if err != nil {
+					Warnf("Unable to parse Cost Explorer amount %q: %v", aws.ToString(metric.Amount), err)
+					continue
+				}
+				spend[tagRegionKey{TagValue: value, Region: group.Keys[1]}] += amount
+			}
+		}
+
+		if resp.NextPageToken == nil {
+			break
+		}
+		nextToken = resp.NextPageToken
+	}
+
+	return spend, nil
+}
+
+// actualCostNote is appended to the cost step of a prompt when Cost Explorer spend is known
+func actualCostNote(actual float64) string {
+	if actual <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(". Cost Explorer reports an actual spend of $%.2f over the last %d days; mention it next to the estimate and explain any large difference (reserved or Savings Plans discounts, stopped time, data transfer)",
+		actual, costExplorerLookbackDays)
+}
@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// maxRecommendationLength caps the recommendation column so spreadsheets stay readable
+const maxRecommendationLength = 200
+
+// csvHeader lists the columns written by FormatAnalysisReportCSV
+var csvHeader = []string{
+	"resource_type",
+	"resource_id",
+	"region",
+	"size",
+	"utilization",
+	"monthly_cost_usd",
+	"monthly_savings_usd",
+	"co2_kg_monthly",
+	"top_recommendation",
+	"cost_source",
+	"model_id",
+}
+
+// numberedItemRegex matches the first entry of a numbered markdown list
+var numberedItemRegex = regexp.MustCompile(`(?m)^\s*1\.\s+(.+)$`)
+
+// FormatAnalysisReportCSV writes one row per analyzed resource in CSV format
+func FormatAnalysisReportCSV(w io.Writer, report []ReportItem) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, item := range report {
+		co2, cost, savings := extractItemMetrics(item)
+		resourceID, region, size, utilization := describeItem(item)
+
+		row := []string{
+			string(item.GetResourceType()),
+			resourceID,
+			region,
+			size,
+			utilization,
+			fmt.Sprintf("%.2f", cost),
+			fmt.Sprintf("%.2f", savings),
+			fmt.Sprintf("%.2f", co2),
+			extractTopRecommendation(item.Analysis),
+			item.CostSource,
+			item.ModelID,
+		}
+
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row for %s: %w", resourceID, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// describeItem returns the identifier, region, size and utilization summary for a report item
+func describeItem(item ReportItem) (resourceID, region, size, utilization string) {
+	switch item.GetResourceType() {
+	case ResourceTypeS3:
+		return item.S3Bucket.BucketName,
+			item.S3Bucket.Region,
+			fmt.Sprintf("%.2f GB", float64(item.S3Bucket.SizeBytes)/(1024*1024*1024)),
+			fmt.Sprintf("%d objects", item.S3Bucket.ObjectCount)
+	case ResourceTypeRDS:
+		return item.RDSInstance.InstanceID,
+			item.RDSInstance.Region,
+			item.RDSInstance.InstanceType,
+			fmt.Sprintf("%.1f%% CPU (%dd avg)", item.RDSInstance.CPUAvg7d, EffectivePeriodDays(item.RDSInstance.MetricsPeriodDays))
+	case ResourceTypeEBS:
+		return item.EBSVolume.VolumeID,
+			item.EBSVolume.Region,
+			fmt.Sprintf("%d GiB %s", item.EBSVolume.SizeGiB, item.EBSVolume.VolumeType),
+			fmt.Sprintf("%.0f ops (%dd total)", item.EBSVolume.ReadOps7d+item.EBSVolume.WriteOps7d, EffectivePeriodDays(item.EBSVolume.MetricsPeriodDays))
+	case ResourceTypeLambda:
+		return item.LambdaFunction.FunctionName,
+			item.LambdaFunction.Region,
+			fmt.Sprintf("%d MB %s", item.LambdaFunction.MemoryMB, item.LambdaFunction.Architecture),
+			fmt.Sprintf("%.0f invocations (%dd total)", item.LambdaFunction.Invocations7d, EffectivePeriodDays(item.LambdaFunction.MetricsPeriodDays))
+	case ResourceTypeELB:
+		traffic := fmt.Sprintf("%.0f requests (%dd total)", item.LoadBalancer.RequestCount7d, EffectivePeriodDays(item.LoadBalancer.MetricsPeriodDays))
+		if item.LoadBalancer.Type != "application" {
+			traffic = fmt.Sprintf("%.1f active flows (%dd avg)", item.LoadBalancer.ActiveFlowCountAvg, EffectivePeriodDays(item.LoadBalancer.MetricsPeriodDays))
+		}
+		return item.LoadBalancer.Name,
+			item.LoadBalancer.Region,
+			fmt.Sprintf("%s, %d target groups", item.LoadBalancer.Type, item.LoadBalancer.TargetGroupCount),
+			traffic
+	case ResourceTypeNetwork:
+		utilization := "unassociated"
+		if item.NetworkResource.Type == NetworkTypeNATGateway {
+			utilization = fmt.Sprintf("%.2f GiB out (%dd total)", item.NetworkResource.BytesOutToDestination7d/(1024*1024*1024), EffectivePeriodDays(item.NetworkResource.MetricsPeriodDays))
+		}
+		return item.NetworkResource.ResourceID,
+			item.NetworkResource.Region,
+			item.NetworkResource.Type,
+			utilization
+	case ResourceTypeDynamoDB:
+		table := item.DynamoTable
+		size := fmt.Sprintf("on-demand, %.2f GB", float64(table.SizeBytes)/(1024*1024*1024))
+		utilization := fmt.Sprintf("%.2f RCU/s, %.2f WCU/s (%dd avg)", table.AvgConsumedRCU, table.AvgConsumedWCU, EffectivePeriodDays(table.MetricsPeriodDays))
+		if table.BillingMode == "PROVISIONED" {
+			size = fmt.Sprintf("%d RCU / %d WCU, %.2f GB", table.ProvisionedRCU, table.ProvisionedWCU, float64(table.SizeBytes)/(1024*1024*1024))
+			utilization = fmt.Sprintf("%.1f%% read, %.1f%% write (%dd avg)", table.ReadUtilizationPct, table.WriteUtilizationPct, EffectivePeriodDays(table.MetricsPeriodDays))
+		}
+		return table.TableName,
+			table.Region,
+			size,
+			utilization
+	case ResourceTypeElastiCache:
+		cluster := item.ElastiCache
+		utilization := fmt.Sprintf("%.1f%% CPU, %.1f connections (%dd avg)", cluster.CPUAvg7d, cluster.ConnectionsAvg7d, EffectivePeriodDays(cluster.MetricsPeriodDays))
+		if cluster.Engine != "memcached" {
+			utilization = fmt.Sprintf("%.1f%% CPU, %.1f%% memory, %.1f connections (%dd avg)", cluster.CPUAvg7d, cluster.MemoryUsageAvg7d, cluster.ConnectionsAvg7d, EffectivePeriodDays(cluster.MetricsPeriodDays))
+		}
+		if cluster.Idle {
+			utilization += ", idle"
+		}
+		return cluster.ClusterID,
+			cluster.Region,
+			fmt.Sprintf("%d x %s %s", cluster.NodeCount, cluster.NodeType, cluster.Engine),
+			utilization
+	case ResourceTypeSnapshots:
+		summary := SummarizeSnapshots(item.Snapshots)
+		region := ""
+		for _, s := range item.Snapshots {
+			if region != "" && s.Region != region {
+				region = "multiple"
+				break
+			}
+			region = s.Region
+		}
+		return fmt.Sprintf("%d snapshots", summary.Count),
+			region,
+			fmt.Sprintf("%d GiB", summary.TotalGiB),
+			fmt.Sprintf("older than %d days", summary.StaleAfterDays)
+	default:
+		return item.Instance.InstanceID,
+			item.Instance.Region,
+			item.Instance.InstanceType,
+			fmt.Sprintf("%.1f%% CPU (%dd avg)", item.Instance.CPUAvg7d, EffectivePeriodDays(item.Instance.MetricsPeriodDays))
+	}
+}
+
+// extractTopRecommendation returns the first numbered recommendation from the analysis text
+func extractTopRecommendation(analysis string) string {
+	// Prefer the recommendations section, fall back to the first numbered item anywhere
+	section := analysis
+	if index := strings.Index(analysis, "Recommendations"); index != -1 {
+		section = analysis[index:]
+	}
+
+	matches := numberedItemRegex.FindStringSubmatch(section)
+	if len(matches) < 2 {
+		return ""
+	}
+
+	recommendation := strings.TrimSpace(strings.ReplaceAll(matches[1], "**", ""))
+	if runes := []rune(recommendation); len(runes) > maxRecommendationLength {
+		recommendation = string(runes[:maxRecommendationLength-3]) + "..."
+	}
+	return recommendation
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// ErrRequeueRunning is returned by RequeueDeadLetters while an earlier requeue of the same
+// dead-letter queue is still moving messages
+var ErrRequeueRunning = errors.New("a requeue of the dead-letter queue is already running")
+
+// deadLetterReason is recorded on items whose messages reached the dead-letter queue. SQS does
+// not say why, so the error of the item's last recorded attempt is added when there is one.
+const deadLetterReason = "dead-lettered: the worker did not finish the item after repeated deliveries"
+
+// RecordDeadLetteredItem accounts for a work item whose message reached the dead-letter queue,
+// which otherwise leaves its job waiting for it forever. The item is recorded as permanently
+// failed, with the reason on its item record, and the job is finalized if it was the last
+// outstanding item. Items of cancelled jobs are recorded as skipped, as the worker does, and
+// items of finished or expired jobs are ignored. Errors are returned so the message can be
+// delivered again; the job counts each item only once, so that is safe.
+func RecordDeadLetteredItem(ctx context.Context, dynamoClient DynamoJobStore, workItem WorkItem) error {
+	status, err := GetJobStatus(ctx, dynamoClient, workItem.JobID)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			Warnf("Job %s of dead-lettered item %d no longer exists, dropping it", workItem.JobID, workItem.ItemIndex)
+			return nil
+		}
+		return err
+	}
+
+	switch {
+	case status == JobStatusCancelled:
+		Infof("Job %s is cancelled, recording dead-lettered item %d as skipped", workItem.JobID, workItem.ItemIndex)
+		if err := UpdateJobItem(ctx, dynamoClient, workItem, ItemSkipped, "", 0); err != nil {
+			Warnf("%v", err)
+		}
+		return RecordSkippedItem(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex)
+	case status.IsTerminal():
+		Infof("Job %s is already %s, ignoring dead-lettered item %d", workItem.JobID, status, workItem.ItemIndex)
+		return nil
+	}
+
+	// A worker may have finished the item and died before SQS heard about it
+	reason := deadLetterReason
+	record, err := GetJobItem(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex)
+	switch {
+	case err != nil:
+		Warnf("%v", err)
+	case record != nil && record.Status != ItemQueued && record.Status != ItemProcessing:
+		Infof("Item %d of job %s is already %s, ignoring its dead-lettered message", workItem.ItemIndex, workItem.JobID, record.Status)
+		return nil
+	case record != nil && record.Error != "":
+		reason += "; last error: " + record.Error
+	}
+	Errorf("Item %d of job %s (%s) was dead-lettered", workItem.ItemIndex, workItem.JobID, workItem.ResourceID())
+
+	if err := UpdateJobItem(ctx, dynamoClient, workItem, ItemFailed, reason, 0); err != nil {
+		Warnf("%v", err)
+	}
+	if err := UpdateJobProgress(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex, false, ReportItem{}); err != nil {
+		return err
+	}
+	if err := MaybeFinalizeJob(ctx, dynamoClient, workItem.JobID); err != nil {
+		return fmt.Errorf("failed to finalize job %s: %w", workItem.JobID, err)
+	}
+	return nil
+}
+
+// RequeueDeadLetters starts moving every message in the dead-letter queue dlqARN back to the
+// queue it was dead-lettered from, and returns the handle of the SQS move task. SQS moves the
+// messages in the background; ErrRequeueRunning is returned while an earlier move of the same
+// queue is unfinished.
+func RequeueDeadLetters(ctx context.Context, sqsClient SQSRedriveAPI, dlqARN string) (string, error) {
+	tasks, err := sqsClient.ListMessageMoveTasks(ctx, &sqs.ListMessageMoveTasksInput{
+		SourceArn: aws.String(dlqARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list requeues of %s: %w", dlqARN, err)
+	}
+	for _, task := range tasks.Results {
+		if aws.ToString(task.Status) == "RUNNING" {
+			return "", fmt.Errorf("%w: %d of about %d messages moved so far", ErrRequeueRunning,
+				task.ApproximateNumberOfMessagesMoved, aws.ToInt64(task.ApproximateNumberOfMessagesToMove))
+		}
+	}
+
+	// Without a destination SQS returns each message to the queue it came from
+	output, err := sqsClient.StartMessageMoveTask(ctx, &sqs.StartMessageMoveTaskInput{
+		SourceArn: aws.String(dlqARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to requeue %s: %w", dlqARN, err)
+	}
+	Infof("Requeueing the messages of %s", dlqARN)
+	return aws.ToString(output.TaskHandle), nil
+}
@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeRedriveSQS serves ListMessageMoveTasks from tasks and records StartMessageMoveTask calls
+type fakeRedriveSQS struct {
+	tasks     []sqsTypes.ListMessageMoveTasksResultEntry
+	listErr   error
+	startErr  error
+	started   []string // source ARNs of every move started
+	listedArn string
+}
+
+var _ SQSRedriveAPI = (*fakeRedriveSQS)(nil)
+
+func (f *fakeRedriveSQS) ListMessageMoveTasks(ctx context.Context, params *sqs.ListMessageMoveTasksInput, optFns ...func(*sqs.Options)) (*sqs.ListMessageMoveTasksOutput, error) {
+	f.listedArn = aws.ToString(params.SourceArn)
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return &sqs.ListMessageMoveTasksOutput{Results: f.tasks}, nil
+}
+
+func (f *fakeRedriveSQS) StartMessageMoveTask(ctx context.Context, params *sqs.StartMessageMoveTaskInput, optFns ...func(*sqs.Options)) (*sqs.StartMessageMoveTaskOutput, error) {
+	if f.startErr != nil {
+		return nil, f.startErr
+	}
+	if params.DestinationArn != nil {
+		return nil, errors.New("requeue should return messages to their source queue")
+	}
+	f.started = append(f.started, aws.ToString(params.SourceArn))
+	return &sqs.StartMessageMoveTaskOutput{TaskHandle: aws.String("handle-1")}, nil
+}
+
+const testDLQARN = "arn:aws:sqs:eu-west-1:123456789012:greenops-dlq"
+
+// moveTask is a move task of the dead-letter queue in the given status
+func moveTask(status string, moved, toMove int64) sqsTypes.ListMessageMoveTasksResultEntry {
+	return sqsTypes.ListMessageMoveTasksResultEntry{
+		SourceArn:                         aws.String(testDLQARN),
+		Status:                            aws.String(status),
+		ApproximateNumberOfMessagesMoved:  moved,
+		ApproximateNumberOfMessagesToMove: aws.Int64(toMove),
+	}
+}
+
+func TestRequeueDeadLetters(t *testing.T) {
+	listErr := errors.New("AccessDenied")
+	startErr := errors.New("UnsupportedOperation")
+
+	tests := []struct {
+		name       string
+		sqs        *fakeRedriveSQS
+		wantHandle string
+		wantErr    func(error) bool
+	}{
+		{name: "no earlier moves", sqs: &fakeRedriveSQS{}, wantHandle: "handle-1"},
+		{
+			name:       "earlier moves finished",
+			sqs:        &fakeRedriveSQS{tasks: []sqsTypes.ListMessageMoveTasksResultEntry{moveTask("COMPLETED", 10, 10), moveTask("FAILED", 2, 5)}},
+			wantHandle: "handle-1",
+		},
+		{
+			name: "move still running",
+			sqs:  &fakeRedriveSQS{tasks: []sqsTypes.ListMessageMoveTasksResultEntry{moveTask("COMPLETED", 10, 10), moveTask("RUNNING", 3, 8)}},
+			wantErr: func(err error) bool {
+				return errors.Is(err, ErrRequeueRunning) && strings.Contains(err.Error(), "3 of about 8")
+			},
+		},
+		{name: "list fails", sqs: &fakeRedriveSQS{listErr: listErr}, wantErr: func(err error) bool { return errors.Is(err, listErr) }},
+		{name: "start fails", sqs: &fakeRedriveSQS{startErr: startErr}, wantErr: func(err error) bool { return errors.Is(err, startErr) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle, err := RequeueDeadLetters(context.Background(), tt.sqs, testDLQARN)
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("RequeueDeadLetters() error = %v, not the one expected", err)
+				}
+				if len(tt.sqs.started) != 0 {
+					t.Errorf("started %d moves after an error", len(tt.sqs.started))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RequeueDeadLetters() error = %v", err)
+			}
+			if handle != tt.wantHandle {
+				t.Errorf("RequeueDeadLetters() = %q, want %q", handle, tt.wantHandle)
+			}
+			if tt.sqs.listedArn != testDLQARN || len(tt.sqs.started) != 1 || tt.sqs.started[0] != testDLQARN {
+				t.Errorf("listed %q, started %v; want both for %s", tt.sqs.listedArn, tt.sqs.started, testDLQARN)
+			}
+		})
+	}
+}
+
+func TestRecordDeadLetteredItem(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, dynamo *fakeDynamo, job *JobInfo, workItems []WorkItem)
+		wantStatus  JobStatus
+		wantFailed  int
+		wantSkipped int
+		wantItem    ItemStatus
+		wantReason  string
+	}{
+		{
+			name:       "queued item fails",
+			wantStatus: JobStatusPending,
+			wantFailed: 1,
+			wantItem:   ItemFailed,
+			wantReason: deadLetterReason,
+		},
+		{
+			name: "last error is kept",
+			setup: func(t *testing.T, dynamo *fakeDynamo, job *JobInfo, workItems []WorkItem) {
+				if err := UpdateJobItem(ctx, dynamo, workItems[0], ItemProcessing, "ThrottlingException", 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStatus: JobStatusPending,
+			wantFailed: 1,
+			wantItem:   ItemFailed,
+			wantReason: deadLetterReason + "; last error: ThrottlingException",
+		},
+		{
+			name: "last outstanding item finalizes the job",
+			setup: func(t *testing.T, dynamo *fakeDynamo, job *JobInfo, workItems []WorkItem) {
+				if err := UpdateJobProgress(ctx, dynamo, job.JobID, 1, true, ReportItem{Analysis: "done"}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStatus: JobStatusCompleted,
+			wantFailed: 1,
+			wantItem:   ItemFailed,
+			wantReason: deadLetterReason,
+		},
+		{
+			name: "item finished before its message was dead-lettered",
+			setup: func(t *testing.T, dynamo *fakeDynamo, job *JobInfo, workItems []WorkItem) {
+				if err := UpdateJobItem(ctx, dynamo, workItems[0], ItemCompleted, "", 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStatus: JobStatusPending,
+			wantItem:   ItemCompleted,
+		},
+		{
+			name: "cancelled job skips the item",
+			setup: func(t *testing.T, dynamo *fakeDynamo, job *JobInfo, workItems []WorkItem) {
+				if err := CancelJob(ctx, dynamo, job.JobID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStatus:  JobStatusCancelled,
+			wantSkipped: 1,
+			wantItem:    ItemSkipped,
+		},
+		{
+			name: "finished job is left alone",
+			setup: func(t *testing.T, dynamo *fakeDynamo, job *JobInfo, workItems []WorkItem) {
+				if err := UpdateJobStatus(ctx, dynamo, job.JobID, JobStatusCompleted, JobStatusPending, JobStatusProcessing); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStatus: JobStatusCompleted,
+			wantItem:   ItemQueued,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			t.Setenv("ITEMS_TABLE", testItemsTable)
+			dynamo := newFakeDynamo()
+			job := createTestJob(t, dynamo, 2)
+			workItems := testWorkItems(2)
+			for i := range workItems {
+				workItems[i].JobID = job.JobID
+			}
+			if err := PutJobItems(ctx, dynamo, workItems); err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(t, dynamo, job, workItems)
+			}
+
+			// SQS may hand the dead-letter processor the same message twice
+			for i := 0; i < 2; i++ {
+				if err := RecordDeadLetteredItem(ctx, dynamo, workItems[0]); err != nil {
+					t.Fatalf("RecordDeadLetteredItem() delivery %d error = %v", i+1, err)
+				}
+			}
+
+			got, err := GetJob(ctx, dynamo, job.JobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || got.FailedItems != tt.wantFailed || got.SkippedItems != tt.wantSkipped {
+				t.Errorf("job %s with %d failed and %d skipped items; want %s, %d and %d",
+					got.Status, got.FailedItems, got.SkippedItems, tt.wantStatus, tt.wantFailed, tt.wantSkipped)
+			}
+			record, err := GetJobItem(ctx, dynamo, job.JobID, 0)
+			if err != nil || record == nil {
+				t.Fatalf("GetJobItem() = %v, %v", record, err)
+			}
+			if record.Status != tt.wantItem || record.Error != tt.wantReason {
+				t.Errorf("item record %s %q, want %s %q", record.Status, record.Error, tt.wantItem, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRecordDeadLetteredItemErrors(t *testing.T) {
+	useJobTables(t)
+	ctx := context.Background()
+
+	// A job that expired or was deleted leaves nothing to record
+	dynamo := newFakeDynamo()
+	if err := RecordDeadLetteredItem(ctx, dynamo, WorkItem{JobID: "gone", ItemType: "ec2"}); err != nil {
+		t.Errorf("RecordDeadLetteredItem() error = %v for a missing job, want nil", err)
+	}
+
+	// Store errors are returned so SQS delivers the message again
+	getErr := errors.New("ProvisionedThroughputExceeded")
+	dynamo.fail("GetItem", getErr)
+	if err := RecordDeadLetteredItem(ctx, dynamo, WorkItem{JobID: "job-1", ItemType: "ec2"}); !errors.Is(err, getErr) {
+		t.Errorf("RecordDeadLetteredItem() error = %v, want %v", err, getErr)
+	}
+}
@@ -0,0 +1,400 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Statuses of a resource in a ReportDiff
+const (
+	DiffAdded     = "added"     // only in the new report
+	DiffRemoved   = "removed"   // only in the old report
+	DiffChanged   = "changed"   // in both, with a different cost, CO2, savings or CPU figure
+	DiffUnchanged = "unchanged" // in both, with the same figures
+)
+
+// diffTolerance is the smallest difference in dollars, kg or percentage points treated as a change
+const diffTolerance = 0.005
+
+// ResourceChange is one resource compared across two reports. The Old figures are zero for
+// added resources and the New ones for removed resources. AccountID is only set for
+// resources scanned in an organization account with --org, and CPU only for resource types
+// that report CPU utilization (EC2, RDS and ElastiCache).
+type ResourceChange struct {
+	Status       string       `json:"status"`
+	ResourceType ResourceType `json:"resource_type"`
+	AccountID    string       `json:"account_id,omitempty"`
+	ResourceID   string       `json:"resource_id"`
+	Region       string       `json:"region,omitempty"`
+
+	OldCost      float64 `json:"old_monthly_cost"`
+	NewCost      float64 `json:"new_monthly_cost"`
+	CostDelta    float64 `json:"monthly_cost_delta"`
+	OldCO2       float64 `json:"old_co2_kg_monthly"`
+	NewCO2       float64 `json:"new_co2_kg_monthly"`
+	CO2Delta     float64 `json:"co2_kg_monthly_delta"`
+	OldSavings   float64 `json:"old_monthly_savings"`
+	NewSavings   float64 `json:"new_monthly_savings"`
+	SavingsDelta float64 `json:"monthly_savings_delta"`
+
+	OldCPU   *float64 `json:"old_cpu_pct,omitempty"`
+	NewCPU   *float64 `json:"new_cpu_pct,omitempty"`
+	CPUDelta *float64 `json:"cpu_pct_delta,omitempty"`
+}
+
+// DiffSummary totals a ReportDiff
+//   - SavingsRealized: monthly cost that went away, i.e. the cost reductions of resources in
+//     both reports plus the whole cost of removed resources
+//   - NewWaste: potential savings that appeared, i.e. the savings found on added resources
+//     plus the increases in potential savings of resources in both reports
+type DiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+
+	OldTotalCost        float64 `json:"old_total_monthly_cost"`
+	NewTotalCost        float64 `json:"new_total_monthly_cost"`
+	CostDelta           float64 `json:"monthly_cost_delta"`
+	OldTotalCO2         float64 `json:"old_total_co2_kg_monthly"`
+	NewTotalCO2         float64 `json:"new_total_co2_kg_monthly"`
+	CO2Delta            float64 `json:"co2_kg_monthly_delta"`
+	OldPotentialSavings float64 `json:"old_potential_monthly_savings"`
+	NewPotentialSavings float64 `json:"new_potential_monthly_savings"`
+	SavingsRealized     float64 `json:"savings_realized"`
+	NewWaste            float64 `json:"new_waste"`
+}
+
+// ReportDiff compares two reports resource by resource. Changes lists removed resources
+// first, then added, changed and unchanged ones, each group by largest cost change.
+type ReportDiff struct {
+	Summary DiffSummary      `json:"summary"`
+	Changes []ResourceChange `json:"changes"`
+}
+
+// diffKey identifies a resource across reports by account, type, region and ID, since
+// resources of different accounts may share an ID. All stale snapshots of an account form
+// one report item whose ID is their count, so that item is matched by account and type alone.
+func diffKey(item ReportItem) (key, resourceID, region string) {
+	resourceID, region, _, _ = describeItem(item)
+	resType := item.GetResourceType()
+	accountID := item.AccountID()
+	if resType == ResourceTypeSnapshots {
+		return accountID + "/" + string(resType), "stale snapshots", region
+	}
+	return accountID + "/" + string(resType) + "/" + region + "/" + resourceID, resourceID, region
+}
+
+// indexReport maps every item of a report to its diff key, rejecting items without an ID
+// and resources listed twice, which could not be matched unambiguously
+func indexReport(report []ReportItem, name string) (map[string]ReportItem, []string, error) {
+	items := make(map[string]ReportItem, len(report))
+	order := make([]string, 0, len(report))
+	for i, item := range report {
+		key, resourceID, region := diffKey(item)
+		if resourceID == "" {
+			return nil, nil, fmt.Errorf("%s report: item %d (%s) has no resource ID", name, i, item.GetResourceType())
+		}
+		if _, dup := items[key]; dup {
+			return nil, nil, fmt.Errorf("%s report: %s %s in %s is listed more than once", name, item.GetResourceType(), resourceID, region)
+		}
+		items[key] = item
+		order = append(order, key)
+	}
+	return items, order, nil
+}
+
+// itemCPU returns the average CPU utilization of resource types that report one
+func itemCPU(item ReportItem) (float64, bool) {
+	switch item.GetResourceType() {
+	case ResourceTypeEC2:
+		return item.Instance.CPUAvg7d, true
+	case ResourceTypeRDS:
+		return item.RDSInstance.CPUAvg7d, true
+	case ResourceTypeElastiCache:
+		return item.ElastiCache.CPUAvg7d, true
+	}
+	return 0, false
+}
+
+// DiffReports matches the resources of two reports by account, type, region and ID and
+// compares their cost, CO2 footprint, potential savings and CPU utilization. Resources found
+// in only one report are listed as added or removed. It fails when an item has no resource
+// ID or a resource appears twice in the same report.
+func DiffReports(oldReport, newReport []ReportItem) (ReportDiff, error) {
+	var diff ReportDiff
+	oldItems, oldOrder, err := indexReport(oldReport, "old")
+	if err != nil {
+		return diff, err
+	}
+	newItems, newOrder, err := indexReport(newReport, "new")
+	if err != nil {
+		return diff, err
+	}
+
+	// Removed and matched resources in the old report's order, then the added ones
+	for _, key := range oldOrder {
+		newItem, matched := newItems[key]
+		if matched {
+			diff.Changes = append(diff.Changes, compareItems(oldItems[key], &newItem))
+		} else {
+			diff.Changes = append(diff.Changes, compareItems(oldItems[key], nil))
+		}
+	}
+	for _, key := range newOrder {
+		if _, matched := oldItems[key]; !matched {
+			diff.Changes = append(diff.Changes, compareAdded(newItems[key]))
+		}
+	}
+
+	summary := &diff.Summary
+	for _, change := range diff.Changes {
+		summary.OldTotalCost += change.OldCost
+		summary.NewTotalCost += change.NewCost
+		summary.OldTotalCO2 += change.OldCO2
+		summary.NewTotalCO2 += change.NewCO2
+		summary.OldPotentialSavings += change.OldSavings
+		summary.NewPotentialSavings += change.NewSavings
+
+		switch change.Status {
+		case DiffAdded:
+			summary.Added++
+			summary.NewWaste += change.NewSavings
+		case DiffRemoved:
+			summary.Removed++
+			summary.SavingsRealized += change.OldCost
+		case DiffChanged:
+			summary.Changed++
+			summary.SavingsRealized += max(-change.CostDelta, 0)
+			summary.NewWaste += max(change.SavingsDelta, 0)
+		default:
+			summary.Unchanged++
+		}
+	}
+	summary.CostDelta = summary.NewTotalCost - summary.OldTotalCost
+	summary.CO2Delta = summary.NewTotalCO2 - summary.OldTotalCO2
+
+	statusOrder := map[string]int{DiffRemoved: 0, DiffAdded: 1, DiffChanged: 2, DiffUnchanged: 3}
+	sort.SliceStable(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if a.Status != b.Status {
+			return statusOrder[a.Status] < statusOrder[b.Status]
+		}
+		return math.Abs(a.CostDelta) > math.Abs(b.CostDelta)
+	})
+	return diff, nil
+}
+
+// compareItems compares a resource of the old report with its match in the new one, or
+// records it as removed when newItem is nil
+func compareItems(oldItem ReportItem, newItem *ReportItem) ResourceChange {
+	_, resourceID, region := diffKey(oldItem)
+	oldCO2, oldCost, oldSavings := extractItemMetrics(oldItem)
+	change := ResourceChange{
+		Status:       DiffRemoved,
+		ResourceType: oldItem.GetResourceType(),
+		AccountID:    oldItem.AccountID(),
+		ResourceID:   resourceID,
+		Region:       region,
+		OldCost:      oldCost,
+		OldCO2:       oldCO2,
+		OldSavings:   oldSavings,
+	}
+	oldCPU, hasCPU := itemCPU(oldItem)
+	if hasCPU {
+		change.OldCPU = &oldCPU
+	}
+	if newItem == nil {
+		change.CostDelta, change.CO2Delta, change.SavingsDelta = -oldCost, -oldCO2, -oldSavings
+		return change
+	}
+
+	change.NewCO2, change.NewCost, change.NewSavings = extractItemMetrics(*newItem)
+	change.CostDelta = change.NewCost - oldCost
+	change.CO2Delta = change.NewCO2 - oldCO2
+	change.SavingsDelta = change.NewSavings - oldSavings
+	change.Status = DiffUnchanged
+	if math.Abs(change.CostDelta) >= diffTolerance || math.Abs(change.CO2Delta) >= diffTolerance || math.Abs(change.SavingsDelta) >= diffTolerance {
+		change.Status = DiffChanged
+	}
+	if newCPU, ok := itemCPU(*newItem); ok && hasCPU {
+		cpuDelta := newCPU - oldCPU
+		change.NewCPU, change.CPUDelta = &newCPU, &cpuDelta
+		if math.Abs(cpuDelta) >= diffTolerance {
+			change.Status = DiffChanged
+		}
+	}
+	return change
+}
+
+// compareAdded records a resource found only in the new report
+func compareAdded(newItem ReportItem) ResourceChange {
+	_, resourceID, region := diffKey(newItem)
+	co2, cost, savings := extractItemMetrics(newItem)
+	change := ResourceChange{
+		Status:       DiffAdded,
+		ResourceType: newItem.GetResourceType(),
+		AccountID:    newItem.AccountID(),
+		ResourceID:   resourceID,
+		Region:       region,
+		NewCost:      cost,
+		CostDelta:    cost,
+		NewCO2:       co2,
+		CO2Delta:     co2,
+		NewSavings:   savings,
+		SavingsDelta: savings,
+	}
+	if cpu, ok := itemCPU(newItem); ok {
+		change.NewCPU = &cpu
+	}
+	return change
+}
+
+// LoadJSONReport reads the results of a report written with --format json
+func LoadJSONReport(path string) ([]ReportItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	// Text, CSV and HTML reports, and bare result lists, do not start with a JSON object
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, fmt.Errorf("%s is not a JSON report; write one with --format json", path)
+	}
+
+	var doc struct {
+		Results *[]ReportItem `json:"results"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, describeJSONError(data, err))
+	}
+	if doc.Results == nil {
+		return nil, fmt.Errorf("%s has no \"results\"; write JSON reports with --format json", path)
+	}
+	return *doc.Results, nil
+}
+
+// FormatReportDiffJSON writes a report diff as a single JSON document
+func FormatReportDiffJSON(w io.Writer, diff ReportDiff) error {
+	if diff.Changes == nil {
+		diff.Changes = []ResourceChange{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diff)
+}
+
+// FormatReportDiff prints the totals of a report diff followed by the removed, added and
+// changed resources. Unchanged resources are only counted.
+func FormatReportDiff(w io.Writer, diff ReportDiff, colorize bool) {
+	printHeader(w, "GreenOps Report Comparison", colorize)
+	s := diff.Summary
+	fmt.Fprintf(w, "Resources: %d removed, %d added, %d changed, %d unchanged\n\n", s.Removed, s.Added, s.Changed, s.Unchanged)
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tOLD\tNEW\tCHANGE")
+	fmt.Fprintf(tw, "Monthly cost ($)\t%.2f\t%.2f\t%s\n", s.OldTotalCost, s.NewTotalCost, signed(s.CostDelta))
+	fmt.Fprintf(tw, "CO2 (kg/month)\t%.2f\t%.2f\t%s\n", s.OldTotalCO2, s.NewTotalCO2, signed(s.CO2Delta))
+	fmt.Fprintf(tw, "Potential savings ($/month)\t%.2f\t%.2f\t%s\n", s.OldPotentialSavings, s.NewPotentialSavings, signed(s.NewPotentialSavings-s.OldPotentialSavings))
+	tw.Flush()
+
+	realized := fmt.Sprintf("$%.2f/month", s.SavingsRealized)
+	waste := fmt.Sprintf("$%.2f/month", s.NewWaste)
+	if colorize {
+		realized = ColorGreen + realized + ColorReset
+		waste = ColorRed + waste + ColorReset
+	}
+	fmt.Fprintf(w, "\nSavings realized: %s (cost reductions and removed resources)\n", realized)
+	fmt.Fprintf(w, "New waste:        %s (savings potential on added resources and increases on existing ones)\n", waste)
+
+	for _, section := range []struct {
+		status, title string
+	}{
+		{DiffRemoved, "REMOVED RESOURCES"},
+		{DiffAdded, "ADDED RESOURCES"},
+		{DiffChanged, "CHANGED RESOURCES"},
+	} {
+		var changes []ResourceChange
+		for _, change := range diff.Changes {
+			if change.Status == section.status {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) > 0 {
+			printDiffSection(w, section.title, changes, colorize)
+		}
+	}
+}
+
+// printDiffSection prints one line per resource with its old and new figures
+func printDiffSection(w io.Writer, title string, changes []ResourceChange, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold, title, ColorReset)
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+	}
+	fmt.Fprintln(w, strings.Repeat("─", len(title)))
+
+	// Reports of an organization scan name the account of each resource
+	withAccounts := false
+	for _, c := range changes {
+		withAccounts = withAccounts || c.AccountID != ""
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	if withAccounts {
+		fmt.Fprint(tw, "ACCOUNT\t")
+	}
+	fmt.Fprintln(tw, "TYPE\tRESOURCE\tREGION\tCOST ($/mo)\tCO2 (kg/mo)\tSAVINGS ($/mo)\tCPU (%)")
+	for _, c := range changes {
+		if withAccounts {
+			fmt.Fprintf(tw, "%s\t", accountLabel(c.AccountID))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.ResourceType, c.ResourceID, c.Region,
+			describeDelta(c.Status, c.OldCost, c.NewCost),
+			describeDelta(c.Status, c.OldCO2, c.NewCO2),
+			describeDelta(c.Status, c.OldSavings, c.NewSavings),
+			describeCPUDelta(c))
+	}
+	tw.Flush()
+}
+
+// describeDelta shows a figure as "old → new (change)", or just the one value a removed or added resource has
+func describeDelta(status string, oldValue, newValue float64) string {
+	switch status {
+	case DiffAdded:
+		return fmt.Sprintf("%.2f", newValue)
+	case DiffRemoved:
+		return fmt.Sprintf("%.2f", oldValue)
+	}
+	return fmt.Sprintf("%.2f → %.2f (%s)", oldValue, newValue, signed(newValue-oldValue))
+}
+
+// describeCPUDelta shows the CPU utilization of resource types that report one
+func describeCPUDelta(c ResourceChange) string {
+	switch {
+	case c.OldCPU != nil && c.NewCPU != nil:
+		return fmt.Sprintf("%.1f → %.1f", *c.OldCPU, *c.NewCPU)
+	case c.OldCPU != nil:
+		return fmt.Sprintf("%.1f", *c.OldCPU)
+	case c.NewCPU != nil:
+		return fmt.Sprintf("%.1f", *c.NewCPU)
+	}
+	return "-"
+}
+
+// signed formats a change with an explicit sign
+func signed(delta float64) string {
+	if math.Abs(delta) < diffTolerance {
+		return "0.00"
+	}
+	return fmt.Sprintf("%+.2f", delta)
+}
@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// accountInstance is an EC2 report item scanned in an account with the given monthly cost
+func accountInstance(accountID, id string, cost float64) ReportItem {
+	return ReportItem{
+		ResourceType: ResourceTypeEC2,
+		Instance:     Instance{InstanceID: id, Region: "eu-west-1", AccountID: accountID},
+		MonthlyCost:  cost,
+	}
+}
+
+// accountSnapshots is the stale snapshots item of an account with the given monthly cost
+func accountSnapshots(accountID string, cost float64, ids ...string) ReportItem {
+	item := ReportItem{ResourceType: ResourceTypeSnapshots, MonthlyCost: cost}
+	for _, id := range ids {
+		item.Snapshots = append(item.Snapshots, EBSSnapshot{SnapshotID: id, Region: "eu-west-1", AccountID: accountID})
+	}
+	return item
+}
+
+// Organization reports list the resources of every account, whose IDs and stale snapshots
+// items are only unique within the account
+func TestDiffReportsAcrossAccounts(t *testing.T) {
+	oldReport := []ReportItem{
+		accountInstance("111111111111", "i-1", 100),
+		accountInstance("222222222222", "i-1", 50),
+		accountSnapshots("111111111111", 10, "snap-1"),
+		accountSnapshots("222222222222", 20, "snap-2", "snap-3"),
+	}
+	newReport := []ReportItem{
+		accountInstance("111111111111", "i-1", 80),
+		accountInstance("222222222222", "i-1", 50),
+		accountInstance("333333333333", "i-1", 30),
+		accountSnapshots("111111111111", 10, "snap-1"),
+	}
+
+	diff, err := DiffReports(oldReport, newReport)
+	if err != nil {
+		t.Fatalf("DiffReports() error = %v", err)
+	}
+
+	want := map[string]string{
+		"111111111111/ec2":       DiffChanged,
+		"222222222222/ec2":       DiffUnchanged,
+		"333333333333/ec2":       DiffAdded,
+		"111111111111/snapshots": DiffUnchanged,
+		"222222222222/snapshots": DiffRemoved,
+	}
+	if len(diff.Changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(diff.Changes), len(want), diff.Changes)
+	}
+	for _, change := range diff.Changes {
+		key := change.AccountID + "/" + string(change.ResourceType)
+		if change.Status != want[key] {
+			t.Errorf("%s %s is %q, want %q", key, change.ResourceID, change.Status, want[key])
+		}
+	}
+	if s := diff.Summary; s.Added != 1 || s.Removed != 1 || s.Changed != 1 || s.Unchanged != 2 {
+		t.Errorf("summary = %+v, want 1 added, 1 removed, 1 changed and 2 unchanged", s)
+	}
+	if got := diff.Summary.SavingsRealized; got != 40 {
+		t.Errorf("SavingsRealized = %v, want the 20 of the EC2 reduction plus the 20 of the removed snapshots", got)
+	}
+
+	var out bytes.Buffer
+	FormatReportDiff(&out, diff, false)
+	if !strings.Contains(out.String(), "ACCOUNT") || !strings.Contains(out.String(), "222222222222") {
+		t.Errorf("text diff does not name the accounts:\n%s", out.String())
+	}
+}
+
+func TestDiffReportsDuplicateInAccount(t *testing.T) {
+	report := []ReportItem{
+		accountInstance("111111111111", "i-1", 100),
+		accountInstance("111111111111", "i-1", 50),
+	}
+	if _, err := DiffReports(report, nil); err == nil || !strings.Contains(err.Error(), "listed more than once") {
+		t.Errorf("DiffReports() error = %v, want the duplicate reported", err)
+	}
+}
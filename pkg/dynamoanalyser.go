@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnalyzeDynamoTableWithBedrock uses Bedrock to generate optimization recommendations
+func AnalyzeDynamoTableWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	table DynamoTable,
+	embeddings []float64,
+) (InvokeResult, error) {
+	// Create a prompt with detailed table information
+	tableText, err := formatDynamoTableForPrompt(table)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is a DynamoDB table record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+Please analyze this DynamoDB table for sustainability and cost optimization.
+Your analysis must include:
+1) Calculate the monthly CO2 footprint considering provisioned or consumed capacity and stored data
+2) Estimate monthly cost from the billing mode (provisioned RCU/WCU hours or on-demand request units), storage and global secondary indexes
+3) Identify inefficiencies (provisioned capacity far above average and peak consumption, tables with no traffic, GSIs on unused tables, Standard table class for rarely read data)
+4) Compare provisioned and on-demand billing for the observed traffic: spiky or low traffic usually suits on-demand, steady high utilization suits provisioned with auto scaling
+5) Calculate potential savings from lowering provisioned capacity, switching billing mode, enabling auto scaling or moving to the Standard-IA table class
+6) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# DynamoDB Table Analysis: [TABLE_NAME]
+
+## Performance Metrics
+- Read Utilization (%[2]d-day avg): [PERCENTAGE or N/A for on-demand]
+- Write Utilization (%[2]d-day avg): [PERCENTAGE or N/A for on-demand]
+- Consumed RCU (%[2]d-day total): [NUMBER]
+- Consumed WCU (%[2]d-day total): [NUMBER]
+
+## Analysis
+
+[1-2 paragraphs general description]
+
+### Inefficiencies Identified
+
+1. [ISSUE 1]: [DESCRIPTION]
+2. [ISSUE 2]: [DESCRIPTION]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+2. [TIP 2]: [DESCRIPTION]
+`, tableText, EffectivePeriodDays(table.MetricsPeriodDays))
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeDynamoDB, prompt)
+}
+
+// formatDynamoTableForPrompt converts a DynamoDB table to a human-readable format for the LLM prompt
+func formatDynamoTableForPrompt(table DynamoTable) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Table Name: %s\n", table.TableName))
+	sb.WriteString(fmt.Sprintf("Status: %s\n", table.Status))
+	sb.WriteString(fmt.Sprintf("Billing Mode: %s\n", table.BillingMode))
+	sb.WriteString(fmt.Sprintf("Table Class: %s\n", table.TableClass))
+	if table.BillingMode == "PROVISIONED" {
+		sb.WriteString(fmt.Sprintf("Provisioned RCU: %d\n", table.ProvisionedRCU))
+		sb.WriteString(fmt.Sprintf("Provisioned WCU: %d\n", table.ProvisionedWCU))
+	}
+	sb.WriteString(fmt.Sprintf("Table Size: %.2f GB\n", float64(table.SizeBytes)/(1024*1024*1024)))
+	sb.WriteString(fmt.Sprintf("Item Count: %d\n", table.ItemCount))
+	sb.WriteString(fmt.Sprintf("Global Secondary Indexes: %d\n", table.GSICount))
+
+	if !table.CreationTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("Creation Time: %s\n", table.CreationTime.Format(time.RFC3339)))
+		age := time.Since(table.CreationTime)
+		sb.WriteString(fmt.Sprintf("Age: %.1f days\n", age.Hours()/24))
+	}
+
+	// Metrics
+	days := EffectivePeriodDays(table.MetricsPeriodDays)
+	sb.WriteString(fmt.Sprintf("Consumed RCU (%d-day total): %.0f\n", days, table.ConsumedRCU7d))
+	sb.WriteString(fmt.Sprintf("Consumed WCU (%d-day total): %.0f\n", days, table.ConsumedWCU7d))
+	sb.WriteString(fmt.Sprintf("Average Consumed RCU per second: %.2f (peak hourly average %.2f)\n", table.AvgConsumedRCU, table.PeakConsumedRCU))
+	sb.WriteString(fmt.Sprintf("Average Consumed WCU per second: %.2f (peak hourly average %.2f)\n", table.AvgConsumedWCU, table.PeakConsumedWCU))
+	if table.BillingMode == "PROVISIONED" {
+		sb.WriteString(fmt.Sprintf("Read Utilization (%d-day avg): %.1f%%\n", days, table.ReadUtilizationPct))
+		sb.WriteString(fmt.Sprintf("Write Utilization (%d-day avg): %.1f%%\n", days, table.WriteUtilizationPct))
+	}
+
+	// Tags
+	if len(table.Tags) > 0 {
+		sb.WriteString("\nTags:\n")
+		for k, v := range table.Tags {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", k, v))
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamoTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoTable holds metadata and computed metrics for a DynamoDB table
+// - ProvisionedRCU/ProvisionedWCU: table capacity; zero for on-demand tables
+// - ConsumedRCU7d/ConsumedWCU7d: total capacity units consumed over the metrics window
+// - AvgConsumedRCU/AvgConsumedWCU: average consumption per second over the window
+// - PeakConsumedRCU/PeakConsumedWCU: highest hourly average consumption per second
+// - ReadUtilizationPct/WriteUtilizationPct: average consumption as a share of provisioned capacity
+// - MetricsPeriodDays: length of the metrics window in days
+type DynamoTable struct {
+	TableName           string            `json:"tableName"`
+	TableArn            string            `json:"tableArn"`
+	Status              string            `json:"status"`
+	BillingMode         string            `json:"billingMode"`
+	TableClass          string            `json:"tableClass"`
+	ProvisionedRCU      int64             `json:"provisionedRCU"`
+	ProvisionedWCU      int64             `json:"provisionedWCU"`
+	SizeBytes           int64             `json:"sizeBytes"`
+	ItemCount           int64             `json:"itemCount"`
+	GSICount            int               `json:"gsiCount"`
+	CreationTime        time.Time         `json:"creationTime"`
+	Region              string            `json:"region"`
+	AccountID           string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	Tags                map[string]string `json:"tags"`
+	ConsumedRCU7d       float64           `json:"consumedRCU7d"`
+	ConsumedWCU7d       float64           `json:"consumedWCU7d"`
+	AvgConsumedRCU      float64           `json:"avgConsumedRCU"`
+	AvgConsumedWCU      float64           `json:"avgConsumedWCU"`
+	PeakConsumedRCU     float64           `json:"peakConsumedRCU"`
+	PeakConsumedWCU     float64           `json:"peakConsumedWCU"`
+	ReadUtilizationPct  float64           `json:"readUtilizationPct"`
+	WriteUtilizationPct float64           `json:"writeUtilizationPct"`
+	MetricsPeriodDays   int               `json:"metricsPeriodDays"`
+}
+
+// ListDynamoTables retrieves all DynamoDB tables and their consumed capacity over the last daysBack days
+func ListDynamoTables(
+	ctx context.Context,
+	dynamoClient DynamoTablesAPI,
+	cwClient CloudWatchMetricsAPI,
+	maxTables int,
+	daysBack int,
+) ([]DynamoTable, error) {
+	// Get list of table names
+	var tableNames []string
+	var startTable *string
+
+	for {
+		resp, err := dynamoClient.ListTables(ctx, &dynamodb.ListTablesInput{
+			ExclusiveStartTableName: startTable,
+			Limit:                   aws.Int32(100),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		tableNames = append(tableNames, resp.TableNames...)
+
+		// Check if there are more pages
+		if resp.LastEvaluatedTableName == nil {
+			break
+		}
+		startTable = resp.LastEvaluatedTableName
+	}
+
+	// Apply limit if specified
+	if maxTables > 0 && len(tableNames) > maxTables {
+		Infof("Limiting DynamoDB scan to %d tables (found %d)", maxTables, len(tableNames))
+		tableNames = tableNames[:maxTables]
+	} else {
+		Infof("Processing %d DynamoDB tables", len(tableNames))
+	}
+
+	// Define time window for metrics: last daysBack days
+	daysBack = EffectivePeriodDays(daysBack)
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack)
+
+	// Process tables in parallel with a worker pool
+	results := make([]DynamoTable, 0, len(tableNames))
+	resultsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
+
+	for _, tableName := range tableNames {
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Set a timeout for processing each table
+			tableCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			table, err := collectDynamoTableData(tableCtx, dynamoClient, cwClient, name, startTime, endTime, daysBack)
+			if err != nil {
+				Warnf("Unable to describe DynamoDB table %s: %v", name, err)
+				return
+			}
+			table.MetricsPeriodDays = daysBack
+
+			// Add to results
+			resultsMutex.Lock()
+			results = append(results, table)
+			resultsMutex.Unlock()
+		}(tableName)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+
+	return results, nil
+}
+
+// collectDynamoTableData gathers all relevant data for a single DynamoDB table
+func collectDynamoTableData(
+	ctx context.Context,
+	dynamoClient DynamoTablesAPI,
+	cwClient CloudWatchMetricsAPI,
+	tableName string,
+	startTime, endTime time.Time,
+	daysBack int,
+) (DynamoTable, error) {
+	resp, err := dynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return DynamoTable{}, err
+	}
+	t := resp.Table
+
+	table := DynamoTable{
+		TableName: tableName,
+		TableArn:  aws.ToString(t.TableArn),
+		Status:    string(t.TableStatus),
+		// Tables created before on-demand existed report no billing mode summary
+		BillingMode: string(dynamoTypes.BillingModeProvisioned),
+		TableClass:  string(dynamoTypes.TableClassStandard),
+		SizeBytes:   aws.ToInt64(t.TableSizeBytes),
+		ItemCount:   aws.ToInt64(t.ItemCount),
+		GSICount:    len(t.GlobalSecondaryIndexes),
+		Region:      dynamoClient.Options().Region,
+		Tags:        make(map[string]string),
+	}
+	if t.BillingModeSummary != nil && t.BillingModeSummary.BillingMode != "" {
+		table.BillingMode = string(t.BillingModeSummary.BillingMode)
+	}
+	if t.TableClassSummary != nil && t.TableClassSummary.TableClass != "" {
+		table.TableClass = string(t.TableClassSummary.TableClass)
+	}
+	if t.ProvisionedThroughput != nil {
+		table.ProvisionedRCU = aws.ToInt64(t.ProvisionedThroughput.ReadCapacityUnits)
+		table.ProvisionedWCU = aws.ToInt64(t.ProvisionedThroughput.WriteCapacityUnits)
+	}
+	if t.CreationDateTime != nil {
+		table.CreationTime = *t.CreationDateTime
+	}
+
+	// Get tags
+	tagsResp, err := dynamoClient.ListTagsOfResource(ctx, &dynamodb.ListTagsOfResourceInput{
+		ResourceArn: t.TableArn,
+	})
+	if err != nil {
+		Warnf("Unable to get tags for DynamoDB table %s: %v", tableName, err)
+	} else {
+		for _, tag := range tagsResp.Tags {
+			table.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	// Hourly sums show peaks; fall back to daily sums when the window exceeds the 1,440 datapoint limit
+	period := int32(3600)
+	if daysBack*24 > 1440 {
+		period = 86400
+	}
+	seconds := float64(daysBack * 86400)
+
+	readSum, readPeak, err := getDynamoConsumedCapacity(ctx, cwClient, tableName, "ConsumedReadCapacityUnits", period, startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get consumed read capacity for DynamoDB table %s: %v", tableName, err)
+	}
+	table.ConsumedRCU7d = readSum
+	table.AvgConsumedRCU = readSum / seconds
+	table.PeakConsumedRCU = readPeak
+
+	writeSum, writePeak, err := getDynamoConsumedCapacity(ctx, cwClient, tableName, "ConsumedWriteCapacityUnits", period, startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get consumed write capacity for DynamoDB table %s: %v", tableName, err)
+	}
+	table.ConsumedWCU7d = writeSum
+	table.AvgConsumedWCU = writeSum / seconds
+	table.PeakConsumedWCU = writePeak
+
+	if table.ProvisionedRCU > 0 {
+		table.ReadUtilizationPct = table.AvgConsumedRCU / float64(table.ProvisionedRCU) * 100
+	}
+	if table.ProvisionedWCU > 0 {
+		table.WriteUtilizationPct = table.AvgConsumedWCU / float64(table.ProvisionedWCU) * 100
+	}
+
+	return table, nil
+}
+
+// getDynamoConsumedCapacity returns the total of a consumed capacity metric for a table and
+// the highest per-second average of any single period
+func getDynamoConsumedCapacity(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	tableName, metricName string,
+	period int32,
+	startTime, endTime time.Time,
+) (float64, float64, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/DynamoDB"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.Dimension{{
+			Name:  aws.String("TableName"),
+			Value: aws.String(tableName),
+		}},
+		StartTime:  &startTime,
+		EndTime:    &endTime,
+		Period:     aws.Int32(period),
+		Statistics: []types.Statistic{types.StatisticSum},
+	}
+
+	resp, err := cwClient.GetMetricStatistics(ctx, input)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total, peak float64
+	for _, dp := range resp.Datapoints {
+		sum := aws.ToFloat64(dp.Sum)
+		total += sum
+		peak = max(peak, sum/float64(period))
+	}
+
+	return total, peak, nil
+}
@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnalyzeEBSVolumeWithBedrock uses Bedrock to generate optimization recommendations
+func AnalyzeEBSVolumeWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	volume EBSVolume,
+	embeddings []float64,
+) (InvokeResult, error) {
+	// Create a prompt with detailed volume information
+	volumeText, err := formatEBSVolumeForPrompt(volume)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is an EBS volume record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+Please analyze this EBS volume for sustainability and cost optimization.
+Your analysis must include:
+1) Calculate the monthly CO2 footprint considering volume type and size
+2) Estimate monthly cost based on volume type, size, provisioned IOPS and throughput
+3) Identify inefficiencies (unattached volume, no I/O activity, over-provisioned IOPS, previous-generation type)
+4) Calculate potential savings from deleting, snapshotting, resizing or migrating the volume (e.g. gp2 to gp3)
+5) Suggest specific actions for optimization
+6) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# EBS Volume Analysis: [VOLUME_ID]
+
+## Performance Metrics
+- Read Operations (%[2]d-day total): [NUMBER]
+- Write Operations (%[2]d-day total): [NUMBER]
+- Attachment State: [ATTACHED/UNATTACHED]
+
+## Analysis
+
+[1-2 paragraphs general description]
+
+### Inefficiencies Identified
+
+1. [ISSUE 1]: [DESCRIPTION]
+2. [ISSUE 2]: [DESCRIPTION]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+2. [TIP 2]: [DESCRIPTION]
+`, volumeText, EffectivePeriodDays(volume.MetricsPeriodDays))
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeEBS, prompt)
+}
+
+// formatEBSVolumeForPrompt converts an EBS volume to a human-readable format for the LLM prompt
+func formatEBSVolumeForPrompt(volume EBSVolume) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Volume ID: %s\n", volume.VolumeID))
+	sb.WriteString(fmt.Sprintf("Volume Type: %s\n", volume.VolumeType))
+	sb.WriteString(fmt.Sprintf("Size: %d GiB\n", volume.SizeGiB))
+	if volume.IOPS > 0 {
+		sb.WriteString(fmt.Sprintf("Provisioned IOPS: %d\n", volume.IOPS))
+	}
+	if volume.Throughput > 0 {
+		sb.WriteString(fmt.Sprintf("Provisioned Throughput: %d MiB/s\n", volume.Throughput))
+	}
+	sb.WriteString(fmt.Sprintf("State: %s\n", volume.State))
+	sb.WriteString(fmt.Sprintf("Encrypted: %t\n", volume.Encrypted))
+	sb.WriteString(fmt.Sprintf("Availability Zone: %s\n", volume.AvailabilityZone))
+
+	if volume.Attached {
+		sb.WriteString(fmt.Sprintf("Attached To: %s\n", volume.AttachedInstanceID))
+	} else {
+		sb.WriteString("Attached To: none (unattached)\n")
+	}
+
+	if !volume.CreateTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("Create Time: %s\n", volume.CreateTime.Format(time.RFC3339)))
+		age := time.Since(volume.CreateTime)
+		sb.WriteString(fmt.Sprintf("Age: %.1f days\n", age.Hours()/24))
+	}
+
+	// Metrics
+	days := EffectivePeriodDays(volume.MetricsPeriodDays)
+	sb.WriteString(fmt.Sprintf("Read Operations (%d-day total): %.0f\n", days, volume.ReadOps7d))
+	sb.WriteString(fmt.Sprintf("Write Operations (%d-day total): %.0f\n", days, volume.WriteOps7d))
+	sb.WriteString(fmt.Sprintf("Idle (no I/O in %d days): %t\n", days, volume.Idle))
+
+	// Tags
+	if len(volume.Tags) > 0 {
+		sb.WriteString("\nTags:\n")
+		for k, v := range volume.Tags {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", k, v))
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// EBSVolume holds metadata and computed metrics for an EBS volume
+// - Attached: whether the volume is attached to any instance
+// - ReadOps7d/WriteOps7d: total read/write operations over the metrics window
+// - Idle: true when the volume saw no read or write operations in the window
+// - MetricsPeriodDays: length of the metrics window in days
+type EBSVolume struct {
+	VolumeID           string            `json:"volumeId"`
+	SizeGiB            int32             `json:"sizeGiB"`
+	VolumeType         string            `json:"volumeType"`
+	IOPS               int32             `json:"iops"`
+	Throughput         int32             `json:"throughput"`
+	State              string            `json:"state"`
+	Encrypted          bool              `json:"encrypted"`
+	Attached           bool              `json:"attached"`
+	AttachedInstanceID string            `json:"attachedInstanceId,omitempty"`
+	AvailabilityZone   string            `json:"availabilityZone"`
+	Region             string            `json:"region"`
+	AccountID          string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	CreateTime         time.Time         `json:"createTime"`
+	Tags               map[string]string `json:"tags"`
+	ReadOps7d          float64           `json:"readOps7d"`
+	WriteOps7d         float64           `json:"writeOps7d"`
+	Idle               bool              `json:"idle"`
+	MetricsPeriodDays  int               `json:"metricsPeriodDays"`
+}
+
+// ListVolumes retrieves all EBS volumes and their I/O activity over the last daysBack days
+func ListVolumes(
+	ctx context.Context,
+	ec2Client EC2DescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	maxVolumes int,
+	daysBack int,
+) ([]EBSVolume, error) {
+	// Get list of volumes
+	var volumes []ec2Types.Volume
+	var nextToken *string
+
+	for {
+		resp, err := ec2Client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+			NextToken:  nextToken,
+			MaxResults: aws.Int32(500),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		volumes = append(volumes, resp.Volumes...)
+
+		// Check if there are more pages
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+
+	// Apply limit if specified
+	if maxVolumes > 0 && len(volumes) > maxVolumes {
+		Infof("Limiting EBS scan to %d volumes (found %d)", maxVolumes, len(volumes))
+		volumes = volumes[:maxVolumes]
+	} else {
+		Infof("Processing %d EBS volumes", len(volumes))
+	}
+
+	// Define time window for metrics: last daysBack days
+	daysBack = EffectivePeriodDays(daysBack)
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack)
+
+	// Process volumes in parallel with a worker pool
+	results := make([]EBSVolume, 0, len(volumes))
+	resultsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
+
+	for _, volume := range volumes {
+		wg.Add(1)
+
+		go func(v ec2Types.Volume) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Set a timeout for processing each volume
+			volCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			ebsVolume := collectVolumeData(volCtx, ec2Client, cwClient, v, startTime, endTime)
+			ebsVolume.MetricsPeriodDays = daysBack
+
+			// Add to results
+			resultsMutex.Lock()
+			results = append(results, ebsVolume)
+			resultsMutex.Unlock()
+		}(volume)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+
+	return results, nil
+}
+
+// collectVolumeData gathers all relevant data for a single EBS volume
+func collectVolumeData(
+	ctx context.Context,
+	ec2Client EC2DescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	v ec2Types.Volume,
+	startTime, endTime time.Time,
+) EBSVolume {
+	volumeID := aws.ToString(v.VolumeId)
+
+	volume := EBSVolume{
+		VolumeID:         volumeID,
+		SizeGiB:          aws.ToInt32(v.Size),
+		VolumeType:       string(v.VolumeType),
+		IOPS:             aws.ToInt32(v.Iops),
+		Throughput:       aws.ToInt32(v.Throughput),
+		State:            string(v.State),
+		Encrypted:        aws.ToBool(v.Encrypted),
+		AvailabilityZone: aws.ToString(v.AvailabilityZone),
+		Region:           ec2Client.Options().Region,
+		Tags:             parseTags(v.Tags),
+	}
+
+	if v.CreateTime != nil {
+		volume.CreateTime = *v.CreateTime
+	}
+
+	// Record attachment state explicitly; unattached volumes are pure waste
+	for _, attachment := range v.Attachments {
+		if attachment.InstanceId != nil {
+			volume.Attached = true
+			volume.AttachedInstanceID = *attachment.InstanceId
+			break
+		}
+	}
+
+	// Get read and write operations
+	readOps, err := getEBSMetricSum(ctx, cwClient, volumeID, "VolumeReadOps", startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get read ops for EBS volume %s: %v", volumeID, err)
+	}
+	volume.ReadOps7d = readOps
+
+	writeOps, err := getEBSMetricSum(ctx, cwClient, volumeID, "VolumeWriteOps", startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get write ops for EBS volume %s: %v", volumeID, err)
+	}
+	volume.WriteOps7d = writeOps
+
+	volume.Idle = readOps == 0 && writeOps == 0
+
+	return volume
+}
+
+// getEBSMetricSum retrieves the total of a CloudWatch metric for an EBS volume
+func getEBSMetricSum(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	volumeID, metricName string,
+	startTime, endTime time.Time,
+) (float64, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/EBS"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.Dimension{{
+			Name:  aws.String("VolumeId"),
+			Value: aws.String(volumeID),
+		}},
+		StartTime:  &startTime,
+		EndTime:    &endTime,
+		Period:     aws.Int32(86400), // 1 day granularity
+		Statistics: []types.Statistic{types.StatisticSum},
+	}
+
+	resp, err := cwClient.GetMetricStatistics(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sum up all datapoints
+	var total float64
+	for _, dp := range resp.Datapoints {
+		if dp.Sum != nil {
+			total += *dp.Sum
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnalyzeElastiCacheWithBedrock uses Bedrock to generate optimization recommendations
+func AnalyzeElastiCacheWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	cluster ElastiCacheCluster,
+	embeddings []float64,
+) (InvokeResult, error) {
+	// Create a prompt with detailed cluster information
+	clusterText, err := formatElastiCacheForPrompt(cluster)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is an ElastiCache cluster record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+Please analyze this ElastiCache cluster for sustainability and cost optimization.
+Your analysis must include:
+1) Calculate the monthly CO2 footprint considering node type and node count
+2) Estimate monthly on-demand cost from node type, node count and engine
+3) Identify inefficiencies. A cluster averaging fewer than one connection over the %[2]d-day window serves no clients and MUST be called out as a SHUTDOWN CANDIDATE, with its full monthly cost as the savings. Also look for low CPU and memory usage, more nodes than the load needs and previous-generation node types
+4) Calculate potential savings from rightsizing to a smaller or Graviton (r7g, m7g, t4g) node type, reducing node count, or buying reserved nodes for steady, long-running clusters
+5) Suggest specific actions for optimization
+6) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# ElastiCache Cluster Analysis: [CLUSTER_ID]
+
+## Performance Metrics
+- CPU Utilization (%[2]d-day avg): [PERCENTAGE]
+- Memory Usage (%[2]d-day avg): [PERCENTAGE or N/A for Memcached]
+- Connections (%[2]d-day avg): [NUMBER]
+
+## Analysis
+
+[1-2 paragraphs general description]
+
+### Inefficiencies Identified
+
+1. [ISSUE 1]: [DESCRIPTION]
+2. [ISSUE 2]: [DESCRIPTION]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+2. [TIP 2]: [DESCRIPTION]
+`, clusterText, EffectivePeriodDays(cluster.MetricsPeriodDays))
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeElastiCache, prompt)
+}
+
+// formatElastiCacheForPrompt converts an ElastiCache cluster to a human-readable format for the LLM prompt
+func formatElastiCacheForPrompt(cluster ElastiCacheCluster) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Cluster ID: %s\n", cluster.ClusterID))
+	sb.WriteString(fmt.Sprintf("Engine: %s %s\n", cluster.Engine, cluster.EngineVersion))
+	sb.WriteString(fmt.Sprintf("Node Type: %s\n", cluster.NodeType))
+	sb.WriteString(fmt.Sprintf("Node Count: %d\n", cluster.NodeCount))
+	sb.WriteString(fmt.Sprintf("Status: %s\n", cluster.Status))
+	if cluster.ReplicationGroupID != "" {
+		sb.WriteString(fmt.Sprintf("Replication Group: %s\n", cluster.ReplicationGroupID))
+	}
+	sb.WriteString(fmt.Sprintf("Multi-AZ: %t\n", cluster.MultiAZ))
+
+	if !cluster.CreateTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("Create Time: %s\n", cluster.CreateTime.Format(time.RFC3339)))
+		age := time.Since(cluster.CreateTime)
+		sb.WriteString(fmt.Sprintf("Age: %.1f days\n", age.Hours()/24))
+	}
+
+	// Metrics
+	days := EffectivePeriodDays(cluster.MetricsPeriodDays)
+	sb.WriteString(fmt.Sprintf("CPU Utilization (%d-day avg): %.2f%%\n", days, cluster.CPUAvg7d))
+	if cluster.Engine != "memcached" {
+		sb.WriteString(fmt.Sprintf("Memory Usage (%d-day avg): %.2f%%\n", days, cluster.MemoryUsageAvg7d))
+	}
+	sb.WriteString(fmt.Sprintf("Connections (%d-day avg): %.1f\n", days, cluster.ConnectionsAvg7d))
+	sb.WriteString(fmt.Sprintf("Connections (%d-day max): %.0f\n", days, cluster.ConnectionsMax7d))
+	sb.WriteString(fmt.Sprintf("Idle (almost no connections in %d days): %t\n", days, cluster.Idle))
+
+	// Tags
+	if len(cluster.Tags) > 0 {
+		sb.WriteString("\nTags:\n")
+		for k, v := range cluster.Tags {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", k, v))
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,263 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	elasticacheTypes "github.com/aws/aws-sdk-go-v2/service/elasticache/types"
+)
+
+// elastiCacheIdleConnections is the average connection count below which a cluster counts as unused
+const elastiCacheIdleConnections = 1.0
+
+// ElastiCacheCluster holds metadata and computed metrics for an ElastiCache cluster
+// - ReplicationGroupID/MultiAZ: Redis replication group the cluster belongs to, if any, and whether it fails over across AZs
+// - MemoryUsageAvg7d: average DatabaseMemoryUsagePercentage; Redis only, zero for Memcached
+// - ConnectionsAvg7d/ConnectionsMax7d: average and peak CurrConnections over the metrics window
+// - Idle: true when the cluster averaged almost no connections over the window
+// - MetricsPeriodDays: length of the metrics window in days
+type ElastiCacheCluster struct {
+	ClusterID          string            `json:"clusterId"`
+	ARN                string            `json:"arn"`
+	Engine             string            `json:"engine"`
+	EngineVersion      string            `json:"engineVersion"`
+	NodeType           string            `json:"nodeType"`
+	NodeCount          int32             `json:"nodeCount"`
+	Status             string            `json:"status"`
+	ReplicationGroupID string            `json:"replicationGroupId,omitempty"`
+	MultiAZ            bool              `json:"multiAZ"`
+	AvailabilityZone   string            `json:"availabilityZone"`
+	CreateTime         time.Time         `json:"createTime"`
+	Region             string            `json:"region"`
+	AccountID          string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	Tags               map[string]string `json:"tags"`
+	CPUAvg7d           float64           `json:"cpuAvg7d"`
+	MemoryUsageAvg7d   float64           `json:"memoryUsageAvg7d"`
+	ConnectionsAvg7d   float64           `json:"connectionsAvg7d"`
+	ConnectionsMax7d   float64           `json:"connectionsMax7d"`
+	Idle               bool              `json:"idle"`
+	MetricsPeriodDays  int               `json:"metricsPeriodDays"`
+}
+
+// ListElastiCacheClusters retrieves all Redis and Memcached clusters and their metrics over the last daysBack days
+func ListElastiCacheClusters(
+	ctx context.Context,
+	cacheClient ElastiCacheDescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	maxClusters int,
+	daysBack int,
+) ([]ElastiCacheCluster, error) {
+	// Get list of clusters
+	var clusters []elasticacheTypes.CacheCluster
+	var marker *string
+
+	for {
+		resp, err := cacheClient.DescribeCacheClusters(ctx, &elasticache.DescribeCacheClustersInput{
+			Marker:     marker,
+			MaxRecords: aws.Int32(100),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		clusters = append(clusters, resp.CacheClusters...)
+
+		// Check if there are more pages
+		if resp.Marker == nil {
+			break
+		}
+		marker = resp.Marker
+	}
+
+	// Apply limit if specified
+	if maxClusters > 0 && len(clusters) > maxClusters {
+		Infof("Limiting ElastiCache scan to %d clusters (found %d)", maxClusters, len(clusters))
+		clusters = clusters[:maxClusters]
+	} else {
+		Infof("Processing %d ElastiCache clusters", len(clusters))
+	}
+
+	// Multi-AZ is a property of the replication group, not the cluster
+	multiAZ, err := listReplicationGroupMultiAZ(ctx, cacheClient)
+	if err != nil {
+		Warnf("Unable to describe ElastiCache replication groups: %v", err)
+	}
+
+	// Define time window for metrics: last daysBack days
+	daysBack = EffectivePeriodDays(daysBack)
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack)
+
+	// Process clusters in parallel with a worker pool
+	results := make([]ElastiCacheCluster, 0, len(clusters))
+	resultsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
+
+	for _, cluster := range clusters {
+		wg.Add(1)
+
+		go func(c elasticacheTypes.CacheCluster) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Set a timeout for processing each cluster
+			clusterCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			cacheCluster := collectElastiCacheData(clusterCtx, cacheClient, cwClient, c, startTime, endTime)
+			cacheCluster.MultiAZ = multiAZ[cacheCluster.ReplicationGroupID]
+			cacheCluster.MetricsPeriodDays = daysBack
+
+			// Add to results
+			resultsMutex.Lock()
+			results = append(results, cacheCluster)
+			resultsMutex.Unlock()
+		}(cluster)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+
+	return results, nil
+}
+
+// listReplicationGroupMultiAZ reports, per replication group ID, whether Multi-AZ is enabled
+func listReplicationGroupMultiAZ(ctx context.Context, cacheClient ElastiCacheDescribeAPI) (map[string]bool, error) {
+	multiAZ := make(map[string]bool)
+	var marker *string
+	for {
+		resp, err := cacheClient.DescribeReplicationGroups(ctx, &elasticache.DescribeReplicationGroupsInput{
+			Marker:     marker,
+			MaxRecords: aws.Int32(100),
+		})
+		if err != nil {
+			return multiAZ, err
+		}
+
+		for _, group := range resp.ReplicationGroups {
+			multiAZ[aws.ToString(group.ReplicationGroupId)] = group.MultiAZ == elasticacheTypes.MultiAZStatusEnabled
+		}
+
+		if resp.Marker == nil {
+			break
+		}
+		marker = resp.Marker
+	}
+	return multiAZ, nil
+}
+
+// collectElastiCacheData gathers all relevant data for a single ElastiCache cluster
+func collectElastiCacheData(
+	ctx context.Context,
+	cacheClient ElastiCacheDescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	c elasticacheTypes.CacheCluster,
+	startTime, endTime time.Time,
+) ElastiCacheCluster {
+	clusterID := aws.ToString(c.CacheClusterId)
+
+	cluster := ElastiCacheCluster{
+		ClusterID:          clusterID,
+		ARN:                aws.ToString(c.ARN),
+		Engine:             aws.ToString(c.Engine),
+		EngineVersion:      aws.ToString(c.EngineVersion),
+		NodeType:           aws.ToString(c.CacheNodeType),
+		NodeCount:          aws.ToInt32(c.NumCacheNodes),
+		Status:             aws.ToString(c.CacheClusterStatus),
+		ReplicationGroupID: aws.ToString(c.ReplicationGroupId),
+		AvailabilityZone:   aws.ToString(c.PreferredAvailabilityZone),
+		Region:             cacheClient.Options().Region,
+		Tags:               make(map[string]string),
+	}
+
+	if c.CacheClusterCreateTime != nil {
+		cluster.CreateTime = *c.CacheClusterCreateTime
+	}
+
+	// Get tags
+	tagsResp, err := cacheClient.ListTagsForResource(ctx, &elasticache.ListTagsForResourceInput{
+		ResourceName: c.ARN,
+	})
+	if err != nil {
+		Warnf("Unable to get tags for ElastiCache cluster %s: %v", clusterID, err)
+	} else {
+		for _, tag := range tagsResp.TagList {
+			cluster.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	// Get CPU metrics
+	cpuAvg, _, err := getElastiCacheMetric(ctx, cwClient, clusterID, "CPUUtilization", startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get CPU metrics for ElastiCache cluster %s: %v", clusterID, err)
+	}
+	cluster.CPUAvg7d = cpuAvg
+
+	// Memory usage is only published by Redis
+	if cluster.Engine != "memcached" {
+		memAvg, _, err := getElastiCacheMetric(ctx, cwClient, clusterID, "DatabaseMemoryUsagePercentage", startTime, endTime)
+		if err != nil {
+			Warnf("Unable to get memory metrics for ElastiCache cluster %s: %v", clusterID, err)
+		}
+		cluster.MemoryUsageAvg7d = memAvg
+	}
+
+	connAvg, connMax, err := getElastiCacheMetric(ctx, cwClient, clusterID, "CurrConnections", startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get connection metrics for ElastiCache cluster %s: %v", clusterID, err)
+	} else {
+		cluster.ConnectionsAvg7d = connAvg
+		cluster.ConnectionsMax7d = connMax
+		cluster.Idle = connAvg < elastiCacheIdleConnections
+	}
+
+	return cluster
+}
+
+// getElastiCacheMetric retrieves the average and maximum of a CloudWatch metric for a cache cluster
+func getElastiCacheMetric(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	clusterID, metricName string,
+	startTime, endTime time.Time,
+) (float64, float64, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/ElastiCache"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.Dimension{{
+			Name:  aws.String("CacheClusterId"),
+			Value: aws.String(clusterID),
+		}},
+		StartTime:  &startTime,
+		EndTime:    &endTime,
+		Period:     aws.Int32(86400), // 1 day granularity
+		Statistics: []types.Statistic{types.StatisticAverage, types.StatisticMaximum},
+	}
+
+	resp, err := cwClient.GetMetricStatistics(ctx, input)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(resp.Datapoints) == 0 {
+		return 0, 0, nil
+	}
+
+	// Average the daily averages and keep the highest maximum
+	var total, peak float64
+	for _, dp := range resp.Datapoints {
+		total += aws.ToFloat64(dp.Average)
+		peak = max(peak, aws.ToFloat64(dp.Maximum))
+	}
+
+	return total / float64(len(resp.Datapoints)), peak, nil
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnalyzeLoadBalancerWithBedrock uses Bedrock to generate optimization recommendations
+func AnalyzeLoadBalancerWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	loadBalancer LoadBalancer,
+	embeddings []float64,
+) (InvokeResult, error) {
+	// Create a prompt with detailed load balancer information
+	lbText, err := formatLoadBalancerForPrompt(loadBalancer)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is an Elastic Load Balancer record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+Please analyze this load balancer for sustainability and cost optimization.
+Your analysis must include:
+1) Calculate the monthly CO2 footprint of the always-on load balancer capacity
+2) Estimate monthly cost from the hourly charge (about $0.0225 per hour, roughly $16.43 per month, for application and network load balancers) plus capacity units for the observed traffic
+3) Identify inefficiencies. A load balancer with zero requests or flows, or with zero healthy targets, over the %[2]d-day window serves no traffic and MUST be called out as a DELETION CANDIDATE, with its full monthly cost as the savings
+4) Calculate potential savings from deleting idle load balancers or consolidating lightly used ones behind a single load balancer with host or path based routing
+5) Suggest specific actions for optimization
+6) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# Load Balancer Analysis: [LOAD_BALANCER_NAME]
+
+## Performance Metrics
+- Requests (%[2]d-day total): [NUMBER or N/A]
+- Active Flows (%[2]d-day average): [NUMBER or N/A]
+- Healthy Targets (%[2]d-day average): [NUMBER]
+- Target Groups: [NUMBER]
+
+## Analysis
+
+[1-2 paragraphs general description]
+
+### Inefficiencies Identified
+
+1. [ISSUE 1]: [DESCRIPTION]
+2. [ISSUE 2]: [DESCRIPTION]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+2. [TIP 2]: [DESCRIPTION]
+`, lbText, EffectivePeriodDays(loadBalancer.MetricsPeriodDays))
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeELB, prompt)
+}
+
+// formatLoadBalancerForPrompt converts a load balancer to a human-readable format for the LLM prompt
+func formatLoadBalancerForPrompt(loadBalancer LoadBalancer) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Load Balancer Name: %s\n", loadBalancer.Name))
+	sb.WriteString(fmt.Sprintf("Type: %s\n", loadBalancer.Type))
+	sb.WriteString(fmt.Sprintf("Scheme: %s\n", loadBalancer.Scheme))
+	sb.WriteString(fmt.Sprintf("State: %s\n", loadBalancer.State))
+	if loadBalancer.VPCID != "" {
+		sb.WriteString(fmt.Sprintf("VPC: %s\n", loadBalancer.VPCID))
+	}
+
+	if !loadBalancer.CreatedTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("Created Time: %s\n", loadBalancer.CreatedTime.Format(time.RFC3339)))
+		age := time.Since(loadBalancer.CreatedTime)
+		sb.WriteString(fmt.Sprintf("Age: %.1f days\n", age.Hours()/24))
+	}
+
+	// Metrics
+	days := EffectivePeriodDays(loadBalancer.MetricsPeriodDays)
+	sb.WriteString(fmt.Sprintf("Target Groups: %d\n", loadBalancer.TargetGroupCount))
+	if loadBalancer.Type == "application" {
+		sb.WriteString(fmt.Sprintf("Requests (%d-day total): %.0f\n", days, loadBalancer.RequestCount7d))
+	} else {
+		sb.WriteString(fmt.Sprintf("Active Flows (%d-day average): %.1f\n", days, loadBalancer.ActiveFlowCountAvg))
+	}
+	sb.WriteString(fmt.Sprintf("Healthy Targets (%d-day average): %.1f\n", days, loadBalancer.HealthyHostCountAvg))
+	sb.WriteString(fmt.Sprintf("Idle (no traffic in %d days): %t\n", days, loadBalancer.Idle))
+
+	// Tags
+	if len(loadBalancer.Tags) > 0 {
+		sb.WriteString("\nTags:\n")
+		for k, v := range loadBalancer.Tags {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", k, v))
+		}
+	}
+
+	return sb.String(), nil
+}
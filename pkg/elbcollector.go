@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	elb "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbTypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// LoadBalancer holds metadata and computed metrics for an Application, Network or Gateway Load Balancer
+// - RequestCount7d: total requests over the metrics window (application load balancers only)
+// - ActiveFlowCountAvg: average concurrent flows (network and gateway load balancers only)
+// - HealthyHostCountAvg: average healthy targets summed across the attached target groups
+// - Idle: true when the load balancer handled no traffic in the window
+// - MetricsPeriodDays: length of the metrics window in days
+type LoadBalancer struct {
+	Name                string            `json:"name"`
+	ARN                 string            `json:"arn"`
+	Type                string            `json:"type"`
+	Scheme              string            `json:"scheme"`
+	State               string            `json:"state"`
+	VPCID               string            `json:"vpcId"`
+	Region              string            `json:"region"`
+	AccountID           string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	CreatedTime         time.Time         `json:"createdTime"`
+	Tags                map[string]string `json:"tags"`
+	TargetGroupCount    int               `json:"targetGroupCount"`
+	RequestCount7d      float64           `json:"requestCount7d"`
+	ActiveFlowCountAvg  float64           `json:"activeFlowCountAvg"`
+	HealthyHostCountAvg float64           `json:"healthyHostCountAvg"`
+	Idle                bool              `json:"idle"`
+	MetricsPeriodDays   int               `json:"metricsPeriodDays"`
+}
+
+// elbNamespaces maps load balancer types to their CloudWatch namespaces
+var elbNamespaces = map[string]string{
+	string(elbTypes.LoadBalancerTypeEnumApplication): "AWS/ApplicationELB",
+	string(elbTypes.LoadBalancerTypeEnumNetwork):     "AWS/NetworkELB",
+	string(elbTypes.LoadBalancerTypeEnumGateway):     "AWS/GatewayELB",
+}
+
+// ListLoadBalancers retrieves all v2 load balancers and their traffic over the last daysBack days
+func ListLoadBalancers(
+	ctx context.Context,
+	elbClient ELBDescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	maxLoadBalancers int,
+	daysBack int,
+) ([]LoadBalancer, error) {
+	// Get list of load balancers
+	var loadBalancers []elbTypes.LoadBalancer
+	var marker *string
+
+	for {
+		resp, err := elbClient.DescribeLoadBalancers(ctx, &elb.DescribeLoadBalancersInput{
+			Marker:   marker,
+			PageSize: aws.Int32(400),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		loadBalancers = append(loadBalancers, resp.LoadBalancers...)
+
+		// Check if there are more pages
+		if resp.NextMarker == nil {
+			break
+		}
+		marker = resp.NextMarker
+	}
+
+	// Apply limit if specified
+	if maxLoadBalancers > 0 && len(loadBalancers) > maxLoadBalancers {
+		Infof("Limiting ELB scan to %d load balancers (found %d)", maxLoadBalancers, len(loadBalancers))
+		loadBalancers = loadBalancers[:maxLoadBalancers]
+	} else {
+		Infof("Processing %d load balancers", len(loadBalancers))
+	}
+
+	// Define time window for metrics: last daysBack days
+	daysBack = EffectivePeriodDays(daysBack)
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack)
+
+	// Process load balancers in parallel with a worker pool
+	results := make([]LoadBalancer, 0, len(loadBalancers))
+	resultsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
+
+	for _, loadBalancer := range loadBalancers {
+		wg.Add(1)
+
+		go func(lb elbTypes.LoadBalancer) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Set a timeout for processing each load balancer
+			lbCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			loadBalancer := collectLoadBalancerData(lbCtx, elbClient, cwClient, lb, startTime, endTime)
+			loadBalancer.MetricsPeriodDays = daysBack
+
+			// Add to results
+			resultsMutex.Lock()
+			results = append(results, loadBalancer)
+			resultsMutex.Unlock()
+		}(loadBalancer)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+
+	return results, nil
+}
+
+// collectLoadBalancerData gathers all relevant data for a single load balancer
+func collectLoadBalancerData(
+	ctx context.Context,
+	elbClient ELBDescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	lb elbTypes.LoadBalancer,
+	startTime, endTime time.Time,
+) LoadBalancer {
+	name := aws.ToString(lb.LoadBalancerName)
+	arn := aws.ToString(lb.LoadBalancerArn)
+
+	loadBalancer := LoadBalancer{
+		Name:   name,
+		ARN:    arn,
+		Type:   string(lb.Type),
+		Scheme: string(lb.Scheme),
+		VPCID:  aws.ToString(lb.VpcId),
+		Region: elbClient.Options().Region,
+		Tags:   make(map[string]string),
+	}
+
+	if lb.State != nil {
+		loadBalancer.State = string(lb.State.Code)
+	}
+	if lb.CreatedTime != nil {
+		loadBalancer.CreatedTime = *lb.CreatedTime
+	}
+
+	// Get tags
+	tagsResp, err := elbClient.DescribeTags(ctx, &elb.DescribeTagsInput{
+		ResourceArns: []string{arn},
+	})
+	if err != nil {
+		Warnf("Unable to get tags for load balancer %s: %v", name, err)
+	} else {
+		for _, description := range tagsResp.TagDescriptions {
+			for _, tag := range description.Tags {
+				if tag.Key != nil && tag.Value != nil {
+					loadBalancer.Tags[*tag.Key] = *tag.Value
+				}
+			}
+		}
+	}
+
+	// Get attached target groups
+	var targetGroups []elbTypes.TargetGroup
+	var marker *string
+	for {
+		resp, err := elbClient.DescribeTargetGroups(ctx, &elb.DescribeTargetGroupsInput{
+			LoadBalancerArn: lb.LoadBalancerArn,
+			Marker:          marker,
+		})
+		if err != nil {
+			Warnf("Unable to get target groups for load balancer %s: %v", name, err)
+			break
+		}
+		targetGroups = append(targetGroups, resp.TargetGroups...)
+		if resp.NextMarker == nil {
+			break
+		}
+		marker = resp.NextMarker
+	}
+	loadBalancer.TargetGroupCount = len(targetGroups)
+
+	namespace, ok := elbNamespaces[loadBalancer.Type]
+	if !ok {
+		Warnf("Unsupported type %q for load balancer %s, skipping metrics", loadBalancer.Type, name)
+		return loadBalancer
+	}
+
+	lbDimension := types.Dimension{
+		Name:  aws.String("LoadBalancer"),
+		Value: aws.String(elbDimensionValue(arn)),
+	}
+
+	if loadBalancer.Type == string(elbTypes.LoadBalancerTypeEnumApplication) {
+		requests, err := getELBMetric(ctx, cwClient, namespace, "RequestCount", []types.Dimension{lbDimension}, types.StatisticSum, startTime, endTime)
+		if err != nil {
+			Warnf("Unable to get request count for load balancer %s: %v", name, err)
+		} else {
+			loadBalancer.RequestCount7d = requests
+			loadBalancer.Idle = requests == 0
+		}
+	} else {
+		flows, err := getELBMetric(ctx, cwClient, namespace, "ActiveFlowCount", []types.Dimension{lbDimension}, types.StatisticAverage, startTime, endTime)
+		if err != nil {
+			Warnf("Unable to get active flow count for load balancer %s: %v", name, err)
+		} else {
+			loadBalancer.ActiveFlowCountAvg = flows
+			loadBalancer.Idle = flows == 0
+		}
+	}
+
+	// Healthy hosts are reported per target group
+	for _, tg := range targetGroups {
+		tgDimension := types.Dimension{
+			Name:  aws.String("TargetGroup"),
+			Value: aws.String(elbDimensionValue(aws.ToString(tg.TargetGroupArn))),
+		}
+		healthy, err := getELBMetric(ctx, cwClient, namespace, "HealthyHostCount", []types.Dimension{tgDimension, lbDimension}, types.StatisticAverage, startTime, endTime)
+		if err != nil {
+			Warnf("Unable to get healthy host count for target group %s: %v", aws.ToString(tg.TargetGroupName), err)
+			continue
+		}
+		loadBalancer.HealthyHostCountAvg += healthy
+	}
+
+	return loadBalancer
+}
+
+// elbDimensionValue converts a load balancer or target group ARN to its CloudWatch dimension value:
+// ...:loadbalancer/app/my-alb/50dc6c495c0c9188 becomes app/my-alb/50dc6c495c0c9188 and
+// ...:targetgroup/my-targets/73e2d6bc24d8a067 becomes targetgroup/my-targets/73e2d6bc24d8a067
+func elbDimensionValue(arn string) string {
+	resource := arn[strings.LastIndex(arn, ":")+1:]
+	return strings.TrimPrefix(resource, "loadbalancer/")
+}
+
+// getELBMetric retrieves a CloudWatch metric for a load balancer over the window, returning
+// the total for Sum and the mean of the daily datapoints for other statistics
+func getELBMetric(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	namespace, metricName string,
+	dimensions []types.Dimension,
+	statistic types.Statistic,
+	startTime, endTime time.Time,
+) (float64, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(namespace),
+		MetricName: aws.String(metricName),
+		Dimensions: dimensions,
+		StartTime:  &startTime,
+		EndTime:    &endTime,
+		Period:     aws.Int32(86400), // 1 day granularity
+		Statistics: []types.Statistic{statistic},
+	}
+
+	resp, err := cwClient.GetMetricStatistics(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, dp := range resp.Datapoints {
+		if statistic == types.StatisticSum {
+			total += aws.ToFloat64(dp.Sum)
+		} else {
+			total += aws.ToFloat64(dp.Average)
+		}
+	}
+
+	if statistic != types.StatisticSum && len(resp.Datapoints) > 0 {
+		return total / float64(len(resp.Datapoints)), nil
+	}
+	return total, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 
 	// AWS SDK v2 modules for config and Bedrock runtime
@@ -23,7 +22,7 @@ type EmbeddingResult struct {
 // embedText calls Bedrock to get embeddings for the input text
 // It handles both V2 and legacy embedding schemas, and attempts to
 // extract the embedding vector from various possible response formats.
-func EmbedText(ctx context.Context, client *bedrockruntime.Client, modelID, text string) ([]float64, error) {
+func EmbedText(ctx context.Context, client BedrockInvoker, modelID, text string) ([]float64, error) {
 	var body []byte
 	var err error
 
@@ -57,7 +56,8 @@ func EmbedText(ctx context.Context, client *bedrockruntime.Client, modelID, text
 
 	// The SDK returns raw JSON bytes in resp.Body
 	data := resp.Body
-	log.Printf("Raw embedding response: %s", string(data))
+	Debugf("Embedding response: %d bytes", len(data))
+	DebugPayload("Raw embedding response", string(data))
 
 	// 1) Try the simple path: top-level "embeddings" field
 	var direct struct {
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEmbedText(t *testing.T) {
+	invokeErr := errors.New("ThrottlingException")
+
+	tests := []struct {
+		name        string
+		modelID     string
+		response    string
+		invokeErr   error
+		wantPayload string // a key the request body must have
+		want        []float64
+		wantErr     string
+	}{
+		{
+			name:        "titan v2 embeddings",
+			modelID:     "amazon.titan-embed-text-v2:0",
+			response:    `{"embedding":[0.1,0.2,0.3],"inputTextTokenCount":4}`,
+			wantPayload: "dimensions",
+			want:        []float64{0.1, 0.2, 0.3},
+		},
+		{
+			name:        "top-level embeddings",
+			modelID:     "amazon.titan-embed-text-v1",
+			response:    `{"embeddings":[1,2]}`,
+			wantPayload: "input",
+			want:        []float64{1, 2},
+		},
+		{
+			name:     "nested results",
+			modelID:  "amazon.titan-embed-text-v1",
+			response: `{"results":[{"embedding":[0.5]}]}`,
+			want:     []float64{0.5},
+		},
+		{
+			name:      "invoke error is returned",
+			modelID:   "amazon.titan-embed-text-v2:0",
+			invokeErr: invokeErr,
+			wantErr:   "embed invoke error",
+		},
+		{
+			name:     "response without embeddings",
+			modelID:  "amazon.titan-embed-text-v2:0",
+			response: `{"message":"nothing here"}`,
+			wantErr:  "no embeddings found",
+		},
+		{
+			name:     "response that is not JSON",
+			modelID:  "amazon.titan-embed-text-v2:0",
+			response: `<html>`,
+			wantErr:  "failed to parse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]any
+			bedrock := &fakeBedrock{invoke: func(modelID string, body []byte) ([]byte, error) {
+				if modelID != tt.modelID {
+					return nil, fmt.Errorf("invoked %s, want %s", modelID, tt.modelID)
+				}
+				if err := json.Unmarshal(body, &payload); err != nil {
+					return nil, err
+				}
+				if tt.invokeErr != nil {
+					return nil, tt.invokeErr
+				}
+				return []byte(tt.response), nil
+			}}
+
+			got, err := EmbedText(context.Background(), bedrock, tt.modelID, "m5.large in eu-west-1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EmbedText() error = %v, want %q", err, tt.wantErr)
+				}
+				if tt.invokeErr != nil && !errors.Is(err, tt.invokeErr) {
+					t.Errorf("EmbedText() error = %v, want it to wrap %v", err, tt.invokeErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EmbedText() error = %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("EmbedText() = %v, want %v", got, tt.want)
+			}
+			if _, ok := payload[tt.wantPayload]; tt.wantPayload != "" && !ok {
+				t.Errorf("request body %v has no %q", payload, tt.wantPayload)
+			}
+		})
+	}
+}
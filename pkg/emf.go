@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Units of EMF metrics, as CloudWatch names them
+const (
+	EMFUnitMilliseconds = "Milliseconds"
+	EMFUnitCount        = "Count"
+)
+
+// EMFMetric is one value of an EMF record
+type EMFMetric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+// EMFLogger writes CloudWatch Embedded Metric Format records, one JSON document per line.
+// In Lambda, lines written to stdout reach CloudWatch Logs, which extracts the metrics
+// without any PutMetricData calls.
+type EMFLogger struct {
+	w         io.Writer
+	namespace string
+	mu        sync.Mutex
+}
+
+// NewEMFLogger creates a logger writing records for a metric namespace to w
+func NewEMFLogger(w io.Writer, namespace string) *EMFLogger {
+	return &EMFLogger{w: w, namespace: namespace}
+}
+
+// emfMetricDirective declares the metrics of a record and how they are dimensioned
+type emfMetricDirective struct {
+	Namespace  string                `json:"Namespace"`
+	Dimensions [][]string            `json:"Dimensions"`
+	Metrics    []emfMetricDefinition `json:"Metrics"`
+}
+
+type emfMetricDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64                `json:"Timestamp"`
+	CloudWatchMetrics []emfMetricDirective `json:"CloudWatchMetrics"`
+}
+
+// Emit writes one record holding every metric, dimensioned by all of the given dimensions
+func (l *EMFLogger) Emit(dimensions map[string]string, metrics ...EMFMetric) error {
+	line, err := l.record(time.Now(), dimensions, metrics)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write EMF record: %w", err)
+	}
+	return nil
+}
+
+// record builds the JSON of one EMF record. Dimension and metric values sit at the top level
+// next to the _aws metadata that describes them.
+func (l *EMFLogger) record(ts time.Time, dimensions map[string]string, metrics []EMFMetric) ([]byte, error) {
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	directive := emfMetricDirective{
+		Namespace:  l.namespace,
+		Dimensions: [][]string{keys},
+		Metrics:    make([]emfMetricDefinition, 0, len(metrics)),
+	}
+	doc := make(map[string]interface{}, len(dimensions)+len(metrics)+1)
+	for _, k := range keys {
+		doc[k] = dimensions[k]
+	}
+	for _, m := range metrics {
+		if _, taken := doc[m.Name]; taken {
+			return nil, fmt.Errorf("EMF metric %q clashes with another field", m.Name)
+		}
+		directive.Metrics = append(directive.Metrics, emfMetricDefinition{Name: m.Name, Unit: m.Unit})
+		doc[m.Name] = m.Value
+	}
+	doc["_aws"] = emfMetadata{
+		Timestamp:         ts.UnixMilli(),
+		CloudWatchMetrics: []emfMetricDirective{directive},
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode EMF record: %w", err)
+	}
+	return line, nil
+}
+
+// DurationMillis converts a duration to the float milliseconds EMF expects
+func DurationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEMFLoggerRecord(t *testing.T) {
+	logger := NewEMFLogger(nil, "GreenOps/Worker")
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	line, err := logger.record(ts, map[string]string{"model": "claude", "item_type": "ec2"},
+		[]EMFMetric{
+			{Name: "ItemProcessingDuration", Unit: EMFUnitMilliseconds, Value: 1250.5},
+			{Name: "ItemsSucceeded", Unit: EMFUnitCount, Value: 1},
+		})
+	if err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+
+	want := `{"ItemProcessingDuration":1250.5,"ItemsSucceeded":1,` +
+		`"_aws":{"Timestamp":1714564800000,"CloudWatchMetrics":[{"Namespace":"GreenOps/Worker",` +
+		`"Dimensions":[["item_type","model"]],` +
+		`"Metrics":[{"Name":"ItemProcessingDuration","Unit":"Milliseconds"},{"Name":"ItemsSucceeded","Unit":"Count"}]}]},` +
+		`"item_type":"ec2","model":"claude"}`
+	if string(line) != want {
+		t.Errorf("record() =\n%s\nwant\n%s", line, want)
+	}
+}
+
+// Every declared metric and dimension must be a top-level field, or CloudWatch drops the record
+func TestEMFLoggerRecordIsValidEMF(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions map[string]string
+		metrics    []EMFMetric
+	}{
+		{name: "no dimensions", metrics: []EMFMetric{{Name: "ItemsFailed", Unit: EMFUnitCount, Value: 1}}},
+		{name: "no unit", dimensions: map[string]string{"item_type": "s3"}, metrics: []EMFMetric{{Name: "ThrottleRetries", Value: 3}}},
+		{
+			name:       "all worker metrics",
+			dimensions: map[string]string{"item_type": "rds", "model": "titan"},
+			metrics: []EMFMetric{
+				{Name: "ItemProcessingDuration", Unit: EMFUnitMilliseconds, Value: 10},
+				{Name: "BedrockInvokeDuration", Unit: EMFUnitMilliseconds, Value: 8},
+				{Name: "EmbedDuration", Unit: EMFUnitMilliseconds, Value: 2},
+				{Name: "ItemsFailed", Unit: EMFUnitCount, Value: 0},
+				{Name: "ItemsSucceeded", Unit: EMFUnitCount, Value: 1},
+				{Name: "ThrottleRetries", Unit: EMFUnitCount, Value: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := NewEMFLogger(nil, "GreenOps/Worker").record(time.Now(), tt.dimensions, tt.metrics)
+			if err != nil {
+				t.Fatalf("record() error = %v", err)
+			}
+
+			var doc map[string]json.RawMessage
+			if err := json.Unmarshal(line, &doc); err != nil {
+				t.Fatalf("record is not JSON: %v", err)
+			}
+			var meta emfMetadata
+			if err := json.Unmarshal(doc["_aws"], &meta); err != nil {
+				t.Fatalf("_aws metadata: %v", err)
+			}
+			if meta.Timestamp <= 0 || len(meta.CloudWatchMetrics) != 1 {
+				t.Fatalf("_aws = %s", doc["_aws"])
+			}
+			directive := meta.CloudWatchMetrics[0]
+			if directive.Namespace != "GreenOps/Worker" || len(directive.Dimensions) != 1 {
+				t.Errorf("directive = %+v", directive)
+			}
+			for _, key := range directive.Dimensions[0] {
+				var value string
+				if err := json.Unmarshal(doc[key], &value); err != nil || value != tt.dimensions[key] {
+					t.Errorf("dimension %s = %s, want %q", key, doc[key], tt.dimensions[key])
+				}
+			}
+			if len(directive.Metrics) != len(tt.metrics) {
+				t.Fatalf("declared %d metrics, want %d", len(directive.Metrics), len(tt.metrics))
+			}
+			for i, m := range tt.metrics {
+				var value float64
+				if err := json.Unmarshal(doc[m.Name], &value); err != nil || value != m.Value {
+					t.Errorf("metric %s = %s, want %v", m.Name, doc[m.Name], m.Value)
+				}
+				if directive.Metrics[i] != (emfMetricDefinition{Name: m.Name, Unit: m.Unit}) {
+					t.Errorf("metric definition %d = %+v", i, directive.Metrics[i])
+				}
+			}
+			if len(doc) != len(tt.dimensions)+len(tt.metrics)+1 {
+				t.Errorf("record has %d fields, want only dimensions, metrics and _aws", len(doc))
+			}
+		})
+	}
+}
+
+func TestEMFLoggerRejectsClashingNames(t *testing.T) {
+	_, err := NewEMFLogger(nil, "GreenOps/Worker").record(time.Now(), map[string]string{"model": "claude"},
+		[]EMFMetric{{Name: "model", Value: 1}})
+	if err == nil {
+		t.Error("record() error = nil for a metric named like a dimension")
+	}
+}
+
+// Concurrent workers share one logger, and each record must stay on its own line
+func TestEMFLoggerEmitWritesLines(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewEMFLogger(&buf, "GreenOps/Worker")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := logger.Emit(map[string]string{"item_type": "ec2"}, EMFMetric{Name: "ItemsSucceeded", Unit: EMFUnitCount, Value: 1}); err != nil {
+				t.Errorf("Emit() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 20 {
+		t.Fatalf("got %d lines, want 20", len(lines))
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("line is not JSON: %s", line)
+		}
+	}
+}
+
+func TestDurationMillis(t *testing.T) {
+	if got := DurationMillis(1500 * time.Microsecond); got != 1.5 {
+		t.Errorf("DurationMillis(1.5ms) = %v, want 1.5", got)
+	}
+}
@@ -0,0 +1,217 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alexalbu001/greenops/pkg/prompts"
+)
+
+// DefaultEstimateItemSeconds is the analysis time per item an estimate assumes until runs
+// recorded in the history say otherwise
+const DefaultEstimateItemSeconds = 6.0
+
+// Rules of the analysis estimate. The prompts of the EBS, Lambda, load balancer, network,
+// DynamoDB, ElastiCache and snapshot analyses are written in code rather than from a template;
+// their instructions count as inlinePromptTokens. The account-level recommendations add one
+// call whose AccountSummary is about accountSummaryTokens.
+const (
+	inlinePromptTokens   = 400
+	accountSummaryTokens = 150
+	estimateHistoryRuns  = 10 // recorded runs the observed time per item is averaged over
+)
+
+// promptTemplates names the template of the item types whose prompt is rendered from one
+var promptTemplates = map[string]string{
+	"ec2":     prompts.EC2,
+	"s3":      prompts.S3,
+	"rds":     prompts.RDS,
+	"account": prompts.Account,
+}
+
+// TypeEstimate is the estimated Bedrock usage of analyzing the items of one type
+type TypeEstimate struct {
+	ItemType         string
+	Items            int
+	PromptTokens     int // per item, on average
+	CompletionTokens int // per item, at the model's completion limit
+	CostUSD          float64
+}
+
+// AnalysisEstimate is what analyzing a payload should roughly cost and take, before it is
+// sent. Token counts are approximations at four characters per token, and completions are
+// counted at the model's limit, so the cost is an upper bound for the first attempt of each
+// item; retries of incomplete analyses come on top. Every item is priced with Model, although
+// the API may route resource types to other models with GEN_MODEL_<TYPE>.
+// - Priced: whether bedrockModelPrices has a price for Model; CostUSD is zero otherwise
+// - ItemSeconds: the time per item the ETA is based on, averaged over ObservedRuns recorded runs or the configured default when there are none
+type AnalysisEstimate struct {
+	Model            string
+	Types            []TypeEstimate
+	Items            int
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+	Priced           bool
+	ItemSeconds      float64
+	ObservedRuns     int
+	ETA              time.Duration
+}
+
+// workItemResource returns the resource a work item carries, as it is serialized into its prompt
+func workItemResource(item WorkItem) interface{} {
+	switch item.ItemType {
+	case "ec2":
+		return item.Instance
+	case "s3":
+		return item.S3Bucket
+	case "rds":
+		return item.RDSInstance
+	case "ebs":
+		return item.EBSVolume
+	case "lambda":
+		return item.LambdaFunction
+	case "elb":
+		return item.LoadBalancer
+	case "network":
+		return item.NetworkResource
+	case "dynamodb":
+		return item.DynamoTable
+	case "elasticache":
+		return item.ElastiCache
+	}
+	return nil
+}
+
+// itemPromptTokens estimates the prompt tokens of a work item: its serialized resource and
+// the instructions of its prompt
+func itemPromptTokens(item WorkItem) int {
+	resource := ""
+	if item.ItemType == "snapshots" {
+		// Only a summary and the largest snapshots go into the prompt
+		resource = formatSnapshotsForPrompt(item.Snapshots, SummarizeSnapshots(item.Snapshots))
+	} else if data, err := json.Marshal(workItemResource(item)); err == nil {
+		resource = string(data)
+	}
+	return estimateTokens(resource) + instructionTokens(item.ItemType)
+}
+
+// instructionTokens estimates the tokens a prompt adds around the resource: its template
+// rendered without data, or inlinePromptTokens, plus the JSON instructions when analyses are
+// asked for as JSON
+func instructionTokens(itemType string) int {
+	tokens := inlinePromptTokens
+	if name, ok := promptTemplates[itemType]; ok {
+		if text, err := prompts.Render(name, prompts.Data{}); err == nil {
+			tokens = estimateTokens(text)
+		}
+	}
+	if AnalysisFormat() == AnalysisFormatJSON {
+		tokens += estimateTokens(jsonAnalysisInstructions)
+	}
+	return tokens
+}
+
+// EstimateAnalysis estimates the Bedrock usage, cost and duration of analyzing a payload with
+// modelID: one call per work item plus the account-level recommendations. It assumes modelID
+// analyzes every item, since the CLI cannot see how the API routes resource types. The ETA is
+// the number of items at itemSeconds each, the wall-clock time per item of earlier runs.
+func EstimateAnalysis(payload ScanPayload, modelID string, itemSeconds float64, observedRuns int) AnalysisEstimate {
+	estimate := AnalysisEstimate{
+		Model:        modelID,
+		Priced:       BedrockCostUSD(modelID, 1000, 1000) > 0,
+		ItemSeconds:  itemSeconds,
+		ObservedRuns: observedRuns,
+	}
+	completion := maxTokensFor(modelID)
+
+	byType := make(map[string]*TypeEstimate)
+	var order []string
+	add := func(itemType string, promptTokens int) {
+		typeEstimate, ok := byType[itemType]
+		if !ok {
+			typeEstimate = &TypeEstimate{ItemType: itemType, CompletionTokens: completion}
+			byType[itemType] = typeEstimate
+			order = append(order, itemType)
+		}
+		typeEstimate.Items++
+		typeEstimate.PromptTokens += promptTokens
+		typeEstimate.CostUSD += BedrockCostUSD(modelID, promptTokens, completion)
+		estimate.PromptTokens += promptTokens
+		estimate.CompletionTokens += completion
+		estimate.CostUSD += BedrockCostUSD(modelID, promptTokens, completion)
+	}
+
+	workItems := payload.WorkItems("", 0)
+	for _, item := range workItems {
+		add(item.ItemType, itemPromptTokens(item))
+	}
+	if len(workItems) > 0 {
+		add("account", accountSummaryTokens+instructionTokens("account"))
+	}
+
+	for _, itemType := range order {
+		typeEstimate := byType[itemType]
+		typeEstimate.PromptTokens /= typeEstimate.Items
+		estimate.Types = append(estimate.Types, *typeEstimate)
+	}
+	estimate.Items = len(workItems)
+	estimate.ETA = time.Duration(float64(estimate.Items) * itemSeconds * float64(time.Second))
+	return estimate
+}
+
+// AverageItemSeconds averages the analysis time per item of the last estimateHistoryRuns
+// records that timed it, and says how many did. ok is false when none did.
+func AverageItemSeconds(records []HistoryRecord) (seconds float64, runs int, ok bool) {
+	var total float64
+	for i := len(records) - 1; i >= 0 && runs < estimateHistoryRuns; i-- {
+		if records[i].ItemSeconds > 0 {
+			total += records[i].ItemSeconds
+			runs++
+		}
+	}
+	if runs == 0 {
+		return 0, 0, false
+	}
+	return total / float64(runs), runs, true
+}
+
+// FormatAnalysisEstimate prints the estimate as a table of the items of each type, followed
+// by the totals, the cost and the ETA
+func FormatAnalysisEstimate(w io.Writer, estimate AnalysisEstimate) {
+	fmt.Fprintf(w, "\nANALYSIS ESTIMATE\n")
+	fmt.Fprintf(w, "─────────────────\n")
+	fmt.Fprintf(w, "Model: %s (assumed for every item; models routed per resource type are not priced)\n", estimate.Model)
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tITEMS\tPROMPT TOKENS/ITEM\tCOST ($)")
+	for _, typeEstimate := range estimate.Types {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", typeEstimate.ItemType, typeEstimate.Items, typeEstimate.PromptTokens,
+			formatEstimateCost(typeEstimate.CostUSD, estimate.Priced))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t\t%s\n", estimate.Items, formatEstimateCost(estimate.CostUSD, estimate.Priced))
+	tw.Flush()
+
+	fmt.Fprintf(w, "• About %d prompt tokens and at most %d completion tokens\n", estimate.PromptTokens, estimate.CompletionTokens)
+	if estimate.Priced {
+		fmt.Fprintf(w, "• Estimated Bedrock cost: up to $%.2f\n", estimate.CostUSD)
+	} else {
+		fmt.Fprintf(w, "• Estimated Bedrock cost: unknown, no price is known for %s\n", estimate.Model)
+	}
+	basis := "the configured default"
+	if estimate.ObservedRuns > 0 {
+		basis = fmt.Sprintf("observed over the last %d runs", estimate.ObservedRuns)
+	}
+	fmt.Fprintf(w, "• Estimated time: about %s at %.1fs per item (%s)\n", estimate.ETA.Round(time.Second), estimate.ItemSeconds, basis)
+}
+
+// formatEstimateCost formats a cost of the estimate, "-" when the model has no price
+func formatEstimateCost(cost float64, priced bool) string {
+	if !priced {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f", cost)
+}
@@ -0,0 +1,217 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexalbu001/greenops/pkg/prompts"
+)
+
+const (
+	estimateHaiku   = "anthropic.claude-3-haiku-20240307-v1:0"
+	estimateProfile = "arn:aws:bedrock:eu-west-1:123456789012:inference-profile/eu.anthropic.claude-3-7-sonnet-20250219-v1:0"
+)
+
+// estimatePayload has two EC2 instances, a bucket and an EBS volume, whose prompt is written
+// in code rather than from a template
+func estimatePayload() ScanPayload {
+	return ScanPayload{
+		Instances: []Instance{
+			{InstanceID: "i-1", InstanceType: "m5.large", Region: "eu-west-1", CPUAvg7d: 12},
+			{InstanceID: "i-2", InstanceType: "m5.4xlarge", Region: "eu-west-1", CPUAvg7d: 3, Tags: map[string]string{"team": "payments", "env": "production"}},
+		},
+		S3Buckets:  []S3Bucket{{BucketName: "logs", Region: "eu-west-1", SizeBytes: 1 << 30, ObjectCount: 1200}},
+		EBSVolumes: []EBSVolume{{VolumeID: "vol-1", Region: "eu-west-1", SizeGiB: 500}},
+	}
+}
+
+// templateTokens estimates the tokens of a prompt template rendered without data
+func templateTokens(t *testing.T, name string) int {
+	t.Helper()
+	text, err := prompts.Render(name, prompts.Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return estimateTokens(text)
+}
+
+// resourceTokens estimates the tokens of a resource serialized into a prompt
+func resourceTokens(t *testing.T, resource interface{}) int {
+	t.Helper()
+	data, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return estimateTokens(string(data))
+}
+
+func TestEstimateAnalysis(t *testing.T) {
+	payload := estimatePayload()
+	ec2 := templateTokens(t, prompts.EC2)
+	wantPrompts := map[string]int{
+		"ec2":     (2*ec2 + resourceTokens(t, payload.Instances[0]) + resourceTokens(t, payload.Instances[1])) / 2,
+		"s3":      templateTokens(t, prompts.S3) + resourceTokens(t, payload.S3Buckets[0]),
+		"ebs":     inlinePromptTokens + resourceTokens(t, payload.EBSVolumes[0]),
+		"account": templateTokens(t, prompts.Account) + accountSummaryTokens,
+	}
+
+	tests := []struct {
+		model          string
+		wantCompletion int
+		wantPriced     bool
+		price          [2]float64 // per 1,000 input and output tokens
+	}{
+		{model: estimateHaiku, wantCompletion: modelMaxTokens, wantPriced: true, price: [2]float64{0.00025, 0.00125}},
+		{model: estimateProfile, wantCompletion: profileMaxTokens, wantPriced: true, price: [2]float64{0.003, 0.015}},
+		{model: "meta.llama3-70b-instruct-v1:0", wantCompletion: modelMaxTokens},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			estimate := EstimateAnalysis(payload, tt.model, 6, 0)
+
+			var types []string
+			for _, typeEstimate := range estimate.Types {
+				types = append(types, fmt.Sprintf("%s:%d", typeEstimate.ItemType, typeEstimate.Items))
+				if typeEstimate.PromptTokens != wantPrompts[typeEstimate.ItemType] {
+					t.Errorf("%s prompt tokens per item = %d, want %d", typeEstimate.ItemType, typeEstimate.PromptTokens, wantPrompts[typeEstimate.ItemType])
+				}
+				if typeEstimate.CompletionTokens != tt.wantCompletion {
+					t.Errorf("%s completion tokens = %d, want %d", typeEstimate.ItemType, typeEstimate.CompletionTokens, tt.wantCompletion)
+				}
+			}
+			if want := "[ec2:2 s3:1 ebs:1 account:1]"; fmt.Sprint(types) != want {
+				t.Errorf("types = %v, want %v", types, want)
+			}
+
+			// The account-level recommendations are a call, but not an item
+			calls := 5
+			if estimate.Items != 4 {
+				t.Errorf("Items = %d, want 4", estimate.Items)
+			}
+			if estimate.CompletionTokens != calls*tt.wantCompletion {
+				t.Errorf("CompletionTokens = %d, want %d", estimate.CompletionTokens, calls*tt.wantCompletion)
+			}
+			promptTokens := 0
+			for _, typeEstimate := range estimate.Types {
+				promptTokens += typeEstimate.PromptTokens * typeEstimate.Items
+			}
+			// Per-type averages round down, by less than a token per item
+			if diff := estimate.PromptTokens - promptTokens; diff < 0 || diff >= calls {
+				t.Errorf("PromptTokens = %d, want the %d of the types", estimate.PromptTokens, promptTokens)
+			}
+
+			wantCost := float64(estimate.PromptTokens)/1000*tt.price[0] + float64(estimate.CompletionTokens)/1000*tt.price[1]
+			if estimate.Priced != tt.wantPriced || math.Abs(estimate.CostUSD-wantCost) > 1e-9 {
+				t.Errorf("CostUSD = %v (priced %v), want %v (priced %v)", estimate.CostUSD, estimate.Priced, wantCost, tt.wantPriced)
+			}
+		})
+	}
+}
+
+func TestEstimateAnalysisJSONFormat(t *testing.T) {
+	markdown := EstimateAnalysis(estimatePayload(), estimateHaiku, 6, 0)
+	SetAnalysisFormat(AnalysisFormatJSON)
+	t.Cleanup(func() { SetAnalysisFormat("") })
+	structured := EstimateAnalysis(estimatePayload(), estimateHaiku, 6, 0)
+
+	if got, want := structured.PromptTokens-markdown.PromptTokens, 5*estimateTokens(jsonAnalysisInstructions); got != want {
+		t.Errorf("JSON instructions add %d prompt tokens, want %d for 5 calls", got, want)
+	}
+}
+
+func TestEstimateAnalysisETA(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     ScanPayload
+		itemSeconds float64
+		want        time.Duration
+	}{
+		{name: "default rate", payload: estimatePayload(), itemSeconds: DefaultEstimateItemSeconds, want: 24 * time.Second},
+		{name: "observed rate", payload: estimatePayload(), itemSeconds: 2.5, want: 10 * time.Second},
+		{name: "empty payload", payload: ScanPayload{}, itemSeconds: DefaultEstimateItemSeconds},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimateAnalysis(tt.payload, estimateHaiku, tt.itemSeconds, 0)
+			if estimate.ETA != tt.want {
+				t.Errorf("ETA = %s, want %s", estimate.ETA, tt.want)
+			}
+		})
+	}
+
+	// Nothing to analyze asks for no account-level recommendations either
+	empty := EstimateAnalysis(ScanPayload{}, estimateHaiku, 6, 0)
+	if len(empty.Types) != 0 || empty.PromptTokens != 0 || empty.CostUSD != 0 {
+		t.Errorf("EstimateAnalysis() of an empty payload = %+v, want nothing", empty)
+	}
+}
+
+func TestAverageItemSeconds(t *testing.T) {
+	records := func(seconds ...float64) []HistoryRecord {
+		var history []HistoryRecord
+		for _, s := range seconds {
+			history = append(history, HistoryRecord{ItemSeconds: s})
+		}
+		return history
+	}
+
+	tests := []struct {
+		name        string
+		records     []HistoryRecord
+		wantSeconds float64
+		wantRuns    int
+	}{
+		{name: "no history"},
+		{name: "no timed runs", records: records(0, 0)},
+		{name: "untimed runs skipped", records: records(4, 0, 8), wantSeconds: 6, wantRuns: 2},
+		// Only the last 10 timed runs count, so the 100 at the start is left out
+		{name: "last runs only", records: records(100, 0, 2, 2, 2, 2, 2, 4, 4, 4, 4, 4), wantSeconds: 3, wantRuns: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seconds, runs, ok := AverageItemSeconds(tt.records)
+			if seconds != tt.wantSeconds || runs != tt.wantRuns || ok != (tt.wantRuns > 0) {
+				t.Errorf("AverageItemSeconds() = %v, %d, %v, want %v, %d", seconds, runs, ok, tt.wantSeconds, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestFormatAnalysisEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		estimate AnalysisEstimate
+		want     []string
+	}{
+		{
+			name:     "priced and observed",
+			estimate: AnalysisEstimate{Model: estimateHaiku, Items: 40, CostUSD: 0.1234, Priced: true, ItemSeconds: 3.2, ObservedRuns: 4, ETA: 128 * time.Second},
+			want:     []string{"Model: " + estimateHaiku + " (assumed for every item", "• Estimated Bedrock cost: up to $0.12", "• Estimated time: about 2m8s at 3.2s per item (observed over the last 4 runs)"},
+		},
+		{
+			name:     "unpriced with the default rate",
+			estimate: AnalysisEstimate{Model: "meta.llama3-70b-instruct-v1:0", Items: 1, ItemSeconds: DefaultEstimateItemSeconds, ETA: 6 * time.Second},
+			want:     []string{"TOTAL  1", "-", "• Estimated Bedrock cost: unknown, no price is known for meta.llama3-70b-instruct-v1:0", "at 6.0s per item (the configured default)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			FormatAnalysisEstimate(&buf, tt.estimate)
+			for _, text := range tt.want {
+				if !strings.Contains(buf.String(), text) {
+					t.Errorf("output is missing %q:\n%s", text, buf.String())
+				}
+			}
+		})
+	}
+}
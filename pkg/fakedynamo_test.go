@@ -0,0 +1,887 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Table names the tests point the job store environment variables at
+const (
+	testJobsTable    = "jobs"
+	testResultsTable = "results"
+	testItemsTable   = "items"
+	testCacheTable   = "cache"
+)
+
+// fakeTableKeys is the key schema of each fake table: partition key, then sort key if any
+var fakeTableKeys = map[string][]string{
+	testJobsTable:    {"job_id"},
+	testResultsTable: {"job_id", "item_index"},
+	testItemsTable:   {"job_id", "item_index"},
+	testCacheTable:   {"fingerprint"},
+}
+
+// useJobTables points JOBS_TABLE at the fake jobs table and clears the optional tables, which
+// tests opt into with t.Setenv
+func useJobTables(t *testing.T) {
+	t.Helper()
+	t.Setenv("JOBS_TABLE", testJobsTable)
+	t.Setenv("RESULTS_TABLE", "")
+	t.Setenv("ITEMS_TABLE", "")
+	t.Setenv("CACHE_TABLE", "")
+}
+
+// fakeDynamo is an in-memory DynamoJobStore. It evaluates the update, condition, key
+// condition, filter and projection expressions pkg writes, which is a small subset of
+// DynamoDB's grammar, and records how often each operation was called. failures makes an
+// operation on a table fail: the key is "Operation" or "Operation table".
+type fakeDynamo struct {
+	mu       sync.Mutex
+	tables   map[string]map[string]map[string]types.AttributeValue
+	calls    map[string]int
+	failures map[string]error
+	// failGet makes GetItem fail for the keys it returns true for
+	failGet func(table string, key map[string]types.AttributeValue) error
+	// unprocessed is how many calls of BatchWriteItem leave their last request unprocessed
+	unprocessed int
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{
+		tables:   make(map[string]map[string]map[string]types.AttributeValue),
+		calls:    make(map[string]int),
+		failures: make(map[string]error),
+	}
+}
+
+var _ DynamoJobStore = (*fakeDynamo)(nil)
+
+// count records a call and returns the error injected for it, if any
+func (f *fakeDynamo) count(op, table string) error {
+	f.calls[op]++
+	if err := f.failures[op+" "+table]; err != nil {
+		return err
+	}
+	return f.failures[op]
+}
+
+// callCount returns how often an operation was called
+func (f *fakeDynamo) callCount(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// fail makes every later call of an operation fail with err; op may name a table
+func (f *fakeDynamo) fail(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[op] = err
+}
+
+// keyOf returns the storage key of an item or key map in table
+func keyOf(table string, item map[string]types.AttributeValue) (string, error) {
+	names, ok := fakeTableKeys[table]
+	if !ok {
+		return "", fmt.Errorf("fake dynamo: unknown table %q", table)
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		av, ok := item[name]
+		if !ok {
+			return "", fmt.Errorf("fake dynamo: missing key attribute %s in table %s", name, table)
+		}
+		parts[i] = avString(av)
+	}
+	return strings.Join(parts, "|"), nil
+}
+
+// put stores a copy of item, as PutItem does
+func (f *fakeDynamo) put(table string, item map[string]types.AttributeValue) error {
+	key, err := keyOf(table, item)
+	if err != nil {
+		return err
+	}
+	if f.tables[table] == nil {
+		f.tables[table] = make(map[string]map[string]types.AttributeValue)
+	}
+	f.tables[table][key] = cloneItem(item)
+	return nil
+}
+
+// item returns a copy of the stored item with the given key, or nil
+func (f *fakeDynamo) item(table string, key map[string]types.AttributeValue) map[string]types.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	k, err := keyOf(table, key)
+	if err != nil {
+		return nil
+	}
+	return cloneItem(f.tables[table][k])
+}
+
+// items returns copies of every item of a table, in key order
+func (f *fakeDynamo) items(table string) []map[string]types.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sortedItems(table)
+}
+
+func (f *fakeDynamo) sortedItems(table string) []map[string]types.AttributeValue {
+	keys := make([]string, 0, len(f.tables[table]))
+	for key := range f.tables[table] {
+		keys = append(keys, key)
+	}
+	names := fakeTableKeys[table]
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := f.tables[table][keys[i]], f.tables[table][keys[j]]
+		for _, name := range names {
+			if c := compareAV(a[name], b[name]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	items := make([]map[string]types.AttributeValue, len(keys))
+	for i, key := range keys {
+		items[i] = cloneItem(f.tables[table][key])
+	}
+	return items
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := aws.ToString(params.TableName)
+	if err := f.count("PutItem", table); err != nil {
+		return nil, err
+	}
+	if params.ConditionExpression != nil {
+		key, err := keyOf(table, params.Item)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := evalCondition(aws.ToString(params.ConditionExpression), f.tables[table][key], params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+	}
+	return &dynamodb.PutItemOutput{}, f.put(table, params.Item)
+}
+
+func (f *fakeDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := aws.ToString(params.TableName)
+	if err := f.count("GetItem", table); err != nil {
+		return nil, err
+	}
+	if f.failGet != nil {
+		if err := f.failGet(table, params.Key); err != nil {
+			return nil, err
+		}
+	}
+	key, err := keyOf(table, params.Key)
+	if err != nil {
+		return nil, err
+	}
+	item := f.tables[table][key]
+	if item == nil {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: project(cloneItem(item), params.ProjectionExpression, params.ExpressionAttributeNames)}, nil
+}
+
+func (f *fakeDynamo) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := aws.ToString(params.TableName)
+	if err := f.count("UpdateItem", table); err != nil {
+		return nil, err
+	}
+	key, err := keyOf(table, params.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := f.tables[table][key]
+
+	if params.ConditionExpression != nil {
+		ok, err := evalCondition(aws.ToString(params.ConditionExpression), old, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			conditionErr := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+			if params.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld {
+				conditionErr.Item = cloneItem(old)
+			}
+			return nil, conditionErr
+		}
+	}
+
+	updated := cloneItem(old)
+	if updated == nil {
+		updated = cloneItem(params.Key)
+	}
+	if err := applyUpdate(aws.ToString(params.UpdateExpression), old, updated, params.ExpressionAttributeNames, params.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	return &dynamodb.UpdateItemOutput{}, f.put(table, updated)
+}
+
+func (f *fakeDynamo) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := aws.ToString(params.TableName)
+	if err := f.count("Query", table); err != nil {
+		return nil, err
+	}
+	var items []map[string]types.AttributeValue
+	for _, item := range f.sortedItems(table) {
+		ok, err := evalCondition(aws.ToString(params.KeyConditionExpression), item, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, project(item, params.ProjectionExpression, params.ExpressionAttributeNames))
+		}
+	}
+	return &dynamodb.QueryOutput{Items: items, Count: int32(len(items))}, nil
+}
+
+func (f *fakeDynamo) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := aws.ToString(params.TableName)
+	if err := f.count("Scan", table); err != nil {
+		return nil, err
+	}
+	all := f.sortedItems(table)
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		startKey, err := keyOf(table, params.ExclusiveStartKey)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range all {
+			if key, _ := keyOf(table, item); key == startKey {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := len(all)
+	if params.Limit != nil && start+int(*params.Limit) < end {
+		end = start + int(*params.Limit)
+	}
+
+	output := &dynamodb.ScanOutput{}
+	for _, item := range all[start:end] {
+		if params.FilterExpression != nil {
+			ok, err := evalCondition(aws.ToString(params.FilterExpression), item, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		output.Items = append(output.Items, project(item, params.ProjectionExpression, params.ExpressionAttributeNames))
+	}
+	if end < len(all) {
+		last := all[end-1]
+		output.LastEvaluatedKey = make(map[string]types.AttributeValue)
+		for _, name := range fakeTableKeys[table] {
+			output.LastEvaluatedKey[name] = last[name]
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeDynamo) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	output := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}
+	for table, requests := range params.RequestItems {
+		if err := f.count("BatchWriteItem", table); err != nil {
+			return nil, err
+		}
+		if f.unprocessed > 0 && len(requests) > 0 {
+			f.unprocessed--
+			output.UnprocessedItems[table] = requests[len(requests)-1:]
+			requests = requests[:len(requests)-1]
+		}
+		for _, request := range requests {
+			if request.PutRequest == nil {
+				return nil, errors.New("fake dynamo: only put requests are supported")
+			}
+			if err := f.put(table, request.PutRequest.Item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return output, nil
+}
+
+// cloneItem deep-copies an item so stored items never share values with callers
+func cloneItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	clone := make(map[string]types.AttributeValue, len(item))
+	for name, av := range item {
+		clone[name] = cloneAV(av)
+	}
+	return clone
+}
+
+func cloneAV(av types.AttributeValue) types.AttributeValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberL:
+		list := make([]types.AttributeValue, len(v.Value))
+		for i, element := range v.Value {
+			list[i] = cloneAV(element)
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(v.Value)}
+	}
+	return av
+}
+
+// avString renders a scalar attribute value for keys and messages
+func avString(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return fmt.Sprintf("%v", av)
+}
+
+// compareAV orders two attribute values of the same scalar type; missing values sort first
+func compareAV(a, b types.AttributeValue) int {
+	an, aIsN := a.(*types.AttributeValueMemberN)
+	bn, bIsN := b.(*types.AttributeValueMemberN)
+	if aIsN && bIsN {
+		x, _ := strconv.ParseFloat(an.Value, 64)
+		y, _ := strconv.ParseFloat(bn.Value, 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(avString(a), avString(b))
+}
+
+// equalAV reports whether two attribute values are equal, comparing numbers by value
+func equalAV(a, b types.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	an, aIsN := a.(*types.AttributeValueMemberN)
+	bn, bIsN := b.(*types.AttributeValueMemberN)
+	if aIsN && bIsN {
+		return compareAV(an, bn) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// project keeps the top-level attributes a projection expression names
+func project(item map[string]types.AttributeValue, projection *string, names map[string]string) map[string]types.AttributeValue {
+	if projection == nil || item == nil {
+		return item
+	}
+	projected := make(map[string]types.AttributeValue)
+	for _, name := range strings.Split(*projection, ",") {
+		name = resolveName(strings.TrimSpace(name), names)
+		if av, ok := item[name]; ok {
+			projected[name] = av
+		}
+	}
+	return projected
+}
+
+func resolveName(name string, names map[string]string) string {
+	if strings.HasPrefix(name, "#") {
+		if resolved, ok := names[name]; ok {
+			return resolved
+		}
+	}
+	return name
+}
+
+// exprLexer splits a DynamoDB expression into names, placeholders, keywords and punctuation
+type exprLexer struct {
+	tokens []string
+	pos    int
+}
+
+func lexExpression(expr string) *exprLexer {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),+-=", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '<' || c == '>':
+			if i+1 < len(expr) && (expr[i+1] == '=' || (c == '<' && expr[i+1] == '>')) {
+				tokens = append(tokens, expr[i:i+2])
+				i += 2
+			} else {
+				tokens = append(tokens, string(c))
+				i++
+			}
+		default:
+			j := i
+			for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("(),+-=<>", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return &exprLexer{tokens: tokens}
+}
+
+func (l *exprLexer) peek() string {
+	if l.pos < len(l.tokens) {
+		return l.tokens[l.pos]
+	}
+	return ""
+}
+
+func (l *exprLexer) next() string {
+	token := l.peek()
+	l.pos++
+	return token
+}
+
+func (l *exprLexer) expect(token string) error {
+	if got := l.next(); got != token {
+		return fmt.Errorf("fake dynamo: expected %q, got %q in %v", token, got, l.tokens)
+	}
+	return nil
+}
+
+// exprEnv resolves the names and values of one expression against an item
+type exprEnv struct {
+	item   map[string]types.AttributeValue
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+// operand evaluates a path, placeholder, or if_not_exists/list_append/size call, with an
+// optional + or - of a second operand
+func (e exprEnv) operand(l *exprLexer) (types.AttributeValue, error) {
+	value, err := e.term(l)
+	if err != nil {
+		return nil, err
+	}
+	if op := l.peek(); op == "+" || op == "-" {
+		l.next()
+		right, err := e.term(l)
+		if err != nil {
+			return nil, err
+		}
+		x, okX := value.(*types.AttributeValueMemberN)
+		y, okY := right.(*types.AttributeValueMemberN)
+		if !okX || !okY {
+			return nil, fmt.Errorf("fake dynamo: arithmetic on non-numbers in %v", l.tokens)
+		}
+		a, _ := strconv.ParseFloat(x.Value, 64)
+		b, _ := strconv.ParseFloat(y.Value, 64)
+		if op == "-" {
+			b = -b
+		}
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(a+b, 'f', -1, 64)}, nil
+	}
+	return value, nil
+}
+
+func (e exprEnv) term(l *exprLexer) (types.AttributeValue, error) {
+	token := l.next()
+	switch {
+	case strings.HasPrefix(token, ":"):
+		value, ok := e.values[token]
+		if !ok {
+			return nil, fmt.Errorf("fake dynamo: undefined value %s", token)
+		}
+		return value, nil
+	case token == "if_not_exists":
+		if err := l.expect("("); err != nil {
+			return nil, err
+		}
+		path := resolveName(l.next(), e.names)
+		if err := l.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := e.operand(l)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.expect(")"); err != nil {
+			return nil, err
+		}
+		if current, ok := e.item[path]; ok {
+			return current, nil
+		}
+		return fallback, nil
+	case token == "list_append":
+		if err := l.expect("("); err != nil {
+			return nil, err
+		}
+		first, err := e.operand(l)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.expect(","); err != nil {
+			return nil, err
+		}
+		second, err := e.operand(l)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.expect(")"); err != nil {
+			return nil, err
+		}
+		a, okA := first.(*types.AttributeValueMemberL)
+		b, okB := second.(*types.AttributeValueMemberL)
+		if !okA || !okB {
+			return nil, errors.New("fake dynamo: list_append of non-lists")
+		}
+		return &types.AttributeValueMemberL{Value: append(slices.Clone(a.Value), b.Value...)}, nil
+	}
+	return e.item[resolveName(token, e.names)], nil
+}
+
+// applyUpdate applies the SET, REMOVE, ADD and DELETE clauses of an update expression to
+// updated, evaluating every operand against the item as it was before the update
+func applyUpdate(expr string, old, updated map[string]types.AttributeValue, names map[string]string, values map[string]types.AttributeValue) error {
+	l := lexExpression(expr)
+	env := exprEnv{item: old, names: names, values: values}
+	if env.item == nil {
+		env.item = map[string]types.AttributeValue{}
+	}
+	clause := ""
+	for l.peek() != "" {
+		switch token := strings.ToUpper(l.peek()); token {
+		case "SET", "REMOVE", "ADD", "DELETE":
+			clause = token
+			l.next()
+			continue
+		case ",":
+			l.next()
+			continue
+		}
+
+		path := resolveName(l.next(), names)
+		switch clause {
+		case "SET":
+			if err := l.expect("="); err != nil {
+				return err
+			}
+			value, err := env.operand(l)
+			if err != nil {
+				return err
+			}
+			if value == nil {
+				return fmt.Errorf("fake dynamo: SET %s to a missing attribute", path)
+			}
+			updated[path] = cloneAV(value)
+		case "REMOVE":
+			delete(updated, path)
+		case "ADD", "DELETE":
+			value, err := env.term(l)
+			if err != nil {
+				return err
+			}
+			result, err := addOrDelete(clause, updated[path], value)
+			if err != nil {
+				return err
+			}
+			if result == nil {
+				delete(updated, path)
+			} else {
+				updated[path] = result
+			}
+		default:
+			return fmt.Errorf("fake dynamo: %q outside a clause in %q", path, expr)
+		}
+	}
+	return nil
+}
+
+// addOrDelete applies ADD to a number or set, or DELETE from a set; an emptied set is removed
+func addOrDelete(clause string, current, value types.AttributeValue) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberN:
+		if clause != "ADD" {
+			return nil, errors.New("fake dynamo: DELETE of a number")
+		}
+		total, _ := strconv.ParseFloat(v.Value, 64)
+		if c, ok := current.(*types.AttributeValueMemberN); ok {
+			n, _ := strconv.ParseFloat(c.Value, 64)
+			total += n
+		}
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(total, 'f', -1, 64)}, nil
+	case *types.AttributeValueMemberNS:
+		var set []string
+		if c, ok := current.(*types.AttributeValueMemberNS); ok {
+			set = slices.Clone(c.Value)
+		}
+		set = updateSet(clause, set, v.Value, func(a, b string) bool {
+			return compareAV(&types.AttributeValueMemberN{Value: a}, &types.AttributeValueMemberN{Value: b}) == 0
+		})
+		if len(set) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberNS{Value: set}, nil
+	case *types.AttributeValueMemberSS:
+		var set []string
+		if c, ok := current.(*types.AttributeValueMemberSS); ok {
+			set = slices.Clone(c.Value)
+		}
+		set = updateSet(clause, set, v.Value, func(a, b string) bool { return a == b })
+		if len(set) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberSS{Value: set}, nil
+	}
+	return nil, fmt.Errorf("fake dynamo: %s of %T", clause, value)
+}
+
+func updateSet(clause string, set, members []string, equal func(a, b string) bool) []string {
+	for _, member := range members {
+		i := slices.IndexFunc(set, func(s string) bool { return equal(s, member) })
+		switch {
+		case clause == "ADD" && i < 0:
+			set = append(set, member)
+		case clause == "DELETE" && i >= 0:
+			set = slices.Delete(set, i, i+1)
+		}
+	}
+	return set
+}
+
+// evalCondition evaluates a condition, key condition or filter expression against an item,
+// which is nil when it does not exist
+func evalCondition(expr string, item map[string]types.AttributeValue, names map[string]string, values map[string]types.AttributeValue) (bool, error) {
+	if item == nil {
+		item = map[string]types.AttributeValue{}
+	}
+	l := lexExpression(expr)
+	env := exprEnv{item: item, names: names, values: values}
+	ok, err := env.or(l)
+	if err != nil {
+		return false, err
+	}
+	if l.peek() != "" {
+		return false, fmt.Errorf("fake dynamo: unexpected %q in %q", l.peek(), expr)
+	}
+	return ok, nil
+}
+
+func (e exprEnv) or(l *exprLexer) (bool, error) {
+	result, err := e.and(l)
+	if err != nil {
+		return false, err
+	}
+	for strings.EqualFold(l.peek(), "OR") {
+		l.next()
+		right, err := e.and(l)
+		if err != nil {
+			return false, err
+		}
+		result = result || right
+	}
+	return result, nil
+}
+
+func (e exprEnv) and(l *exprLexer) (bool, error) {
+	result, err := e.not(l)
+	if err != nil {
+		return false, err
+	}
+	for strings.EqualFold(l.peek(), "AND") {
+		l.next()
+		right, err := e.not(l)
+		if err != nil {
+			return false, err
+		}
+		result = result && right
+	}
+	return result, nil
+}
+
+func (e exprEnv) not(l *exprLexer) (bool, error) {
+	if strings.EqualFold(l.peek(), "NOT") {
+		l.next()
+		result, err := e.not(l)
+		return !result, err
+	}
+	return e.predicate(l)
+}
+
+func (e exprEnv) predicate(l *exprLexer) (bool, error) {
+	switch token := l.peek(); token {
+	case "(":
+		l.next()
+		result, err := e.or(l)
+		if err != nil {
+			return false, err
+		}
+		return result, l.expect(")")
+	case "attribute_exists", "attribute_not_exists":
+		l.next()
+		if err := l.expect("("); err != nil {
+			return false, err
+		}
+		_, exists := e.item[resolveName(l.next(), e.names)]
+		if err := l.expect(")"); err != nil {
+			return false, err
+		}
+		return exists == (token == "attribute_exists"), nil
+	case "contains":
+		l.next()
+		if err := l.expect("("); err != nil {
+			return false, err
+		}
+		haystack := e.item[resolveName(l.next(), e.names)]
+		if err := l.expect(","); err != nil {
+			return false, err
+		}
+		needle, err := e.operand(l)
+		if err != nil {
+			return false, err
+		}
+		if err := l.expect(")"); err != nil {
+			return false, err
+		}
+		return containsAV(haystack, needle), nil
+	}
+
+	left, err := e.operand(l)
+	if err != nil {
+		return false, err
+	}
+	switch op := strings.ToUpper(l.next()); op {
+	case "BETWEEN":
+		low, err := e.operand(l)
+		if err != nil {
+			return false, err
+		}
+		if !strings.EqualFold(l.next(), "AND") {
+			return false, errors.New("fake dynamo: BETWEEN without AND")
+		}
+		high, err := e.operand(l)
+		if err != nil {
+			return false, err
+		}
+		return left != nil && compareAV(left, low) >= 0 && compareAV(left, high) <= 0, nil
+	case "IN":
+		if err := l.expect("("); err != nil {
+			return false, err
+		}
+		found := false
+		for {
+			candidate, err := e.operand(l)
+			if err != nil {
+				return false, err
+			}
+			found = found || equalAV(left, candidate)
+			if l.peek() != "," {
+				break
+			}
+			l.next()
+		}
+		return found, l.expect(")")
+	case "=", "<>", "<", "<=", ">", ">=":
+		right, err := e.operand(l)
+		if err != nil {
+			return false, err
+		}
+		if left == nil || right == nil {
+			return op == "<>" && (left != nil || right != nil), nil
+		}
+		c := compareAV(left, right)
+		switch op {
+		case "=":
+			return equalAV(left, right), nil
+		case "<>":
+			return !equalAV(left, right), nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	default:
+		return false, fmt.Errorf("fake dynamo: unsupported operator %q in %v", op, l.tokens)
+	}
+}
+
+// containsAV implements contains() for sets, lists and strings
+func containsAV(haystack, needle types.AttributeValue) bool {
+	switch h := haystack.(type) {
+	case *types.AttributeValueMemberNS:
+		return slices.ContainsFunc(h.Value, func(n string) bool { return equalAV(&types.AttributeValueMemberN{Value: n}, needle) })
+	case *types.AttributeValueMemberSS:
+		s, ok := needle.(*types.AttributeValueMemberS)
+		return ok && slices.Contains(h.Value, s.Value)
+	case *types.AttributeValueMemberS:
+		s, ok := needle.(*types.AttributeValueMemberS)
+		return ok && strings.Contains(h.Value, s.Value)
+	case *types.AttributeValueMemberL:
+		return slices.ContainsFunc(h.Value, func(av types.AttributeValue) bool { return equalAV(av, needle) })
+	}
+	return false
+}
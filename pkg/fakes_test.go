@@ -0,0 +1,673 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brTypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeCloudWatch answers GetMetricData and GetMetricStatistics from values, which returns
+// the datapoints of one metric given its namespace, name, statistic and dimensions. It
+// records every GetMetricData call so tests can count round trips.
+type fakeCloudWatch struct {
+	mu     sync.Mutex
+	values func(namespace, metricName, stat string, dims map[string]string) []float64
+	err    error
+	// pageSize splits GetMetricData results into pages of that many queries when set
+	pageSize int
+	// batches holds the number of queries in each GetMetricData call
+	batches    []int
+	statsCalls int
+}
+
+var _ CloudWatchMetricsAPI = (*fakeCloudWatch)(nil)
+
+func dimensionMap(dims []cwTypes.Dimension) map[string]string {
+	m := make(map[string]string, len(dims))
+	for _, d := range dims {
+		m[aws.ToString(d.Name)] = aws.ToString(d.Value)
+	}
+	return m
+}
+
+func (f *fakeCloudWatch) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.batches = append(f.batches, len(params.MetricDataQueries))
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	queries := params.MetricDataQueries
+	start := 0
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(*params.NextToken)
+	}
+	end := len(queries)
+	output := &cloudwatch.GetMetricDataOutput{}
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	for _, q := range queries[start:end] {
+		var values []float64
+		if f.values != nil && q.MetricStat != nil {
+			metric := q.MetricStat.Metric
+			values = f.values(aws.ToString(metric.Namespace), aws.ToString(metric.MetricName), aws.ToString(q.MetricStat.Stat), dimensionMap(metric.Dimensions))
+		}
+		output.MetricDataResults = append(output.MetricDataResults, cwTypes.MetricDataResult{Id: q.Id, Values: values})
+	}
+	return output, nil
+}
+
+func (f *fakeCloudWatch) GetMetricStatistics(ctx context.Context, params *cloudwatch.GetMetricStatisticsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.statsCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	output := &cloudwatch.GetMetricStatisticsOutput{}
+	if f.values == nil {
+		return output, nil
+	}
+	stat := ""
+	if len(params.Statistics) > 0 {
+		stat = string(params.Statistics[0])
+	}
+	for _, v := range f.values(aws.ToString(params.Namespace), aws.ToString(params.MetricName), stat, dimensionMap(params.Dimensions)) {
+		output.Datapoints = append(output.Datapoints, cwTypes.Datapoint{
+			Average: aws.Float64(v), Sum: aws.Float64(v), Maximum: aws.Float64(v), Minimum: aws.Float64(v),
+		})
+	}
+	return output, nil
+}
+
+// metricCalls returns the number of GetMetricData calls made so far
+func (f *fakeCloudWatch) metricCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.batches)
+}
+
+// fakeEC2 serves DescribeInstances from reservations; the other describe calls return nothing
+type fakeEC2 struct {
+	region       string
+	reservations []ec2Types.Reservation
+	err          error
+	calls        int
+}
+
+var _ EC2DescribeAPI = (*fakeEC2)(nil)
+
+func (f *fakeEC2) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: f.reservations}, nil
+}
+
+func (f *fakeEC2) DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error) {
+	f.calls++
+	return &ec2.DescribeVolumesOutput{}, f.err
+}
+
+func (f *fakeEC2) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	f.calls++
+	return &ec2.DescribeAddressesOutput{}, f.err
+}
+
+func (f *fakeEC2) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	f.calls++
+	return &ec2.DescribeNatGatewaysOutput{}, f.err
+}
+
+func (f *fakeEC2) DescribeSnapshots(ctx context.Context, params *ec2.DescribeSnapshotsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSnapshotsOutput, error) {
+	f.calls++
+	return &ec2.DescribeSnapshotsOutput{}, f.err
+}
+
+func (f *fakeEC2) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	f.calls++
+	return &ec2.DescribeImagesOutput{}, f.err
+}
+
+func (f *fakeEC2) Options() ec2.Options {
+	return ec2.Options{Region: f.region}
+}
+
+// testInstances returns n running instances i-0 … i-(n-1) of the given type in one reservation
+func testInstances(n int, instanceType string) []ec2Types.Reservation {
+	launched := aws.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	instances := make([]ec2Types.Instance, n)
+	for i := range instances {
+		instances[i] = ec2Types.Instance{
+			InstanceId:   aws.String(fmt.Sprintf("i-%d", i)),
+			InstanceType: ec2Types.InstanceType(instanceType),
+			LaunchTime:   launched,
+			Tags:         []ec2Types.Tag{{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("web-%d", i))}},
+		}
+	}
+	return []ec2Types.Reservation{{Instances: instances}}
+}
+
+// fakeRDS serves DescribeDBInstances from instances, pageSize at a time when set
+type fakeRDS struct {
+	mu        sync.Mutex
+	region    string
+	instances []rdsTypes.DBInstance
+	tags      map[string]map[string]string // by instance ARN
+	pageSize  int
+	err       error
+	calls     int
+	// cancel, when set, is called as call number cancelAt is made
+	cancel   context.CancelFunc
+	cancelAt int
+}
+
+var _ RDSDescribeAPI = (*fakeRDS)(nil)
+
+// count records a call; f.mu must be held
+func (f *fakeRDS) count() {
+	f.calls++
+	if f.cancel != nil && f.calls == f.cancelAt {
+		f.cancel()
+	}
+}
+
+func (f *fakeRDS) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count()
+	if f.err != nil {
+		return nil, f.err
+	}
+	start := 0
+	if params.Marker != nil {
+		start, _ = strconv.Atoi(*params.Marker)
+	}
+	end := len(f.instances)
+	output := &rds.DescribeDBInstancesOutput{}
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+		output.Marker = aws.String(strconv.Itoa(end))
+	}
+	output.DBInstances = f.instances[start:end]
+	return output, nil
+}
+
+func (f *fakeRDS) ListTagsForResource(ctx context.Context, params *rds.ListTagsForResourceInput, optFns ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count()
+	output := &rds.ListTagsForResourceOutput{}
+	for key, value := range f.tags[aws.ToString(params.ResourceName)] {
+		output.TagList = append(output.TagList, rdsTypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (f *fakeRDS) Options() rds.Options {
+	return rds.Options{Region: f.region}
+}
+
+// callCount returns the number of RDS calls made so far
+func (f *fakeRDS) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// testDBInstances returns n available PostgreSQL instances db-0 … db-(n-1)
+func testDBInstances(n int, class string) []rdsTypes.DBInstance {
+	instances := make([]rdsTypes.DBInstance, n)
+	for i := range instances {
+		id := fmt.Sprintf("db-%d", i)
+		instances[i] = rdsTypes.DBInstance{
+			DBInstanceIdentifier: aws.String(id),
+			DBInstanceArn:        aws.String("arn:aws:rds:eu-west-1:123456789012:db:" + id),
+			DBInstanceClass:      aws.String(class),
+			Engine:               aws.String("postgres"),
+			EngineVersion:        aws.String("16.3"),
+			StorageType:          aws.String("gp3"),
+			DBInstanceStatus:     aws.String("available"),
+			AllocatedStorage:     aws.Int32(100),
+			MultiAZ:              aws.Bool(false),
+			InstanceCreateTime:   aws.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		}
+	}
+	return instances
+}
+
+// fakeBucket is a bucket served by fakeS3Buckets
+type fakeBucket struct {
+	name       string
+	region     string
+	tags       map[string]string
+	rules      []s3Types.LifecycleRule
+	objects    []s3Types.Object
+	versioning bool
+}
+
+// fakeS3Buckets serves the bucket calls of the S3 collector from buckets. Buckets must be
+// in the client's region, as the collector creates real clients for other regions.
+type fakeS3Buckets struct {
+	mu      sync.Mutex
+	region  string
+	buckets []fakeBucket
+	listErr error
+	calls   int
+	// cancel, when set, is called as call number cancelAt is made
+	cancel   context.CancelFunc
+	cancelAt int
+}
+
+var _ S3BucketAPI = (*fakeS3Buckets)(nil)
+
+// count records a call; f.mu must be held
+func (f *fakeS3Buckets) count() {
+	f.calls++
+	if f.cancel != nil && f.calls == f.cancelAt {
+		f.cancel()
+	}
+}
+
+func (f *fakeS3Buckets) bucket(name *string) (fakeBucket, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count()
+	for _, b := range f.buckets {
+		if b.name == aws.ToString(name) {
+			return b, nil
+		}
+	}
+	return fakeBucket{}, fmt.Errorf("NoSuchBucket: %s", aws.ToString(name))
+}
+
+// callCount returns the number of S3 calls made so far
+func (f *fakeS3Buckets) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeS3Buckets) ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count()
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	output := &s3.ListBucketsOutput{}
+	for _, b := range f.buckets {
+		output.Buckets = append(output.Buckets, s3Types.Bucket{
+			Name:         aws.String(b.name),
+			CreationDate: aws.Time(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)),
+		})
+	}
+	return output, nil
+}
+
+func (f *fakeS3Buckets) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	b, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	region := b.region
+	if region == "" {
+		region = f.region
+	}
+	if region == "us-east-1" {
+		region = ""
+	}
+	return &s3.GetBucketLocationOutput{LocationConstraint: s3Types.BucketLocationConstraint(region)}, nil
+}
+
+func (f *fakeS3Buckets) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	b, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.GetBucketTaggingOutput{}
+	for key, value := range b.tags {
+		output.TagSet = append(output.TagSet, s3Types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (f *fakeS3Buckets) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	b, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.rules) == 0 {
+		return nil, errors.New("NoSuchLifecycleConfiguration")
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: b.rules}, nil
+}
+
+func (f *fakeS3Buckets) GetBucketPolicyStatus(ctx context.Context, params *s3.GetBucketPolicyStatusInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyStatusOutput, error) {
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.GetBucketPolicyStatusOutput{PolicyStatus: &s3Types.PolicyStatus{IsPublic: aws.Bool(false)}}, nil
+}
+
+func (f *fakeS3Buckets) GetPublicAccessBlock(ctx context.Context, params *s3.GetPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.GetPublicAccessBlockOutput, error) {
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.GetPublicAccessBlockOutput{PublicAccessBlockConfiguration: &s3Types.PublicAccessBlockConfiguration{
+		BlockPublicAcls: aws.Bool(true), IgnorePublicAcls: aws.Bool(true), BlockPublicPolicy: aws.Bool(true), RestrictPublicBuckets: aws.Bool(true),
+	}}, nil
+}
+
+func (f *fakeS3Buckets) GetBucketEncryption(ctx context.Context, params *s3.GetBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error) {
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: &s3Types.ServerSideEncryptionConfiguration{
+		Rules: []s3Types.ServerSideEncryptionRule{{
+			ApplyServerSideEncryptionByDefault: &s3Types.ServerSideEncryptionByDefault{SSEAlgorithm: s3Types.ServerSideEncryptionAes256},
+		}},
+	}}, nil
+}
+
+func (f *fakeS3Buckets) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	b, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.GetBucketVersioningOutput{}
+	if b.versioning {
+		output.Status = s3Types.BucketVersioningStatusEnabled
+	}
+	return output, nil
+}
+
+func (f *fakeS3Buckets) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	b, err := f.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.ListObjectsV2Output{Contents: b.objects, IsTruncated: aws.Bool(false)}, nil
+}
+
+func (f *fakeS3Buckets) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.ListMultipartUploadsOutput{IsTruncated: aws.Bool(false)}, nil
+}
+
+func (f *fakeS3Buckets) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.ListPartsOutput{IsTruncated: aws.Bool(false)}, nil
+}
+
+func (f *fakeS3Buckets) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	if _, err := f.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false)}, nil
+}
+
+func (f *fakeS3Buckets) Options() s3.Options {
+	return s3.Options{Region: f.region}
+}
+
+// fakeSQS records the messages sent to it. failEntry makes a batch entry fail by its ID, and
+// err fails whole calls.
+type fakeSQS struct {
+	mu        sync.Mutex
+	messages  []string   // bodies of every message accepted
+	batches   [][]string // entry IDs of each SendMessageBatch call
+	failEntry func(id string) bool
+	err       error
+}
+
+var _ SQSQueueAPI = (*fakeSQS)(nil)
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.messages = append(f.messages, aws.ToString(params.MessageBody))
+	return &sqs.SendMessageOutput{MessageId: aws.String(fmt.Sprintf("msg-%d", len(f.messages)))}, nil
+}
+
+func (f *fakeSQS) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, len(params.Entries))
+	for i, entry := range params.Entries {
+		ids[i] = aws.ToString(entry.Id)
+	}
+	f.batches = append(f.batches, ids)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if len(params.Entries) > 10 {
+		return nil, errors.New("AWS.SimpleQueueService.TooManyEntriesInBatchRequest")
+	}
+
+	output := &sqs.SendMessageBatchOutput{}
+	for _, entry := range params.Entries {
+		if f.failEntry != nil && f.failEntry(aws.ToString(entry.Id)) {
+			output.Failed = append(output.Failed, sqsTypes.BatchResultErrorEntry{
+				Id: entry.Id, Code: aws.String("InternalError"), Message: aws.String("try again"), SenderFault: false,
+			})
+			continue
+		}
+		f.messages = append(f.messages, aws.ToString(entry.MessageBody))
+		output.Successful = append(output.Successful, sqsTypes.SendMessageBatchResultEntry{Id: entry.Id, MessageId: entry.Id})
+	}
+	return output, nil
+}
+
+// workItems decodes the messages sent so far
+func (f *fakeSQS) workItems(t interface{ Fatalf(string, ...any) }) []WorkItem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make([]WorkItem, len(f.messages))
+	for i, body := range f.messages {
+		if err := json.Unmarshal([]byte(body), &items[i]); err != nil {
+			t.Fatalf("message %d is not a work item: %v", i, err)
+		}
+	}
+	return items
+}
+
+// fakeBedrock answers InvokeModel and Converse with the functions it is given, which
+// default to an error. delay holds up a Converse call by its prompt until ctx is done, and
+// maxInFlight records the most Converse calls that were running at once.
+type fakeBedrock struct {
+	mu            sync.Mutex
+	invoke        func(modelID string, body []byte) ([]byte, error)
+	converse      func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error)
+	delay         func(prompt string) time.Duration
+	invokeCalls   int
+	converseCalls int
+	inFlight      int
+	maxInFlight   int
+}
+
+var _ BedrockInvoker = (*fakeBedrock)(nil)
+
+func (f *fakeBedrock) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	f.mu.Lock()
+	f.invokeCalls++
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.invoke == nil {
+		return nil, errors.New("InvokeModel not expected")
+	}
+	body, err := f.invoke(aws.ToString(params.ModelId), params.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &bedrockruntime.InvokeModelOutput{Body: body, ContentType: aws.String("application/json")}, nil
+}
+
+func (f *fakeBedrock) Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	f.mu.Lock()
+	f.converseCalls++
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.converse == nil {
+		return nil, errors.New("Converse not expected")
+	}
+	var prompt strings.Builder
+	for _, message := range params.Messages {
+		for _, block := range message.Content {
+			if text, ok := block.(*brTypes.ContentBlockMemberText); ok {
+				prompt.WriteString(text.Value)
+			}
+		}
+	}
+	if f.delay != nil {
+		select {
+		case <-time.After(f.delay(prompt.String())):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return f.converse(aws.ToString(params.ModelId), prompt.String())
+}
+
+// converseText is a Converse response with one text block and the given token counts
+func converseText(text string, inputTokens, outputTokens int32) *bedrockruntime.ConverseOutput {
+	return &bedrockruntime.ConverseOutput{
+		Output: &brTypes.ConverseOutputMemberMessage{Value: brTypes.Message{
+			Role:    brTypes.ConversationRoleAssistant,
+			Content: []brTypes.ContentBlock{&brTypes.ContentBlockMemberText{Value: text}},
+		}},
+		Usage: &brTypes.TokenUsage{InputTokens: aws.Int32(inputTokens), OutputTokens: aws.Int32(outputTokens), TotalTokens: aws.Int32(inputTokens + outputTokens)},
+	}
+}
+
+// fakeS3Objects is an in-memory object store for one bucket. ListObjectsV2 returns
+// pageSize keys a page when set, and keys in failGet cannot be read.
+type fakeS3Objects struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	pageSize  int
+	failGet   map[string]bool
+	getCalls  int
+	listCalls int
+	putErr    error
+}
+
+var (
+	_ S3ResultStore  = (*fakeS3Objects)(nil)
+	_ S3RequestStore = (*fakeS3Objects)(nil)
+)
+
+func newFakeS3Objects() *fakeS3Objects {
+	return &fakeS3Objects{objects: make(map[string][]byte), failGet: make(map[string]bool)}
+}
+
+func (f *fakeS3Objects) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getCalls++
+	key := aws.ToString(params.Key)
+	if f.failGet[key] {
+		return nil, fmt.Errorf("InternalError reading %s", key)
+	}
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, &s3Types.NoSuchKey{Message: aws.String(key)}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ContentLength: aws.Int64(int64(len(data)))}, nil
+}
+
+func (f *fakeS3Objects) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3Objects) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listCalls++
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	// S3 lists keys in UTF-8 binary order
+	sort.Strings(keys)
+
+	start := 0
+	if params.ContinuationToken != nil {
+		start, _ = strconv.Atoi(*params.ContinuationToken)
+	}
+	end := len(keys)
+	output := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+		output.IsTruncated = aws.Bool(true)
+		output.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	for _, key := range keys[start:end] {
+		output.Contents = append(output.Contents, s3Types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(f.objects[key])))})
+	}
+	output.KeyCount = aws.Int32(int32(len(output.Contents)))
+	return output, nil
+}
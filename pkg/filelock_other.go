@@ -0,0 +1,16 @@
+//go:build !unix
+
+package pkg
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable; AppendHistory still writes each record
+// with a single append, which keeps concurrent records from interleaving in practice
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op where flock is unavailable
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -26,23 +26,102 @@ const (
 	ColorGrey    = "\033[90m"
 )
 
+// Verbosity levels of the text report, set with --verbosity or output.verbosity
+const (
+	VerbosityQuiet    = "quiet"    // sustainability summary and one line per resource
+	VerbosityNormal   = "normal"   // summary plus the details and analysis of every resource
+	VerbosityDetailed = "detailed" // normal plus the metric breakdowns and figures behind the summary
+)
+
+// ReportOptions controls how FormatAnalysisReport renders a report
+type ReportOptions struct {
+	Colorize  bool
+	Verbosity string // VerbosityQuiet, VerbosityNormal or VerbosityDetailed; empty means normal
+	SortBy    string // SortBySavings, SortByCO2, SortByCost or SortByName; empty means savings
+	// GroupSimilarity adds a Resource Groups section clustering resources whose embeddings are
+	// at least this similar (see ClusterReportItems); 0 leaves it out
+	GroupSimilarity float64
+	// Account, if set, is printed at the top of the report at normal and detailed verbosity
+	Account *AccountRecommendations
+	// Commitments, if set, follows the sustainability summary at every verbosity
+	Commitments *CommitmentCoverage
+	// Metadata, if set, is printed under the "Generated:" line
+	Metadata *ReportMetadata
+}
+
 // FormatAnalysisReport prints the analysis results in a user-friendly format
-func FormatAnalysisReport(w io.Writer, report []ReportItem, colorize bool) {
+func FormatAnalysisReport(w io.Writer, report []ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	sortBy := opts.SortBy
+	if sortBy == "" {
+		sortBy = SortBySavings
+	}
+	opts.SortBy = sortBy
+
+	// Rank first; each section below keeps the ranked order unless sorting by name
+	report = RankReport(report, sortBy)
+
+	if opts.Verbosity == VerbosityQuiet {
+		printSustainabilitySummary(w, report, colorize)
+		printCommitmentCoverage(w, opts.Commitments, colorize)
+		printResourceSummaryTable(w, report, colorize)
+		return
+	}
+
 	// Header
 	printSustainabilityHeader(w, colorize)
 	printHeader(w, "GreenOps Analysis Report", colorize)
-	fmt.Fprintf(w, "Generated: %s\n", time.Now().Format(time.RFC1123))
+	fmt.Fprintf(w, "Generated: %s by %s\n", time.Now().Format(time.RFC1123), ReportGenerator())
+	printReportMetadata(w, opts.Metadata, colorize)
+	printAccountRecommendations(w, opts.Account, opts)
 	printSustainabilitySummary(w, report, colorize)
-	fmt.Printf("\n")
+	printCommitmentCoverage(w, opts.Commitments, colorize)
+	printResourceSummaryTable(w, report, colorize)
+	printGravitonMigrations(w, report, colorize)
+	fmt.Fprintln(w)
+
+	// A report spanning several accounts lists the resources of each account in turn
+	accountIDs, byAccount := groupReportByAccount(report)
+	if len(accountIDs) < 2 {
+		printResourceDetails(w, report, opts)
+		return
+	}
+	for _, accountID := range accountIDs {
+		printHeader(w, "Account "+accountLabel(accountID), colorize)
+		printResourceDetails(w, byAccount[accountID], opts)
+		fmt.Fprintln(w)
+	}
+}
+
+// printResourceDetails prints the resource counts and the details of every resource of a
+// report, by resource type
+func printResourceDetails(w io.Writer, report []ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	sortBy := opts.SortBy
+
 	// Pre-process and separate resources by type
 	var ec2Items []ReportItem
 	var s3Items []ReportItem
 	var rdsItems []ReportItem
+	var ebsItems []ReportItem
+	var lambdaItems []ReportItem
+	var elbItems []ReportItem
+	var networkItems []ReportItem
+	var dynamoItems []ReportItem
+	var cacheItems []ReportItem
+	var snapshotItems []ReportItem
 
 	// Debug counter for validating resources
 	ec2Count := 0
 	s3Count := 0
 	rdsCount := 0
+	ebsCount := 0
+	lambdaCount := 0
+	elbCount := 0
+	networkCount := 0
+	dynamoCount := 0
+	cacheCount := 0
+	snapshotCount := 0
 	unknownCount := 0
 
 	// Explicitly separate resources by type
@@ -65,6 +144,41 @@ func FormatAnalysisReport(w io.Writer, report []ReportItem, colorize bool) {
 			if !isEmptyStruct(item.RDSInstance) && item.RDSInstance.InstanceID != "" {
 				rdsItems = append(rdsItems, item)
 			}
+		} else if resourceType == ResourceTypeEBS {
+			ebsCount++
+			if !isEmptyStruct(item.EBSVolume) && item.EBSVolume.VolumeID != "" {
+				ebsItems = append(ebsItems, item)
+			}
+		} else if resourceType == ResourceTypeLambda {
+			lambdaCount++
+			if !isEmptyStruct(item.LambdaFunction) && item.LambdaFunction.FunctionName != "" {
+				lambdaItems = append(lambdaItems, item)
+			}
+		} else if resourceType == ResourceTypeELB {
+			elbCount++
+			if !isEmptyStruct(item.LoadBalancer) && item.LoadBalancer.Name != "" {
+				elbItems = append(elbItems, item)
+			}
+		} else if resourceType == ResourceTypeNetwork {
+			networkCount++
+			if !isEmptyStruct(item.NetworkResource) && item.NetworkResource.ResourceID != "" {
+				networkItems = append(networkItems, item)
+			}
+		} else if resourceType == ResourceTypeDynamoDB {
+			dynamoCount++
+			if !isEmptyStruct(item.DynamoTable) && item.DynamoTable.TableName != "" {
+				dynamoItems = append(dynamoItems, item)
+			}
+		} else if resourceType == ResourceTypeElastiCache {
+			cacheCount++
+			if !isEmptyStruct(item.ElastiCache) && item.ElastiCache.ClusterID != "" {
+				cacheItems = append(cacheItems, item)
+			}
+		} else if resourceType == ResourceTypeSnapshots {
+			snapshotCount++
+			if len(item.Snapshots) > 0 {
+				snapshotItems = append(snapshotItems, item)
+			}
 		} else {
 			unknownCount++
 
@@ -128,7 +242,17 @@ func FormatAnalysisReport(w io.Writer, report []ReportItem, colorize bool) {
 	ec2DisplayCount := len(ec2Items)
 	s3DisplayCount := len(s3Items)
 	rdsDisplayCount := len(rdsItems)
-	totalCount := ec2DisplayCount + s3DisplayCount + rdsDisplayCount
+	ebsDisplayCount := len(ebsItems)
+	lambdaDisplayCount := len(lambdaItems)
+	elbDisplayCount := len(elbItems)
+	networkDisplayCount := len(networkItems)
+	dynamoDisplayCount := len(dynamoItems)
+	cacheDisplayCount := len(cacheItems)
+	snapshotDisplayCount := 0
+	for _, item := range snapshotItems {
+		snapshotDisplayCount += len(item.Snapshots)
+	}
+	totalCount := ec2DisplayCount + s3DisplayCount + rdsDisplayCount + ebsDisplayCount + lambdaDisplayCount + elbDisplayCount + networkDisplayCount + dynamoDisplayCount + cacheDisplayCount + snapshotDisplayCount
 
 	if ec2DisplayCount > 0 {
 		fmt.Fprintf(w, "EC2 instances analyzed: %d\n", ec2DisplayCount)
@@ -139,19 +263,49 @@ func FormatAnalysisReport(w io.Writer, report []ReportItem, colorize bool) {
 	if rdsDisplayCount > 0 {
 		fmt.Fprintf(w, "RDS instances analyzed: %d\n", rdsDisplayCount)
 	}
+	if ebsDisplayCount > 0 {
+		fmt.Fprintf(w, "EBS volumes analyzed: %d\n", ebsDisplayCount)
+	}
+	if lambdaDisplayCount > 0 {
+		fmt.Fprintf(w, "Lambda functions analyzed: %d\n", lambdaDisplayCount)
+	}
+	if elbDisplayCount > 0 {
+		fmt.Fprintf(w, "Load balancers analyzed: %d\n", elbDisplayCount)
+	}
+	if networkDisplayCount > 0 {
+		fmt.Fprintf(w, "Idle Elastic IPs and NAT gateways: %d\n", networkDisplayCount)
+	}
+	if dynamoDisplayCount > 0 {
+		fmt.Fprintf(w, "DynamoDB tables analyzed: %d\n", dynamoDisplayCount)
+	}
+	if cacheDisplayCount > 0 {
+		fmt.Fprintf(w, "ElastiCache clusters analyzed: %d\n", cacheDisplayCount)
+	}
+	if snapshotDisplayCount > 0 {
+		fmt.Fprintf(w, "Stale EBS snapshots: %d\n", snapshotDisplayCount)
+	}
 	fmt.Fprintf(w, "Total resources analyzed: %d\n", totalCount)
 
+	if opts.GroupSimilarity > 0 {
+		printResourceGroups(w, report, opts.GroupSimilarity, colorize)
+	}
+
 	// Print EC2 instance details
 	if len(ec2Items) > 0 {
 		printEC2DetailsHeader(w, colorize)
 
-		// Sort instances by ID for consistent display
-		sort.Slice(ec2Items, func(i, j int) bool {
-			return ec2Items[i].Instance.InstanceID < ec2Items[j].Instance.InstanceID
-		})
+		if sortBy == SortByName {
+			// Sort instances by region, then ID, so each region's instances are grouped
+			sort.Slice(ec2Items, func(i, j int) bool {
+				if ec2Items[i].Instance.Region != ec2Items[j].Instance.Region {
+					return ec2Items[i].Instance.Region < ec2Items[j].Instance.Region
+				}
+				return ec2Items[i].Instance.InstanceID < ec2Items[j].Instance.InstanceID
+			})
+		}
 
 		for i, item := range ec2Items {
-			printEC2Details(w, i+1, item, colorize)
+			printEC2Details(w, i+1, item, opts)
 		}
 	}
 
@@ -159,13 +313,15 @@ func FormatAnalysisReport(w io.Writer, report []ReportItem, colorize bool) {
 	if len(s3Items) > 0 {
 		printS3DetailsHeader(w, colorize)
 
-		// Sort buckets by name for consistent display
-		sort.Slice(s3Items, func(i, j int) bool {
-			return s3Items[i].S3Bucket.BucketName < s3Items[j].S3Bucket.BucketName
-		})
+		if sortBy == SortByName {
+			// Sort buckets by name for consistent display
+			sort.Slice(s3Items, func(i, j int) bool {
+				return s3Items[i].S3Bucket.BucketName < s3Items[j].S3Bucket.BucketName
+			})
+		}
 
 		for i, item := range s3Items {
-			printS3Details(w, i+1, item, colorize)
+			printS3Details(w, i+1, item, opts)
 		}
 	}
 
@@ -173,15 +329,144 @@ func FormatAnalysisReport(w io.Writer, report []ReportItem, colorize bool) {
 	if len(rdsItems) > 0 {
 		printRDSDetailsHeader(w, colorize)
 
-		// Sort instances by ID for consistent display
-		sort.Slice(rdsItems, func(i, j int) bool {
-			return rdsItems[i].RDSInstance.InstanceID < rdsItems[j].RDSInstance.InstanceID
-		})
+		if sortBy == SortByName {
+			// Sort instances by region, then ID, so each region's instances are grouped
+			sort.Slice(rdsItems, func(i, j int) bool {
+				if rdsItems[i].RDSInstance.Region != rdsItems[j].RDSInstance.Region {
+					return rdsItems[i].RDSInstance.Region < rdsItems[j].RDSInstance.Region
+				}
+				return rdsItems[i].RDSInstance.InstanceID < rdsItems[j].RDSInstance.InstanceID
+			})
+		}
 
 		for i, item := range rdsItems {
-			printRDSDetails(w, i+1, item, colorize)
+			printRDSDetails(w, i+1, item, opts)
+		}
+	}
+
+	// Print EBS volume details
+	if len(ebsItems) > 0 {
+		printEBSDetailsHeader(w, colorize)
+
+		if sortBy == SortByName {
+			// Sort volumes by region, then ID, so each region's volumes are grouped
+			sort.Slice(ebsItems, func(i, j int) bool {
+				if ebsItems[i].EBSVolume.Region != ebsItems[j].EBSVolume.Region {
+					return ebsItems[i].EBSVolume.Region < ebsItems[j].EBSVolume.Region
+				}
+				return ebsItems[i].EBSVolume.VolumeID < ebsItems[j].EBSVolume.VolumeID
+			})
+		}
+
+		for i, item := range ebsItems {
+			printEBSDetails(w, i+1, item, opts)
+		}
+	}
+
+	// Print Lambda function details
+	if len(lambdaItems) > 0 {
+		printLambdaDetailsHeader(w, colorize)
+
+		if sortBy == SortByName {
+			// Sort functions by region, then name, so each region's functions are grouped
+			sort.Slice(lambdaItems, func(i, j int) bool {
+				if lambdaItems[i].LambdaFunction.Region != lambdaItems[j].LambdaFunction.Region {
+					return lambdaItems[i].LambdaFunction.Region < lambdaItems[j].LambdaFunction.Region
+				}
+				return lambdaItems[i].LambdaFunction.FunctionName < lambdaItems[j].LambdaFunction.FunctionName
+			})
+		}
+
+		for i, item := range lambdaItems {
+			printLambdaDetails(w, i+1, item, opts)
+		}
+	}
+
+	// Print load balancer details
+	if len(elbItems) > 0 {
+		printELBDetailsHeader(w, colorize)
+
+		if sortBy == SortByName {
+			// Sort load balancers by region, then name, so each region's load balancers are grouped
+			sort.Slice(elbItems, func(i, j int) bool {
+				if elbItems[i].LoadBalancer.Region != elbItems[j].LoadBalancer.Region {
+					return elbItems[i].LoadBalancer.Region < elbItems[j].LoadBalancer.Region
+				}
+				return elbItems[i].LoadBalancer.Name < elbItems[j].LoadBalancer.Name
+			})
+		}
+
+		for i, item := range elbItems {
+			printELBDetails(w, i+1, item, opts)
+		}
+	}
+
+	// Print Elastic IP and NAT gateway details
+	if len(networkItems) > 0 {
+		printNetworkDetailsHeader(w, colorize)
+
+		if sortBy == SortByName {
+			// Sort by region, then type and ID, so each region's findings are grouped
+			sort.Slice(networkItems, func(i, j int) bool {
+				a, b := networkItems[i].NetworkResource, networkItems[j].NetworkResource
+				if a.Region != b.Region {
+					return a.Region < b.Region
+				}
+				if a.Type != b.Type {
+					return a.Type < b.Type
+				}
+				return a.ResourceID < b.ResourceID
+			})
+		}
+
+		for i, item := range networkItems {
+			printNetworkDetails(w, i+1, item, opts)
+		}
+	}
+
+	// Print DynamoDB table details
+	if len(dynamoItems) > 0 {
+		printDynamoDetailsHeader(w, colorize)
+
+		if sortBy == SortByName {
+			// Sort tables by region, then name, so each region's tables are grouped
+			sort.Slice(dynamoItems, func(i, j int) bool {
+				if dynamoItems[i].DynamoTable.Region != dynamoItems[j].DynamoTable.Region {
+					return dynamoItems[i].DynamoTable.Region < dynamoItems[j].DynamoTable.Region
+				}
+				return dynamoItems[i].DynamoTable.TableName < dynamoItems[j].DynamoTable.TableName
+			})
+		}
+
+		for i, item := range dynamoItems {
+			printDynamoDetails(w, i+1, item, opts)
+		}
+	}
+
+	// Print ElastiCache cluster details
+	if len(cacheItems) > 0 {
+		printElastiCacheDetailsHeader(w, colorize)
+
+		if sortBy == SortByName {
+			// Sort clusters by region, then ID, so each region's clusters are grouped
+			sort.Slice(cacheItems, func(i, j int) bool {
+				if cacheItems[i].ElastiCache.Region != cacheItems[j].ElastiCache.Region {
+					return cacheItems[i].ElastiCache.Region < cacheItems[j].ElastiCache.Region
+				}
+				return cacheItems[i].ElastiCache.ClusterID < cacheItems[j].ElastiCache.ClusterID
+			})
+		}
+
+		for i, item := range cacheItems {
+			printElastiCacheDetails(w, i+1, item, opts)
 		}
 	}
+
+	// Print the stale snapshot summary
+	if len(snapshotItems) > 0 {
+		printSnapshotDetailsHeader(w, colorize)
+		printSnapshotDetails(w, snapshotItems, opts)
+	}
 }
 
 // printSustainabilityHeader prints a banner for sustainability focus
@@ -201,76 +486,124 @@ func printSustainabilityHeader(w io.Writer, colorize bool) {
 	}
 }
 
-// printSustainabilitySummary prints a summary of CO2 emissions and potential savings
-func printSustainabilitySummary(w io.Writer, report []ReportItem, colorize bool) {
-	// Calculate total CO2 and potential savings
-	var totalCO2 float64
-	var potentialCO2Savings float64
-	var totalCost float64
-	var potentialCostSavings float64
+// ReportSummary aggregates the sustainability and cost figures of a report
+type ReportSummary struct {
+	TotalCO2             float64        `json:"total_co2_kg"`
+	PotentialCO2Savings  float64        `json:"potential_co2_savings_kg"`
+	TotalCost            float64        `json:"total_monthly_cost"`
+	PotentialCostSavings float64        `json:"potential_monthly_savings"`
+	ResourceCounts       map[string]int `json:"resource_counts"`
+	TotalResources       int            `json:"total_resources"`
+	CostSources          map[string]int `json:"cost_sources,omitempty"` // items per ReportItem.CostSource
+	// EC2 instances the scan classified as idle
+	IdleResources int `json:"idle_resources,omitempty"`
+	// Cost Explorer spend and the matching estimates, for the resources that have both
+	ActualCostResources      int     `json:"actual_cost_resources,omitempty"`
+	ActualCost               float64 `json:"actual_monthly_cost,omitempty"`
+	EstimatedCostWithActuals float64 `json:"estimated_cost_with_actuals,omitempty"`
+	// Bedrock usage of the analyses in the report
+	AnalysisUsage
+}
+
+// SummarizeReport calculates total CO2 and potential savings across all report items
+func SummarizeReport(report []ReportItem) ReportSummary {
+	summary := ReportSummary{
+		ResourceCounts: make(map[string]int),
+		CostSources:    make(map[string]int),
+	}
 
 	// Process each report item
 	for _, item := range report {
-		// Extract CO2 footprint
-		var itemCO2 float64
-
-		// For RDS, look for "CO2 Footprint: X kg"
-		if item.GetResourceType() == ResourceTypeRDS {
-			// Try using markdown bold format first (most common in our output)
-			if strings.Contains(item.Analysis, "CO2 Footprint:") {
-				itemCO2 = extractNumberAfterPhrase(item.Analysis, "CO2 Footprint:")
-			} else if item.GetResourceType() == ResourceTypeEC2 {
-			}
-			// For EC2, look for the Monthly CO2 Footprint calculation
-			if strings.Contains(item.Analysis, "Monthly CO2 Footprint Calculation") {
-				// Try to find the calculation result after "="
-				re := regexp.MustCompile(`= ([\d\.]+) kg CO2/month`)
-				matches := re.FindStringSubmatch(item.Analysis)
-				if len(matches) > 1 {
-					itemCO2, _ = strconv.ParseFloat(matches[1], 64)
-				}
-			}
-		} else if item.GetResourceType() == ResourceTypeS3 {
-			// For S3, try the standard format
-			if strings.Contains(item.Analysis, "CO2 Footprint:") {
-				itemCO2 = extractNumberAfterPhrase(item.Analysis, "CO2 Footprint:")
-			}
+		summary.ResourceCounts[string(item.GetResourceType())]++
+		summary.TotalResources++
+		if item.CostSource != "" {
+			summary.CostSources[item.CostSource]++
+		}
+		summary.AnalysisUsage.Add(item.AnalysisUsage)
+		if item.IsIdle() {
+			summary.IdleResources++
 		}
 
-		// Extract cost
-		var itemCost float64
-		if strings.Contains(item.Analysis, "Estimated Monthly Cost:") {
-			costText := item.Analysis[strings.Index(item.Analysis, "Estimated Monthly Cost:"):]
-			if strings.Contains(costText, "$") {
-				itemCost = extractNumberAfterPhrase(costText, "$")
-			}
+		itemCO2, itemCost, itemCostSavings := extractItemMetrics(item)
+
+		// Calculate CO2 savings using the same ratio as cost savings
+		itemCO2Savings := estimateCO2Savings(itemCO2, itemCost, itemCostSavings)
+
+		if actual := item.ActualMonthlyCost(); actual > 0 {
+			summary.ActualCostResources++
+			summary.ActualCost += actual
+			summary.EstimatedCostWithActuals += itemCost
 		}
 
-		// Extract savings
-		var itemCostSavings float64
-		if strings.Contains(item.Analysis, "Monthly Savings Potential:") {
-			savingsText := item.Analysis[strings.Index(item.Analysis, "Monthly Savings Potential:"):]
-			if strings.Contains(savingsText, "$") {
-				itemCostSavings = extractNumberAfterPhrase(savingsText, "$")
+		// Add to totals
+		summary.TotalCO2 += itemCO2
+		summary.TotalCost += itemCost
+		summary.PotentialCO2Savings += itemCO2Savings
+		summary.PotentialCostSavings += itemCostSavings
+	}
+
+	return summary
+}
+
+// extractItemMetrics returns the monthly CO2 footprint, cost and savings for a single item,
+// preferring the structured fields and falling back to the analysis text for older records
+func extractItemMetrics(item ReportItem) (itemCO2, itemCost, itemCostSavings float64) {
+	if item.HasStructuredMetrics() {
+		return item.CO2KgMonthly, item.MonthlyCost, item.MonthlySavings
+	}
+
+	// Extract CO2 footprint
+	// For RDS, look for "CO2 Footprint: X kg"
+	if item.GetResourceType() == ResourceTypeRDS {
+		// Try using markdown bold format first (most common in our output)
+		if strings.Contains(item.Analysis, "CO2 Footprint:") {
+			itemCO2 = extractNumberAfterPhrase(item.Analysis, "CO2 Footprint:")
+		} else if item.GetResourceType() == ResourceTypeEC2 {
+		}
+		// For EC2, look for the Monthly CO2 Footprint calculation
+		if strings.Contains(item.Analysis, "Monthly CO2 Footprint Calculation") {
+			// Try to find the calculation result after "="
+			re := regexp.MustCompile(`= ([\d\.]+) kg CO2/month`)
+			matches := re.FindStringSubmatch(item.Analysis)
+			if len(matches) > 1 {
+				itemCO2, _ = strconv.ParseFloat(matches[1], 64)
 			}
 		}
+	} else if item.GetResourceType() == ResourceTypeS3 || item.GetResourceType() == ResourceTypeEBS || item.GetResourceType() == ResourceTypeLambda || item.GetResourceType() == ResourceTypeELB || item.GetResourceType() == ResourceTypeNetwork || item.GetResourceType() == ResourceTypeDynamoDB || item.GetResourceType() == ResourceTypeElastiCache || item.GetResourceType() == ResourceTypeSnapshots {
+		// For S3, EBS, Lambda, ELB, network, DynamoDB, ElastiCache and snapshot findings, try the standard format
+		if strings.Contains(item.Analysis, "CO2 Footprint:") {
+			itemCO2 = extractNumberAfterPhrase(item.Analysis, "CO2 Footprint:")
+		}
+	}
 
-		// Extract or calculate CO2 savings
-		var itemCO2Savings float64
-
-		// Calculate CO2 savings using the same ratio as cost savings
-		if itemCO2 > 0 && itemCost > 0 && itemCostSavings > 0 {
-			savingsRatio := itemCostSavings / itemCost
-			itemCO2Savings = itemCO2 * savingsRatio
+	// Extract cost
+	if strings.Contains(item.Analysis, "Estimated Monthly Cost:") {
+		costText := item.Analysis[strings.Index(item.Analysis, "Estimated Monthly Cost:"):]
+		if strings.Contains(costText, "$") {
+			itemCost = extractNumberAfterPhrase(costText, "$")
 		}
+	}
 
-		// Add to totals
-		totalCO2 += itemCO2
-		totalCost += itemCost
-		potentialCO2Savings += itemCO2Savings
-		potentialCostSavings += itemCostSavings
+	// Extract savings
+	if strings.Contains(item.Analysis, "Monthly Savings Potential:") {
+		savingsText := item.Analysis[strings.Index(item.Analysis, "Monthly Savings Potential:"):]
+		if strings.Contains(savingsText, "$") {
+			itemCostSavings = extractNumberAfterPhrase(savingsText, "$")
+		}
 	}
 
+	return itemCO2, itemCost, itemCostSavings
+}
+
+// printSustainabilitySummary prints a summary of CO2 emissions and potential savings
+func printSustainabilitySummary(w io.Writer, report []ReportItem, colorize bool) {
+	// Calculate total CO2 and potential savings
+	summary := SummarizeReport(report)
+	totalCO2 := summary.TotalCO2
+	potentialCO2Savings := summary.PotentialCO2Savings
+	totalCost := summary.TotalCost
+	potentialCostSavings := summary.PotentialCostSavings
+
 	// Print sustainability section header
 	if colorize {
 		fmt.Fprintf(w, "\n\n%s╔══════════════════════════════════════════════════════════════╗%s\n", ColorGreen, ColorReset)
@@ -356,6 +689,104 @@ func printSustainabilitySummary(w io.Writer, report []ReportItem, colorize bool)
 		potentialCostSavings,
 		safePercentage(potentialCostSavings, totalCost))
 	fmt.Fprintf(w, "• Projected annual savings: $%.2f\n", potentialCostSavings*12)
+	if summary.IdleResources > 0 {
+		fmt.Fprintf(w, "• Idle EC2 instances: %d (stopping them saves their full cost)\n", summary.IdleResources)
+	}
+	if len(summary.CostSources) > 0 {
+		fmt.Fprintf(w, "• Pricing source: %s\n", describeCostSources(summary.CostSources))
+	}
+	if !summary.AnalysisUsage.IsZero() {
+		fmt.Fprintf(w, "• Analysis cost: $%.2f (%d prompt and %d completion tokens)\n",
+			summary.AnalysisCostUSD, summary.PromptTokens, summary.CompletionTokens)
+	}
+
+	if summary.ActualCostResources > 0 {
+		printActualCostComparison(w, report, summary, colorize)
+	}
+	if rollups := RollupByAccount(report); len(rollups) > 1 {
+		printAccountRollup(w, rollups, colorize)
+	}
+}
+
+// printActualCostComparison lists the estimated and Cost Explorer cost of every resource that has both
+func printActualCostComparison(w io.Writer, report []ReportItem, summary ReportSummary, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sACTUAL VS ESTIMATED (last 30 days, Cost Explorer)%s\n", ColorBold, ColorReset)
+	} else {
+		fmt.Fprintf(w, "\nACTUAL VS ESTIMATED (last 30 days, Cost Explorer)\n")
+	}
+	fmt.Fprintf(w, "─────────────────────────────────────────────────\n")
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tESTIMATED\tACTUAL\tDIFFERENCE")
+	for _, item := range report {
+		actual := item.ActualMonthlyCost()
+		if actual <= 0 {
+			continue
+		}
+		_, estimated, _ := extractItemMetrics(item)
+		resourceID, _, _, _ := describeItem(item)
+		fmt.Fprintf(tw, "%s\t$%.2f\t$%.2f\t%s\n", resourceID, estimated, actual, formatCostDifference(estimated, actual))
+	}
+	fmt.Fprintf(tw, "TOTAL (%d resources)\t$%.2f\t$%.2f\t%s\n", summary.ActualCostResources,
+		summary.EstimatedCostWithActuals, summary.ActualCost, formatCostDifference(summary.EstimatedCostWithActuals, summary.ActualCost))
+	tw.Flush()
+}
+
+// formatCostDifference shows how far the actual cost is above or below the estimate
+func formatCostDifference(estimated, actual float64) string {
+	if estimated <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (actual-estimated)/estimated*100)
+}
+
+// costSourceLabels names each cost source in the report, in the order they are listed
+var costSourceLabels = []struct {
+	Source string
+	Label  string
+}{
+	{CostSourcePricingAPI, "AWS Pricing API"},
+	{CostSourcePricingTable, "bundled price table"},
+	{CostSourceModel, "model estimate"},
+}
+
+// describeCostSources lists how many resources were priced from each source, e.g.
+// "3 from the AWS Pricing API, 1 from the model estimate"
+func describeCostSources(sources map[string]int) string {
+	var parts []string
+	for _, s := range costSourceLabels {
+		if n := sources[s.Source]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d from the %s", n, s.Label))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatAnalysisReportJSON writes the report items and their summary as a single JSON
+// document, with the metadata of the scan when there is any
+func FormatAnalysisReportJSON(w io.Writer, report []ReportItem, meta *ReportMetadata) error {
+	if report == nil {
+		report = []ReportItem{}
+	}
+
+	output := struct {
+		GeneratedAt time.Time       `json:"generated_at"`
+		Generator   string          `json:"generator"`
+		Metadata    *ReportMetadata `json:"metadata,omitempty"`
+		Summary     ReportSummary   `json:"summary"`
+		Results     []ReportItem    `json:"results"`
+	}{
+		GeneratedAt: time.Now().UTC(),
+		Generator:   ReportGenerator(),
+		Metadata:    meta,
+		Summary:     SummarizeReport(report),
+		Results:     report,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
 }
 
 // Helper function to extract numbers from text
@@ -534,14 +965,93 @@ func printRDSDetailsHeader(w io.Writer, colorize bool) {
 	}
 }
 
+// Print EBS details section header
+func printEBSDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sEBS VOLUME DETAILS%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 18))
+	} else {
+		fmt.Fprintln(w, "\nEBS VOLUME DETAILS")
+		fmt.Fprintln(w, strings.Repeat("=", 18))
+	}
+}
+
+// Print Lambda details section header
+func printLambdaDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sLAMBDA FUNCTION DETAILS%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 23))
+	} else {
+		fmt.Fprintln(w, "\nLAMBDA FUNCTION DETAILS")
+		fmt.Fprintln(w, strings.Repeat("=", 23))
+	}
+}
+
+// Print load balancer details section header
+func printELBDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sLOAD BALANCER DETAILS%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 21))
+	} else {
+		fmt.Fprintln(w, "\nLOAD BALANCER DETAILS")
+		fmt.Fprintln(w, strings.Repeat("=", 21))
+	}
+}
+
+// Print Elastic IP and NAT gateway details section header
+func printNetworkDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sIDLE NETWORK RESOURCES%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 22))
+	} else {
+		fmt.Fprintln(w, "\nIDLE NETWORK RESOURCES")
+		fmt.Fprintln(w, strings.Repeat("=", 22))
+	}
+}
+
+// Print DynamoDB table details section header
+func printDynamoDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sDYNAMODB TABLE DETAILS%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 22))
+	} else {
+		fmt.Fprintln(w, "\nDYNAMODB TABLE DETAILS")
+		fmt.Fprintln(w, strings.Repeat("=", 22))
+	}
+}
+
+// Print ElastiCache cluster details section header
+func printElastiCacheDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sELASTICACHE CLUSTER DETAILS%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 27))
+	} else {
+		fmt.Fprintln(w, "\nELASTICACHE CLUSTER DETAILS")
+		fmt.Fprintln(w, strings.Repeat("=", 27))
+	}
+}
+
+// Print stale snapshot section header
+func printSnapshotDetailsHeader(w io.Writer, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sSNAPSHOTS%s\n", ColorBold+ColorBlue, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("=", 9))
+	} else {
+		fmt.Fprintln(w, "\nSNAPSHOTS")
+		fmt.Fprintln(w, strings.Repeat("=", 9))
+	}
+}
+
 // printEC2Details prints detailed analysis for an EC2 instance with coloring
-func printEC2Details(w io.Writer, index int, item ReportItem, colorize bool) {
+func printEC2Details(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
 	// Section header (already colored in previous step)
 	instanceType := item.Instance.InstanceType
 	if instanceType == "" {
 		instanceType = "unknown"
 	}
 	title := fmt.Sprintf("Instance %d: %s (%s)", index, item.Instance.InstanceID, instanceType)
+	title += rankLabel(item.Rank, opts.SortBy)
 	if colorize {
 		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
 		fmt.Fprintln(w, strings.Repeat("-", len(title)))
@@ -561,10 +1071,28 @@ func printEC2Details(w io.Writer, index int, item ReportItem, colorize bool) {
 	}
 
 	// Instance metadata
+	if item.Instance.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, item.Instance.Region)
+	}
+	if link := itemConsoleURL(item); link != "" {
+		fmt.Fprintf(w, "%sConsole:%s %s\n", labelColor, reset, link)
+	}
 	if !item.Instance.LaunchTime.IsZero() {
 		fmt.Fprintf(w, "%sLaunch Time:%s %s\n", labelColor, reset, item.Instance.LaunchTime.Format(time.RFC3339))
 	}
-	fmt.Fprintf(w, "%sCPU Utilization (7-day avg):%s %.1f%%\n", labelColor, reset, item.Instance.CPUAvg7d)
+	days := EffectivePeriodDays(item.Instance.MetricsPeriodDays)
+	fmt.Fprintf(w, "%sCPU Utilization (%d-day avg):%s %.1f%%\n", labelColor, days, reset, item.Instance.CPUAvg7d)
+	if item.Instance.MemAvg7d > 0 {
+		fmt.Fprintf(w, "%sMemory Utilization (%d-day avg):%s %.1f%%\n", labelColor, days, reset, item.Instance.MemAvg7d)
+	}
+	fmt.Fprintf(w, "%sNetwork In (%d-day avg):%s %s\n", labelColor, days, reset, formatByteRate(item.Instance.NetworkInAvg7d))
+	fmt.Fprintf(w, "%sNetwork Out (%d-day avg):%s %s\n", labelColor, days, reset, formatByteRate(item.Instance.NetworkOutAvg7d))
+	if item.RightsizeSuggestion != nil {
+		fmt.Fprintf(w, "%sSuggested Type:%s %s\n", labelColor, reset, item.RightsizeSuggestion)
+	}
+	if verdict := idleVerdict(item.Instance); verdict != "" {
+		fmt.Fprintf(w, "%sIdle:%s %s\n", labelColor, reset, verdict)
+	}
 
 	// Tags
 	if len(item.Instance.Tags) > 0 {
@@ -582,13 +1110,19 @@ func printEC2Details(w io.Writer, index int, item ReportItem, colorize bool) {
 
 	// Analysis
 	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
-	fmt.Fprintln(w, item.Analysis)                                 // Print analysis content as is
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
 }
 
 // printS3Details prints detailed analysis for an S3 bucket with coloring
-func printS3Details(w io.Writer, index int, item ReportItem, colorize bool) {
+func printS3Details(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
 	// Section header (already colored)
 	title := fmt.Sprintf("Bucket %d: %s", index, item.S3Bucket.BucketName)
+	title += rankLabel(item.Rank, opts.SortBy)
 	if colorize {
 		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
 		fmt.Fprintln(w, strings.Repeat("-", len(title)))
@@ -611,14 +1145,33 @@ func printS3Details(w io.Writer, index int, item ReportItem, colorize bool) {
 	if item.S3Bucket.Region != "" {
 		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, item.S3Bucket.Region)
 	}
+	if link := itemConsoleURL(item); link != "" {
+		fmt.Fprintf(w, "%sConsole:%s %s\n", labelColor, reset, link)
+	}
 	if !item.S3Bucket.CreationDate.IsZero() {
 		fmt.Fprintf(w, "%sCreation Date:%s %s\n", labelColor, reset, item.S3Bucket.CreationDate.Format(time.RFC3339))
 	}
-	fmt.Fprintf(w, "%sSize:%s %.2f GB\n", labelColor, reset, float64(item.S3Bucket.SizeBytes)/(1024*1024*1024))
-	fmt.Fprintf(w, "%sObject Count:%s %d\n", labelColor, reset, item.S3Bucket.ObjectCount)
+	estimated := ""
+	if item.S3Bucket.MetricsSource == S3MetricsSourceSampled {
+		estimated = " (estimated from a sample)"
+	}
+	fmt.Fprintf(w, "%sSize:%s %.2f GB%s\n", labelColor, reset, float64(item.S3Bucket.SizeBytes)/(1024*1024*1024), estimated)
+	fmt.Fprintf(w, "%sObject Count:%s %d%s\n", labelColor, reset, item.S3Bucket.ObjectCount, estimated)
 	if !item.S3Bucket.LastModified.IsZero() {
 		fmt.Fprintf(w, "%sLast Modified:%s %s\n", labelColor, reset, item.S3Bucket.LastModified.Format(time.RFC3339))
 	}
+	if item.S3Bucket.HasPosture() {
+		publicAccess := postureValue(item.S3Bucket.PublicAccess)
+		if colorize && publicAccess == S3PublicAccessPublic {
+			publicAccess = ColorBold + ColorRed + strings.ToUpper(publicAccess) + ColorReset
+		}
+		fmt.Fprintf(w, "%sPublic Access:%s %s\n", labelColor, reset, publicAccess)
+		fmt.Fprintf(w, "%sDefault Encryption:%s %s\n", labelColor, reset, postureValue(item.S3Bucket.EncryptionType))
+		fmt.Fprintf(w, "%sVersioning:%s %s\n", labelColor, reset, item.S3Bucket.Versioning())
+	}
+	if reclaimable := describeReclaimable(item.S3Bucket); reclaimable != "" {
+		fmt.Fprintf(w, "%sReclaimable:%s %s\n", labelColor, reset, reclaimable)
+	}
 
 	// Storage class breakdown
 	if len(item.S3Bucket.StorageClasses) > 0 {
@@ -641,6 +1194,10 @@ func printS3Details(w io.Writer, index int, item ReportItem, colorize bool) {
 		}
 	}
 
+	if item.StoragePlan != nil {
+		printStoragePlan(w, *item.StoragePlan, labelColor, bold, reset)
+	}
+
 	// Access patterns
 	if len(item.S3Bucket.AccessFrequency) > 0 {
 		fmt.Fprintf(w, "\n%sAccess Patterns (daily average):%s\n", bold+labelColor, reset) // Bold and color label
@@ -712,13 +1269,19 @@ func printS3Details(w io.Writer, index int, item ReportItem, colorize bool) {
 
 	// Analysis
 	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
-	fmt.Fprintln(w, item.Analysis)                                 // Print analysis content as is
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
 }
 
 // printRDSDetails prints detailed analysis for an RDS instance with coloring
-func printRDSDetails(w io.Writer, index int, item ReportItem, colorize bool) {
+func printRDSDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
 	// Section header (already colored)
 	title := fmt.Sprintf("RDS Instance %d: %s (%s)", index, item.RDSInstance.InstanceID, item.RDSInstance.InstanceType)
+	title += rankLabel(item.Rank, opts.SortBy)
 	if colorize {
 		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
 		fmt.Fprintln(w, strings.Repeat("-", len(title)))
@@ -738,16 +1301,38 @@ func printRDSDetails(w io.Writer, index int, item ReportItem, colorize bool) {
 	}
 
 	// Instance metadata
+	if item.RDSInstance.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, item.RDSInstance.Region)
+	}
+	if link := itemConsoleURL(item); link != "" {
+		fmt.Fprintf(w, "%sConsole:%s %s\n", labelColor, reset, link)
+	}
 	fmt.Fprintf(w, "%sEngine:%s %s %s\n", labelColor, reset, item.RDSInstance.Engine, item.RDSInstance.EngineVersion)
 	fmt.Fprintf(w, "%sStorage:%s %d GB (%s)\n", labelColor, reset, item.RDSInstance.AllocatedStorage, item.RDSInstance.StorageType)
 	fmt.Fprintf(w, "%sMulti-AZ:%s %t\n", labelColor, reset, item.RDSInstance.MultiAZ)
+	if item.RDSInstance.GravitonSupported {
+		fmt.Fprintf(w, "%sGraviton:%s supported\n", labelColor, reset)
+	}
 	if !item.RDSInstance.LaunchTime.IsZero() {
 		fmt.Fprintf(w, "%sLaunch Time:%s %s\n", labelColor, reset, item.RDSInstance.LaunchTime.Format(time.RFC3339))
 	}
-	fmt.Fprintf(w, "%sCPU Utilization (7-day avg):%s %.1f%%\n", labelColor, reset, item.RDSInstance.CPUAvg7d)
+	days := EffectivePeriodDays(item.RDSInstance.MetricsPeriodDays)
+	fmt.Fprintf(w, "%sCPU Utilization (%d-day avg):%s %.1f%%\n", labelColor, days, reset, item.RDSInstance.CPUAvg7d)
 	fmt.Fprintf(w, "%sStorage Used:%s %.1f%%\n", labelColor, reset, item.RDSInstance.StorageUsed)
-	fmt.Fprintf(w, "%sConnections (7-day avg):%s %.1f\n", labelColor, reset, item.RDSInstance.ConnectionsAvg7d)
-	fmt.Fprintf(w, "%sIOPS (7-day avg):%s %.1f\n", labelColor, reset, item.RDSInstance.IOPSAvg7d)
+	fmt.Fprintf(w, "%sConnections (%d-day avg):%s %.1f\n", labelColor, days, reset, item.RDSInstance.ConnectionsAvg7d)
+	fmt.Fprintf(w, "%sIOPS (%d-day avg):%s %.1f\n", labelColor, days, reset, item.RDSInstance.IOPSAvg7d)
+
+	// Findings of the deterministic checks, before the analysis
+	if len(item.RDSInstance.Findings) > 0 {
+		fmt.Fprintf(w, "\n%sPre-analysis Checklist:%s\n", bold+labelColor, reset)
+		for _, finding := range item.RDSInstance.Findings {
+			if colorize {
+				fmt.Fprintf(w, "  %s[!]%s %s\n", ColorYellow, ColorReset, finding)
+			} else {
+				fmt.Fprintf(w, "  [!] %s\n", finding)
+			}
+		}
+	}
 
 	// Tags
 	if len(item.RDSInstance.Tags) > 0 {
@@ -763,9 +1348,586 @@ func printRDSDetails(w io.Writer, index int, item ReportItem, colorize bool) {
 		}
 	}
 
+	if item.PurchaseOptions != nil {
+		printPurchaseOptions(w, *item.PurchaseOptions, labelColor, bold, reset)
+	}
+
+	// Analysis
+	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printEBSDetails prints detailed analysis for an EBS volume with coloring
+func printEBSDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	// Section header
+	title := fmt.Sprintf("Volume %d: %s (%s, %d GiB)", index, item.EBSVolume.VolumeID, item.EBSVolume.VolumeType, item.EBSVolume.SizeGiB)
+	title += rankLabel(item.Rank, opts.SortBy)
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	}
+
+	// --- Apply coloring to labels ---
+	labelColor := ""
+	reset := ""
+	bold := ""
+	warn := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+		warn = ColorYellow
+	}
+
+	// Volume metadata
+	if item.EBSVolume.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, item.EBSVolume.Region)
+	}
+	fmt.Fprintf(w, "%sState:%s %s\n", labelColor, reset, item.EBSVolume.State)
+	if item.EBSVolume.Attached {
+		fmt.Fprintf(w, "%sAttached To:%s %s\n", labelColor, reset, item.EBSVolume.AttachedInstanceID)
+	} else {
+		fmt.Fprintf(w, "%sAttached To:%s %sunattached%s\n", labelColor, reset, warn, reset)
+	}
+	if item.EBSVolume.IOPS > 0 {
+		fmt.Fprintf(w, "%sProvisioned IOPS:%s %d\n", labelColor, reset, item.EBSVolume.IOPS)
+	}
+	if !item.EBSVolume.CreateTime.IsZero() {
+		fmt.Fprintf(w, "%sCreate Time:%s %s\n", labelColor, reset, item.EBSVolume.CreateTime.Format(time.RFC3339))
+	}
+	days := EffectivePeriodDays(item.EBSVolume.MetricsPeriodDays)
+	fmt.Fprintf(w, "%sRead Ops (%d-day total):%s %.0f\n", labelColor, days, reset, item.EBSVolume.ReadOps7d)
+	fmt.Fprintf(w, "%sWrite Ops (%d-day total):%s %.0f\n", labelColor, days, reset, item.EBSVolume.WriteOps7d)
+	if item.EBSVolume.Idle {
+		fmt.Fprintf(w, "%sIdle:%s %sno I/O in the last %d days%s\n", labelColor, reset, warn, days, reset)
+	}
+
+	// Tags
+	if len(item.EBSVolume.Tags) > 0 {
+		fmt.Fprintf(w, "%sTags:%s\n", bold+labelColor, reset) // Bold and color the label
+		// Sort tags for consistent output
+		keys := make([]string, 0, len(item.EBSVolume.Tags))
+		for k := range item.EBSVolume.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s%s:%s %s\n", labelColor, k, reset, item.EBSVolume.Tags[k]) // Color the key
+		}
+	}
+
 	// Analysis
 	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
-	fmt.Fprintln(w, item.Analysis)                                 // Print analysis content as is
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printLambdaDetails prints detailed analysis for a Lambda function with coloring
+func printLambdaDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	// Section header
+	function := item.LambdaFunction
+	title := fmt.Sprintf("Function %d: %s (%d MB, %s)", index, function.FunctionName, function.MemoryMB, function.Architecture)
+	title += rankLabel(item.Rank, opts.SortBy)
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	}
+
+	// --- Apply coloring to labels ---
+	labelColor := ""
+	reset := ""
+	bold := ""
+	warn := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+		warn = ColorYellow
+	}
+
+	// Function metadata
+	if function.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, function.Region)
+	}
+	if function.Runtime != "" {
+		fmt.Fprintf(w, "%sRuntime:%s %s\n", labelColor, reset, function.Runtime)
+	}
+	fmt.Fprintf(w, "%sTimeout:%s %ds\n", labelColor, reset, function.TimeoutSec)
+	if !function.LastModified.IsZero() {
+		fmt.Fprintf(w, "%sLast Modified:%s %s\n", labelColor, reset, function.LastModified.Format(time.RFC3339))
+	}
+	days := EffectivePeriodDays(function.MetricsPeriodDays)
+	fmt.Fprintf(w, "%sInvocations (%d-day total):%s %.0f\n", labelColor, days, reset, function.Invocations7d)
+	fmt.Fprintf(w, "%sErrors (%d-day total):%s %.0f\n", labelColor, days, reset, function.Errors7d)
+	fmt.Fprintf(w, "%sDuration (avg / p95):%s %.1f ms / %.1f ms\n", labelColor, reset, function.DurationAvgMs, function.DurationP95Ms)
+	fmt.Fprintf(w, "%sCompute (%d-day total):%s %.1f GB-seconds\n", labelColor, days, reset, function.GBSeconds7d)
+	if function.Idle {
+		fmt.Fprintf(w, "%sIdle:%s %sno invocations in the last %d days%s\n", labelColor, reset, warn, days, reset)
+	}
+
+	// Tags
+	if len(function.Tags) > 0 {
+		fmt.Fprintf(w, "%sTags:%s\n", bold+labelColor, reset) // Bold and color the label
+		// Sort tags for consistent output
+		keys := make([]string, 0, len(function.Tags))
+		for k := range function.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s%s:%s %s\n", labelColor, k, reset, function.Tags[k]) // Color the key
+		}
+	}
+
+	// Analysis
+	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printELBDetails prints detailed analysis for a load balancer with coloring
+func printELBDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	// Section header
+	lb := item.LoadBalancer
+	title := fmt.Sprintf("Load Balancer %d: %s (%s, %s)", index, lb.Name, lb.Type, lb.Scheme)
+	title += rankLabel(item.Rank, opts.SortBy)
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	}
+
+	// --- Apply coloring to labels ---
+	labelColor := ""
+	reset := ""
+	bold := ""
+	warn := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+		warn = ColorYellow
+	}
+
+	// Load balancer metadata
+	if lb.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, lb.Region)
+	}
+	fmt.Fprintf(w, "%sState:%s %s\n", labelColor, reset, lb.State)
+	if !lb.CreatedTime.IsZero() {
+		fmt.Fprintf(w, "%sCreated Time:%s %s\n", labelColor, reset, lb.CreatedTime.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "%sTarget Groups:%s %d\n", labelColor, reset, lb.TargetGroupCount)
+	days := EffectivePeriodDays(lb.MetricsPeriodDays)
+	if lb.Type == "application" {
+		fmt.Fprintf(w, "%sRequests (%d-day total):%s %.0f\n", labelColor, days, reset, lb.RequestCount7d)
+	} else {
+		fmt.Fprintf(w, "%sActive Flows (%d-day avg):%s %.1f\n", labelColor, days, reset, lb.ActiveFlowCountAvg)
+	}
+	fmt.Fprintf(w, "%sHealthy Targets (%d-day avg):%s %.1f\n", labelColor, days, reset, lb.HealthyHostCountAvg)
+	if lb.Idle {
+		fmt.Fprintf(w, "%sIdle:%s %sno traffic in the last %d days%s\n", labelColor, reset, warn, days, reset)
+	}
+	if lb.HealthyHostCountAvg == 0 {
+		fmt.Fprintf(w, "%sTargets:%s %sno healthy targets%s\n", labelColor, reset, warn, reset)
+	}
+
+	// Tags
+	if len(lb.Tags) > 0 {
+		fmt.Fprintf(w, "%sTags:%s\n", bold+labelColor, reset) // Bold and color the label
+		// Sort tags for consistent output
+		keys := make([]string, 0, len(lb.Tags))
+		for k := range lb.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s%s:%s %s\n", labelColor, k, reset, lb.Tags[k]) // Color the key
+		}
+	}
+
+	// Analysis
+	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printNetworkDetails prints detailed analysis for an Elastic IP or NAT gateway with coloring
+func printNetworkDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	// Section header
+	resource := item.NetworkResource
+	title := fmt.Sprintf("Resource %d: %s (%s)", index, resource.ResourceID, resource.Type)
+	title += rankLabel(item.Rank, opts.SortBy)
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	}
+
+	// --- Apply coloring to labels ---
+	labelColor := ""
+	reset := ""
+	bold := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+	}
+
+	// Resource metadata
+	if resource.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, resource.Region)
+	}
+	if resource.PublicIP != "" {
+		fmt.Fprintf(w, "%sPublic IP:%s %s\n", labelColor, reset, resource.PublicIP)
+	}
+	if resource.VPCID != "" {
+		fmt.Fprintf(w, "%sVPC:%s %s\n", labelColor, reset, resource.VPCID)
+	}
+	if !resource.CreateTime.IsZero() {
+		fmt.Fprintf(w, "%sAge:%s %.0f days\n", labelColor, reset, resource.AgeDays)
+	}
+	if resource.Type == NetworkTypeNATGateway {
+		days := EffectivePeriodDays(resource.MetricsPeriodDays)
+		fmt.Fprintf(w, "%sBytes Out (%d-day total):%s %.0f\n", labelColor, days, reset, resource.BytesOutToDestination7d)
+	}
+
+	// Tags
+	if len(resource.Tags) > 0 {
+		fmt.Fprintf(w, "%sTags:%s\n", bold+labelColor, reset) // Bold and color the label
+		// Sort tags for consistent output
+		keys := make([]string, 0, len(resource.Tags))
+		for k := range resource.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s%s:%s %s\n", labelColor, k, reset, resource.Tags[k]) // Color the key
+		}
+	}
+
+	// Analysis
+	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printDynamoDetails prints detailed analysis for a DynamoDB table with coloring
+func printDynamoDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	// Section header
+	table := item.DynamoTable
+	title := fmt.Sprintf("Table %d: %s (%s)", index, table.TableName, table.BillingMode)
+	title += rankLabel(item.Rank, opts.SortBy)
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	}
+
+	// --- Apply coloring to labels ---
+	labelColor := ""
+	reset := ""
+	bold := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+	}
+
+	// Table metadata
+	if table.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, table.Region)
+	}
+	fmt.Fprintf(w, "%sTable Class:%s %s\n", labelColor, reset, table.TableClass)
+	fmt.Fprintf(w, "%sSize:%s %.2f GB (%d items)\n", labelColor, reset, float64(table.SizeBytes)/(1024*1024*1024), table.ItemCount)
+	fmt.Fprintf(w, "%sGlobal Secondary Indexes:%s %d\n", labelColor, reset, table.GSICount)
+	days := EffectivePeriodDays(table.MetricsPeriodDays)
+	if table.BillingMode == "PROVISIONED" {
+		fmt.Fprintf(w, "%sProvisioned:%s %d RCU / %d WCU\n", labelColor, reset, table.ProvisionedRCU, table.ProvisionedWCU)
+		fmt.Fprintf(w, "%sRead Utilization (%d-day avg):%s %.1f%%\n", labelColor, days, reset, table.ReadUtilizationPct)
+		fmt.Fprintf(w, "%sWrite Utilization (%d-day avg):%s %.1f%%\n", labelColor, days, reset, table.WriteUtilizationPct)
+	}
+	fmt.Fprintf(w, "%sConsumed RCU (%d-day total):%s %.0f\n", labelColor, days, reset, table.ConsumedRCU7d)
+	fmt.Fprintf(w, "%sConsumed WCU (%d-day total):%s %.0f\n", labelColor, days, reset, table.ConsumedWCU7d)
+
+	// Tags
+	if len(table.Tags) > 0 {
+		fmt.Fprintf(w, "%sTags:%s\n", bold+labelColor, reset) // Bold and color the label
+		// Sort tags for consistent output
+		keys := make([]string, 0, len(table.Tags))
+		for k := range table.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s%s:%s %s\n", labelColor, k, reset, table.Tags[k]) // Color the key
+		}
+	}
+
+	// Analysis
+	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printElastiCacheDetails prints detailed analysis for an ElastiCache cluster with coloring
+func printElastiCacheDetails(w io.Writer, index int, item ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	// Section header
+	cluster := item.ElastiCache
+	title := fmt.Sprintf("Cluster %d: %s (%s, %d x %s)", index, cluster.ClusterID, cluster.Engine, cluster.NodeCount, cluster.NodeType)
+	title += rankLabel(item.Rank, opts.SortBy)
+	if colorize {
+		fmt.Fprintf(w, "\n%s%s%s\n", ColorBold+ColorBlue, title, ColorReset)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	} else {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprintln(w, strings.Repeat("-", len(title)))
+	}
+
+	// --- Apply coloring to labels ---
+	labelColor := ""
+	reset := ""
+	bold := ""
+	warn := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+		warn = ColorYellow
+	}
+
+	// Cluster metadata
+	if cluster.Region != "" {
+		fmt.Fprintf(w, "%sRegion:%s %s\n", labelColor, reset, cluster.Region)
+	}
+	fmt.Fprintf(w, "%sEngine Version:%s %s\n", labelColor, reset, cluster.EngineVersion)
+	if cluster.ReplicationGroupID != "" {
+		fmt.Fprintf(w, "%sReplication Group:%s %s\n", labelColor, reset, cluster.ReplicationGroupID)
+	}
+	fmt.Fprintf(w, "%sMulti-AZ:%s %t\n", labelColor, reset, cluster.MultiAZ)
+	days := EffectivePeriodDays(cluster.MetricsPeriodDays)
+	fmt.Fprintf(w, "%sCPU Utilization (%d-day avg):%s %.2f%%\n", labelColor, days, reset, cluster.CPUAvg7d)
+	if cluster.Engine != "memcached" {
+		fmt.Fprintf(w, "%sMemory Usage (%d-day avg):%s %.2f%%\n", labelColor, days, reset, cluster.MemoryUsageAvg7d)
+	}
+	fmt.Fprintf(w, "%sConnections (%d-day avg):%s %.1f (max %.0f)\n", labelColor, days, reset, cluster.ConnectionsAvg7d, cluster.ConnectionsMax7d)
+	if cluster.Idle {
+		fmt.Fprintf(w, "%sIdle:%s %salmost no connections in the last %d days%s\n", labelColor, reset, warn, days, reset)
+	}
+
+	// Tags
+	if len(cluster.Tags) > 0 {
+		fmt.Fprintf(w, "%sTags:%s\n", bold+labelColor, reset) // Bold and color the label
+		// Sort tags for consistent output
+		keys := make([]string, 0, len(cluster.Tags))
+		for k := range cluster.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s%s:%s %s\n", labelColor, k, reset, cluster.Tags[k]) // Color the key
+		}
+	}
+
+	// Analysis
+	fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+	printItemAnalysis(w, item, opts)
+
+	if opts.Verbosity == VerbosityDetailed {
+		printItemDiagnostics(w, item, opts)
+	}
+}
+
+// printSnapshotDetails prints the reclaimable snapshot totals, a table of the largest
+// snapshots and the summary analysis
+func printSnapshotDetails(w io.Writer, items []ReportItem, opts ReportOptions) {
+	colorize := opts.Colorize
+	labelColor := ""
+	reset := ""
+	bold := ""
+	if colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+	}
+
+	// A report normally holds one snapshot item, but merged reports may hold several
+	var snapshots []EBSSnapshot
+	for _, item := range items {
+		snapshots = append(snapshots, item.Snapshots...)
+	}
+	summary := SummarizeSnapshots(snapshots)
+
+	fmt.Fprintf(w, "%sStale snapshots:%s %d (older than %d days, source volume deleted, no AMI)\n", labelColor, reset, summary.Count, summary.StaleAfterDays)
+	fmt.Fprintf(w, "%sReclaimable storage:%s %d GiB\n", labelColor, reset, summary.TotalGiB)
+	fmt.Fprintf(w, "%sMonthly cost:%s $%.2f\n", labelColor, reset, summary.MonthlyCost)
+	fmt.Fprintf(w, "%sCO2 footprint:%s %.2f kg CO2 per month\n", labelColor, reset, summary.CO2KgMonthly)
+
+	// Top offenders
+	top := TopSnapshots(snapshots, snapshotPromptLimit)
+	fmt.Fprintf(w, "\n%sLargest %d snapshots:%s\n", bold+labelColor, len(top), reset)
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "SNAPSHOT\tREGION\tSIZE\tAGE\tSOURCE VOLUME\tDESCRIPTION")
+	for _, s := range top {
+		fmt.Fprintf(tw, "%s\t%s\t%d GiB\t%.0f days\t%s\t%s\n", s.SnapshotID, s.Region, s.SizeGiB, s.AgeDays, s.VolumeID, truncateText(s.Description, 40))
+	}
+	tw.Flush()
+
+	// Analysis
+	for _, item := range items {
+		fmt.Fprintf(w, "\n%sAI ANALYSIS:%s\n", bold+labelColor, reset) // Bold and color the label
+		printItemAnalysis(w, item, opts)
+
+		if opts.Verbosity == VerbosityDetailed {
+			printItemDiagnostics(w, item, opts)
+		}
+	}
+}
+
+// printItemAnalysis prints an item's analysis: a structured analysis as the same bulleted
+// sections for every item, a markdown one as the model wrote it. Incomplete analyses are
+// flagged first, since their figures may be missing or wrong.
+func printItemAnalysis(w io.Writer, item ReportItem, opts ReportOptions) {
+	labelColor := ""
+	warnColor := ""
+	reset := ""
+	if opts.Colorize {
+		labelColor = ColorCyan
+		warnColor = ColorYellow
+		reset = ColorReset
+	}
+
+	if quality := item.AnalysisQuality; quality.IsLow() {
+		fmt.Fprintf(w, "%s⚠ Incomplete analysis (completeness %d/100, missing %s); treat its figures with caution%s\n",
+			warnColor, quality.Score, strings.Join(quality.Missing, ", "), reset)
+	}
+
+	structured := item.StructuredAnalysis
+	if structured == nil {
+		fmt.Fprintln(w, item.Analysis) // Print analysis content as is
+		return
+	}
+
+	printList := func(label string, entries []string) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s%s:%s\n", labelColor, label, reset)
+		for _, entry := range entries {
+			fmt.Fprintf(w, "  • %s\n", entry)
+		}
+	}
+	printList("Findings", structured.Findings)
+	printList("Recommendations", structured.Recommendations)
+	metrics := structured.Metrics()
+	fmt.Fprintf(w, "%sCost:%s $%.2f per month, $%.2f optimized, saving $%.2f (%.1f%%)\n",
+		labelColor, reset, metrics.MonthlyCost, metrics.OptimizedCost, metrics.MonthlySavings, metrics.SavingsPct)
+	fmt.Fprintf(w, "%sCO2 footprint:%s %.2f kg CO2 per month\n", labelColor, reset, metrics.CO2KgMonthly)
+	printList("Security notes", structured.SecurityNotes)
+	printList("Sustainability tips", structured.SustainabilityTips)
+}
+
+// printItemDiagnostics prints the inputs behind an item's summary figures: the metric
+// breakdown of the estimate, the embedding size and the values read from the analysis text
+func printItemDiagnostics(w io.Writer, item ReportItem, opts ReportOptions) {
+	labelColor := ""
+	reset := ""
+	bold := ""
+	if opts.Colorize {
+		labelColor = ColorCyan
+		reset = ColorReset
+		bold = ColorBold
+	}
+
+	fmt.Fprintf(w, "\n%sDIAGNOSTICS:%s\n", bold+labelColor, reset)
+	switch item.GetResourceType() {
+	case ResourceTypeEC2:
+		_, breakdown := EstimateEC2CO2(item.Instance.InstanceType, item.Instance.Region, item.Instance.CPUAvg7d)
+		fmt.Fprintf(w, "%sCarbon breakdown:%s %s\n", labelColor, reset, breakdown)
+		if item.Instance.HourlyPrice > 0 {
+			fmt.Fprintf(w, "%sHourly price:%s $%.4f (%s)\n", labelColor, reset, item.Instance.HourlyPrice, item.Instance.PriceSource)
+		}
+	case ResourceTypeRDS:
+		if item.RDSInstance.HourlyPrice > 0 {
+			fmt.Fprintf(w, "%sHourly price:%s $%.4f (%s)\n", labelColor, reset, item.RDSInstance.HourlyPrice, item.RDSInstance.PriceSource)
+		}
+	}
+	if cost, source, ok := item.listPriceMonthlyCost(); ok {
+		fmt.Fprintf(w, "%sList price:%s $%.2f per month (%s)\n", labelColor, reset, cost, source)
+	}
+	if actual := item.ActualMonthlyCost(); actual > 0 {
+		fmt.Fprintf(w, "%sActual spend (Cost Explorer):%s $%.2f\n", labelColor, reset, actual)
+	}
+	fmt.Fprintf(w, "%sEmbedding:%s %d dimensions\n", labelColor, reset, len(item.Embedding))
+	if item.ModelID != "" {
+		fmt.Fprintf(w, "%sAnalyzed by:%s %s\n", labelColor, reset, item.ModelID)
+	}
+	if quality := item.AnalysisQuality; quality != nil {
+		fmt.Fprintf(w, "%sCompleteness:%s %d/100", labelColor, reset, quality.Score)
+		if len(quality.Missing) > 0 {
+			fmt.Fprintf(w, " (missing %s)", strings.Join(quality.Missing, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+
+	// What the summary used, and what the analysis text itself says
+	source := "analysis text"
+	if item.StructuredAnalysis != nil {
+		source = "JSON analysis"
+	} else if item.HasStructuredMetrics() {
+		source = "structured fields"
+	}
+	co2, cost, savings := extractItemMetrics(item)
+	fmt.Fprintf(w, "%sSummary inputs (%s):%s CO2 %.2f kg, cost $%.2f, savings $%.2f, cost source %q\n",
+		labelColor, source, reset, co2, cost, savings, item.CostSource)
+	raw := ExtractAnalysisMetrics(item.Analysis)
+	fmt.Fprintf(w, "%sExtracted from analysis:%s CO2 %.2f kg, cost $%.2f, optimized $%.2f, savings $%.2f (%.1f%%)\n",
+		labelColor, reset, raw.CO2KgMonthly, raw.MonthlyCost, raw.OptimizedCost, raw.MonthlySavings, raw.SavingsPct)
+}
+
+// truncateText shortens s to at most n runes, marking the cut with "..."
+func truncateText(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-3]) + "..."
+	}
+	return s
 }
 
 // // getEfficiencyStatus returns a status based on CPU utilization
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, rewriting the file instead with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s; run go test -update if the change is intended\ngot:\n%s", path, got)
+	}
+}
+
+// costImpact is the Cost & Environmental Impact section of an analysis
+func costImpact(co2, cost, optimized, savings, pct string) string {
+	return "## " + costImpactSectionName + "\n" +
+		"- CO2 Footprint: " + co2 + " kg CO2e/month\n" +
+		"- Estimated Monthly Cost: $" + cost + "\n" +
+		"- Potential Optimized Cost: $" + optimized + "\n" +
+		"- Monthly Savings Potential: $" + savings + " (" + pct + "%)\n"
+}
+
+// sampleReport is a report of one resource of each common type with fixed figures
+func sampleReport() []ReportItem {
+	report := []ReportItem{
+		{
+			ResourceType: ResourceTypeEC2,
+			Instance:     Instance{InstanceID: "i-0abc", InstanceType: "m5.xlarge", Region: "eu-west-1", CPUAvg7d: 3.2},
+			Analysis:     "Downsize to m5.large.\n" + costImpact("0.68", "140.16", "70.08", "70.08", "50"),
+		},
+		{
+			ResourceType: ResourceTypeS3,
+			S3Bucket:     S3Bucket{BucketName: "logs", Region: "eu-west-1", SizeBytes: 500 << 30},
+			Analysis:     "Add a lifecycle rule.\n" + costImpact("1.20", "11.50", "5.75", "5.75", "50"),
+		},
+		{
+			ResourceType: ResourceTypeRDS,
+			RDSInstance:  RDSInstance{InstanceID: "db-1", InstanceType: "db.m5.large", Engine: "postgres", AllocatedStorage: 100, Region: "eu-west-1", CPUAvg7d: 40},
+			Analysis:     "Looks fine.\n" + costImpact("2.50", "150.00", "150.00", "0.00", "0"),
+		},
+	}
+	for i := range report {
+		report[i].ApplyAnalysisMetrics()
+	}
+	return report
+}
+
+// Quiet output has no timestamps, so it is compared byte for byte
+func TestFormatAnalysisReportQuiet(t *testing.T) {
+	for _, colorize := range []bool{false, true} {
+		name := "quiet_report.golden"
+		if colorize {
+			name = "quiet_report_color.golden"
+		}
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			FormatAnalysisReport(&buf, sampleReport(), ReportOptions{Verbosity: VerbosityQuiet, Colorize: colorize})
+			checkGolden(t, name, buf.Bytes())
+		})
+	}
+}
+
+func TestFormatAnalysisReportVerbosity(t *testing.T) {
+	render := func(verbosity string) string {
+		var buf bytes.Buffer
+		FormatAnalysisReport(&buf, sampleReport(), ReportOptions{Verbosity: verbosity})
+		return buf.String()
+	}
+	quiet, normal, detailed := render(VerbosityQuiet), render(VerbosityNormal), render(VerbosityDetailed)
+
+	if strings.Contains(quiet, "Downsize to m5.large") {
+		t.Error("quiet output includes the analysis text")
+	}
+	if !strings.Contains(normal, "Downsize to m5.large") {
+		t.Error("normal output leaves out the analysis text")
+	}
+	if render("") != normal {
+		t.Error("empty verbosity differs from normal")
+	}
+	if len(detailed) <= len(normal) || len(normal) <= len(quiet) {
+		t.Errorf("output lengths quiet %d, normal %d, detailed %d should grow with verbosity", len(quiet), len(normal), len(detailed))
+	}
+}
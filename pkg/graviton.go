@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// gravitonFamilies maps x86 EC2 instance families and RDS instance class families to the
+// Graviton family of the same class that they can move to
+var gravitonFamilies = map[string]string{
+	"t2":  "t4g",
+	"t3":  "t4g",
+	"t3a": "t4g",
+	"m5":  "m6g",
+	"m5a": "m6g",
+	"m6i": "m7g",
+	"m6a": "m7g",
+	"m7i": "m7g",
+	"c5":  "c6g",
+	"c5a": "c6g",
+	"c6i": "c7g",
+	"c6a": "c7g",
+	"c7i": "c7g",
+	"r5":  "r6g",
+	"r5a": "r6g",
+	"r6i": "r7g",
+	"r6a": "r7g",
+	"r7i": "r7g",
+
+	"db.t3":  "db.t4g",
+	"db.m5":  "db.m6g",
+	"db.m6i": "db.m6g",
+	"db.r5":  "db.r6g",
+	"db.r6i": "db.r6g",
+}
+
+// gravitonRDSEngines are the RDS engines that run on Graviton instance classes
+var gravitonRDSEngines = map[string]bool{
+	"mysql":             true,
+	"mariadb":           true,
+	"postgres":          true,
+	"aurora-mysql":      true,
+	"aurora-postgresql": true,
+}
+
+// GravitonEquivalent returns the Graviton instance type, or RDS instance class, of the same
+// size as an x86 one. ok is false for Graviton types, families without an equivalent and
+// sizes the Graviton family does not offer.
+func GravitonEquivalent(instanceType string) (string, bool) {
+	prefix := ""
+	if class, ok := strings.CutPrefix(strings.ToLower(instanceType), "db."); ok {
+		prefix, instanceType = "db.", class
+	}
+	family, size := splitInstanceType(instanceType)
+	graviton, ok := gravitonFamilies[prefix+family]
+	if !ok || size == "" {
+		return "", false
+	}
+	if catalog, known := ec2Families[strings.TrimPrefix(graviton, prefix)]; known && catalog.size(size) == -1 {
+		return "", false
+	}
+	return graviton + "." + size, true
+}
+
+// rdsSupportsGraviton reports whether an RDS database can run on Graviton: its engine runs on
+// Graviton classes and its class is one, or has an equivalent of the same size
+func rdsSupportsGraviton(instanceClass, engine string) bool {
+	if !gravitonRDSEngines[strings.ToLower(engine)] {
+		return false
+	}
+	if _, ok := GravitonEquivalent(instanceClass); ok {
+		return true
+	}
+	family, _ := splitInstanceType(strings.TrimPrefix(strings.ToLower(instanceClass), "db."))
+	for _, graviton := range gravitonFamilies {
+		if strings.TrimPrefix(graviton, "db.") == family {
+			return true
+		}
+	}
+	return false
+}
+
+// GravitonMigration is an x86 EC2 instance or RDS database with a Graviton equivalent of the
+// same size. Costs are on-demand prices for a month from the bundled pricing table, zero when
+// it does not cover both types; CO2KgSaved is the difference in monthly footprint at the
+// resource's current CPU utilization.
+type GravitonMigration struct {
+	ResourceType        ResourceType
+	ResourceID          string
+	Region              string
+	CurrentType         string
+	GravitonType        string
+	CurrentMonthlyCost  float64
+	GravitonMonthlyCost float64
+	MonthlySavings      float64
+	CO2KgSaved          float64
+}
+
+// Priced reports whether the pricing table covers both types
+func (m GravitonMigration) Priced() bool {
+	return m.CurrentMonthlyCost > 0 && m.GravitonMonthlyCost > 0
+}
+
+// String describes the migration in one line, e.g. "m5.large → m6g.large, $13.68/mo and 1.20 kg CO2/mo saved"
+func (m GravitonMigration) String() string {
+	if !m.Priced() {
+		return fmt.Sprintf("%s → %s, %.2f kg CO2/mo saved", m.CurrentType, m.GravitonType, m.CO2KgSaved)
+	}
+	return fmt.Sprintf("%s → %s, $%.2f/mo and %.2f kg CO2/mo saved", m.CurrentType, m.GravitonType, m.MonthlySavings, m.CO2KgSaved)
+}
+
+// EC2GravitonMigration returns the Graviton migration of an x86 EC2 instance
+func EC2GravitonMigration(instance Instance) (GravitonMigration, bool) {
+	graviton, ok := GravitonEquivalent(instance.InstanceType)
+	if !ok {
+		return GravitonMigration{}, false
+	}
+	migration := GravitonMigration{
+		ResourceType: ResourceTypeEC2,
+		ResourceID:   instance.InstanceID,
+		Region:       instance.Region,
+		CurrentType:  instance.InstanceType,
+		GravitonType: graviton,
+	}
+	if current, ok := LookupEC2Price(instance.InstanceType, instance.Region); ok {
+		if price, ok := LookupEC2Price(graviton, instance.Region); ok {
+			migration.CurrentMonthlyCost = current * hoursPerMonth
+			migration.GravitonMonthlyCost = price * hoursPerMonth
+		}
+	}
+	currentCO2, _ := EstimateEC2CO2(instance.InstanceType, instance.Region, instance.CPUAvg7d)
+	gravitonCO2, _ := EstimateEC2CO2(graviton, instance.Region, instance.CPUAvg7d)
+	migration.CO2KgSaved = currentCO2 - gravitonCO2
+	return migration.settle()
+}
+
+// RDSGravitonMigration returns the Graviton migration of an x86 RDS database whose engine
+// runs on Graviton. Multi-AZ deployments migrate their standby too, doubling both deltas.
+func RDSGravitonMigration(instance RDSInstance) (GravitonMigration, bool) {
+	if !gravitonRDSEngines[strings.ToLower(instance.Engine)] {
+		return GravitonMigration{}, false
+	}
+	graviton, ok := GravitonEquivalent(instance.InstanceType)
+	if !ok {
+		return GravitonMigration{}, false
+	}
+	migration := GravitonMigration{
+		ResourceType: ResourceTypeRDS,
+		ResourceID:   instance.InstanceID,
+		Region:       instance.Region,
+		CurrentType:  instance.InstanceType,
+		GravitonType: graviton,
+	}
+	deployments := 1.0
+	if instance.MultiAZ {
+		deployments = 2
+	}
+	if current, ok := LookupRDSPrice(instance.InstanceType, instance.Engine, instance.Region); ok {
+		if price, ok := LookupRDSPrice(graviton, instance.Engine, instance.Region); ok {
+			migration.CurrentMonthlyCost = current * hoursPerMonth * deployments
+			migration.GravitonMonthlyCost = price * hoursPerMonth * deployments
+		}
+	}
+
+	// Database instances draw like the EC2 instances of the same class
+	currentCO2, _ := EstimateEC2CO2(strings.TrimPrefix(instance.InstanceType, "db."), instance.Region, instance.CPUAvg7d)
+	gravitonCO2, _ := EstimateEC2CO2(strings.TrimPrefix(graviton, "db."), instance.Region, instance.CPUAvg7d)
+	migration.CO2KgSaved = (currentCO2 - gravitonCO2) * deployments
+	return migration.settle()
+}
+
+// settle computes the savings of a migration and drops it when the Graviton type is known
+// to cost at least as much
+func (m GravitonMigration) settle() (GravitonMigration, bool) {
+	m.CO2KgSaved = max(m.CO2KgSaved, 0)
+	if !m.Priced() {
+		return m, true
+	}
+	m.MonthlySavings = m.CurrentMonthlyCost - m.GravitonMonthlyCost
+	return m, m.MonthlySavings > 0
+}
+
+// GravitonMigrations lists the EC2 instances and RDS databases of a report that could move to
+// Graviton, largest savings first
+func GravitonMigrations(report []ReportItem) []GravitonMigration {
+	var migrations []GravitonMigration
+	for _, item := range report {
+		var migration GravitonMigration
+		var ok bool
+		switch item.GetResourceType() {
+		case ResourceTypeEC2:
+			migration, ok = EC2GravitonMigration(item.Instance)
+		case ResourceTypeRDS:
+			migration, ok = RDSGravitonMigration(item.RDSInstance)
+		}
+		if ok {
+			migrations = append(migrations, migration)
+		}
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		if migrations[i].MonthlySavings != migrations[j].MonthlySavings {
+			return migrations[i].MonthlySavings > migrations[j].MonthlySavings
+		}
+		return migrations[i].CO2KgSaved > migrations[j].CO2KgSaved
+	})
+	return migrations
+}
+
+// totalGravitonSavings sums the monthly cost and CO2 savings of migrations
+func totalGravitonSavings(migrations []GravitonMigration) (cost, co2 float64) {
+	for _, migration := range migrations {
+		cost += migration.MonthlySavings
+		co2 += migration.CO2KgSaved
+	}
+	return cost, co2
+}
+
+// formatGravitonSavings formats the cost savings of a migration, "-" when it is not priced
+func formatGravitonSavings(migration GravitonMigration) string {
+	if !migration.Priced() {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", migration.MonthlySavings)
+}
+
+// printGravitonMigrations prints the ARM migration opportunities of a report, if it has any
+func printGravitonMigrations(w io.Writer, report []ReportItem, colorize bool) {
+	migrations := GravitonMigrations(report)
+	if len(migrations) == 0 {
+		return
+	}
+
+	if colorize {
+		fmt.Fprintf(w, "\n%sARM MIGRATION OPPORTUNITIES%s\n", ColorBold, ColorReset)
+	} else {
+		fmt.Fprintf(w, "\nARM MIGRATION OPPORTUNITIES\n")
+	}
+	fmt.Fprintf(w, "───────────────────────────\n")
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tRESOURCE\tCURRENT\tGRAVITON\tSAVINGS ($/mo)\tCO2 SAVED (kg/mo)")
+	for _, migration := range migrations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.2f\n",
+			migration.ResourceType, migration.ResourceID, migration.CurrentType, migration.GravitonType,
+			formatGravitonSavings(migration), migration.CO2KgSaved)
+	}
+	tw.Flush()
+
+	cost, co2 := totalGravitonSavings(migrations)
+	fmt.Fprintf(w, "• Moving these %d resources to Graviton would save $%.2f and %.2f kg CO2e per month\n", len(migrations), cost, co2)
+}
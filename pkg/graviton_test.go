@@ -0,0 +1,217 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGravitonEquivalent(t *testing.T) {
+	tests := []struct {
+		instanceType string
+		want         string // "" when there is no equivalent
+	}{
+		{instanceType: "m5.large", want: "m6g.large"},
+		{instanceType: "m6i.4xlarge", want: "m7g.4xlarge"},
+		{instanceType: "c5.xlarge", want: "c6g.xlarge"},
+		{instanceType: "r5a.2xlarge", want: "r6g.2xlarge"},
+		{instanceType: "t2.nano", want: "t4g.nano"},
+		{instanceType: "t3a.medium", want: "t4g.medium"},
+		{instanceType: "M5.XLARGE", want: "m6g.xlarge"},
+		{instanceType: "db.m5.large", want: "db.m6g.large"},
+		{instanceType: "db.r6i.xlarge", want: "db.r6g.xlarge"},
+		{instanceType: "DB.T3.MEDIUM", want: "db.t4g.medium"},
+		{instanceType: "m5.24xlarge"},   // m6g stops at 16xlarge
+		{instanceType: "c5.9xlarge"},    // c6g has no 9xlarge
+		{instanceType: "m6g.large"},     // already Graviton
+		{instanceType: "db.r6g.large"},  // already Graviton
+		{instanceType: "db.m4.large"},   // no equivalent
+		{instanceType: "x2iedn.xlarge"}, // no equivalent
+		{instanceType: "m5"},
+		{instanceType: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instanceType, func(t *testing.T) {
+			got, ok := GravitonEquivalent(tt.instanceType)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("GravitonEquivalent(%q) = %q, %v, want %q", tt.instanceType, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// Every family of the map is x86 and moves to a Graviton family of the same class, and the
+// EC2 families the rightsizing catalog knows agree with it
+func TestGravitonFamilies(t *testing.T) {
+	for family, graviton := range gravitonFamilies {
+		x86 := strings.TrimPrefix(family, "db.")
+		arm := strings.TrimPrefix(graviton, "db.")
+		if strings.HasPrefix(family, "db.") != strings.HasPrefix(graviton, "db.") {
+			t.Errorf("%s maps to %s across EC2 and RDS", family, graviton)
+		}
+		if x86[0] != arm[0] {
+			t.Errorf("%s maps to %s of another instance class", family, graviton)
+		}
+		if !strings.HasSuffix(arm, "g") || strings.HasSuffix(x86, "g") {
+			t.Errorf("%s maps to %s, want an x86 family mapped to a Graviton one", family, graviton)
+		}
+		if _, ok := gravitonFamilies[graviton]; ok {
+			t.Errorf("%s maps to %s, which is mapped again", family, graviton)
+		}
+		if !strings.HasPrefix(family, "db.") {
+			if _, ok := ec2Families[graviton]; !ok {
+				t.Errorf("%s maps to %s, which the rightsizing catalog does not know", family, graviton)
+			}
+		}
+	}
+}
+
+func TestRDSSupportsGraviton(t *testing.T) {
+	tests := []struct {
+		class  string
+		engine string
+		want   bool
+	}{
+		{class: "db.m5.large", engine: "postgres", want: true},
+		{class: "db.m5.large", engine: "Aurora-MySQL", want: true},
+		{class: "db.r6g.large", engine: "mariadb", want: true},
+		{class: "db.m5.large", engine: "oracle-ee"},
+		{class: "db.m5.large", engine: "sqlserver-se"},
+		{class: "db.m4.large", engine: "mysql"},
+		{class: "db.x2g.large", engine: "mysql"},
+	}
+
+	for _, tt := range tests {
+		if got := rdsSupportsGraviton(tt.class, tt.engine); got != tt.want {
+			t.Errorf("rdsSupportsGraviton(%q, %q) = %v, want %v", tt.class, tt.engine, got, tt.want)
+		}
+	}
+}
+
+func TestEC2GravitonMigration(t *testing.T) {
+	tests := []struct {
+		name        string
+		instance    Instance
+		wantOK      bool
+		wantType    string
+		wantSavings float64
+	}{
+		{
+			name:        "priced",
+			instance:    Instance{InstanceID: "i-1", InstanceType: "m5.xlarge", Region: "us-east-1", CPUAvg7d: 40},
+			wantOK:      true,
+			wantType:    "m6g.xlarge",
+			wantSavings: (0.192 - 0.154) * hoursPerMonth,
+		},
+		{
+			name:     "unpriced region",
+			instance: Instance{InstanceID: "i-2", InstanceType: "m5.xlarge", Region: "mars-1", CPUAvg7d: 40},
+			wantOK:   true,
+			wantType: "m6g.xlarge",
+		},
+		{name: "already Graviton", instance: Instance{InstanceID: "i-3", InstanceType: "m7g.large", Region: "us-east-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EC2GravitonMigration(tt.instance)
+			if ok != tt.wantOK {
+				t.Fatalf("EC2GravitonMigration() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.GravitonType != tt.wantType || got.ResourceID != tt.instance.InstanceID {
+				t.Errorf("migration = %+v, want %s to %s", got, tt.instance.InstanceID, tt.wantType)
+			}
+			if math.Abs(got.MonthlySavings-tt.wantSavings) > 1e-9 {
+				t.Errorf("MonthlySavings = %.4f, want %.4f", got.MonthlySavings, tt.wantSavings)
+			}
+			if got.Priced() != (tt.wantSavings > 0) {
+				t.Errorf("Priced() = %v", got.Priced())
+			}
+			if got.CO2KgSaved < 0 {
+				t.Errorf("CO2KgSaved = %.2f, want no negative savings", got.CO2KgSaved)
+			}
+		})
+	}
+}
+
+func TestRDSGravitonMigration(t *testing.T) {
+	tests := []struct {
+		name        string
+		instance    RDSInstance
+		wantOK      bool
+		wantType    string
+		wantSavings float64
+	}{
+		{
+			name:        "single-AZ",
+			instance:    RDSInstance{InstanceID: "orders", InstanceType: "db.m5.large", Engine: "postgres", Region: "us-east-1", CPUAvg7d: 30},
+			wantOK:      true,
+			wantType:    "db.m6g.large",
+			wantSavings: (0.178 - 0.159) * hoursPerMonth,
+		},
+		{
+			name:        "multi-AZ migrates the standby too",
+			instance:    RDSInstance{InstanceID: "orders", InstanceType: "db.m5.large", Engine: "postgres", Region: "us-east-1", CPUAvg7d: 30, MultiAZ: true},
+			wantOK:      true,
+			wantType:    "db.m6g.large",
+			wantSavings: 2 * (0.178 - 0.159) * hoursPerMonth,
+		},
+		{
+			name:        "mariadb priced as mysql",
+			instance:    RDSInstance{InstanceID: "cms", InstanceType: "db.r5.xlarge", Engine: "mariadb", Region: "us-east-1"},
+			wantOK:      true,
+			wantType:    "db.r6g.xlarge",
+			wantSavings: (0.5 - 0.45) * hoursPerMonth,
+		},
+		{
+			name:     "aurora is not priced",
+			instance: RDSInstance{InstanceID: "cluster-1", InstanceType: "db.r5.large", Engine: "aurora-postgresql", Region: "us-east-1"},
+			wantOK:   true,
+			wantType: "db.r6g.large",
+		},
+		{name: "engine without Graviton", instance: RDSInstance{InstanceID: "erp", InstanceType: "db.m5.large", Engine: "oracle-ee", Region: "us-east-1"}},
+		{name: "already Graviton", instance: RDSInstance{InstanceID: "db-2", InstanceType: "db.m6g.large", Engine: "mysql", Region: "us-east-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RDSGravitonMigration(tt.instance)
+			if ok != tt.wantOK {
+				t.Fatalf("RDSGravitonMigration() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.GravitonType != tt.wantType {
+				t.Errorf("GravitonType = %q, want %q", got.GravitonType, tt.wantType)
+			}
+			if math.Abs(got.MonthlySavings-tt.wantSavings) > 1e-9 {
+				t.Errorf("MonthlySavings = %.4f, want %.4f", got.MonthlySavings, tt.wantSavings)
+			}
+		})
+	}
+}
+
+func TestGravitonMigrationsOrder(t *testing.T) {
+	report := []ReportItem{
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "small", InstanceType: "m5.large", Region: "us-east-1"}},
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "graviton", InstanceType: "m6g.large", Region: "us-east-1"}},
+		{ResourceType: ResourceTypeRDS, RDSInstance: RDSInstance{InstanceID: "db", InstanceType: "db.m5.xlarge", Engine: "mysql", Region: "us-east-1"}},
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "large", InstanceType: "m5.4xlarge", Region: "us-east-1"}},
+		{ResourceType: ResourceTypeS3, S3Bucket: S3Bucket{BucketName: "logs"}},
+	}
+
+	var got []string
+	for _, migration := range GravitonMigrations(report) {
+		got = append(got, migration.ResourceID)
+	}
+	// m5.4xlarge saves $109.44, db.m5.xlarge $27.36 and m5.large $13.68 a month
+	if want := []string{"large", "db", "small"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GravitonMigrations() = %v, want %v", got, want)
+	}
+}
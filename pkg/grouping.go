@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultGroupSimilarity is the cosine similarity at which --group-similar treats two resources
+// as near-duplicates
+const DefaultGroupSimilarity = 0.95
+
+// ResourceGroup is a set of near-duplicate resources, such as a fleet of identical idle dev
+// instances, described by the analysis of one of them
+type ResourceGroup struct {
+	Representative ReportItem
+	Members        []ReportItem // every resource of the group, the representative first
+	// MinSimilarity is the lowest cosine similarity of a member to the representative; 1 for a single resource
+	MinSimilarity float64
+	// The representative's figures multiplied by the member count
+	MonthlyCost    float64
+	MonthlySavings float64
+	CO2Savings     float64
+}
+
+// Count returns the number of resources in the group
+func (g ResourceGroup) Count() int {
+	return len(g.Members)
+}
+
+// ClusterReportItems groups resources whose embeddings have a cosine similarity of at least
+// threshold to a group's representative. Only resources of the same type are grouped, and
+// items without an embedding each get a group of their own. The items are visited in type,
+// region and ID order and join the most similar existing group, so the same report always
+// gives the same groups whatever order it arrives in. Groups are returned largest first,
+// then by aggregated savings.
+func ClusterReportItems(report []ReportItem, threshold float64) []ResourceGroup {
+	items := make([]ReportItem, len(report))
+	copy(items, report)
+	sort.SliceStable(items, func(i, j int) bool {
+		return lessByName(items[i], items[j])
+	})
+
+	var groups []ResourceGroup
+	for _, item := range items {
+		best, bestSimilarity := -1, 0.0
+		if len(item.Embedding) > 0 {
+			for i, group := range groups {
+				rep := group.Representative
+				if rep.GetResourceType() != item.GetResourceType() {
+					continue
+				}
+				similarity, ok := CosineSimilarity(rep.Embedding, item.Embedding)
+				if ok && similarity >= threshold && (best < 0 || similarity > bestSimilarity) {
+					best, bestSimilarity = i, similarity
+				}
+			}
+		}
+
+		if best < 0 {
+			groups = append(groups, ResourceGroup{Representative: item, Members: []ReportItem{item}, MinSimilarity: 1})
+			continue
+		}
+		groups[best].Members = append(groups[best].Members, item)
+		groups[best].MinSimilarity = math.Min(groups[best].MinSimilarity, bestSimilarity)
+	}
+
+	for i := range groups {
+		co2, cost, savings := extractItemMetrics(groups[i].Representative)
+		count := float64(groups[i].Count())
+		groups[i].MonthlyCost = cost * count
+		groups[i].MonthlySavings = savings * count
+		groups[i].CO2Savings = estimateCO2Savings(co2, cost, savings) * count
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Count() != groups[j].Count() {
+			return groups[i].Count() > groups[j].Count()
+		}
+		return groups[i].MonthlySavings > groups[j].MonthlySavings
+	})
+	return groups
+}
+
+// printResourceGroups prints every group of two or more near-duplicate resources with the
+// analysis of its representative and the savings of acting on the whole group
+func printResourceGroups(w io.Writer, report []ReportItem, threshold float64, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sRESOURCE GROUPS%s\n", ColorBold+ColorBlue, ColorReset)
+	} else {
+		fmt.Fprintln(w, "\nRESOURCE GROUPS")
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 15))
+
+	groups := ClusterReportItems(report, threshold)
+	shown, singles := 0, 0
+	for _, group := range groups {
+		if group.Count() < 2 {
+			singles++
+			continue
+		}
+		shown++
+
+		repID, region, _, _ := describeItem(group.Representative)
+		title := fmt.Sprintf("%d. %d similar %s resources like %s (%s)", shown, group.Count(), group.Representative.GetResourceType(), repID, region)
+		if colorize {
+			title = ColorBold + title + ColorReset
+		}
+		fmt.Fprintf(w, "\n%s\n", title)
+
+		ids := make([]string, 0, group.Count())
+		for _, member := range group.Members {
+			id, _, _, _ := describeItem(member)
+			ids = append(ids, id)
+		}
+		fmt.Fprintf(w, "Members: %s\n", strings.Join(ids, ", "))
+		fmt.Fprintf(w, "Similarity: at least %.3f\n", group.MinSimilarity)
+
+		savings := fmt.Sprintf("$%.2f/month", group.MonthlySavings)
+		if colorize && group.MonthlySavings > 0 {
+			savings = ColorGreen + savings + ColorReset
+		}
+		fmt.Fprintf(w, "Group cost: $%.2f/month, potential savings: %s, %.2f kg CO2/month (%d × the representative)\n",
+			group.MonthlyCost, savings, group.CO2Savings, group.Count())
+		fmt.Fprintf(w, "\nRepresentative analysis (%s):\n%s\n", repID, strings.TrimSpace(group.Representative.Analysis))
+	}
+
+	if shown == 0 {
+		fmt.Fprintf(w, "No near-duplicate resources found at a similarity of %.2f.\n", threshold)
+		return
+	}
+	if singles > 0 {
+		fmt.Fprintf(w, "\n%d other resources have no near-duplicates.\n", singles)
+	}
+}
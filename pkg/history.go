@@ -0,0 +1,264 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// HistoryRecord summarizes one run in the history file
+type HistoryRecord struct {
+	Timestamp            time.Time      `json:"timestamp"`
+	Regions              []string       `json:"regions"`
+	TotalResources       int            `json:"total_resources"`
+	ResourceCounts       map[string]int `json:"resource_counts"`
+	TotalCost            float64        `json:"total_monthly_cost"`
+	TotalCO2             float64        `json:"total_co2_kg_monthly"`
+	PotentialCostSavings float64        `json:"potential_monthly_savings"`
+	PotentialCO2Savings  float64        `json:"potential_co2_kg_savings"`
+	ConfigHash           string         `json:"config_hash"`
+	Version              string         `json:"version"`
+	// ItemSeconds is the wall-clock analysis time per item, for the ETA of AnalysisEstimate;
+	// zero for runs that did not analyze, such as greenops jobs results
+	ItemSeconds float64 `json:"item_seconds,omitempty"`
+}
+
+// maxHistoryLine bounds one line of the history file; records are a few hundred bytes
+const maxHistoryLine = 1024 * 1024
+
+// sparkLevels are the bars of a sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// DefaultHistoryPath returns ~/.greenops/history.jsonl
+func DefaultHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".greenops", "history.jsonl"), nil
+}
+
+// ScanHash identifies the settings that shape a run's results: the API, the regions and
+// profile, what is scanned, the models and where prices come from. Runs with the same hash
+// are comparable. Output settings, CI thresholds and the API key are left out.
+func (c *Config) ScanHash() string {
+	shaping := struct {
+		APIURL       string
+		AWS          interface{}
+		Scan         interface{}
+		Bedrock      interface{}
+		Pricing      interface{}
+		CostExplorer interface{}
+	}{c.API.URL, c.AWS, c.Scan, c.Bedrock, c.Pricing, c.CostExplorer}
+
+	data, err := canonicalJSON(shaping)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// NewHistoryRecord summarizes a report for the history file. The regions are those of the
+// reported resources, so runs over a saved scan record where the resources really are.
+func NewHistoryRecord(report []ReportItem, configHash string) HistoryRecord {
+	summary := SummarizeReport(report)
+	regions := []string{}
+	for _, item := range report {
+		_, region, _, _ := describeItem(item)
+		if region != "" && region != "multiple" && !slices.Contains(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+
+	return HistoryRecord{
+		Timestamp:            time.Now().UTC(),
+		Regions:              regions,
+		TotalResources:       summary.TotalResources,
+		ResourceCounts:       summary.ResourceCounts,
+		TotalCost:            summary.TotalCost,
+		TotalCO2:             summary.TotalCO2,
+		PotentialCostSavings: summary.PotentialCostSavings,
+		PotentialCO2Savings:  summary.PotentialCO2Savings,
+		ConfigHash:           configHash,
+		Version:              CurrentBuild().Version,
+	}
+}
+
+// AppendHistory adds a record as one line to the history file, creating the file and its
+// directory if needed. The file is locked while the line is written, so concurrent runs
+// never interleave their records.
+func AppendHistory(path string, record HistoryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file, true); err != nil {
+		return fmt.Errorf("failed to lock history file: %w", err)
+	}
+	defer unlockFile(file)
+
+	// A line cut short by a crashed run is ended first, so it cannot swallow this record
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	// One write per record, so even an unlocked reader sees whole lines
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// ReadHistory returns the records of the history file, oldest first. A missing file has no
+// records. Lines that cannot be parsed, such as one cut short by a crashed run, are skipped
+// with a warning.
+func ReadHistory(path string) ([]HistoryRecord, error) {
+	records := []HistoryRecord{}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file, false); err != nil {
+		return nil, fmt.Errorf("failed to lock history file: %w", err)
+	}
+	defer unlockFile(file)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxHistoryLine)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			Warnf("Skipping unreadable line %d of %s: %v", lineNo, path, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return records, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return records, nil
+}
+
+// Sparkline draws values as a row of bars scaled between their minimum and maximum
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = int(math.Round((v - lo) / (hi - lo) * float64(len(sparkLevels)-1)))
+		}
+		sb.WriteRune(sparkLevels[level])
+	}
+	return sb.String()
+}
+
+// FormatHistoryJSON writes history records as a JSON array
+func FormatHistoryJSON(w io.Writer, records []HistoryRecord) error {
+	if records == nil {
+		records = []HistoryRecord{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// FormatHistory prints one line per run, oldest first, followed by the CO2 and cost trend
+func FormatHistory(w io.Writer, records []HistoryRecord, colorize bool) {
+	printHeader(w, "GreenOps Run History", colorize)
+	if len(records) == 0 {
+		fmt.Fprintln(w, "No runs recorded yet.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tREGIONS\tRESOURCES\tCOST ($/mo)\tCO2 (kg/mo)\tSAVINGS ($/mo)\tCONFIG")
+	for _, r := range records {
+		regions := strings.Join(r.Regions, ",")
+		if regions == "" {
+			regions = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f\t%.2f\t%.2f\t%s\n",
+			r.Timestamp.Local().Format("2006-01-02 15:04"), regions, r.TotalResources,
+			r.TotalCost, r.TotalCO2, r.PotentialCostSavings, r.ConfigHash)
+	}
+	tw.Flush()
+
+	co2 := make([]float64, len(records))
+	cost := make([]float64, len(records))
+	for i, r := range records {
+		co2[i], cost[i] = r.TotalCO2, r.TotalCost
+	}
+	fmt.Fprintln(w)
+	printTrend(w, "CO2 ", co2, "kg/mo", colorize)
+	printTrend(w, "Cost", cost, "$/mo", colorize)
+
+	if hashes := distinctHashes(records); hashes > 1 {
+		fmt.Fprintf(w, "\nNote: these runs used %d different configurations (CONFIG column), so not all of them are comparable.\n", hashes)
+	}
+}
+
+// printTrend prints a sparkline with the first and last value and the change between them.
+// A fall is colored green, since lower cost and CO2 are improvements.
+func printTrend(w io.Writer, label string, values []float64, unit string, colorize bool) {
+	first, last := values[0], values[len(values)-1]
+	change := signed(last - first)
+	if colorize && last < first {
+		change = ColorGreen + change + ColorReset
+	} else if colorize && last > first {
+		change = ColorRed + change + ColorReset
+	}
+	fmt.Fprintf(w, "%s  %s  %.2f → %.2f %s (%s)\n", label, Sparkline(values), first, last, unit, change)
+}
+
+// distinctHashes counts the configurations the runs used
+func distinctHashes(records []HistoryRecord) int {
+	seen := make(map[string]bool)
+	for _, r := range records {
+		seen[r.ConfigHash] = true
+	}
+	return len(seen)
+}
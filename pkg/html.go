@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// htmlReportTemplate is the self-contained HTML report layout with an embedded green theme
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="generator" content="{{.Generator}}">
+<title>GreenOps Analysis Report</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; background: #f4f9f4; color: #1f2d1f; }
+  header { background: #1e6b34; color: #fff; padding: 24px 32px; }
+  header h1 { margin: 0 0 4px 0; font-size: 26px; }
+  header p { margin: 0; opacity: 0.85; }
+  main { max-width: 1000px; margin: 0 auto; padding: 24px 32px; }
+  .cards { display: flex; flex-wrap: wrap; gap: 16px; margin-bottom: 24px; }
+  .card { flex: 1 1 200px; background: #fff; border-left: 6px solid #2e9e4f; border-radius: 6px; padding: 16px; box-shadow: 0 1px 3px rgba(0,0,0,0.08); }
+  .card .label { font-size: 13px; text-transform: uppercase; color: #4f6f4f; }
+  .card .value { font-size: 24px; font-weight: bold; color: #1e6b34; margin-top: 4px; }
+  .card .sub { font-size: 13px; color: #4f6f4f; }
+  .counts { margin-bottom: 24px; color: #4f6f4f; }
+  section.resource { background: #fff; border-radius: 6px; padding: 20px 24px; margin-bottom: 20px; box-shadow: 0 1px 3px rgba(0,0,0,0.08); }
+  section.resource h2 { margin-top: 0; color: #1e6b34; font-size: 20px; }
+  .meta { display: flex; flex-wrap: wrap; gap: 8px 24px; font-size: 14px; color: #4f6f4f; margin-bottom: 12px; }
+  .meta span b { color: #1f2d1f; }
+  .analysis h3, .analysis h4, .analysis h5 { color: #2e7d42; margin-bottom: 6px; }
+  .analysis ul, .analysis ol { padding-left: 24px; }
+  footer { text-align: center; font-size: 12px; color: #7a8f7a; padding: 16px; }
+</style>
+</head>
+<body>
+<header>
+  <h1>GreenOps Analysis Report</h1>
+  <p>Generated {{.GeneratedAt}} by {{.Generator}}</p>
+  {{range .Metadata}}<p>{{.}}</p>
+  {{end}}
+</header>
+<main>
+  <div class="cards">
+    <div class="card"><div class="label">CO2 Emissions</div><div class="value">{{printf "%.2f" .Summary.TotalCO2}} kg</div><div class="sub">CO2e per month</div></div>
+    <div class="card"><div class="label">Potential CO2 Savings</div><div class="value">{{printf "%.2f" .Summary.PotentialCO2Savings}} kg</div><div class="sub">CO2e per month</div></div>
+    <div class="card"><div class="label">Current Cost</div><div class="value">${{printf "%.2f" .Summary.TotalCost}}</div><div class="sub">per month</div></div>
+    <div class="card"><div class="label">Potential Savings</div><div class="value">${{printf "%.2f" .Summary.PotentialCostSavings}}</div><div class="sub">per month</div></div>
+  </div>
+  <p class="counts">{{range .Counts}}{{.}} &middot; {{end}}{{.Summary.TotalResources}} resources analyzed</p>
+  {{range .Resources}}
+  <section class="resource">
+    <h2>{{.Title}}</h2>
+    <div class="meta">
+      {{if .Region}}<span><b>Region:</b> {{.Region}}</span>{{end}}
+      {{if .ConsoleURL}}<span><a href="{{.ConsoleURL}}">Open in the AWS console</a></span>{{end}}
+      {{if .Size}}<span><b>Size:</b> {{.Size}}</span>{{end}}
+      <span><b>Utilization:</b> {{.Utilization}}</span>
+    </div>
+    <div class="analysis">{{.AnalysisHTML}}</div>
+  </section>
+  {{end}}
+</main>
+<footer>GreenOps &mdash; sustainable cloud optimization</footer>
+</body>
+</html>
+`))
+
+// htmlResource is the per-resource view model for the HTML template
+type htmlResource struct {
+	Title        string
+	Region       string
+	ConsoleURL   string
+	Size         string
+	Utilization  string
+	AnalysisHTML template.HTML
+}
+
+var (
+	markdownHeadingRegex = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownBulletRegex  = regexp.MustCompile(`^\s*[-*]\s+(.*)$`)
+	markdownNumberRegex  = regexp.MustCompile(`^\s*\d+\.\s+(.*)$`)
+	markdownBoldRegex    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+)
+
+// FormatAnalysisReportHTML writes the analysis report as a standalone HTML document, with the
+// metadata of the scan in its header when there is any
+func FormatAnalysisReportHTML(w io.Writer, report []ReportItem, meta *ReportMetadata) error {
+	summary := SummarizeReport(report)
+
+	// Resource counts, sorted for consistent output
+	types := make([]string, 0, len(summary.ResourceCounts))
+	for t := range summary.ResourceCounts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	counts := make([]string, 0, len(types))
+	for _, t := range types {
+		counts = append(counts, fmt.Sprintf("%d %s", summary.ResourceCounts[t], strings.ToUpper(t)))
+	}
+
+	resources := make([]htmlResource, 0, len(report))
+	for i, item := range report {
+		resourceID, region, size, utilization := describeItem(item)
+		resources = append(resources, htmlResource{
+			Title:        fmt.Sprintf("%d. %s %s", i+1, strings.ToUpper(string(item.GetResourceType())), resourceID),
+			Region:       region,
+			ConsoleURL:   itemConsoleURL(item),
+			Size:         size,
+			Utilization:  utilization,
+			AnalysisHTML: renderMarkdownHTML(item.Analysis),
+		})
+	}
+
+	data := struct {
+		GeneratedAt string
+		Generator   string
+		Metadata    []string
+		Summary     ReportSummary
+		Counts      []string
+		Resources   []htmlResource
+	}{
+		GeneratedAt: time.Now().Format(time.RFC1123),
+		Generator:   ReportGenerator(),
+		Metadata:    meta.Lines(),
+		Summary:     summary,
+		Counts:      counts,
+		Resources:   resources,
+	}
+
+	if err := htmlReportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return nil
+}
+
+// ExportReportToHTML renders the analysis report to an HTML file at the given path
+func ExportReportToHTML(report []ReportItem, meta *ReportMetadata, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer file.Close()
+
+	return FormatAnalysisReportHTML(file, report, meta)
+}
+
+// renderMarkdownHTML converts the headings, lists and bold text the model produces into HTML.
+// Every piece of model text is escaped first, so raw HTML in the response is shown literally.
+func renderMarkdownHTML(text string) template.HTML {
+	var sb strings.Builder
+	openList := ""
+
+	closeList := func() {
+		if openList != "" {
+			sb.WriteString("</" + openList + ">\n")
+			openList = ""
+		}
+	}
+	startList := func(tag string) {
+		if openList != tag {
+			closeList()
+			sb.WriteString("<" + tag + ">\n")
+			openList = tag
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			closeList()
+		case markdownHeadingRegex.MatchString(trimmed):
+			closeList()
+			m := markdownHeadingRegex.FindStringSubmatch(trimmed)
+			// Report sections already use h2, so model headings start at h3
+			level := min(len(m[1])+2, 6)
+			fmt.Fprintf(&sb, "<h%d>%s</h%d>\n", level, renderInlineHTML(m[2]), level)
+		case markdownBulletRegex.MatchString(line):
+			startList("ul")
+			fmt.Fprintf(&sb, "<li>%s</li>\n", renderInlineHTML(markdownBulletRegex.FindStringSubmatch(line)[1]))
+		case markdownNumberRegex.MatchString(line):
+			startList("ol")
+			fmt.Fprintf(&sb, "<li>%s</li>\n", renderInlineHTML(markdownNumberRegex.FindStringSubmatch(line)[1]))
+		default:
+			closeList()
+			fmt.Fprintf(&sb, "<p>%s</p>\n", renderInlineHTML(trimmed))
+		}
+	}
+	closeList()
+
+	return template.HTML(sb.String())
+}
+
+// renderInlineHTML escapes a line of model output and then applies **bold** markup
+func renderInlineHTML(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, "`", "")
+	return markdownBoldRegex.ReplaceAllString(escaped, "<strong>$1</strong>")
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Defaults of IdleThresholds
+const (
+	DefaultIdleCPUPct             = 5.0
+	DefaultIdleNetworkBytesPerSec = 2048.0
+)
+
+// IdleThresholds are the limits below which an EC2 instance is classified as idle: its CPU
+// on every day of the metrics window, and its average network traffic in and out combined
+type IdleThresholds struct {
+	CPUPct             float64 `json:"idle_cpu_pct" yaml:"idle_cpu_pct"`
+	NetworkBytesPerSec float64 `json:"idle_network_bytes_per_sec" yaml:"idle_network_bytes_per_sec"`
+}
+
+// withDefaults returns the thresholds with the defaults in place of unset ones
+func (t IdleThresholds) withDefaults() IdleThresholds {
+	if t.CPUPct <= 0 {
+		t.CPUPct = DefaultIdleCPUPct
+	}
+	if t.NetworkBytesPerSec <= 0 {
+		t.NetworkBytesPerSec = DefaultIdleNetworkBytesPerSec
+	}
+	return t
+}
+
+// IdleScore rates from 0 to 100 how far below the thresholds an instance's busiest day of
+// CPU and its network traffic are, half each; 100 is no activity at all
+func IdleScore(cpuDailyPeak, networkBytesPerSec float64, thresholds IdleThresholds) int {
+	thresholds = thresholds.withDefaults()
+	cpu := 1 - min(max(cpuDailyPeak, 0)/thresholds.CPUPct, 1)
+	network := 1 - min(max(networkBytesPerSec, 0)/thresholds.NetworkBytesPerSec, 1)
+	return int(math.Round(50*cpu + 50*network))
+}
+
+// classifyIdle sets the idle verdict of an instance whose metrics cover the window starting
+// at windowStart. It is idle when no day's average CPU reached the threshold and its network
+// traffic stayed below it; IdleSince is then the later of the window start and the launch,
+// as nothing older is known. Instances without CPU datapoints are left unclassified.
+func classifyIdle(instance *Instance, thresholds IdleThresholds, windowStart time.Time) {
+	if instance.CPUDailyPeak <= 0 {
+		return
+	}
+	thresholds = thresholds.withDefaults()
+	network := instance.NetworkInAvg7d + instance.NetworkOutAvg7d
+	instance.IdleScore = IdleScore(instance.CPUDailyPeak, network, thresholds)
+	instance.Idle = instance.CPUDailyPeak < thresholds.CPUPct && network < thresholds.NetworkBytesPerSec
+	if instance.Idle {
+		since := windowStart
+		if instance.LaunchTime.After(since) {
+			since = instance.LaunchTime
+		}
+		instance.IdleSince = &since
+	}
+}
+
+// idleVerdict describes why an idle instance was classified as idle, for the analysis prompt
+func idleVerdict(instance Instance) string {
+	if !instance.Idle {
+		return ""
+	}
+	verdict := fmt.Sprintf("no day averaged above %.1f%% CPU and network traffic averaged %s, idle score %d/100",
+		instance.CPUDailyPeak, formatByteRate(instance.NetworkInAvg7d+instance.NetworkOutAvg7d), instance.IdleScore)
+	if instance.IdleSince != nil {
+		verdict += ", idle since at least " + instance.IdleSince.Format("2006-01-02")
+	}
+	return verdict
+}
+
+// IsIdle reports whether the item is an EC2 instance the scan classified as idle
+func (r *ReportItem) IsIdle() bool {
+	return r.GetResourceType() == ResourceTypeEC2 && r.Instance.Idle
+}
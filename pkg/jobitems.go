@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ItemStatus is the state of one work item of a job
+type ItemStatus string
+
+const (
+	ItemQueued     ItemStatus = "queued"
+	ItemProcessing ItemStatus = "processing"
+	ItemCompleted  ItemStatus = "completed"
+	ItemFailed     ItemStatus = "failed"
+	ItemSkipped    ItemStatus = "skipped" // the job was cancelled before the item was processed
+)
+
+// JobItemRecord tracks one work item of a job in the items table. It is keyed by job_id and
+// item_index like the results table, so every write for an item lands on the same record
+// whichever worker or retry makes it.
+type JobItemRecord struct {
+	JobID          string     `json:"-" dynamodbav:"job_id"`
+	ItemIndex      int        `json:"item_index" dynamodbav:"item_index"`
+	ItemType       string     `json:"item_type" dynamodbav:"item_type"`
+	ResourceID     string     `json:"resource_id" dynamodbav:"resource_id"`
+	Status         ItemStatus `json:"status" dynamodbav:"status"`
+	Error          string     `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Attempts       int        `json:"attempts,omitempty" dynamodbav:"attempts,omitempty"`       // times a worker started the item
+	DurationMs     int64      `json:"duration_ms,omitempty" dynamodbav:"duration_ms,omitempty"` // of the last attempt
+	UpdatedAt      int64      `json:"updated_at" dynamodbav:"updated_at"`
+	ExpirationTime int64      `json:"-" dynamodbav:"expiration_time"`
+}
+
+// dynamoBatchWriteSize is the most items one BatchWriteItem call accepts
+const dynamoBatchWriteSize = 25
+
+// maxBatchWriteAttempts bounds the calls PutJobItems makes for items DynamoDB leaves unprocessed
+const maxBatchWriteAttempts = 3
+
+// ItemProcessingLease is how long an item claimed by ClaimJobItem belongs to the worker that
+// claimed it. It matches the worker's timeout, so an older claim was left by a worker that died.
+const ItemProcessingLease = 5 * time.Minute
+
+// Errors returned by ClaimJobItem for items that must not be processed now
+var (
+	ErrItemAlreadyProcessed = errors.New("item was already processed")
+	ErrItemInProgress       = errors.New("item is being processed by another worker")
+)
+
+// JobItemsEnabled reports whether items are tracked, which needs ITEMS_TABLE to be set
+func JobItemsEnabled() bool {
+	return os.Getenv("ITEMS_TABLE") != ""
+}
+
+// PutJobItems records every work item of a newly created job as queued
+func PutJobItems(ctx context.Context, dynamoClient DynamoJobStore, workItems []WorkItem) error {
+	if !JobItemsEnabled() || len(workItems) == 0 {
+		return nil
+	}
+	table := os.Getenv("ITEMS_TABLE")
+	now := time.Now().Unix()
+
+	requests := make([]types.WriteRequest, 0, len(workItems))
+	for _, workItem := range workItems {
+		item, err := attributevalue.MarshalMap(JobItemRecord{
+			JobID:      workItem.JobID,
+			ItemIndex:  workItem.ItemIndex,
+			ItemType:   workItem.ItemType,
+			ResourceID: workItem.ResourceID(),
+			Status:     ItemQueued,
+			UpdatedAt:  now,
+			// Same TTL as the job record
+			ExpirationTime: workItem.Expiration(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal item %d: %w", workItem.ItemIndex, err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	for start := 0; start < len(requests); start += dynamoBatchWriteSize {
+		batch := requests[start:min(start+dynamoBatchWriteSize, len(requests))]
+		for attempt := 1; len(batch) > 0; attempt++ {
+			if attempt > maxBatchWriteAttempts {
+				return fmt.Errorf("failed to record %d items of job %s: left unprocessed", len(batch), workItems[0].JobID)
+			}
+			output, err := dynamoClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{table: batch},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to record items of job %s: %w", workItems[0].JobID, err)
+			}
+			batch = output.UnprocessedItems[table]
+		}
+	}
+	return nil
+}
+
+// UpdateJobItem records the new status of a work item with its error message, if any, and
+// the duration of the attempt that ended. The record is created if PutJobItems did not write
+// it. Workers move items to processing with ClaimJobItem instead.
+func UpdateJobItem(ctx context.Context, dynamoClient DynamoJobStore, workItem WorkItem, status ItemStatus, errMsg string, duration time.Duration) error {
+	if !JobItemsEnabled() {
+		return nil
+	}
+	now := time.Now().Unix()
+
+	updateExpr := "SET #status = :status, updated_at = :now, item_type = :item_type, resource_id = :resource_id, " +
+		"expiration_time = if_not_exists(expiration_time, :expires)"
+	values := map[string]types.AttributeValue{
+		":status":      &types.AttributeValueMemberS{Value: string(status)},
+		":now":         &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		":item_type":   &types.AttributeValueMemberS{Value: workItem.ItemType},
+		":resource_id": &types.AttributeValueMemberS{Value: workItem.ResourceID()},
+		":expires":     &types.AttributeValueMemberN{Value: strconv.FormatInt(workItem.Expiration(), 10)},
+	}
+	if duration > 0 {
+		updateExpr += ", duration_ms = :duration"
+		values[":duration"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(duration.Milliseconds(), 10)}
+	}
+	if errMsg != "" {
+		updateExpr += ", #error = :error"
+		values[":error"] = &types.AttributeValueMemberS{Value: errMsg}
+	} else {
+		updateExpr += " REMOVE #error"
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("ITEMS_TABLE")),
+		Key: map[string]types.AttributeValue{
+			"job_id":     &types.AttributeValueMemberS{Value: workItem.JobID},
+			"item_index": &types.AttributeValueMemberN{Value: strconv.Itoa(workItem.ItemIndex)},
+		},
+		UpdateExpression:          aws.String(updateExpr),
+		ExpressionAttributeNames:  map[string]string{"#status": "status", "#error": "error"},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record status of item %d of job %s: %w", workItem.ItemIndex, workItem.JobID, err)
+	}
+	return nil
+}
+
+// ClaimJobItem marks a work item as processing, and counts an attempt, before a worker spends
+// Bedrock calls on it, so a message SQS delivers more than once is processed once. It returns
+// ErrItemAlreadyProcessed for items that completed, failed or were skipped, and
+// ErrItemInProgress for items another worker claimed within ItemProcessingLease. The error of
+// the item's last attempt is kept until the attempt ends. Without ITEMS_TABLE there are no
+// claims, and only items the job has already counted are detected.
+func ClaimJobItem(ctx context.Context, dynamoClient DynamoJobStore, workItem WorkItem) error {
+	if !JobItemsEnabled() {
+		counted, err := itemCounted(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex)
+		if err != nil {
+			return err
+		}
+		if counted {
+			return fmt.Errorf("%w: job %s has counted item %d", ErrItemAlreadyProcessed, workItem.JobID, workItem.ItemIndex)
+		}
+		return nil
+	}
+	now := time.Now()
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("ITEMS_TABLE")),
+		Key: map[string]types.AttributeValue{
+			"job_id":     &types.AttributeValueMemberS{Value: workItem.JobID},
+			"item_index": &types.AttributeValueMemberN{Value: strconv.Itoa(workItem.ItemIndex)},
+		},
+		UpdateExpression: aws.String("SET #status = :processing, updated_at = :now, item_type = :item_type, resource_id = :resource_id, " +
+			"expiration_time = if_not_exists(expiration_time, :expires) ADD attempts :one"),
+		// Queued items, items without a record and claims past their lease may be taken
+		ConditionExpression:      aws.String("attribute_not_exists(#status) OR #status = :queued OR (#status = :processing AND updated_at < :stale)"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":processing":  &types.AttributeValueMemberS{Value: string(ItemProcessing)},
+			":queued":      &types.AttributeValueMemberS{Value: string(ItemQueued)},
+			":now":         &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			":stale":       &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(-ItemProcessingLease).Unix(), 10)},
+			":item_type":   &types.AttributeValueMemberS{Value: workItem.ItemType},
+			":resource_id": &types.AttributeValueMemberS{Value: workItem.ResourceID()},
+			":expires":     &types.AttributeValueMemberN{Value: strconv.FormatInt(workItem.Expiration(), 10)},
+			":one":         &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		var record JobItemRecord
+		if err := attributevalue.UnmarshalMap(conditionErr.Item, &record); err != nil {
+			return fmt.Errorf("failed to unmarshal item %d of job %s: %w", workItem.ItemIndex, workItem.JobID, err)
+		}
+		if record.Status == ItemProcessing {
+			return fmt.Errorf("%w: item %d of job %s was claimed %s ago", ErrItemInProgress, workItem.ItemIndex, workItem.JobID,
+				now.Sub(time.Unix(record.UpdatedAt, 0)).Round(time.Second))
+		}
+		return fmt.Errorf("%w: item %d of job %s is %s", ErrItemAlreadyProcessed, workItem.ItemIndex, workItem.JobID, record.Status)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim item %d of job %s: %w", workItem.ItemIndex, workItem.JobID, err)
+	}
+	return nil
+}
+
+// QueryJobItems returns the tracked items of a job ordered by item index, or none when items
+// are not tracked
+func QueryJobItems(ctx context.Context, dynamoClient DynamoJobStore, jobID string) ([]JobItemRecord, error) {
+	if !JobItemsEnabled() {
+		return nil, nil
+	}
+
+	items := make([]JobItemRecord, 0)
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("ITEMS_TABLE")),
+		KeyConditionExpression: aws.String("job_id = :job_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query items of job %s: %w", jobID, err)
+		}
+		var records []JobItemRecord
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &records); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items of job %s: %w", jobID, err)
+		}
+		items = append(items, records...)
+	}
+	return items, nil
+}
+
+// GetJobItem returns the record of one work item, or nil when it has none or items are not tracked
+func GetJobItem(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int) (*JobItemRecord, error) {
+	if !JobItemsEnabled() {
+		return nil, nil
+	}
+
+	result, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("ITEMS_TABLE")),
+		Key: map[string]types.AttributeValue{
+			"job_id":     &types.AttributeValueMemberS{Value: jobID},
+			"item_index": &types.AttributeValueMemberN{Value: strconv.Itoa(itemIndex)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item %d of job %s: %w", itemIndex, jobID, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var record JobItemRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal item %d of job %s: %w", itemIndex, jobID, err)
+	}
+	return &record, nil
+}
+
+// FailedJobItems returns the items that failed
+func FailedJobItems(items []JobItemRecord) []JobItemRecord {
+	var failed []JobItemRecord
+	for _, item := range items {
+		if item.Status == ItemFailed {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,266 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+)
+
+// deliverWorkItem handles one delivery of a work item the way the worker does: claim it,
+// record its result or failure, close its item record and finalize the job. It reports
+// whether the item was processed rather than dropped as a redelivery.
+func deliverWorkItem(t *testing.T, dynamo *fakeDynamo, workItem WorkItem, success bool) bool {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := ClaimJobItem(ctx, dynamo, workItem); err != nil {
+		if errors.Is(err, ErrItemAlreadyProcessed) {
+			return false
+		}
+		t.Fatalf("ClaimJobItem() error = %v", err)
+	}
+
+	status := ItemCompleted
+	if success {
+		result := ReportItem{ResourceType: ResourceTypeEC2, Instance: workItem.Instance, Analysis: "analysis of " + workItem.Instance.InstanceID}
+		if err := RecordJobResult(ctx, dynamo, workItem, result); err != nil {
+			t.Fatalf("RecordJobResult() error = %v", err)
+		}
+	} else {
+		status = ItemFailed
+		if err := UpdateJobProgress(ctx, dynamo, workItem.JobID, workItem.ItemIndex, false, ReportItem{}); err != nil {
+			t.Fatalf("UpdateJobProgress() error = %v", err)
+		}
+	}
+	if err := UpdateJobItem(ctx, dynamo, workItem, status, "", time.Second); err != nil {
+		t.Fatalf("UpdateJobItem() error = %v", err)
+	}
+	if err := MaybeFinalizeJob(ctx, dynamo, workItem.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v", err)
+	}
+	return true
+}
+
+// newDeliveryJob creates a job of n items, recorded in the items table when it is in use
+func newDeliveryJob(t *testing.T, dynamo *fakeDynamo, n int) (*JobInfo, []WorkItem) {
+	t.Helper()
+	job := createTestJob(t, dynamo, n)
+	workItems := testWorkItems(n)
+	for i := range workItems {
+		workItems[i].JobID = job.JobID
+	}
+	if err := PutJobItems(context.Background(), dynamo, workItems); err != nil {
+		t.Fatalf("PutJobItems() error = %v", err)
+	}
+	return job, workItems
+}
+
+// SQS delivers at least once; a second delivery of an item must not count it again
+func TestDuplicateDelivery(t *testing.T) {
+	tests := []struct {
+		name         string
+		itemsTable   bool
+		resultsTable bool
+		success      bool
+	}{
+		{name: "completed, inline results", success: true},
+		{name: "completed, results table", resultsTable: true, success: true},
+		{name: "completed, items table", itemsTable: true, success: true},
+		{name: "completed, items and results tables", itemsTable: true, resultsTable: true, success: true},
+		{name: "failed", success: false},
+		{name: "failed, items table", itemsTable: true, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			if tt.itemsTable {
+				t.Setenv("ITEMS_TABLE", testItemsTable)
+			}
+			if tt.resultsTable {
+				t.Setenv("RESULTS_TABLE", testResultsTable)
+			}
+			dynamo := newFakeDynamo()
+			job, workItems := newDeliveryJob(t, dynamo, 2)
+
+			if !deliverWorkItem(t, dynamo, workItems[0], tt.success) {
+				t.Fatal("first delivery was dropped")
+			}
+			if deliverWorkItem(t, dynamo, workItems[0], tt.success) {
+				t.Error("second delivery was processed again")
+			}
+
+			got, err := GetJob(context.Background(), dynamo, job.JobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCompleted, wantFailed, wantResults := 1, 0, 1
+			if !tt.success {
+				wantCompleted, wantFailed, wantResults = 0, 1, 0
+			}
+			if got.CompletedItems != wantCompleted || got.FailedItems != wantFailed {
+				t.Errorf("completed %d, failed %d; want %d, %d", got.CompletedItems, got.FailedItems, wantCompleted, wantFailed)
+			}
+			if got.Status.IsTerminal() {
+				t.Errorf("job is %s with an item still outstanding", got.Status)
+			}
+			results, err := GetJobResults(context.Background(), dynamo, nil, got)
+			if err != nil {
+				t.Fatalf("GetJobResults() error = %v", err)
+			}
+			if len(results) != wantResults {
+				t.Errorf("got %d results, want %d", len(results), wantResults)
+			}
+
+			// The other item still finishes the job
+			deliverWorkItem(t, dynamo, workItems[1], true)
+			got, err = GetJob(context.Background(), dynamo, job.JobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != JobStatusCompleted || got.CompletedItems+got.FailedItems != 2 {
+				t.Errorf("job %s with %d completed and %d failed, want completed with 2 processed", got.Status, got.CompletedItems, got.FailedItems)
+			}
+		})
+	}
+}
+
+// When the claim itself fails the worker goes ahead, so the job's counters are what keep
+// a duplicate from being counted twice
+func TestDuplicateResultCountedOnce(t *testing.T) {
+	for _, resultsTable := range []string{"", testResultsTable} {
+		t.Run("results table "+resultsTable, func(t *testing.T) {
+			useJobTables(t)
+			t.Setenv("RESULTS_TABLE", resultsTable)
+			dynamo := newFakeDynamo()
+			job, workItems := newDeliveryJob(t, dynamo, 2)
+			result := ReportItem{ResourceType: ResourceTypeEC2, Instance: workItems[0].Instance, Analysis: "done",
+				AnalysisUsage: AnalysisUsage{PromptTokens: 100, CompletionTokens: 10}}
+
+			for i := 0; i < 2; i++ {
+				if err := RecordJobResult(context.Background(), dynamo, workItems[0], result); err != nil {
+					t.Fatalf("RecordJobResult() call %d error = %v", i+1, err)
+				}
+			}
+			// A failure reported for the same item after its success is not counted either
+			if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, 0, false, ReportItem{}); err != nil {
+				t.Fatalf("UpdateJobProgress() error = %v", err)
+			}
+
+			got, err := GetJob(context.Background(), dynamo, job.JobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.CompletedItems != 1 || got.FailedItems != 0 || len(got.FailedIndices) != 0 {
+				t.Errorf("completed %d, failed %d, failed indices %v; want 1, 0 and none", got.CompletedItems, got.FailedItems, got.FailedIndices)
+			}
+			if got.PromptTokens != 100 {
+				t.Errorf("PromptTokens = %d, want the usage of one result", got.PromptTokens)
+			}
+			results, err := GetJobResults(context.Background(), dynamo, nil, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 {
+				t.Errorf("got %d results, want 1", len(results))
+			}
+		})
+	}
+}
+
+func TestClaimJobItem(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name         string
+		record       *JobItemRecord // stored before the claim; nil leaves the queued record
+		noRecord     bool
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "queued", wantAttempts: 1},
+		{name: "no record", noRecord: true, wantAttempts: 1},
+		{name: "claimed by another worker", record: &JobItemRecord{Status: ItemProcessing, Attempts: 1, UpdatedAt: now}, wantErr: ErrItemInProgress},
+		{name: "claim past its lease", record: &JobItemRecord{Status: ItemProcessing, Attempts: 1, UpdatedAt: now - int64(ItemProcessingLease.Seconds()) - 60}, wantAttempts: 2},
+		{name: "completed", record: &JobItemRecord{Status: ItemCompleted, Attempts: 1, UpdatedAt: now}, wantErr: ErrItemAlreadyProcessed},
+		{name: "failed", record: &JobItemRecord{Status: ItemFailed, Attempts: 3, UpdatedAt: now}, wantErr: ErrItemAlreadyProcessed},
+		{name: "skipped", record: &JobItemRecord{Status: ItemSkipped, UpdatedAt: now}, wantErr: ErrItemAlreadyProcessed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			t.Setenv("ITEMS_TABLE", testItemsTable)
+			dynamo := newFakeDynamo()
+			job := createTestJob(t, dynamo, 1)
+			workItem := testWorkItems(1)[0]
+			workItem.JobID = job.JobID
+			if !tt.noRecord {
+				if err := PutJobItems(ctx, dynamo, []WorkItem{workItem}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.record != nil {
+				record := *tt.record
+				record.JobID, record.ItemIndex, record.ItemType, record.ResourceID = job.JobID, 0, "ec2", "i-0"
+				item, err := attributevalue.MarshalMap(record)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := dynamo.put(testItemsTable, item); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := ClaimJobItem(ctx, dynamo, workItem)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ClaimJobItem() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClaimJobItem() error = %v", err)
+			}
+			record, err := GetJobItem(ctx, dynamo, job.JobID, 0)
+			if err != nil || record == nil {
+				t.Fatalf("GetJobItem() = %v, %v", record, err)
+			}
+			if record.Status != ItemProcessing || record.Attempts != tt.wantAttempts {
+				t.Errorf("item %s after %d attempts, want processing after %d", record.Status, record.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// Without the items table only items the job has counted are detected
+func TestClaimJobItemWithoutItemsTable(t *testing.T) {
+	useJobTables(t)
+	ctx := context.Background()
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 2)
+	workItems := testWorkItems(2)
+	for i := range workItems {
+		workItems[i].JobID = job.JobID
+	}
+
+	if err := UpdateJobProgress(ctx, dynamo, job.JobID, 0, false, ReportItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimJobItem(ctx, dynamo, workItems[0]); !errors.Is(err, ErrItemAlreadyProcessed) {
+		t.Errorf("ClaimJobItem() of a counted item error = %v, want ErrItemAlreadyProcessed", err)
+	}
+	// Two deliveries of an uncounted item may both go ahead; the counters catch the second
+	for i := 0; i < 2; i++ {
+		if err := ClaimJobItem(ctx, dynamo, workItems[1]); err != nil {
+			t.Errorf("ClaimJobItem() of an uncounted item error = %v", err)
+		}
+	}
+	if n := dynamo.callCount("UpdateItem"); n != 1 {
+		t.Errorf("UpdateItem calls = %d, want only the progress update", n)
+	}
+}
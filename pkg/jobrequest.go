@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MaxStoredRequestSize bounds the JSON of a request payload kept with its job. Larger
+// payloads are not stored, so GET /jobs/{id}/request always fits in a Lambda response.
+const MaxStoredRequestSize = 5 * 1024 * 1024
+
+// ErrJobRequestNotStored is returned by GetJobRequest for a job whose request was not kept
+var ErrJobRequestNotStored = errors.New("request payload not stored for this job")
+
+// JobRequestKey returns the S3 key of a job's stored request payload. It sits under jobs/,
+// so the bucket's lifecycle rule expires it together with the job.
+func JobRequestKey(jobID string) string {
+	return "jobs/" + jobID + "/request.json.gz"
+}
+
+// StoreJobRequest keeps the payload a job was submitted with, gzipped in RESULTS_BUCKET, and
+// records its key on the job so the resources the workers were given can be looked at later.
+// Nothing is stored, with a warning, when RESULTS_BUCKET is not set or the payload is larger
+// than MaxStoredRequestSize.
+func StoreJobRequest(ctx context.Context, dynamoClient DynamoJobStore, s3Client S3RequestStore, jobID string, payload ScanPayload) error {
+	bucket := os.Getenv("RESULTS_BUCKET")
+	if bucket == "" {
+		Warnf("RESULTS_BUCKET is not set; not storing the request of job %s", jobID)
+		return nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if len(data) > MaxStoredRequestSize {
+		Warnf("Not storing the %d byte request of job %s, which is over %d bytes", len(data), jobID, MaxStoredRequestSize)
+		return nil
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("failed to compress request: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress request: %w", err)
+	}
+
+	key := JobRequestKey(jobID)
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(compressed.Bytes()),
+		ContentType: aws.String("application/gzip"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store request of job %s: %w", jobID, err)
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(os.Getenv("JOBS_TABLE")),
+		Key:              map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
+		UpdateExpression: aws.String("SET request_key = :key, updated_at = :updated_at"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key":        &types.AttributeValueMemberS{Value: key},
+			":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record request key of job %s: %w", jobID, err)
+	}
+
+	Debugf("Stored %d byte request of job %s (%d bytes compressed)", len(data), jobID, compressed.Len())
+	return nil
+}
+
+// GetJobRequest returns the JSON payload a job was submitted with, or ErrJobRequestNotStored
+func GetJobRequest(ctx context.Context, s3Client S3RequestStore, job *JobInfo) ([]byte, error) {
+	if job.RequestKey == "" {
+		return nil, ErrJobRequestNotStored
+	}
+
+	obj, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(os.Getenv("RESULTS_BUCKET")),
+		Key:    aws.String(job.RequestKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request of job %s: %w", job.JobID, err)
+	}
+	defer obj.Body.Close()
+
+	zr, err := gzip.NewReader(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress request of job %s: %w", job.JobID, err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, MaxStoredRequestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request of job %s: %w", job.JobID, err)
+	}
+	if len(data) > MaxStoredRequestSize {
+		return nil, fmt.Errorf("request of job %s is over %d bytes", job.JobID, MaxStoredRequestSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,294 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// JobResultRecord is a single processed resource stored in the results table,
+// keyed by job_id and item_index so concurrent workers never write the same item
+type JobResultRecord struct {
+	JobID          string     `dynamodbav:"job_id"`
+	ItemIndex      int        `dynamodbav:"item_index"`
+	Result         ReportItem `dynamodbav:"result"`
+	ExpirationTime int64      `dynamodbav:"expiration_time"`
+}
+
+// PutJobResult writes the result of one work item to the results table, expiring at
+// expirationTime like its job
+func PutJobResult(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int, result ReportItem, expirationTime int64) error {
+	record := JobResultRecord{
+		JobID:          jobID,
+		ItemIndex:      itemIndex,
+		Result:         result,
+		ExpirationTime: expirationTime,
+	}
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("RESULTS_TABLE")),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store result %d for job %s: %w", itemIndex, jobID, err)
+	}
+
+	return nil
+}
+
+// finishedJobResults returns the results of a job whose items have all been processed, from
+// the results table or, for a job read without them, its inline results. Results stored in
+// S3 are not read.
+func finishedJobResults(ctx context.Context, dynamoClient DynamoJobStore, job *JobInfo) ([]ReportItem, error) {
+	if os.Getenv("RESULTS_TABLE") != "" {
+		return QueryJobResults(ctx, dynamoClient, job.JobID)
+	}
+	if job.Results != nil {
+		return job.Results, nil
+	}
+	full, err := GetJob(ctx, dynamoClient, job.JobID)
+	if err != nil {
+		return nil, err
+	}
+	return full.Results, nil
+}
+
+// QueryJobResults returns every result record of a job from the results table, ordered by item index
+func QueryJobResults(ctx context.Context, dynamoClient DynamoJobStore, jobID string) ([]ReportItem, error) {
+	return QueryJobResultsRange(ctx, dynamoClient, jobID, 0, 0)
+}
+
+// QueryJobResultsRange returns the result records of a job's items offset to offset+limit-1
+// from the results table, ordered by item index. Only those records are read. A limit of 0
+// or less reads through the last item.
+func QueryJobResultsRange(ctx context.Context, dynamoClient DynamoJobStore, jobID string, offset, limit int) ([]ReportItem, error) {
+	results := make([]ReportItem, 0)
+
+	keyCondition := "job_id = :job_id"
+	values := map[string]types.AttributeValue{
+		":job_id": &types.AttributeValueMemberS{Value: jobID},
+	}
+	if offset > 0 || limit > 0 {
+		keyCondition = "job_id = :job_id AND item_index >= :first"
+		values[":first"] = &types.AttributeValueMemberN{Value: strconv.Itoa(offset)}
+		if limit > 0 {
+			keyCondition = "job_id = :job_id AND item_index BETWEEN :first AND :last"
+			values[":last"] = &types.AttributeValueMemberN{Value: strconv.Itoa(offset + limit - 1)}
+		}
+	}
+
+	// Query returns items in ascending sort key order, i.e. by item index
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("RESULTS_TABLE")),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeValues: values,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query results for job %s: %w", jobID, err)
+		}
+		for _, item := range page.Items {
+			var record JobResultRecord
+			if err := attributevalue.UnmarshalMap(item, &record); err != nil {
+				Warnf("Failed to unmarshal result record for job %s: %v", jobID, err)
+				continue
+			}
+			results = append(results, record.Result)
+		}
+	}
+
+	return results, nil
+}
+
+// RecordJobResult stores the result of a successfully processed work item and bumps the
+// job's completed counter. With RESULTS_TABLE set the result gets its own record;
+// otherwise it is appended to the job item as before. On error nothing is counted,
+// leaving the caller to retry the item or record it as failed. Items carrying a
+// fingerprint also have their result written to the analysis cache.
+func RecordJobResult(ctx context.Context, dynamoClient DynamoJobStore, workItem WorkItem, result ReportItem) error {
+	result.Fingerprint = workItem.Fingerprint
+	if err := storeJobResult(ctx, dynamoClient, workItem, result); err != nil {
+		return err
+	}
+
+	// A result that could not be cached is only analyzed again next time
+	if workItem.Fingerprint != "" && AnalysisCacheEnabled() {
+		if err := PutCachedAnalysis(ctx, dynamoClient, workItem.Fingerprint, result); err != nil {
+			Warnf("%v", err)
+		}
+	}
+	return nil
+}
+
+// storeJobResult records a result in the job without touching the analysis cache
+func storeJobResult(ctx context.Context, dynamoClient DynamoJobStore, workItem WorkItem, result ReportItem) error {
+	if os.Getenv("RESULTS_TABLE") == "" {
+		return UpdateJobProgress(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex, true, result)
+	}
+
+	if err := PutJobResult(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex, result, workItem.Expiration()); err != nil {
+		return err
+	}
+
+	return updateJobProgress(ctx, dynamoClient, workItem.JobID, workItem.ItemIndex, true, ReportItem{}, result.AnalysisUsage)
+}
+
+// GetJobResults returns the results of a job ordered by item index. Per-item records in
+// the results table take precedence; jobs written before that table existed are read from
+// their S3 results prefix or from their inline results, which are loaded if the job was
+// read without them.
+func GetJobResults(ctx context.Context, dynamoClient DynamoJobStore, s3Client S3ResultStore, job *JobInfo) ([]ReportItem, error) {
+	return GetJobResultsRange(ctx, dynamoClient, s3Client, job, 0, 0)
+}
+
+// GetJobResultsRange returns the results of a job's items offset to offset+limit-1, ordered
+// by item index, reading only those results from the results table or S3. A page holds fewer
+// results than limit where items failed or are still pending. A limit of 0 or less reads
+// through the last item. Inline results of the oldest jobs have no item indices, so they
+// are paged by position. An empty page of a job created with the results table is final;
+// only jobs created before it fall back to S3 or their inline results.
+func GetJobResultsRange(ctx context.Context, dynamoClient DynamoJobStore, s3Client S3ResultStore, job *JobInfo, offset, limit int) ([]ReportItem, error) {
+	if os.Getenv("RESULTS_TABLE") != "" {
+		results, err := QueryJobResultsRange(ctx, dynamoClient, job.JobID, offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > 0 || job.ResultsInTable {
+			return results, nil
+		}
+	}
+
+	if job.ResultsPrefix != "" {
+		return getS3JobResults(ctx, s3Client, job, offset, limit)
+	}
+	if job.Results == nil {
+		full, err := GetJob(ctx, dynamoClient, job.JobID)
+		if err != nil {
+			return nil, err
+		}
+		job.Results = full.Results
+	}
+	if offset >= len(job.Results) {
+		return []ReportItem{}, nil
+	}
+	end := len(job.Results)
+	if limit > 0 {
+		end = min(offset+limit, end)
+	}
+	return job.Results[offset:end], nil
+}
+
+// getS3JobResults assembles the results of a job that stored them as objects under its S3
+// results prefix, fetching only the objects of items offset to offset+limit-1
+func getS3JobResults(ctx context.Context, s3Client S3ResultStore, job *JobInfo, offset, limit int) ([]ReportItem, error) {
+
+	bucket := aws.String(os.Getenv("RESULTS_BUCKET"))
+
+	// Collect the result keys and their item indices
+	type resultKey struct {
+		index int
+		key   string
+	}
+	var keys []resultKey
+
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: bucket,
+		Prefix: aws.String(job.ResultsPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list results for job %s: %w", job.JobID, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			index, err := strconv.Atoi(strings.TrimSuffix(path.Base(key), ".json"))
+			if err != nil {
+				Warnf("Skipping unexpected result object %s", key)
+				continue
+			}
+			if index < offset || (limit > 0 && index >= offset+limit) {
+				continue
+			}
+			keys = append(keys, resultKey{index: index, key: key})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].index < keys[j].index })
+
+	// Fetch the objects concurrently, keeping them in index order
+	items := make([]ReportItem, len(keys))
+	loaded := make([]bool, len(keys))
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 5)
+
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			item, err := readJobResult(ctx, s3Client, bucket, key)
+			if err != nil {
+				Warnf("%v", err)
+				return
+			}
+			items[i] = item
+			loaded[i] = true
+		}(i, k.key)
+	}
+	wg.Wait()
+
+	results := make([]ReportItem, 0, len(items))
+	for i, item := range items {
+		if loaded[i] {
+			results = append(results, item)
+		}
+	}
+
+	Infof("Loaded %d of %d results for job %s from S3", len(results), len(keys), job.JobID)
+	return results, nil
+}
+
+// readJobResult fetches and decodes a single result object
+func readJobResult(ctx context.Context, s3Client S3ResultStore, bucket *string, key string) (ReportItem, error) {
+	var item ReportItem
+
+	obj, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: bucket,
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return item, fmt.Errorf("failed to get result %s: %w", key, err)
+	}
+	defer obj.Body.Close()
+
+	data, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return item, fmt.Errorf("failed to read result %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return item, fmt.Errorf("failed to parse result %s: %w", key, err)
+	}
+
+	return item, nil
+}
@@ -0,0 +1,247 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// putS3Results stores a result object for each index under the job's results prefix
+func putS3Results(t *testing.T, store *fakeS3Objects, job *JobInfo, indices ...int) {
+	t.Helper()
+	for _, index := range indices {
+		data, err := json.Marshal(ReportItem{Instance: Instance{InstanceID: fmt.Sprintf("i-%d", index)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.objects[fmt.Sprintf("%s%d.json", job.ResultsPrefix, index)] = data
+	}
+}
+
+// resultIDs returns the instance IDs of results, joined for comparison
+func resultIDs(results []ReportItem) string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Instance.InstanceID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestGetJobResultsFromS3(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset, limit int
+		failGet       []int
+		want          string
+		wantGets      int
+	}{
+		// 10.json lists before 2.json, but results come back in index order
+		{name: "all results", want: "i-0,i-1,i-2,i-10", wantGets: 4},
+		{name: "range", offset: 1, limit: 2, want: "i-1,i-2", wantGets: 2},
+		{name: "range past the end", offset: 11, limit: 5, want: "", wantGets: 0},
+		{name: "unreadable object is skipped", failGet: []int{1}, want: "i-0,i-2,i-10", wantGets: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			t.Setenv("RESULTS_BUCKET", "results")
+			job := &JobInfo{JobID: "job-1", ResultsPrefix: "jobs/job-1/results/"}
+			store := newFakeS3Objects()
+			store.pageSize = 2
+			putS3Results(t, store, job, 0, 1, 2, 10)
+			store.objects[job.ResultsPrefix+"manifest.txt"] = []byte("not a result")
+			// Another job's results share the bucket
+			putS3Results(t, store, &JobInfo{ResultsPrefix: "jobs/job-2/results/"}, 0)
+			for _, index := range tt.failGet {
+				store.failGet[fmt.Sprintf("%s%d.json", job.ResultsPrefix, index)] = true
+			}
+			dynamo := newFakeDynamo()
+
+			results, err := GetJobResultsRange(context.Background(), dynamo, store, job, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("GetJobResultsRange() error = %v", err)
+			}
+			if got := resultIDs(results); got != tt.want {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+			if store.getCalls != tt.wantGets {
+				t.Errorf("GetObject calls = %d, want %d", store.getCalls, tt.wantGets)
+			}
+			if dynamo.callCount("GetItem") != 0 {
+				t.Errorf("read the job item for results stored in S3")
+			}
+		})
+	}
+}
+
+// Jobs from before results moved out of the job item still return their inline results
+func TestGetJobResultsInline(t *testing.T) {
+	useJobTables(t)
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 3)
+	for i := 0; i < 3; i++ {
+		result := ReportItem{Instance: Instance{InstanceID: fmt.Sprintf("i-%d", i)}, Analysis: "ok"}
+		if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, i, true, result); err != nil {
+			t.Fatalf("UpdateJobProgress() error = %v", err)
+		}
+	}
+	store := newFakeS3Objects()
+
+	// A job read without its results has them loaded
+	light, err := GetJobWith(context.Background(), dynamo, job.JobID, JobReadOptions{WithoutResults: true})
+	if err != nil {
+		t.Fatalf("GetJobWith() error = %v", err)
+	}
+	if light.Results != nil {
+		t.Fatalf("job read without results has %d results", len(light.Results))
+	}
+	gets := dynamo.callCount("GetItem")
+	results, err := GetJobResults(context.Background(), dynamo, store, light)
+	if err != nil {
+		t.Fatalf("GetJobResults() error = %v", err)
+	}
+	if got := resultIDs(results); got != "i-0,i-1,i-2" {
+		t.Errorf("results = %q", got)
+	}
+	if dynamo.callCount("GetItem") != gets+1 {
+		t.Errorf("GetItem calls = %d, want one to load the results", dynamo.callCount("GetItem")-gets)
+	}
+
+	// A job read with its results pages them by position without further reads
+	full, err := GetJob(context.Background(), dynamo, job.JobID)
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	gets = dynamo.callCount("GetItem")
+	page, err := GetJobResultsRange(context.Background(), dynamo, store, full, 1, 5)
+	if err != nil {
+		t.Fatalf("GetJobResultsRange() error = %v", err)
+	}
+	if got := resultIDs(page); got != "i-1,i-2" {
+		t.Errorf("page = %q, want i-1,i-2", got)
+	}
+	if dynamo.callCount("GetItem") != gets || store.listCalls != 0 {
+		t.Errorf("paging inline results made %d GetItem and %d ListObjectsV2 calls", dynamo.callCount("GetItem")-gets, store.listCalls)
+	}
+}
+
+// useResultsTable points RESULTS_TABLE at the fake's results table
+func useResultsTable(t *testing.T) {
+	t.Helper()
+	useJobTables(t)
+	t.Setenv("RESULTS_TABLE", testResultsTable)
+}
+
+// putTableResults stores a result record for each index of job-1 and another job's results
+func putTableResults(t *testing.T, dynamo *fakeDynamo, indices ...int) {
+	t.Helper()
+	for _, index := range indices {
+		for _, jobID := range []string{"job-1", "job-2"} {
+			result := ReportItem{Instance: Instance{InstanceID: fmt.Sprintf("i-%d", index)}}
+			if jobID == "job-2" {
+				result.Instance.InstanceID = "other"
+			}
+			if err := PutJobResult(context.Background(), dynamo, jobID, index, result, 0); err != nil {
+				t.Fatalf("PutJobResult() error = %v", err)
+			}
+		}
+	}
+}
+
+func TestQueryJobResultsRange(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          string
+	}{
+		{name: "all results", want: "i-0,i-1,i-2,i-4,i-10"},
+		{name: "from an offset", offset: 2, want: "i-2,i-4,i-10"},
+		// Item 3 failed, so the page is one short
+		{name: "range", offset: 2, limit: 3, want: "i-2,i-4"},
+		{name: "first page", limit: 2, want: "i-0,i-1"},
+		{name: "past the end", offset: 11, limit: 5, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useResultsTable(t)
+			dynamo := newFakeDynamo()
+			putTableResults(t, dynamo, 10, 4, 2, 1, 0)
+
+			results, err := QueryJobResultsRange(context.Background(), dynamo, "job-1", tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("QueryJobResultsRange() error = %v", err)
+			}
+			if results == nil {
+				t.Fatal("QueryJobResultsRange() returned nil, want an empty page")
+			}
+			if got := resultIDs(results); got != tt.want {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Only jobs created before the results table fall back to S3 when it has no results for them
+func TestGetJobResultsRangeFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		inTable   bool
+		tableRows []int
+		want      string
+		wantLists int
+	}{
+		{name: "results in the table", inTable: true, tableRows: []int{0, 1}, want: "i-0,i-1"},
+		{name: "empty page of a table job is final", inTable: true, want: ""},
+		{name: "older job falls back to S3", want: "s3-0,s3-1", wantLists: 1},
+		{name: "older job with table results", tableRows: []int{0}, want: "i-0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useResultsTable(t)
+			t.Setenv("RESULTS_BUCKET", "results")
+			dynamo := newFakeDynamo()
+			putTableResults(t, dynamo, tt.tableRows...)
+			job := &JobInfo{JobID: "job-1", ResultsPrefix: "jobs/job-1/results/", ResultsInTable: tt.inTable}
+			store := newFakeS3Objects()
+			for i := 0; i < 2; i++ {
+				data, err := json.Marshal(ReportItem{Instance: Instance{InstanceID: fmt.Sprintf("s3-%d", i)}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				store.objects[fmt.Sprintf("%s%d.json", job.ResultsPrefix, i)] = data
+			}
+
+			results, err := GetJobResultsRange(context.Background(), dynamo, store, job, 0, 10)
+			if err != nil {
+				t.Fatalf("GetJobResultsRange() error = %v", err)
+			}
+			if got := resultIDs(results); got != tt.want {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+			if store.listCalls != tt.wantLists {
+				t.Errorf("ListObjectsV2 calls = %d, want %d", store.listCalls, tt.wantLists)
+			}
+		})
+	}
+}
+
+func TestGetJobResultsRangeQueryError(t *testing.T) {
+	useResultsTable(t)
+	dynamo := newFakeDynamo()
+	dynamo.fail("Query", errors.New("ProvisionedThroughputExceededException"))
+	job := &JobInfo{JobID: "job-1", ResultsPrefix: "jobs/job-1/results/"}
+	store := newFakeS3Objects()
+
+	if _, err := GetJobResultsRange(context.Background(), dynamo, store, job, 0, 10); err == nil {
+		t.Fatal("GetJobResultsRange() error = nil, want the query error")
+	}
+	if store.listCalls != 0 {
+		t.Errorf("fell back to S3 after a failed query")
+	}
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxJobRetries is how many times the failed items of one job may be queued again
+const MaxJobRetries = 3
+
+// ErrJobNotRetryable is returned by CheckJobRetryable, and by RetryFailedItems when another
+// retry won the race
+var ErrJobNotRetryable = errors.New("job cannot be retried")
+
+// CheckJobRetryable returns an error wrapping ErrJobExpired or ErrJobNotRetryable, with the
+// reason, unless the job has finished with failed items that may be queued again
+func CheckJobRetryable(job *JobInfo) error {
+	if err := checkJobExpiry(job); err != nil {
+		return err
+	}
+	switch {
+	case job.Status != JobStatusCompleted && job.Status != JobStatusFailed:
+		return fmt.Errorf("%w: it is %s; only completed and failed jobs are retried", ErrJobNotRetryable, job.Status)
+	case job.FailedItems == 0:
+		return fmt.Errorf("%w: it has no failed items", ErrJobNotRetryable)
+	case len(job.FailedIndices) == 0:
+		return fmt.Errorf("%w: which of its items failed was not recorded", ErrJobNotRetryable)
+	case job.RetryCount >= MaxJobRetries:
+		return fmt.Errorf("%w: it was already retried %d times", ErrJobNotRetryable, job.RetryCount)
+	}
+	return nil
+}
+
+// RetryFailedItems queues the failed items of a finished job again, rebuilding them from the
+// request payload kept by StoreJobRequest. The job moves back to processing, its failed count
+// drops by the items queued and its retry count goes up; the transition is conditional on the
+// job being unchanged, so of two concurrent retries only one queues anything. Items that
+// cannot be queued are recorded as failed again. It returns the number of items queued.
+func RetryFailedItems(ctx context.Context, dynamoClient DynamoJobStore, sqsClient SQSQueueAPI, s3Client S3RequestStore, job *JobInfo) (int, error) {
+	if err := CheckJobRetryable(job); err != nil {
+		return 0, err
+	}
+
+	// Rebuild the failed items before touching the job, so a missing payload changes nothing
+	data, err := GetJobRequest(ctx, s3Client, job)
+	if err != nil {
+		return 0, err
+	}
+	var payload ScanPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return 0, fmt.Errorf("failed to parse request of job %s: %w", job.JobID, err)
+	}
+	failed := make(map[int]bool, len(job.FailedIndices))
+	for _, index := range job.FailedIndices {
+		failed[index] = true
+	}
+	var workItems []WorkItem
+	for _, workItem := range payload.WorkItems(job.JobID, job.ExpirationTime) {
+		if failed[workItem.ItemIndex] {
+			workItems = append(workItems, workItem)
+		}
+	}
+	if len(workItems) == 0 {
+		return 0, fmt.Errorf("%w: its failed items are not in its stored request", ErrJobNotRetryable)
+	}
+
+	// Retried items are analyzed afresh, and their new analyses replace any cached ones
+	if AnalysisCacheEnabled() {
+		workItems, _ = ResolveCachedWorkItems(ctx, dynamoClient, workItems, true)
+	}
+
+	if err := reopenFailedItems(ctx, dynamoClient, job, workItems); err != nil {
+		return 0, err
+	}
+	Infof("Retrying %d failed items of job %s (retry %d of %d)", len(workItems), job.JobID, job.RetryCount+1, MaxJobRetries)
+	for _, workItem := range workItems {
+		if err := UpdateJobItem(ctx, dynamoClient, workItem, ItemQueued, "", 0); err != nil {
+			Warnf("%v", err)
+		}
+	}
+
+	// Items that never reach the queue are failures again, so the job can still finish
+	failures := QueueWorkItems(ctx, sqsClient, workItems)
+	byIndex := make(map[int]WorkItem, len(workItems))
+	for _, workItem := range workItems {
+		byIndex[workItem.ItemIndex] = workItem
+	}
+	for _, failure := range failures {
+		Errorf("Failed to queue work item %d for retry: %v", failure.ItemIndex, failure.Err)
+		if err := UpdateJobItem(ctx, dynamoClient, byIndex[failure.ItemIndex], ItemFailed, "not queued: "+failure.Err.Error(), 0); err != nil {
+			Warnf("%v", err)
+		}
+		if err := UpdateJobProgress(ctx, dynamoClient, job.JobID, failure.ItemIndex, false, ReportItem{}); err != nil {
+			Warnf("%v", err)
+		}
+	}
+	if len(failures) == len(workItems) {
+		if err := MaybeFinalizeJob(ctx, dynamoClient, job.JobID); err != nil {
+			Warnf("Failed to finalize job %s: %v", job.JobID, err)
+		}
+	}
+	return len(workItems) - len(failures), nil
+}
+
+// reopenFailedItems moves a finished job back to processing with the given items no longer
+// counted as processed or failed, provided its status and retry count are still those of job
+func reopenFailedItems(ctx context.Context, dynamoClient DynamoJobStore, job *JobInfo, workItems []WorkItem) error {
+	indices := make([]string, len(workItems))
+	for i, workItem := range workItems {
+		indices[i] = strconv.Itoa(workItem.ItemIndex)
+	}
+	sort.Strings(indices)
+
+	condition := "#status = :finished AND "
+	values := map[string]types.AttributeValue{
+		":processing": &types.AttributeValueMemberS{Value: string(JobStatusProcessing)},
+		":finished":   &types.AttributeValueMemberS{Value: string(job.Status)},
+		":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		":retried":    &types.AttributeValueMemberN{Value: strconv.Itoa(len(workItems))},
+		":one":        &types.AttributeValueMemberN{Value: "1"},
+		":indices":    &types.AttributeValueMemberNS{Value: indices},
+	}
+	if job.RetryCount == 0 {
+		condition += "attribute_not_exists(retry_count)"
+	} else {
+		condition += "retry_count = :retry_count"
+		values[":retry_count"] = &types.AttributeValueMemberN{Value: strconv.Itoa(job.RetryCount)}
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("JOBS_TABLE")),
+		Key:       map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: job.JobID}},
+		UpdateExpression: aws.String("SET #status = :processing, updated_at = :updated_at, failed_items = failed_items - :retried " +
+			"REMOVE completed_at ADD retry_count :one DELETE processed_items :indices, failed_indices :indices"),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: values,
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return fmt.Errorf("%w: it changed while being retried", ErrJobNotRetryable)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reopen job %s: %w", job.JobID, err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// failedJob creates a finished job of three instances whose request is stored in s3 and whose
+// items failIdx failed, recorded in the items table
+func failedJob(t *testing.T, dynamo *fakeDynamo, s3Client *fakeS3Objects, failIdx ...int) *JobInfo {
+	t.Helper()
+	ctx := context.Background()
+	t.Setenv("ITEMS_TABLE", testItemsTable)
+	t.Setenv("RESULTS_BUCKET", "results")
+
+	payload := ScanPayload{Instances: []Instance{{InstanceID: "i-0"}, {InstanceID: "i-1"}, {InstanceID: "i-2"}}}
+	job, _ := newDeliveryJob(t, dynamo, 3)
+	if err := StoreJobRequest(ctx, dynamo, s3Client, job.JobID, payload); err != nil {
+		t.Fatalf("StoreJobRequest() error = %v", err)
+	}
+	for _, workItem := range payload.WorkItems(job.JobID, job.ExpirationTime) {
+		deliverWorkItem(t, dynamo, workItem, !slices.Contains(failIdx, workItem.ItemIndex))
+	}
+
+	finished, err := GetJob(ctx, dynamo, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Status.IsTerminal() {
+		t.Fatalf("job is %s after every item was delivered", finished.Status)
+	}
+	return finished
+}
+
+// queuedIndices returns the item indices of the work items sent to sqs
+func queuedIndices(t *testing.T, sqs *fakeSQS) []int {
+	t.Helper()
+	sqs.mu.Lock()
+	defer sqs.mu.Unlock()
+	var indices []int
+	for _, body := range sqs.messages {
+		var workItem WorkItem
+		if err := json.Unmarshal([]byte(body), &workItem); err != nil {
+			t.Fatalf("queued message is not a work item: %v", err)
+		}
+		indices = append(indices, workItem.ItemIndex)
+	}
+	return indices
+}
+
+func TestRetryFailedItems(t *testing.T) {
+	useJobTables(t)
+	ctx := context.Background()
+	dynamo, s3Client, sqs := newFakeDynamo(), newFakeS3Objects(), &fakeSQS{}
+	job := failedJob(t, dynamo, s3Client, 1, 2)
+	if job.Status != JobStatusCompleted || job.FailedItems != 2 || !slices.Equal(job.FailedIndices, []int{1, 2}) {
+		t.Fatalf("job %s with %d failed items %v, want completed with items 1 and 2 failed", job.Status, job.FailedItems, job.FailedIndices)
+	}
+
+	queued, err := RetryFailedItems(ctx, dynamo, sqs, s3Client, job)
+	if err != nil {
+		t.Fatalf("RetryFailedItems() error = %v", err)
+	}
+	if queued != 2 {
+		t.Errorf("RetryFailedItems() = %d, want 2", queued)
+	}
+	if got := queuedIndices(t, sqs); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("queued items %v, want [1 2]", got)
+	}
+
+	reopened, err := GetJob(ctx, dynamo, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Status != JobStatusProcessing || reopened.FailedItems != 0 || reopened.CompletedItems != 1 || reopened.RetryCount != 1 {
+		t.Errorf("reopened job %s, completed %d, failed %d, retry %d; want processing, 1, 0, 1",
+			reopened.Status, reopened.CompletedItems, reopened.FailedItems, reopened.RetryCount)
+	}
+	if len(reopened.FailedIndices) != 0 || reopened.CompletedAt != 0 {
+		t.Errorf("reopened job keeps failed indices %v and completed_at %d", reopened.FailedIndices, reopened.CompletedAt)
+	}
+	for _, index := range []int{1, 2} {
+		record, err := GetJobItem(ctx, dynamo, job.JobID, index)
+		if err != nil || record == nil || record.Status != ItemQueued {
+			t.Errorf("item %d record = %+v, %v; want queued", index, record, err)
+		}
+	}
+
+	// A second retry from the same snapshot of the job loses the race
+	if _, err := RetryFailedItems(ctx, dynamo, sqs, s3Client, job); !errors.Is(err, ErrJobNotRetryable) {
+		t.Errorf("second RetryFailedItems() error = %v, want ErrJobNotRetryable", err)
+	}
+	if got := queuedIndices(t, sqs); len(got) != 2 {
+		t.Errorf("queued %d items after the lost race, want still 2", len(got))
+	}
+
+	// The retried items are processed once more, and the job completes without failures
+	payload := ScanPayload{Instances: []Instance{{InstanceID: "i-0"}, {InstanceID: "i-1"}, {InstanceID: "i-2"}}}
+	for _, workItem := range payload.WorkItems(job.JobID, job.ExpirationTime)[1:] {
+		if !deliverWorkItem(t, dynamo, workItem, true) {
+			t.Fatalf("retried item %d was dropped as already processed", workItem.ItemIndex)
+		}
+	}
+	done, err := GetJob(ctx, dynamo, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Status != JobStatusCompleted || done.CompletedItems != 3 || done.FailedItems != 0 {
+		t.Errorf("job %s with %d completed and %d failed, want completed with 3 and 0", done.Status, done.CompletedItems, done.FailedItems)
+	}
+}
+
+// Items SQS refuses are failures again, and the job finishes instead of waiting for them
+func TestRetryFailedItemsQueueFailure(t *testing.T) {
+	useJobTables(t)
+	ctx := context.Background()
+	dynamo, s3Client := newFakeDynamo(), newFakeS3Objects()
+	sqs := &fakeSQS{err: errors.New("QueueDoesNotExist")}
+	job := failedJob(t, dynamo, s3Client, 0, 1, 2)
+	if job.Status != JobStatusFailed {
+		t.Fatalf("job is %s, want failed", job.Status)
+	}
+
+	queued, err := RetryFailedItems(ctx, dynamo, sqs, s3Client, job)
+	if err != nil {
+		t.Fatalf("RetryFailedItems() error = %v", err)
+	}
+	if queued != 0 {
+		t.Errorf("RetryFailedItems() = %d, want 0", queued)
+	}
+	got, err := GetJob(ctx, dynamo, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobStatusFailed || got.FailedItems != 3 || got.RetryCount != 1 {
+		t.Errorf("job %s with %d failed items after retry %d, want failed with 3 after retry 1", got.Status, got.FailedItems, got.RetryCount)
+	}
+	record, err := GetJobItem(ctx, dynamo, job.JobID, 0)
+	if err != nil || record == nil || record.Status != ItemFailed {
+		t.Errorf("item record = %+v, %v; want failed", record, err)
+	}
+}
+
+func TestCheckJobRetryable(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	retryable := JobInfo{JobID: "job-1", Status: JobStatusCompleted, FailedItems: 1, FailedIndices: []int{0}, ExpirationTime: future}
+
+	tests := []struct {
+		name    string
+		change  func(job *JobInfo)
+		wantErr error
+	}{
+		{name: "completed with failures", change: func(job *JobInfo) {}},
+		{name: "failed", change: func(job *JobInfo) { job.Status = JobStatusFailed }},
+		{name: "last retry left", change: func(job *JobInfo) { job.RetryCount = MaxJobRetries - 1 }},
+		{name: "processing", change: func(job *JobInfo) { job.Status = JobStatusProcessing }, wantErr: ErrJobNotRetryable},
+		{name: "cancelled", change: func(job *JobInfo) { job.Status = JobStatusCancelled }, wantErr: ErrJobNotRetryable},
+		{name: "no failures", change: func(job *JobInfo) { job.FailedItems = 0 }, wantErr: ErrJobNotRetryable},
+		{name: "failures not recorded", change: func(job *JobInfo) { job.FailedIndices = nil }, wantErr: ErrJobNotRetryable},
+		{name: "retries used up", change: func(job *JobInfo) { job.RetryCount = MaxJobRetries }, wantErr: ErrJobNotRetryable},
+		{name: "expired", change: func(job *JobInfo) { job.ExpirationTime = time.Now().Add(-time.Minute).Unix() }, wantErr: ErrJobExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := retryable
+			tt.change(&job)
+			err := CheckJobRetryable(&job)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("CheckJobRetryable() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckJobRetryable() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A job whose request cannot be rebuilt is left exactly as it was
+func TestRetryFailedItemsWithoutRequest(t *testing.T) {
+	useJobTables(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		prepare func(job *JobInfo, s3Client *fakeS3Objects)
+		wantErr error
+	}{
+		{name: "never stored", prepare: func(job *JobInfo, s3Client *fakeS3Objects) { job.RequestKey = "" }, wantErr: ErrJobRequestNotStored},
+		{name: "deleted", prepare: func(job *JobInfo, s3Client *fakeS3Objects) { delete(s3Client.objects, job.RequestKey) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamo, s3Client, sqs := newFakeDynamo(), newFakeS3Objects(), &fakeSQS{}
+			job := failedJob(t, dynamo, s3Client, 1)
+			tt.prepare(job, s3Client)
+			updatesBefore := dynamo.callCount("UpdateItem")
+
+			_, err := RetryFailedItems(ctx, dynamo, sqs, s3Client, job)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("RetryFailedItems() error = %v, want %v", err, tt.wantErr)
+			}
+			if n := dynamo.callCount("UpdateItem") - updatesBefore; n != 0 {
+				t.Errorf("made %d updates for a job that cannot be retried", n)
+			}
+			if len(sqs.messages) != 0 {
+				t.Errorf("queued %d items", len(sqs.messages))
+			}
+		})
+	}
+}
@@ -3,10 +3,14 @@ package pkg
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +20,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/google/uuid"
 )
 
@@ -27,8 +32,14 @@ const (
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusCancelled  JobStatus = "cancelled"
 )
 
+// IsTerminal reports whether a job in this status will no longer change
+func (s JobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
 // JobInfo represents a job record in DynamoDB
 type JobInfo struct {
 	JobID          string       `json:"job_id" dynamodbav:"job_id"`
@@ -39,29 +50,164 @@ type JobInfo struct {
 	TotalItems     int          `json:"total_items" dynamodbav:"total_items"`
 	CompletedItems int          `json:"completed_items" dynamodbav:"completed_items"`
 	FailedItems    int          `json:"failed_items" dynamodbav:"failed_items"`
+	SkippedItems   int          `json:"skipped_items" dynamodbav:"skipped_items"`
 	Results        []ReportItem `json:"results,omitempty" dynamodbav:"results,omitempty"`
+	ResultsPrefix  string       `json:"results_prefix,omitempty" dynamodbav:"results_prefix,omitempty"`
+	ResultsInTable bool         `json:"-" dynamodbav:"results_in_table,omitempty"`                // results are records of the results table; see GetJobResultsRange
+	RequestKey     string       `json:"request_key,omitempty" dynamodbav:"request_key,omitempty"` // S3 key of the stored request payload; see StoreJobRequest
+	FailedIndices  []int        `json:"-" dynamodbav:"failed_indices,omitempty,numberset"`        // indices of the items that failed, for RetryFailedItems
+	RetryCount     int          `json:"retry_count,omitempty" dynamodbav:"retry_count,omitempty"`
+	TTLDays        int          `json:"ttl_days,omitempty" dynamodbav:"ttl_days,omitempty"` // retention the job was created with; see JobTTLDays
 	ResourceTypes  []string     `json:"resource_types" dynamodbav:"resource_types"`
 	ExpirationTime int64        `json:"expiration_time" dynamodbav:"expiration_time"`
+	Owner          string       `json:"-" dynamodbav:"owner,omitempty"` // CallerIdentity of the submitter, empty for anonymous jobs
+	AnalysisUsage               // Bedrock usage of the job's completed items, added up by UpdateJobProgress
+	// AccountRecommendations is written by MaybeFinalizeJob when SetAccountAnalyzer was called
+	AccountRecommendations *AccountRecommendations `json:"account_recommendations,omitempty" dynamodbav:"account_recommendations,omitempty"`
+	// Metadata is the scan's ReportMetadata as the client submitted it, nil when it sent none
+	Metadata *ReportMetadata `json:"metadata,omitempty" dynamodbav:"metadata,omitempty"`
+}
+
+// ParseJobStatus returns the job status named by s
+func ParseJobStatus(s string) (JobStatus, error) {
+	switch status := JobStatus(s); status {
+	case JobStatusPending, JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown job status %q (expected pending, processing, completed, failed or cancelled)", s)
+}
+
+// ErrJobAccessDenied is returned by CheckJobAccess when a job belongs to another caller
+var ErrJobAccessDenied = errors.New("job belongs to another caller")
+
+// Errors returned for jobs that cannot be read or changed; callers match them with errors.Is
+var (
+	ErrJobNotFound        = errors.New("job not found")
+	ErrJobExpired         = errors.New("job has expired")
+	ErrJobAlreadyFinished = errors.New("job already finished")
+)
+
+// JobStoreError is a DynamoDB call on a job that failed, as opposed to a job that does not exist
+type JobStoreError struct {
+	Op    string // what was being done, e.g. "get job"
+	JobID string
+	Err   error
+}
+
+func (e *JobStoreError) Error() string {
+	return fmt.Sprintf("failed to %s %s: %v", e.Op, e.JobID, e.Err)
+}
+
+func (e *JobStoreError) Unwrap() error {
+	return e.Err
+}
+
+// checkJobExpiry returns an error wrapping ErrJobExpired for a job past its TTL. DynamoDB
+// deletes expired items within a day or two, so they can still be read for a while.
+func checkJobExpiry(job *JobInfo) error {
+	if job.ExpirationTime > 0 && time.Now().Unix() >= job.ExpirationTime {
+		return fmt.Errorf("%w: job %s expired at %s", ErrJobExpired, job.JobID, time.Unix(job.ExpirationTime, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
 }
 
 // WorkItem represents a single task to be processed
 type WorkItem struct {
-	JobID       string      `json:"job_id"`
-	ItemIndex   int         `json:"item_index"`
-	ItemType    string      `json:"item_type"`
-	Instance    Instance    `json:"instance,omitempty"`
-	S3Bucket    S3Bucket    `json:"s3_bucket,omitempty"`
-	RDSInstance RDSInstance `json:"rds_instance,omitempty"`
-	// Add other resource types here later (EBS, etc.)
+	JobID           string             `json:"job_id"`
+	ItemIndex       int                `json:"item_index"`
+	ItemType        string             `json:"item_type"`
+	Instance        Instance           `json:"instance,omitempty"`
+	S3Bucket        S3Bucket           `json:"s3_bucket,omitempty"`
+	RDSInstance     RDSInstance        `json:"rds_instance,omitempty"`
+	EBSVolume       EBSVolume          `json:"ebs_volume,omitempty"`
+	LambdaFunction  LambdaFunction     `json:"lambda_function,omitempty"`
+	LoadBalancer    LoadBalancer       `json:"load_balancer,omitempty"`
+	NetworkResource NetworkResource    `json:"network_resource,omitempty"`
+	DynamoTable     DynamoTable        `json:"dynamo_table,omitempty"`
+	ElastiCache     ElastiCacheCluster `json:"elasticache_cluster,omitempty"`
+	Snapshots       []EBSSnapshot      `json:"snapshots,omitempty"`   // all stale snapshots, analyzed as one item
+	Fingerprint     string             `json:"fingerprint,omitempty"` // set when the analysis cache is on; see ResourceFingerprint
+	ExpiresAt       int64              `json:"expires_at,omitempty"`  // when the job expires, in Unix seconds; see Expiration
+	Model           string             `json:"model,omitempty"`       // model the request asked for; see GenerationModel
+	// Add other resource types here later
+}
+
+// Expiration returns when the records of the item's result and status expire, in Unix
+// seconds: with its job, or DefaultJobTTLDays from now for items queued by an older API
+func (w WorkItem) Expiration() int64 {
+	if w.ExpiresAt > 0 {
+		return w.ExpiresAt
+	}
+	return time.Now().Add(DefaultJobTTLDays * 24 * time.Hour).Unix()
 }
 
-// CreateJob creates a new job record in DynamoDB
-func CreateJob(ctx context.Context, dynamoClient *dynamodb.Client, resourceTypes []string, itemCount int) (string, error) {
+// GenerationModel returns the inference profile or model that analyzes the item: the one
+// its request asked for, else the one ResolveModelID routes its type to
+func (w WorkItem) GenerationModel() string {
+	if w.Model != "" {
+		return w.Model
+	}
+	return ResolveModelID(w.ItemType)
+}
+
+// ResourceID returns the identifier of the resource carried by the work item
+func (w WorkItem) ResourceID() string {
+	switch w.ItemType {
+	case "ec2":
+		return w.Instance.InstanceID
+	case "s3":
+		return w.S3Bucket.BucketName
+	case "rds":
+		return w.RDSInstance.InstanceID
+	case "ebs":
+		return w.EBSVolume.VolumeID
+	case "lambda":
+		return w.LambdaFunction.FunctionName
+	case "elb":
+		return w.LoadBalancer.Name
+	case "network":
+		return w.NetworkResource.ResourceID
+	case "dynamodb":
+		return w.DynamoTable.TableName
+	case "elasticache":
+		return w.ElastiCache.ClusterID
+	case "snapshots":
+		return fmt.Sprintf("%d snapshots", len(w.Snapshots))
+	}
+	return ""
+}
+
+// Retention of jobs, with their results, item records and stored requests, in days: JOB_TTL_DAYS
+// on the API, or ttl_days in an analyze request, within MinJobTTLDays and MaxJobTTLDays
+const (
+	DefaultJobTTLDays = 7
+	MinJobTTLDays     = 1
+	MaxJobTTLDays     = 90
+)
+
+// JobTTLDays returns the retention of a job whose request asked for requested days, 0 for
+// none: requested, or JOB_TTL_DAYS, or DefaultJobTTLDays when that is unset or invalid.
+// Requests are checked against the range by ValidateRequest.
+func JobTTLDays(requested int) int {
+	if requested > 0 {
+		return requested
+	}
+	days := DefaultJobTTLDays
+	if value := os.Getenv("JOB_TTL_DAYS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= MinJobTTLDays && n <= MaxJobTTLDays {
+			days = n
+		} else {
+			Warnf("Ignoring JOB_TTL_DAYS %q, which is not between %d and %d, using %d days", value, MinJobTTLDays, MaxJobTTLDays, DefaultJobTTLDays)
+		}
+	}
+	return days
+}
+
+// CreateJob creates a new job record in DynamoDB that expires after ttlDays
+func CreateJob(ctx context.Context, dynamoClient DynamoJobStore, resourceTypes []string, itemCount int, owner string, ttlDays int, metadata *ReportMetadata) (*JobInfo, error) {
 	jobID := uuid.New().String()
 	now := time.Now().Unix()
-
-	// TTL of 7 days
-	expirationTime := now + (7 * 24 * 60 * 60)
+	expirationTime := now + int64(ttlDays)*24*60*60
 
 	job := JobInfo{
 		JobID:          jobID,
@@ -71,14 +217,25 @@ func CreateJob(ctx context.Context, dynamoClient *dynamodb.Client, resourceTypes
 		TotalItems:     itemCount,
 		CompletedItems: 0,
 		FailedItems:    0,
+		SkippedItems:   0,
 		ResourceTypes:  resourceTypes,
 		ExpirationTime: expirationTime,
-		Results:        make([]ReportItem, 0),
+		TTLDays:        ttlDays,
+		Owner:          owner,
+		Metadata:       metadata,
+	}
+
+	// Results get their own records when a results table is configured; otherwise they
+	// are appended to the job item itself, which only works for small jobs
+	if os.Getenv("RESULTS_TABLE") == "" {
+		job.Results = make([]ReportItem, 0)
+	} else {
+		job.ResultsInTable = true
 	}
 
 	item, err := attributevalue.MarshalMap(job)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal job: %w", err)
+		return nil, fmt.Errorf("failed to marshal job: %w", err)
 	}
 
 	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
@@ -87,15 +244,15 @@ func CreateJob(ctx context.Context, dynamoClient *dynamodb.Client, resourceTypes
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to save job: %w", err)
+		return nil, fmt.Errorf("failed to save job: %w", err)
 	}
 
-	log.Printf("Created job %s with %d items", jobID, itemCount)
-	return jobID, nil
+	Infof("Created job %s with %d items, kept for %d days", jobID, itemCount, ttlDays)
+	return &job, nil
 }
 
 // QueueWorkItem adds a work item to the SQS queue
-func QueueWorkItem(ctx context.Context, sqsClient *sqs.Client, jobID string, itemIndex int, itemType string, workItem WorkItem) error {
+func QueueWorkItem(ctx context.Context, sqsClient SQSQueueAPI, jobID string, itemIndex int, itemType string, workItem WorkItem) error {
 	// Set the job ID and other metadata
 	workItem.JobID = jobID
 	workItem.ItemIndex = itemIndex
@@ -118,8 +275,89 @@ func QueueWorkItem(ctx context.Context, sqsClient *sqs.Client, jobID string, ite
 	return nil
 }
 
-// UpdateJobStatus updates the status of a job in DynamoDB
-func UpdateJobStatus(ctx context.Context, dynamoClient *dynamodb.Client, jobID string, status JobStatus) error {
+// sqsMaxBatchSize is the maximum number of entries SendMessageBatch accepts
+const sqsMaxBatchSize = 10
+
+// QueueFailure describes a work item that could not be queued
+type QueueFailure struct {
+	ItemIndex int
+	Err       error
+}
+
+// QueueWorkItems sends work items to the SQS queue in batches of 10. Items must already
+// carry their JobID, ItemIndex and ItemType. Failed entries are returned rather than
+// aborting the remaining batches, so the caller can retry them or adjust the job total.
+func QueueWorkItems(ctx context.Context, sqsClient SQSQueueAPI, workItems []WorkItem) []QueueFailure {
+	var failures []QueueFailure
+	queueURL := aws.String(os.Getenv("QUEUE_URL"))
+
+	for start := 0; start < len(workItems); start += sqsMaxBatchSize {
+		chunk := workItems[start:min(start+sqsMaxBatchSize, len(workItems))]
+
+		// Entry IDs are the item indices, which are unique within a job
+		entries := make([]sqsTypes.SendMessageBatchRequestEntry, 0, len(chunk))
+		for _, workItem := range chunk {
+			body, err := json.Marshal(workItem)
+			if err != nil {
+				failures = append(failures, QueueFailure{ItemIndex: workItem.ItemIndex, Err: fmt.Errorf("failed to marshal work item: %w", err)})
+				continue
+			}
+			entries = append(entries, sqsTypes.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(workItem.ItemIndex)),
+				MessageBody: aws.String(string(body)),
+			})
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		resp, err := sqsClient.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: queueURL,
+			Entries:  entries,
+		})
+		if err != nil {
+			// The whole batch was rejected
+			for _, entry := range entries {
+				index, _ := strconv.Atoi(aws.ToString(entry.Id))
+				failures = append(failures, QueueFailure{ItemIndex: index, Err: fmt.Errorf("failed to queue work item: %w", err)})
+			}
+			continue
+		}
+
+		for _, failed := range resp.Failed {
+			index, _ := strconv.Atoi(aws.ToString(failed.Id))
+			failures = append(failures, QueueFailure{
+				ItemIndex: index,
+				Err:       fmt.Errorf("failed to queue work item: %s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message)),
+			})
+		}
+	}
+
+	return failures
+}
+
+// ReduceJobTotal lowers a job's total item count, e.g. for work items that could not be queued,
+// so the job can still reach a terminal state
+func ReduceJobTotal(ctx context.Context, dynamoClient DynamoJobStore, jobID string, count int) error {
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(os.Getenv("JOBS_TABLE")),
+		Key:              map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
+		UpdateExpression: aws.String("SET updated_at = :updated_at ADD total_items :dec"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			":dec":        &types.AttributeValueMemberN{Value: strconv.Itoa(-count)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reduce job total: %w", err)
+	}
+	return nil
+}
+
+// UpdateJobStatus updates the status of a job in DynamoDB. When expected statuses are given
+// the update only applies if the job is currently in one of them, so a transition happens
+// once; otherwise the returned error wraps *types.ConditionalCheckFailedException.
+func UpdateJobStatus(ctx context.Context, dynamoClient DynamoJobStore, jobID string, status JobStatus, expected ...JobStatus) error {
 	now := time.Now().Unix()
 
 	update := map[string]types.AttributeValue{
@@ -130,11 +368,21 @@ func UpdateJobStatus(ctx context.Context, dynamoClient *dynamodb.Client, jobID s
 	updateExp := "SET #status = :status, updated_at = :updated_at"
 
 	// If completing, set completion time
-	if status == JobStatusCompleted || status == JobStatusFailed {
+	if status.IsTerminal() {
 		update[":completed_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)}
 		updateExp += ", completed_at = :completed_at"
 	}
 
+	var condition *string
+	if len(expected) > 0 {
+		placeholders := make([]string, len(expected))
+		for i, s := range expected {
+			placeholders[i] = fmt.Sprintf(":expected%d", i)
+			update[placeholders[i]] = &types.AttributeValueMemberS{Value: string(s)}
+		}
+		condition = aws.String(fmt.Sprintf("#status IN (%s)", strings.Join(placeholders, ", ")))
+	}
+
 	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(os.Getenv("JOBS_TABLE")),
 		Key: map[string]types.AttributeValue{
@@ -145,6 +393,7 @@ func UpdateJobStatus(ctx context.Context, dynamoClient *dynamodb.Client, jobID s
 		},
 		ExpressionAttributeValues: update,
 		UpdateExpression:          aws.String(updateExp),
+		ConditionExpression:       condition,
 	})
 
 	if err != nil {
@@ -154,23 +403,376 @@ func UpdateJobStatus(ctx context.Context, dynamoClient *dynamodb.Client, jobID s
 	return nil
 }
 
-// GetJob retrieves a job from DynamoDB with robust string handling
-func GetJob(ctx context.Context, dynamoClient *dynamodb.Client, jobID string) (*JobInfo, error) {
-	log.Printf("Retrieving job %s from DynamoDB", jobID)
+// accountAnalyzer and accountModel are set by SetAccountAnalyzer; without them jobs complete
+// without account-level recommendations
+var (
+	accountAnalyzer BedrockInvoker
+	accountModel    string
+)
+
+// SetAccountAnalyzer makes MaybeFinalizeJob write the account-level recommendations of a job
+// with client and modelID before marking it completed
+func SetAccountAnalyzer(client BedrockInvoker, modelID string) {
+	accountAnalyzer, accountModel = client, modelID
+}
+
+// recordAccountRecommendations analyzes the results of a job that is about to complete as a
+// whole and stores the AccountRecommendations on it, when SetAccountAnalyzer was called. The
+// job completes either way, so a failure is logged rather than returned.
+func recordAccountRecommendations(ctx context.Context, dynamoClient DynamoJobStore, job *JobInfo) {
+	if accountAnalyzer == nil || job.AccountRecommendations != nil {
+		return
+	}
+	results, err := finishedJobResults(ctx, dynamoClient, job)
+	if err != nil {
+		Warnf("Completing job %s without account-level recommendations: %v", job.JobID, err)
+		return
+	}
+	account, err := AnalyzeAccount(ctx, accountAnalyzer, accountModel, results)
+	if err != nil {
+		Warnf("Completing job %s without account-level recommendations: %v", job.JobID, err)
+		return
+	}
 
+	err = StoreAccountRecommendations(ctx, dynamoClient, job.JobID, account)
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		Infof("Account-level recommendations of job %s were already written by another worker", job.JobID)
+		return
+	}
+	if err != nil {
+		Warnf("%v", err)
+		return
+	}
+	job.AccountRecommendations = account
+}
+
+// StoreAccountRecommendations stores the account-level recommendations of a job that has not
+// finished yet and adds their Bedrock usage to the job's. They are written once; a second
+// write fails with an error wrapping *types.ConditionalCheckFailedException.
+func StoreAccountRecommendations(ctx context.Context, dynamoClient DynamoJobStore, jobID string, account *AccountRecommendations) error {
+	accountAV, err := attributevalue.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account-level recommendations: %w", err)
+	}
+	usage := account.AnalysisUsage
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("JOBS_TABLE")),
+		Key:       map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
+		UpdateExpression: aws.String("SET account_recommendations = :account, updated_at = :updated_at" +
+			", prompt_tokens = if_not_exists(prompt_tokens, :zero) + :prompt_tokens" +
+			", completion_tokens = if_not_exists(completion_tokens, :zero) + :completion_tokens" +
+			", analysis_cost_usd = if_not_exists(analysis_cost_usd, :zero) + :analysis_cost"),
+		ConditionExpression:      aws.String("attribute_not_exists(account_recommendations) AND #status IN (:pending, :processing)"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":account":           accountAV,
+			":updated_at":        &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			":zero":              &types.AttributeValueMemberN{Value: "0"},
+			":prompt_tokens":     &types.AttributeValueMemberN{Value: strconv.Itoa(usage.PromptTokens)},
+			":completion_tokens": &types.AttributeValueMemberN{Value: strconv.Itoa(usage.CompletionTokens)},
+			":analysis_cost":     &types.AttributeValueMemberN{Value: strconv.FormatFloat(usage.AnalysisCostUSD, 'f', -1, 64)},
+			":pending":           &types.AttributeValueMemberS{Value: string(JobStatusPending)},
+			":processing":        &types.AttributeValueMemberS{Value: string(JobStatusProcessing)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store account-level recommendations of job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// MaybeFinalizeJob marks a job completed (or failed) once every item has been processed,
+// including items that failed before reaching Bedrock. The transition is conditional on the
+// job still being pending or processing, so concurrent workers finishing the last items
+// write it once and a cancellation is never overwritten; losing that race is not an error.
+// Before completing a job it records its account-level recommendations, if
+// SetAccountAnalyzer was called. The worker that makes the transition publishes the job's
+// completion, if SetJobNotifier was called.
+func MaybeFinalizeJob(ctx context.Context, dynamoClient DynamoJobStore, jobID string) error {
+	// A consistent read sees the counter update of the item just processed
+	job, err := GetJobWith(ctx, dynamoClient, jobID, JobReadOptions{WithoutResults: true, ConsistentRead: true})
+	if err != nil {
+		return fmt.Errorf("failed to load job %s for completion check: %w", jobID, err)
+	}
+
+	if job.CompletedItems+job.FailedItems+job.SkippedItems < job.TotalItems || job.Status.IsTerminal() {
+		return nil
+	}
+
+	status := JobStatusCompleted
+	if job.FailedItems == job.TotalItems {
+		status = JobStatusFailed
+	}
+	if status == JobStatusCompleted {
+		recordAccountRecommendations(ctx, dynamoClient, job)
+	}
+
+	err = UpdateJobStatus(ctx, dynamoClient, jobID, status, JobStatusPending, JobStatusProcessing)
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		Warnf("Job %s was already finalized by another worker", jobID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	job.Status, job.CompletedAt = status, time.Now().Unix()
+	notifyJobFinished(ctx, dynamoClient, job)
+	return nil
+}
+
+// CancelJob marks a pending or processing job as cancelled and publishes its completion, if
+// SetJobNotifier was called. It fails with ErrJobNotFound for unknown IDs and
+// ErrJobAlreadyFinished for terminal jobs.
+func CancelJob(ctx context.Context, dynamoClient DynamoJobStore, jobID string) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("JOBS_TABLE")),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		UpdateExpression:    aws.String("SET #status = :cancelled, updated_at = :now, completed_at = :now"),
+		ConditionExpression: aws.String("attribute_exists(job_id) AND #status IN (:pending, :processing)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cancelled":  &types.AttributeValueMemberS{Value: string(JobStatusCancelled)},
+			":pending":    &types.AttributeValueMemberS{Value: string(JobStatusPending)},
+			":processing": &types.AttributeValueMemberS{Value: string(JobStatusProcessing)},
+			":now":        &types.AttributeValueMemberN{Value: now},
+		},
+	})
+	if err == nil {
+		notifyJobFinishedByID(ctx, dynamoClient, jobID)
+		return nil
+	}
+
+	// Distinguish a missing job from one that has already finished
+	var conditionErr *types.ConditionalCheckFailedException
+	if !errors.As(err, &conditionErr) {
+		return &JobStoreError{Op: "cancel job", JobID: jobID, Err: err}
+	}
+	status, statusErr := GetJobStatus(ctx, dynamoClient, jobID)
+	if statusErr != nil {
+		return statusErr
+	}
+	return fmt.Errorf("%w with status %s", ErrJobAlreadyFinished, status)
+}
+
+// GetJobStatus reads only the status attribute of a job, avoiding the cost of loading its
+// results. Unknown jobs return ErrJobNotFound; unlike GetJob it does not check expiry.
+func GetJobStatus(ctx context.Context, dynamoClient DynamoJobStore, jobID string) (JobStatus, error) {
 	result, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(os.Getenv("JOBS_TABLE")),
 		Key: map[string]types.AttributeValue{
 			"job_id": &types.AttributeValueMemberS{Value: jobID},
 		},
+		ProjectionExpression:     aws.String("#status"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+	})
+	if err != nil {
+		return "", &JobStoreError{Op: "get status of job", JobID: jobID, Err: err}
+	}
+
+	if result.Item == nil {
+		return "", ErrJobNotFound
+	}
+
+	var job JobInfo
+	if err := attributevalue.UnmarshalMap(result.Item, &job); err != nil {
+		return "", fmt.Errorf("failed to unmarshal job status: %w", err)
+	}
+
+	return job.Status, nil
+}
+
+// listJobsScanBatch is how many job items one Scan call of ListJobs evaluates, and
+// maxListJobsScans how many calls one page may take before it is returned short
+const (
+	listJobsScanBatch = 100
+	maxListJobsScans  = 10
+)
+
+// listJobsProjection names every job attribute but the results, which would make a scan of
+// jobs stored before the results table existed read their whole reports
+const listJobsProjection = "job_id, #status, created_at, updated_at, completed_at, total_items, completed_items, " +
+	"failed_items, skipped_items, resource_types, #owner"
+
+// jobProjection names every job attribute GetJobWith reads for JobReadOptions.WithoutResults
+const jobProjection = listJobsProjection + ", results_prefix, results_in_table, request_key, failed_indices, retry_count, ttl_days, expiration_time, " +
+	"prompt_tokens, completion_tokens, analysis_cost_usd, account_recommendations, metadata"
+
+// ErrInvalidNextToken is returned by ListJobs for a next token it did not issue
+var ErrInvalidNextToken = errors.New("invalid next token")
+
+// ListJobs returns up to limit jobs visible to owner, as CheckJobAccess decides, without their
+// results, optionally only those in status. The jobs table is scanned, so jobs come in no
+// particular order across pages; each page is sorted newest first. nextToken continues a
+// previous listing, and the returned token is empty once the table has been read to the end.
+// A page may hold fewer than limit jobs, even none, while the token is set.
+func ListJobs(ctx context.Context, dynamoClient DynamoJobStore, limit int, status JobStatus, owner, nextToken string) ([]JobInfo, string, error) {
+	if limit <= 0 {
+		limit = DefaultJobsPageSize
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(os.Getenv("JOBS_TABLE")),
+		ProjectionExpression:     aws.String(listJobsProjection),
+		ExpressionAttributeNames: map[string]string{"#status": "status", "#owner": "owner"},
+		Limit:                    aws.Int32(listJobsScanBatch),
+	}
+
+	// Anonymous jobs are open to every caller, and a caller's own jobs only to them
+	filter := "attribute_not_exists(#owner)"
+	values := map[string]types.AttributeValue{}
+	if owner != "" {
+		filter = "(attribute_not_exists(#owner) OR #owner = :owner)"
+		values[":owner"] = &types.AttributeValueMemberS{Value: owner}
+	}
+	if status != "" {
+		filter += " AND #status = :status"
+		values[":status"] = &types.AttributeValueMemberS{Value: string(status)}
+	}
+	input.FilterExpression = aws.String(filter)
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	if nextToken != "" {
+		jobID, err := base64.RawURLEncoding.DecodeString(nextToken)
+		if err != nil || len(jobID) == 0 {
+			return nil, "", ErrInvalidNextToken
+		}
+		input.ExclusiveStartKey = map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: string(jobID)}}
+	}
+
+	jobs := make([]JobInfo, 0, limit)
+	for scans := 0; scans < maxListJobsScans; scans++ {
+		output, err := dynamoClient.Scan(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list jobs: %w", err)
+		}
+
+		var page []JobInfo
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal jobs: %w", err)
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+
+		// Items are returned in scan order, so a page that fills up continues after its last job
+		if remaining := limit - len(jobs); len(page) >= remaining {
+			jobs = append(jobs, page[:remaining]...)
+			input.ExclusiveStartKey = map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobs[len(jobs)-1].JobID}}
+			break
+		}
+		jobs = append(jobs, page...)
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt > jobs[j].CreatedAt
 	})
 
+	token := ""
+	if key, ok := input.ExclusiveStartKey["job_id"].(*types.AttributeValueMemberS); ok {
+		token = base64.RawURLEncoding.EncodeToString([]byte(key.Value))
+	}
+	Debugf("Listed %d jobs", len(jobs))
+	return jobs, token, nil
+}
+
+// RecordSkippedItem increments the skipped items counter for an item of a job whose items are
+// no longer processed. Like UpdateJobProgress it counts each item index once.
+func RecordSkippedItem(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int) error {
+	updateExpr := "SET updated_at = :updated_at, skipped_items = if_not_exists(skipped_items, :zero) + :inc"
+	exprValues := map[string]types.AttributeValue{
+		":updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		":zero":       &types.AttributeValueMemberN{Value: "0"},
+		":inc":        &types.AttributeValueMemberN{Value: "1"},
+	}
+	if err := updateJobCounters(ctx, dynamoClient, jobID, itemIndex, false, updateExpr, exprValues); err != nil {
+		return fmt.Errorf("failed to record skipped item: %w", err)
+	}
+	return nil
+}
+
+// itemCounted reports whether a job has already counted an item as completed, failed or
+// skipped. Items of unknown jobs are not counted.
+func itemCounted(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int) (bool, error) {
+	result, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("JOBS_TABLE")),
+		Key:                  map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
+		ProjectionExpression: aws.String("processed_items"),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get processed items of job %s: %w", jobID, err)
+	}
+
+	var job struct {
+		ProcessedItems []int `dynamodbav:"processed_items,numberset"`
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, &job); err != nil {
+		return false, fmt.Errorf("failed to unmarshal processed items of job %s: %w", jobID, err)
+	}
+	return slices.Contains(job.ProcessedItems, itemIndex), nil
+}
+
+// CheckJobAccess returns ErrJobAccessDenied unless identity may read or change the job.
+// Jobs submitted anonymously, including those created before owners were recorded,
+// stay open to every caller.
+func CheckJobAccess(job *JobInfo, identity string) error {
+	if job.Owner == "" || job.Owner == identity {
+		return nil
+	}
+	return ErrJobAccessDenied
+}
+
+// JobReadOptions select how GetJobWith reads a job
+type JobReadOptions struct {
+	// WithoutResults leaves out the inline results of jobs stored before the results table,
+	// which can fill most of the 400 KB a DynamoDB item may hold. The job's Results are nil.
+	WithoutResults bool
+	// ConsistentRead sees every write that succeeded before the read, at twice the read cost
+	ConsistentRead bool
+}
+
+// GetJob retrieves a job from DynamoDB with robust string handling
+func GetJob(ctx context.Context, dynamoClient DynamoJobStore, jobID string) (*JobInfo, error) {
+	return GetJobWith(ctx, dynamoClient, jobID, JobReadOptions{})
+}
+
+// GetJobWith retrieves a job from DynamoDB as opts say. Without results a job's Results are
+// nil, and GetJobResults loads them if they turn out to be inline. Unknown jobs return
+// ErrJobNotFound and jobs past their TTL ErrJobExpired; failed DynamoDB calls return a
+// *JobStoreError.
+func GetJobWith(ctx context.Context, dynamoClient DynamoJobStore, jobID string, opts JobReadOptions) (*JobInfo, error) {
+	Debugf("Retrieving job %s from DynamoDB", jobID)
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("JOBS_TABLE")),
+		Key: map[string]types.AttributeValue{
+			"job_id": &types.AttributeValueMemberS{Value: jobID},
+		},
+		ConsistentRead: aws.Bool(opts.ConsistentRead),
+	}
+	if opts.WithoutResults {
+		input.ProjectionExpression = aws.String(jobProjection)
+		input.ExpressionAttributeNames = map[string]string{"#status": "status", "#owner": "owner"}
+	}
+	result, err := dynamoClient.GetItem(ctx, input)
+
 	if err != nil {
-		return nil, fmt.Errorf("failed to get job: %w", err)
+		return nil, &JobStoreError{Op: "get job", JobID: jobID, Err: err}
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("job not found")
+		return nil, ErrJobNotFound
 	}
 
 	// First extract the basic job information (without the results)
@@ -181,10 +783,16 @@ func GetJob(ctx context.Context, dynamoClient *dynamodb.Client, jobID string) (*
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal job basic info: %w", err)
 	}
+	if err := checkJobExpiry(&job); err != nil {
+		return nil, err
+	}
 
 	// Now handle results separately
+	if opts.WithoutResults {
+		return &job, nil
+	}
 	if resultsAV, hasResults := result.Item["results"]; hasResults {
-		log.Printf("Found results field in job %s, processing separately", jobID)
+		Debugf("Found results field in job %s, processing separately", jobID)
 
 		switch typedResults := resultsAV.(type) {
 		case *types.AttributeValueMemberL:
@@ -193,20 +801,20 @@ func GetJob(ctx context.Context, dynamoClient *dynamodb.Client, jobID string) (*
 			for i, resultItemAV := range typedResults.Value {
 				reportItem, err := extractReportItem(resultItemAV, i)
 				if err != nil {
-					log.Printf("Warning: Failed to extract report item %d: %v", i, err)
+					Warnf("Failed to extract report item %d: %v", i, err)
 					continue
 				}
 
 				job.Results = append(job.Results, reportItem)
 			}
 
-			log.Printf("Successfully extracted %d report items for job %s", len(job.Results), jobID)
+			Debugf("Successfully extracted %d report items for job %s", len(job.Results), jobID)
 
 		default:
-			log.Printf("Warning: Results field is not a list, found type %T instead", resultsAV)
+			Warnf("Results field is not a list, found type %T instead", resultsAV)
 		}
 	} else {
-		log.Printf("No results field found for job %s", jobID)
+		Debugf("No results field found for job %s", jobID)
 		job.Results = []ReportItem{}
 	}
 
@@ -226,7 +834,7 @@ func extractReportItem(av types.AttributeValue, index int) (ReportItem, error) {
 
 	case *types.AttributeValueMemberS:
 		// If it's a string, try various approaches to parse it
-		log.Printf("Found string-formatted report item at index %d", index)
+		Debugf("Found string-formatted report item at index %d", index)
 
 		var stringValue string
 		if err := attributevalue.Unmarshal(typedAV, &stringValue); err != nil {
@@ -235,12 +843,12 @@ func extractReportItem(av types.AttributeValue, index int) (ReportItem, error) {
 
 		// Try direct JSON unmarshaling
 		if err := json.Unmarshal([]byte(stringValue), &reportItem); err != nil {
-			log.Printf("Failed direct unmarshal, trying unescape: %v", err)
+			Debugf("Failed direct unmarshal, trying unescape: %v", err)
 
 			// Try unescaping quotes
 			cleanJSON := strings.ReplaceAll(stringValue, "\\\"", "\"")
 			if err := json.Unmarshal([]byte(cleanJSON), &reportItem); err != nil {
-				log.Printf("Failed cleanup unmarshal, trying parse instance directly: %v", err)
+				Debugf("Failed cleanup unmarshal, trying parse instance directly: %v", err)
 
 				// Last resort - try to manually extract and parse components
 				return parseManually(stringValue)
@@ -384,8 +992,18 @@ func copyDynamoItemWithoutResults(item map[string]types.AttributeValue) map[stri
 // 	return 0
 // }
 
-// UpdateJobProgress increments the completed items counter for a job
-func UpdateJobProgress(ctx context.Context, dynamoClient *dynamodb.Client, jobID string, success bool, result ReportItem) error {
+// UpdateJobProgress increments the completed or failed items counter for a job. The item
+// index is recorded in the job's processed_items set, so an item whose SQS message is
+// redelivered and processed again is only counted once, and the index of a failed item also
+// in its failed_indices set, so RetryFailedItems can queue it again. The Bedrock usage of a
+// completed item's result is added to the job's totals.
+func UpdateJobProgress(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int, success bool, result ReportItem) error {
+	return updateJobProgress(ctx, dynamoClient, jobID, itemIndex, success, result, result.AnalysisUsage)
+}
+
+// updateJobProgress is UpdateJobProgress with the usage to add given apart from the result,
+// for results kept outside the job record
+func updateJobProgress(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int, success bool, result ReportItem, usage AnalysisUsage) error {
 	now := time.Now().Unix()
 
 	if success {
@@ -396,6 +1014,17 @@ func UpdateJobProgress(ctx context.Context, dynamoClient *dynamodb.Client, jobID
 			":inc":        &types.AttributeValueMemberN{Value: "1"},
 		}
 
+		// The item is counted at most once, so its usage is too
+		if !usage.IsZero() {
+			updateExpr += ", prompt_tokens = if_not_exists(prompt_tokens, :zero) + :prompt_tokens" +
+				", completion_tokens = if_not_exists(completion_tokens, :zero) + :completion_tokens" +
+				", analysis_cost_usd = if_not_exists(analysis_cost_usd, :zero) + :analysis_cost"
+			exprValues[":zero"] = &types.AttributeValueMemberN{Value: "0"}
+			exprValues[":prompt_tokens"] = &types.AttributeValueMemberN{Value: strconv.Itoa(usage.PromptTokens)}
+			exprValues[":completion_tokens"] = &types.AttributeValueMemberN{Value: strconv.Itoa(usage.CompletionTokens)}
+			exprValues[":analysis_cost"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(usage.AnalysisCostUSD, 'f', -1, 64)}
+		}
+
 		// Only append a ReportItem if it's non-empty
 		if !IsEmptyObject(result) {
 			// Check whether the "results" list already exists
@@ -405,28 +1034,22 @@ func UpdateJobProgress(ctx context.Context, dynamoClient *dynamodb.Client, jobID
 				ProjectionExpression: aws.String("results"),
 			})
 			if err != nil {
-				log.Printf("Warning: Failed to check current results: %v", err)
+				Warnf("Failed to check current results: %v", err)
 			}
 
 			// Marshal the new ReportItem
 			resultAV, err := attributevalue.MarshalMap(result)
 			if err != nil {
-				log.Printf("Warning: Failed to marshal result: %v", err)
+				Warnf("Failed to marshal result: %v", err)
 				// Fallback: update count only
-				_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-					TableName:                 aws.String(os.Getenv("JOBS_TABLE")),
-					Key:                       map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
-					UpdateExpression:          aws.String(updateExpr),
-					ExpressionAttributeValues: exprValues,
-				})
-				if err != nil {
+				if err := updateJobCounters(ctx, dynamoClient, jobID, itemIndex, false, updateExpr, exprValues); err != nil {
 					return fmt.Errorf("failed to update job progress (count only): %w", err)
 				}
 				return nil
 			}
 
 			// Determine whether to prepend an empty list or append to existing
-			if _, exists := getResult.Item["results"]; exists {
+			if getResult != nil && getResult.Item["results"] != nil {
 				updateExpr += ", results = list_append(results, :result)"
 			} else {
 				updateExpr += ", results = list_append(:empty_list, :result)"
@@ -442,13 +1065,7 @@ func UpdateJobProgress(ctx context.Context, dynamoClient *dynamodb.Client, jobID
 		}
 
 		// Perform the update
-		_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-			TableName:                 aws.String(os.Getenv("JOBS_TABLE")),
-			Key:                       map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
-			UpdateExpression:          aws.String(updateExpr),
-			ExpressionAttributeValues: exprValues,
-		})
-		if err != nil {
+		if err := updateJobCounters(ctx, dynamoClient, jobID, itemIndex, false, updateExpr, exprValues); err != nil {
 			return fmt.Errorf("failed to update job progress: %w", err)
 		}
 
@@ -460,13 +1077,7 @@ func UpdateJobProgress(ctx context.Context, dynamoClient *dynamodb.Client, jobID
 			":inc":        &types.AttributeValueMemberN{Value: "1"},
 		}
 
-		_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-			TableName:                 aws.String(os.Getenv("JOBS_TABLE")),
-			Key:                       map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
-			UpdateExpression:          aws.String(updateExpr),
-			ExpressionAttributeValues: exprValues,
-		})
-		if err != nil {
+		if err := updateJobCounters(ctx, dynamoClient, jobID, itemIndex, true, updateExpr, exprValues); err != nil {
 			return fmt.Errorf("failed to update job progress: %w", err)
 		}
 	}
@@ -474,12 +1085,37 @@ func UpdateJobProgress(ctx context.Context, dynamoClient *dynamodb.Client, jobID
 	return nil
 }
 
-// IsEmptyObject checks if a struct is empty
-func IsEmptyObject(obj interface{}) bool {
-	// Simple check - this would need to be more robust in production
-	jsonData, err := json.Marshal(obj)
-	if err != nil {
-		return true
+// updateJobCounters applies a counter update unless the item index was already processed.
+// An item that was already counted is logged and not treated as an error. The index of a
+// failed item is also added to failed_indices.
+func updateJobCounters(ctx context.Context, dynamoClient DynamoJobStore, jobID string, itemIndex int, failed bool, updateExpr string, exprValues map[string]types.AttributeValue) error {
+	index := strconv.Itoa(itemIndex)
+	exprValues[":item_index"] = &types.AttributeValueMemberN{Value: index}
+	exprValues[":item_index_set"] = &types.AttributeValueMemberNS{Value: []string{index}}
+
+	updateExpr += " ADD processed_items :item_index_set"
+	if failed {
+		updateExpr += ", failed_indices :item_index_set"
 	}
-	return string(jsonData) == "{}" || string(jsonData) == "null"
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("JOBS_TABLE")),
+		Key:                       map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}},
+		UpdateExpression:          aws.String(updateExpr),
+		ConditionExpression:       aws.String("NOT contains(processed_items, :item_index)"),
+		ExpressionAttributeValues: exprValues,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		Infof("Item %d of job %s was already counted, skipping", itemIndex, jobID)
+		return nil
+	}
+	return err
+}
+
+// IsEmptyObject checks if a struct is empty, i.e. its zero value. A ReportItem{} does not
+// marshal to {}, so comparing JSON would count it as a result.
+func IsEmptyObject(obj interface{}) bool {
+	return obj == nil || reflect.ValueOf(obj).IsZero()
 }
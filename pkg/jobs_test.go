@@ -0,0 +1,751 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// jobKey is the key of a job in the jobs table
+func jobKey(jobID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"job_id": &types.AttributeValueMemberS{Value: jobID}}
+}
+
+// createTestJob creates a job with itemCount items in dynamo and fails the test on error
+func createTestJob(t *testing.T, dynamo *fakeDynamo, itemCount int) *JobInfo {
+	t.Helper()
+	job, err := CreateJob(context.Background(), dynamo, []string{"ec2"}, itemCount, "", 7, nil)
+	if err != nil {
+		t.Fatalf("CreateJob() error = %v", err)
+	}
+	return job
+}
+
+func TestCreateJob(t *testing.T) {
+	tests := []struct {
+		name           string
+		resultsTable   string
+		failPut        error
+		wantErr        string
+		wantResultsIn  bool
+		wantResultList bool
+	}{
+		{name: "inline results", wantResultList: true},
+		{name: "results table", resultsTable: testResultsTable, wantResultsIn: true},
+		{name: "put error is returned", failPut: errors.New("ProvisionedThroughputExceeded"), wantErr: "failed to save job"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			t.Setenv("RESULTS_TABLE", tt.resultsTable)
+			dynamo := newFakeDynamo()
+			if tt.failPut != nil {
+				dynamo.fail("PutItem", tt.failPut)
+			}
+
+			job, err := CreateJob(context.Background(), dynamo, []string{"ec2", "s3"}, 4, "arn:aws:iam::123456789012:user/alice", 7, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, tt.failPut) {
+					t.Fatalf("CreateJob() error = %v, want %q wrapping %v", err, tt.wantErr, tt.failPut)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateJob() error = %v", err)
+			}
+			if job.Status != JobStatusPending || job.TotalItems != 4 || job.TTLDays != 7 {
+				t.Errorf("job = %+v", job)
+			}
+			if got := job.ExpirationTime - job.CreatedAt; got != 7*24*60*60 {
+				t.Errorf("job expires %d seconds after creation, want 7 days", got)
+			}
+
+			item := dynamo.item(testJobsTable, jobKey(job.JobID))
+			if item == nil {
+				t.Fatalf("job %s was not stored", job.JobID)
+			}
+			_, inTable := item["results_in_table"]
+			_, hasList := item["results"]
+			if inTable != tt.wantResultsIn || hasList != tt.wantResultList {
+				t.Errorf("stored results_in_table %t, results %t; want %t, %t", inTable, hasList, tt.wantResultsIn, tt.wantResultList)
+			}
+			if owner := item["owner"].(*types.AttributeValueMemberS).Value; owner != "arn:aws:iam::123456789012:user/alice" {
+				t.Errorf("stored owner = %q", owner)
+			}
+		})
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	getErr := errors.New("RequestLimitExceeded")
+
+	tests := []struct {
+		name    string
+		jobID   func(created string) string
+		failGet error
+		wantErr error
+	}{
+		{name: "existing job", jobID: func(created string) string { return created }},
+		{name: "unknown job", jobID: func(string) string { return "missing" }, wantErr: ErrJobNotFound},
+		{name: "get error is returned", jobID: func(created string) string { return created }, failGet: getErr, wantErr: getErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			dynamo := newFakeDynamo()
+			created := createTestJob(t, dynamo, 2)
+			if tt.failGet != nil {
+				dynamo.fail("GetItem", tt.failGet)
+			}
+
+			job, err := GetJob(context.Background(), dynamo, tt.jobID(created.JobID))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetJob() error = %v, want %v", err, tt.wantErr)
+				}
+				var storeErr *JobStoreError
+				if tt.failGet != nil && !errors.As(err, &storeErr) {
+					t.Errorf("GetJob() error = %T, want *JobStoreError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetJob() error = %v", err)
+			}
+			if job.JobID != created.JobID || job.Status != JobStatusPending || job.TotalItems != 2 {
+				t.Errorf("job = %+v", job)
+			}
+			if job.Results == nil || len(job.Results) != 0 {
+				t.Errorf("Results = %v, want an empty list", job.Results)
+			}
+		})
+	}
+}
+
+func TestUpdateJobProgress(t *testing.T) {
+	result := ReportItem{
+		ResourceType:  ResourceTypeEC2,
+		Instance:      Instance{InstanceID: "i-0"},
+		Analysis:      "Downsize to t3.small",
+		AnalysisUsage: AnalysisUsage{PromptTokens: 100, CompletionTokens: 20, AnalysisCostUSD: 0.5},
+	}
+	updateErr := errors.New("InternalServerError")
+
+	tests := []struct {
+		name          string
+		success       bool
+		failUpdate    error
+		wantCompleted int
+		wantFailed    int
+		wantResults   int
+		wantTokens    int
+	}{
+		{name: "completed item", success: true, wantCompleted: 1, wantResults: 1, wantTokens: 100},
+		{name: "failed item", success: false, wantFailed: 1},
+		{name: "update error is returned", success: true, failUpdate: updateErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			dynamo := newFakeDynamo()
+			job := createTestJob(t, dynamo, 2)
+			if tt.failUpdate != nil {
+				dynamo.fail("UpdateItem", tt.failUpdate)
+			}
+
+			err := UpdateJobProgress(context.Background(), dynamo, job.JobID, 0, tt.success, result)
+			if tt.failUpdate != nil {
+				if !errors.Is(err, tt.failUpdate) {
+					t.Fatalf("UpdateJobProgress() error = %v, want %v", err, tt.failUpdate)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateJobProgress() error = %v", err)
+			}
+
+			got, err := GetJob(context.Background(), dynamo, job.JobID)
+			if err != nil {
+				t.Fatalf("GetJob() error = %v", err)
+			}
+			if got.CompletedItems != tt.wantCompleted || got.FailedItems != tt.wantFailed {
+				t.Errorf("completed %d, failed %d; want %d, %d", got.CompletedItems, got.FailedItems, tt.wantCompleted, tt.wantFailed)
+			}
+			if len(got.Results) != tt.wantResults {
+				t.Errorf("got %d results, want %d", len(got.Results), tt.wantResults)
+			}
+			if got.PromptTokens != tt.wantTokens {
+				t.Errorf("PromptTokens = %d, want %d", got.PromptTokens, tt.wantTokens)
+			}
+			if !tt.success && (len(got.FailedIndices) != 1 || got.FailedIndices[0] != 0) {
+				t.Errorf("FailedIndices = %v, want [0]", got.FailedIndices)
+			}
+		})
+	}
+}
+
+// An item whose SQS message is redelivered is only counted once
+func TestUpdateJobProgressCountsRedeliveredItemOnce(t *testing.T) {
+	useJobTables(t)
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 2)
+	for i := 0; i < 2; i++ {
+		if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, 0, true, ReportItem{}); err != nil {
+			t.Fatalf("UpdateJobProgress() error = %v", err)
+		}
+	}
+	if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, 0, false, ReportItem{}); err != nil {
+		t.Fatalf("UpdateJobProgress() error = %v", err)
+	}
+
+	got, err := GetJob(context.Background(), dynamo, job.JobID)
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	if got.CompletedItems != 1 || got.FailedItems != 0 {
+		t.Errorf("completed %d, failed %d; want 1, 0", got.CompletedItems, got.FailedItems)
+	}
+}
+
+func TestQueueWorkItem(t *testing.T) {
+	sendErr := errors.New("QueueDoesNotExist")
+
+	tests := []struct {
+		name    string
+		sqs     *fakeSQS
+		wantErr error
+	}{
+		{name: "queued", sqs: &fakeSQS{}},
+		{name: "send error is returned", sqs: &fakeSQS{err: sendErr}, wantErr: sendErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("QUEUE_URL", "https://sqs.eu-west-1.amazonaws.com/123456789012/work")
+
+			err := QueueWorkItem(context.Background(), tt.sqs, "job-1", 3, "ec2", WorkItem{Instance: Instance{InstanceID: "i-3"}})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("QueueWorkItem() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QueueWorkItem() error = %v", err)
+			}
+
+			items := tt.sqs.workItems(t)
+			if len(items) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(items))
+			}
+			item := items[0]
+			if item.JobID != "job-1" || item.ItemIndex != 3 || item.ItemType != "ec2" || item.Instance.InstanceID != "i-3" {
+				body, _ := json.Marshal(item)
+				t.Errorf("queued work item = %s", body)
+			}
+		})
+	}
+}
+
+// testWorkItems returns n EC2 work items of job-1 with indices 0 to n-1
+func testWorkItems(n int) []WorkItem {
+	items := make([]WorkItem, n)
+	for i := range items {
+		items[i] = WorkItem{JobID: "job-1", ItemIndex: i, ItemType: "ec2", Instance: Instance{InstanceID: fmt.Sprintf("i-%d", i)}}
+	}
+	return items
+}
+
+func TestQueueWorkItemsBatches(t *testing.T) {
+	tests := []struct {
+		items   int
+		batches []int
+	}{
+		{items: 0},
+		{items: 1, batches: []int{1}},
+		{items: 10, batches: []int{10}},
+		{items: 11, batches: []int{10, 1}},
+		{items: 25, batches: []int{10, 10, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.items), func(t *testing.T) {
+			sqsClient := &fakeSQS{}
+			if failures := QueueWorkItems(context.Background(), sqsClient, testWorkItems(tt.items)); len(failures) != 0 {
+				t.Fatalf("QueueWorkItems() failures = %v", failures)
+			}
+
+			var sizes []int
+			for _, batch := range sqsClient.batches {
+				sizes = append(sizes, len(batch))
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.batches) {
+				t.Errorf("batch sizes = %v, want %v", sizes, tt.batches)
+			}
+			for i, item := range sqsClient.workItems(t) {
+				if item.ItemIndex != i || item.Instance.InstanceID != fmt.Sprintf("i-%d", i) {
+					t.Errorf("message %d carries item %d (%s)", i, item.ItemIndex, item.Instance.InstanceID)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueWorkItemsFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		sqs         *fakeSQS
+		wantFailed  []int
+		wantQueued  int
+		wantErrText string
+	}{
+		{
+			name:        "failed entries",
+			sqs:         &fakeSQS{failEntry: func(id string) bool { return id == "3" || id == "17" }},
+			wantFailed:  []int{3, 17},
+			wantQueued:  23,
+			wantErrText: "InternalError",
+		},
+		{
+			name: "every entry of one batch",
+			sqs: &fakeSQS{failEntry: func(id string) bool {
+				n, _ := strconv.Atoi(id)
+				return n >= 10 && n < 20
+			}},
+			wantFailed: []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+			wantQueued: 15,
+		},
+		{
+			name:        "every call fails",
+			sqs:         &fakeSQS{err: errors.New("AccessDenied")},
+			wantFailed:  []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24},
+			wantErrText: "AccessDenied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := QueueWorkItems(context.Background(), tt.sqs, testWorkItems(25))
+
+			var failed []int
+			for _, failure := range failures {
+				failed = append(failed, failure.ItemIndex)
+				if tt.wantErrText != "" && !strings.Contains(failure.Err.Error(), tt.wantErrText) {
+					t.Errorf("item %d error = %v, want %q", failure.ItemIndex, failure.Err, tt.wantErrText)
+				}
+			}
+			if fmt.Sprint(failed) != fmt.Sprint(tt.wantFailed) {
+				t.Errorf("failed items = %v, want %v", failed, tt.wantFailed)
+			}
+			if got := len(tt.sqs.workItems(t)); got != tt.wantQueued {
+				t.Errorf("queued %d items, want %d", got, tt.wantQueued)
+			}
+			// Every batch is still sent after an earlier one failed
+			if len(tt.sqs.batches) != 3 {
+				t.Errorf("sent %d batches, want 3", len(tt.sqs.batches))
+			}
+		})
+	}
+}
+
+// Indices are assigned across resource types before queueing, and the entry IDs are those
+// indices, so failures name the items they came from
+func TestQueueWorkItemsKeepsIndices(t *testing.T) {
+	payload := ScanPayload{
+		Instances:    []Instance{{InstanceID: "i-1"}, {InstanceID: "i-2"}},
+		S3Buckets:    []S3Bucket{{BucketName: "logs"}},
+		RDSInstances: []RDSInstance{{InstanceID: "db-1"}},
+		Snapshots:    []EBSSnapshot{{SnapshotID: "snap-1"}, {SnapshotID: "snap-2"}},
+	}
+	workItems := payload.WorkItems("job-1", 0)
+
+	want := []string{"0 ec2 i-1", "1 ec2 i-2", "2 s3 logs", "3 rds db-1", "4 snapshots 2 snapshots"}
+	if len(workItems) != len(want) {
+		t.Fatalf("got %d work items, want %d", len(workItems), len(want))
+	}
+	for i, item := range workItems {
+		if got := fmt.Sprintf("%d %s %s", item.ItemIndex, item.ItemType, item.ResourceID()); got != want[i] {
+			t.Errorf("work item %d = %q, want %q", i, got, want[i])
+		}
+	}
+
+	sqsClient := &fakeSQS{failEntry: func(id string) bool { return id == "2" }}
+	failures := QueueWorkItems(context.Background(), sqsClient, workItems)
+	if len(failures) != 1 || failures[0].ItemIndex != 2 || workItems[failures[0].ItemIndex].S3Bucket.BucketName != "logs" {
+		t.Fatalf("failures = %+v, want item 2, the bucket", failures)
+	}
+
+	// The retry of the failed item keeps its index
+	sqsClient.failEntry = nil
+	if failures := QueueWorkItems(context.Background(), sqsClient, []WorkItem{workItems[2]}); len(failures) != 0 {
+		t.Fatalf("retry failures = %v", failures)
+	}
+	if ids := sqsClient.batches[len(sqsClient.batches)-1]; len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("retry entry IDs = %v, want [2]", ids)
+	}
+}
+
+// Items that could not be queued are taken off the job's total, so it still finishes
+func TestReduceJobTotal(t *testing.T) {
+	useJobTables(t)
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 5)
+
+	sqsClient := &fakeSQS{failEntry: func(id string) bool { return id == "1" || id == "4" }}
+	workItems := testWorkItems(5)
+	for i := range workItems {
+		workItems[i].JobID = job.JobID
+	}
+	failures := QueueWorkItems(context.Background(), sqsClient, workItems)
+	if err := ReduceJobTotal(context.Background(), dynamo, job.JobID, len(failures)); err != nil {
+		t.Fatalf("ReduceJobTotal() error = %v", err)
+	}
+
+	got, err := GetJob(context.Background(), dynamo, job.JobID)
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	if got.TotalItems != 3 {
+		t.Fatalf("TotalItems = %d, want 3", got.TotalItems)
+	}
+
+	for _, item := range sqsClient.workItems(t) {
+		if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, item.ItemIndex, true, ReportItem{}); err != nil {
+			t.Fatalf("UpdateJobProgress() error = %v", err)
+		}
+	}
+	if err := MaybeFinalizeJob(context.Background(), dynamo, job.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v", err)
+	}
+	if status, _ := GetJobStatus(context.Background(), dynamo, job.JobID); status != JobStatusCompleted {
+		t.Errorf("status = %s, want %s once the queued items are done", status, JobStatusCompleted)
+	}
+
+	dynamo.fail("UpdateItem", errors.New("InternalServerError"))
+	if err := ReduceJobTotal(context.Background(), dynamo, job.JobID, 1); err == nil || !strings.Contains(err.Error(), "failed to reduce job total") {
+		t.Errorf("ReduceJobTotal() error = %v, want the update error", err)
+	}
+}
+
+func TestUpdateJobStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		from          JobStatus
+		to            JobStatus
+		expected      []JobStatus
+		wantCondition bool
+		wantStatus    JobStatus
+	}{
+		{name: "unconditional", from: JobStatusPending, to: JobStatusProcessing, wantStatus: JobStatusProcessing},
+		{name: "expected status", from: JobStatusProcessing, to: JobStatusCompleted, expected: []JobStatus{JobStatusPending, JobStatusProcessing}, wantStatus: JobStatusCompleted},
+		{name: "already completed", from: JobStatusCompleted, to: JobStatusFailed, expected: []JobStatus{JobStatusPending, JobStatusProcessing}, wantCondition: true, wantStatus: JobStatusCompleted},
+		{name: "processing after a fast worker finished", from: JobStatusCompleted, to: JobStatusProcessing, expected: []JobStatus{JobStatusPending}, wantCondition: true, wantStatus: JobStatusCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			dynamo := newFakeDynamo()
+			job := createTestJob(t, dynamo, 1)
+			if tt.from != JobStatusPending {
+				if err := UpdateJobStatus(context.Background(), dynamo, job.JobID, tt.from); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := UpdateJobStatus(context.Background(), dynamo, job.JobID, tt.to, tt.expected...)
+			var conditionErr *types.ConditionalCheckFailedException
+			if got := errors.As(err, &conditionErr); got != tt.wantCondition {
+				t.Fatalf("UpdateJobStatus() error = %v, want a conditional check failure: %t", err, tt.wantCondition)
+			}
+			if !tt.wantCondition && err != nil {
+				t.Fatalf("UpdateJobStatus() error = %v", err)
+			}
+
+			got, err := GetJob(context.Background(), dynamo, job.JobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if got.Status.IsTerminal() != (got.CompletedAt != 0) {
+				t.Errorf("status %s with completed_at %d", got.Status, got.CompletedAt)
+			}
+		})
+	}
+}
+
+// racingDynamo runs beforeUpdate ahead of the first UpdateItem, to let another writer in
+// between a read and a conditional write
+type racingDynamo struct {
+	*fakeDynamo
+	beforeUpdate func()
+}
+
+func (r *racingDynamo) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if before := r.beforeUpdate; before != nil {
+		r.beforeUpdate = nil
+		before()
+	}
+	return r.fakeDynamo.UpdateItem(ctx, params, optFns...)
+}
+
+func TestMaybeFinalizeJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		completed  []int
+		failed     []int
+		skipped    int
+		wantStatus JobStatus
+	}{
+		{name: "items outstanding", completed: []int{0}, wantStatus: JobStatusProcessing},
+		{name: "all completed", completed: []int{0, 1, 2}, wantStatus: JobStatusCompleted},
+		{name: "some failed", completed: []int{0, 1}, failed: []int{2}, wantStatus: JobStatusCompleted},
+		{name: "all failed", failed: []int{0, 1, 2}, wantStatus: JobStatusFailed},
+		{name: "skipped items count", completed: []int{0, 1}, skipped: 1, wantStatus: JobStatusCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJobTables(t)
+			dynamo := newFakeDynamo()
+			job := createTestJob(t, dynamo, 3)
+			if err := UpdateJobStatus(context.Background(), dynamo, job.JobID, JobStatusProcessing); err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range tt.completed {
+				if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, i, true, ReportItem{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, i := range tt.failed {
+				if err := UpdateJobProgress(context.Background(), dynamo, job.JobID, i, false, ReportItem{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < tt.skipped; i++ {
+				if err := RecordSkippedItem(context.Background(), dynamo, job.JobID, 2); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := MaybeFinalizeJob(context.Background(), dynamo, job.JobID); err != nil {
+				t.Fatalf("MaybeFinalizeJob() error = %v", err)
+			}
+			if status, _ := GetJobStatus(context.Background(), dynamo, job.JobID); status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// Two workers that see the last item counted both try to finalize the job; the second
+// write fails its condition and is tolerated
+func TestMaybeFinalizeJobLosesRace(t *testing.T) {
+	useJobTables(t)
+	dynamo := &racingDynamo{fakeDynamo: newFakeDynamo()}
+	job := createTestJob(t, dynamo.fakeDynamo, 1)
+	if err := UpdateJobProgress(context.Background(), dynamo.fakeDynamo, job.JobID, 0, true, ReportItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other worker finalizes between this one's read and its write
+	dynamo.beforeUpdate = func() {
+		if err := UpdateJobStatus(context.Background(), dynamo.fakeDynamo, job.JobID, JobStatusFailed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MaybeFinalizeJob(context.Background(), dynamo, job.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v, want the lost race tolerated", err)
+	}
+	if status, _ := GetJobStatus(context.Background(), dynamo, job.JobID); status != JobStatusFailed {
+		t.Errorf("status = %s, want the first worker's %s kept", status, JobStatusFailed)
+	}
+
+	// A finished job is left alone without a write
+	updates := dynamo.callCount("UpdateItem")
+	if err := MaybeFinalizeJob(context.Background(), dynamo, job.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v", err)
+	}
+	if dynamo.callCount("UpdateItem") != updates {
+		t.Errorf("finalizing a finished job wrote to it")
+	}
+}
+
+func TestCheckJobAccess(t *testing.T) {
+	owner := CallerIdentity("key-a")
+	tests := []struct {
+		name     string
+		owner    string
+		identity string
+		wantErr  bool
+	}{
+		{name: "owner", owner: owner, identity: owner},
+		{name: "another caller", owner: owner, identity: CallerIdentity("key-b"), wantErr: true},
+		{name: "anonymous caller", owner: owner, identity: "", wantErr: true},
+		{name: "anonymous job", owner: "", identity: CallerIdentity("key-b")},
+		{name: "anonymous job and caller", owner: "", identity: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckJobAccess(&JobInfo{JobID: "job-1", Owner: tt.owner}, tt.identity)
+			if tt.wantErr != errors.Is(err, ErrJobAccessDenied) || (!tt.wantErr && err != nil) {
+				t.Errorf("CheckJobAccess() error = %v, want denied %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// sizingDynamo records the size of the item each GetItem call returns, as JSON
+type sizingDynamo struct {
+	*fakeDynamo
+	lastSize int
+}
+
+func (s *sizingDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	out, err := s.fakeDynamo.GetItem(ctx, params, optFns...)
+	if err != nil || out.Item == nil {
+		return out, err
+	}
+	var generic map[string]interface{}
+	if err := attributevalue.UnmarshalMap(out.Item, &generic); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return nil, err
+	}
+	s.lastSize = len(data)
+	return out, nil
+}
+
+// storeJob writes job to the fake jobs table as it is, bypassing CreateJob
+func storeJob(t *testing.T, dynamo *fakeDynamo, job JobInfo) {
+	t.Helper()
+	item, err := attributevalue.MarshalMap(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dynamo.put(testJobsTable, item); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A status poll of a job with inline results reads its counters, not its results
+func TestGetJobWithoutResultsShrinksReads(t *testing.T) {
+	useJobTables(t)
+	dynamo := &sizingDynamo{fakeDynamo: newFakeDynamo()}
+
+	embedding := make([]float64, 512)
+	for i := range embedding {
+		embedding[i] = float64(i) / 512
+	}
+	job := JobInfo{
+		JobID:          "job-legacy",
+		Status:         JobStatusCompleted,
+		TotalItems:     200,
+		CompletedItems: 200,
+		ResourceTypes:  []string{"ec2"},
+		ExpirationTime: time.Now().Add(time.Hour).Unix(),
+	}
+	for i := 0; i < 200; i++ {
+		job.Results = append(job.Results, ReportItem{
+			ResourceType: ResourceTypeEC2,
+			Instance:     Instance{InstanceID: fmt.Sprintf("i-%03d", i), InstanceType: "m5.large"},
+			Analysis:     strings.Repeat("Downsize to t3.medium. ", 40),
+			Embedding:    embedding,
+		})
+	}
+	storeJob(t, dynamo.fakeDynamo, job)
+	ctx := context.Background()
+
+	full, err := GetJobWith(ctx, dynamo, job.JobID, JobReadOptions{})
+	if err != nil {
+		t.Fatalf("GetJobWith() error = %v", err)
+	}
+	fullSize := dynamo.lastSize
+	projected, err := GetJobWith(ctx, dynamo, job.JobID, JobReadOptions{WithoutResults: true, ConsistentRead: true})
+	if err != nil {
+		t.Fatalf("GetJobWith(WithoutResults) error = %v", err)
+	}
+	projectedSize := dynamo.lastSize
+
+	if len(full.Results) != 200 || projected.Results != nil {
+		t.Errorf("full read has %d results, projected read %d; want 200 and none", len(full.Results), len(projected.Results))
+	}
+	if projectedSize*100 > fullSize {
+		t.Errorf("projected read returned %d bytes of %d, want under 1%%", projectedSize, fullSize)
+	}
+
+	// Results are still there for the caller that needs them
+	results, err := GetJobResults(ctx, dynamo, nil, projected)
+	if err != nil {
+		t.Fatalf("GetJobResults() error = %v", err)
+	}
+	if len(results) != 200 {
+		t.Errorf("GetJobResults() = %d results, want the 200 inline ones", len(results))
+	}
+}
+
+// Every attribute but results survives the projection, so a field added to JobInfo without
+// being added to jobProjection fails here
+func TestGetJobWithoutResultsKeepsEveryField(t *testing.T) {
+	useJobTables(t)
+	dynamo := newFakeDynamo()
+	job := JobInfo{
+		JobID:                  "job-1",
+		Status:                 JobStatusCompleted,
+		CreatedAt:              1,
+		UpdatedAt:              2,
+		CompletedAt:            3,
+		TotalItems:             4,
+		CompletedItems:         2,
+		FailedItems:            1,
+		SkippedItems:           1,
+		Results:                []ReportItem{{Analysis: "inline"}},
+		ResultsPrefix:          "results/job-1/",
+		ResultsInTable:         true,
+		RequestKey:             "requests/job-1.json.gz",
+		FailedIndices:          []int{3},
+		RetryCount:             1,
+		TTLDays:                7,
+		ResourceTypes:          []string{"ec2", "s3"},
+		ExpirationTime:         time.Now().Add(time.Hour).Unix(),
+		Owner:                  "key:abc",
+		AnalysisUsage:          AnalysisUsage{PromptTokens: 10, CompletionTokens: 5, AnalysisCostUSD: 0.25},
+		AccountRecommendations: &AccountRecommendations{Analysis: "consolidate"},
+		Metadata:               &ReportMetadata{AccountID: "123456789012"},
+	}
+	fields := reflect.ValueOf(job)
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).IsZero() {
+			t.Fatalf("sample job leaves %s unset; set it so the projection is checked for it", fields.Type().Field(i).Name)
+		}
+	}
+	storeJob(t, dynamo, job)
+
+	got, err := GetJobWith(context.Background(), dynamo, job.JobID, JobReadOptions{WithoutResults: true})
+	if err != nil {
+		t.Fatalf("GetJobWith() error = %v", err)
+	}
+	want := job
+	want.Results = nil
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("projected job = %+v\nwant %+v", *got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Lambda allocates one vCPU per 1,769 MB of memory, so a GB-second is converted to
+// vCPU-hours and priced with the flat per-vCPU-hour factor
+const (
+	lambdaMBPerVCPU       = 1769
+	lambdaCO2PerGBSecond  = co2PerVCPUHour / 3600 * 1024 / lambdaMBPerVCPU
+	lambdaARMEnergyFactor = 0.8 // arm64 (Graviton) uses roughly 20% less energy for the same work
+)
+
+// EstimateLambdaCO2Monthly projects the function's observed GB-seconds to a 30 day month
+// and returns the resulting CO2 footprint in kg
+func EstimateLambdaCO2Monthly(function LambdaFunction) float64 {
+	days := EffectivePeriodDays(function.MetricsPeriodDays)
+	monthlyGBSeconds := function.GBSeconds7d * 30 / float64(days)
+
+	co2 := monthlyGBSeconds * lambdaCO2PerGBSecond
+	if function.Architecture == "arm64" {
+		co2 *= lambdaARMEnergyFactor
+	}
+	return co2
+}
+
+// AnalyzeLambdaWithBedrock uses Bedrock to generate optimization recommendations
+func AnalyzeLambdaWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	function LambdaFunction,
+	embeddings []float64,
+) (InvokeResult, error) {
+	// Create a prompt with detailed function information
+	functionText, err := formatLambdaFunctionForPrompt(function)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is a Lambda function record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+Please analyze this Lambda function for sustainability and cost optimization.
+Your analysis must include:
+1) Calculate the monthly CO2 footprint using the formula: monthly GB-seconds × %.10[3]f kg CO2/GB-second (multiply by %.1[4]f for arm64)
+2) Estimate monthly cost from invocations, duration, memory and architecture
+3) Identify inefficiencies (idle function with no invocations, memory far above what the duration suggests, timeout far above p95 duration, x86_64 where arm64 would work, high error rate)
+4) Calculate potential savings from memory rightsizing, migrating to arm64 (Graviton) or deleting idle functions
+5) Suggest specific actions for optimization
+6) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# Lambda Function Analysis: [FUNCTION_NAME]
+
+## Performance Metrics
+- Invocations (%[2]d-day total): [NUMBER]
+- Duration p95: [NUMBER] ms
+- Errors (%[2]d-day total): [NUMBER]
+- Compute (%[2]d-day total): [NUMBER] GB-seconds
+
+## Analysis
+
+[1-2 paragraphs general description]
+
+### Inefficiencies Identified
+
+1. [ISSUE 1]: [DESCRIPTION]
+2. [ISSUE 2]: [DESCRIPTION]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+2. [TIP 2]: [DESCRIPTION]
+`, functionText, EffectivePeriodDays(function.MetricsPeriodDays), lambdaCO2PerGBSecond, lambdaARMEnergyFactor)
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeLambda, prompt)
+}
+
+// formatLambdaFunctionForPrompt converts a Lambda function to a human-readable format for the LLM prompt
+func formatLambdaFunctionForPrompt(function LambdaFunction) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Function Name: %s\n", function.FunctionName))
+	if function.Runtime != "" {
+		sb.WriteString(fmt.Sprintf("Runtime: %s\n", function.Runtime))
+	}
+	if function.PackageType != "" {
+		sb.WriteString(fmt.Sprintf("Package Type: %s\n", function.PackageType))
+	}
+	sb.WriteString(fmt.Sprintf("Architecture: %s\n", function.Architecture))
+	sb.WriteString(fmt.Sprintf("Memory: %d MB\n", function.MemoryMB))
+	sb.WriteString(fmt.Sprintf("Timeout: %d seconds\n", function.TimeoutSec))
+
+	if !function.LastModified.IsZero() {
+		sb.WriteString(fmt.Sprintf("Last Modified: %s\n", function.LastModified.Format(time.RFC3339)))
+		age := time.Since(function.LastModified)
+		sb.WriteString(fmt.Sprintf("Days Since Last Change: %.1f\n", age.Hours()/24))
+	}
+
+	// Metrics
+	days := EffectivePeriodDays(function.MetricsPeriodDays)
+	sb.WriteString(fmt.Sprintf("Invocations (%d-day total): %.0f\n", days, function.Invocations7d))
+	sb.WriteString(fmt.Sprintf("Errors (%d-day total): %.0f\n", days, function.Errors7d))
+	sb.WriteString(fmt.Sprintf("Duration Average: %.1f ms\n", function.DurationAvgMs))
+	sb.WriteString(fmt.Sprintf("Duration p95: %.1f ms\n", function.DurationP95Ms))
+	sb.WriteString(fmt.Sprintf("Compute (%d-day total): %.1f GB-seconds\n", days, function.GBSeconds7d))
+	sb.WriteString(fmt.Sprintf("Estimated CO2 Footprint: %.4f kg CO2 per month\n", EstimateLambdaCO2Monthly(function)))
+	sb.WriteString(fmt.Sprintf("Idle (no invocations in %d days): %t\n", days, function.Idle))
+
+	// Tags
+	if len(function.Tags) > 0 {
+		sb.WriteString("\nTags:\n")
+		for k, v := range function.Tags {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", k, v))
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// lambdaLastModifiedLayout is the timestamp format Lambda uses for LastModified
+const lambdaLastModifiedLayout = "2006-01-02T15:04:05.000-0700"
+
+// LambdaFunction holds metadata and computed metrics for a Lambda function
+// - Invocations7d/Errors7d: total invocations and errors over the metrics window
+// - DurationAvgMs/DurationP95Ms: average and 95th percentile duration over the window
+// - GBSeconds7d: estimated compute used over the window (invocations × duration × memory)
+// - Idle: true when the function was not invoked in the window
+// - MetricsPeriodDays: length of the metrics window in days
+type LambdaFunction struct {
+	FunctionName      string            `json:"functionName"`
+	FunctionArn       string            `json:"functionArn"`
+	Runtime           string            `json:"runtime"`
+	MemoryMB          int32             `json:"memoryMB"`
+	TimeoutSec        int32             `json:"timeoutSec"`
+	Architecture      string            `json:"architecture"`
+	PackageType       string            `json:"packageType"`
+	LastModified      time.Time         `json:"lastModified"`
+	Region            string            `json:"region"`
+	AccountID         string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	Tags              map[string]string `json:"tags"`
+	Invocations7d     float64           `json:"invocations7d"`
+	Errors7d          float64           `json:"errors7d"`
+	DurationAvgMs     float64           `json:"durationAvgMs"`
+	DurationP95Ms     float64           `json:"durationP95Ms"`
+	GBSeconds7d       float64           `json:"gbSeconds7d"`
+	Idle              bool              `json:"idle"`
+	MetricsPeriodDays int               `json:"metricsPeriodDays"`
+}
+
+// ListLambdaFunctions retrieves all Lambda functions and their invocation metrics over the last daysBack days
+func ListLambdaFunctions(
+	ctx context.Context,
+	lambdaClient LambdaFunctionsAPI,
+	cwClient CloudWatchMetricsAPI,
+	maxFunctions int,
+	daysBack int,
+) ([]LambdaFunction, error) {
+	// Get list of functions
+	var functions []lambdaTypes.FunctionConfiguration
+	var marker *string
+
+	for {
+		resp, err := lambdaClient.ListFunctions(ctx, &lambda.ListFunctionsInput{
+			Marker:   marker,
+			MaxItems: aws.Int32(50),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		functions = append(functions, resp.Functions...)
+
+		// Check if there are more pages
+		if resp.NextMarker == nil {
+			break
+		}
+		marker = resp.NextMarker
+	}
+
+	// Apply limit if specified
+	if maxFunctions > 0 && len(functions) > maxFunctions {
+		Infof("Limiting Lambda scan to %d functions (found %d)", maxFunctions, len(functions))
+		functions = functions[:maxFunctions]
+	} else {
+		Infof("Processing %d Lambda functions", len(functions))
+	}
+
+	// Define time window for metrics: last daysBack days
+	daysBack = EffectivePeriodDays(daysBack)
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack)
+
+	// Process functions in parallel with a worker pool
+	results := make([]LambdaFunction, 0, len(functions))
+	resultsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
+
+	for _, function := range functions {
+		wg.Add(1)
+
+		go func(f lambdaTypes.FunctionConfiguration) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Set a timeout for processing each function
+			fnCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			lambdaFunction := collectLambdaData(fnCtx, lambdaClient, cwClient, f, startTime, endTime, daysBack)
+			lambdaFunction.MetricsPeriodDays = daysBack
+
+			// Add to results
+			resultsMutex.Lock()
+			results = append(results, lambdaFunction)
+			resultsMutex.Unlock()
+		}(function)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+
+	return results, nil
+}
+
+// collectLambdaData gathers all relevant data for a single Lambda function
+func collectLambdaData(
+	ctx context.Context,
+	lambdaClient LambdaFunctionsAPI,
+	cwClient CloudWatchMetricsAPI,
+	f lambdaTypes.FunctionConfiguration,
+	startTime, endTime time.Time,
+	daysBack int,
+) LambdaFunction {
+	functionName := aws.ToString(f.FunctionName)
+
+	function := LambdaFunction{
+		FunctionName: functionName,
+		FunctionArn:  aws.ToString(f.FunctionArn),
+		Runtime:      string(f.Runtime),
+		MemoryMB:     aws.ToInt32(f.MemorySize),
+		TimeoutSec:   aws.ToInt32(f.Timeout),
+		Architecture: string(lambdaTypes.ArchitectureX8664),
+		PackageType:  string(f.PackageType),
+		Region:       lambdaClient.Options().Region,
+		Tags:         make(map[string]string),
+	}
+
+	// Functions list a single architecture; x86_64 is the default when none is reported
+	if len(f.Architectures) > 0 {
+		function.Architecture = string(f.Architectures[0])
+	}
+
+	if lastModified, err := time.Parse(lambdaLastModifiedLayout, aws.ToString(f.LastModified)); err == nil {
+		function.LastModified = lastModified
+	}
+
+	// Get tags
+	tagsResp, err := lambdaClient.ListTags(ctx, &lambda.ListTagsInput{
+		Resource: f.FunctionArn,
+	})
+	if err != nil {
+		Warnf("Unable to get tags for Lambda function %s: %v", functionName, err)
+	} else if tagsResp.Tags != nil {
+		function.Tags = tagsResp.Tags
+	}
+
+	// A single period covering the whole window gives one datapoint per statistic
+	period := int32(daysBack * 86400)
+
+	invocations, err := getLambdaMetric(ctx, cwClient, functionName, "Invocations", period, startTime, endTime,
+		[]types.Statistic{types.StatisticSum}, nil)
+	if err != nil {
+		Warnf("Unable to get invocations for Lambda function %s: %v", functionName, err)
+	}
+	for _, dp := range invocations {
+		function.Invocations7d += aws.ToFloat64(dp.Sum)
+	}
+
+	errorsDps, err := getLambdaMetric(ctx, cwClient, functionName, "Errors", period, startTime, endTime,
+		[]types.Statistic{types.StatisticSum}, nil)
+	if err != nil {
+		Warnf("Unable to get errors for Lambda function %s: %v", functionName, err)
+	}
+	for _, dp := range errorsDps {
+		function.Errors7d += aws.ToFloat64(dp.Sum)
+	}
+
+	duration, err := getLambdaMetric(ctx, cwClient, functionName, "Duration", period, startTime, endTime,
+		[]types.Statistic{types.StatisticAverage, types.StatisticSampleCount}, []string{"p95"})
+	if err != nil {
+		Warnf("Unable to get duration for Lambda function %s: %v", functionName, err)
+	}
+	var durationTotal, samples float64
+	for _, dp := range duration {
+		durationTotal += aws.ToFloat64(dp.Average) * aws.ToFloat64(dp.SampleCount)
+		samples += aws.ToFloat64(dp.SampleCount)
+		if p95, ok := dp.ExtendedStatistics["p95"]; ok && p95 > function.DurationP95Ms {
+			function.DurationP95Ms = p95
+		}
+	}
+	if samples > 0 {
+		function.DurationAvgMs = durationTotal / samples
+	}
+
+	// Lambda bills compute as allocated memory × duration
+	function.GBSeconds7d = function.Invocations7d * (function.DurationAvgMs / 1000) * (float64(function.MemoryMB) / 1024)
+	function.Idle = function.Invocations7d == 0
+
+	return function
+}
+
+// getLambdaMetric retrieves the datapoints of a CloudWatch metric for a Lambda function
+func getLambdaMetric(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	functionName, metricName string,
+	period int32,
+	startTime, endTime time.Time,
+	statistics []types.Statistic,
+	extendedStatistics []string,
+) ([]types.Datapoint, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/Lambda"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.Dimension{{
+			Name:  aws.String("FunctionName"),
+			Value: aws.String(functionName),
+		}},
+		StartTime:          &startTime,
+		EndTime:            &endTime,
+		Period:             aws.Int32(period),
+		Statistics:         statistics,
+		ExtendedStatistics: extendedStatistics,
+	}
+
+	resp, err := cwClient.GetMetricStatistics(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Datapoints, nil
+}
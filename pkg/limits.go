@@ -0,0 +1,261 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScanResourceTypes lists the resource types ScanResources can scan, in the order --help shows them
+var ScanResourceTypes = []string{"ec2", "s3", "rds", "ebs", "lambda", "elb", "network", "dynamodb", "elasticache", "snapshots"}
+
+// ParseTypeLimits parses per-type resource limits written as "ec2=20,s3=5"
+func ParseTypeLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		resType, value, ok := strings.Cut(entry, "=")
+		resType = strings.TrimSpace(resType)
+		if !ok {
+			return nil, fmt.Errorf("invalid limit %q: expected type=count, e.g. ec2=20", entry)
+		}
+		if !slices.Contains(ScanResourceTypes, resType) {
+			return nil, fmt.Errorf("invalid limit %q: unknown resource type %q", entry, resType)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %q: count must be a positive whole number", entry)
+		}
+		limits[resType] = limit
+	}
+	return limits, nil
+}
+
+// AllocateLimits shares a total resource limit between resource types. found holds how many
+// resources of each type were scanned and typeLimits optional caps per type. Every type gets
+// an equal share; what a type cannot use, because fewer of its resources were found or its
+// cap is lower, is shared again among the others, so a handful of S3 buckets is never crowded
+// out by hundreds of EC2 instances. Slots that do not divide evenly go to the types listed
+// first. A total of 0 or less applies only the per-type caps.
+func AllocateLimits(types []string, found map[string]int, total int, typeLimits map[string]int) map[string]int {
+	caps := make(map[string]int, len(types))
+	for _, resType := range types {
+		available := found[resType]
+		if limit := typeLimits[resType]; limit > 0 && limit < available {
+			available = limit
+		}
+		caps[resType] = available
+	}
+	if total <= 0 {
+		return caps
+	}
+
+	alloc := make(map[string]int, len(types))
+	var active []string
+	for _, resType := range types {
+		if caps[resType] > 0 && !slices.Contains(active, resType) {
+			active = append(active, resType)
+		}
+	}
+
+	remaining := total
+	for len(active) > 0 && remaining > 0 {
+		share := remaining / len(active)
+		if share == 0 {
+			// Fewer slots than types still wanting more
+			for _, resType := range active[:remaining] {
+				alloc[resType]++
+			}
+			break
+		}
+
+		var next []string
+		for _, resType := range active {
+			give := min(share, caps[resType]-alloc[resType])
+			alloc[resType] += give
+			remaining -= give
+			if alloc[resType] < caps[resType] {
+				next = append(next, resType)
+			}
+		}
+		active = next
+	}
+	return alloc
+}
+
+// maxSkippedIDsLogged bounds how many skipped resource IDs are listed in the log
+const maxSkippedIDsLogged = 20
+
+// SelectTopResources returns the n items with the highest score, keeping the scanned order
+// among equal scores, and the items left out. n of 0 or less selects every item.
+func SelectTopResources[T any](items []T, n int, score func(T) float64) (selected, skipped []T) {
+	if n <= 0 || len(items) <= n {
+		return items, nil
+	}
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return score(sorted[i]) > score(sorted[j])
+	})
+	return sorted[:n], sorted[n:]
+}
+
+// limitScanResults applies the total and per-type limits to merged scan results in place.
+// A type left without a share of the total is dropped rather than kept whole.
+func limitScanResults(results map[string]interface{}, types []string, total int, typeLimits map[string]int) {
+	found := make(map[string]int, len(results))
+	for name, result := range results {
+		found[name] = scanResultLen(result)
+	}
+	alloc := AllocateLimits(types, found, total, typeLimits)
+	for name, result := range results {
+		if alloc[name] == 0 && found[name] > 0 && total > 0 {
+			Infof("Limit of %d reached before any %s resources; skipping %d", total, name, found[name])
+			delete(results, name)
+			continue
+		}
+		results[name] = limitScanResult(result, alloc[name])
+	}
+}
+
+// selectForLimit applies a limit to one resource type with SelectTopResources and logs
+// which resources were skipped, so users know what was not analyzed
+func selectForLimit[T any](items []T, n int, kind string, score func(T) float64, id func(T) string) []T {
+	selected, skipped := SelectTopResources(items, n, score)
+	if len(skipped) == 0 {
+		return selected
+	}
+
+	Infof("Limiting %s to the %d most promising of %d", kind, len(selected), len(items))
+	ids := make([]string, 0, min(len(skipped), maxSkippedIDsLogged))
+	for _, item := range skipped[:cap(ids)] {
+		ids = append(ids, id(item))
+	}
+	if more := len(skipped) - len(ids); more > 0 {
+		Infof("Skipped %s not analyzed: %s and %d more", kind, strings.Join(ids, ", "), more)
+	} else {
+		Infof("Skipped %s not analyzed: %s", kind, strings.Join(ids, ", "))
+	}
+	return selected
+}
+
+// idleShare returns the unused share of a utilization percentage, from 0 to 1
+func idleShare(utilization float64) float64 {
+	return (100 - min(max(utilization, 0), 100)) / 100
+}
+
+// ScoreEC2Instance ranks an instance by its size in vCPUs weighted by how idle it is, so an
+// idle m5.4xlarge comes before a busy one and before any number of idle t3.micros
+func ScoreEC2Instance(i Instance) float64 {
+	return float64(instanceVCPUs(i.InstanceType)) * idleShare(i.CPUAvg7d)
+}
+
+// ScoreS3Bucket ranks a bucket by size, counting buckets without lifecycle rules double
+// since nothing moves their data to cheaper storage
+func ScoreS3Bucket(b S3Bucket) float64 {
+	score := float64(b.SizeBytes)
+	if len(b.LifecycleRules) == 0 {
+		score *= 2
+	}
+	return score
+}
+
+// ScoreRDSInstance ranks an instance by allocated storage, divided down by its average
+// connection count so that large, little-used databases come first
+func ScoreRDSInstance(i RDSInstance) float64 {
+	return float64(i.AllocatedStorage) / (1 + max(i.ConnectionsAvg7d, 0))
+}
+
+// ScoreEBSVolume ranks a volume by size, counting unattached or idle volumes double
+func ScoreEBSVolume(v EBSVolume) float64 {
+	score := float64(v.SizeGiB)
+	if !v.Attached || v.Idle {
+		score *= 2
+	}
+	return score
+}
+
+// ScoreLambdaFunction ranks a function by its configured memory
+func ScoreLambdaFunction(f LambdaFunction) float64 {
+	return float64(f.MemoryMB)
+}
+
+// ScoreDynamoTable ranks a table by size
+func ScoreDynamoTable(t DynamoTable) float64 {
+	return float64(t.SizeBytes)
+}
+
+// ScoreElastiCacheCluster ranks a cluster by node count weighted by how idle its CPU is
+func ScoreElastiCacheCluster(c ElastiCacheCluster) float64 {
+	return float64(c.NodeCount) * idleShare(c.CPUAvg7d)
+}
+
+// ScoreSnapshot ranks a snapshot by size
+func ScoreSnapshot(s EBSSnapshot) float64 {
+	return float64(s.SizeGiB)
+}
+
+// unranked keeps the scanned order for resources with nothing to rank on: load balancers
+// and network resources are only reported when idle
+func unranked[T any](T) float64 {
+	return 0
+}
+
+// limitScanResult keeps the n most promising resources of a merged scan result
+func limitScanResult(result interface{}, n int) interface{} {
+	switch r := result.(type) {
+	case []Instance:
+		return selectForLimit(r, n, "EC2 instances", ScoreEC2Instance, func(i Instance) string { return i.InstanceID })
+	case []S3Bucket:
+		return selectForLimit(r, n, "S3 buckets", ScoreS3Bucket, func(b S3Bucket) string { return b.BucketName })
+	case []RDSInstance:
+		return selectForLimit(r, n, "RDS instances", ScoreRDSInstance, func(i RDSInstance) string { return i.InstanceID })
+	case []EBSVolume:
+		return selectForLimit(r, n, "EBS volumes", ScoreEBSVolume, func(v EBSVolume) string { return v.VolumeID })
+	case []LambdaFunction:
+		return selectForLimit(r, n, "Lambda functions", ScoreLambdaFunction, func(f LambdaFunction) string { return f.FunctionName })
+	case []DynamoTable:
+		return selectForLimit(r, n, "DynamoDB tables", ScoreDynamoTable, func(t DynamoTable) string { return t.TableName })
+	case []ElastiCacheCluster:
+		return selectForLimit(r, n, "ElastiCache clusters", ScoreElastiCacheCluster, func(c ElastiCacheCluster) string { return c.ClusterID })
+	case []LoadBalancer:
+		return selectForLimit(r, n, "load balancers", unranked[LoadBalancer], func(lb LoadBalancer) string { return lb.Name })
+	case []NetworkResource:
+		return selectForLimit(r, n, "network resources", unranked[NetworkResource], func(nr NetworkResource) string { return nr.ResourceID })
+	case []EBSSnapshot:
+		return selectForLimit(r, n, "EBS snapshots", ScoreSnapshot, func(s EBSSnapshot) string { return s.SnapshotID })
+	}
+	return result
+}
+
+// scanResultLen returns how many resources a scan result holds
+func scanResultLen(result interface{}) int {
+	switch r := result.(type) {
+	case []Instance:
+		return len(r)
+	case []S3Bucket:
+		return len(r)
+	case []RDSInstance:
+		return len(r)
+	case []EBSVolume:
+		return len(r)
+	case []LambdaFunction:
+		return len(r)
+	case []LoadBalancer:
+		return len(r)
+	case []NetworkResource:
+		return len(r)
+	case []EBSSnapshot:
+		return len(r)
+	case []DynamoTable:
+		return len(r)
+	case []ElastiCacheCluster:
+		return len(r)
+	}
+	return 0
+}
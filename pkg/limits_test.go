@@ -0,0 +1,309 @@
+package pkg
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseTypeLimits(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]int
+		wantErr bool
+	}{
+		{in: "", want: map[string]int{}},
+		{in: "ec2=20", want: map[string]int{"ec2": 20}},
+		{in: " ec2 = 20 , s3=5,", want: map[string]int{"ec2": 20, "s3": 5}},
+		{in: "ec2=20,ec2=3", want: map[string]int{"ec2": 3}},
+		{in: "ec2", wantErr: true},
+		{in: "ec3=1", wantErr: true},
+		{in: "s3=0", wantErr: true},
+		{in: "s3=-1", wantErr: true},
+		{in: "s3=five", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTypeLimits(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTypeLimits(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ParseTypeLimits(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateLimits(t *testing.T) {
+	types := []string{"ec2", "s3", "rds"}
+
+	tests := []struct {
+		name       string
+		types      []string
+		found      map[string]int
+		total      int
+		typeLimits map[string]int
+		want       map[string]int
+	}{
+		{
+			name:  "equal shares",
+			found: map[string]int{"ec2": 100, "s3": 100, "rds": 100},
+			total: 30,
+			want:  map[string]int{"ec2": 10, "s3": 10, "rds": 10},
+		},
+		{
+			name:  "remainder goes to the types listed first",
+			found: map[string]int{"ec2": 100, "s3": 100, "rds": 100},
+			total: 11,
+			want:  map[string]int{"ec2": 4, "s3": 4, "rds": 3},
+		},
+		{
+			name:  "unused share is shared again",
+			found: map[string]int{"ec2": 100, "s3": 2, "rds": 5},
+			total: 30,
+			want:  map[string]int{"ec2": 23, "s3": 2, "rds": 5},
+		},
+		{
+			name:  "more room than resources",
+			found: map[string]int{"ec2": 3, "s3": 2, "rds": 1},
+			total: 100,
+			want:  map[string]int{"ec2": 3, "s3": 2, "rds": 1},
+		},
+		{
+			name:  "types without resources get nothing",
+			found: map[string]int{"ec2": 100, "s3": 100},
+			total: 10,
+			want:  map[string]int{"ec2": 5, "s3": 5},
+		},
+		{
+			name:       "per-type caps",
+			found:      map[string]int{"ec2": 100, "s3": 100, "rds": 100},
+			total:      30,
+			typeLimits: map[string]int{"ec2": 2},
+			want:       map[string]int{"ec2": 2, "s3": 14, "rds": 14},
+		},
+		{
+			name:       "caps only",
+			found:      map[string]int{"ec2": 100, "s3": 3, "rds": 100},
+			typeLimits: map[string]int{"ec2": 20, "s3": 5},
+			want:       map[string]int{"ec2": 20, "s3": 3, "rds": 100},
+		},
+		{
+			name:  "fewer slots than types",
+			found: map[string]int{"ec2": 100, "s3": 100, "rds": 100},
+			total: 2,
+			want:  map[string]int{"ec2": 1, "s3": 1},
+		},
+		{
+			name:  "duplicate types count once",
+			types: []string{"ec2", "ec2", "s3"},
+			found: map[string]int{"ec2": 100, "s3": 100},
+			total: 10,
+			want:  map[string]int{"ec2": 5, "s3": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanTypes := tt.types
+			if scanTypes == nil {
+				scanTypes = types
+			}
+			got := AllocateLimits(scanTypes, tt.found, tt.total, tt.typeLimits)
+
+			sum := 0
+			for _, resType := range scanTypes {
+				if got[resType] != tt.want[resType] {
+					t.Errorf("%s = %d, want %d (allocation %v)", resType, got[resType], tt.want[resType], got)
+				}
+				if got[resType] > tt.found[resType] {
+					t.Errorf("%s allocated %d of %d found", resType, got[resType], tt.found[resType])
+				}
+			}
+			for _, n := range got {
+				sum += n
+			}
+			if tt.total > 0 && sum > tt.total {
+				t.Errorf("allocated %d in total, more than %d", sum, tt.total)
+			}
+		})
+	}
+}
+
+func TestScanOptionsTypeLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxItems   int
+		typeLimits map[string]int
+		want       int
+	}{
+		{name: "no limits", want: 0},
+		{name: "total only", maxItems: 50, want: 50},
+		{name: "type only", typeLimits: map[string]int{"s3": 5}, want: 5},
+		{name: "type below total", maxItems: 50, typeLimits: map[string]int{"s3": 5}, want: 5},
+		{name: "type above total", maxItems: 3, typeLimits: map[string]int{"s3": 5}, want: 3},
+		{name: "other type's cap", maxItems: 50, typeLimits: map[string]int{"ec2": 5}, want: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ScanOptions{MaxItems: tt.maxItems, TypeLimits: tt.typeLimits}
+			if got := opts.typeLimit("s3"); got != tt.want {
+				t.Errorf("typeLimit(s3) = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// A type that gets no share of the total must not slip through with every resource it found
+func TestLimitScanResults(t *testing.T) {
+	scanned := func() map[string]interface{} {
+		return map[string]interface{}{
+			"ec2": []Instance{{InstanceID: "i-1"}, {InstanceID: "i-2"}, {InstanceID: "i-3"}},
+			"s3":  []S3Bucket{{BucketName: "b-1"}, {BucketName: "b-2"}},
+			"rds": []RDSInstance{{InstanceID: "db-1"}},
+		}
+	}
+	types := []string{"ec2", "s3", "rds"}
+
+	tests := []struct {
+		name       string
+		total      int
+		typeLimits map[string]int
+		want       map[string]int
+	}{
+		{name: "no limit", want: map[string]int{"ec2": 3, "s3": 2, "rds": 1}},
+		{name: "room for everything", total: 10, want: map[string]int{"ec2": 3, "s3": 2, "rds": 1}},
+		{name: "shared", total: 4, want: map[string]int{"ec2": 2, "s3": 1, "rds": 1}},
+		{name: "fewer slots than types", total: 2, want: map[string]int{"ec2": 1, "s3": 1}},
+		{name: "per-type caps only", typeLimits: map[string]int{"ec2": 1}, want: map[string]int{"ec2": 1, "s3": 2, "rds": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := scanned()
+			limitScanResults(results, types, tt.total, tt.typeLimits)
+
+			got := make(map[string]int, len(results))
+			for name, result := range results {
+				got[name] = scanResultLen(result)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectTopResources(t *testing.T) {
+	items := []int{3, 9, 1, 9, 5}
+	score := func(i int) float64 { return float64(i) }
+
+	tests := []struct {
+		n           int
+		wantSel     []int
+		wantSkipped []int
+	}{
+		{n: 0, wantSel: []int{3, 9, 1, 9, 5}},
+		{n: -1, wantSel: []int{3, 9, 1, 9, 5}},
+		{n: 5, wantSel: []int{3, 9, 1, 9, 5}},
+		{n: 10, wantSel: []int{3, 9, 1, 9, 5}},
+		{n: 1, wantSel: []int{9}, wantSkipped: []int{9, 5, 3, 1}},
+		{n: 3, wantSel: []int{9, 9, 5}, wantSkipped: []int{3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			selected, skipped := SelectTopResources(items, tt.n, score)
+			if fmt.Sprint(selected) != fmt.Sprint(tt.wantSel) || fmt.Sprint(skipped) != fmt.Sprint(tt.wantSkipped) {
+				t.Errorf("SelectTopResources(%d) = %v, %v; want %v, %v", tt.n, selected, skipped, tt.wantSel, tt.wantSkipped)
+			}
+		})
+	}
+	if fmt.Sprint(items) != "[3 9 1 9 5]" {
+		t.Errorf("SelectTopResources() reordered its input to %v", items)
+	}
+}
+
+// Equal scores keep the order the resources were scanned in
+func TestSelectTopResourcesIsStable(t *testing.T) {
+	instances := []Instance{{InstanceID: "i-1"}, {InstanceID: "i-2"}, {InstanceID: "i-3"}, {InstanceID: "i-4"}}
+	selected, _ := SelectTopResources(instances, 2, unranked[Instance])
+	if selected[0].InstanceID != "i-1" || selected[1].InstanceID != "i-2" {
+		t.Errorf("selected %s and %s, want i-1 and i-2", selected[0].InstanceID, selected[1].InstanceID)
+	}
+}
+
+// Each pair lists the resource that must rank higher first
+func TestScoreOrdering(t *testing.T) {
+	tests := []struct {
+		name          string
+		higher, lower float64
+	}{
+		{
+			name:   "idle instance over busy one of the same type",
+			higher: ScoreEC2Instance(Instance{InstanceType: "m5.4xlarge", CPUAvg7d: 2}),
+			lower:  ScoreEC2Instance(Instance{InstanceType: "m5.4xlarge", CPUAvg7d: 80}),
+		},
+		{
+			name:   "idle large instance over idle small one",
+			higher: ScoreEC2Instance(Instance{InstanceType: "m5.4xlarge", CPUAvg7d: 5}),
+			lower:  ScoreEC2Instance(Instance{InstanceType: "t3.micro", CPUAvg7d: 0}),
+		},
+		{
+			name:   "bucket without lifecycle rules over one with the same size",
+			higher: ScoreS3Bucket(S3Bucket{SizeBytes: 1 << 30}),
+			lower:  ScoreS3Bucket(S3Bucket{SizeBytes: 1 << 30, LifecycleRules: []LifecycleRuleInfo{{}}}),
+		},
+		{
+			name:   "large bucket over small one",
+			higher: ScoreS3Bucket(S3Bucket{SizeBytes: 10 << 30, LifecycleRules: []LifecycleRuleInfo{{}}}),
+			lower:  ScoreS3Bucket(S3Bucket{SizeBytes: 1 << 30}),
+		},
+		{
+			name:   "unused database over busy one of the same size",
+			higher: ScoreRDSInstance(RDSInstance{AllocatedStorage: 100, ConnectionsAvg7d: 0}),
+			lower:  ScoreRDSInstance(RDSInstance{AllocatedStorage: 100, ConnectionsAvg7d: 50}),
+		},
+		{
+			name:   "unattached volume over attached one of the same size",
+			higher: ScoreEBSVolume(EBSVolume{SizeGiB: 100}),
+			lower:  ScoreEBSVolume(EBSVolume{SizeGiB: 100, Attached: true}),
+		},
+		{
+			name:   "idle cluster over busy one with as many nodes",
+			higher: ScoreElastiCacheCluster(ElastiCacheCluster{NodeCount: 3, CPUAvg7d: 1}),
+			lower:  ScoreElastiCacheCluster(ElastiCacheCluster{NodeCount: 3, CPUAvg7d: 60}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.higher <= tt.lower {
+				t.Errorf("score %v does not rank above %v", tt.higher, tt.lower)
+			}
+		})
+	}
+}
+
+func TestScoreEC2InstanceBounds(t *testing.T) {
+	tests := []struct {
+		cpu  float64
+		want float64
+	}{
+		{cpu: 0, want: 16},
+		{cpu: 25, want: 12},
+		{cpu: 100, want: 0},
+		{cpu: 150, want: 0},
+		{cpu: -10, want: 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.cpu), func(t *testing.T) {
+			if got := ScoreEC2Instance(Instance{InstanceType: "m5.4xlarge", CPUAvg7d: tt.cpu}); got != tt.want {
+				t.Errorf("ScoreEC2Instance(m5.4xlarge at %v%%) = %v, want %v", tt.cpu, got, tt.want)
+			}
+		})
+	}
+}
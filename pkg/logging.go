@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel orders log messages by severity; messages below the logger's level are dropped
+type LogLevel int
+
+// Log levels, from most to least verbose
+const (
+	LevelDebug LogLevel = iota // raw payloads, responses and step-by-step tracing
+	LevelInfo                  // progress of scans, jobs and items
+	LevelWarn                  // recoverable problems, e.g. a metric or price that could not be fetched
+	LevelError                 // failures that lose a resource, an item or a request
+)
+
+// String returns the level's name as accepted by ParseLogLevel
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLogLevel parses a level name such as "debug" or "WARN"
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", s)
+	}
+}
+
+// Logger writes leveled log lines. It always writes to stderr, so stdout stays free for
+// reports and, in Lambda, for EMF metric records.
+type Logger struct {
+	std   *log.Logger
+	level LogLevel
+}
+
+// NewLogger creates a logger that drops messages below level. flags are the standard
+// log package flags, e.g. log.LstdFlags or log.Lshortfile.
+func NewLogger(level LogLevel, flags int) *Logger {
+	return &Logger{std: log.New(os.Stderr, "", flags), level: level}
+}
+
+// NewLoggerFromEnv creates the Lambda logger, taking its level from the LOG_LEVEL variable
+func NewLoggerFromEnv() *Logger {
+	level, err := ParseLogLevel(os.Getenv("LOG_LEVEL"))
+	logger := NewLogger(level, log.LstdFlags)
+	if err != nil {
+		logger.logf(LevelWarn, "%v; using info", err)
+	}
+	return logger
+}
+
+// Enabled reports whether messages at level are written
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level >= l.level
+}
+
+// logf writes one message; callDepth 3 makes Lshortfile name the caller of Debugf and friends
+func (l *Logger) logf(level LogLevel, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.std.Output(3, "["+strings.ToUpper(level.String())+"] "+fmt.Sprintf(format, args...))
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	defaultLogger.Store(NewLogger(LevelInfo, log.LstdFlags))
+}
+
+// SetLogger replaces the logger used by Debugf, Infof, Warnf, Errorf and Fatalf
+func SetLogger(l *Logger) {
+	defaultLogger.Store(l)
+}
+
+// DebugEnabled reports whether debug messages are written, to skip building expensive ones
+func DebugEnabled() bool {
+	return defaultLogger.Load().Enabled(LevelDebug)
+}
+
+// Debugf logs raw payloads and tracing detail
+func Debugf(format string, args ...interface{}) {
+	defaultLogger.Load().logf(LevelDebug, format, args...)
+}
+
+// Infof logs progress
+func Infof(format string, args ...interface{}) {
+	defaultLogger.Load().logf(LevelInfo, format, args...)
+}
+
+// Warnf logs a recoverable problem
+func Warnf(format string, args ...interface{}) {
+	defaultLogger.Load().logf(LevelWarn, format, args...)
+}
+
+// Errorf logs a failure
+func Errorf(format string, args ...interface{}) {
+	defaultLogger.Load().logf(LevelError, format, args...)
+}
+
+// Fatalf logs a failure and exits with status 1, like log.Fatalf
+func Fatalf(format string, args ...interface{}) {
+	defaultLogger.Load().logf(LevelError, format, args...)
+	os.Exit(1)
+}
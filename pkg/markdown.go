@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// severityEmoji marks each severity in the markdown report
+var severityEmoji = map[string]string{
+	SeverityHigh:   "🔴",
+	SeverityMedium: "🟠",
+	SeverityLow:    "🟡",
+	SeverityOK:     "🟢",
+}
+
+// markdownHeadingOffset demotes model headings so they nest below the report's own "###" level
+const markdownHeadingOffset = 2
+
+// FormatAnalysisReportMarkdown writes the analysis report as GitHub-flavored markdown, suitable
+// for a pull or merge request comment: a summary table, a table of resources by potential
+// savings, and a collapsible section with the analysis of each resource
+func FormatAnalysisReportMarkdown(w io.Writer, report []ReportItem) error {
+	summary := SummarizeReport(report)
+	var sb strings.Builder
+
+	sb.WriteString("## 🌱 GreenOps Analysis Report\n\n")
+	fmt.Fprintf(&sb, "_Generated: %s by %s_\n\n", time.Now().Format(time.RFC1123), ReportGenerator())
+
+	// Sustainability summary
+	sb.WriteString("### Sustainability Impact Summary\n\n")
+	sb.WriteString("| Metric | Current (monthly) | Potential savings |\n")
+	sb.WriteString("|---|---:|---:|\n")
+	fmt.Fprintf(&sb, "| CO2 emissions | %.2f kg CO2e | %.2f kg CO2e |\n", summary.TotalCO2, summary.PotentialCO2Savings)
+	fmt.Fprintf(&sb, "| Cost | $%.2f | $%.2f |\n", summary.TotalCost, summary.PotentialCostSavings)
+	fmt.Fprintf(&sb, "\nResources analyzed: %d", summary.TotalResources)
+	if len(summary.CostSources) > 0 {
+		fmt.Fprintf(&sb, "; pricing: %s", describeCostSources(summary.CostSources))
+	}
+	if !summary.AnalysisUsage.IsZero() {
+		fmt.Fprintf(&sb, "; analysis cost: $%.2f", summary.AnalysisCostUSD)
+	}
+	sb.WriteString(".\n\n")
+
+	if len(report) > 0 {
+		// Resources, biggest potential savings first
+		sb.WriteString("### Resources\n\n")
+		sb.WriteString("| | Type | Resource | Region | Key metric | CO2 (kg/mo) | Cost ($/mo) | Savings ($/mo) |\n")
+		sb.WriteString("|---|---|---|---|---|---:|---:|---:|\n")
+		for _, row := range SummarizeResources(report) {
+			badge := severityEmoji[row.Severity]
+			if row.Idle {
+				badge += " " + IdleBadge
+			}
+			fmt.Fprintf(&sb, "| %s | %s | `%s` | %s | %s | %.2f | %.2f | %.2f (%.0f%%) |\n",
+				badge, row.ResourceType, escapeMarkdownCell(row.ResourceID), escapeMarkdownCell(row.Region),
+				escapeMarkdownCell(row.KeyMetric), row.CO2KgMonthly, row.MonthlyCost, row.MonthlySavings, row.SavingsPct)
+		}
+
+		// One collapsible section per resource
+		sb.WriteString("\n### Details\n\n")
+		for _, item := range report {
+			row := summarizeResource(item)
+			title := fmt.Sprintf("%s %s", strings.ToUpper(string(row.ResourceType)), row.ResourceID)
+			if item.Rank > 0 {
+				title = fmt.Sprintf("#%d %s", item.Rank, title)
+			}
+			fmt.Fprintf(&sb, "<details>\n<summary>%s <b>%s</b> - $%.2f/mo potential savings</summary>\n\n",
+				severityEmoji[row.Severity], escapeMarkdownHTML(title), row.MonthlySavings)
+			if quality := item.AnalysisQuality; quality.IsLow() {
+				fmt.Fprintf(&sb, "> ⚠️ Incomplete analysis (completeness %d/100, missing %s); treat its figures with caution.\n\n",
+					quality.Score, escapeMarkdownHTML(strings.Join(quality.Missing, ", ")))
+			}
+			sb.WriteString(demoteMarkdownHeadings(strings.TrimSpace(item.Analysis), markdownHeadingOffset))
+			sb.WriteString("\n\n</details>\n\n")
+		}
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("failed to write markdown report: %w", err)
+	}
+	return nil
+}
+
+// demoteMarkdownHeadings lowers every heading outside code fences by offset levels, capped at
+// level 6, so model output nests under the report's own headings
+func demoteMarkdownHeadings(text string, offset int) string {
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if m := markdownHeadingRegex.FindStringSubmatch(trimmed); m != nil {
+			level := min(len(m[1])+offset, 6)
+			lines[i] = strings.Repeat("#", level) + " " + m[2]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeMarkdownCell keeps a value from breaking out of its table cell
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// escapeMarkdownHTML escapes a value placed inside the HTML of a <summary> element
+func escapeMarkdownHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
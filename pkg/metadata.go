@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// ReportMetadata says what a report describes, so an archived report stays unambiguous: the
+// AWS account and caller that scanned it, and the parameters of the scan.
+// - Caller: the role name of the caller's ARN; users and session names are not kept
+// - Accounts: the accounts an --org scan covered, empty for a single account
+// - Regions: the regions scanned, as configured, e.g. ["all"]
+type ReportMetadata struct {
+	AccountID     string   `json:"account_id,omitempty" dynamodbav:"account_id,omitempty"`
+	Caller        string   `json:"caller,omitempty" dynamodbav:"caller,omitempty"`
+	Accounts      []string `json:"accounts,omitempty" dynamodbav:"accounts,omitempty"`
+	Regions       []string `json:"regions,omitempty" dynamodbav:"regions,omitempty"`
+	ResourceTypes []string `json:"resource_types,omitempty" dynamodbav:"resource_types,omitempty"`
+	Limit         int      `json:"limit,omitempty" dynamodbav:"limit,omitempty"`
+	MetricsDays   int      `json:"metrics_days,omitempty" dynamodbav:"metrics_days,omitempty"`
+}
+
+// CallerName redacts a caller ARN from GetCallerIdentity to the role name: "Admin" for
+// arn:aws:sts::123456789012:assumed-role/Admin/jane@example.com. IAM users, federated users
+// and the root user are only named by their kind.
+func CallerName(arn string) string {
+	fields := strings.SplitN(arn, ":", 6)
+	if len(fields) < 6 {
+		return ""
+	}
+	parts := strings.Split(fields[5], "/")
+	switch parts[0] {
+	case "assumed-role":
+		if len(parts) > 1 {
+			return parts[1]
+		}
+	case "role":
+		return parts[len(parts)-1]
+	case "user":
+		return "IAM user"
+	case "federated-user":
+		return "federated user"
+	case "root":
+		return "root"
+	}
+	return ""
+}
+
+// NewSTSClientFromConfig creates an STS client from an AWS config
+func NewSTSClientFromConfig(cfg aws.Config) *sts.Client {
+	return sts.NewFromConfig(cfg)
+}
+
+// ResolveCaller fills AccountID and Caller from STS GetCallerIdentity, which needs no
+// permissions
+func (m *ReportMetadata) ResolveCaller(ctx context.Context, client STSIdentityAPI) error {
+	identity, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	m.AccountID = aws.ToString(identity.Account)
+	m.Caller = CallerName(aws.ToString(identity.Arn))
+	return nil
+}
+
+// Lines describes the metadata one "Label: value" line at a time, for the report headers
+func (m *ReportMetadata) Lines() []string {
+	if m == nil {
+		return nil
+	}
+	var lines []string
+	if m.AccountID != "" {
+		account := m.AccountID
+		if m.Caller != "" {
+			account += " as " + m.Caller
+		}
+		lines = append(lines, "Account: "+account)
+	}
+	if len(m.Accounts) > 0 {
+		lines = append(lines, fmt.Sprintf("Accounts scanned: %d (%s)", len(m.Accounts), strings.Join(m.Accounts, ", ")))
+	}
+	if len(m.Regions) > 0 {
+		lines = append(lines, "Regions: "+strings.Join(m.Regions, ", "))
+	}
+
+	var scan []string
+	if len(m.ResourceTypes) > 0 {
+		scan = append(scan, strings.Join(m.ResourceTypes, ", "))
+	}
+	if m.Limit > 0 {
+		scan = append(scan, fmt.Sprintf("limit %d", m.Limit))
+	}
+	if m.MetricsDays > 0 {
+		scan = append(scan, fmt.Sprintf("%d days of metrics", m.MetricsDays))
+	}
+	if len(scan) > 0 {
+		lines = append(lines, "Scan: "+strings.Join(scan, "; "))
+	}
+	return lines
+}
+
+// printReportMetadata prints the metadata under the "Generated:" line of the text report
+func printReportMetadata(w io.Writer, meta *ReportMetadata, colorize bool) {
+	for _, line := range meta.Lines() {
+		if label, value, ok := strings.Cut(line, ": "); ok && colorize {
+			fmt.Fprintf(w, "%s%s:%s %s\n", ColorCyan, label, ColorReset, value)
+			continue
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// maxMetricDataQueries is the GetMetricData limit on queries per request
+const maxMetricDataQueries = 500
+
+// metricQuery describes one CloudWatch metric to fetch for one resource
+type metricQuery struct {
+	ResourceID string
+	Namespace  string
+	MetricName string
+	Dimensions []cwTypes.Dimension
+	Stat       string // e.g. "Average" or "Sum"
+	Period     int32  // seconds
+	Key        string // result key, for fetching one metric twice; MetricName unless set
+	Peak       bool   // keep the highest datapoint instead of the mean
+}
+
+// key returns the key of the query's value in the fetchMetricAverages result
+func (q metricQuery) key() string {
+	if q.Key != "" {
+		return q.Key
+	}
+	return q.MetricName
+}
+
+// fetchMetricAverages fetches the given metrics with as few GetMetricData calls as possible
+// and returns the mean datapoint value, or the highest for Peak queries, keyed by resource ID
+// and metric key. Metrics without datapoints are left out of the result.
+func fetchMetricAverages(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	queries []metricQuery,
+	start, end time.Time,
+) (map[string]map[string]float64, error) {
+	results := make(map[string]map[string]float64)
+
+	for offset := 0; offset < len(queries); offset += maxMetricDataQueries {
+		batch := queries[offset:min(offset+maxMetricDataQueries, len(queries))]
+
+		// Query IDs must be unique within a request and start with a lowercase letter
+		dataQueries := make([]cwTypes.MetricDataQuery, len(batch))
+		for i, q := range batch {
+			dataQueries[i] = cwTypes.MetricDataQuery{
+				Id: aws.String(fmt.Sprintf("m%d", i)),
+				MetricStat: &cwTypes.MetricStat{
+					Metric: &cwTypes.Metric{
+						Namespace:  aws.String(q.Namespace),
+						MetricName: aws.String(q.MetricName),
+						Dimensions: q.Dimensions,
+					},
+					Period: aws.Int32(q.Period),
+					Stat:   aws.String(q.Stat),
+				},
+			}
+		}
+
+		// Accumulate values across pages before averaging
+		sums := make([]float64, len(batch))
+		peaks := make([]float64, len(batch))
+		counts := make([]int, len(batch))
+
+		var nextToken *string
+		for {
+			resp, err := cwClient.GetMetricData(ctx, &cloudwatch.GetMetricDataInput{
+				MetricDataQueries: dataQueries,
+				StartTime:         aws.Time(start),
+				EndTime:           aws.Time(end),
+				NextToken:         nextToken,
+			})
+			if err != nil {
+				return results, fmt.Errorf("failed to get metric data: %w", err)
+			}
+
+			for _, r := range resp.MetricDataResults {
+				var index int
+				if _, err := fmt.Sscanf(aws.ToString(r.Id), "m%d", &index); err != nil || index >= len(batch) {
+					continue
+				}
+				for _, v := range r.Values {
+					sums[index] += v
+					peaks[index] = max(peaks[index], v)
+				}
+				counts[index] += len(r.Values)
+			}
+
+			if resp.NextToken == nil {
+				break
+			}
+			nextToken = resp.NextToken
+		}
+
+		for i, q := range batch {
+			if counts[i] == 0 {
+				continue
+			}
+			if results[q.ResourceID] == nil {
+				results[q.ResourceID] = make(map[string]float64)
+			}
+			if q.Peak {
+				results[q.ResourceID][q.key()] = peaks[i]
+			} else {
+				results[q.ResourceID][q.key()] = sums[i] / float64(counts[i])
+			}
+		}
+	}
+
+	return results, nil
+}
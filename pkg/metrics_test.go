@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFetchMetricAveragesBatchesQueries(t *testing.T) {
+	tests := []struct {
+		queries int
+		batches []int
+	}{
+		{queries: 0, batches: nil},
+		{queries: 1, batches: []int{1}},
+		{queries: 500, batches: []int{500}},
+		{queries: 501, batches: []int{500, 1}},
+		{queries: 1200, batches: []int{500, 500, 200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.queries), func(t *testing.T) {
+			cw := &fakeCloudWatch{values: func(namespace, metricName, stat string, dims map[string]string) []float64 {
+				return []float64{1}
+			}}
+			queries := make([]metricQuery, tt.queries)
+			for i := range queries {
+				queries[i] = metricQuery{ResourceID: fmt.Sprintf("r-%d", i), Namespace: "AWS/EC2", MetricName: "CPUUtilization", Stat: "Average", Period: 3600}
+			}
+
+			metrics, err := fetchMetricAverages(context.Background(), cw, queries, time.Now().Add(-time.Hour), time.Now())
+			if err != nil {
+				t.Fatalf("fetchMetricAverages() error = %v", err)
+			}
+			if fmt.Sprint(cw.batches) != fmt.Sprint(tt.batches) {
+				t.Errorf("GetMetricData batches = %v, want %v", cw.batches, tt.batches)
+			}
+			if len(metrics) != tt.queries {
+				t.Errorf("got metrics for %d resources, want %d", len(metrics), tt.queries)
+			}
+		})
+	}
+}
+
+func TestFetchMetricAveragesFollowsPages(t *testing.T) {
+	cw := &fakeCloudWatch{
+		pageSize: 1,
+		values: func(namespace, metricName, stat string, dims map[string]string) []float64 {
+			return []float64{10, 30, 20}
+		},
+	}
+	queries := []metricQuery{
+		{ResourceID: "i-1", Namespace: "AWS/EC2", MetricName: "CPUUtilization", Stat: "Average", Period: 3600},
+		{ResourceID: "i-1", Namespace: "AWS/EC2", MetricName: "CPUUtilization", Stat: "Average", Period: 86400, Key: "CPUDailyPeak", Peak: true},
+	}
+
+	metrics, err := fetchMetricAverages(context.Background(), cw, queries, time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("fetchMetricAverages() error = %v", err)
+	}
+	if got := cw.metricCalls(); got != 2 {
+		t.Errorf("GetMetricData calls = %d, want 2 pages", got)
+	}
+	if got := metrics["i-1"]["CPUUtilization"]; got != 20 {
+		t.Errorf("CPUUtilization = %v, want the mean 20", got)
+	}
+	if got := metrics["i-1"]["CPUDailyPeak"]; got != 30 {
+		t.Errorf("CPUDailyPeak = %v, want the peak 30", got)
+	}
+}
+
+func TestFetchMetricAveragesSkipsMetricsWithoutData(t *testing.T) {
+	cw := &fakeCloudWatch{values: func(namespace, metricName, stat string, dims map[string]string) []float64 {
+		if namespace == "CWAgent" {
+			return nil
+		}
+		return []float64{5}
+	}}
+	queries := []metricQuery{
+		{ResourceID: "i-1", Namespace: "AWS/EC2", MetricName: "CPUUtilization", Stat: "Average", Period: 3600},
+		{ResourceID: "i-1", Namespace: "CWAgent", MetricName: "mem_used_percent", Stat: "Average", Period: 3600},
+	}
+
+	metrics, err := fetchMetricAverages(context.Background(), cw, queries, time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("fetchMetricAverages() error = %v", err)
+	}
+	if _, ok := metrics["i-1"]["mem_used_percent"]; ok {
+		t.Errorf("mem_used_percent without datapoints should be left out, got %v", metrics["i-1"])
+	}
+}
+
+// Before batching, every instance cost one GetMetricStatistics call per metric
+func TestListInstancesBatchesMetricCalls(t *testing.T) {
+	tests := []struct {
+		instances int
+		calls     int
+	}{
+		{instances: 1, calls: 1},
+		{instances: 100, calls: 1},   // 500 queries
+		{instances: 120, calls: 2},   // 600 queries
+		{instances: 1000, calls: 10}, // 5000 queries
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.instances), func(t *testing.T) {
+			ec2Client := &fakeEC2{region: "eu-west-1", reservations: testInstances(tt.instances, "m5.large")}
+			cw := &fakeCloudWatch{values: func(namespace, metricName, stat string, dims map[string]string) []float64 {
+				return []float64{50}
+			}}
+
+			instances, err := ListInstances(context.Background(), ec2Client, cw, 7, IdleThresholds{})
+			if err != nil {
+				t.Fatalf("ListInstances() error = %v", err)
+			}
+			if len(instances) != tt.instances {
+				t.Fatalf("got %d instances, want %d", len(instances), tt.instances)
+			}
+			if got := cw.metricCalls(); got != tt.calls {
+				t.Errorf("GetMetricData calls = %d, want %d for %d metric queries", got, tt.calls, tt.instances*5)
+			}
+			if cw.statsCalls != 0 {
+				t.Errorf("GetMetricStatistics calls = %d, want none", cw.statsCalls)
+			}
+		})
+	}
+}
+
+func TestListRDSInstancesBatchesMetricCalls(t *testing.T) {
+	tests := []struct {
+		instances int
+		calls     int
+	}{
+		{instances: 1, calls: 1},
+		{instances: 100, calls: 1}, // 500 queries
+		{instances: 150, calls: 2}, // 750 queries
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.instances), func(t *testing.T) {
+			rdsClient := &fakeRDS{region: "eu-west-1", instances: testDBInstances(tt.instances, "db.m5.large"), pageSize: 100}
+			cw := &fakeCloudWatch{values: func(namespace, metricName, stat string, dims map[string]string) []float64 {
+				return []float64{50}
+			}}
+
+			instances, err := ListRDSInstances(context.Background(), rdsClient, cw, 0, 7)
+			if err != nil {
+				t.Fatalf("ListRDSInstances() error = %v", err)
+			}
+			if len(instances) != tt.instances {
+				t.Fatalf("got %d instances, want %d", len(instances), tt.instances)
+			}
+			if got := cw.metricCalls(); got != tt.calls {
+				t.Errorf("GetMetricData calls = %d, want %d for %d metric queries", got, tt.calls, tt.instances*5)
+			}
+		})
+	}
+}
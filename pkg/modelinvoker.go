@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// InvokeResult is the completion of a Bedrock generation call with its token counts
+type InvokeResult struct {
+	Text         string
+	ModelID      string
+	InputTokens  int
+	OutputTokens int
+	// Estimated is set when the response did not count the tokens, so they were estimated
+	// from the length of the prompt and completion
+	Estimated bool
+	// Structured is the decoded analysis when it was generated in AnalysisFormatJSON
+	Structured *StructuredAnalysis
+	// Quality is the completeness of the analysis, set by generateAnalysis
+	Quality *AnalysisQuality
+}
+
+// Usage returns the tokens of the call and their cost
+func (r InvokeResult) Usage() AnalysisUsage {
+	return AnalysisUsage{
+		PromptTokens:     r.InputTokens,
+		CompletionTokens: r.OutputTokens,
+		AnalysisCostUSD:  BedrockCostUSD(r.ModelID, r.InputTokens, r.OutputTokens),
+	}
+}
+
+// addTokens adds the token counts of another call
+func (r *InvokeResult) addTokens(other InvokeResult) {
+	r.InputTokens += other.InputTokens
+	r.OutputTokens += other.OutputTokens
+	r.Estimated = r.Estimated || other.Estimated
+}
+
+// ModelInvoker sends a prompt to a Bedrock generation model and returns its completion
+type ModelInvoker interface {
+	Invoke(ctx context.Context, modelID, prompt string) (InvokeResult, error)
+}
+
+// ErrConverseUnsupported is returned by ConverseInvoker for models the Converse API does
+// not serve, which LegacyInvoker can still call
+var ErrConverseUnsupported = errors.New("model does not support the Converse API")
+
+// Generation settings shared by both invokers. Inference profiles get the longer limit the
+// analysis templates need; the shorter one is kept for models called by ID.
+const (
+	profileMaxTokens = 800
+	modelMaxTokens   = 300
+)
+
+// maxTokensFor returns the completion limit for a model ID or inference profile ARN
+func maxTokensFor(modelID string) int {
+	if strings.Contains(modelID, "inference-profile") {
+		return profileMaxTokens
+	}
+	return modelMaxTokens
+}
+
+// ConverseInvoker calls models through the Converse API, whose request and response are the
+// same for every model family
+type ConverseInvoker struct {
+	Client BedrockInvoker
+}
+
+// Invoke sends the prompt as a single user message
+func (c ConverseInvoker) Invoke(ctx context.Context, modelID, prompt string) (InvokeResult, error) {
+	Debugf("Invoking model ID: %s through Converse with a %d byte prompt", modelID, len(prompt))
+	DebugPayload("Prompt", prompt)
+
+	resp, err := c.Client.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId: aws.String(modelID),
+		Messages: []types.Message{{
+			Role:    types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: prompt}},
+		}},
+		InferenceConfig: &types.InferenceConfiguration{
+			MaxTokens:   aws.Int32(int32(maxTokensFor(modelID))),
+			Temperature: aws.Float32(0),
+		},
+	})
+	var validationErr *types.ValidationException
+	if errors.As(err, &validationErr) && strings.Contains(validationErr.ErrorMessage(), "doesn't support the model") {
+		return InvokeResult{}, fmt.Errorf("%w: %s", ErrConverseUnsupported, modelID)
+	}
+	if err != nil {
+		return InvokeResult{}, fmt.Errorf("generation invoke error for %s: %w", modelID, err)
+	}
+
+	result := InvokeResult{ModelID: modelID}
+	if message, ok := resp.Output.(*types.ConverseOutputMemberMessage); ok {
+		var sb strings.Builder
+		for _, block := range message.Value.Content {
+			if text, ok := block.(*types.ContentBlockMemberText); ok {
+				sb.WriteString(text.Value)
+			}
+		}
+		result.Text = sb.String()
+	}
+	if resp.Usage != nil {
+		result.InputTokens = int(aws.ToInt32(resp.Usage.InputTokens))
+		result.OutputTokens = int(aws.ToInt32(resp.Usage.OutputTokens))
+	} else {
+		result.InputTokens, result.OutputTokens = estimateTokens(prompt), estimateTokens(result.Text)
+		result.Estimated = true
+	}
+	return result, nil
+}
+
+// LegacyInvoker calls models through InvokeModel with their native request schema: the
+// Anthropic messages schema for Claude and the Titan Text schema for everything else. It is
+// only used for models the Converse API does not serve.
+type LegacyInvoker struct {
+	Client BedrockInvoker
+}
+
+// Invoke sends the prompt in the model family's schema. The result carries the token counts
+// the response reports, or estimates of them when it reports none.
+func (l LegacyInvoker) Invoke(ctx context.Context, modelID, prompt string) (InvokeResult, error) {
+	var payload interface{}
+	if strings.Contains(modelID, "anthropic") || strings.Contains(modelID, "claude") {
+		payload = map[string]interface{}{
+			"anthropic_version": "bedrock-2023-05-31",
+			"max_tokens":        maxTokensFor(modelID),
+			"temperature":       0.0,
+			"messages": []map[string]interface{}{
+				{
+					"role": "user",
+					"content": []map[string]string{
+						{"type": "text", "text": prompt},
+					},
+				},
+			},
+		}
+	} else {
+		payload = map[string]interface{}{
+			"inputText": prompt,
+			"textGenerationConfig": map[string]interface{}{
+				"maxTokenCount": maxTokensFor(modelID),
+				"temperature":   0.0,
+				"topP":          1.0,
+			},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return InvokeResult{}, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	// Log what we're about to send
+	Debugf("Invoking model ID: %s with payload length: %d bytes", modelID, len(body))
+	DebugPayload("Payload", string(body))
+
+	resp, err := l.Client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return InvokeResult{}, fmt.Errorf("generation invoke error for %s: %w", modelID, err)
+	}
+	Debugf("Received response with length: %d bytes", len(resp.Body))
+
+	result := InvokeResult{Text: extractTextFromResponse(resp.Body), ModelID: modelID}
+	if input, output, ok := extractUsageFromResponse(resp.Body); ok {
+		result.InputTokens, result.OutputTokens = input, output
+	} else {
+		result.InputTokens, result.OutputTokens = estimateTokens(prompt), estimateTokens(result.Text)
+		result.Estimated = true
+	}
+	return result, nil
+}
+
+// extractTextFromResponse returns the completion in an InvokeModel response: the text
+// blocks of a Claude message or the first Titan result. Anything else is returned raw.
+func extractTextFromResponse(responseData []byte) string {
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Results []struct {
+			OutputText string `json:"outputText"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(responseData, &resp); err == nil {
+		var sb strings.Builder
+		for _, content := range resp.Content {
+			if content.Type == "text" {
+				sb.WriteString(content.Text)
+			}
+		}
+		if sb.Len() > 0 {
+			return sb.String()
+		}
+		if len(resp.Results) > 0 && resp.Results[0].OutputText != "" {
+			return resp.Results[0].OutputText
+		}
+	}
+
+	// Return the raw response as a last resort
+	return string(responseData)
+}
+
+// legacyModels records the models Converse turned down, so later calls go straight to
+// LegacyInvoker
+var legacyModels sync.Map
+
+// InvokeBedrockModel sends a prompt to a Bedrock generation model through the Converse API,
+// falling back to InvokeModel for models Converse does not support
+func InvokeBedrockModel(ctx context.Context, client BedrockInvoker, modelID string, prompt string) (InvokeResult, error) {
+	var result InvokeResult
+	var err error
+	if _, legacy := legacyModels.Load(modelID); !legacy {
+		result, err = ConverseInvoker{Client: client}.Invoke(ctx, modelID, prompt)
+		if !errors.Is(err, ErrConverseUnsupported) {
+			logInvokeResult(result, err)
+			return result, err
+		}
+		Infof("%s does not support the Converse API, using InvokeModel", modelID)
+		legacyModels.Store(modelID, true)
+	}
+	result, err = LegacyInvoker{Client: client}.Invoke(ctx, modelID, prompt)
+	logInvokeResult(result, err)
+	return result, err
+}
+
+// logInvokeResult logs the token usage of a successful generation call
+func logInvokeResult(result InvokeResult, err error) {
+	if err == nil {
+		Debugf("Generation used %d input and %d output tokens (estimated: %t)", result.InputTokens, result.OutputTokens, result.Estimated)
+	}
+}
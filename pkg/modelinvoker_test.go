@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brTypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestInvokeBedrockModel(t *testing.T) {
+	unsupported := &brTypes.ValidationException{Message: aws.String("This action doesn't support the model that you provided.")}
+	throttled := errors.New("ThrottlingException")
+
+	tests := []struct {
+		name         string
+		modelID      string
+		converse     func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error)
+		invoke       func(modelID string, body []byte) ([]byte, error)
+		wantText     string
+		wantTokens   [2]int
+		wantEstimate bool
+		wantErr      error
+		wantInvokes  int
+	}{
+		{
+			name:    "converse",
+			modelID: "eu.anthropic.claude-3-7-sonnet-20250219-v1:0",
+			converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+				return converseText("Downsize to m5.large", 120, 30), nil
+			},
+			wantText:   "Downsize to m5.large",
+			wantTokens: [2]int{120, 30},
+		},
+		{
+			name:    "converse error is returned",
+			modelID: "eu.anthropic.claude-3-7-sonnet-20250219-v1:0",
+			converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+				return nil, throttled
+			},
+			wantErr: throttled,
+		},
+		{
+			name:    "unsupported model falls back to InvokeModel",
+			modelID: "test.legacy-claude-model",
+			converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+				return nil, unsupported
+			},
+			invoke: func(modelID string, body []byte) ([]byte, error) {
+				return []byte(`{"content":[{"type":"text","text":"Use gp3"}],"usage":{"input_tokens":50,"output_tokens":5}}`), nil
+			},
+			wantText:    "Use gp3",
+			wantTokens:  [2]int{50, 5},
+			wantInvokes: 1,
+		},
+		{
+			name:    "fallback error is returned",
+			modelID: "test.legacy-titan-model",
+			converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+				return nil, unsupported
+			},
+			invoke: func(modelID string, body []byte) ([]byte, error) {
+				return nil, throttled
+			},
+			wantErr:     throttled,
+			wantInvokes: 1,
+		},
+		{
+			name:    "missing usage is estimated",
+			modelID: "eu.anthropic.claude-3-haiku-20240307-v1:0",
+			converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+				output := converseText("Delete the snapshot", 0, 0)
+				output.Usage = nil
+				return output, nil
+			},
+			wantText:     "Delete the snapshot",
+			wantEstimate: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bedrock := &fakeBedrock{converse: tt.converse, invoke: tt.invoke}
+
+			result, err := InvokeBedrockModel(context.Background(), bedrock, tt.modelID, "Analyze i-0")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("InvokeBedrockModel() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("InvokeBedrockModel() error = %v", err)
+			}
+			if bedrock.invokeCalls != tt.wantInvokes {
+				t.Errorf("InvokeModel calls = %d, want %d", bedrock.invokeCalls, tt.wantInvokes)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if result.Text != tt.wantText || result.ModelID != tt.modelID {
+				t.Errorf("result = %q from %q, want %q from %q", result.Text, result.ModelID, tt.wantText, tt.modelID)
+			}
+			if result.Estimated != tt.wantEstimate {
+				t.Errorf("Estimated = %t, want %t", result.Estimated, tt.wantEstimate)
+			}
+			if tt.wantEstimate {
+				if result.InputTokens == 0 || result.OutputTokens == 0 {
+					t.Errorf("estimated tokens = %d/%d, want non-zero", result.InputTokens, result.OutputTokens)
+				}
+			} else if [2]int{result.InputTokens, result.OutputTokens} != tt.wantTokens {
+				t.Errorf("tokens = %d/%d, want %v", result.InputTokens, result.OutputTokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+// A model Converse turned down is called through InvokeModel from then on
+func TestInvokeBedrockModelRemembersLegacyModels(t *testing.T) {
+	modelID := "test.remembered-legacy-model"
+	t.Cleanup(func() { legacyModels.Delete(modelID) })
+	bedrock := &fakeBedrock{
+		converse: func(modelID, prompt string) (*bedrockruntime.ConverseOutput, error) {
+			return nil, &brTypes.ValidationException{Message: aws.String("This action doesn't support the model that you provided.")}
+		},
+		invoke: func(modelID string, body []byte) ([]byte, error) {
+			if !strings.Contains(string(body), "inputText") {
+				return nil, errors.New("want the Titan schema for a non-Claude model")
+			}
+			return []byte(`{"results":[{"outputText":"ok","tokenCount":2}],"inputTextTokenCount":3}`), nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := InvokeBedrockModel(context.Background(), bedrock, modelID, "Analyze"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if bedrock.converseCalls != 1 || bedrock.invokeCalls != 3 {
+		t.Errorf("Converse calls = %d, InvokeModel calls = %d; want 1 and 3", bedrock.converseCalls, bedrock.invokeCalls)
+	}
+}
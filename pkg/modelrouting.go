@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultWorkerGenModel is the generation model the worker uses when neither GEN_PROFILE_ARN
+// nor GEN_MODEL_ID is set
+const DefaultWorkerGenModel = "arn:aws:bedrock:eu-west-1:767048271788:inference-profile/eu.anthropic.claude-3-7-sonnet-20250219-v1:0"
+
+// GenerationModelFromEnv returns the inference profile or model the worker analyzes with:
+// GEN_PROFILE_ARN, else GEN_MODEL_ID, else DefaultWorkerGenModel
+func GenerationModelFromEnv() string {
+	if arn := os.Getenv("GEN_PROFILE_ARN"); arn != "" {
+		return arn
+	}
+	if model := os.Getenv("GEN_MODEL_ID"); model != "" {
+		return model
+	}
+	return DefaultWorkerGenModel
+}
+
+// ResolveModelID returns the inference profile or model that analyzes work items of
+// itemType: GEN_MODEL_<TYPE>, e.g. GEN_MODEL_EC2 or GEN_MODEL_S3, else the model from
+// GenerationModelFromEnv. The API and the worker must resolve the same models, since the
+// API fingerprints cached analyses with them.
+func ResolveModelID(itemType string) string {
+	if itemType != "" {
+		if model := os.Getenv("GEN_MODEL_" + strings.ToUpper(itemType)); model != "" {
+			return model
+		}
+	}
+	return GenerationModelFromEnv()
+}
+
+// AllowedModelOverrides returns the models an analyze request may pick with its model field,
+// from the comma-separated ALLOWED_GEN_MODELS. Without it requests cannot pick a model.
+func AllowedModelOverrides() []string {
+	var models []string
+	for _, model := range strings.Split(os.Getenv("ALLOWED_GEN_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// checkModelOverride returns why a request may not analyze with model, or "" when it may
+func checkModelOverride(model string) string {
+	allowed := AllowedModelOverrides()
+	switch {
+	case len(allowed) == 0:
+		return "this API does not allow requests to choose the model"
+	case !slices.Contains(allowed, model):
+		return fmt.Sprintf("%q is not an allowed model; allowed: %s", model, strings.Join(allowed, ", "))
+	}
+	return ""
+}
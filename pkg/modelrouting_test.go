@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// useModelEnv clears every model variable, then sets those in env
+func useModelEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{"GEN_PROFILE_ARN", "GEN_MODEL_ID", "ALLOWED_GEN_MODELS"} {
+		t.Setenv(name, "")
+	}
+	for _, resType := range ScanResourceTypes {
+		t.Setenv("GEN_MODEL_"+strings.ToUpper(resType), "")
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestResolveModelID(t *testing.T) {
+	const profile = "arn:aws:bedrock:eu-west-1:123456789012:inference-profile/eu.anthropic.claude-3-7-sonnet-20250219-v1:0"
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		itemType string
+		want     string
+	}{
+		{name: "default", itemType: "ec2", want: DefaultWorkerGenModel},
+		{name: "model ID", env: map[string]string{"GEN_MODEL_ID": "model-a"}, itemType: "ec2", want: "model-a"},
+		{name: "profile over model ID", env: map[string]string{"GEN_PROFILE_ARN": profile, "GEN_MODEL_ID": "model-a"}, itemType: "ec2", want: profile},
+		{name: "type override", env: map[string]string{"GEN_MODEL_ID": "model-a", "GEN_MODEL_S3": "model-small"}, itemType: "s3", want: "model-small"},
+		{name: "type override over profile", env: map[string]string{"GEN_PROFILE_ARN": profile, "GEN_MODEL_RDS": "model-db"}, itemType: "rds", want: "model-db"},
+		{name: "other type's override", env: map[string]string{"GEN_MODEL_ID": "model-a", "GEN_MODEL_S3": "model-small"}, itemType: "ec2", want: "model-a"},
+		{name: "lowercase type", env: map[string]string{"GEN_MODEL_ELASTICACHE": "model-cache"}, itemType: "elasticache", want: "model-cache"},
+		{name: "no type", env: map[string]string{"GEN_MODEL_ID": "model-a", "GEN_MODEL_": "model-blank"}, itemType: "", want: "model-a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useModelEnv(t, tt.env)
+			if got := ResolveModelID(tt.itemType); got != tt.want {
+				t.Errorf("ResolveModelID(%q) = %q, want %q", tt.itemType, got, tt.want)
+			}
+		})
+	}
+}
+
+// A model picked by the request wins over the configured ones, and the cache keys on it
+func TestWorkItemGenerationModel(t *testing.T) {
+	useModelEnv(t, map[string]string{"GEN_MODEL_ID": "model-a", "GEN_MODEL_EC2": "model-ec2"})
+
+	configured := WorkItem{ItemType: "ec2", Instance: Instance{InstanceID: "i-1"}}
+	picked := configured
+	picked.Model = "model-picked"
+
+	if got := configured.GenerationModel(); got != "model-ec2" {
+		t.Errorf("GenerationModel() = %q, want the type's model-ec2", got)
+	}
+	if got := picked.GenerationModel(); got != "model-picked" {
+		t.Errorf("GenerationModel() = %q, want the request's model-picked", got)
+	}
+
+	a, errA := ResourceFingerprint(configured, configured.GenerationModel())
+	b, errB := ResourceFingerprint(picked, picked.GenerationModel())
+	if errA != nil || errB != nil {
+		t.Fatalf("ResourceFingerprint() errors %v, %v", errA, errB)
+	}
+	if a == b {
+		t.Error("analyses by different models share a cache fingerprint")
+	}
+}
+
+func TestCheckModelOverride(t *testing.T) {
+	tests := []struct {
+		allowed string
+		model   string
+		want    string // substring of the problem, "" when the model is allowed
+	}{
+		{allowed: "", model: "model-a", want: "does not allow"},
+		{allowed: "model-a, model-b", model: "model-b"},
+		{allowed: "model-a,,model-b ", model: "model-a"},
+		{allowed: "model-a,model-b", model: "model-c", want: `"model-c" is not an allowed model; allowed: model-a, model-b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%s", tt.allowed, tt.model), func(t *testing.T) {
+			useModelEnv(t, map[string]string{"ALLOWED_GEN_MODELS": tt.allowed})
+			got := checkModelOverride(tt.model)
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("checkModelOverride(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fixed us-east-1 on-demand pricing used by the deterministic network analysis
+const (
+	elasticIPHourlyPrice = 0.005 // idle public IPv4 address
+	natHourlyPrice       = 0.045
+	natPerGBPrice        = 0.045 // data processed
+	natVCPUEquivalent    = 2     // managed capacity of a NAT gateway, in vCPUs, for the CO2 estimate
+)
+
+// NetworkResourceEstimate holds the deterministic monthly figures for a network finding
+type NetworkResourceEstimate struct {
+	MonthlyCost    float64
+	MonthlySavings float64
+	CO2KgMonthly   float64
+}
+
+// EstimateNetworkResource prices a network finding with fixed hourly rates. Both finding
+// types are removal candidates, so the savings are the whole monthly cost.
+func EstimateNetworkResource(resource NetworkResource) NetworkResourceEstimate {
+	var estimate NetworkResourceEstimate
+	switch resource.Type {
+	case NetworkTypeElasticIP:
+		estimate.MonthlyCost = elasticIPHourlyPrice * hoursPerMonth
+	case NetworkTypeNATGateway:
+		days := EffectivePeriodDays(resource.MetricsPeriodDays)
+		monthlyGB := (resource.BytesOutToDestination7d + resource.BytesOutToSource7d) / (1024 * 1024 * 1024) * 30 / float64(days)
+		estimate.MonthlyCost = natHourlyPrice*hoursPerMonth + natPerGBPrice*monthlyGB
+		estimate.CO2KgMonthly = natVCPUEquivalent * co2PerVCPUHour * hoursPerMonth
+	}
+	estimate.MonthlySavings = estimate.MonthlyCost
+	return estimate
+}
+
+// AnalyzeNetworkResourceWithBedrock uses Bedrock to generate optimization recommendations.
+// Callers fall back to AnalyzeNetworkResourceLocally when Bedrock is unavailable.
+func AnalyzeNetworkResourceWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	resource NetworkResource,
+	embeddings []float64,
+) (InvokeResult, error) {
+	// Create a prompt with detailed resource information
+	resourceText, err := formatNetworkResourceForPrompt(resource)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+
+	estimate := EstimateNetworkResource(resource)
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is a network resource record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+This resource was flagged because it is an Elastic IP with no association, or a NAT gateway that moved almost no traffic in the last %[2]d days.
+Its estimated monthly cost is $%.2[3]f and its CO2 footprint %.2[4]f kg CO2 per month; use these figures.
+Your analysis must include:
+1) Explain why the resource is likely unused and what to check before removing it
+2) Recommend releasing the Elastic IP or deleting the NAT gateway, or a cheaper alternative (e.g. VPC endpoints) if it is still needed
+3) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# Network Resource Analysis: [RESOURCE_ID]
+
+## Analysis
+
+[1 paragraph general description]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+`, resourceText, EffectivePeriodDays(resource.MetricsPeriodDays), estimate.MonthlyCost, estimate.CO2KgMonthly)
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeNetwork, prompt)
+}
+
+// AnalyzeNetworkResourceLocally builds the analysis from fixed pricing without calling Bedrock.
+// It follows the prompt template so the cost and CO2 figures are extracted like any other analysis.
+func AnalyzeNetworkResourceLocally(resource NetworkResource) string {
+	estimate := EstimateNetworkResource(resource)
+	days := EffectivePeriodDays(resource.MetricsPeriodDays)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Network Resource Analysis: %s\n\n## Analysis\n\n", resource.ResourceID))
+
+	switch resource.Type {
+	case NetworkTypeElasticIP:
+		sb.WriteString(fmt.Sprintf("Elastic IP %s is not associated with any instance or network interface and is billed hourly while it sits unused.\n\n", resource.PublicIP))
+		sb.WriteString("### Optimization Recommendations\n\n")
+		sb.WriteString("1. Release the address: confirm no DNS record or allow-list still refers to it, then release it.\n\n")
+	case NetworkTypeNATGateway:
+		gib := (resource.BytesOutToDestination7d + resource.BytesOutToSource7d) / (1024 * 1024 * 1024)
+		sb.WriteString(fmt.Sprintf("NAT gateway %s in %s processed %.2f GiB in the last %d days but is billed for every hour it runs.\n\n", resource.ResourceID, resource.VPCID, gib, days))
+		sb.WriteString("### Optimization Recommendations\n\n")
+		sb.WriteString("1. Delete the NAT gateway: confirm no private subnet route still needs outbound internet access, then delete it and release its Elastic IP.\n")
+		sb.WriteString("2. Use VPC endpoints: if only AWS services are reached, gateway or interface endpoints avoid the NAT charge.\n\n")
+	}
+
+	savingsPct := 0.0
+	if estimate.MonthlyCost > 0 {
+		savingsPct = 100
+	}
+	sb.WriteString("## Cost & Environmental Impact\n")
+	sb.WriteString(fmt.Sprintf("- Estimated Monthly Cost: $%.2f\n", estimate.MonthlyCost))
+	sb.WriteString("- Potential Optimized Cost: $0.00\n")
+	sb.WriteString(fmt.Sprintf("- Monthly Savings Potential: $%.2f (%.1f%%)\n", estimate.MonthlySavings, savingsPct))
+	sb.WriteString(fmt.Sprintf("- CO2 Footprint: %.2f kg CO2 per month\n", estimate.CO2KgMonthly))
+
+	return sb.String()
+}
+
+// formatNetworkResourceForPrompt converts a network resource to a human-readable format for the LLM prompt
+func formatNetworkResourceForPrompt(resource NetworkResource) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Resource Type: %s\n", resource.Type))
+	sb.WriteString(fmt.Sprintf("Resource ID: %s\n", resource.ResourceID))
+	if resource.PublicIP != "" {
+		sb.WriteString(fmt.Sprintf("Public IP: %s\n", resource.PublicIP))
+	}
+	if resource.VPCID != "" {
+		sb.WriteString(fmt.Sprintf("VPC: %s\n", resource.VPCID))
+	}
+	if resource.SubnetID != "" {
+		sb.WriteString(fmt.Sprintf("Subnet: %s\n", resource.SubnetID))
+	}
+
+	if !resource.CreateTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("Create Time: %s\n", resource.CreateTime.Format(time.RFC3339)))
+		sb.WriteString(fmt.Sprintf("Age: %.1f days\n", resource.AgeDays))
+	}
+
+	// Metrics
+	if resource.Type == NetworkTypeNATGateway {
+		days := EffectivePeriodDays(resource.MetricsPeriodDays)
+		sb.WriteString(fmt.Sprintf("Bytes Out To Destination (%d-day total): %.0f\n", days, resource.BytesOutToDestination7d))
+		sb.WriteString(fmt.Sprintf("Bytes Out To Source (%d-day total): %.0f\n", days, resource.BytesOutToSource7d))
+	}
+
+	// Tags
+	if len(resource.Tags) > 0 {
+		sb.WriteString("\nTags:\n")
+		for k, v := range resource.Tags {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", k, v))
+		}
+	}
+
+	return sb.String(), nil
+}
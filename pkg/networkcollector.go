@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Network resource types reported by the network scanner
+const (
+	NetworkTypeElasticIP  = "elastic-ip"
+	NetworkTypeNATGateway = "nat-gateway"
+)
+
+// natIdleBytesThreshold is the traffic below which a NAT gateway counts as idle over the metrics window
+const natIdleBytesThreshold = 1024 * 1024 * 1024 // 1 GiB
+
+// NetworkResource holds metadata and computed metrics for an unassociated Elastic IP or an idle NAT gateway
+// - Type: NetworkTypeElasticIP or NetworkTypeNATGateway
+// - AgeDays: days since creation; Elastic IPs carry no creation time, so it is zero for them
+// - BytesOutToDestination7d/BytesOutToSource7d: NAT gateway traffic over the metrics window
+// - MetricsPeriodDays: length of the metrics window in days
+type NetworkResource struct {
+	Type                    string            `json:"type"`
+	ResourceID              string            `json:"resourceId"`
+	Region                  string            `json:"region"`
+	AccountID               string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	State                   string            `json:"state,omitempty"`
+	PublicIP                string            `json:"publicIp,omitempty"`
+	VPCID                   string            `json:"vpcId,omitempty"`
+	SubnetID                string            `json:"subnetId,omitempty"`
+	CreateTime              time.Time         `json:"createTime"`
+	AgeDays                 float64           `json:"ageDays"`
+	Tags                    map[string]string `json:"tags"`
+	BytesOutToDestination7d float64           `json:"bytesOutToDestination7d"`
+	BytesOutToSource7d      float64           `json:"bytesOutToSource7d"`
+	MetricsPeriodDays       int               `json:"metricsPeriodDays"`
+}
+
+// ListNetworkResources finds Elastic IPs with no association and NAT gateways that moved
+// almost no traffic over the last daysBack days. Only these findings are returned.
+func ListNetworkResources(
+	ctx context.Context,
+	ec2Client EC2DescribeAPI,
+	cwClient CloudWatchMetricsAPI,
+	maxResources int,
+	daysBack int,
+) ([]NetworkResource, error) {
+	region := ec2Client.Options().Region
+	daysBack = EffectivePeriodDays(daysBack)
+
+	// Elastic IPs: any address without an association is billed for nothing
+	addresses, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []NetworkResource
+	for _, address := range addresses.Addresses {
+		if address.AssociationId != nil {
+			continue
+		}
+		results = append(results, NetworkResource{
+			Type:              NetworkTypeElasticIP,
+			ResourceID:        aws.ToString(address.AllocationId),
+			Region:            region,
+			PublicIP:          aws.ToString(address.PublicIp),
+			Tags:              parseTags(address.Tags),
+			MetricsPeriodDays: daysBack,
+		})
+	}
+	Infof("Found %d unassociated Elastic IPs of %d", len(results), len(addresses.Addresses))
+
+	// NAT gateways: list the available ones, then keep those with near-zero traffic
+	var natGateways []ec2Types.NatGateway
+	var nextToken *string
+	for {
+		resp, err := ec2Client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+			Filter: []ec2Types.Filter{{
+				Name:   aws.String("state"),
+				Values: []string{string(ec2Types.NatGatewayStateAvailable)},
+			}},
+			NextToken:  nextToken,
+			MaxResults: aws.Int32(1000),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		natGateways = append(natGateways, resp.NatGateways...)
+
+		// Check if there are more pages
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+
+	// Define time window for metrics: last daysBack days
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack)
+
+	// Process NAT gateways in parallel with a worker pool
+	resultsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
+	idleNATs := 0
+
+	for _, natGateway := range natGateways {
+		wg.Add(1)
+
+		go func(n ec2Types.NatGateway) {
+			defer wg.Done()
+
+			// Acquire semaphore
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Set a timeout for processing each NAT gateway
+			natCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			resource, ok := collectNATGatewayData(natCtx, cwClient, n, region, startTime, endTime)
+			if !ok {
+				return
+			}
+			resource.MetricsPeriodDays = daysBack
+
+			// Add to results
+			resultsMutex.Lock()
+			results = append(results, resource)
+			idleNATs++
+			resultsMutex.Unlock()
+		}(natGateway)
+	}
+
+	// Wait for all goroutines to complete
+	wg.Wait()
+	Infof("Found %d idle NAT gateways of %d", idleNATs, len(natGateways))
+
+	// Apply limit if specified
+	if maxResources > 0 && len(results) > maxResources {
+		Infof("Limiting network scan to %d resources (found %d)", maxResources, len(results))
+		results = results[:maxResources]
+	}
+
+	return results, nil
+}
+
+// collectNATGatewayData gathers the traffic of a NAT gateway and reports whether it is idle.
+// Gateways whose metrics cannot be read are not reported, since their traffic is unknown.
+func collectNATGatewayData(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	n ec2Types.NatGateway,
+	region string,
+	startTime, endTime time.Time,
+) (NetworkResource, bool) {
+	natGatewayID := aws.ToString(n.NatGatewayId)
+
+	resource := NetworkResource{
+		Type:       NetworkTypeNATGateway,
+		ResourceID: natGatewayID,
+		Region:     region,
+		State:      string(n.State),
+		VPCID:      aws.ToString(n.VpcId),
+		SubnetID:   aws.ToString(n.SubnetId),
+		Tags:       parseTags(n.Tags),
+	}
+
+	if n.CreateTime != nil {
+		resource.CreateTime = *n.CreateTime
+		resource.AgeDays = time.Since(*n.CreateTime).Hours() / 24
+	}
+	for _, address := range n.NatGatewayAddresses {
+		if address.PublicIp != nil {
+			resource.PublicIP = *address.PublicIp
+			break
+		}
+	}
+
+	bytesOut, err := getNATGatewayMetricSum(ctx, cwClient, natGatewayID, "BytesOutToDestination", startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get traffic for NAT gateway %s: %v", natGatewayID, err)
+		return resource, false
+	}
+	resource.BytesOutToDestination7d = bytesOut
+
+	bytesBack, err := getNATGatewayMetricSum(ctx, cwClient, natGatewayID, "BytesOutToSource", startTime, endTime)
+	if err != nil {
+		Warnf("Unable to get return traffic for NAT gateway %s: %v", natGatewayID, err)
+	}
+	resource.BytesOutToSource7d = bytesBack
+
+	return resource, bytesOut < natIdleBytesThreshold
+}
+
+// getNATGatewayMetricSum retrieves the total of a CloudWatch metric for a NAT gateway
+func getNATGatewayMetricSum(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	natGatewayID, metricName string,
+	startTime, endTime time.Time,
+) (float64, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/NATGateway"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.Dimension{{
+			Name:  aws.String("NatGatewayId"),
+			Value: aws.String(natGatewayID),
+		}},
+		StartTime:  &startTime,
+		EndTime:    &endTime,
+		Period:     aws.Int32(86400), // 1 day granularity
+		Statistics: []types.Statistic{types.StatisticSum},
+	}
+
+	resp, err := cwClient.GetMetricStatistics(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sum up all datapoints
+	var total float64
+	for _, dp := range resp.Datapoints {
+		if dp.Sum != nil {
+			total += *dp.Sum
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,237 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// snsAPIVersion is the version of the SNS query API that SNSClient calls
+const snsAPIVersion = "2010-03-31"
+
+// snsPublishTimeout bounds one publish, so a slow SNS endpoint cannot hold up a worker
+const snsPublishTimeout = 10 * time.Second
+
+// SNSAttribute is a message attribute subscribers can filter on. DataType is "String" or
+// "String.Array", whose Value is a JSON array of strings.
+type SNSAttribute struct {
+	DataType string
+	Value    string
+}
+
+// SNSMessage is one message for an SNS topic
+type SNSMessage struct {
+	TopicARN   string
+	Subject    string
+	Message    string
+	Attributes map[string]SNSAttribute
+}
+
+// SNSPublisher publishes messages to SNS; SNSClient is the real one
+type SNSPublisher interface {
+	Publish(ctx context.Context, msg SNSMessage) error
+}
+
+// SNSClient publishes to SNS through its query API, signing requests with the credentials of
+// an AWS config
+type SNSClient struct {
+	cfg    aws.Config
+	http   *http.Client
+	signer *v4.Signer
+}
+
+// NewSNSClient creates an SNS client using the credentials of cfg
+func NewSNSClient(cfg aws.Config) *SNSClient {
+	return &SNSClient{
+		cfg:    cfg,
+		http:   &http.Client{Timeout: snsPublishTimeout},
+		signer: v4.NewSigner(),
+	}
+}
+
+// snsErrorResponse is the body of a failed SNS query API call
+type snsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Publish sends a message to its topic, in the topic's region
+func (c *SNSClient) Publish(ctx context.Context, msg SNSMessage) error {
+	region, domain := c.cfg.Region, "amazonaws.com"
+	if topic, err := arn.Parse(msg.TopicARN); err == nil {
+		region = topic.Region
+		if topic.Partition == "aws-cn" {
+			domain = "amazonaws.com.cn"
+		}
+	}
+	endpoint := fmt.Sprintf("https://sns.%s.%s/", region, domain)
+
+	form := url.Values{}
+	form.Set("Action", "Publish")
+	form.Set("Version", snsAPIVersion)
+	form.Set("TopicArn", msg.TopicARN)
+	form.Set("Message", msg.Message)
+	if msg.Subject != "" {
+		form.Set("Subject", msg.Subject)
+	}
+	names := make([]string, 0, len(msg.Attributes))
+	for name := range msg.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		prefix := fmt.Sprintf("MessageAttributes.entry.%d.", i+1)
+		form.Set(prefix+"Name", name)
+		form.Set(prefix+"Value.DataType", msg.Attributes[name].DataType)
+		form.Set(prefix+"Value.StringValue", msg.Attributes[name].Value)
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create SNS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds, err := c.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials for SNS: %w", err)
+	}
+	payloadHash := sha256.Sum256([]byte(body))
+	if err := c.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), "sns", region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign SNS request: %w", err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", msg.TopicARN, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var snsErr snsErrorResponse
+		if xml.Unmarshal(data, &snsErr) == nil && snsErr.Code != "" {
+			return fmt.Errorf("failed to publish to %s: %s: %s", msg.TopicARN, snsErr.Code, snsErr.Message)
+		}
+		return fmt.Errorf("failed to publish to %s: status %d: %s", msg.TopicARN, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// JobCompleteNotification is the message published when a job reaches a terminal state
+type JobCompleteNotification struct {
+	JobID                   string    `json:"job_id"`
+	Status                  JobStatus `json:"status"`
+	ResourceTypes           []string  `json:"resource_types"`
+	TotalItems              int       `json:"total_items"`
+	CompletedItems          int       `json:"completed_items"`
+	FailedItems             int       `json:"failed_items"`
+	SkippedItems            int       `json:"skipped_items"`
+	TotalMonthlyCost        float64   `json:"total_monthly_cost"`
+	PotentialMonthlySavings float64   `json:"potential_monthly_savings"`
+	PotentialCO2Savings     float64   `json:"potential_co2_kg_savings"`
+	CompletedAt             time.Time `json:"completed_at"`
+}
+
+// jobNotifier and jobNotifyTopic are set by SetJobNotifier; without them jobs finish silently
+var (
+	jobNotifier    SNSPublisher
+	jobNotifyTopic string
+)
+
+// SetJobNotifier publishes a JobCompleteNotification to topicARN through publisher whenever a
+// job reaches a terminal state
+func SetJobNotifier(publisher SNSPublisher, topicARN string) {
+	jobNotifier, jobNotifyTopic = publisher, topicARN
+}
+
+// PublishJobComplete publishes the summary of a finished job and its results. The message
+// carries a "status" attribute and a "resource_types" array attribute, so subscribers can
+// filter on either.
+func PublishJobComplete(ctx context.Context, publisher SNSPublisher, topicARN string, job *JobInfo, results []ReportItem) error {
+	summary := SummarizeReport(results)
+	notification := JobCompleteNotification{
+		JobID:                   job.JobID,
+		Status:                  job.Status,
+		ResourceTypes:           job.ResourceTypes,
+		TotalItems:              job.TotalItems,
+		CompletedItems:          job.CompletedItems,
+		FailedItems:             job.FailedItems,
+		SkippedItems:            job.SkippedItems,
+		TotalMonthlyCost:        summary.TotalCost,
+		PotentialMonthlySavings: summary.PotentialCostSavings,
+		PotentialCO2Savings:     summary.PotentialCO2Savings,
+		CompletedAt:             time.Now().UTC(),
+	}
+	if notification.ResourceTypes == nil {
+		notification.ResourceTypes = []string{}
+	}
+	if job.CompletedAt > 0 {
+		notification.CompletedAt = time.Unix(job.CompletedAt, 0).UTC()
+	}
+
+	message, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job notification: %w", err)
+	}
+	resourceTypes, err := json.Marshal(notification.ResourceTypes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job notification: %w", err)
+	}
+
+	return publisher.Publish(ctx, SNSMessage{
+		TopicARN: topicARN,
+		Subject:  fmt.Sprintf("GreenOps job %s %s", job.JobID, job.Status),
+		Message:  string(message),
+		Attributes: map[string]SNSAttribute{
+			"status":         {DataType: "String", Value: string(job.Status)},
+			"resource_types": {DataType: "String.Array", Value: string(resourceTypes)},
+		},
+	})
+}
+
+// notifyJobFinished publishes the completion of a job that has just reached a terminal state,
+// when a notifier is set. The job's transition is conditional, so only the caller that made
+// it gets here. A failed publish is logged rather than returned, since the job itself is done.
+func notifyJobFinished(ctx context.Context, dynamoClient DynamoJobStore, job *JobInfo) {
+	if jobNotifier == nil {
+		return
+	}
+
+	results, err := finishedJobResults(ctx, dynamoClient, job)
+	if err != nil {
+		Warnf("Sending the notification for job %s without savings: %v", job.JobID, err)
+	}
+
+	if err := PublishJobComplete(ctx, jobNotifier, jobNotifyTopic, job, results); err != nil {
+		Warnf("Failed to notify completion of job %s: %v", job.JobID, err)
+		return
+	}
+	Infof("Published completion of job %s (%s) to %s", job.JobID, job.Status, jobNotifyTopic)
+}
+
+// notifyJobFinishedByID loads a job that has just reached a terminal state and publishes its completion
+func notifyJobFinishedByID(ctx context.Context, dynamoClient DynamoJobStore, jobID string) {
+	if jobNotifier == nil {
+		return
+	}
+	job, err := GetJob(ctx, dynamoClient, jobID)
+	if err != nil {
+		Warnf("Failed to load job %s for its notification: %v", jobID, err)
+		return
+	}
+	notifyJobFinished(ctx, dynamoClient, job)
+}
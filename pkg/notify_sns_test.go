@@ -0,0 +1,216 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSNS records the messages it is asked to publish and fails them all with err, if set
+type fakeSNS struct {
+	mu       sync.Mutex
+	messages []SNSMessage
+	err      error
+}
+
+func (f *fakeSNS) Publish(ctx context.Context, msg SNSMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, msg)
+	return f.err
+}
+
+func (f *fakeSNS) published() []SNSMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SNSMessage(nil), f.messages...)
+}
+
+const testTopicARN = "arn:aws:sns:eu-west-1:123456789012:greenops-jobs"
+
+// useJobNotifier publishes job completions to a fake SNS for the duration of the test
+func useJobNotifier(t *testing.T) *fakeSNS {
+	t.Helper()
+	sns := &fakeSNS{}
+	SetJobNotifier(sns, testTopicARN)
+	t.Cleanup(func() { SetJobNotifier(nil, "") })
+	return sns
+}
+
+func TestPublishJobComplete(t *testing.T) {
+	sns := &fakeSNS{}
+	job := &JobInfo{
+		JobID:          "job-1",
+		Status:         JobStatusCompleted,
+		ResourceTypes:  []string{"ec2", "s3"},
+		TotalItems:     3,
+		CompletedItems: 2,
+		FailedItems:    1,
+		CompletedAt:    time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC).Unix(),
+	}
+	results := []ReportItem{
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-1"}, MonthlyCost: 100, OptimizedCost: 60, MonthlySavings: 40, CO2KgMonthly: 10},
+		{ResourceType: ResourceTypeS3, S3Bucket: S3Bucket{BucketName: "logs"}, MonthlyCost: 20, OptimizedCost: 15, MonthlySavings: 5, CO2KgMonthly: 1},
+	}
+
+	if err := PublishJobComplete(context.Background(), sns, testTopicARN, job, results); err != nil {
+		t.Fatalf("PublishJobComplete() error = %v", err)
+	}
+	messages := sns.published()
+	if len(messages) != 1 {
+		t.Fatalf("published %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.TopicARN != testTopicARN || msg.Subject != "GreenOps job job-1 completed" {
+		t.Errorf("topic %q, subject %q", msg.TopicARN, msg.Subject)
+	}
+	if got := msg.Attributes["status"]; got != (SNSAttribute{DataType: "String", Value: "completed"}) {
+		t.Errorf("status attribute = %+v", got)
+	}
+	if got := msg.Attributes["resource_types"]; got != (SNSAttribute{DataType: "String.Array", Value: `["ec2","s3"]`}) {
+		t.Errorf("resource_types attribute = %+v", got)
+	}
+
+	var notification JobCompleteNotification
+	if err := json.Unmarshal([]byte(msg.Message), &notification); err != nil {
+		t.Fatalf("message is not a JobCompleteNotification: %v", err)
+	}
+	summary := SummarizeReport(results)
+	if notification.JobID != "job-1" || notification.CompletedItems != 2 || notification.FailedItems != 1 || notification.TotalItems != 3 {
+		t.Errorf("notification counts = %+v", notification)
+	}
+	if notification.TotalMonthlyCost != summary.TotalCost || notification.PotentialMonthlySavings != summary.PotentialCostSavings || notification.PotentialCO2Savings != summary.PotentialCO2Savings {
+		t.Errorf("notification figures = %+v, want those of %+v", notification, summary)
+	}
+	if !notification.CompletedAt.Equal(time.Unix(job.CompletedAt, 0)) {
+		t.Errorf("completed_at = %s, want the job's", notification.CompletedAt)
+	}
+}
+
+// Subscribers filter on resource_types, so a job without any still sends an empty array
+func TestPublishJobCompleteWithoutResourceTypes(t *testing.T) {
+	sns := &fakeSNS{}
+	job := &JobInfo{JobID: "job-1", Status: JobStatusCancelled}
+
+	if err := PublishJobComplete(context.Background(), sns, testTopicARN, job, nil); err != nil {
+		t.Fatalf("PublishJobComplete() error = %v", err)
+	}
+	msg := sns.published()[0]
+	if got := msg.Attributes["resource_types"].Value; got != "[]" {
+		t.Errorf("resource_types attribute = %q, want []", got)
+	}
+
+	publishErr := errors.New("AuthorizationError")
+	sns.err = publishErr
+	if err := PublishJobComplete(context.Background(), sns, testTopicARN, job, nil); !errors.Is(err, publishErr) {
+		t.Errorf("PublishJobComplete() error = %v, want %v", err, publishErr)
+	}
+}
+
+// However many workers see the last item processed, the job's completion is published once
+func TestMaybeFinalizeJobNotifiesOnce(t *testing.T) {
+	useJobTables(t)
+	sns := useJobNotifier(t)
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 2)
+	ctx := context.Background()
+
+	if err := UpdateJobProgress(ctx, dynamo, job.JobID, 0, true, ReportItem{Analysis: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MaybeFinalizeJob(ctx, dynamo, job.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v", err)
+	}
+	if n := len(sns.published()); n != 0 {
+		t.Fatalf("published %d messages before the job finished", n)
+	}
+
+	if err := UpdateJobProgress(ctx, dynamo, job.JobID, 1, false, ReportItem{}); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := MaybeFinalizeJob(ctx, dynamo, job.JobID); err != nil {
+				t.Errorf("MaybeFinalizeJob() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	messages := sns.published()
+	if len(messages) != 1 {
+		t.Fatalf("published %d messages, want exactly 1", len(messages))
+	}
+	var notification JobCompleteNotification
+	if err := json.Unmarshal([]byte(messages[0].Message), &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Status != JobStatusCompleted || notification.CompletedItems != 1 || notification.FailedItems != 1 {
+		t.Errorf("notification = %+v, want a completed job with 1 completed and 1 failed item", notification)
+	}
+}
+
+func TestCancelJobNotifiesOnce(t *testing.T) {
+	useJobTables(t)
+	sns := useJobNotifier(t)
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 2)
+	ctx := context.Background()
+
+	if err := CancelJob(ctx, dynamo, job.JobID); err != nil {
+		t.Fatalf("CancelJob() error = %v", err)
+	}
+	if err := CancelJob(ctx, dynamo, job.JobID); !errors.Is(err, ErrJobAlreadyFinished) {
+		t.Errorf("second CancelJob() error = %v, want ErrJobAlreadyFinished", err)
+	}
+	// Items still in flight finish after the cancellation without finalizing the job again
+	for i := 0; i < 2; i++ {
+		if err := UpdateJobProgress(ctx, dynamo, job.JobID, i, true, ReportItem{Analysis: "late"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MaybeFinalizeJob(ctx, dynamo, job.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v", err)
+	}
+
+	messages := sns.published()
+	if len(messages) != 1 {
+		t.Fatalf("published %d messages, want exactly 1", len(messages))
+	}
+	if got := messages[0].Attributes["status"].Value; got != string(JobStatusCancelled) {
+		t.Errorf("status attribute = %q, want cancelled", got)
+	}
+}
+
+// A failed publish is logged; the job is finished all the same
+func TestMaybeFinalizeJobIgnoresPublishErrors(t *testing.T) {
+	useJobTables(t)
+	sns := useJobNotifier(t)
+	sns.err = errors.New("AuthorizationError")
+	dynamo := newFakeDynamo()
+	job := createTestJob(t, dynamo, 1)
+	ctx := context.Background()
+
+	if err := UpdateJobProgress(ctx, dynamo, job.JobID, 0, true, ReportItem{Analysis: "only"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MaybeFinalizeJob(ctx, dynamo, job.JobID); err != nil {
+		t.Fatalf("MaybeFinalizeJob() error = %v, want the publish error swallowed", err)
+	}
+	got, err := GetJob(ctx, dynamo, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobStatusCompleted {
+		t.Errorf("job status = %s, want completed", got.Status)
+	}
+	if n := len(sns.published()); n != 1 {
+		t.Errorf("publish attempts = %d, want 1", n)
+	}
+}
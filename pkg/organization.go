@@ -0,0 +1,321 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DefaultOrgMaxConcurrent is how many accounts an organization scan scans at once
+const DefaultOrgMaxConcurrent = 4
+
+// orgRoleSessionName names the sessions of the role assumed in each account, for CloudTrail
+const orgRoleSessionName = "greenops-scan"
+
+// accountIDPattern matches a 12-digit AWS account ID
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// IsAccountID reports whether id is a 12-digit AWS account ID
+func IsAccountID(id string) bool {
+	return accountIDPattern.MatchString(id)
+}
+
+// OrgAccount is an active member account of an AWS Organization
+type OrgAccount struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+}
+
+// OrganizationsAPI lists the accounts of the caller's organization
+type OrganizationsAPI interface {
+	ListAccounts(ctx context.Context) ([]OrgAccount, error)
+}
+
+// organizationsClient calls the Organizations ListAccounts action, the only one an
+// organization scan needs, over its JSON protocol signed with the caller's credentials
+type organizationsClient struct {
+	cfg    aws.Config
+	signer *v4.Signer
+}
+
+// Organizations is a global service whose endpoint lives in us-east-1
+const (
+	organizationsEndpoint = "https://organizations.us-east-1.amazonaws.com/"
+	organizationsRegion   = "us-east-1"
+)
+
+// NewOrganizationsClientFromConfig creates an Organizations client from an AWS config
+func NewOrganizationsClientFromConfig(cfg aws.Config) OrganizationsAPI {
+	return &organizationsClient{cfg: cfg, signer: v4.NewSigner()}
+}
+
+// organizationsError is the error body of the Organizations JSON protocol
+type organizationsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"Message"`
+}
+
+// ListAccounts returns the active accounts of the organization, sorted by ID. The caller must
+// be in the management account or a delegated administrator, with organizations:ListAccounts.
+func (c *organizationsClient) ListAccounts(ctx context.Context) ([]OrgAccount, error) {
+	var accounts []OrgAccount
+	nextToken := ""
+	for {
+		var page struct {
+			Accounts []struct {
+				OrgAccount
+				Status string `json:"Status"`
+			} `json:"Accounts"`
+			NextToken string `json:"NextToken"`
+		}
+		input := map[string]string{}
+		if nextToken != "" {
+			input["NextToken"] = nextToken
+		}
+		if err := c.call(ctx, "ListAccounts", input, &page); err != nil {
+			return nil, err
+		}
+		for _, account := range page.Accounts {
+			if account.Status == "ACTIVE" {
+				accounts = append(accounts, account.OrgAccount)
+			}
+		}
+		if page.NextToken == "" {
+			break
+		}
+		nextToken = page.NextToken
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+// call invokes an Organizations action with a JSON input and decodes its output
+func (c *organizationsClient) call(ctx context.Context, action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", action, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, organizationsEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AWSOrganizationsV20161128."+action)
+
+	if c.cfg.Credentials == nil {
+		return errors.New("no AWS credentials to call Organizations with")
+	}
+	creds, err := c.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "organizations", organizationsRegion, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign %s request: %w", action, err)
+	}
+
+	httpClient := c.cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("organizations %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", action, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr organizationsError
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Type != "" {
+			// The type may be qualified, e.g. "com.amazonaws...#AccessDeniedException"
+			code := apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:]
+			return fmt.Errorf("organizations %s failed: %s: %s", action, code, apiErr.Message)
+		}
+		return fmt.Errorf("organizations %s failed with status %d", action, resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", action, err)
+	}
+	return nil
+}
+
+// AssumeRoleConfig returns a copy of cfg whose credentials come from assuming roleName in
+// another account. The role is assumed lazily, on the first call made with the config.
+func AssumeRoleConfig(cfg aws.Config, accountID, roleName string) aws.Config {
+	roleARN := fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, strings.TrimPrefix(roleName, "/"))
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = orgRoleSessionName
+	})
+
+	accountCfg := cfg.Copy()
+	accountCfg.Credentials = aws.NewCredentialsCache(provider)
+	return accountCfg
+}
+
+// AccountScan is the outcome of scanning one account of an organization: its resources,
+// each recording AccountID, or the error that stopped the scan
+type AccountScan struct {
+	AccountID string
+	Payload   ScanPayload
+	Err       error
+}
+
+// ScanOrganization assumes roleName in each account and scans it with ScanResources, at
+// most maxConcurrent accounts at a time. Scan limits apply to each account. An account
+// whose role cannot be assumed or whose scan fails records the error without stopping
+// the others; the scans are returned in the order of accountIDs.
+func ScanOrganization(ctx context.Context, cfg aws.Config, accountIDs []string, roleName string, resourceTypes []string, opts ScanOptions, maxConcurrent int) []AccountScan {
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultOrgMaxConcurrent
+	}
+	Infof("Scanning %d accounts with role %s, %d at a time", len(accountIDs), roleName, maxConcurrent)
+
+	scans := make([]AccountScan, len(accountIDs))
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrent)
+	for i, accountID := range accountIDs {
+		scans[i].AccountID = accountID
+		wg.Add(1)
+		go func(scan *AccountScan) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			if ctx.Err() != nil {
+				scan.Err = ctx.Err()
+				return
+			}
+
+			accountCfg := AssumeRoleConfig(cfg, scan.AccountID, roleName)
+			// Assume the role up front so a missing role is one error, not one per scanner
+			if _, err := accountCfg.Credentials.Retrieve(ctx); err != nil {
+				scan.Err = fmt.Errorf("unable to assume role %s: %w", roleName, err)
+				return
+			}
+
+			results, err := ScanResources(ctx, accountCfg, resourceTypes, opts)
+			if err != nil {
+				scan.Err = err
+				return
+			}
+			scan.Payload = NewScanPayload(results)
+			scan.Payload.SetAccountID(scan.AccountID)
+			Infof("Account %s: found %d resources", scan.AccountID, scan.Payload.Count())
+		}(&scans[i])
+	}
+	wg.Wait()
+	return scans
+}
+
+// AccountRollup is the total of the resources of one account in a report
+type AccountRollup struct {
+	AccountID      string
+	Resources      int
+	MonthlyCost    float64
+	MonthlySavings float64
+	CO2KgMonthly   float64
+}
+
+// RollupByAccount totals a report per account, in account order. Resources without an
+// account are totalled under "".
+func RollupByAccount(report []ReportItem) []AccountRollup {
+	byAccount := make(map[string]*AccountRollup)
+	for _, item := range report {
+		accountID := item.AccountID()
+		rollup, ok := byAccount[accountID]
+		if !ok {
+			rollup = &AccountRollup{AccountID: accountID}
+			byAccount[accountID] = rollup
+		}
+		co2, cost, savings := extractItemMetrics(item)
+		rollup.Resources += reportItemResourceCount(item)
+		rollup.MonthlyCost += cost
+		rollup.MonthlySavings += savings
+		rollup.CO2KgMonthly += co2
+	}
+
+	rollups := make([]AccountRollup, 0, len(byAccount))
+	for _, rollup := range byAccount {
+		rollups = append(rollups, *rollup)
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].AccountID < rollups[j].AccountID })
+	return rollups
+}
+
+// reportItemResourceCount is how many resources an item stands for: every snapshot of a
+// snapshots item, one otherwise
+func reportItemResourceCount(item ReportItem) int {
+	if item.GetResourceType() == ResourceTypeSnapshots {
+		return len(item.Snapshots)
+	}
+	return 1
+}
+
+// groupReportByAccount splits a report into the items of each account, in account order,
+// keeping the order of the items within each
+func groupReportByAccount(report []ReportItem) (accountIDs []string, groups map[string][]ReportItem) {
+	groups = make(map[string][]ReportItem)
+	for _, item := range report {
+		accountID := item.AccountID()
+		if _, ok := groups[accountID]; !ok {
+			accountIDs = append(accountIDs, accountID)
+		}
+		groups[accountID] = append(groups[accountID], item)
+	}
+	sort.Strings(accountIDs)
+	return accountIDs, groups
+}
+
+// accountLabel names an account in the report, "unknown account" for resources without one
+func accountLabel(accountID string) string {
+	if accountID == "" {
+		return "unknown account"
+	}
+	return accountID
+}
+
+// printAccountRollup prints the per-account and total figures of a report that spans
+// several accounts
+func printAccountRollup(w io.Writer, rollups []AccountRollup, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sBY ACCOUNT%s\n", ColorBold, ColorReset)
+	} else {
+		fmt.Fprintf(w, "\nBY ACCOUNT\n")
+	}
+	fmt.Fprintf(w, "──────────\n")
+
+	var total AccountRollup
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tRESOURCES\tCOST ($/mo)\tSAVINGS ($/mo)\tCO2 (kg/mo)")
+	for _, rollup := range rollups {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%.2f\n", accountLabel(rollup.AccountID), rollup.Resources,
+			rollup.MonthlyCost, rollup.MonthlySavings, rollup.CO2KgMonthly)
+		total.Resources += rollup.Resources
+		total.MonthlyCost += rollup.MonthlyCost
+		total.MonthlySavings += rollup.MonthlySavings
+		total.CO2KgMonthly += rollup.CO2KgMonthly
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%.2f\t%.2f\t%.2f\n", total.Resources, total.MonthlyCost, total.MonthlySavings, total.CO2KgMonthly)
+	tw.Flush()
+}
@@ -0,0 +1,309 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ScanPayload is the set of scanned resources submitted to the analyze API.
+// Empty sections are left out of the JSON, matching what the API expects.
+// NoCache asks the API to analyze every resource again instead of reusing cached analyses,
+// TTLDays, when set, how many days the job and its results are kept, and Model, when set,
+// which of the API's allowed models analyzes every resource.
+type ScanPayload struct {
+	Instances           []Instance           `json:"instances,omitempty"`
+	S3Buckets           []S3Bucket           `json:"s3_buckets,omitempty"`
+	RDSInstances        []RDSInstance        `json:"rds_instances,omitempty"`
+	EBSVolumes          []EBSVolume          `json:"ebs_volumes,omitempty"`
+	LambdaFunctions     []LambdaFunction     `json:"lambda_functions,omitempty"`
+	LoadBalancers       []LoadBalancer       `json:"load_balancers,omitempty"`
+	NetworkResources    []NetworkResource    `json:"network_resources,omitempty"`
+	DynamoTables        []DynamoTable        `json:"dynamo_tables,omitempty"`
+	ElastiCacheClusters []ElastiCacheCluster `json:"elasticache_clusters,omitempty"`
+	Snapshots           []EBSSnapshot        `json:"snapshots,omitempty"`
+	NoCache             bool                 `json:"no_cache,omitempty"`
+	TTLDays             int                  `json:"ttl_days,omitempty"`
+	Model               string               `json:"model,omitempty"`
+	// Metadata describes the scan, for the job to hand back with its results
+	Metadata *ReportMetadata `json:"metadata,omitempty"`
+}
+
+// NewScanPayload builds the payload from the results of ScanResources
+func NewScanPayload(scanResults map[string]interface{}) ScanPayload {
+	var payload ScanPayload
+	if instances, ok := scanResults["ec2"].([]Instance); ok {
+		payload.Instances = instances
+	}
+	if buckets, ok := scanResults["s3"].([]S3Bucket); ok {
+		payload.S3Buckets = buckets
+	}
+	if rdsInstances, ok := scanResults["rds"].([]RDSInstance); ok {
+		payload.RDSInstances = rdsInstances
+	}
+	if volumes, ok := scanResults["ebs"].([]EBSVolume); ok {
+		payload.EBSVolumes = volumes
+	}
+	if functions, ok := scanResults["lambda"].([]LambdaFunction); ok {
+		payload.LambdaFunctions = functions
+	}
+	if loadBalancers, ok := scanResults["elb"].([]LoadBalancer); ok {
+		payload.LoadBalancers = loadBalancers
+	}
+	if networkResources, ok := scanResults["network"].([]NetworkResource); ok {
+		payload.NetworkResources = networkResources
+	}
+	if tables, ok := scanResults["dynamodb"].([]DynamoTable); ok {
+		payload.DynamoTables = tables
+	}
+	if clusters, ok := scanResults["elasticache"].([]ElastiCacheCluster); ok {
+		payload.ElastiCacheClusters = clusters
+	}
+	if snapshots, ok := scanResults["snapshots"].([]EBSSnapshot); ok {
+		payload.Snapshots = snapshots
+	}
+	return payload
+}
+
+// SetAccountID records the account every resource of the payload was scanned in
+func (p *ScanPayload) SetAccountID(accountID string) {
+	for i := range p.Instances {
+		p.Instances[i].AccountID = accountID
+	}
+	for i := range p.S3Buckets {
+		p.S3Buckets[i].AccountID = accountID
+	}
+	for i := range p.RDSInstances {
+		p.RDSInstances[i].AccountID = accountID
+	}
+	for i := range p.EBSVolumes {
+		p.EBSVolumes[i].AccountID = accountID
+	}
+	for i := range p.LambdaFunctions {
+		p.LambdaFunctions[i].AccountID = accountID
+	}
+	for i := range p.LoadBalancers {
+		p.LoadBalancers[i].AccountID = accountID
+	}
+	for i := range p.NetworkResources {
+		p.NetworkResources[i].AccountID = accountID
+	}
+	for i := range p.DynamoTables {
+		p.DynamoTables[i].AccountID = accountID
+	}
+	for i := range p.ElastiCacheClusters {
+		p.ElastiCacheClusters[i].AccountID = accountID
+	}
+	for i := range p.Snapshots {
+		p.Snapshots[i].AccountID = accountID
+	}
+}
+
+// Merge appends the resources of other to the payload, keeping the payload's own settings
+func (p *ScanPayload) Merge(other ScanPayload) {
+	p.Instances = append(p.Instances, other.Instances...)
+	p.S3Buckets = append(p.S3Buckets, other.S3Buckets...)
+	p.RDSInstances = append(p.RDSInstances, other.RDSInstances...)
+	p.EBSVolumes = append(p.EBSVolumes, other.EBSVolumes...)
+	p.LambdaFunctions = append(p.LambdaFunctions, other.LambdaFunctions...)
+	p.LoadBalancers = append(p.LoadBalancers, other.LoadBalancers...)
+	p.NetworkResources = append(p.NetworkResources, other.NetworkResources...)
+	p.DynamoTables = append(p.DynamoTables, other.DynamoTables...)
+	p.ElastiCacheClusters = append(p.ElastiCacheClusters, other.ElastiCacheClusters...)
+	p.Snapshots = append(p.Snapshots, other.Snapshots...)
+}
+
+// SplitByAccount splits the payload into one payload per account its resources were scanned
+// in, keyed by AccountID, each with the payload's own settings. Resources without an account
+// share the "" payload.
+func (p ScanPayload) SplitByAccount() map[string]ScanPayload {
+	split := make(map[string]ScanPayload)
+	payloadOf := func(accountID string) *ScanPayload {
+		account, ok := split[accountID]
+		if !ok {
+			account = ScanPayload{NoCache: p.NoCache, TTLDays: p.TTLDays, Model: p.Model, Metadata: p.Metadata}
+		}
+		return &account
+	}
+	for _, instance := range p.Instances {
+		account := payloadOf(instance.AccountID)
+		account.Instances = append(account.Instances, instance)
+		split[instance.AccountID] = *account
+	}
+	for _, bucket := range p.S3Buckets {
+		account := payloadOf(bucket.AccountID)
+		account.S3Buckets = append(account.S3Buckets, bucket)
+		split[bucket.AccountID] = *account
+	}
+	for _, instance := range p.RDSInstances {
+		account := payloadOf(instance.AccountID)
+		account.RDSInstances = append(account.RDSInstances, instance)
+		split[instance.AccountID] = *account
+	}
+	for _, volume := range p.EBSVolumes {
+		account := payloadOf(volume.AccountID)
+		account.EBSVolumes = append(account.EBSVolumes, volume)
+		split[volume.AccountID] = *account
+	}
+	for _, function := range p.LambdaFunctions {
+		account := payloadOf(function.AccountID)
+		account.LambdaFunctions = append(account.LambdaFunctions, function)
+		split[function.AccountID] = *account
+	}
+	for _, loadBalancer := range p.LoadBalancers {
+		account := payloadOf(loadBalancer.AccountID)
+		account.LoadBalancers = append(account.LoadBalancers, loadBalancer)
+		split[loadBalancer.AccountID] = *account
+	}
+	for _, resource := range p.NetworkResources {
+		account := payloadOf(resource.AccountID)
+		account.NetworkResources = append(account.NetworkResources, resource)
+		split[resource.AccountID] = *account
+	}
+	for _, table := range p.DynamoTables {
+		account := payloadOf(table.AccountID)
+		account.DynamoTables = append(account.DynamoTables, table)
+		split[table.AccountID] = *account
+	}
+	for _, cluster := range p.ElastiCacheClusters {
+		account := payloadOf(cluster.AccountID)
+		account.ElastiCacheClusters = append(account.ElastiCacheClusters, cluster)
+		split[cluster.AccountID] = *account
+	}
+	for _, snapshot := range p.Snapshots {
+		account := payloadOf(snapshot.AccountID)
+		account.Snapshots = append(account.Snapshots, snapshot)
+		split[snapshot.AccountID] = *account
+	}
+	return split
+}
+
+// Count returns the total number of resources in the payload
+func (p ScanPayload) Count() int {
+	return len(p.Instances) + len(p.S3Buckets) + len(p.RDSInstances) + len(p.EBSVolumes) + len(p.LambdaFunctions) + len(p.LoadBalancers) + len(p.NetworkResources) + len(p.DynamoTables) + len(p.ElastiCacheClusters) + len(p.Snapshots)
+}
+
+// WorkItemCount returns the number of work items the payload produces. Snapshots are
+// analyzed together, so they add one item however many there are.
+func (p ScanPayload) WorkItemCount() int {
+	count := p.Count() - len(p.Snapshots)
+	if len(p.Snapshots) > 0 {
+		count++
+	}
+	return count
+}
+
+// WorkItems returns one work item per resource, indexed in payload order, plus a single
+// item for all snapshots, each expiring with its job at expiresAt and analyzed by the
+// payload's model, if it names one
+func (p ScanPayload) WorkItems(jobID string, expiresAt int64) []WorkItem {
+	workItems := make([]WorkItem, 0, p.WorkItemCount())
+	for _, instance := range p.Instances {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "ec2", Instance: instance})
+	}
+	for _, bucket := range p.S3Buckets {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "s3", S3Bucket: bucket})
+	}
+	for _, rdsInstance := range p.RDSInstances {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "rds", RDSInstance: rdsInstance})
+	}
+	for _, volume := range p.EBSVolumes {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "ebs", EBSVolume: volume})
+	}
+	for _, function := range p.LambdaFunctions {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "lambda", LambdaFunction: function})
+	}
+	for _, loadBalancer := range p.LoadBalancers {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "elb", LoadBalancer: loadBalancer})
+	}
+	for _, resource := range p.NetworkResources {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "network", NetworkResource: resource})
+	}
+	for _, table := range p.DynamoTables {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "dynamodb", DynamoTable: table})
+	}
+	for _, cluster := range p.ElastiCacheClusters {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "elasticache", ElastiCache: cluster})
+	}
+	if len(p.Snapshots) > 0 {
+		workItems = append(workItems, WorkItem{JobID: jobID, ItemIndex: len(workItems), ItemType: "snapshots", Snapshots: p.Snapshots})
+	}
+	for i := range workItems {
+		workItems[i].ExpiresAt = expiresAt
+		workItems[i].Model = p.Model
+	}
+	return workItems
+}
+
+// Validate checks that every resource carries the identifier the analysis relies on
+func (p ScanPayload) Validate() error {
+	if p.Count() == 0 {
+		return errors.New("payload contains no resources (expected instances, s3_buckets, rds_instances, ebs_volumes, lambda_functions, load_balancers, network_resources, dynamo_tables, elasticache_clusters or snapshots)")
+	}
+	for _, r := range p.resources() {
+		if r.id == "" {
+			return fmt.Errorf("%s[%d]: missing %s", r.collection, r.index, r.idField)
+		}
+	}
+	return nil
+}
+
+// LoadScanPayload reads and validates a payload saved with SaveScanPayload or exported by
+// another tool. Parse errors report the line, column and field that could not be read.
+func LoadScanPayload(path string) (ScanPayload, error) {
+	var payload ScanPayload
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return payload, fmt.Errorf("failed to read scan file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, fmt.Errorf("%s: %w", path, describeJSONError(data, err))
+	}
+	if err := payload.Validate(); err != nil {
+		return payload, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return payload, nil
+}
+
+// SaveScanPayload writes the payload as indented JSON so it can be replayed with LoadScanPayload
+func SaveScanPayload(path string, payload ScanPayload) error {
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write scan file: %w", err)
+	}
+	return nil
+}
+
+// describeJSONError adds the line and column, and the field where known, to a JSON decoding error
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, col := lineAndColumn(data, syntaxErr.Offset)
+		return fmt.Errorf("line %d, column %d: %v", line, col, syntaxErr)
+	case errors.As(err, &typeErr):
+		line, col := lineAndColumn(data, typeErr.Offset)
+		return fmt.Errorf("line %d, column %d: field %q: cannot use JSON %s as %s", line, col, typeErr.Field, typeErr.Value, typeErr.Type)
+	default:
+		return err
+	}
+}
+
+// lineAndColumn converts a byte offset into a 1-based line and column
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
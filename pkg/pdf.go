@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ExportReportToPDF renders the analysis report to a PDF file at the given path, with the
+// metadata of the scan under its title when there is any
+func ExportReportToPDF(report []ReportItem, meta *ReportMetadata, path string) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+	pdf.SetTitle("GreenOps Analysis Report", true)
+	pdf.SetCreator(ReportGenerator(), true)
+
+	// Core fonts only cover cp1252, so translate UTF-8 text before writing it. Arrows, as in
+	// rightsizing suggestions, are not in it and would print as dots.
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+	tr := func(text string) string {
+		return translate(strings.ReplaceAll(text, "→", "->"))
+	}
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 10, fmt.Sprintf("GreenOps Analysis Report - page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+
+	// Title
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.SetTextColor(34, 139, 34)
+	pdf.CellFormat(0, 12, "GreenOps Analysis Report", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetTextColor(80, 80, 80)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Generated: %s by %s", time.Now().Format(time.RFC1123), ReportGenerator()), "", 1, "L", false, 0, "")
+	for _, line := range meta.Lines() {
+		pdf.CellFormat(0, 6, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	// Sustainability summary
+	summary := SummarizeReport(report)
+	writePDFSectionTitle(pdf, "Sustainability Impact Summary")
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 245, 230)
+	pdf.CellFormat(60, 7, "Metric", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(60, 7, "Current (monthly)", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(60, 7, "Potential savings", "1", 1, "R", true, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(60, 7, "CO2 Emissions", "1", 0, "L", false, 0, "")
+	pdf.CellFormat(60, 7, fmt.Sprintf("%.2f kg CO2e", summary.TotalCO2), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(60, 7, fmt.Sprintf("%.2f kg CO2e", summary.PotentialCO2Savings), "1", 1, "R", false, 0, "")
+	pdf.CellFormat(60, 7, "Cost", "1", 0, "L", false, 0, "")
+	pdf.CellFormat(60, 7, fmt.Sprintf("$%.2f", summary.TotalCost), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(60, 7, fmt.Sprintf("$%.2f", summary.PotentialCostSavings), "1", 1, "R", false, 0, "")
+	pdf.Ln(3)
+
+	// Resource counts, sorted for consistent output
+	types := make([]string, 0, len(summary.ResourceCounts))
+	for t := range summary.ResourceCounts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		pdf.CellFormat(0, 6, fmt.Sprintf("%s resources analyzed: %d", strings.ToUpper(t), summary.ResourceCounts[t]), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 6, fmt.Sprintf("Total resources analyzed: %d", summary.TotalResources), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	writePDFSectionTitle(pdf, "Resource Summary")
+	writePDFResourceSummary(pdf, tr, SummarizeResources(report))
+	if migrations := GravitonMigrations(report); len(migrations) > 0 {
+		pdf.Ln(4)
+		writePDFSectionTitle(pdf, "ARM Migration Opportunities")
+		writePDFGravitonMigrations(pdf, tr, migrations)
+	}
+
+	// Per-resource details
+	for i, item := range report {
+		pdf.AddPage()
+		resourceID, region, size, utilization := describeItem(item)
+		writePDFSectionTitle(pdf, tr(fmt.Sprintf("%d. %s %s", i+1, strings.ToUpper(string(item.GetResourceType())), resourceID)))
+
+		pdf.SetFont("Helvetica", "", 10)
+		if region != "" {
+			pdf.CellFormat(0, 6, tr("Region: "+region), "", 1, "L", false, 0, "")
+		}
+		if link := itemConsoleURL(item); link != "" {
+			writePDFLink(pdf, "Open in the AWS console", link)
+		}
+		if size != "" {
+			pdf.CellFormat(0, 6, tr("Size: "+size), "", 1, "L", false, 0, "")
+		}
+		pdf.CellFormat(0, 6, tr("Utilization: "+utilization), "", 1, "L", false, 0, "")
+		pdf.Ln(2)
+
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.MultiCell(0, 4.5, tr(stripMarkdown(item.Analysis)), "", "L", false)
+	}
+
+	if err := pdf.OutputFileAndClose(path); err != nil {
+		return fmt.Errorf("failed to write PDF report: %w", err)
+	}
+
+	return nil
+}
+
+// pdfSummaryColumns are the headers and widths (mm) of the resource summary table
+var pdfSummaryColumns = []struct {
+	Header string
+	Width  float64
+	Align  string
+}{
+	{"Type", 16, "L"},
+	{"Resource", 42, "L"},
+	{"Key metric", 44, "L"},
+	{"CO2 (kg/mo)", 20, "R"},
+	{"Cost ($/mo)", 20, "R"},
+	{"Savings ($/mo)", 22, "R"},
+	{"Severity", 16, "C"},
+}
+
+// pdfSeverityFill is the background color of each severity badge
+var pdfSeverityFill = map[string][3]int{
+	SeverityHigh:   {248, 215, 218},
+	SeverityMedium: {255, 243, 205},
+	SeverityLow:    {230, 245, 230},
+	SeverityOK:     {230, 245, 230},
+}
+
+// writePDFResourceSummary writes the resource summary rows as a bordered table
+func writePDFResourceSummary(pdf *gofpdf.Fpdf, tr func(string) string, rows []ResourceSummaryRow) {
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(230, 245, 230)
+	for _, col := range pdfSummaryColumns {
+		pdf.CellFormat(col.Width, 6, col.Header, "1", 0, col.Align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 8)
+	for _, row := range rows {
+		cells := []string{
+			string(row.ResourceType),
+			truncateText(row.ResourceID, 28),
+			truncateText(row.KeyMetric, 30),
+			fmt.Sprintf("%.2f", row.CO2KgMonthly),
+			fmt.Sprintf("%.2f", row.MonthlyCost),
+			fmt.Sprintf("%.2f (%.0f%%)", row.MonthlySavings, row.SavingsPct),
+		}
+		for i, cell := range cells {
+			col := pdfSummaryColumns[i]
+			pdf.CellFormat(col.Width, 6, tr(cell), "1", 0, col.Align, false, 0, "")
+		}
+
+		fill := pdfSeverityFill[row.Severity]
+		pdf.SetFillColor(fill[0], fill[1], fill[2])
+		last := pdfSummaryColumns[len(pdfSummaryColumns)-1]
+		pdf.CellFormat(last.Width, 6, row.Severity, "1", 1, last.Align, true, 0, "")
+	}
+}
+
+// pdfGravitonColumns are the headers and widths (mm) of the ARM migration table
+var pdfGravitonColumns = []struct {
+	Header string
+	Width  float64
+	Align  string
+}{
+	{"Type", 16, "L"},
+	{"Resource", 46, "L"},
+	{"Current", 28, "L"},
+	{"Graviton", 28, "L"},
+	{"Savings ($/mo)", 32, "R"},
+	{"CO2 saved (kg/mo)", 30, "R"},
+}
+
+// writePDFGravitonMigrations writes the ARM migration candidates as a bordered table with a total row
+func writePDFGravitonMigrations(pdf *gofpdf.Fpdf, tr func(string) string, migrations []GravitonMigration) {
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(230, 245, 230)
+	for _, col := range pdfGravitonColumns {
+		pdf.CellFormat(col.Width, 6, col.Header, "1", 0, col.Align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 8)
+	for _, migration := range migrations {
+		cells := []string{
+			string(migration.ResourceType),
+			truncateText(migration.ResourceID, 30),
+			migration.CurrentType,
+			migration.GravitonType,
+			formatGravitonSavings(migration),
+			fmt.Sprintf("%.2f", migration.CO2KgSaved),
+		}
+		for i, cell := range cells {
+			col := pdfGravitonColumns[i]
+			pdf.CellFormat(col.Width, 6, tr(cell), "1", 0, col.Align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	cost, co2 := totalGravitonSavings(migrations)
+	pdf.SetFont("Helvetica", "B", 8)
+	labelWidth := 0.0
+	for _, col := range pdfGravitonColumns[:4] {
+		labelWidth += col.Width
+	}
+	pdf.CellFormat(labelWidth, 6, fmt.Sprintf("Total (%d resources)", len(migrations)), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(pdfGravitonColumns[4].Width, 6, fmt.Sprintf("%.2f", cost), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(pdfGravitonColumns[5].Width, 6, fmt.Sprintf("%.2f", co2), "1", 1, "R", false, 0, "")
+}
+
+// writePDFLink writes a line of blue text that opens link when clicked
+func writePDFLink(pdf *gofpdf.Fpdf, text, link string) {
+	pdf.SetTextColor(0, 0, 238)
+	x, y := pdf.GetX(), pdf.GetY()
+	pdf.CellFormat(0, 6, text, "", 1, "L", false, 0, "")
+	pdf.LinkString(x, y, pdf.GetStringWidth(text), 6, link)
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// writePDFSectionTitle writes a bold, green section heading
+func writePDFSectionTitle(pdf *gofpdf.Fpdf, title string) {
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.SetTextColor(34, 139, 34)
+	pdf.CellFormat(0, 9, title, "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// stripMarkdown removes the markdown syntax that would otherwise be printed literally
+func stripMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimLeft(line, "#")
+		line = strings.ReplaceAll(line, "**", "")
+		line = strings.ReplaceAll(line, "`", "")
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:generate go run ../cmd/pricegen -o pricing_data.json
+
+// pricingData is the bundled on-demand price table; `go generate ./pkg` refreshes it from the AWS Pricing API
+//
+//go:embed pricing_data.json
+var pricingData []byte
+
+// Sources for ReportItem.CostSource
+const (
+	CostSourcePricingTable = "pricing_table" // computed from published list prices
+	CostSourcePricingAPI   = "pricing_api"   // computed from prices fetched from the AWS Pricing API
+	CostSourceModel        = "model"         // estimated by the model because no list price was found
+)
+
+// PriceTable holds on-demand list prices in USD, keyed by region
+// - EC2: instance type to hourly price (Linux, shared tenancy)
+// - RDS: engine ("mysql" or "postgres") to instance class to hourly price for a single-AZ deployment
+// - RDSStorage: storage type to price per GB-month for a single-AZ deployment
+// - RDSReserved: engine to instance class to the hourly price of a 1-year, no-upfront, standard reservation for a single-AZ deployment
+// - S3: storage class to price per GB-month for the first storage tier
+// - AuroraServerless: price per ACU-hour of Aurora Serverless v2
+type PriceTable struct {
+	GeneratedAt      string                                   `json:"generatedAt"`
+	EC2              map[string]map[string]float64            `json:"ec2"`
+	RDS              map[string]map[string]map[string]float64 `json:"rds"`
+	RDSStorage       map[string]map[string]float64            `json:"rdsStorage"`
+	RDSReserved      map[string]map[string]map[string]float64 `json:"rdsReserved"`
+	S3               map[string]map[string]float64            `json:"s3"`
+	AuroraServerless map[string]float64                       `json:"auroraServerless"`
+}
+
+var (
+	bundledPricesOnce sync.Once
+	bundledPrices     PriceTable
+)
+
+// BundledPrices returns the price table embedded in the binary
+func BundledPrices() *PriceTable {
+	bundledPricesOnce.Do(func() {
+		if err := json.Unmarshal(pricingData, &bundledPrices); err != nil {
+			Warnf("Unable to parse bundled pricing table: %v", err)
+		}
+	})
+	return &bundledPrices
+}
+
+// LookupEC2Price returns the on-demand hourly price of an instance type in a region
+func LookupEC2Price(instanceType, region string) (float64, bool) {
+	price, ok := BundledPrices().EC2[region][instanceType]
+	return price, ok
+}
+
+// LookupRDSPrice returns the single-AZ on-demand hourly price of an RDS instance class in a region.
+// Only the MySQL, MariaDB and PostgreSQL engines are covered.
+func LookupRDSPrice(instanceClass, engine, region string) (float64, bool) {
+	price, ok := BundledPrices().RDS[region][rdsPricingEngine(engine)][instanceClass]
+	return price, ok
+}
+
+// LookupRDSStoragePrice returns the single-AZ price per GB-month of an RDS storage type in a region
+func LookupRDSStoragePrice(storageType, region string) (float64, bool) {
+	price, ok := BundledPrices().RDSStorage[region][storageType]
+	return price, ok
+}
+
+// LookupRDSReservedPrice returns the hourly price of a 1-year, no-upfront reservation of an
+// RDS instance class for a single-AZ deployment, covering the same engines as LookupRDSPrice
+func LookupRDSReservedPrice(instanceClass, engine, region string) (float64, bool) {
+	price, ok := BundledPrices().RDSReserved[region][rdsPricingEngine(engine)][instanceClass]
+	return price, ok
+}
+
+// LookupAuroraServerlessPrice returns the price per ACU-hour of Aurora Serverless v2 in a region
+func LookupAuroraServerlessPrice(region string) (float64, bool) {
+	price, ok := BundledPrices().AuroraServerless[region]
+	return price, ok
+}
+
+// LookupS3Price returns the price per GB-month of an S3 storage class in a region
+func LookupS3Price(storageClass, region string) (float64, bool) {
+	price, ok := BundledPrices().S3[region][storageClass]
+	return price, ok
+}
+
+// rdsPricingEngine maps an RDS engine name to the engine key of the price table. MariaDB is
+// priced like MySQL; other engines, including Aurora, have no key.
+func rdsPricingEngine(engine string) string {
+	switch strings.ToLower(engine) {
+	case "mysql", "mariadb":
+		return "mysql"
+	case "postgres":
+		return "postgres"
+	default:
+		return ""
+	}
+}
+
+// EstimateEC2MonthlyCost prices an instance at its on-demand rate for a full month, preferring
+// a live price recorded on the instance over the bundled table
+func EstimateEC2MonthlyCost(instance Instance) (float64, bool) {
+	hourly, ok := instance.HourlyPrice, instance.HourlyPrice > 0
+	if !ok {
+		hourly, ok = LookupEC2Price(instance.InstanceType, instance.Region)
+	}
+	if !ok {
+		return 0, false
+	}
+	return hourly * hoursPerMonth, true
+}
+
+// EstimateRDSMonthlyCost prices an RDS instance and its allocated storage for a full month,
+// preferring a live instance price recorded on it. Multi-AZ deployments run a standby and pay for both.
+func EstimateRDSMonthlyCost(instance RDSInstance) (float64, bool) {
+	hourly, ok := instance.HourlyPrice, instance.HourlyPrice > 0
+	if !ok {
+		hourly, ok = LookupRDSPrice(instance.InstanceType, instance.Engine, instance.Region)
+	}
+	if !ok {
+		return 0, false
+	}
+	storage, ok := LookupRDSStoragePrice(instance.StorageType, instance.Region)
+	if !ok {
+		return 0, false
+	}
+
+	cost := hourly*hoursPerMonth + storage*float64(instance.AllocatedStorage)
+	if instance.MultiAZ {
+		cost *= 2
+	}
+	return cost, true
+}
+
+// EstimateS3MonthlyCost prices the storage of a bucket by storage class. Request and transfer
+// charges are not included.
+func EstimateS3MonthlyCost(bucket S3Bucket) (float64, bool) {
+	classes := bucket.StorageClasses
+	if len(classes) == 0 {
+		classes = map[string]int64{"STANDARD": bucket.SizeBytes}
+	}
+
+	var cost float64
+	for class, bytes := range classes {
+		price, ok := LookupS3Price(class, bucket.Region)
+		if !ok {
+			return 0, false
+		}
+		cost += float64(bytes) / (1024 * 1024 * 1024) * price
+	}
+	return cost, true
+}
+
+// monthlyCostInstruction is the prompt step for the current cost: the list price when one is
+// known, otherwise the given instruction asking the model to estimate it
+func monthlyCostInstruction(cost float64, ok bool, estimate string) string {
+	if !ok {
+		return estimate
+	}
+	return fmt.Sprintf("Use $%.2f as the Estimated Monthly Cost; it is the on-demand list price, so do not recalculate it, and derive the optimized cost and savings from it", cost)
+}
@@ -0,0 +1,275 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	pricingTypes "github.com/aws/aws-sdk-go-v2/service/pricing/types"
+	"github.com/aws/smithy-go"
+)
+
+// Pricing modes selected with --live-pricing or the worker's PRICING_MODE variable
+const (
+	PricingModeBundled = "bundled" // bundled price table only
+	PricingModeLive    = "live"    // AWS Pricing API first, bundled table as the fallback
+)
+
+// pricingAPIRegion is the region serving the AWS Pricing API; prices for every region are available there
+const pricingAPIRegion = "us-east-1"
+
+// errLivePricingDisabled is returned once the Pricing API has failed with an access or
+// throttling error, so the rest of the scan uses the bundled table without more calls
+var errLivePricingDisabled = errors.New("live pricing disabled after an earlier failure")
+
+// priceKey identifies a cached price. Engine is only set for RDS.
+type priceKey struct {
+	Service string
+	Type    string
+	Region  string
+	Engine  string
+}
+
+// cachedPrice is a lookup result; failed lookups are cached too so they are not repeated
+type cachedPrice struct {
+	Price float64
+	Err   error
+}
+
+// PricingClient resolves on-demand prices from the AWS Pricing API. Every lookup is cached
+// in memory for the life of the client, and the first access or throttling error turns
+// off further calls.
+type PricingClient struct {
+	client   PricingAPI
+	mu       sync.Mutex
+	cache    map[priceKey]cachedPrice
+	disabled bool
+}
+
+// NewPricingClient wraps a Pricing API client
+func NewPricingClient(client PricingAPI) *PricingClient {
+	return &PricingClient{
+		client: client,
+		cache:  make(map[priceKey]cachedPrice),
+	}
+}
+
+// NewPricingClientFromConfig creates a PricingClient from an AWS config, pointing it at the
+// region that serves the Pricing API
+func NewPricingClientFromConfig(cfg aws.Config) *PricingClient {
+	cfg = cfg.Copy()
+	cfg.Region = pricingAPIRegion
+	return NewPricingClient(pricing.NewFromConfig(cfg))
+}
+
+// EC2Price returns the on-demand hourly price of a Linux instance type in a region
+func (p *PricingClient) EC2Price(ctx context.Context, instanceType, region string) (float64, error) {
+	key := priceKey{Service: "AmazonEC2", Type: instanceType, Region: region}
+	return p.cachedLookup(ctx, key, map[string]string{
+		"regionCode":      region,
+		"instanceType":    instanceType,
+		"operatingSystem": "Linux",
+		"tenancy":         "Shared",
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+	})
+}
+
+// RDSPrice returns the single-AZ on-demand hourly price of an RDS instance class in a region.
+// Only the engines of the bundled table are supported.
+func (p *PricingClient) RDSPrice(ctx context.Context, instanceClass, engine, region string) (float64, error) {
+	databaseEngine, ok := rdsDatabaseEngines[rdsPricingEngine(engine)]
+	if !ok {
+		return 0, fmt.Errorf("no pricing for RDS engine %q", engine)
+	}
+	key := priceKey{Service: "AmazonRDS", Type: instanceClass, Region: region, Engine: databaseEngine}
+	return p.cachedLookup(ctx, key, map[string]string{
+		"regionCode":       region,
+		"instanceType":     instanceClass,
+		"databaseEngine":   databaseEngine,
+		"deploymentOption": "Single-AZ",
+	})
+}
+
+// rdsDatabaseEngines maps the engine keys of the price table to the Pricing API databaseEngine values
+var rdsDatabaseEngines = map[string]string{
+	"mysql":    "MySQL",
+	"postgres": "PostgreSQL",
+}
+
+func (p *PricingClient) cachedLookup(ctx context.Context, key priceKey, filters map[string]string) (float64, error) {
+	p.mu.Lock()
+	if cached, ok := p.cache[key]; ok {
+		p.mu.Unlock()
+		return cached.Price, cached.Err
+	}
+	if p.disabled {
+		p.mu.Unlock()
+		return 0, errLivePricingDisabled
+	}
+	p.mu.Unlock()
+
+	price, err := LookupOnDemandPrice(ctx, p.client, key.Service, filters)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var apiErr smithy.APIError
+	if err != nil && errors.As(err, &apiErr) {
+		// Access denied or throttling will not improve during this scan
+		Warnf("AWS Pricing API unavailable, using the bundled price table: %v", err)
+		p.disabled = true
+		return 0, err
+	}
+	p.cache[key] = cachedPrice{Price: price, Err: err}
+	return price, err
+}
+
+// firstProduct returns the price list document of the first product of a service matching
+// the attribute filters
+func firstProduct(ctx context.Context, client PricingAPI, serviceCode string, filters map[string]string) (string, error) {
+	input := &pricing.GetProductsInput{
+		ServiceCode: aws.String(serviceCode),
+		MaxResults:  aws.Int32(1),
+	}
+	for field, value := range filters {
+		input.Filters = append(input.Filters, pricingTypes.Filter{
+			Field: aws.String(field),
+			Type:  pricingTypes.FilterTypeTermMatch,
+			Value: aws.String(value),
+		})
+	}
+
+	resp, err := client.GetProducts(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.PriceList) == 0 {
+		return "", fmt.Errorf("no %s product matches %v", serviceCode, filters)
+	}
+	return resp.PriceList[0], nil
+}
+
+// LookupOnDemandPrice returns the first-tier on-demand price of the first product of a
+// service matching the attribute filters
+func LookupOnDemandPrice(ctx context.Context, client PricingAPI, serviceCode string, filters map[string]string) (float64, error) {
+	priceList, err := firstProduct(ctx, client, serviceCode, filters)
+	if err != nil {
+		return 0, err
+	}
+
+	// Each price list entry is a JSON document; on-demand prices sit under terms.OnDemand
+	var product struct {
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					BeginRange   string            `json:"beginRange"`
+					PricePerUnit map[string]string `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
+	}
+	if err := json.Unmarshal([]byte(priceList), &product); err != nil {
+		return 0, fmt.Errorf("failed to parse price list: %w", err)
+	}
+
+	for _, term := range product.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			// Tiered prices (S3 storage) list one dimension per tier; use the first
+			if dimension.BeginRange != "" && dimension.BeginRange != "0" {
+				continue
+			}
+			return strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+		}
+	}
+	return 0, fmt.Errorf("no on-demand price for %s product matching %v", serviceCode, filters)
+}
+
+// LookupReservedPrice returns the hourly price of the 1-year, no-upfront, standard
+// reservation of the first product of a service matching the attribute filters
+func LookupReservedPrice(ctx context.Context, client PricingAPI, serviceCode string, filters map[string]string) (float64, error) {
+	priceList, err := firstProduct(ctx, client, serviceCode, filters)
+	if err != nil {
+		return 0, err
+	}
+
+	// Reservations sit under terms.Reserved, one term per length, purchase option and class
+	var product struct {
+		Terms struct {
+			Reserved map[string]struct {
+				TermAttributes  map[string]string `json:"termAttributes"`
+				PriceDimensions map[string]struct {
+					Unit         string            `json:"unit"`
+					PricePerUnit map[string]string `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"Reserved"`
+		} `json:"terms"`
+	}
+	if err := json.Unmarshal([]byte(priceList), &product); err != nil {
+		return 0, fmt.Errorf("failed to parse price list: %w", err)
+	}
+
+	for _, term := range product.Terms.Reserved {
+		attributes := term.TermAttributes
+		if attributes["LeaseContractLength"] != "1yr" || attributes["PurchaseOption"] != "No Upfront" ||
+			(attributes["OfferingClass"] != "" && attributes["OfferingClass"] != "standard") {
+			continue
+		}
+		for _, dimension := range term.PriceDimensions {
+			if dimension.Unit == "Hrs" {
+				return strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("no 1-year no-upfront reservation for %s product matching %v", serviceCode, filters)
+}
+
+// ResolveInstancePrice records the live hourly price of an EC2 instance on it. On failure
+// the instance is left alone and priced from the bundled table.
+func (p *PricingClient) ResolveInstancePrice(ctx context.Context, instance *Instance) {
+	price, err := p.EC2Price(ctx, instance.InstanceType, instance.Region)
+	if err != nil || price <= 0 {
+		return
+	}
+	instance.HourlyPrice = price
+	instance.PriceSource = CostSourcePricingAPI
+}
+
+// ResolveRDSPrice records the live hourly price of an RDS instance on it. On failure the
+// instance is left alone and priced from the bundled table.
+func (p *PricingClient) ResolveRDSPrice(ctx context.Context, instance *RDSInstance) {
+	price, err := p.RDSPrice(ctx, instance.InstanceType, instance.Engine, instance.Region)
+	if err != nil || price <= 0 {
+		return
+	}
+	instance.HourlyPrice = price
+	instance.PriceSource = CostSourcePricingAPI
+}
+
+// ResolveLivePrices looks up live prices for the EC2 and RDS instances of a scan
+func (p *PricingClient) ResolveLivePrices(ctx context.Context, payload *ScanPayload) {
+	for i := range payload.Instances {
+		p.ResolveInstancePrice(ctx, &payload.Instances[i])
+	}
+	for i := range payload.RDSInstances {
+		p.ResolveRDSPrice(ctx, &payload.RDSInstances[i])
+	}
+}
+
+// ResolveWorkItemPrice looks up the live price of a work item that arrived without one
+func (p *PricingClient) ResolveWorkItemPrice(ctx context.Context, workItem *WorkItem) {
+	switch workItem.ItemType {
+	case "ec2":
+		if workItem.Instance.HourlyPrice == 0 {
+			p.ResolveInstancePrice(ctx, &workItem.Instance)
+		}
+	case "rds":
+		if workItem.RDSInstance.HourlyPrice == 0 {
+			p.ResolveRDSPrice(ctx, &workItem.RDSInstance)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package prompts holds the templates of the Bedrock analysis prompts. The templates are
+// embedded in the binary; SetDir points at a directory whose same-named files replace them,
+// so prompts can be tuned without a rebuild.
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+// Names of the analysis prompt templates
+const (
+	EC2     = "ec2.tmpl"
+	S3      = "s3.tmpl"
+	RDS     = "rds.tmpl"
+	Account = "account.tmpl" // the account-level recommendations of a whole report
+)
+
+// Data is what the analysis prompts are rendered from
+// - Resource: the resource record, or the AccountSummary of the account prompt, as JSON
+// - Metrics: the utilization metrics, one per line (EC2 only)
+// - PeriodDays: the days the metrics were averaged over
+// - CO2KgMonthly and CarbonBreakdown: the computed monthly footprint and how it was computed (EC2 only)
+// - CostInstruction: how the model should arrive at the monthly cost
+// - Rightsizing: the instance type the rightsizing engine suggests, empty when it has none (EC2 only)
+// - Graviton: the Graviton equivalent and what moving to it saves, empty when there is none (EC2 and RDS)
+// - Idle: why the collector classified the instance as idle, empty when it did not (EC2 only)
+// - Reclaimable: what the storage that can be deleted outright costs and the lifecycle rules that would remove it, empty when there is none (S3 only)
+// - StoragePlan: the storage class options and their monthly costs, one per line, empty when the bucket is not priced (S3 only)
+// - PurchaseOptions: the monthly compute cost on-demand, reserved and on Aurora Serverless v2, one per line, empty when the instance is not priced (RDS only)
+// - TopSavings: the resources with the largest savings potential, one per line (account only)
+// - FocusAreas: what the analysis should pay particular attention to, from SetFocusAreas
+type Data struct {
+	Resource        string
+	Metrics         string
+	PeriodDays      int
+	CO2KgMonthly    float64
+	CarbonBreakdown string
+	CostInstruction string
+	Rightsizing     string
+	Graviton        string
+	Idle            string
+	Reclaimable     string
+	StoragePlan     string
+	PurchaseOptions string
+	TopSavings      string
+	FocusAreas      []string
+}
+
+var funcs = template.FuncMap{"join": strings.Join}
+
+var (
+	mu         sync.RWMutex
+	templates  *template.Template
+	focusAreas []string
+)
+
+// Load parses the embedded templates, with any same-named file in dir replacing its embedded
+// template. Other files in dir are ignored. An empty dir loads the embedded templates only.
+func Load(dir string) (*template.Template, error) {
+	names, err := fs.Glob(embedded, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("prompt templates directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("prompt templates directory %s is not a directory", dir)
+		}
+	}
+
+	set := template.New("prompts").Funcs(funcs)
+	for _, path := range names {
+		name := filepath.Base(path)
+		text, err := embedded.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			override, err := os.ReadFile(filepath.Join(dir, name))
+			switch {
+			case err == nil:
+				text = override
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("failed to read prompt template %s: %w", name, err)
+			}
+		}
+		if _, err := set.New(name).Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+		}
+	}
+	return set, nil
+}
+
+// SetDir makes Render use the templates in dir over the embedded ones. Every template is
+// parsed up front, so a broken override is reported here rather than on the first analysis.
+func SetDir(dir string) error {
+	set, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	templates = set
+	return nil
+}
+
+// SetFocusAreas sets the focus areas every prompt is rendered with
+func SetFocusAreas(areas []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	focusAreas = nil
+	for _, area := range areas {
+		if area = strings.TrimSpace(area); area != "" {
+			focusAreas = append(focusAreas, area)
+		}
+	}
+}
+
+// ParseFocusAreas splits a comma-separated list of focus areas, such as PROMPT_FOCUS_AREAS
+func ParseFocusAreas(list string) []string {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// Render renders the named prompt template with data and the configured focus areas
+func Render(name string, data Data) (string, error) {
+	mu.Lock()
+	if templates == nil {
+		set, err := Load("")
+		if err != nil {
+			mu.Unlock()
+			return "", err
+		}
+		templates = set
+	}
+	set := templates
+	data.FocusAreas = focusAreas
+	mu.Unlock()
+
+	var sb strings.Builder
+	if err := set.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,212 @@
+package prompts
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, rewriting the file instead with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s; run go test -update if the change is intended\ngot:\n%s", path, got)
+	}
+}
+
+// useTemplates makes Render use the templates in dir and the given focus areas until the
+// test ends
+func useTemplates(t *testing.T, dir string, areas ...string) {
+	t.Helper()
+	if err := SetDir(dir); err != nil {
+		t.Fatalf("SetDir(%q) error = %v", dir, err)
+	}
+	SetFocusAreas(areas)
+	t.Cleanup(func() {
+		SetDir("")
+		SetFocusAreas(nil)
+	})
+}
+
+// The rendered prompts are snapshotted so template edits show up in review as a diff of
+// what the model is sent
+func TestRenderSnapshots(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     Data
+	}{
+		{
+			name:     "ec2",
+			template: EC2,
+			data: Data{
+				Resource:        `{"instance_id":"i-0abc","instance_type":"m5.xlarge","region":"eu-west-1"}`,
+				Metrics:         "- CPUUtilization: 3.10\n- mem_used_percent: 21.00\n",
+				PeriodDays:      7,
+				CO2KgMonthly:    12.345,
+				CarbonBreakdown: "4 vCPU at 0.0108 kWh per vCPU-hour, 0.2950 kg CO2 per kWh in eu-west-1",
+				CostInstruction: "Use this monthly on-demand cost: $140.16",
+				Rightsizing:     "m5.large (2 vCPU, 8 GiB)",
+				Graviton:        "m6g.xlarge, saving $28.03 per month",
+				Idle:            "average CPU 3.1% under the 5% threshold",
+			},
+		},
+		{
+			name:     "ec2-minimal",
+			template: EC2,
+			data: Data{
+				Resource:        `{"instance_id":"i-0def","instance_type":"t3.micro"}`,
+				PeriodDays:      14,
+				CostInstruction: "Estimate the monthly on-demand cost from the instance type",
+			},
+		},
+		{
+			name:     "s3",
+			template: S3,
+			data: Data{
+				Resource:        `{"bucket_name":"logs-archive","size_bytes":5497558138880,"object_count":1200000}`,
+				PeriodDays:      7,
+				CostInstruction: "Use this monthly storage cost: $126.50",
+				Reclaimable:     "$3.20 per month in incomplete multipart uploads; an AbortIncompleteMultipartUpload rule removes them",
+				StoragePlan:     "- STANDARD: $126.50\n- INTELLIGENT_TIERING: $71.20\n- GLACIER_IR: $22.00\n",
+			},
+		},
+		{
+			name:     "rds",
+			template: RDS,
+			data: Data{
+				Resource:        `{"db_instance_identifier":"orders","db_instance_class":"db.r5.large","engine":"postgres"}`,
+				PeriodDays:      7,
+				CostInstruction: "Use this monthly on-demand cost: $182.50",
+				Graviton:        "db.r6g.large, saving $18.25 per month",
+				PurchaseOptions: "- On-demand: $182.50\n- 1-year reserved, no upfront: $124.10\n- Aurora Serverless v2 at 2 ACU average: $175.20\n",
+			},
+		},
+		{
+			name:     "account",
+			template: Account,
+			data: Data{
+				Resource:   `{"total_resources":12,"monthly_cost_usd":1840.2,"monthly_co2_kg":96.4}`,
+				TopSavings: "- i-0abc (ec2): $70.08 per month\n- logs-archive (s3): $55.30 per month\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTemplates(t, "")
+			got, err := Render(tt.template, tt.data)
+			if err != nil {
+				t.Fatalf("Render(%s) error = %v", tt.template, err)
+			}
+			checkGolden(t, tt.name+".golden", []byte(got))
+		})
+	}
+}
+
+func TestRenderFocusAreas(t *testing.T) {
+	useTemplates(t, "", " carbon ", "", "idle capacity")
+
+	for _, name := range []string{EC2, S3, RDS, Account} {
+		got, err := Render(name, Data{})
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", name, err)
+		}
+		if !strings.Contains(got, "Pay particular attention to: carbon, idle capacity\n") {
+			t.Errorf("Render(%s) is missing the focus areas:\n%s", name, got)
+		}
+	}
+}
+
+func TestSetDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, S3), []byte("Bucket {{.Resource}}{{template \"focus\" .}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("{{ not a template"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	useTemplates(t, dir, "lifecycle")
+
+	got, err := Render(S3, Data{Resource: "logs"})
+	if err != nil {
+		t.Fatalf("Render(%s) error = %v", S3, err)
+	}
+	if want := "Bucket logs\nPay particular attention to: lifecycle\n"; got != want {
+		t.Errorf("Render(%s) = %q, want the override %q", S3, got, want)
+	}
+
+	// Templates without an override are still the embedded ones
+	got, err = Render(EC2, Data{})
+	if err != nil {
+		t.Fatalf("Render(%s) error = %v", EC2, err)
+	}
+	if !strings.Contains(got, "EC2 instance record") {
+		t.Errorf("Render(%s) is not the embedded template:\n%s", EC2, got)
+	}
+}
+
+func TestSetDirErrors(t *testing.T) {
+	broken := t.TempDir()
+	if err := os.WriteFile(filepath.Join(broken, RDS), []byte("{{if .Resource}}unclosed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "prompts")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr string
+	}{
+		{name: "broken override", dir: broken, wantErr: "failed to parse prompt template rds.tmpl"},
+		{name: "missing directory", dir: filepath.Join(broken, "missing"), wantErr: "prompt templates directory"},
+		{name: "not a directory", dir: file, wantErr: "is not a directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { SetDir("") })
+			err := SetDir(tt.dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("SetDir() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFocusAreas(t *testing.T) {
+	tests := []struct {
+		list string
+		want int
+	}{
+		{list: "", want: 0},
+		{list: "  ", want: 0},
+		{list: "carbon", want: 1},
+		{list: "carbon, idle capacity", want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := ParseFocusAreas(tt.list); len(got) != tt.want {
+			t.Errorf("ParseFocusAreas(%q) = %q, want %d areas", tt.list, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Report orders for RankReport, set with --sort or output.sort
+const (
+	SortBySavings = "savings" // potential monthly savings, then CO2 savings
+	SortByCO2     = "co2"     // monthly CO2 footprint, then potential savings
+	SortByCost    = "cost"    // monthly cost, then potential savings
+	SortByName    = "name"    // resource type, region and ID, the order before ranking existed
+)
+
+// RankReport returns a copy of the report ordered by sortBy, with Rank set from 1 on every
+// item that has a non-zero value to rank on. Items whose figure is zero, usually because
+// nothing could be extracted from the analysis, go last in name order. An empty sortBy
+// ranks by savings; SortByName orders by name and sets no ranks.
+func RankReport(report []ReportItem, sortBy string) []ReportItem {
+	type entry struct {
+		item               ReportItem
+		primary, secondary float64
+	}
+
+	entries := make([]entry, len(report))
+	for i, item := range report {
+		item.Rank = 0
+		entries[i].item = item
+		if sortBy != SortByName {
+			entries[i].primary, entries[i].secondary = rankValues(item, sortBy)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if (a.primary > 0) != (b.primary > 0) {
+			return a.primary > 0
+		}
+		if a.primary != b.primary {
+			return a.primary > b.primary
+		}
+		if a.secondary != b.secondary {
+			return a.secondary > b.secondary
+		}
+		return lessByName(a.item, b.item)
+	})
+
+	ranked := make([]ReportItem, len(entries))
+	rank := 0
+	for i, e := range entries {
+		if e.primary > 0 {
+			rank++
+			e.item.Rank = rank
+		}
+		ranked[i] = e.item
+	}
+	return ranked
+}
+
+// rankValues returns the primary and secondary figures an item is ranked on
+func rankValues(item ReportItem, sortBy string) (float64, float64) {
+	co2, cost, savings := extractItemMetrics(item)
+	switch sortBy {
+	case SortByCO2:
+		return co2, savings
+	case SortByCost:
+		return cost, savings
+	default:
+		return savings, estimateCO2Savings(co2, cost, savings)
+	}
+}
+
+// estimateCO2Savings scales the CO2 footprint by the share of the cost that could be saved
+func estimateCO2Savings(co2, cost, savings float64) float64 {
+	if co2 <= 0 || cost <= 0 || savings <= 0 {
+		return 0
+	}
+	return co2 * savings / cost
+}
+
+// lessByName orders items by resource type, region and resource ID
+func lessByName(a, b ReportItem) bool {
+	if a.GetResourceType() != b.GetResourceType() {
+		return a.GetResourceType() < b.GetResourceType()
+	}
+	aID, aRegion, _, _ := describeItem(a)
+	bID, bRegion, _, _ := describeItem(b)
+	if aRegion != bRegion {
+		return aRegion < bRegion
+	}
+	return aID < bID
+}
+
+// rankLabel annotates a resource heading with its rank, e.g. " - #1 optimization opportunity"
+func rankLabel(rank int, sortBy string) string {
+	if rank == 0 {
+		return ""
+	}
+	switch sortBy {
+	case SortByCO2:
+		return fmt.Sprintf(" - #%d by CO2 footprint", rank)
+	case SortByCost:
+		return fmt.Sprintf(" - #%d by monthly cost", rank)
+	default:
+		return fmt.Sprintf(" - #%d optimization opportunity", rank)
+	}
+}
@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Recommendations of PurchaseOptions
+const (
+	PurchaseOnDemand   = "on-demand"  // keep paying on-demand
+	PurchaseReserved   = "reserved"   // buy a 1-year, no-upfront reservation
+	PurchaseServerless = "serverless" // move to Aurora Serverless v2
+)
+
+// Rules of the purchase advisor. A reservation commits to a year, so it is only recommended
+// for instances that have been running long enough to look permanent. Aurora Serverless v2
+// is considered for databases whose CPU and connections averages are both low, which for an
+// always-on instance means load that comes in bursts or not at all; it is sized like the
+// rightsizing engine sizes instances, at rightsizeTargetCPU of its capacity.
+const (
+	rdsReservedMinAgeDays        = 90
+	rdsServerlessMaxCPU          = 20.0 // percent
+	rdsServerlessMaxConnections  = 20.0
+	auroraServerlessMinACUs      = 0.5
+	auroraServerlessGiBPerACU    = 2.0
+	rdsMinPurchaseOptionsSavings = 1.0 // dollars per month
+)
+
+// PurchaseOptions compares what an RDS instance's compute would cost per month on-demand,
+// under a 1-year no-upfront reservation and on Aurora Serverless v2. Storage is the same
+// under all three and left out; Multi-AZ deployments count their standby.
+// - ReservedMonthlyCost: zero when the pricing table has no reservation price for the class
+// - ServerlessACUs: the average capacity Aurora Serverless v2 would run at; zero when the usage does not suit it
+type PurchaseOptions struct {
+	OnDemandMonthlyCost   float64 `json:"on_demand_monthly_cost" dynamodbav:"on_demand_monthly_cost"`
+	ReservedMonthlyCost   float64 `json:"reserved_monthly_cost,omitempty" dynamodbav:"reserved_monthly_cost,omitempty"`
+	ServerlessACUs        float64 `json:"serverless_acus,omitempty" dynamodbav:"serverless_acus,omitempty"`
+	ServerlessMonthlyCost float64 `json:"serverless_monthly_cost,omitempty" dynamodbav:"serverless_monthly_cost,omitempty"`
+	Recommendation        string  `json:"recommendation" dynamodbav:"recommendation"`
+	MonthlySavings        float64 `json:"monthly_savings,omitempty" dynamodbav:"monthly_savings,omitempty"`
+}
+
+// String describes the recommendation in one line, e.g. "1-year no-upfront reservation, $38.50/mo saved"
+func (p PurchaseOptions) String() string {
+	switch p.Recommendation {
+	case PurchaseReserved:
+		return fmt.Sprintf("1-year no-upfront reservation, $%.2f/mo saved", p.MonthlySavings)
+	case PurchaseServerless:
+		return fmt.Sprintf("Aurora Serverless v2 at about %.1f ACUs, $%.2f/mo saved", p.ServerlessACUs, p.MonthlySavings)
+	default:
+		return "stay on-demand"
+	}
+}
+
+// rdsServerlessACUs returns the average ACUs Aurora Serverless v2 would need for an instance:
+// the capacity of its class, at 2 GiB per ACU, scaled by its CPU use to the target utilization.
+// ok is false for classes missing from the instance catalog.
+func rdsServerlessACUs(instance RDSInstance) (float64, bool) {
+	familyName, sizeName := splitInstanceType(strings.TrimPrefix(instance.InstanceType, "db."))
+	family, known := ec2Families[familyName]
+	if !known {
+		return 0, false
+	}
+	index := family.size(sizeName)
+	if index == -1 {
+		return 0, false
+	}
+	capacity := family.Sizes[index].MemoryGiB / auroraServerlessGiBPerACU
+	return max(auroraServerlessMinACUs, capacity*instance.CPUAvg7d/rightsizeTargetCPU), true
+}
+
+// AdvisePurchaseOptions compares the purchase options of an RDS instance and recommends the
+// cheapest that saves at least a dollar a month: Aurora Serverless v2 when the usage is low
+// enough to suit it, else a reservation once the instance is rdsReservedMinAgeDays old.
+// ok is false when the instance is not running or its class has no on-demand price.
+func AdvisePurchaseOptions(instance RDSInstance) (PurchaseOptions, bool) {
+	if instance.Status != "" && instance.Status != "available" {
+		return PurchaseOptions{}, false
+	}
+	hourly, ok := instance.HourlyPrice, instance.HourlyPrice > 0
+	if !ok {
+		hourly, ok = LookupRDSPrice(instance.InstanceType, instance.Engine, instance.Region)
+	}
+	if !ok {
+		return PurchaseOptions{}, false
+	}
+	deployments := 1.0
+	if instance.MultiAZ {
+		deployments = 2
+	}
+
+	options := PurchaseOptions{
+		OnDemandMonthlyCost: hourly * hoursPerMonth * deployments,
+		Recommendation:      PurchaseOnDemand,
+	}
+	if reserved, ok := LookupRDSReservedPrice(instance.InstanceType, instance.Engine, instance.Region); ok {
+		options.ReservedMonthlyCost = reserved * hoursPerMonth * deployments
+	}
+	// Zero averages are missing metrics, not an idle database
+	if instance.CPUAvg7d > 0 && instance.CPUAvg7d < rdsServerlessMaxCPU && instance.ConnectionsAvg7d < rdsServerlessMaxConnections {
+		acuPrice, priced := LookupAuroraServerlessPrice(instance.Region)
+		if acus, sized := rdsServerlessACUs(instance); priced && sized {
+			options.ServerlessACUs = acus
+			options.ServerlessMonthlyCost = acus * acuPrice * hoursPerMonth * deployments
+		}
+	}
+
+	best := options.OnDemandMonthlyCost - rdsMinPurchaseOptionsSavings
+	if options.ServerlessMonthlyCost > 0 && options.ServerlessMonthlyCost < best {
+		options.Recommendation, best = PurchaseServerless, options.ServerlessMonthlyCost
+	}
+	established := !instance.LaunchTime.IsZero() && time.Since(instance.LaunchTime) >= rdsReservedMinAgeDays*24*time.Hour
+	if options.ReservedMonthlyCost > 0 && options.ReservedMonthlyCost < best && established {
+		options.Recommendation, best = PurchaseReserved, options.ReservedMonthlyCost
+	}
+	if options.Recommendation != PurchaseOnDemand {
+		options.MonthlySavings = options.OnDemandMonthlyCost - best
+	}
+	return options, true
+}
+
+// purchaseOptionsNote gives the purchase options to the analysis prompt, one per line
+func purchaseOptionsNote(options PurchaseOptions) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "- On-demand: $%.2f per month\n", options.OnDemandMonthlyCost)
+	if options.ReservedMonthlyCost > 0 {
+		fmt.Fprintf(&sb, "- 1-year no-upfront reservation: $%.2f per month\n", options.ReservedMonthlyCost)
+	}
+	if options.ServerlessMonthlyCost > 0 {
+		fmt.Fprintf(&sb, "- Aurora Serverless v2 at about %.1f ACUs: $%.2f per month\n", options.ServerlessACUs, options.ServerlessMonthlyCost)
+	}
+	fmt.Fprintf(&sb, "- Recommendation: %s\n", options)
+	return sb.String()
+}
+
+// printPurchaseOptions prints the purchase options of an RDS instance as a small table
+func printPurchaseOptions(w io.Writer, options PurchaseOptions, labelColor, bold, reset string) {
+	fmt.Fprintf(w, "\n%sPurchase Options:%s\n", bold+labelColor, reset)
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "  OPTION\tCOMPUTE ($/mo)")
+	fmt.Fprintf(tw, "  On-demand\t%.2f\n", options.OnDemandMonthlyCost)
+	if options.ReservedMonthlyCost > 0 {
+		fmt.Fprintf(tw, "  1-year reservation\t%.2f\n", options.ReservedMonthlyCost)
+	}
+	if options.ServerlessMonthlyCost > 0 {
+		fmt.Fprintf(tw, "  Aurora Serverless v2 (%.1f ACUs)\t%.2f\n", options.ServerlessACUs, options.ServerlessMonthlyCost)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "  %sRecommended:%s %s\n", labelColor, reset, options)
+}
@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAdvisePurchaseOptions(t *testing.T) {
+	// db.m5.xlarge on PostgreSQL in us-east-1: $0.356 an hour on-demand, $0.2456 reserved, and
+	// 16 GiB of memory for 8 ACUs of Aurora Serverless v2 at $0.12 an ACU-hour
+	const (
+		onDemand = 0.356 * hoursPerMonth
+		reserved = 0.2456 * hoursPerMonth
+		acuMonth = 0.12 * hoursPerMonth
+	)
+	established := time.Now().AddDate(-1, 0, 0)
+	recent := time.Now().AddDate(0, 0, -30)
+	database := func(cpu, connections float64, launched time.Time) RDSInstance {
+		return RDSInstance{
+			InstanceID:       "orders",
+			InstanceType:     "db.m5.xlarge",
+			Engine:           "postgres",
+			Region:           "us-east-1",
+			Status:           "available",
+			LaunchTime:       launched,
+			CPUAvg7d:         cpu,
+			ConnectionsAvg7d: connections,
+		}
+	}
+	multiAZ := database(60, 100, established)
+	multiAZ.MultiAZ = true
+	livePrice := database(60, 100, recent)
+	livePrice.HourlyPrice = 0.5
+
+	tests := []struct {
+		name         string
+		instance     RDSInstance
+		wantRec      string
+		wantOnDemand float64
+		wantACUs     float64
+		wantSavings  float64
+	}{
+		{
+			name:         "steady load, established",
+			instance:     database(60, 100, established),
+			wantRec:      PurchaseReserved,
+			wantOnDemand: onDemand,
+			wantSavings:  onDemand - reserved,
+		},
+		{
+			name:         "steady load, too recent to reserve",
+			instance:     database(60, 100, recent),
+			wantRec:      PurchaseOnDemand,
+			wantOnDemand: onDemand,
+		},
+		{
+			name:         "steady load, launch time unknown",
+			instance:     database(60, 100, time.Time{}),
+			wantRec:      PurchaseOnDemand,
+			wantOnDemand: onDemand,
+		},
+		{
+			name:         "low load",
+			instance:     database(5, 2, established),
+			wantRec:      PurchaseServerless,
+			wantOnDemand: onDemand,
+			wantACUs:     0.8,
+			wantSavings:  onDemand - 0.8*acuMonth,
+		},
+		{
+			name:         "near idle runs at the minimum capacity",
+			instance:     database(1, 0, established),
+			wantRec:      PurchaseServerless,
+			wantOnDemand: onDemand,
+			wantACUs:     auroraServerlessMinACUs,
+			wantSavings:  onDemand - auroraServerlessMinACUs*acuMonth,
+		},
+		{
+			// 2.4 ACUs cost more than the reservation
+			name:         "moderate load, established",
+			instance:     database(15, 5, established),
+			wantRec:      PurchaseReserved,
+			wantOnDemand: onDemand,
+			wantACUs:     2.4,
+			wantSavings:  onDemand - reserved,
+		},
+		{
+			name:         "moderate load, too recent to reserve",
+			instance:     database(15, 5, recent),
+			wantRec:      PurchaseServerless,
+			wantOnDemand: onDemand,
+			wantACUs:     2.4,
+			wantSavings:  onDemand - 2.4*acuMonth,
+		},
+		{
+			name:         "low CPU but many connections",
+			instance:     database(5, 50, established),
+			wantRec:      PurchaseReserved,
+			wantOnDemand: onDemand,
+			wantSavings:  onDemand - reserved,
+		},
+		{
+			// A zero average is missing metrics rather than an idle database
+			name:         "no CPU metrics",
+			instance:     database(0, 0, established),
+			wantRec:      PurchaseReserved,
+			wantOnDemand: onDemand,
+			wantSavings:  onDemand - reserved,
+		},
+		{
+			name:         "multi-AZ counts the standby",
+			instance:     multiAZ,
+			wantRec:      PurchaseReserved,
+			wantOnDemand: 2 * onDemand,
+			wantSavings:  2 * (onDemand - reserved),
+		},
+		{
+			name:         "live price",
+			instance:     livePrice,
+			wantRec:      PurchaseOnDemand,
+			wantOnDemand: 0.5 * hoursPerMonth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := AdvisePurchaseOptions(tt.instance)
+			if !ok {
+				t.Fatal("AdvisePurchaseOptions() ok = false")
+			}
+			if got.Recommendation != tt.wantRec {
+				t.Errorf("Recommendation = %s, want %s", got.Recommendation, tt.wantRec)
+			}
+			if math.Abs(got.OnDemandMonthlyCost-tt.wantOnDemand) > 1e-6 {
+				t.Errorf("OnDemandMonthlyCost = %.4f, want %.4f", got.OnDemandMonthlyCost, tt.wantOnDemand)
+			}
+			if math.Abs(got.ServerlessACUs-tt.wantACUs) > 1e-9 {
+				t.Errorf("ServerlessACUs = %.2f, want %.2f", got.ServerlessACUs, tt.wantACUs)
+			}
+			if math.Abs(got.MonthlySavings-tt.wantSavings) > 1e-6 {
+				t.Errorf("MonthlySavings = %.4f, want %.4f", got.MonthlySavings, tt.wantSavings)
+			}
+		})
+	}
+}
+
+func TestAdvisePurchaseOptionsNotAdvised(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance RDSInstance
+	}{
+		{name: "stopped", instance: RDSInstance{InstanceType: "db.m5.xlarge", Engine: "postgres", Region: "us-east-1", Status: "stopped", CPUAvg7d: 50}},
+		{name: "engine not priced", instance: RDSInstance{InstanceType: "db.m5.xlarge", Engine: "oracle-ee", Region: "us-east-1", Status: "available"}},
+		{name: "region not priced", instance: RDSInstance{InstanceType: "db.m5.xlarge", Engine: "postgres", Region: "mars-1", Status: "available"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := AdvisePurchaseOptions(tt.instance); ok {
+				t.Errorf("AdvisePurchaseOptions() = %+v, want no advice", got)
+			}
+		})
+	}
+}
+
+func TestPurchaseOptionsString(t *testing.T) {
+	tests := []struct {
+		options PurchaseOptions
+		want    string
+	}{
+		{options: PurchaseOptions{Recommendation: PurchaseOnDemand}, want: "stay on-demand"},
+		{options: PurchaseOptions{Recommendation: PurchaseReserved, MonthlySavings: 79.488}, want: "1-year no-upfront reservation, $79.49/mo saved"},
+		{options: PurchaseOptions{Recommendation: PurchaseServerless, ServerlessACUs: 0.8, MonthlySavings: 187.2}, want: "Aurora Serverless v2 at about 0.8 ACUs, $187.20/mo saved"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.options.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
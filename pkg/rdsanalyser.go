@@ -3,12 +3,10 @@ package pkg
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/alexalbu001/greenops/pkg/prompts"
 )
 
 // RDSInstanceAnalysis contains the analysis results for an RDS instance
@@ -23,89 +21,59 @@ type RDSInstanceAnalysis struct {
 		SaveAmount float64 `json:"saveAmount"`
 		SavePct    float64 `json:"savePct"`
 	} `json:"costEstimate"`
+	// PurchaseOptions is what AdvisePurchaseOptions computed; nil when the instance is not
+	// running or its class is not priced
+	PurchaseOptions *PurchaseOptions `json:"purchaseOptions,omitempty"`
 }
 
 // AnalyzeRDSInstanceWithBedrock uses Bedrock to generate optimization recommendations
 func AnalyzeRDSInstanceWithBedrock(
 	ctx context.Context,
-	client *bedrockruntime.Client,
+	client BedrockInvoker,
 	modelID string,
 	instance RDSInstance,
 	embeddings []float64,
-) (string, error) {
+) (InvokeResult, error) {
 	// Create a prompt with detailed instance information
 	instanceJSON, err := formatRDSInstanceForPrompt(instance)
 	if err != nil {
-		return "", err
+		return InvokeResult{}, err
 	}
 
-	// Construct the prompt with an example to ensure consistent formatting
-	prompt := fmt.Sprintf(`Here is an RDS instance record. This is a cloud optimisation tool that's also helping with sustainability efforts:
-%s
-
-Please analyze this RDS instance for sustainability and cost optimization.
-Your analysis must include:
-1) Calculate the monthly CO2 footprint considering database instance family, size, and Multi-AZ
-2) Estimate monthly cost based on the instance type, storage, and settings
-3) Identify inefficiencies (over-provisioning, low utilization, etc.)
-4) Calculate potential savings from rightsizing or optimization
-5) Suggest specific actions for rightsizing or optimization
-6) Identify any performance or availability concerns
-7) Provide SUSTAINABILITY TIPS for this finding
-
-FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
-
-# RDS Instance Analysis: [INSTANCE_ID]
-
-## Performance Metrics
-- CPU Utilization (7-day avg): [PERCENTAGE]%
-- Database Connections (7-day avg): [NUMBER]
-- IOPS (7-day avg): [NUMBER]
-- Storage Used: [PERCENTAGE]%
-
-## Analysis
-
-[1-2 paragraphs general description]
-
-### Inefficiencies Identified
-
-1. [ISSUE 1]: [DESCRIPTION]
-2. [ISSUE 2]: [DESCRIPTION]
-3. [ISSUE 3]: [DESCRIPTION]
-
-### Optimization Recommendations
-
-1. [RECOMMENDATION 1]: [DESCRIPTION]
-2. [RECOMMENDATION 2]: [DESCRIPTION]
-3. [RECOMMENDATION 3]: [DESCRIPTION]
-
-## Cost & Environmental Impact
-- Estimated Monthly Cost: $X.XX
-- Potential Optimized Cost: $X.XX
-- Monthly Savings Potential: $X.XX (XX.X%)
-- CO2 Footprint: X.XX kg CO2 per month
-
-## Sustainability Tips
-
-1. [TIP 1]: [DESCRIPTION]
-2. [TIP 2]: [DESCRIPTION]
-3. [TIP 3]: [DESCRIPTION]
-`, instanceJSON)
+	monthlyCost, priced := EstimateRDSMonthlyCost(instance)
+	graviton := ""
+	if migration, ok := RDSGravitonMigration(instance); ok {
+		graviton = migration.String()
+	}
+	purchaseOptions := ""
+	if options, ok := AdvisePurchaseOptions(instance); ok {
+		purchaseOptions = purchaseOptionsNote(options)
+	}
 
-	// Use the general-purpose function to invoke Bedrock
-	analysis, err := InvokeBedrockModel(ctx, client, modelID, prompt)
+	// Render the prompt from its template, with an example to ensure consistent formatting
+	prompt, err := prompts.Render(prompts.RDS, prompts.Data{
+		Resource:        instanceJSON,
+		PeriodDays:      EffectivePeriodDays(instance.MetricsPeriodDays),
+		CostInstruction: monthlyCostInstruction(monthlyCost, priced, "Estimate monthly cost based on the instance type, storage, and settings") + actualCostNote(instance.ActualMonthlyCost),
+		Graviton:        graviton,
+		PurchaseOptions: purchaseOptions,
+	})
 	if err != nil {
-		return "", err
+		return InvokeResult{}, err
 	}
 
-	return analysis, nil
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeRDS, prompt)
 }
 
 // AnalyzeRDSInstance generates optimization recommendations for a single RDS instance using Bedrock
-func AnalyzeRDSInstance(ctx context.Context, instance RDSInstance, client *bedrockruntime.Client, modelID string) (RDSInstanceAnalysis, error) {
+func AnalyzeRDSInstance(ctx context.Context, instance RDSInstance, client BedrockInvoker, modelID string) (RDSInstanceAnalysis, error) {
 	analysis := RDSInstanceAnalysis{
 		Instance: instance,
 	}
+	if options, ok := AdvisePurchaseOptions(instance); ok {
+		analysis.PurchaseOptions = &options
+	}
 
 	// Get embeddings
 	embeddings, err := EmbedText(ctx, client, "amazon.titan-embed-text-v2:0", instance.InstanceID)
@@ -115,11 +83,11 @@ func AnalyzeRDSInstance(ctx context.Context, instance RDSInstance, client *bedro
 	analysis.Embedding = embeddings
 
 	// Get analysis directly from Bedrock
-	analysisText, err := AnalyzeRDSInstanceWithBedrock(ctx, client, modelID, instance, embeddings)
+	result, err := AnalyzeRDSInstanceWithBedrock(ctx, client, modelID, instance, embeddings)
 	if err != nil {
 		return analysis, err
 	}
-	analysis.Analysis = analysisText
+	analysis.Analysis = result.Text
 
 	// Extract cost and CO2 metrics from the Bedrock response
 	extractRDSMetricsFromAnalysis(&analysis)
@@ -129,46 +97,12 @@ func AnalyzeRDSInstance(ctx context.Context, instance RDSInstance, client *bedro
 
 // Helper function to extract metrics from the Bedrock analysis text
 func extractRDSMetricsFromAnalysis(analysis *RDSInstanceAnalysis) {
-	// Find the Cost & Environmental Impact section
-	text := analysis.Analysis
-	if index := strings.Index(text, "Cost & Environmental Impact"); index != -1 {
-		section := text[index:]
-
-		// Extract CO2 footprint
-		co2Match := regexp.MustCompile(`CO2 Footprint: ([\d\.]+)`).FindStringSubmatch(section)
-		if len(co2Match) > 1 {
-			if val, err := strconv.ParseFloat(co2Match[1], 64); err == nil {
-				analysis.CO2Footprint = val
-			}
-		}
-
-		// Extract current cost
-		costMatch := regexp.MustCompile(`Estimated Monthly Cost: \$([\d\.]+)`).FindStringSubmatch(section)
-		if len(costMatch) > 1 {
-			if val, err := strconv.ParseFloat(costMatch[1], 64); err == nil {
-				analysis.CostEstimate.Current = val
-			}
-		}
-
-		// Extract optimized cost
-		optCostMatch := regexp.MustCompile(`Potential Optimized Cost: \$([\d\.]+)`).FindStringSubmatch(section)
-		if len(optCostMatch) > 1 {
-			if val, err := strconv.ParseFloat(optCostMatch[1], 64); err == nil {
-				analysis.CostEstimate.Optimized = val
-			}
-		}
-
-		// Extract savings
-		savingsMatch := regexp.MustCompile(`Monthly Savings Potential: \$([\d\.]+) \(([\d\.]+)%\)`).FindStringSubmatch(section)
-		if len(savingsMatch) > 2 {
-			if val, err := strconv.ParseFloat(savingsMatch[1], 64); err == nil {
-				analysis.CostEstimate.SaveAmount = val
-			}
-			if val, err := strconv.ParseFloat(savingsMatch[2], 64); err == nil {
-				analysis.CostEstimate.SavePct = val
-			}
-		}
-	}
+	metrics := ExtractAnalysisMetrics(analysis.Analysis)
+	analysis.CO2Footprint = metrics.CO2KgMonthly
+	analysis.CostEstimate.Current = metrics.MonthlyCost
+	analysis.CostEstimate.Optimized = metrics.OptimizedCost
+	analysis.CostEstimate.SaveAmount = metrics.MonthlySavings
+	analysis.CostEstimate.SavePct = metrics.SavingsPct
 }
 
 // formatRDSInstanceForPrompt converts an RDS instance to a human-readable format for the LLM prompt
@@ -181,6 +115,7 @@ func formatRDSInstanceForPrompt(instance RDSInstance) (string, error) {
 	sb.WriteString(fmt.Sprintf("Storage Type: %s\n", instance.StorageType))
 	sb.WriteString(fmt.Sprintf("Allocated Storage: %d GB\n", instance.AllocatedStorage))
 	sb.WriteString(fmt.Sprintf("Multi-AZ: %t\n", instance.MultiAZ))
+	sb.WriteString(fmt.Sprintf("Graviton Supported: %t\n", instance.GravitonSupported))
 	sb.WriteString(fmt.Sprintf("Status: %s\n", instance.Status))
 	sb.WriteString(fmt.Sprintf("Region: %s\n", instance.Region))
 
@@ -192,11 +127,20 @@ func formatRDSInstanceForPrompt(instance RDSInstance) (string, error) {
 	}
 
 	// Metrics
-	sb.WriteString(fmt.Sprintf("CPU Utilization (7-day avg): %.1f%%\n", instance.CPUAvg7d))
-	sb.WriteString(fmt.Sprintf("Database Connections (7-day avg): %.1f\n", instance.ConnectionsAvg7d))
-	sb.WriteString(fmt.Sprintf("IOPS (7-day avg): %.1f\n", instance.IOPSAvg7d))
+	days := EffectivePeriodDays(instance.MetricsPeriodDays)
+	sb.WriteString(fmt.Sprintf("CPU Utilization (%d-day avg): %.1f%%\n", days, instance.CPUAvg7d))
+	sb.WriteString(fmt.Sprintf("Database Connections (%d-day avg): %.1f\n", days, instance.ConnectionsAvg7d))
+	sb.WriteString(fmt.Sprintf("IOPS (%d-day avg): %.1f\n", days, instance.IOPSAvg7d))
 	sb.WriteString(fmt.Sprintf("Storage Used: %.1f%%\n", instance.StorageUsed))
 
+	// Deterministic findings
+	if len(instance.Findings) > 0 {
+		sb.WriteString("\nFindings (from deterministic checks; address each one):\n")
+		for _, finding := range instance.Findings {
+			sb.WriteString(fmt.Sprintf("- %s\n", finding))
+		}
+	}
+
 	// Tags
 	if len(instance.Tags) > 0 {
 		sb.WriteString("\nTags:\n")
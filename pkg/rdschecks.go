@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// rdsEngineEOL is when RDS standard support ends for each major version of the MySQL,
+// PostgreSQL and MariaDB engines. After it, RDS upgrades the database automatically or bills
+// Extended Support on top of the instance.
+var rdsEngineEOL = map[string]map[string]string{
+	"mysql": {
+		"5.6": "2022-03-01",
+		"5.7": "2024-02-29",
+		"8.0": "2026-07-31",
+		"8.4": "2029-07-31",
+	},
+	"postgres": {
+		"9.6": "2022-04-26",
+		"10":  "2023-04-17",
+		"11":  "2024-02-29",
+		"12":  "2025-02-28",
+		"13":  "2026-02-28",
+		"14":  "2027-02-28",
+		"15":  "2028-02-29",
+		"16":  "2029-02-28",
+		"17":  "2030-02-28",
+	},
+	"mariadb": {
+		"10.3":  "2023-10-23",
+		"10.4":  "2024-06-18",
+		"10.5":  "2025-06-24",
+		"10.6":  "2026-07-06",
+		"10.11": "2028-02-16",
+		"11.4":  "2029-05-29",
+	},
+}
+
+// Thresholds of the RDS checks
+const (
+	rdsEOLWarningDays       = 180  // how long before the end of standard support a version is flagged
+	rdsOverallocatedUsedPct = 25.0 // storage used below which allocated storage counts as overallocated
+	rdsOverallocatedMinGB   = 100  // smaller allocations are not worth migrating to shrink
+)
+
+// rdsMajorVersion returns the major version of an engine version, the form rdsEngineEOL is
+// keyed by: "8.0" for MySQL 8.0.35, "14" for PostgreSQL 14.7 but "9.6" for 9.6.24
+func rdsMajorVersion(engine, version string) string {
+	parts := strings.Split(version, ".")
+	if engine == "postgres" && len(parts) > 0 && parts[0] != "9" {
+		return parts[0]
+	}
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// RDSEngineEOL returns when RDS standard support ends for an engine version. ok is false for
+// engines and versions the table does not cover, including every Aurora engine.
+func RDSEngineEOL(engine, version string) (time.Time, bool) {
+	engine = strings.ToLower(engine)
+	date, ok := rdsEngineEOL[engine][rdsMajorVersion(engine, version)]
+	if !ok {
+		return time.Time{}, false
+	}
+	eol, err := time.Parse(time.DateOnly, date)
+	return eol, err == nil
+}
+
+// rdsStorageOverallocated reports whether an RDS instance uses under rdsOverallocatedUsedPct
+// of at least rdsOverallocatedMinGB of allocated storage. A StorageUsed of 0 is a missing
+// metric rather than an empty database.
+func rdsStorageOverallocated(instance RDSInstance) bool {
+	return instance.AllocatedStorage >= rdsOverallocatedMinGB &&
+		instance.StorageUsed > 0 && instance.StorageUsed < rdsOverallocatedUsedPct
+}
+
+// RDSFindings runs the deterministic checks on an RDS instance as of now: a stopped
+// instance, an engine version past or within rdsEOLWarningDays of the end of standard
+// support, and overallocated storage
+func RDSFindings(instance RDSInstance, now time.Time) []string {
+	var findings []string
+	if strings.EqualFold(instance.Status, "stopped") {
+		findings = append(findings, fmt.Sprintf("Stopped: still billed for %d GB of storage, and RDS starts stopped instances again after 7 days", instance.AllocatedStorage))
+	}
+
+	if eol, ok := RDSEngineEOL(instance.Engine, instance.EngineVersion); ok {
+		version := rdsMajorVersion(strings.ToLower(instance.Engine), instance.EngineVersion)
+		switch days := int(eol.Sub(now).Hours() / 24); {
+		case days < 0:
+			findings = append(findings, fmt.Sprintf("End of life: standard support for %s %s ended on %s; upgrade to avoid Extended Support charges",
+				instance.Engine, version, eol.Format(time.DateOnly)))
+		case days <= rdsEOLWarningDays:
+			findings = append(findings, fmt.Sprintf("Nearing end of life: standard support for %s %s ends on %s, in %d days; plan the upgrade",
+				instance.Engine, version, eol.Format(time.DateOnly), days))
+		}
+	}
+
+	if rdsStorageOverallocated(instance) {
+		findings = append(findings, fmt.Sprintf("Overallocated storage: %.1f%% of %d GB used; allocated storage cannot shrink, so migrate to a smaller volume",
+			instance.StorageUsed, instance.AllocatedStorage))
+	}
+	return findings
+}
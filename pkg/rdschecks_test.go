@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRDSEngineEOL(t *testing.T) {
+	tests := []struct {
+		engine  string
+		version string
+		want    string // "" when the table does not cover the version
+	}{
+		{engine: "mysql", version: "8.0.35", want: "2026-07-31"},
+		{engine: "mysql", version: "5.7.44", want: "2024-02-29"},
+		{engine: "MySQL", version: "8.4.3", want: "2029-07-31"},
+		{engine: "postgres", version: "14.7", want: "2027-02-28"},
+		{engine: "postgres", version: "9.6.24", want: "2022-04-26"},
+		{engine: "postgres", version: "10.21", want: "2023-04-17"},
+		{engine: "postgres", version: "16", want: "2029-02-28"},
+		{engine: "mariadb", version: "10.11.6", want: "2028-02-16"},
+		{engine: "mariadb", version: "10.6.14", want: "2026-07-06"},
+		{engine: "mysql", version: "5.5.62"},
+		{engine: "postgres", version: "9.5.25"},
+		{engine: "mysql", version: ""},
+		{engine: "aurora-mysql", version: "8.0.mysql_aurora.3.04.0"},
+		{engine: "aurora-postgresql", version: "14.7"},
+		{engine: "oracle-ee", version: "19.0.0.0.ru-2023-04.rur-2023-04.r1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engine+"/"+tt.version, func(t *testing.T) {
+			got, ok := RDSEngineEOL(tt.engine, tt.version)
+			if ok != (tt.want != "") {
+				t.Fatalf("RDSEngineEOL() ok = %v, want %v", ok, tt.want != "")
+			}
+			if ok && got.Format(time.DateOnly) != tt.want {
+				t.Errorf("RDSEngineEOL() = %s, want %s", got.Format(time.DateOnly), tt.want)
+			}
+		})
+	}
+}
+
+// Every date of the table parses, every version is the major version it is looked up by,
+// and support ends later for later versions
+func TestRDSEngineEOLTable(t *testing.T) {
+	for engine, versions := range rdsEngineEOL {
+		var sorted []string
+		for version, date := range versions {
+			if _, err := time.Parse(time.DateOnly, date); err != nil {
+				t.Errorf("%s %s: %v", engine, version, err)
+			}
+			if major := rdsMajorVersion(engine, version+".1"); major != version {
+				t.Errorf("%s %s.1 is looked up as %s", engine, version, major)
+			}
+			sorted = append(sorted, version)
+		}
+		slices.SortFunc(sorted, compareVersions)
+		for i := 1; i < len(sorted); i++ {
+			if versions[sorted[i]] <= versions[sorted[i-1]] {
+				t.Errorf("%s %s ends on %s, before %s does", engine, sorted[i], versions[sorted[i]], sorted[i-1])
+			}
+		}
+	}
+}
+
+// compareVersions orders dotted version numbers numerically
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < min(len(as), len(bs)); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x - y
+		}
+	}
+	return len(as) - len(bs)
+}
+
+func TestRDSStorageOverallocated(t *testing.T) {
+	tests := []struct {
+		allocated int32
+		used      float64
+		want      bool
+	}{
+		{allocated: 100, used: 24.9, want: true},
+		{allocated: 1000, used: 0.5, want: true},
+		{allocated: 100, used: 25},
+		{allocated: 100, used: 60},
+		{allocated: 99, used: 10},
+		{allocated: 20, used: 1},
+		{allocated: 500, used: 0}, // missing metric
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dGB/%.1f%%", tt.allocated, tt.used), func(t *testing.T) {
+			instance := RDSInstance{AllocatedStorage: tt.allocated, StorageUsed: tt.used}
+			if got := rdsStorageOverallocated(instance); got != tt.want {
+				t.Errorf("rdsStorageOverallocated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRDSFindings(t *testing.T) {
+	// MySQL 8.0 leaves standard support on 2026-07-31
+	eol := time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return eol.AddDate(0, 0, -n) }
+	mysql := RDSInstance{Engine: "mysql", EngineVersion: "8.0.35", Status: "available", AllocatedStorage: 50, StorageUsed: 40}
+
+	stopped := mysql
+	stopped.Status = "Stopped"
+	overallocated := mysql
+	overallocated.AllocatedStorage, overallocated.StorageUsed = 400, 12.34
+	aurora := mysql
+	aurora.Engine, aurora.EngineVersion = "aurora-mysql", "5.7.mysql_aurora.2.11.2"
+
+	tests := []struct {
+		name     string
+		instance RDSInstance
+		now      time.Time
+		want     []string // prefixes of the findings
+	}{
+		{name: "nothing to flag", instance: mysql, now: days(181)},
+		{name: "warning starts", instance: mysql, now: days(rdsEOLWarningDays), want: []string{"Nearing end of life: standard support for mysql 8.0 ends on 2026-07-31, in 180 days"}},
+		{name: "warning on the last day", instance: mysql, now: days(1), want: []string{"Nearing end of life: standard support for mysql 8.0 ends on 2026-07-31, in 1 days"}},
+		{name: "past end of life", instance: mysql, now: days(-1), want: []string{"End of life: standard support for mysql 8.0 ended on 2026-07-31"}},
+		{name: "aurora is not checked", instance: aurora, now: days(-1000)},
+		{name: "stopped", instance: stopped, now: days(181), want: []string{"Stopped: still billed for 50 GB of storage"}},
+		{name: "overallocated", instance: overallocated, now: days(181), want: []string{"Overallocated storage: 12.3% of 400 GB used"}},
+		{
+			name:     "every finding",
+			instance: RDSInstance{Engine: "postgres", EngineVersion: "11.22", Status: "stopped", AllocatedStorage: 400, StorageUsed: 5},
+			now:      days(0),
+			want:     []string{"Stopped", "End of life: standard support for postgres 11 ended on 2024-02-29", "Overallocated storage"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RDSFindings(tt.instance, tt.now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("RDSFindings() = %q, want %d findings", got, len(tt.want))
+			}
+			for i, finding := range got {
+				if !strings.HasPrefix(finding, tt.want[i]) {
+					t.Errorf("finding %d = %q, want it to start with %q", i, finding, tt.want[i])
+				}
+			}
+		})
+	}
+}
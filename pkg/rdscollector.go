@@ -2,12 +2,10 @@ package pkg
 
 import (
 	"context"
-	"log"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
@@ -15,35 +13,47 @@ import (
 
 // RDSInstance holds metadata and computed metrics for an RDS instance
 type RDSInstance struct {
-	InstanceID       string            `json:"instanceId"`
-	InstanceType     string            `json:"instanceType"`
-	Engine           string            `json:"engine"`
-	EngineVersion    string            `json:"engineVersion"`
-	StorageType      string            `json:"storageType"`
-	AllocatedStorage int32             `json:"allocatedStorage"`
-	MultiAZ          bool              `json:"multiAZ"`
-	LaunchTime       time.Time         `json:"launchTime"`
-	Status           string            `json:"status"`
-	Region           string            `json:"region"`
-	Tags             map[string]string `json:"tags"`
-	CPUAvg7d         float64           `json:"cpuAvg7d"`
-	ConnectionsAvg7d float64           `json:"connectionsAvg7d"`
-	IOPSAvg7d        float64           `json:"iopsAvg7d"`
-	StorageUsed      float64           `json:"storageUsed"`
+	InstanceID        string            `json:"instanceId"`
+	InstanceType      string            `json:"instanceType"`
+	Engine            string            `json:"engine"`
+	EngineVersion     string            `json:"engineVersion"`
+	StorageType       string            `json:"storageType"`
+	AllocatedStorage  int32             `json:"allocatedStorage"`
+	MultiAZ           bool              `json:"multiAZ"`
+	LaunchTime        time.Time         `json:"launchTime"`
+	Status            string            `json:"status"`
+	Region            string            `json:"region"`
+	AccountID         string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	Tags              map[string]string `json:"tags"`
+	CPUAvg7d          float64           `json:"cpuAvg7d"`
+	ConnectionsAvg7d  float64           `json:"connectionsAvg7d"`
+	IOPSAvg7d         float64           `json:"iopsAvg7d"`
+	StorageUsed       float64           `json:"storageUsed"`
+	MetricsPeriodDays int               `json:"metricsPeriodDays"`     // CloudWatch lookback window for the Avg7d fields
+	HourlyPrice       float64           `json:"hourlyPrice,omitempty"` // single-AZ on-demand price from the AWS Pricing API, when live pricing is on
+	PriceSource       string            `json:"priceSource,omitempty"`
+	ActualMonthlyCost float64           `json:"actualMonthlyCost,omitempty"` // last 30 days of spend from Cost Explorer
+	GravitonSupported bool              `json:"gravitonSupported"`           // the engine runs on Graviton and the class is, or has, a Graviton class
+	Findings          []string          `json:"findings,omitempty"`          // what RDSFindings found at collection time
 }
 
 // ListRDSInstances retrieves all RDS instances and their key metrics
 func ListRDSInstances(
 	ctx context.Context,
-	rdsClient *rds.Client,
-	cwClient *cloudwatch.Client,
+	rdsClient RDSDescribeAPI,
+	cwClient CloudWatchMetricsAPI,
 	maxInstances int,
+	daysBack int,
 ) ([]RDSInstance, error) {
 	// Get list of RDS instances
 	var instances []rdsTypes.DBInstance
 	var nextToken *string
 
 	for {
+		// A cancelled scan makes no further AWS calls
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		input := &rds.DescribeDBInstancesInput{
 			Marker:     nextToken,
 			MaxRecords: aws.Int32(100),
@@ -65,10 +75,10 @@ func ListRDSInstances(
 
 	// Apply limit if specified
 	if maxInstances > 0 && len(instances) > maxInstances {
-		log.Printf("Limiting RDS scan to %d instances (found %d)", maxInstances, len(instances))
+		Infof("Limiting RDS scan to %d instances (found %d)", maxInstances, len(instances))
 		instances = instances[:maxInstances]
 	} else {
-		log.Printf("Processing %d RDS instances", len(instances))
+		Infof("Processing %d RDS instances", len(instances))
 	}
 
 	// Process instances in parallel with a worker pool
@@ -78,24 +88,37 @@ func ListRDSInstances(
 	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
 
 	for _, instance := range instances {
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
 
 		go func(db rdsTypes.DBInstance) {
 			defer wg.Done()
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			// Acquire semaphore, giving up once the scan is cancelled
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+			// select picks at random when both cases are ready, so check again
+			if ctx.Err() != nil {
+				return
+			}
 
 			// Set a timeout for processing each instance
 			instCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 			defer cancel()
 
 			// Collect instance data
-			rdsInstance, err := collectRDSInstanceData(instCtx, rdsClient, cwClient, db)
+			rdsInstance, err := collectRDSInstanceData(instCtx, rdsClient, cwClient, db, daysBack)
 			if err != nil {
-				log.Printf("Warning: Error collecting data for RDS instance %s: %v",
-					aws.ToString(db.DBInstanceIdentifier), err)
+				if ctx.Err() == nil {
+					Warnf("Error collecting data for RDS instance %s: %v",
+						aws.ToString(db.DBInstanceIdentifier), err)
+				}
 				return
 			}
 
@@ -108,16 +131,34 @@ func ListRDSInstances(
 
 	// Wait for all goroutines to complete
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fetch metrics for every instance in as few CloudWatch calls as possible
+	daysBack = EffectivePeriodDays(daysBack)
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -daysBack) // Last daysBack days
+	if err := applyRDSMetrics(ctx, cwClient, results, startTime, endTime); err != nil {
+		Warnf("Unable to get CloudWatch metrics for RDS instances: %v", err)
+	}
+
+	// The storage check needs the metrics, so the findings come last
+	for i := range results {
+		results[i].Findings = RDSFindings(results[i], endTime)
+	}
 
 	return results, nil
 }
 
-// collectRDSInstanceData gathers all relevant data for a single RDS instance
+// collectRDSInstanceData gathers all relevant data for a single RDS instance, returning
+// ctx.Err() instead of calling AWS once ctx is done
 func collectRDSInstanceData(
 	ctx context.Context,
-	rdsClient *rds.Client,
-	cwClient *cloudwatch.Client,
+	rdsClient RDSDescribeAPI,
+	cwClient CloudWatchMetricsAPI,
 	db rdsTypes.DBInstance,
+	daysBack int,
 ) (RDSInstance, error) {
 	instanceID := aws.ToString(db.DBInstanceIdentifier)
 
@@ -132,6 +173,8 @@ func collectRDSInstanceData(
 		Tags:          make(map[string]string),
 	}
 
+	instance.GravitonSupported = rdsSupportsGraviton(instance.InstanceType, instance.Engine)
+
 	// Set allocated storage
 	if db.AllocatedStorage != nil {
 		instance.AllocatedStorage = *db.AllocatedStorage
@@ -148,13 +191,16 @@ func collectRDSInstanceData(
 	}
 
 	// Get instance tags
+	if err := ctx.Err(); err != nil {
+		return instance, err
+	}
 	tagsInput := &rds.ListTagsForResourceInput{
 		ResourceName: db.DBInstanceArn,
 	}
 
 	tagsResp, err := rdsClient.ListTagsForResource(ctx, tagsInput)
 	if err != nil {
-		log.Printf("Warning: Unable to get tags for RDS instance %s: %v", instanceID, err)
+		Warnf("Unable to get tags for RDS instance %s: %v", instanceID, err)
 	} else {
 		for _, tag := range tagsResp.TagList {
 			if tag.Key != nil && tag.Value != nil {
@@ -163,46 +209,64 @@ func collectRDSInstanceData(
 		}
 	}
 
-	// Get CloudWatch metrics
-	// Define the metrics to retrieve
-	endTime := time.Now().UTC()
-	startTime := endTime.AddDate(0, 0, -7) // Last 7 days
+	instance.MetricsPeriodDays = EffectivePeriodDays(daysBack)
 
-	// Get CPU utilization
-	cpuAvg, err := getRDSMetric(ctx, cwClient, instanceID, "CPUUtilization", startTime, endTime)
-	if err != nil {
-		log.Printf("Warning: Unable to get CPU metrics for %s: %v", instanceID, err)
-	}
-	instance.CPUAvg7d = cpuAvg
+	return instance, ctx.Err()
+}
 
-	// Get database connections
-	connectionsAvg, err := getRDSMetric(ctx, cwClient, instanceID, "DatabaseConnections", startTime, endTime)
-	if err != nil {
-		log.Printf("Warning: Unable to get connections metrics for %s: %v", instanceID, err)
+// applyRDSMetrics fills in CloudWatch metrics for all instances using batched GetMetricData calls
+func applyRDSMetrics(
+	ctx context.Context,
+	cwClient CloudWatchMetricsAPI,
+	instances []RDSInstance,
+	startTime, endTime time.Time,
+) error {
+	if len(instances) == 0 {
+		return nil
 	}
-	instance.ConnectionsAvg7d = connectionsAvg
 
-	// Get IOPS (Read + Write)
-	readIOPSAvg, err := getRDSMetric(ctx, cwClient, instanceID, "ReadIOPS", startTime, endTime)
-	if err != nil {
-		log.Printf("Warning: Unable to get Read IOPS metrics for %s: %v", instanceID, err)
+	metricNames := []string{"CPUUtilization", "DatabaseConnections", "ReadIOPS", "WriteIOPS", "FreeStorageSpace"}
+
+	queries := make([]metricQuery, 0, len(instances)*len(metricNames))
+	for _, inst := range instances {
+		dims := []types.Dimension{{
+			Name:  aws.String("DBInstanceIdentifier"),
+			Value: aws.String(inst.InstanceID),
+		}}
+		for _, name := range metricNames {
+			queries = append(queries, metricQuery{
+				ResourceID: inst.InstanceID,
+				Namespace:  "AWS/RDS",
+				MetricName: name,
+				Dimensions: dims,
+				Stat:       "Average",
+				Period:     3600, // 1 hour granularity
+			})
+		}
 	}
 
-	writeIOPSAvg, err := getRDSMetric(ctx, cwClient, instanceID, "WriteIOPS", startTime, endTime)
+	metrics, err := fetchMetricAverages(ctx, cwClient, queries, startTime, endTime)
 	if err != nil {
-		log.Printf("Warning: Unable to get Write IOPS metrics for %s: %v", instanceID, err)
+		return err
 	}
 
-	instance.IOPSAvg7d = readIOPSAvg + writeIOPSAvg
+	for i := range instances {
+		instance := &instances[i]
+		m := metrics[instance.InstanceID]
+
+		instance.CPUAvg7d = m["CPUUtilization"]
+		instance.ConnectionsAvg7d = m["DatabaseConnections"]
+		instance.IOPSAvg7d = m["ReadIOPS"] + m["WriteIOPS"]
+
+		// Get storage used percentage
+		freeStorage, ok := m["FreeStorageSpace"]
+		if !ok || instance.AllocatedStorage <= 0 {
+			continue
+		}
 
-	// Get storage used percentage
-	storageUsed, err := getRDSMetric(ctx, cwClient, instanceID, "FreeStorageSpace", startTime, endTime)
-	if err != nil {
-		log.Printf("Warning: Unable to get storage metrics for %s: %v", instanceID, err)
-	} else if instance.AllocatedStorage > 0 {
 		// Convert from free bytes to used percentage
 		allocatedBytes := float64(instance.AllocatedStorage) * 1024 * 1024 * 1024 // GiB to bytes
-		instance.StorageUsed = 100.0 - ((storageUsed / allocatedBytes) * 100.0)
+		instance.StorageUsed = 100.0 - ((freeStorage / allocatedBytes) * 100.0)
 
 		// Clamp to valid range
 		if instance.StorageUsed < 0 {
@@ -212,45 +276,5 @@ func collectRDSInstanceData(
 		}
 	}
 
-	return instance, nil
-}
-
-// getRDSMetric retrieves a specific CloudWatch metric for an RDS instance
-func getRDSMetric(
-	ctx context.Context,
-	cwClient *cloudwatch.Client,
-	instanceID, metricName string,
-	startTime, endTime time.Time,
-) (float64, error) {
-	input := &cloudwatch.GetMetricStatisticsInput{
-		Namespace:  aws.String("AWS/RDS"),
-		MetricName: aws.String(metricName),
-		Dimensions: []types.Dimension{{
-			Name:  aws.String("DBInstanceIdentifier"),
-			Value: aws.String(instanceID),
-		}},
-		StartTime:  &startTime,
-		EndTime:    &endTime,
-		Period:     aws.Int32(3600), // 1 hour granularity
-		Statistics: []types.Statistic{types.StatisticAverage},
-	}
-
-	resp, err := cwClient.GetMetricStatistics(ctx, input)
-	if err != nil {
-		return 0, err
-	}
-
-	// Calculate average from datapoints
-	var sum float64
-	for _, dp := range resp.Datapoints {
-		sum += *dp.Average
-	}
-
-	// Avoid division by zero if no datapoints
-	count := float64(len(resp.Datapoints))
-	if count == 0 {
-		return 0, nil
-	}
-
-	return sum / count, nil
+	return nil
 }
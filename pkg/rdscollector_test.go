@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestListRDSInstances(t *testing.T) {
+	metrics := func(namespace, metricName, stat string, dims map[string]string) []float64 {
+		switch metricName {
+		case "CPUUtilization":
+			return []float64{12}
+		case "DatabaseConnections":
+			return []float64{4}
+		case "ReadIOPS":
+			return []float64{100}
+		case "WriteIOPS":
+			return []float64{50}
+		case "FreeStorageSpace":
+			return []float64{25 * 1024 * 1024 * 1024} // 25 of 100 GiB free
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name         string
+		rds          *fakeRDS
+		maxInstances int
+		wantErr      string
+		wantCount    int
+	}{
+		{
+			name:      "all pages",
+			rds:       &fakeRDS{region: "eu-west-1", instances: testDBInstances(5, "db.m5.large"), pageSize: 2},
+			wantCount: 5,
+		},
+		{
+			name:         "limited to maxInstances",
+			rds:          &fakeRDS{region: "eu-west-1", instances: testDBInstances(5, "db.m5.large")},
+			maxInstances: 3,
+			wantCount:    3,
+		},
+		{
+			name:    "describe error is returned",
+			rds:     &fakeRDS{region: "eu-west-1", err: errors.New("AccessDenied")},
+			wantErr: "AccessDenied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rds.tags = map[string]map[string]string{
+				"arn:aws:rds:eu-west-1:123456789012:db:db-0": {"env": "prod"},
+			}
+			cw := &fakeCloudWatch{values: metrics}
+
+			got, err := ListRDSInstances(context.Background(), tt.rds, cw, tt.maxInstances, 7)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ListRDSInstances() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListRDSInstances() error = %v", err)
+			}
+			if len(got) != tt.wantCount {
+				t.Fatalf("got %d instances, want %d", len(got), tt.wantCount)
+			}
+			for _, db := range got {
+				if db.Region != "eu-west-1" || db.Engine != "postgres" || db.AllocatedStorage != 100 {
+					t.Errorf("%s = %+v", db.InstanceID, db)
+				}
+				if db.CPUAvg7d != 12 || db.ConnectionsAvg7d != 4 || db.IOPSAvg7d != 150 {
+					t.Errorf("%s metrics: CPU %v, connections %v, IOPS %v", db.InstanceID, db.CPUAvg7d, db.ConnectionsAvg7d, db.IOPSAvg7d)
+				}
+				if db.StorageUsed != 75 {
+					t.Errorf("%s StorageUsed = %v, want 75", db.InstanceID, db.StorageUsed)
+				}
+				if db.InstanceID == "db-0" && db.Tags["env"] != "prod" {
+					t.Errorf("db-0 tags = %v", db.Tags)
+				}
+			}
+		})
+	}
+}
+
+func TestListRDSInstancesCancelled(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancelAt  int // call that cancels the scan, 0 to cancel before it starts
+		wantCalls int // at most; workers finish the call they are making
+	}{
+		{name: "before the scan", cancelAt: 0, wantCalls: 0},
+		{name: "while listing", cancelAt: 1, wantCalls: 1},
+		{name: "while collecting", cancelAt: 4, wantCalls: 4 + 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			rdsClient := &fakeRDS{region: "eu-west-1", instances: testDBInstances(30, "db.m5.large"), pageSize: 10, cancel: cancel, cancelAt: tt.cancelAt}
+			if tt.cancelAt == 0 {
+				cancel()
+			}
+			cw := &fakeCloudWatch{}
+
+			instances, err := ListRDSInstances(ctx, rdsClient, cw, 0, 7)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("ListRDSInstances() error = %v, want context.Canceled", err)
+			}
+			if instances != nil {
+				t.Errorf("got %d instances from a cancelled scan", len(instances))
+			}
+			if calls := rdsClient.callCount(); calls > tt.wantCalls {
+				t.Errorf("made %d RDS calls, want at most %d", calls, tt.wantCalls)
+			}
+			if calls := cw.metricCalls() + cw.statsCalls; calls != 0 {
+				t.Errorf("made %d CloudWatch calls after cancellation", calls)
+			}
+		})
+	}
+}
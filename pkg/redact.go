@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultLogPayloadLimit is how many bytes of a payload are logged unless LOG_PAYLOAD_LIMIT says otherwise
+const DefaultLogPayloadLimit = 512
+
+// sensitiveTagKeyPatterns mark tag keys whose values are never sent or logged in clear
+var sensitiveTagKeyPatterns = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"key",
+	"credential",
+}
+
+// sensitiveValueRegex matches values that look like credentials whatever their tag key:
+// AWS access key IDs and bearer or basic authorization headers
+var sensitiveValueRegex = regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b|(?i)\b(bearer|basic)\s+[a-z0-9._~+/=-]{8,}`)
+
+// IsSensitiveTag reports whether a tag's value must be redacted, because its key names a
+// credential or its value looks like one
+func IsSensitiveTag(key, value string) bool {
+	lower := strings.ToLower(key)
+	for _, pattern := range sensitiveTagKeyPatterns {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return sensitiveValueRegex.MatchString(value)
+}
+
+// RedactTags returns a copy of tags with every sensitive value replaced by a short hash.
+// The hash is stable, so equal secrets still compare equal without being readable.
+func RedactTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if IsSensitiveTag(key, value) {
+			value = redactValue(value)
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// redactValue replaces a value with the first 8 hex characters of its SHA-256
+func redactValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "redacted:" + hex.EncodeToString(sum[:4])
+}
+
+// redactResourceTags redacts the tags of every item in place, through the map each item holds
+func redactResourceTags[T any](items []T, tagsOf func(T) map[string]string) {
+	for _, item := range items {
+		tags := tagsOf(item)
+		for key, value := range RedactTags(tags) {
+			tags[key] = value
+		}
+	}
+}
+
+// TruncateForLog shortens s to at most limit bytes for logging, noting how much was cut
+func TruncateForLog(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d more bytes)", s[:limit], len(s)-limit)
+}
+
+// PayloadLoggingEnabled reports whether raw request and model payloads may be logged. They
+// need both debug logging and DEBUG_PAYLOADS=true, since they can hold resource metadata.
+func PayloadLoggingEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DEBUG_PAYLOADS"))
+	return enabled && DebugEnabled()
+}
+
+// logPayloadLimit returns LOG_PAYLOAD_LIMIT, or DefaultLogPayloadLimit when it is unset or invalid
+func logPayloadLimit() int {
+	if limit, err := strconv.Atoi(os.Getenv("LOG_PAYLOAD_LIMIT")); err == nil && limit > 0 {
+		return limit
+	}
+	return DefaultLogPayloadLimit
+}
+
+// DebugPayload logs a raw payload, truncated, when payload logging is enabled
+func DebugPayload(label, payload string) {
+	if !PayloadLoggingEnabled() {
+		return
+	}
+	Debugf("%s: %s", label, TruncateForLog(payload, logPayloadLimit()))
+}
@@ -0,0 +1,343 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RemediationFinding is a deterministic finding of the scan that a remediation can act on.
+// Findings come from the collected data, never from the text of an analysis.
+type RemediationFinding string
+
+const (
+	FindingIdleInstance            RemediationFinding = "idle-instance"
+	FindingUnassociatedElasticIP   RemediationFinding = "unassociated-eip"
+	FindingNoLifecycleRule         RemediationFinding = "no-lifecycle-rule"
+	FindingRDSStorageOverallocated RemediationFinding = "rds-storage-overallocated"
+)
+
+// Settings of the generated remediations
+const (
+	remediationTransitionDays  = 30  // S3 objects move to Intelligent-Tiering after this many days
+	remediationAbortUploadDays = 7   // incomplete multipart uploads are aborted after this many days
+	remediationNoncurrentDays  = 90  // noncurrent object versions expire after this many days
+	remediationRDSHeadroom     = 1.5 // the shrunk allocation is the used storage with 50% to grow into
+	remediationRDSMinStorageGB = 20  // the smallest gp2 and gp3 allocation RDS accepts
+)
+
+// RemediationScript is one generated file: an AWS CLI shell script or a Terraform snippet
+type RemediationScript struct {
+	Name    string
+	Content string
+}
+
+// remediationTarget is the report item a remediation acts on, with what its header says about it
+type remediationTarget struct {
+	Index      int
+	Item       ReportItem
+	ResourceID string
+	Region     string
+	Savings    float64
+	Now        time.Time
+}
+
+// remediationGenerator writes the scripts of one finding
+type remediationGenerator func(target remediationTarget) []RemediationScript
+
+// remediationGenerators holds the generator of each finding
+var remediationGenerators = map[RemediationFinding]remediationGenerator{
+	FindingIdleInstance:            idleInstanceRemediation,
+	FindingUnassociatedElasticIP:   elasticIPRemediation,
+	FindingNoLifecycleRule:         lifecycleRuleRemediation,
+	FindingRDSStorageOverallocated: rdsStorageRemediation,
+}
+
+// RemediationFindings returns the findings of a report item that have a remediation
+func RemediationFindings(item ReportItem) []RemediationFinding {
+	var findings []RemediationFinding
+	switch item.GetResourceType() {
+	case ResourceTypeEC2:
+		if item.IsIdle() {
+			findings = append(findings, FindingIdleInstance)
+		}
+	case ResourceTypeNetwork:
+		if item.NetworkResource.Type == NetworkTypeElasticIP {
+			findings = append(findings, FindingUnassociatedElasticIP)
+		}
+	case ResourceTypeS3:
+		if !item.S3Bucket.hasEnabledLifecycleRule() {
+			findings = append(findings, FindingNoLifecycleRule)
+		}
+	case ResourceTypeRDS:
+		if rdsStorageOverallocated(item.RDSInstance) {
+			findings = append(findings, FindingRDSStorageOverallocated)
+		}
+	}
+	return findings
+}
+
+// GenerateRemediations writes the remediation scripts of the findings of a report, in report
+// order. Each script is headed by the report item it acts on and its estimated savings. The
+// scripts are only generated; nothing runs them.
+func GenerateRemediations(report []ReportItem) []RemediationScript {
+	return generateRemediations(report, time.Now().UTC())
+}
+
+// generateRemediations writes the remediation scripts of a report as generated at now
+func generateRemediations(report []ReportItem, now time.Time) []RemediationScript {
+	var scripts []RemediationScript
+	for i, item := range report {
+		resourceID, region, _, _ := describeItem(item)
+		_, _, savings := extractItemMetrics(item)
+		target := remediationTarget{Index: i + 1, Item: item, ResourceID: resourceID, Region: region, Savings: savings, Now: now}
+		for _, finding := range RemediationFindings(item) {
+			scripts = append(scripts, remediationGenerators[finding](target)...)
+		}
+	}
+	return scripts
+}
+
+// WriteRemediations writes scripts into dir, creating it when needed. Scripts are written
+// without the executable bit so they are read before they are run.
+func WriteRemediations(dir string, scripts []RemediationScript) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create script directory: %w", err)
+	}
+	for _, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, script.Name), []byte(script.Content), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", script.Name, err)
+		}
+	}
+	return nil
+}
+
+// unsafeFileChars are the characters replaced in the resource IDs of file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileName names a script of a target, e.g. "003-ec2-i-0abc-stop.sh"
+func (t remediationTarget) fileName(action, ext string) string {
+	id := unsafeFileChars.ReplaceAllString(t.ResourceID, "-")
+	return fmt.Sprintf("%03d-%s-%s-%s.%s", t.Index, t.Item.GetResourceType(), id, action, ext)
+}
+
+// header describes the target of a script in comment lines: the report item, its console
+// page, the finding and the estimated savings
+func (t remediationTarget) header(title, finding string) string {
+	location := ""
+	if t.Region != "" {
+		location = " in " + t.Region
+	}
+	if accountID := t.Item.AccountID(); accountID != "" {
+		location += " (account " + accountID + ")"
+	}
+
+	lines := []string{
+		"GreenOps remediation: " + title,
+		fmt.Sprintf("Report item #%d: %s %s%s", t.Index, t.Item.GetResourceType(), t.ResourceID, location),
+	}
+	if link := itemConsoleURL(t.Item); link != "" {
+		lines = append(lines, "Console: "+link)
+	}
+	lines = append(lines, "Finding: "+finding)
+	if t.Savings > 0 {
+		lines = append(lines, fmt.Sprintf("Estimated savings: $%.2f per month", t.Savings))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Generated by %s on %s. Nothing has been run: review it before applying it.", ReportGenerator(), t.Now.Format(time.DateOnly)),
+	)
+
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString("# " + line + "\n")
+	}
+	return sb.String()
+}
+
+// shellScript assembles a bash script from its header and commands
+func shellScript(header, commands string) string {
+	return "#!/usr/bin/env bash\n" + header + "set -euo pipefail\n\n" + commands
+}
+
+// safeShellWord matches the words that need no quoting in a shell command
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// shellQuote quotes a word for a shell command when it needs it
+func shellQuote(word string) string {
+	if safeShellWord.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// unsafeTerraformChars are the characters replaced in Terraform resource names
+var unsafeTerraformChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// terraformName turns a resource ID into a Terraform resource name, e.g. "i_0abc"
+func terraformName(id string) string {
+	name := strings.Trim(unsafeTerraformChars.ReplaceAllString(id, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "r_" + name
+	}
+	return name
+}
+
+// idleInstanceRemediation stops an idle EC2 instance, keeping its volumes so it can be started again
+func idleInstanceRemediation(t remediationTarget) []RemediationScript {
+	instance := t.Item.Instance
+	finding := fmt.Sprintf("idle, %.1f%% average CPU over %d days", instance.CPUAvg7d, EffectivePeriodDays(instance.MetricsPeriodDays))
+	if instance.IdleSince != nil {
+		finding += ", idle since " + instance.IdleSince.Format(time.DateOnly)
+	}
+	title := "stop idle EC2 instance " + instance.InstanceID
+
+	commands := fmt.Sprintf(`# Stopping keeps the instance and its EBS volumes, which are still billed; start it again
+# with: aws ec2 start-instances --region %[1]s --instance-ids %[2]s
+aws ec2 create-tags --region %[1]s --resources %[2]s --tags Key=greenops:stopped,Value=%[3]s
+aws ec2 stop-instances --region %[1]s --instance-ids %[2]s
+`, shellQuote(instance.Region), shellQuote(instance.InstanceID), t.Now.Format(time.DateOnly))
+
+	terraform := t.header(title, finding) + fmt.Sprintf(`
+resource "aws_ec2_instance_state" %q {
+  instance_id = %q
+  state       = "stopped"
+}
+`, terraformName(instance.InstanceID), instance.InstanceID)
+
+	return []RemediationScript{
+		{Name: t.fileName("stop", "sh"), Content: shellScript(t.header(title, finding), commands)},
+		{Name: t.fileName("stop", "tf"), Content: terraform},
+	}
+}
+
+// elasticIPRemediation releases an Elastic IP that is associated with nothing
+func elasticIPRemediation(t remediationTarget) []RemediationScript {
+	address := t.Item.NetworkResource
+	finding := "Elastic IP " + address.PublicIP + " is not associated with any instance or network interface"
+	title := "release unassociated Elastic IP " + address.ResourceID
+
+	commands := fmt.Sprintf(`# A released address cannot be allocated again unless nobody else took it; keep it if a DNS
+# record or an allowlist refers to %[3]s
+association=$(aws ec2 describe-addresses --region %[1]s --allocation-ids %[2]s --query 'Addresses[0].AssociationId' --output text)
+if [ "$association" != "None" ]; then
+  echo "%[2]s has been associated since the scan ($association); not releasing it" >&2
+  exit 1
+fi
+aws ec2 release-address --region %[1]s --allocation-id %[2]s
+`, shellQuote(address.Region), shellQuote(address.ResourceID), address.PublicIP)
+
+	return []RemediationScript{
+		{Name: t.fileName("release", "sh"), Content: shellScript(t.header(title, finding), commands)},
+	}
+}
+
+// lifecycleRuleRemediation adds a lifecycle rule to a bucket without an enabled one: objects
+// move to Intelligent-Tiering, incomplete uploads are aborted and, on versioned buckets,
+// noncurrent versions expire
+func lifecycleRuleRemediation(t remediationTarget) []RemediationScript {
+	bucket := t.Item.S3Bucket
+	sizeGB := float64(bucket.SizeBytes) / (1024 * 1024 * 1024)
+	finding := fmt.Sprintf("no enabled lifecycle rule on %.2f GB in %d objects", sizeGB, bucket.ObjectCount)
+	title := "add a lifecycle rule to S3 bucket " + bucket.BucketName
+	versioned := bucket.VersioningEnabled != nil && *bucket.VersioningEnabled
+
+	noncurrent := ""
+	if versioned {
+		noncurrent = fmt.Sprintf(`,
+      "NoncurrentVersionExpiration": {"NoncurrentDays": %d}`, remediationNoncurrentDays)
+	}
+	commands := fmt.Sprintf(`# put-bucket-lifecycle-configuration replaces the whole configuration, including any disabled
+# rules; check what is there first
+aws s3api get-bucket-lifecycle-configuration --bucket %[1]s || true
+
+aws s3api put-bucket-lifecycle-configuration --bucket %[1]s --lifecycle-configuration '{
+  "Rules": [
+    {
+      "ID": "greenops-tiering",
+      "Status": "Enabled",
+      "Filter": {"Prefix": ""},
+      "Transitions": [{"Days": %[2]d, "StorageClass": "INTELLIGENT_TIERING"}],
+      "AbortIncompleteMultipartUpload": {"DaysAfterInitiation": %[3]d}%[4]s
+    }
+  ]
+}'
+`, shellQuote(bucket.BucketName), remediationTransitionDays, remediationAbortUploadDays, noncurrent)
+
+	noncurrentBlock := ""
+	if versioned {
+		noncurrentBlock = fmt.Sprintf(`
+    noncurrent_version_expiration {
+      noncurrent_days = %d
+    }
+`, remediationNoncurrentDays)
+	}
+	terraform := t.header(title, finding) + fmt.Sprintf(`
+resource "aws_s3_bucket_lifecycle_configuration" %q {
+  bucket = %q
+
+  rule {
+    id     = "greenops-tiering"
+    status = "Enabled"
+
+    filter {}
+
+    transition {
+      days          = %d
+      storage_class = "INTELLIGENT_TIERING"
+    }
+
+    abort_incomplete_multipart_upload {
+      days_after_initiation = %d
+    }
+%s  }
+}
+`, terraformName(bucket.BucketName), bucket.BucketName, remediationTransitionDays, remediationAbortUploadDays, noncurrentBlock)
+
+	return []RemediationScript{
+		{Name: t.fileName("lifecycle", "sh"), Content: shellScript(t.header(title, finding), commands)},
+		{Name: t.fileName("lifecycle", "tf"), Content: terraform},
+	}
+}
+
+// rdsStorageTargetGB is the allocation an overallocated RDS instance could move to: its used
+// storage with remediationRDSHeadroom, at least remediationRDSMinStorageGB
+func rdsStorageTargetGB(instance RDSInstance) int {
+	usedGB := float64(instance.AllocatedStorage) * instance.StorageUsed / 100
+	return max(int(math.Ceil(usedGB*remediationRDSHeadroom)), remediationRDSMinStorageGB)
+}
+
+// rdsStorageRemediation is guidance rather than a fix: RDS storage cannot shrink in place, so
+// the script takes a snapshot and leaves the migration to a smaller volume commented out
+func rdsStorageRemediation(t remediationTarget) []RemediationScript {
+	instance := t.Item.RDSInstance
+	target := rdsStorageTargetGB(instance)
+	finding := fmt.Sprintf("%.1f%% of %d GB of storage used", instance.StorageUsed, instance.AllocatedStorage)
+	title := fmt.Sprintf("shrink the storage of RDS instance %s from %d GB to %d GB", instance.InstanceID, instance.AllocatedStorage, target)
+
+	accountID := instance.AccountID
+	if accountID == "" {
+		accountID = "<account-id>"
+	}
+	commands := fmt.Sprintf(`# Allocated storage cannot shrink in place. Take a snapshot first, then move the database to
+# a %[3]d GB volume with a blue/green deployment, where the engine supports shrinking storage,
+# or by restoring a dump into a new instance. The steps after the snapshot are commented out
+# because they need a maintenance window and an application cutover.
+aws rds create-db-snapshot --region %[1]s --db-instance-identifier %[2]s \
+  --db-snapshot-identifier %[2]s-before-shrink-%[4]s
+
+# aws rds create-blue-green-deployment --region %[1]s \
+#   --blue-green-deployment-name %[2]s-shrink \
+#   --source arn:aws:rds:%[1]s:%[5]s:db:%[2]s \
+#   --target-allocated-storage %[3]d
+# Once the green environment has caught up and been tested:
+# aws rds switchover-blue-green-deployment --region %[1]s --blue-green-deployment-identifier <deployment-id>
+`, shellQuote(instance.Region), shellQuote(instance.InstanceID), target, t.Now.Format("20060102"), accountID)
+
+	return []RemediationScript{
+		{Name: t.fileName("shrink-storage", "sh"), Content: shellScript(t.header(title, finding), commands)},
+	}
+}
@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// remediationTime is when the golden remediation scripts were generated
+var remediationTime = time.Date(2025, 6, 2, 9, 30, 0, 0, time.UTC)
+
+// remediationReport has one item of each deterministic finding, and items without findings
+func remediationReport() []ReportItem {
+	idleSince := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	versioned := true
+	return []ReportItem{
+		{
+			ResourceType: ResourceTypeEC2,
+			Instance: Instance{
+				InstanceID: "i-0abc123", InstanceType: "m5.large", Region: "eu-west-1", AccountID: "123456789012",
+				CPUAvg7d: 0.8, MetricsPeriodDays: 14, Idle: true, IdleSince: &idleSince,
+			},
+			MonthlyCost: 69.12, MonthlySavings: 69.12,
+		},
+		{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: "i-0busy", Region: "eu-west-1", CPUAvg7d: 60}},
+		{
+			ResourceType:    ResourceTypeNetwork,
+			NetworkResource: NetworkResource{Type: NetworkTypeElasticIP, ResourceID: "eipalloc-0def456", Region: "us-east-1", PublicIP: "203.0.113.10"},
+			MonthlyCost:     3.6, MonthlySavings: 3.6,
+		},
+		{
+			ResourceType: ResourceTypeS3,
+			S3Bucket: S3Bucket{
+				BucketName: "team's logs", Region: "eu-west-1", SizeBytes: 250 * 1024 * 1024 * 1024, ObjectCount: 48000,
+				VersioningEnabled: &versioned,
+			},
+			MonthlyCost: 5.75, MonthlySavings: 2.1,
+		},
+		{
+			ResourceType: ResourceTypeS3,
+			S3Bucket:     S3Bucket{BucketName: "managed", Region: "eu-west-1", LifecycleRules: []LifecycleRuleInfo{{ID: "expire", Status: "Enabled"}}},
+		},
+		{
+			ResourceType: ResourceTypeRDS,
+			RDSInstance: RDSInstance{
+				InstanceID: "orders-db", Region: "eu-west-1", AccountID: "123456789012", Engine: "postgres",
+				AllocatedStorage: 500, StorageUsed: 8.4,
+			},
+			MonthlyCost: 57.5, MonthlySavings: 48.3,
+		},
+	}
+}
+
+// The scripts of each finding are snapshotted, one golden file per finding
+func TestGenerateRemediations(t *testing.T) {
+	scripts := generateRemediations(remediationReport(), remediationTime)
+	byFinding := map[RemediationFinding]string{
+		FindingIdleInstance:            "-ec2-",
+		FindingUnassociatedElasticIP:   "-network-",
+		FindingNoLifecycleRule:         "-s3-",
+		FindingRDSStorageOverallocated: "-rds-",
+	}
+
+	for finding, kind := range byFinding {
+		t.Run(string(finding), func(t *testing.T) {
+			var sb strings.Builder
+			for _, script := range scripts {
+				if strings.Contains(script.Name, kind) {
+					fmt.Fprintf(&sb, "==> %s <==\n%s\n", script.Name, script.Content)
+				}
+			}
+			if sb.Len() == 0 {
+				t.Fatalf("no scripts for %s", finding)
+			}
+			checkGolden(t, "remediation_"+string(finding)+".golden", []byte(sb.String()))
+		})
+	}
+
+	var names []string
+	for _, script := range scripts {
+		names = append(names, script.Name)
+	}
+	want := []string{
+		"001-ec2-i-0abc123-stop.sh", "001-ec2-i-0abc123-stop.tf",
+		"003-network-eipalloc-0def456-release.sh",
+		"004-s3-team-s-logs-lifecycle.sh", "004-s3-team-s-logs-lifecycle.tf",
+		"006-rds-orders-db-shrink-storage.sh",
+	}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("scripts = %v, want %v", names, want)
+	}
+}
+
+func TestRemediationFindings(t *testing.T) {
+	var got []string
+	for _, item := range remediationReport() {
+		got = append(got, fmt.Sprint(RemediationFindings(item)))
+	}
+	want := []string{"[idle-instance]", "[]", "[unassociated-eip]", "[no-lifecycle-rule]", "[]", "[rds-storage-overallocated]"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("RemediationFindings() = %v, want %v", got, want)
+	}
+}
+
+func TestRDSStorageTargetGB(t *testing.T) {
+	tests := []struct {
+		allocated int32
+		used      float64
+		want      int
+	}{
+		{allocated: 500, used: 8.4, want: 63}, // 42 GB used
+		{allocated: 1000, used: 20, want: 300},
+		{allocated: 100, used: 5, want: remediationRDSMinStorageGB},
+	}
+
+	for _, tt := range tests {
+		if got := rdsStorageTargetGB(RDSInstance{AllocatedStorage: tt.allocated, StorageUsed: tt.used}); got != tt.want {
+			t.Errorf("rdsStorageTargetGB(%d GB, %.1f%%) = %d, want %d", tt.allocated, tt.used, got, tt.want)
+		}
+	}
+}
+
+// Scripts are written for review, never executable
+func TestWriteRemediations(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "remediation")
+	scripts := generateRemediations(remediationReport(), remediationTime)
+	if err := WriteRemediations(dir, scripts); err != nil {
+		t.Fatalf("WriteRemediations() error = %v", err)
+	}
+
+	for _, script := range scripts {
+		info, err := os.Stat(filepath.Join(dir, script.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0o111 != 0 {
+			t.Errorf("%s is executable: %s", script.Name, info.Mode())
+		}
+	}
+}
@@ -8,20 +8,246 @@ import (
 type ResourceType string
 
 const (
-	ResourceTypeEC2 ResourceType = "ec2"
-	ResourceTypeS3  ResourceType = "s3"
-	ResourceTypeRDS ResourceType = "rds"
-	ResourceTypeEBS ResourceType = "ebs"
+	ResourceTypeEC2         ResourceType = "ec2"
+	ResourceTypeS3          ResourceType = "s3"
+	ResourceTypeRDS         ResourceType = "rds"
+	ResourceTypeEBS         ResourceType = "ebs"
+	ResourceTypeLambda      ResourceType = "lambda"
+	ResourceTypeELB         ResourceType = "elb"
+	ResourceTypeNetwork     ResourceType = "network"
+	ResourceTypeDynamoDB    ResourceType = "dynamodb"
+	ResourceTypeElastiCache ResourceType = "elasticache"
+	// ResourceTypeSnapshots items carry every stale snapshot of a scan, analyzed together
+	ResourceTypeSnapshots ResourceType = "snapshots"
+	// ResourceTypeAccount is the account-level analysis of a whole report, never a report item
+	ResourceTypeAccount ResourceType = "account"
 )
 
 // ReportItem represents a single analyzed resource
 type ReportItem struct {
-	ResourceType ResourceType `json:"resource_type,omitempty"`
-	Instance     Instance     `json:"instance,omitempty"`
-	S3Bucket     S3Bucket     `json:"s3_bucket,omitempty"`
-	RDSInstance  RDSInstance  `json:"rds_instance,omitempty"`
-	Embedding    []float64    `json:"embedding,omitempty"`
-	Analysis     string       `json:"analysis"`
+	ResourceType    ResourceType       `json:"resource_type,omitempty"`
+	Instance        Instance           `json:"instance,omitempty"`
+	S3Bucket        S3Bucket           `json:"s3_bucket,omitempty"`
+	RDSInstance     RDSInstance        `json:"rds_instance,omitempty"`
+	EBSVolume       EBSVolume          `json:"ebs_volume,omitempty"`
+	LambdaFunction  LambdaFunction     `json:"lambda_function,omitempty"`
+	LoadBalancer    LoadBalancer       `json:"load_balancer,omitempty"`
+	NetworkResource NetworkResource    `json:"network_resource,omitempty"`
+	DynamoTable     DynamoTable        `json:"dynamo_table,omitempty"`
+	ElastiCache     ElastiCacheCluster `json:"elasticache_cluster,omitempty"`
+	Snapshots       []EBSSnapshot      `json:"snapshots,omitempty"`
+	Embedding       []float64          `json:"embedding,omitempty"`
+	Analysis        string             `json:"analysis"`
+	// StructuredAnalysis is the analysis as the model returned it in AnalysisFormatJSON, in
+	// which case Analysis holds its markdown rendering; nil for markdown analyses
+	StructuredAnalysis *StructuredAnalysis `json:"structured_analysis,omitempty" dynamodbav:"structured_analysis,omitempty"`
+	// AnalysisQuality is how completely the analysis follows its prompt template; nil when the
+	// analysis was written without the model, or predates the field
+	AnalysisQuality *AnalysisQuality `json:"analysis_quality,omitempty" dynamodbav:"analysis_quality,omitempty"`
+	// RightsizeSuggestion is the instance type SuggestRightsize proposes for an EC2 instance;
+	// nil for other resources and instances it has nothing to suggest for
+	RightsizeSuggestion *RightsizeSuggestion `json:"rightsize_suggestion,omitempty" dynamodbav:"rightsize_suggestion,omitempty"`
+	// StoragePlan is the storage class plan PlanS3Storage computes for an S3 bucket; nil for
+	// other resources and buckets it cannot price
+	StoragePlan *StorageOptimizationPlan `json:"storage_plan,omitempty" dynamodbav:"storage_plan,omitempty"`
+	// PurchaseOptions are the purchase options AdvisePurchaseOptions compares for an RDS
+	// instance; nil for other resources and instances it cannot price
+	PurchaseOptions *PurchaseOptions `json:"purchase_options,omitempty" dynamodbav:"purchase_options,omitempty"`
+
+	// Structured metrics extracted by the worker; zero for records written before they existed
+	CO2KgMonthly   float64 `json:"co2_kg_monthly,omitempty" dynamodbav:"co2_kg_monthly,omitempty"`
+	MonthlyCost    float64 `json:"monthly_cost,omitempty" dynamodbav:"monthly_cost,omitempty"`
+	OptimizedCost  float64 `json:"optimized_cost,omitempty" dynamodbav:"optimized_cost,omitempty"`
+	MonthlySavings float64 `json:"monthly_savings,omitempty" dynamodbav:"monthly_savings,omitempty"`
+	SavingsPct     float64 `json:"savings_pct,omitempty" dynamodbav:"savings_pct,omitempty"`
+	// CostSource says where MonthlyCost came from: CostSourcePricingAPI, CostSourcePricingTable or CostSourceModel
+	CostSource string `json:"cost_source,omitempty" dynamodbav:"cost_source,omitempty"`
+	// Fingerprint identifies the resource and model for the analysis cache; Cached marks
+	// results the API answered from that cache instead of analyzing the resource again
+	Fingerprint string `json:"fingerprint,omitempty" dynamodbav:"fingerprint,omitempty"`
+	Cached      bool   `json:"cached,omitempty" dynamodbav:"cached,omitempty"`
+	// ModelID is the inference profile or model that wrote Analysis; empty when the analysis
+	// was priced locally after the model failed, or predates the field
+	ModelID string `json:"model_id,omitempty" dynamodbav:"model_id,omitempty"`
+	// AnalysisUsage is what generating Analysis used; zero for cached results, which cost nothing
+	AnalysisUsage
+	// Rank is the position set by RankReport when the report is rendered; it is not stored
+	Rank int `json:"rank,omitempty" dynamodbav:"-"`
+}
+
+// ApplyAnalysisMetrics fills the structured metric fields from the structured analysis, or
+// from the analysis text when there is none
+func (r *ReportItem) ApplyAnalysisMetrics() {
+	var metrics AnalysisMetrics
+	if r.StructuredAnalysis != nil {
+		metrics = r.StructuredAnalysis.Metrics()
+	} else {
+		metrics = ExtractAnalysisMetrics(r.Analysis)
+	}
+	r.CO2KgMonthly = metrics.CO2KgMonthly
+	r.MonthlyCost = metrics.MonthlyCost
+	r.OptimizedCost = metrics.OptimizedCost
+	r.MonthlySavings = metrics.MonthlySavings
+	r.SavingsPct = metrics.SavingsPct
+
+	// The EC2 footprint comes from the region's grid intensity rather than the model's arithmetic
+	if r.GetResourceType() == ResourceTypeEC2 && r.Instance.InstanceType != "" {
+		r.CO2KgMonthly, _ = EstimateEC2CO2(r.Instance.InstanceType, r.Instance.Region, r.Instance.CPUAvg7d)
+		r.RightsizeSuggestion = nil
+		if suggestion, ok := SuggestRightsize(r.Instance); ok {
+			r.RightsizeSuggestion = &suggestion
+		}
+	}
+
+	// Lambda usage is measured directly, so estimate the footprint when the analysis gives none
+	if r.CO2KgMonthly == 0 && r.GetResourceType() == ResourceTypeLambda {
+		r.CO2KgMonthly = EstimateLambdaCO2Monthly(r.LambdaFunction)
+	}
+
+	// Network findings are priced from fixed rates when the analysis gives no figures
+	if !r.HasStructuredMetrics() && r.GetResourceType() == ResourceTypeNetwork {
+		estimate := EstimateNetworkResource(r.NetworkResource)
+		r.CO2KgMonthly = estimate.CO2KgMonthly
+		r.MonthlyCost = estimate.MonthlyCost
+		r.MonthlySavings = estimate.MonthlySavings
+		r.CostSource = CostSourcePricingTable
+		if estimate.MonthlyCost > 0 {
+			r.SavingsPct = 100
+		}
+	}
+
+	// Snapshot storage is priced the same way, and deleting them saves all of it
+	if !r.HasStructuredMetrics() && r.GetResourceType() == ResourceTypeSnapshots {
+		summary := SummarizeSnapshots(r.Snapshots)
+		r.CO2KgMonthly = summary.CO2KgMonthly
+		r.MonthlyCost = summary.MonthlyCost
+		r.MonthlySavings = summary.MonthlyCost
+		r.CostSource = CostSourcePricingTable
+		if summary.MonthlyCost > 0 {
+			r.SavingsPct = 100
+		}
+	}
+
+	// List prices replace the model's cost where the pricing table covers the resource
+	if cost, source, ok := r.listPriceMonthlyCost(); ok {
+		r.applyListPrice(cost, source)
+	} else if r.MonthlyCost > 0 && r.CostSource == "" {
+		r.CostSource = CostSourceModel
+	}
+
+	// Incomplete uploads and noncurrent versions can be deleted outright
+	if r.GetResourceType() == ResourceTypeS3 {
+		r.applyReclaimableSavings()
+		r.StoragePlan = nil
+		if plan, ok := PlanS3Storage(r.S3Bucket); ok {
+			r.StoragePlan = &plan
+		}
+	}
+	if r.GetResourceType() == ResourceTypeRDS {
+		r.PurchaseOptions = nil
+		if options, ok := AdvisePurchaseOptions(r.RDSInstance); ok {
+			r.PurchaseOptions = &options
+		}
+	}
+}
+
+// listPriceMonthlyCost prices the resource from a live price recorded on it or the bundled
+// pricing table, and names the source used
+func (r *ReportItem) listPriceMonthlyCost() (float64, string, bool) {
+	switch r.GetResourceType() {
+	case ResourceTypeEC2:
+		cost, ok := EstimateEC2MonthlyCost(r.Instance)
+		return cost, priceSourceOrTable(r.Instance.PriceSource), ok
+	case ResourceTypeRDS:
+		cost, ok := EstimateRDSMonthlyCost(r.RDSInstance)
+		return cost, priceSourceOrTable(r.RDSInstance.PriceSource), ok
+	case ResourceTypeS3:
+		cost, ok := EstimateS3MonthlyCost(r.S3Bucket)
+		return cost, CostSourcePricingTable, ok
+	default:
+		return 0, "", false
+	}
+}
+
+// priceSourceOrTable returns the source recorded with a live price, or the bundled table when there is none
+func priceSourceOrTable(source string) string {
+	if source == "" {
+		return CostSourcePricingTable
+	}
+	return source
+}
+
+// applyListPrice sets the monthly cost to a list price. The model's savings keep their share
+// of its own cost estimate, so a model that priced the resource differently still yields a
+// consistent saving.
+func (r *ReportItem) applyListPrice(cost float64, source string) {
+	savingsShare := 0.0
+	if r.MonthlyCost > 0 {
+		savingsShare = min(r.MonthlySavings/r.MonthlyCost, 1)
+	}
+
+	r.MonthlyCost = cost
+	r.MonthlySavings = cost * savingsShare
+	r.OptimizedCost = cost - r.MonthlySavings
+	r.SavingsPct = savingsShare * 100
+	r.CostSource = source
+}
+
+// ActualMonthlyCost returns the Cost Explorer spend attached to the resource, or 0 when there is none
+func (r *ReportItem) ActualMonthlyCost() float64 {
+	switch r.GetResourceType() {
+	case ResourceTypeEC2:
+		return r.Instance.ActualMonthlyCost
+	case ResourceTypeRDS:
+		return r.RDSInstance.ActualMonthlyCost
+	case ResourceTypeS3:
+		return r.S3Bucket.ActualMonthlyCost
+	default:
+		return 0
+	}
+}
+
+// AccountID returns the account the resource was scanned in with --org, or "" when it was
+// scanned with the caller's own credentials. A snapshots item has an account only when all
+// of its snapshots share one.
+func (r *ReportItem) AccountID() string {
+	switch r.GetResourceType() {
+	case ResourceTypeEC2:
+		return r.Instance.AccountID
+	case ResourceTypeS3:
+		return r.S3Bucket.AccountID
+	case ResourceTypeRDS:
+		return r.RDSInstance.AccountID
+	case ResourceTypeEBS:
+		return r.EBSVolume.AccountID
+	case ResourceTypeLambda:
+		return r.LambdaFunction.AccountID
+	case ResourceTypeELB:
+		return r.LoadBalancer.AccountID
+	case ResourceTypeNetwork:
+		return r.NetworkResource.AccountID
+	case ResourceTypeDynamoDB:
+		return r.DynamoTable.AccountID
+	case ResourceTypeElastiCache:
+		return r.ElastiCache.AccountID
+	case ResourceTypeSnapshots:
+		if len(r.Snapshots) == 0 {
+			return ""
+		}
+		for _, snapshot := range r.Snapshots[1:] {
+			if snapshot.AccountID != r.Snapshots[0].AccountID {
+				return ""
+			}
+		}
+		return r.Snapshots[0].AccountID
+	default:
+		return ""
+	}
+}
+
+// HasStructuredMetrics reports whether the worker stored explicit metrics for this item
+func (r *ReportItem) HasStructuredMetrics() bool {
+	return r.CO2KgMonthly > 0 || r.MonthlyCost > 0 || r.MonthlySavings > 0
 }
 
 // GetResourceType explicitly determines the type of resource based on data
@@ -44,6 +270,34 @@ func (r *ReportItem) GetResourceType() ResourceType {
 		return ResourceTypeRDS
 	}
 
+	if !IsEmptyObject(r.EBSVolume) && r.EBSVolume.VolumeID != "" {
+		return ResourceTypeEBS
+	}
+
+	if !IsEmptyObject(r.LambdaFunction) && r.LambdaFunction.FunctionName != "" {
+		return ResourceTypeLambda
+	}
+
+	if !IsEmptyObject(r.LoadBalancer) && r.LoadBalancer.Name != "" {
+		return ResourceTypeELB
+	}
+
+	if !IsEmptyObject(r.NetworkResource) && r.NetworkResource.ResourceID != "" {
+		return ResourceTypeNetwork
+	}
+
+	if !IsEmptyObject(r.DynamoTable) && r.DynamoTable.TableName != "" {
+		return ResourceTypeDynamoDB
+	}
+
+	if !IsEmptyObject(r.ElastiCache) && r.ElastiCache.ClusterID != "" {
+		return ResourceTypeElastiCache
+	}
+
+	if len(r.Snapshots) > 0 {
+		return ResourceTypeSnapshots
+	}
+
 	// Default to EC2 for backward compatibility
 	return ResourceTypeEC2
 }
@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of RightsizeSuggestion
+const (
+	RightsizeDownsize = "downsize" // a smaller size of the same family
+	RightsizeGraviton = "graviton" // the Graviton equivalent of the same size
+)
+
+// Utilization a rightsized instance is sized for. Only averages are collected, so fixed
+// performance targets keep half their CPU for peaks; burstable targets must cover the load
+// with their baseline so they do not run out of CPU credits.
+const (
+	rightsizeTargetCPU    = 50.0 // percent of the target's vCPUs
+	rightsizeTargetMemory = 70.0 // percent of the target's memory
+)
+
+// ec2Size is one size of an instance family
+// - BaselinePct: the CPU each vCPU of a burstable size may use without spending credits
+type ec2Size struct {
+	Name        string
+	VCPUs       int
+	MemoryGiB   float64
+	BaselinePct float64
+}
+
+// ec2Family is an instance family the rightsizing engine knows, with its sizes smallest first
+type ec2Family struct {
+	Burstable bool
+	Sizes     []ec2Size
+}
+
+// size returns the index of a size name in the family, or -1
+func (f ec2Family) size(name string) int {
+	for i, size := range f.Sizes {
+		if size.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// t3Sizes are the sizes of the t3, t3a and t4g families
+var t3Sizes = []ec2Size{
+	{"nano", 2, 0.5, 5},
+	{"micro", 2, 1, 10},
+	{"small", 2, 2, 20},
+	{"medium", 2, 4, 20},
+	{"large", 2, 8, 30},
+	{"xlarge", 4, 16, 40},
+	{"2xlarge", 8, 32, 40},
+}
+
+// t2Sizes are the sizes of the previous-generation t2 family
+var t2Sizes = []ec2Size{
+	{"nano", 1, 0.5, 5},
+	{"micro", 1, 1, 10},
+	{"small", 1, 2, 20},
+	{"medium", 2, 4, 20},
+	{"large", 2, 8, 30},
+	{"xlarge", 4, 16, 22.5},
+	{"2xlarge", 8, 32, 17},
+}
+
+// fixedSizes builds the sizes of a fixed performance family with memPerVCPU GiB of memory
+// per vCPU: medium has 1 vCPU, large 2, xlarge 4 and Nxlarge 4N
+func fixedSizes(memPerVCPU float64, names ...string) []ec2Size {
+	sizes := make([]ec2Size, len(names))
+	for i, name := range names {
+		vcpus := 1
+		if name != "medium" {
+			vcpus = instanceVCPUs("x." + name)
+		}
+		sizes[i] = ec2Size{Name: name, VCPUs: vcpus, MemoryGiB: float64(vcpus) * memPerVCPU}
+	}
+	return sizes
+}
+
+var (
+	intelSizes    = []string{"large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge"}
+	gravitonSizes = append([]string{"medium"}, "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge")
+	c5Sizes       = []string{"large", "xlarge", "2xlarge", "4xlarge", "9xlarge", "12xlarge", "18xlarge", "24xlarge"}
+)
+
+// ec2Families is the vCPU and memory catalog of the general purpose, compute and memory
+// optimized families
+var ec2Families = map[string]ec2Family{
+	"t2":  {Burstable: true, Sizes: t2Sizes},
+	"t3":  {Burstable: true, Sizes: t3Sizes},
+	"t3a": {Burstable: true, Sizes: t3Sizes},
+	"t4g": {Burstable: true, Sizes: t3Sizes},
+	"m5":  {Sizes: fixedSizes(4, intelSizes...)},
+	"m5a": {Sizes: fixedSizes(4, intelSizes...)},
+	"m6i": {Sizes: fixedSizes(4, intelSizes...)},
+	"m6a": {Sizes: fixedSizes(4, intelSizes...)},
+	"m7i": {Sizes: fixedSizes(4, intelSizes...)},
+	"m6g": {Sizes: fixedSizes(4, gravitonSizes...)},
+	"m7g": {Sizes: fixedSizes(4, gravitonSizes...)},
+	"c5":  {Sizes: fixedSizes(2, c5Sizes...)},
+	"c6i": {Sizes: fixedSizes(2, intelSizes...)},
+	"c6g": {Sizes: fixedSizes(2, gravitonSizes...)},
+	"c7g": {Sizes: fixedSizes(2, gravitonSizes...)},
+	"r5":  {Sizes: fixedSizes(8, intelSizes...)},
+	"r6i": {Sizes: fixedSizes(8, intelSizes...)},
+	"r6g": {Sizes: fixedSizes(8, gravitonSizes...)},
+	"r7g": {Sizes: fixedSizes(8, gravitonSizes...)},
+}
+
+// RightsizeSuggestion is a concrete instance type the rightsizing engine proposes for an EC2
+// instance. Costs are on-demand list prices for a month, zero when the pricing table does
+// not cover both types in the region.
+// - GravitonType: the Graviton equivalent of a smaller SuggestedType, when it saves more still
+type RightsizeSuggestion struct {
+	CurrentType            string  `json:"current_type" dynamodbav:"current_type"`
+	SuggestedType          string  `json:"suggested_type" dynamodbav:"suggested_type"`
+	Kind                   string  `json:"kind" dynamodbav:"kind"`
+	CurrentMonthlyCost     float64 `json:"current_monthly_cost,omitempty" dynamodbav:"current_monthly_cost,omitempty"`
+	SuggestedMonthlyCost   float64 `json:"suggested_monthly_cost,omitempty" dynamodbav:"suggested_monthly_cost,omitempty"`
+	MonthlySavings         float64 `json:"monthly_savings,omitempty" dynamodbav:"monthly_savings,omitempty"`
+	GravitonType           string  `json:"graviton_type,omitempty" dynamodbav:"graviton_type,omitempty"`
+	GravitonMonthlySavings float64 `json:"graviton_monthly_savings,omitempty" dynamodbav:"graviton_monthly_savings,omitempty"`
+}
+
+// String describes the suggestion in one line, e.g. "t3.large → t3.medium, $30.37/mo saved"
+func (s RightsizeSuggestion) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s → %s", s.CurrentType, s.SuggestedType)
+	if s.Kind == RightsizeGraviton {
+		sb.WriteString(" (Graviton)")
+	}
+	if s.MonthlySavings > 0 {
+		fmt.Fprintf(&sb, ", $%.2f/mo saved", s.MonthlySavings)
+	}
+	if s.GravitonType != "" {
+		fmt.Fprintf(&sb, "; %s (Graviton) would save $%.2f/mo", s.GravitonType, s.GravitonMonthlySavings)
+	}
+	return sb.String()
+}
+
+// SuggestRightsize proposes a concrete instance type for an EC2 instance: the smallest size of
+// its family that carries its average CPU load and memory at the target utilization, else the
+// Graviton equivalent of its size. Without memory metrics memory is not cut by more than
+// half. Burstable instances only move to sizes whose baseline covers the load, and fixed
+// performance instances stay fixed performance. ok is false for families missing from the
+// catalog and for instances with nothing to suggest.
+func SuggestRightsize(instance Instance) (RightsizeSuggestion, bool) {
+	familyName, sizeName := splitInstanceType(instance.InstanceType)
+	family, known := ec2Families[familyName]
+	if !known {
+		return RightsizeSuggestion{}, false
+	}
+	index := family.size(sizeName)
+	if index == -1 {
+		return RightsizeSuggestion{}, false
+	}
+	current := family.Sizes[index]
+
+	// Find the smallest size that fits. An average of 0 is a stopped instance or missing
+	// metrics, which says nothing about the size it needs.
+	target := -1
+	if instance.CPUAvg7d > 0 {
+		load := float64(current.VCPUs) * min(instance.CPUAvg7d, 100)
+		memoryNeeded := current.MemoryGiB / 2
+		if instance.MemAvg7d > 0 {
+			memoryNeeded = current.MemoryGiB * min(instance.MemAvg7d, 100) / rightsizeTargetMemory
+		}
+		for i, size := range family.Sizes[:index] {
+			capacity := rightsizeTargetCPU
+			if family.Burstable {
+				capacity = size.BaselinePct
+			}
+			if size.MemoryGiB >= memoryNeeded && load <= float64(size.VCPUs)*capacity {
+				target = i
+				break
+			}
+		}
+	}
+
+	suggestion := RightsizeSuggestion{CurrentType: familyName + "." + sizeName}
+	switch {
+	case target != -1:
+		suggestion.Kind = RightsizeDownsize
+		suggestion.SuggestedType = familyName + "." + family.Sizes[target].Name
+		if graviton, ok := GravitonEquivalent(suggestion.SuggestedType); ok {
+			suggestion.GravitonType = graviton
+		}
+	default:
+		graviton, ok := GravitonEquivalent(suggestion.CurrentType)
+		if !ok {
+			return RightsizeSuggestion{}, false
+		}
+		suggestion.Kind = RightsizeGraviton
+		suggestion.SuggestedType = graviton
+	}
+
+	// Price both types from the same table, so live prices of one side do not skew the delta
+	currentPrice, currentOK := LookupEC2Price(suggestion.CurrentType, instance.Region)
+	suggestedPrice, suggestedOK := LookupEC2Price(suggestion.SuggestedType, instance.Region)
+	if currentOK && suggestedOK {
+		suggestion.CurrentMonthlyCost = currentPrice * hoursPerMonth
+		suggestion.SuggestedMonthlyCost = suggestedPrice * hoursPerMonth
+		suggestion.MonthlySavings = max(suggestion.CurrentMonthlyCost-suggestion.SuggestedMonthlyCost, 0)
+		if suggestion.Kind == RightsizeGraviton && suggestion.MonthlySavings == 0 {
+			return RightsizeSuggestion{}, false
+		}
+	}
+	if suggestion.GravitonType != "" {
+		gravitonPrice, ok := LookupEC2Price(suggestion.GravitonType, instance.Region)
+		if ok && currentOK && gravitonPrice*hoursPerMonth < suggestion.SuggestedMonthlyCost {
+			suggestion.GravitonMonthlySavings = suggestion.CurrentMonthlyCost - gravitonPrice*hoursPerMonth
+		} else {
+			suggestion.GravitonType = ""
+		}
+	}
+	return suggestion, true
+}
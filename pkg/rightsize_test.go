@@ -0,0 +1,183 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSuggestRightsize(t *testing.T) {
+	tests := []struct {
+		name         string
+		instance     Instance
+		wantOK       bool
+		wantType     string
+		wantKind     string
+		wantGraviton string
+		wantSavings  float64
+	}{
+		{
+			name:         "downsize with memory metrics",
+			instance:     Instance{InstanceType: "m5.2xlarge", Region: "us-east-1", CPUAvg7d: 10, MemAvg7d: 20},
+			wantOK:       true,
+			wantType:     "m5.xlarge",
+			wantKind:     RightsizeDownsize,
+			wantGraviton: "m6g.xlarge",
+			wantSavings:  (0.384 - 0.192) * hoursPerMonth,
+		},
+		{
+			// At 20% memory m5.large would do; without the metric memory is only halved
+			name:         "memory not cut by more than half without metrics",
+			instance:     Instance{InstanceType: "m5.2xlarge", Region: "us-east-1", CPUAvg7d: 5},
+			wantOK:       true,
+			wantType:     "m5.xlarge",
+			wantKind:     RightsizeDownsize,
+			wantGraviton: "m6g.xlarge",
+			wantSavings:  (0.384 - 0.192) * hoursPerMonth,
+		},
+		{
+			name:        "memory keeps the size",
+			instance:    Instance{InstanceType: "m5.xlarge", Region: "us-east-1", CPUAvg7d: 5, MemAvg7d: 60},
+			wantOK:      true,
+			wantType:    "m6g.xlarge",
+			wantKind:    RightsizeGraviton,
+			wantSavings: (0.192 - 0.154) * hoursPerMonth,
+		},
+		{
+			name:         "c5 sizes skip to 9xlarge",
+			instance:     Instance{InstanceType: "c5.9xlarge", Region: "us-east-1", CPUAvg7d: 10, MemAvg7d: 10},
+			wantOK:       true,
+			wantType:     "c5.2xlarge",
+			wantKind:     RightsizeDownsize,
+			wantGraviton: "c6g.2xlarge",
+			wantSavings:  (1.53 - 0.34) * hoursPerMonth,
+		},
+		{
+			name:         "burstable target's baseline covers the load",
+			instance:     Instance{InstanceType: "t3.large", Region: "us-east-1", CPUAvg7d: 15},
+			wantOK:       true,
+			wantType:     "t3.medium",
+			wantKind:     RightsizeDownsize,
+			wantGraviton: "t4g.medium",
+			wantSavings:  (0.0832 - 0.0416) * hoursPerMonth,
+		},
+		{
+			name:        "load above every smaller baseline",
+			instance:    Instance{InstanceType: "t3.large", Region: "us-east-1", CPUAvg7d: 25},
+			wantOK:      true,
+			wantType:    "t4g.large",
+			wantKind:    RightsizeGraviton,
+			wantSavings: (0.0832 - 0.0672) * hoursPerMonth,
+		},
+		{
+			name:        "smallest size",
+			instance:    Instance{InstanceType: "m5.large", Region: "us-east-1", CPUAvg7d: 1, MemAvg7d: 1},
+			wantOK:      true,
+			wantType:    "m6g.large",
+			wantKind:    RightsizeGraviton,
+			wantSavings: (0.096 - 0.077) * hoursPerMonth,
+		},
+		{
+			// A stopped instance or missing metrics say nothing about the size it needs
+			name:        "no CPU average",
+			instance:    Instance{InstanceType: "m5.2xlarge", Region: "us-east-1"},
+			wantOK:      true,
+			wantType:    "m6g.2xlarge",
+			wantKind:    RightsizeGraviton,
+			wantSavings: (0.384 - 0.308) * hoursPerMonth,
+		},
+		{
+			name:        "CPU above 100%",
+			instance:    Instance{InstanceType: "m5.xlarge", Region: "us-east-1", CPUAvg7d: 150, MemAvg7d: 150},
+			wantOK:      true,
+			wantType:    "m6g.xlarge",
+			wantKind:    RightsizeGraviton,
+			wantSavings: (0.192 - 0.154) * hoursPerMonth,
+		},
+		{
+			name:     "Graviton downsize has no Graviton alternative",
+			instance: Instance{InstanceType: "m6g.xlarge", Region: "us-east-1", CPUAvg7d: 5, MemAvg7d: 10},
+			wantOK:   true,
+			wantType: "m6g.medium",
+			wantKind: RightsizeDownsize,
+			// m6g.xlarge to m6g.medium
+			wantSavings: (0.154 - 0.0385) * hoursPerMonth,
+		},
+		{
+			name:         "mixed case type",
+			instance:     Instance{InstanceType: "M5.2XLARGE", Region: "us-east-1", CPUAvg7d: 10, MemAvg7d: 20},
+			wantOK:       true,
+			wantType:     "m5.xlarge",
+			wantKind:     RightsizeDownsize,
+			wantGraviton: "m6g.xlarge",
+			wantSavings:  (0.384 - 0.192) * hoursPerMonth,
+		},
+		{
+			name:     "unpriced region",
+			instance: Instance{InstanceType: "m5.2xlarge", Region: "mars-1", CPUAvg7d: 10, MemAvg7d: 20},
+			wantOK:   true,
+			wantType: "m5.xlarge",
+			wantKind: RightsizeDownsize,
+		},
+		{
+			name:     "Graviton in an unpriced region",
+			instance: Instance{InstanceType: "m5.large", Region: "mars-1"},
+			wantOK:   true,
+			wantType: "m6g.large",
+			wantKind: RightsizeGraviton,
+		},
+		{name: "already Graviton and idle", instance: Instance{InstanceType: "m6g.large", Region: "us-east-1"}},
+		{name: "unknown family", instance: Instance{InstanceType: "x2iedn.xlarge", Region: "us-east-1", CPUAvg7d: 1}},
+		{name: "unknown size", instance: Instance{InstanceType: "m5.metal", Region: "us-east-1", CPUAvg7d: 1}},
+		{name: "no type", instance: Instance{Region: "us-east-1", CPUAvg7d: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SuggestRightsize(tt.instance)
+			if ok != tt.wantOK {
+				t.Fatalf("SuggestRightsize() ok = %v, want %v (got %+v)", ok, tt.wantOK, got)
+			}
+			if !ok {
+				return
+			}
+			if got.SuggestedType != tt.wantType || got.Kind != tt.wantKind {
+				t.Errorf("suggested %s (%s), want %s (%s)", got.SuggestedType, got.Kind, tt.wantType, tt.wantKind)
+			}
+			if got.GravitonType != tt.wantGraviton {
+				t.Errorf("GravitonType = %q, want %q", got.GravitonType, tt.wantGraviton)
+			}
+			if got.GravitonType != "" && got.GravitonMonthlySavings <= got.MonthlySavings {
+				t.Errorf("GravitonMonthlySavings = %.2f, want more than the downsize's %.2f", got.GravitonMonthlySavings, got.MonthlySavings)
+			}
+			if math.Abs(got.MonthlySavings-tt.wantSavings) > 1e-9 {
+				t.Errorf("MonthlySavings = %.4f, want %.4f", got.MonthlySavings, tt.wantSavings)
+			}
+		})
+	}
+}
+
+func TestRightsizeSuggestionString(t *testing.T) {
+	tests := []struct {
+		suggestion RightsizeSuggestion
+		want       string
+	}{
+		{
+			suggestion: RightsizeSuggestion{CurrentType: "t3.large", SuggestedType: "t3.medium", Kind: RightsizeDownsize, MonthlySavings: 29.952},
+			want:       "t3.large → t3.medium, $29.95/mo saved",
+		},
+		{
+			suggestion: RightsizeSuggestion{CurrentType: "m5.large", SuggestedType: "m6g.large", Kind: RightsizeGraviton},
+			want:       "m5.large → m6g.large (Graviton)",
+		},
+		{
+			suggestion: RightsizeSuggestion{CurrentType: "m5.2xlarge", SuggestedType: "m5.xlarge", Kind: RightsizeDownsize, MonthlySavings: 138.24, GravitonType: "m6g.xlarge", GravitonMonthlySavings: 165.6},
+			want:       "m5.2xlarge → m5.xlarge, $138.24/mo saved; m6g.xlarge (Graviton) would save $165.60/mo",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.suggestion.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -3,12 +3,12 @@ package pkg
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strconv"
+	"io"
 	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/alexalbu001/greenops/pkg/prompts"
 )
 
 // S3BucketAnalysis contains the analysis results for an S3 bucket
@@ -24,98 +24,54 @@ type S3BucketAnalysis struct {
 		SavePct    float64 `json:"savePct"`
 	} `json:"costEstimate"`
 	OptimizationScore int `json:"optimizationScore"` // 0-100, higher means more optimization needed
+	// StoragePlan is the storage class plan PlanS3Storage computed; nil when the bucket's
+	// region is not in the pricing table or it holds no objects
+	StoragePlan *StorageOptimizationPlan `json:"storagePlan,omitempty"`
 }
 
 // AnalyzeS3BucketWithBedrock uses Bedrock to generate optimization recommendations
 func AnalyzeS3BucketWithBedrock(
 	ctx context.Context,
-	client *bedrockruntime.Client,
+	client BedrockInvoker,
 	modelID string,
 	bucket S3Bucket,
 	embeddings []float64,
-) (string, error) {
+) (InvokeResult, error) {
 	// Create a prompt with detailed bucket information
 	bucketJSON, err := formatS3BucketForPrompt(bucket)
 	if err != nil {
-		return "", err
+		return InvokeResult{}, err
 	}
 
-	// Construct the prompt with an example to ensure consistent formatting
-	prompt := fmt.Sprintf(`Here is an S3 bucket record. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
-%s
-
-Please analyze this S3 bucket for sustainability and cost optimization. 
-Your analysis must include:
-1) Calculate the monthly CO2 footprint considering different storage classes
-2) Estimate monthly cost based on storage classes, volume, and request patterns
-3) Identify storage class inefficiencies and optimization opportunities
-4) Evaluate lifecycle rule configuration
-5) Analyze access patterns vs storage setup
-6) Calculate potential savings from optimization
-7) Suggest specific actionable optimizations with estimated impacts
-8) Identify any security or data protection concerns
-9) Provide SUSTAINABILITY TIPS for this finding
-
-FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
-
-# S3 Bucket Analysis: [BUCKET_NAME]
-
-## Overview
-[1-2 paragraphs describing the bucket's purpose and general observations]
-
-## Cost & Environmental Impact
-- Estimated Monthly Cost: $X.XX
-- Potential Optimized Cost: $X.XX
-- Monthly Savings Potential: $X.XX (XX.X%)
-- CO2 Footprint: X.XX kg CO2 per month
-
-## Detailed Analysis
-
-### Inefficiencies Identified
-1. [ISSUE 1]: [DESCRIPTION]
-2. [ISSUE 2]: [DESCRIPTION]
-3. [ISSUE 3]: [DESCRIPTION]
-
-### Lifecycle Configuration Recommendations
-[SPECIFIC RECOMMENDATIONS ABOUT LIFECYCLE RULES]
-
-### Access Pattern Optimization
-[RECOMMENDATIONS BASED ON ACCESS PATTERNS]
-
-## Recommendations
-
-1. [CATEGORY 1]:
-   - [ACTION ITEM]
-   - [ACTION ITEM]
-
-2. [CATEGORY 2]:
-   - [ACTION ITEM]
-   - [ESTIMATED IMPACT]
-
-3. Security Considerations:
-   - [SECURITY RECOMMENDATIONS]
-
-## Sustainability Tips
-
-1. [TIP 1]: [DESCRIPTION]
-2. [TIP 2]: [DESCRIPTION]
-3. [TIP 3]: [DESCRIPTION]
-`, bucketJSON)
+	monthlyCost, priced := EstimateS3MonthlyCost(bucket)
+	storagePlan := ""
+	if plan, ok := PlanS3Storage(bucket); ok {
+		storagePlan = storagePlanNote(plan)
+	}
 
-	// Use the general-purpose function to invoke Bedrock
-	analysis, err := InvokeBedrockModel(ctx, client, modelID, prompt)
+	// Render the prompt from its template, with an example to ensure consistent formatting
+	prompt, err := prompts.Render(prompts.S3, prompts.Data{
+		Resource:        bucketJSON,
+		CostInstruction: monthlyCostInstruction(monthlyCost, priced, "Estimate monthly cost based on storage classes, volume, and request patterns") + actualCostNote(bucket.ActualMonthlyCost),
+		Reclaimable:     reclaimableNote(bucket),
+		StoragePlan:     storagePlan,
+	})
 	if err != nil {
-		return "", err
+		return InvokeResult{}, err
 	}
 
-	return analysis, nil
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeS3, prompt)
 }
 
 // AnalyzeS3Bucket generates optimization recommendations for a single bucket
-func AnalyzeS3Bucket(ctx context.Context, bucket S3Bucket, client *bedrockruntime.Client, modelID string) (S3BucketAnalysis, error) {
+func AnalyzeS3Bucket(ctx context.Context, bucket S3Bucket, client BedrockInvoker, modelID string) (S3BucketAnalysis, error) {
 	analysis := S3BucketAnalysis{
 		Bucket: bucket,
 	}
+	if plan, ok := PlanS3Storage(bucket); ok {
+		analysis.StoragePlan = &plan
+	}
 
 	// Get embeddings
 	embeddings, err := EmbedText(ctx, client, "amazon.titan-embed-text-v2:0", bucket.BucketName)
@@ -125,11 +81,11 @@ func AnalyzeS3Bucket(ctx context.Context, bucket S3Bucket, client *bedrockruntim
 	analysis.Embedding = embeddings
 
 	// Get analysis directly from Bedrock
-	analysisText, err := AnalyzeS3BucketWithBedrock(ctx, client, modelID, bucket, embeddings)
+	result, err := AnalyzeS3BucketWithBedrock(ctx, client, modelID, bucket, embeddings)
 	if err != nil {
 		return analysis, err
 	}
-	analysis.Analysis = analysisText
+	analysis.Analysis = result.Text
 
 	// Extract cost and CO2 metrics from the Bedrock response
 	extractMetricsFromAnalysis(&analysis)
@@ -139,46 +95,12 @@ func AnalyzeS3Bucket(ctx context.Context, bucket S3Bucket, client *bedrockruntim
 
 // Helper function to extract metrics from the Bedrock analysis text
 func extractMetricsFromAnalysis(analysis *S3BucketAnalysis) {
-	// Find the Cost & Environmental Impact section
-	text := analysis.Analysis
-	if index := strings.Index(text, "Cost & Environmental Impact"); index != -1 {
-		section := text[index:]
-
-		// Extract CO2 footprint
-		co2Match := regexp.MustCompile(`CO2 Footprint: ([\d\.]+)`).FindStringSubmatch(section)
-		if len(co2Match) > 1 {
-			if val, err := strconv.ParseFloat(co2Match[1], 64); err == nil {
-				analysis.CO2Footprint = val
-			}
-		}
-
-		// Extract current cost
-		costMatch := regexp.MustCompile(`Estimated Monthly Cost: \$([\d\.]+)`).FindStringSubmatch(section)
-		if len(costMatch) > 1 {
-			if val, err := strconv.ParseFloat(costMatch[1], 64); err == nil {
-				analysis.CostEstimate.Current = val
-			}
-		}
-
-		// Extract optimized cost
-		optCostMatch := regexp.MustCompile(`Potential Optimized Cost: \$([\d\.]+)`).FindStringSubmatch(section)
-		if len(optCostMatch) > 1 {
-			if val, err := strconv.ParseFloat(optCostMatch[1], 64); err == nil {
-				analysis.CostEstimate.Optimized = val
-			}
-		}
-
-		// Extract savings
-		savingsMatch := regexp.MustCompile(`Monthly Savings Potential: \$([\d\.]+) \(([\d\.]+)%\)`).FindStringSubmatch(section)
-		if len(savingsMatch) > 2 {
-			if val, err := strconv.ParseFloat(savingsMatch[1], 64); err == nil {
-				analysis.CostEstimate.SaveAmount = val
-			}
-			if val, err := strconv.ParseFloat(savingsMatch[2], 64); err == nil {
-				analysis.CostEstimate.SavePct = val
-			}
-		}
-	}
+	metrics := ExtractAnalysisMetrics(analysis.Analysis)
+	analysis.CO2Footprint = metrics.CO2KgMonthly
+	analysis.CostEstimate.Current = metrics.MonthlyCost
+	analysis.CostEstimate.Optimized = metrics.OptimizedCost
+	analysis.CostEstimate.SaveAmount = metrics.MonthlySavings
+	analysis.CostEstimate.SavePct = metrics.SavingsPct
 }
 
 // formatS3BucketForPrompt converts a bucket to a human-readable format for the LLM prompt
@@ -195,6 +117,11 @@ func formatS3BucketForPrompt(bucket S3Bucket) (string, error) {
 
 	sb.WriteString(fmt.Sprintf("Size: %.2f GB\n", float64(bucket.SizeBytes)/(1024*1024*1024)))
 	sb.WriteString(fmt.Sprintf("Object Count: %d\n", bucket.ObjectCount))
+	if bucket.MetricsSource == S3MetricsSourceSampled {
+		sb.WriteString("Size Source: ESTIMATED from a sample of at most 5000 objects; the real size and object count may be much larger\n")
+	} else if bucket.MetricsSource == S3MetricsSourceCloudWatch {
+		sb.WriteString("Size Source: CloudWatch storage metrics (exact, updated daily)\n")
+	}
 
 	// Storage class distribution
 	sb.WriteString("\nStorage Class Distribution:\n")
@@ -207,7 +134,7 @@ func formatS3BucketForPrompt(bucket S3Bucket) (string, error) {
 	}
 
 	// Access frequency
-	sb.WriteString("\nAccess Patterns (average per day):\n")
+	sb.WriteString(fmt.Sprintf("\nAccess Patterns (average per day over the last %d days):\n", EffectivePeriodDays(bucket.MetricsPeriodDays)))
 	for op, count := range bucket.AccessFrequency {
 		sb.WriteString(fmt.Sprintf("- %s: %.1f\n", op, count))
 	}
@@ -234,10 +161,29 @@ func formatS3BucketForPrompt(bucket S3Bucket) (string, error) {
 			if rule.HasExpirations {
 				sb.WriteString(fmt.Sprintf(", Expires objects at %d days", rule.ObjectAgeThreshold))
 			}
+			if rule.AbortIncompleteUploadDays > 0 {
+				sb.WriteString(fmt.Sprintf(", Aborts incomplete multipart uploads at %d days", rule.AbortIncompleteUploadDays))
+			}
+			if rule.NoncurrentExpirationDays > 0 {
+				sb.WriteString(fmt.Sprintf(", Expires noncurrent versions at %d days", rule.NoncurrentExpirationDays))
+			}
 			sb.WriteString("\n")
 		}
 	}
 
+	// Security posture
+	if bucket.HasPosture() {
+		sb.WriteString("\nSecurity Posture:\n")
+		sb.WriteString(fmt.Sprintf("- Public Access: %s\n", postureValue(bucket.PublicAccess)))
+		sb.WriteString(fmt.Sprintf("- Default Encryption: %s\n", postureValue(bucket.EncryptionType)))
+		sb.WriteString(fmt.Sprintf("- Versioning: %s\n", bucket.Versioning()))
+	}
+
+	// Reclaimable storage
+	if reclaimable := describeReclaimable(bucket); reclaimable != "" {
+		sb.WriteString(fmt.Sprintf("\nReclaimable Storage: %s\n", reclaimable))
+	}
+
 	// Tags
 	sb.WriteString("\nTags:\n")
 	if len(bucket.Tags) == 0 {
@@ -250,3 +196,190 @@ func formatS3BucketForPrompt(bucket S3Bucket) (string, error) {
 
 	return sb.String(), nil
 }
+
+// postureValue returns a posture field, or S3PostureUnknown when it was not recorded
+func postureValue(value string) string {
+	if value == "" {
+		return S3PostureUnknown
+	}
+	return value
+}
+
+// Recommendations of a StorageOptimizationPlan
+const (
+	StoragePlanKeep               = "keep"                // the storage classes are already the cheapest option
+	StoragePlanLifecycle          = "lifecycle"           // lifecycle rules moving cold data to TransitionClass
+	StoragePlanIntelligentTiering = "intelligent-tiering" // Intelligent-Tiering for the Standard data
+)
+
+// Storage class rules the calculator applies. Infrequent Access and Glacier Instant Retrieval
+// bill objects under 128 KB as 128 KB, and Intelligent-Tiering neither monitors nor moves
+// them, so buckets of small objects gain nothing from either. Deep Archive stores 40 KB of
+// index per object, 8 KB of it at the Standard rate.
+const (
+	s3MinTieringObjectBytes     = 128 * 1024
+	s3MonitoringPer1000Objects  = 0.0025 // Intelligent-Tiering monitoring and automation fee per month
+	s3DeepArchiveMinAgeDays     = 180    // the minimum storage duration of Deep Archive
+	s3DeepArchiveOverheadBytes  = 32 * 1024
+	s3DeepArchiveStandardBytes  = 8 * 1024
+	s3GlacierIRReadsPerObject   = 0.1 // monthly reads per object below which cold data goes to Glacier Instant Retrieval
+	s3MinStoragePlanSavings     = 0.01
+	s3StoragePlanGiB            = 1024 * 1024 * 1024
+	s3IntelligentTieringIAClass = "STANDARD_IA" // priced like the Infrequent Access tier of Intelligent-Tiering
+	s3IntelligentTieringAIClass = "GLACIER_IR"  // priced like its Archive Instant Access tier
+)
+
+// s3RetrievalPerGB is the retrieval charge per GB read from a transition class
+var s3RetrievalPerGB = map[string]float64{
+	"STANDARD_IA":  0.01,
+	"GLACIER_IR":   0.03,
+	"DEEP_ARCHIVE": 0.02,
+}
+
+// StorageOptimizationPlan is what an S3 bucket's storage would cost per month in its current
+// storage classes, with lifecycle rules moving its cold Standard data to a colder class, and
+// with its Standard data in Intelligent-Tiering. Costs are monthly storage from the pricing
+// table, plus retrieval and monitoring charges; cold data is the share of Standard objects
+// not read in a month, assuming every GET reads a different object. Lifecycle rules move
+// data by age rather than by access, so reads are assumed to land on the moved data in the
+// same share and pay its retrieval charge; Intelligent-Tiering has none.
+// - TransitionClass: where lifecycle rules move cold data, "" when the objects are too small to move
+type StorageOptimizationPlan struct {
+	ColdFraction                  float64 `json:"cold_fraction" dynamodbav:"cold_fraction"`
+	CurrentMonthlyCost            float64 `json:"current_monthly_cost" dynamodbav:"current_monthly_cost"`
+	TransitionClass               string  `json:"transition_class,omitempty" dynamodbav:"transition_class,omitempty"`
+	TransitionMonthlyCost         float64 `json:"transition_monthly_cost" dynamodbav:"transition_monthly_cost"`
+	RetrievalMonthlyCost          float64 `json:"retrieval_monthly_cost,omitempty" dynamodbav:"retrieval_monthly_cost,omitempty"`
+	IntelligentTieringMonthlyCost float64 `json:"intelligent_tiering_monthly_cost" dynamodbav:"intelligent_tiering_monthly_cost"`
+	MonitoringMonthlyCost         float64 `json:"monitoring_monthly_cost" dynamodbav:"monitoring_monthly_cost"`
+	Recommendation                string  `json:"recommendation" dynamodbav:"recommendation"`
+	MonthlySavings                float64 `json:"monthly_savings,omitempty" dynamodbav:"monthly_savings,omitempty"`
+}
+
+// PlanS3Storage computes the StorageOptimizationPlan of a bucket. Cold data moves to Standard-IA,
+// to Glacier Instant Retrieval when the bucket averages under one read per ten objects a month,
+// or to Deep Archive when nothing was read over the metrics window and the bucket is old enough
+// for its minimum storage duration. ok is false when the region is not in the pricing table
+// or the bucket holds no objects.
+func PlanS3Storage(bucket S3Bucket) (StorageOptimizationPlan, bool) {
+	current, ok := EstimateS3MonthlyCost(bucket)
+	if !ok || bucket.ObjectCount <= 0 || bucket.SizeBytes <= 0 {
+		return StorageOptimizationPlan{}, false
+	}
+	price := func(class string) float64 {
+		p, _ := LookupS3Price(class, bucket.Region)
+		return p
+	}
+
+	// Standard data is what can move; other classes have been chosen already
+	classes := bucket.StorageClasses
+	if len(classes) == 0 {
+		classes = map[string]int64{"STANDARD": bucket.SizeBytes}
+	}
+	standardBytes := float64(classes["STANDARD"] + classes["REDUCED_REDUNDANCY"])
+	standardCost := float64(classes["STANDARD"])/s3StoragePlanGiB*price("STANDARD") +
+		float64(classes["REDUCED_REDUNDANCY"])/s3StoragePlanGiB*price("REDUCED_REDUNDANCY")
+	standardObjects := float64(bucket.ObjectCount) * min(standardBytes/float64(bucket.SizeBytes), 1)
+
+	monthlyReads := bucket.AccessFrequency["GetRequests"] * 30
+	readsPerObject := monthlyReads / float64(bucket.ObjectCount)
+	plan := StorageOptimizationPlan{
+		ColdFraction:                  1 - min(readsPerObject, 1),
+		CurrentMonthlyCost:            current,
+		TransitionMonthlyCost:         current,
+		IntelligentTieringMonthlyCost: current,
+		Recommendation:                StoragePlanKeep,
+	}
+	if standardBytes == 0 || bucket.SizeBytes/bucket.ObjectCount < s3MinTieringObjectBytes {
+		return plan, true
+	}
+	coldBytes := standardBytes * plan.ColdFraction
+	coldObjects := standardObjects * plan.ColdFraction
+	otherCost := current - standardCost
+	standardRate := standardCost / standardBytes // per byte, blending Standard and RRS
+
+	// Lifecycle transitions of the cold data
+	ageDays := time.Since(bucket.CreationDate).Hours() / 24
+	switch {
+	case readsPerObject == 0 && !bucket.CreationDate.IsZero() && ageDays >= s3DeepArchiveMinAgeDays:
+		plan.TransitionClass = "DEEP_ARCHIVE"
+	case readsPerObject < s3GlacierIRReadsPerObject:
+		plan.TransitionClass = "GLACIER_IR"
+	default:
+		plan.TransitionClass = "STANDARD_IA"
+	}
+	plan.TransitionMonthlyCost = otherCost + (standardBytes-coldBytes)*standardRate +
+		coldBytes/s3StoragePlanGiB*price(plan.TransitionClass)
+	averageObjectBytes := float64(bucket.SizeBytes) / float64(bucket.ObjectCount)
+	plan.RetrievalMonthlyCost = monthlyReads * plan.ColdFraction * averageObjectBytes / s3StoragePlanGiB * s3RetrievalPerGB[plan.TransitionClass]
+	plan.TransitionMonthlyCost += plan.RetrievalMonthlyCost
+	if plan.TransitionClass == "DEEP_ARCHIVE" {
+		plan.TransitionMonthlyCost += coldObjects * (s3DeepArchiveOverheadBytes*price("DEEP_ARCHIVE") +
+			s3DeepArchiveStandardBytes*price("STANDARD")) / s3StoragePlanGiB
+	}
+
+	// Intelligent-Tiering moves objects unread for 30 days to its Infrequent Access tier, and
+	// for 90 days to Archive Instant Access; unread over the whole window stands in for the latter
+	coldTier := s3IntelligentTieringIAClass
+	if readsPerObject == 0 {
+		coldTier = s3IntelligentTieringAIClass
+	}
+	plan.MonitoringMonthlyCost = standardObjects / 1000 * s3MonitoringPer1000Objects
+	plan.IntelligentTieringMonthlyCost = otherCost + (standardBytes-coldBytes)/s3StoragePlanGiB*price("INTELLIGENT_TIERING") +
+		coldBytes/s3StoragePlanGiB*price(coldTier) + plan.MonitoringMonthlyCost
+
+	// Recommend the cheapest option that saves at least a cent
+	best := current - s3MinStoragePlanSavings
+	if plan.TransitionMonthlyCost < best {
+		plan.Recommendation, best = StoragePlanLifecycle, plan.TransitionMonthlyCost
+	}
+	if plan.IntelligentTieringMonthlyCost < best {
+		plan.Recommendation, best = StoragePlanIntelligentTiering, plan.IntelligentTieringMonthlyCost
+	}
+	if plan.Recommendation != StoragePlanKeep {
+		plan.MonthlySavings = current - best
+	}
+	return plan, true
+}
+
+// String describes the recommendation of the plan in one line, e.g. "lifecycle rules moving
+// cold data to GLACIER_IR, $12.40/mo saved"
+func (p StorageOptimizationPlan) String() string {
+	switch p.Recommendation {
+	case StoragePlanLifecycle:
+		return fmt.Sprintf("lifecycle rules moving cold data to %s, $%.2f/mo saved", p.TransitionClass, p.MonthlySavings)
+	case StoragePlanIntelligentTiering:
+		return fmt.Sprintf("Intelligent-Tiering for the Standard data, $%.2f/mo saved", p.MonthlySavings)
+	default:
+		return "keep the current storage classes"
+	}
+}
+
+// storagePlanNote gives the plan to the analysis prompt, one option per line
+func storagePlanNote(plan StorageOptimizationPlan) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "- Cold share of Standard data (not read in a month): %.0f%%\n", plan.ColdFraction*100)
+	fmt.Fprintf(&sb, "- Current storage classes: $%.2f per month\n", plan.CurrentMonthlyCost)
+	if plan.TransitionClass != "" {
+		fmt.Fprintf(&sb, "- Lifecycle rules moving cold data to %s: $%.2f per month, including $%.2f of retrieval charges\n",
+			plan.TransitionClass, plan.TransitionMonthlyCost, plan.RetrievalMonthlyCost)
+		fmt.Fprintf(&sb, "- Intelligent-Tiering for the Standard data: $%.2f per month, including $%.2f of monitoring fees\n",
+			plan.IntelligentTieringMonthlyCost, plan.MonitoringMonthlyCost)
+	}
+	fmt.Fprintf(&sb, "- Recommendation: %s\n", plan)
+	return sb.String()
+}
+
+// printStoragePlan prints the storage class options of a bucket as a small table
+func printStoragePlan(w io.Writer, plan StorageOptimizationPlan, labelColor, bold, reset string) {
+	fmt.Fprintf(w, "\n%sStorage Class Plan:%s\n", bold+labelColor, reset)
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "  OPTION\tCOST ($/mo)")
+	fmt.Fprintf(tw, "  Current\t%.2f\n", plan.CurrentMonthlyCost)
+	if plan.TransitionClass != "" {
+		fmt.Fprintf(tw, "  Lifecycle to %s\t%.2f (%.2f retrieval)\n", plan.TransitionClass, plan.TransitionMonthlyCost, plan.RetrievalMonthlyCost)
+		fmt.Fprintf(tw, "  Intelligent-Tiering\t%.2f (%.2f monitoring)\n", plan.IntelligentTieringMonthlyCost, plan.MonitoringMonthlyCost)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "  %sRecommended:%s %s\n", labelColor, reset, plan)
+}
@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storagePlanBucket is a us-east-1 bucket of 1000 GiB in 100,000 objects of 10 MiB, created
+// ageDays ago and reading objects at readsPerObject a month
+func storagePlanBucket(ageDays int, readsPerObject float64) S3Bucket {
+	return S3Bucket{
+		BucketName:      "plan-test",
+		Region:          "us-east-1",
+		CreationDate:    time.Now().AddDate(0, 0, -ageDays),
+		SizeBytes:       1000 * s3StoragePlanGiB,
+		ObjectCount:     100000,
+		AccessFrequency: map[string]float64{"GetRequests": readsPerObject * 100000 / 30},
+	}
+}
+
+func TestPlanS3Storage(t *testing.T) {
+	// 100,000 objects under Intelligent-Tiering monitoring
+	const monitoring = 0.25
+	deepArchiveOverhead := 100000 * (s3DeepArchiveOverheadBytes*0.00099 + s3DeepArchiveStandardBytes*0.023) / s3StoragePlanGiB
+
+	glacierIR := storagePlanBucket(400, 0)
+	glacierIR.StorageClasses = map[string]int64{"GLACIER_IR": glacierIR.SizeBytes}
+	smallObjects := storagePlanBucket(400, 0)
+	smallObjects.SizeBytes, smallObjects.ObjectCount = 10*s3StoragePlanGiB, 1000000
+
+	tests := []struct {
+		name           string
+		bucket         S3Bucket
+		wantRec        string
+		wantClass      string
+		wantCurrent    float64
+		wantTransition float64
+		wantTiering    float64
+		wantSavings    float64
+	}{
+		{
+			// Reading every object each month leaves nothing cold, and monitoring costs extra
+			name:           "hot Standard data is already optimal",
+			bucket:         storagePlanBucket(400, 2),
+			wantRec:        StoragePlanKeep,
+			wantClass:      "STANDARD_IA",
+			wantCurrent:    23,
+			wantTransition: 23,
+			wantTiering:    23 + monitoring,
+		},
+		{
+			name:           "data already in a cold class",
+			bucket:         glacierIR,
+			wantRec:        StoragePlanKeep,
+			wantCurrent:    4,
+			wantTransition: 4,
+			wantTiering:    4,
+		},
+		{
+			// 10,737 byte objects are billed as 128 KB in the cold classes and not tiered
+			name:           "objects too small for Intelligent-Tiering",
+			bucket:         smallObjects,
+			wantRec:        StoragePlanKeep,
+			wantCurrent:    0.23,
+			wantTransition: 0.23,
+			wantTiering:    0.23,
+		},
+		{
+			// Half the data is read: lifecycle rules pay $2.50 retrieving what they moved
+			name:           "half read",
+			bucket:         storagePlanBucket(400, 0.5),
+			wantRec:        StoragePlanIntelligentTiering,
+			wantClass:      "STANDARD_IA",
+			wantCurrent:    23,
+			wantTransition: 500*0.023 + 500*0.0125 + 2.5,
+			wantTiering:    500*0.023 + 500*0.0125 + monitoring,
+			wantSavings:    23 - (500*0.023 + 500*0.0125 + monitoring),
+		},
+		{
+			name:           "rarely read",
+			bucket:         storagePlanBucket(400, 0.05),
+			wantRec:        StoragePlanLifecycle,
+			wantClass:      "GLACIER_IR",
+			wantCurrent:    23,
+			wantTransition: 50*0.023 + 950*0.004 + 5000*0.95*0.01*0.03,
+			wantTiering:    50*0.023 + 950*0.0125 + monitoring,
+			wantSavings:    23 - (50*0.023 + 950*0.004 + 5000*0.95*0.01*0.03),
+		},
+		{
+			name:           "never read, too young for Deep Archive",
+			bucket:         storagePlanBucket(30, 0),
+			wantRec:        StoragePlanLifecycle,
+			wantClass:      "GLACIER_IR",
+			wantCurrent:    23,
+			wantTransition: 4,
+			wantTiering:    4 + monitoring,
+			wantSavings:    19,
+		},
+		{
+			name:           "never read and old",
+			bucket:         storagePlanBucket(400, 0),
+			wantRec:        StoragePlanLifecycle,
+			wantClass:      "DEEP_ARCHIVE",
+			wantCurrent:    23,
+			wantTransition: 0.99 + deepArchiveOverhead,
+			wantTiering:    4 + monitoring,
+			wantSavings:    23 - 0.99 - deepArchiveOverhead,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, ok := PlanS3Storage(tt.bucket)
+			if !ok {
+				t.Fatal("PlanS3Storage() ok = false")
+			}
+			if plan.Recommendation != tt.wantRec || plan.TransitionClass != tt.wantClass {
+				t.Errorf("recommendation = %s to %q, want %s to %q", plan.Recommendation, plan.TransitionClass, tt.wantRec, tt.wantClass)
+			}
+			costs := []struct {
+				name      string
+				got, want float64
+			}{
+				{"CurrentMonthlyCost", plan.CurrentMonthlyCost, tt.wantCurrent},
+				{"TransitionMonthlyCost", plan.TransitionMonthlyCost, tt.wantTransition},
+				{"IntelligentTieringMonthlyCost", plan.IntelligentTieringMonthlyCost, tt.wantTiering},
+				{"MonthlySavings", plan.MonthlySavings, tt.wantSavings},
+			}
+			for _, cost := range costs {
+				if math.Abs(cost.got-cost.want) > 1e-6 {
+					t.Errorf("%s = %.4f, want %.4f", cost.name, cost.got, cost.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanS3StorageNotPlanned(t *testing.T) {
+	unpriced := storagePlanBucket(400, 0)
+	unpriced.Region = "mars-1"
+	empty := storagePlanBucket(400, 0)
+	empty.SizeBytes, empty.ObjectCount = 0, 0
+
+	for name, bucket := range map[string]S3Bucket{"unpriced region": unpriced, "empty bucket": empty} {
+		if plan, ok := PlanS3Storage(bucket); ok {
+			t.Errorf("%s: PlanS3Storage() = %+v, want no plan", name, plan)
+		}
+	}
+}
+
+// Plans that keep the storage classes because nothing can move leave the options out
+func TestStoragePlanNote(t *testing.T) {
+	small := storagePlanBucket(400, 0)
+	small.SizeBytes, small.ObjectCount = 10*s3StoragePlanGiB, 1000000
+	plan, _ := PlanS3Storage(small)
+	note := storagePlanNote(plan)
+	if strings.Contains(note, "Lifecycle") || strings.Contains(note, "Intelligent-Tiering") {
+		t.Errorf("storagePlanNote() offers options for objects too small to move:\n%s", note)
+	}
+	if !strings.Contains(note, "- Recommendation: keep the current storage classes\n") {
+		t.Errorf("storagePlanNote() is missing the recommendation:\n%s", note)
+	}
+
+	plan, _ = PlanS3Storage(storagePlanBucket(30, 0))
+	if got, want := plan.String(), "lifecycle rules moving cold data to GLACIER_IR, $19.00/mo saved"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
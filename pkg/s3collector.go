@@ -2,7 +2,9 @@ package pkg
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,20 +13,98 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 // S3Bucket holds metadata and computed metrics for an S3 bucket
 type S3Bucket struct {
-	BucketName      string              `json:"bucketName"`
-	CreationDate    time.Time           `json:"creationDate"`
-	Region          string              `json:"region"`
-	SizeBytes       int64               `json:"sizeBytes"`
-	ObjectCount     int64               `json:"objectCount"`
-	StorageClasses  map[string]int64    `json:"storageClasses"`  // Map of storage class to bytes
-	AccessFrequency map[string]float64  `json:"accessFrequency"` // GET/PUT/DELETE ops per day
-	LifecycleRules  []LifecycleRuleInfo `json:"lifecycleRules"`
-	Tags            map[string]string   `json:"tags"`
-	LastModified    time.Time           `json:"lastModified"`
+	BucketName        string              `json:"bucketName"`
+	CreationDate      time.Time           `json:"creationDate"`
+	Region            string              `json:"region"`
+	AccountID         string              `json:"accountId,omitempty"` // set when scanning an organization with --org
+	SizeBytes         int64               `json:"sizeBytes"`
+	ObjectCount       int64               `json:"objectCount"`
+	StorageClasses    map[string]int64    `json:"storageClasses"`  // Map of storage class to bytes
+	AccessFrequency   map[string]float64  `json:"accessFrequency"` // GET/PUT/DELETE ops per day
+	LifecycleRules    []LifecycleRuleInfo `json:"lifecycleRules"`
+	Tags              map[string]string   `json:"tags"`
+	LastModified      time.Time           `json:"lastModified"`
+	MetricsPeriodDays int                 `json:"metricsPeriodDays"`           // CloudWatch lookback window
+	MetricsSource     string              `json:"metricsSource"`               // "cloudwatch" or "sampled" (estimated from a partial object listing)
+	ActualMonthlyCost float64             `json:"actualMonthlyCost,omitempty"` // last 30 days of spend from Cost Explorer
+	// Security posture; S3PostureUnknown when the calls reading it were denied or failed
+	PublicAccess      string `json:"publicAccess,omitempty"`      // S3PublicAccessPublic, S3PublicAccessBlocked or S3PublicAccessPrivate
+	EncryptionType    string `json:"encryptionType,omitempty"`    // default encryption algorithm, e.g. AES256 or aws:kms, or S3EncryptionNone
+	VersioningEnabled *bool  `json:"versioningEnabled,omitempty"` // nil when unknown
+	// Storage that can be deleted outright; ReclaimableEstimated is set when a sample limit of
+	// a large bucket was reached and the figures were extrapolated
+	IncompleteUploadCount      int64   `json:"incompleteUploadCount,omitempty"`
+	IncompleteUploadBytes      int64   `json:"incompleteUploadBytes,omitempty"`
+	IncompleteUploadOldestDays int     `json:"incompleteUploadOldestDays,omitempty"`
+	IncompleteUploadAvgAgeDays float64 `json:"incompleteUploadAvgAgeDays,omitempty"`
+	NoncurrentVersionCount     int64   `json:"noncurrentVersionCount,omitempty"`
+	NoncurrentVersionBytes     int64   `json:"noncurrentVersionBytes,omitempty"`
+	ReclaimableEstimated       bool    `json:"reclaimableEstimated,omitempty"`
+}
+
+// Values of S3Bucket.PublicAccess and EncryptionType
+const (
+	S3PublicAccessPublic  = "public"  // the bucket policy grants public access
+	S3PublicAccessBlocked = "blocked" // every Block Public Access setting is on
+	S3PublicAccessPrivate = "private" // no public policy, but public access is not fully blocked
+	S3EncryptionNone      = "none"    // no default encryption configured
+	S3PostureUnknown      = "unknown"
+)
+
+// HasPosture reports whether the scan recorded the bucket's security posture; scans by older
+// clients did not
+func (b S3Bucket) HasPosture() bool {
+	return b.PublicAccess != "" || b.EncryptionType != "" || b.VersioningEnabled != nil
+}
+
+// Versioning describes VersioningEnabled as "enabled", "disabled" or S3PostureUnknown
+func (b S3Bucket) Versioning() string {
+	switch {
+	case b.VersioningEnabled == nil:
+		return S3PostureUnknown
+	case *b.VersioningEnabled:
+		return "enabled"
+	default:
+		return "disabled"
+	}
+}
+
+// Sources for S3Bucket.MetricsSource
+const (
+	S3MetricsSourceCloudWatch = "cloudwatch"
+	S3MetricsSourceSampled    = "sampled"
+)
+
+// s3StorageTypeClasses maps the StorageType dimension of BucketSizeBytes to the
+// storage class names used by object listings. Overhead types are counted
+// towards the class they belong to.
+var s3StorageTypeClasses = map[string]string{
+	"StandardStorage":                "STANDARD",
+	"StandardIAStorage":              "STANDARD_IA",
+	"StandardIASizeOverhead":         "STANDARD_IA",
+	"OneZoneIAStorage":               "ONEZONE_IA",
+	"OneZoneIASizeOverhead":          "ONEZONE_IA",
+	"ReducedRedundancyStorage":       "REDUCED_REDUNDANCY",
+	"IntelligentTieringFAStorage":    "INTELLIGENT_TIERING",
+	"IntelligentTieringIAStorage":    "INTELLIGENT_TIERING",
+	"IntelligentTieringAIAStorage":   "INTELLIGENT_TIERING",
+	"IntelligentTieringAAStorage":    "INTELLIGENT_TIERING",
+	"IntelligentTieringDAAStorage":   "INTELLIGENT_TIERING",
+	"GlacierInstantRetrievalStorage": "GLACIER_IR",
+	"GlacierStorage":                 "GLACIER",
+	"GlacierStagingStorage":          "GLACIER",
+	"GlacierObjectOverhead":          "GLACIER",
+	"GlacierS3ObjectOverhead":        "GLACIER",
+	"DeepArchiveStorage":             "DEEP_ARCHIVE",
+	"DeepArchiveStagingStorage":      "DEEP_ARCHIVE",
+	"DeepArchiveObjectOverhead":      "DEEP_ARCHIVE",
+	"DeepArchiveS3ObjectOverhead":    "DEEP_ARCHIVE",
+	"ExpressOneZone":                 "EXPRESS_ONEZONE",
 }
 
 // LifecycleRuleInfo contains simplified lifecycle rule information
@@ -34,15 +114,25 @@ type LifecycleRuleInfo struct {
 	HasTransitions     bool   `json:"hasTransitions"`
 	HasExpirations     bool   `json:"hasExpirations"`
 	ObjectAgeThreshold int    `json:"objectAgeThreshold"` // Days until first transition/expiration
+	// Days after which the rule aborts incomplete multipart uploads and expires noncurrent
+	// versions; 0 when it does not
+	AbortIncompleteUploadDays int `json:"abortIncompleteUploadDays,omitempty"`
+	NoncurrentExpirationDays  int `json:"noncurrentExpirationDays,omitempty"`
 }
 
 // ListBuckets retrieves all S3 buckets and their key metrics
 func ListBuckets(
 	ctx context.Context,
-	s3Client *s3.Client,
-	cwClient *cloudwatch.Client,
+	s3Client S3BucketAPI,
+	cwClient CloudWatchMetricsAPI,
 	maxBuckets int,
+	daysBack int,
 ) ([]S3Bucket, error) {
+	// A cancelled scan makes no further AWS calls
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Get list of buckets
 	bucketList, err := s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
@@ -55,7 +145,7 @@ func ListBuckets(
 		buckets = buckets[:maxBuckets]
 	}
 
-	log.Printf("Processing %d S3 buckets (out of %d total)", len(buckets), len(bucketList.Buckets))
+	Infof("Processing %d S3 buckets (out of %d total)", len(buckets), len(bucketList.Buckets))
 
 	// Process buckets in parallel with a worker pool
 	results := make([]S3Bucket, 0, len(buckets))
@@ -64,23 +154,36 @@ func ListBuckets(
 	semaphore := make(chan struct{}, 5) // Limit to 5 concurrent requests
 
 	for _, bucket := range buckets {
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
 
 		go func(b s3Types.Bucket) {
 			defer wg.Done()
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			// Acquire semaphore, giving up once the scan is cancelled
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+			// select picks at random when both cases are ready, so check again
+			if ctx.Err() != nil {
+				return
+			}
 
 			// Set a timeout for processing each bucket
 			bucketCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 			defer cancel()
 
 			// Collect bucket data
-			bucketData, err := collectBucketData(bucketCtx, s3Client, cwClient, *b.Name, b.CreationDate)
+			bucketData, err := collectBucketData(bucketCtx, s3Client, cwClient, *b.Name, b.CreationDate, daysBack)
 			if err != nil {
-				log.Printf("Warning: Error collecting data for bucket %s: %v", *b.Name, err)
+				if ctx.Err() == nil {
+					Warnf("Error collecting data for bucket %s: %v", *b.Name, err)
+				}
 				return
 			}
 
@@ -93,17 +196,23 @@ func ListBuckets(
 
 	// Wait for all goroutines to complete
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	return results, nil
 }
 
-// collectBucketData gathers all relevant data for a single bucket
-func collectBucketData(ctx context.Context, s3Client *s3.Client, cwClient *cloudwatch.Client, bucketName string, creationDate *time.Time) (S3Bucket, error) {
+// collectBucketData gathers all relevant data for a single bucket. It stops between API
+// calls once ctx is done and returns ctx.Err(), rather than a bucket built from failed calls.
+func collectBucketData(ctx context.Context, s3Client S3BucketAPI, cwClient CloudWatchMetricsAPI, bucketName string, creationDate *time.Time, daysBack int) (S3Bucket, error) {
+	daysBack = EffectivePeriodDays(daysBack)
 	bucket := S3Bucket{
-		BucketName:      bucketName,
-		StorageClasses:  make(map[string]int64),
-		AccessFrequency: make(map[string]float64),
-		Tags:            make(map[string]string),
+		BucketName:        bucketName,
+		MetricsPeriodDays: daysBack,
+		StorageClasses:    make(map[string]int64),
+		AccessFrequency:   make(map[string]float64),
+		Tags:              make(map[string]string),
 	}
 
 	if creationDate != nil {
@@ -111,14 +220,18 @@ func collectBucketData(ctx context.Context, s3Client *s3.Client, cwClient *cloud
 	}
 
 	// Get bucket region first
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
 	region, err := getBucketRegion(ctx, s3Client, bucketName)
 	if err != nil {
-		log.Printf("Warning: Unable to determine region for bucket %s: %v", bucketName, err)
+		Warnf("Unable to determine region for bucket %s: %v", bucketName, err)
 	}
 	bucket.Region = region
 
-	// Create a region-specific client for this bucket
-	var bucketClient *s3.Client
+	// Create region-specific clients for this bucket; S3 metrics are only published in the bucket's region
+	var bucketClient S3BucketAPI
+	bucketCW := cwClient
 	if region != "" && region != s3Client.Options().Region {
 		// Create a new client with the bucket's region
 		cfg := s3Client.Options().Copy()
@@ -128,44 +241,110 @@ func collectBucketData(ctx context.Context, s3Client *s3.Client, cwClient *cloud
 			Credentials: cfg.Credentials,
 			HTTPClient:  cfg.HTTPClient,
 		})
-		log.Printf("Created region-specific S3 client for bucket %s (region: %s)", bucketName, region)
+		bucketCW = cloudwatch.NewFromConfig(aws.Config{
+			Region:      region,
+			Credentials: cfg.Credentials,
+			HTTPClient:  cfg.HTTPClient,
+		})
+		Debugf("Created region-specific S3 client for bucket %s (region: %s)", bucketName, region)
 	} else {
 		bucketClient = s3Client
 	}
 
 	// Use bucketClient instead of s3Client for all subsequent operations
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
 	tags, err := getBucketTags(ctx, bucketClient, bucketName)
 	if err != nil {
-		log.Printf("Warning: Unable to get tags for bucket %s: %v", bucketName, err)
+		Warnf("Unable to get tags for bucket %s: %v", bucketName, err)
 	}
 	bucket.Tags = tags
 
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
 	lifecycleRules, err := getBucketLifecycleRules(ctx, bucketClient, bucketName)
 	if err != nil {
-		log.Printf("Warning: Unable to get lifecycle rules for bucket %s: %v", bucketName, err)
+		Warnf("Unable to get lifecycle rules for bucket %s: %v", bucketName, err)
 	}
 	bucket.LifecycleRules = lifecycleRules
 
-	size, objectCount, storageClasses, lastModified, err := getBucketStorageMetrics(ctx, bucketClient, bucketName)
+	// Security posture; a denied call leaves its part unknown rather than failing the bucket
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
+	bucket.PublicAccess, err = getBucketPublicAccess(ctx, bucketClient, bucketName)
+	if err != nil {
+		Warnf("Unable to get public access settings for bucket %s: %v", bucketName, err)
+	}
+	bucket.EncryptionType, err = getBucketEncryptionType(ctx, bucketClient, bucketName)
+	if err != nil {
+		Warnf("Unable to get default encryption for bucket %s: %v", bucketName, err)
+	}
+	bucket.VersioningEnabled, err = getBucketVersioning(ctx, bucketClient, bucketName)
 	if err != nil {
-		log.Printf("Warning: Unable to get storage metrics for bucket %s: %v", bucketName, err)
+		Warnf("Unable to get versioning status for bucket %s: %v", bucketName, err)
 	}
-	bucket.SizeBytes = size
-	bucket.ObjectCount = objectCount
-	bucket.StorageClasses = storageClasses
-	bucket.LastModified = lastModified
 
-	accessMetrics, err := getBucketAccessMetrics(ctx, cwClient, bucketName)
+	// Prefer the daily CloudWatch storage metrics; they are exact but absent for new buckets
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
+	size, objectCount, storageClasses, found, err := getBucketCloudWatchStorage(ctx, bucketCW, bucketName)
 	if err != nil {
-		log.Printf("Warning: Unable to get access metrics for bucket %s: %v", bucketName, err)
+		Warnf("Unable to get CloudWatch storage metrics for bucket %s: %v", bucketName, err)
+	}
+	if found {
+		bucket.SizeBytes = size
+		bucket.ObjectCount = objectCount
+		bucket.StorageClasses = storageClasses
+		bucket.MetricsSource = S3MetricsSourceCloudWatch
+	} else {
+		if err := ctx.Err(); err != nil {
+			return bucket, err
+		}
+		size, objectCount, storageClasses, lastModified, err := getBucketStorageMetrics(ctx, bucketClient, bucketName)
+		if err != nil {
+			Warnf("Unable to get storage metrics for bucket %s: %v", bucketName, err)
+		}
+		bucket.SizeBytes = size
+		bucket.ObjectCount = objectCount
+		bucket.StorageClasses = storageClasses
+		bucket.LastModified = lastModified
+		bucket.MetricsSource = S3MetricsSourceSampled
+	}
+
+	// Incomplete uploads and noncurrent versions, sampled after the size so they can be scaled to it
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
+	if err := collectIncompleteUploads(ctx, bucketClient, &bucket); err != nil {
+		Warnf("Unable to list incomplete multipart uploads for bucket %s: %v", bucketName, err)
+	}
+	if aws.ToBool(bucket.VersioningEnabled) {
+		if err := ctx.Err(); err != nil {
+			return bucket, err
+		}
+		if err := collectNoncurrentVersions(ctx, bucketClient, &bucket); err != nil {
+			Warnf("Unable to list object versions for bucket %s: %v", bucketName, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return bucket, err
+	}
+	accessMetrics, err := getBucketAccessMetrics(ctx, bucketCW, bucketName, daysBack)
+	if err != nil {
+		Warnf("Unable to get access metrics for bucket %s: %v", bucketName, err)
 	}
 	bucket.AccessFrequency = accessMetrics
 
-	return bucket, nil
+	return bucket, ctx.Err()
 }
 
 // getBucketRegion determines the region of a bucket
-func getBucketRegion(ctx context.Context, client *s3.Client, bucketName string) (string, error) {
+func getBucketRegion(ctx context.Context, client S3BucketAPI, bucketName string) (string, error) {
 	result, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
 		Bucket: aws.String(bucketName),
 	})
@@ -183,7 +362,7 @@ func getBucketRegion(ctx context.Context, client *s3.Client, bucketName string)
 }
 
 // getBucketTags retrieves tags for a bucket
-func getBucketTags(ctx context.Context, client *s3.Client, bucketName string) (map[string]string, error) {
+func getBucketTags(ctx context.Context, client S3BucketAPI, bucketName string) (map[string]string, error) {
 	result, err := client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{
 		Bucket: aws.String(bucketName),
 	})
@@ -204,7 +383,7 @@ func getBucketTags(ctx context.Context, client *s3.Client, bucketName string) (m
 }
 
 // getBucketLifecycleRules retrieves and simplifies lifecycle rules
-func getBucketLifecycleRules(ctx context.Context, client *s3.Client, bucketName string) ([]LifecycleRuleInfo, error) {
+func getBucketLifecycleRules(ctx context.Context, client S3BucketAPI, bucketName string) ([]LifecycleRuleInfo, error) {
 	result, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
 		Bucket: aws.String(bucketName),
 	})
@@ -254,14 +433,179 @@ func getBucketLifecycleRules(ctx context.Context, client *s3.Client, bucketName
 			}
 		}
 
+		if rule.AbortIncompleteMultipartUpload != nil {
+			ruleInfo.AbortIncompleteUploadDays = int(aws.ToInt32(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+		}
+		if rule.NoncurrentVersionExpiration != nil {
+			ruleInfo.NoncurrentExpirationDays = int(aws.ToInt32(rule.NoncurrentVersionExpiration.NoncurrentDays))
+		}
+
 		rules = append(rules, ruleInfo)
 	}
 
 	return rules, nil
 }
 
+// s3ErrorCode returns the error code of an S3 API error, or "" for other errors
+func s3ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// getBucketPublicAccess classifies the public exposure of a bucket from its Block Public
+// Access settings and the status of its bucket policy
+func getBucketPublicAccess(ctx context.Context, client S3BucketAPI, bucketName string) (string, error) {
+	blockAll, blockErr := false, error(nil)
+	block, err := client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{
+		Bucket: aws.String(bucketName),
+	})
+	switch {
+	case err == nil && block.PublicAccessBlockConfiguration != nil:
+		config := block.PublicAccessBlockConfiguration
+		blockAll = aws.ToBool(config.BlockPublicAcls) && aws.ToBool(config.IgnorePublicAcls) &&
+			aws.ToBool(config.BlockPublicPolicy) && aws.ToBool(config.RestrictPublicBuckets)
+	case err != nil && s3ErrorCode(err) != "NoSuchPublicAccessBlockConfiguration":
+		blockErr = err
+	}
+	if blockAll {
+		return S3PublicAccessBlocked, nil
+	}
+
+	status, err := client.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{
+		Bucket: aws.String(bucketName),
+	})
+	switch {
+	case err == nil && status.PolicyStatus != nil && aws.ToBool(status.PolicyStatus.IsPublic):
+		return S3PublicAccessPublic, nil
+	case err != nil && s3ErrorCode(err) != "NoSuchBucketPolicy":
+		return S3PostureUnknown, err
+	case blockErr != nil:
+		return S3PostureUnknown, blockErr
+	}
+	return S3PublicAccessPrivate, nil
+}
+
+// getBucketEncryptionType returns the algorithm of a bucket's default encryption, or
+// S3EncryptionNone when it has none
+func getBucketEncryptionType(ctx context.Context, client S3BucketAPI, bucketName string) (string, error) {
+	result, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		if s3ErrorCode(err) == "ServerSideEncryptionConfigurationNotFoundError" {
+			return S3EncryptionNone, nil
+		}
+		return S3PostureUnknown, err
+	}
+
+	if config := result.ServerSideEncryptionConfiguration; config != nil {
+		for _, rule := range config.Rules {
+			if rule.ApplyServerSideEncryptionByDefault != nil {
+				return string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm), nil
+			}
+		}
+	}
+	return S3EncryptionNone, nil
+}
+
+// getBucketVersioning reports whether versioning is enabled on a bucket; suspended
+// versioning counts as disabled
+func getBucketVersioning(ctx context.Context, client S3BucketAPI, bucketName string) (*bool, error) {
+	result, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aws.Bool(result.Status == s3Types.BucketVersioningStatusEnabled), nil
+}
+
+// getBucketCloudWatchStorage reads the latest BucketSizeBytes (per storage type) and
+// NumberOfObjects datapoints. found is false when CloudWatch has no size data.
+func getBucketCloudWatchStorage(ctx context.Context, client CloudWatchMetricsAPI, bucketName string) (
+	size int64,
+	objectCount int64,
+	storageClasses map[string]int64,
+	found bool,
+	err error,
+) {
+	storageClasses = make(map[string]int64)
+
+	// Storage metrics are published once a day, so look back a few days for the latest point
+	endTime := time.Now().UTC()
+	startTime := endTime.AddDate(0, 0, -3)
+
+	storageTypes := make([]string, 0, len(s3StorageTypeClasses)+1)
+	for storageType := range s3StorageTypeClasses {
+		storageTypes = append(storageTypes, storageType)
+	}
+	sort.Strings(storageTypes)
+
+	queries := make([]types.MetricDataQuery, 0, len(storageTypes)+1)
+	for i, storageType := range storageTypes {
+		queries = append(queries, s3StorageQuery(fmt.Sprintf("s%d", i), bucketName, "BucketSizeBytes", storageType))
+	}
+	queries = append(queries, s3StorageQuery("objects", bucketName, "NumberOfObjects", "AllStorageTypes"))
+
+	resp, err := client.GetMetricData(ctx, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(startTime),
+		EndTime:           aws.Time(endTime),
+		ScanBy:            types.ScanByTimestampDescending,
+	})
+	if err != nil {
+		return 0, 0, storageClasses, false, err
+	}
+
+	for _, result := range resp.MetricDataResults {
+		// Values are newest first
+		if len(result.Values) == 0 {
+			continue
+		}
+		latest := int64(result.Values[0])
+
+		id := aws.ToString(result.Id)
+		if id == "objects" {
+			objectCount = latest
+			continue
+		}
+
+		var index int
+		if _, err := fmt.Sscanf(id, "s%d", &index); err != nil || index >= len(storageTypes) {
+			continue
+		}
+		storageClasses[s3StorageTypeClasses[storageTypes[index]]] += latest
+		size += latest
+		found = true
+	}
+
+	return size, objectCount, storageClasses, found, nil
+}
+
+// s3StorageQuery builds a daily GetMetricData query for an AWS/S3 storage metric
+func s3StorageQuery(id, bucketName, metricName, storageType string) types.MetricDataQuery {
+	return types.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &types.MetricStat{
+			Metric: &types.Metric{
+				Namespace:  aws.String("AWS/S3"),
+				MetricName: aws.String(metricName),
+				Dimensions: []types.Dimension{
+					{Name: aws.String("BucketName"), Value: aws.String(bucketName)},
+					{Name: aws.String("StorageType"), Value: aws.String(storageType)},
+				},
+			},
+			Period: aws.Int32(86400), // 1 day in seconds
+			Stat:   aws.String("Average"),
+		},
+	}
+}
+
 // getBucketStorageMetrics estimates bucket size and composition by sampling objects
-func getBucketStorageMetrics(ctx context.Context, client *s3.Client, bucketName string) (
+func getBucketStorageMetrics(ctx context.Context, client S3BucketAPI, bucketName string) (
 	size int64,
 	objectCount int64,
 	storageClasses map[string]int64,
@@ -328,7 +672,7 @@ func getBucketStorageMetrics(ctx context.Context, client *s3.Client, bucketName
 }
 
 // getBucketAccessMetrics retrieves access patterns from CloudWatch
-func getBucketAccessMetrics(ctx context.Context, client *cloudwatch.Client, bucketName string) (map[string]float64, error) {
+func getBucketAccessMetrics(ctx context.Context, client CloudWatchMetricsAPI, bucketName string, daysBack int) (map[string]float64, error) {
 	accessFrequency := make(map[string]float64)
 
 	// Define the metrics to retrieve
@@ -338,9 +682,9 @@ func getBucketAccessMetrics(ctx context.Context, client *cloudwatch.Client, buck
 		"DeleteRequests",
 	}
 
-	// Calculate time period for metric queries (last 7 days)
+	// Calculate time period for metric queries (last daysBack days)
 	endTime := time.Now()
-	startTime := endTime.AddDate(0, 0, -7)
+	startTime := endTime.AddDate(0, 0, -daysBack)
 
 	// Query each operation type
 	for _, operation := range operations {
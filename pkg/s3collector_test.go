@@ -0,0 +1,256 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestListBuckets(t *testing.T) {
+	buckets := []fakeBucket{
+		{
+			name: "logs",
+			tags: map[string]string{"team": "platform"},
+			rules: []s3Types.LifecycleRule{{
+				ID:          aws.String("archive"),
+				Status:      s3Types.ExpirationStatusEnabled,
+				Transitions: []s3Types.Transition{{Days: aws.Int32(30), StorageClass: s3Types.TransitionStorageClassGlacier}},
+			}},
+			objects: []s3Types.Object{
+				{Key: aws.String("a"), Size: aws.Int64(100)},
+				{Key: aws.String("b"), Size: aws.Int64(300), StorageClass: s3Types.ObjectStorageClassStandardIa},
+			},
+		},
+		{name: "assets"},
+		{name: "backups"},
+	}
+
+	tests := []struct {
+		name       string
+		s3         *fakeS3Buckets
+		maxBuckets int
+		wantErr    string
+		wantNames  []string
+	}{
+		{
+			name:      "all buckets",
+			s3:        &fakeS3Buckets{region: "eu-west-1", buckets: buckets},
+			wantNames: []string{"assets", "backups", "logs"},
+		},
+		{
+			name:       "limited to maxBuckets",
+			s3:         &fakeS3Buckets{region: "eu-west-1", buckets: buckets},
+			maxBuckets: 1,
+			wantNames:  []string{"logs"},
+		},
+		{
+			name:    "list error is returned",
+			s3:      &fakeS3Buckets{region: "eu-west-1", buckets: buckets, listErr: errors.New("AccessDenied")},
+			wantErr: "AccessDenied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cw := &fakeCloudWatch{}
+			got, err := ListBuckets(context.Background(), tt.s3, cw, tt.maxBuckets, 7)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ListBuckets() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListBuckets() error = %v", err)
+			}
+
+			var names []string
+			for _, b := range got {
+				names = append(names, b.BucketName)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Fatalf("buckets = %v, want %v", names, tt.wantNames)
+			}
+
+			for _, b := range got {
+				if b.Region != "eu-west-1" {
+					t.Errorf("%s region = %q, want eu-west-1", b.BucketName, b.Region)
+				}
+				if b.PublicAccess != S3PublicAccessBlocked {
+					t.Errorf("%s PublicAccess = %q, want %q", b.BucketName, b.PublicAccess, S3PublicAccessBlocked)
+				}
+				if b.BucketName != "logs" {
+					continue
+				}
+				if b.Tags["team"] != "platform" {
+					t.Errorf("logs tags = %v", b.Tags)
+				}
+				if len(b.LifecycleRules) != 1 || b.LifecycleRules[0].ObjectAgeThreshold != 30 {
+					t.Errorf("logs lifecycle rules = %+v, want one rule at 30 days", b.LifecycleRules)
+				}
+				// Without CloudWatch storage metrics the size comes from sampling the objects
+				if b.MetricsSource != S3MetricsSourceSampled || b.SizeBytes != 400 || b.ObjectCount != 2 {
+					t.Errorf("logs size = %d bytes in %d objects from %q, want 400 in 2 from %q", b.SizeBytes, b.ObjectCount, b.MetricsSource, S3MetricsSourceSampled)
+				}
+				if b.StorageClasses["STANDARD"] != 100 || b.StorageClasses["STANDARD_IA"] != 300 {
+					t.Errorf("logs storage classes = %v", b.StorageClasses)
+				}
+			}
+		})
+	}
+}
+
+func TestListBucketsStorageFromCloudWatch(t *testing.T) {
+	const gib = 1 << 30
+
+	tests := []struct {
+		name        string
+		storage     map[string]float64 // latest BucketSizeBytes by StorageType
+		objects     float64
+		wantSource  string
+		wantSize    int64
+		wantObjects int64
+		wantClasses map[string]int64
+	}{
+		{
+			name: "several storage types",
+			storage: map[string]float64{
+				"StandardStorage":             10 * gib,
+				"StandardIAStorage":           4 * gib,
+				"StandardIASizeOverhead":      1 * gib,
+				"IntelligentTieringFAStorage": 2 * gib,
+				"IntelligentTieringIAStorage": 3 * gib,
+				"GlacierStorage":              5 * gib,
+			},
+			objects:     12345,
+			wantSource:  S3MetricsSourceCloudWatch,
+			wantSize:    25 * gib,
+			wantObjects: 12345,
+			wantClasses: map[string]int64{
+				"STANDARD":            10 * gib,
+				"STANDARD_IA":         5 * gib,
+				"INTELLIGENT_TIERING": 5 * gib,
+				"GLACIER":             5 * gib,
+			},
+		},
+		{
+			name:        "no storage metrics falls back to sampling",
+			wantSource:  S3MetricsSourceSampled,
+			wantSize:    400,
+			wantObjects: 2,
+			wantClasses: map[string]int64{"STANDARD": 400},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3Client := &fakeS3Buckets{region: "eu-west-1", buckets: []fakeBucket{{
+				name: "data",
+				objects: []s3Types.Object{
+					{Key: aws.String("a"), Size: aws.Int64(100)},
+					{Key: aws.String("b"), Size: aws.Int64(300)},
+				},
+			}}}
+			cw := &fakeCloudWatch{values: func(namespace, metricName, stat string, dims map[string]string) []float64 {
+				if namespace != "AWS/S3" || dims["BucketName"] != "data" {
+					return nil
+				}
+				switch metricName {
+				case "BucketSizeBytes":
+					if size, ok := tt.storage[dims["StorageType"]]; ok {
+						// Newest first, as ScanByTimestampDescending returns them
+						return []float64{size, size / 2}
+					}
+				case "NumberOfObjects":
+					if tt.objects > 0 && dims["StorageType"] == "AllStorageTypes" {
+						return []float64{tt.objects}
+					}
+				}
+				return nil
+			}}
+
+			buckets, err := ListBuckets(context.Background(), s3Client, cw, 0, 7)
+			if err != nil {
+				t.Fatalf("ListBuckets() error = %v", err)
+			}
+			if len(buckets) != 1 {
+				t.Fatalf("got %d buckets, want 1", len(buckets))
+			}
+			b := buckets[0]
+			if b.MetricsSource != tt.wantSource {
+				t.Errorf("MetricsSource = %q, want %q", b.MetricsSource, tt.wantSource)
+			}
+			if b.SizeBytes != tt.wantSize || b.ObjectCount != tt.wantObjects {
+				t.Errorf("size = %d bytes in %d objects, want %d in %d", b.SizeBytes, b.ObjectCount, tt.wantSize, tt.wantObjects)
+			}
+			if len(b.StorageClasses) != len(tt.wantClasses) {
+				t.Errorf("StorageClasses = %v, want %v", b.StorageClasses, tt.wantClasses)
+			}
+			for class, size := range tt.wantClasses {
+				if b.StorageClasses[class] != size {
+					t.Errorf("StorageClasses[%s] = %d, want %d", class, b.StorageClasses[class], size)
+				}
+			}
+		})
+	}
+}
+
+// testBuckets returns n empty buckets bucket-0 … bucket-(n-1)
+func testBuckets(n int) []fakeBucket {
+	buckets := make([]fakeBucket, n)
+	for i := range buckets {
+		buckets[i] = fakeBucket{name: fmt.Sprintf("bucket-%d", i)}
+	}
+	return buckets
+}
+
+func TestListBucketsCancelled(t *testing.T) {
+	// An uncancelled scan of the same buckets, to compare the cancelled ones against
+	full := &fakeS3Buckets{region: "eu-west-1", buckets: testBuckets(20)}
+	if _, err := ListBuckets(context.Background(), full, &fakeCloudWatch{}, 0, 7); err != nil {
+		t.Fatalf("ListBuckets() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		cancelAt  int // call that cancels the scan, 0 to cancel before it starts
+		wantCalls int // at most; workers finish the call they are making
+	}{
+		{name: "before the scan", cancelAt: 0, wantCalls: 0},
+		{name: "while listing", cancelAt: 1, wantCalls: 1},
+		{name: "while collecting", cancelAt: 10, wantCalls: 10 + 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s3Client := &fakeS3Buckets{region: "eu-west-1", buckets: testBuckets(20), cancel: cancel, cancelAt: tt.cancelAt}
+			if tt.cancelAt == 0 {
+				cancel()
+			}
+			cw := &fakeCloudWatch{}
+
+			buckets, err := ListBuckets(ctx, s3Client, cw, 0, 7)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("ListBuckets() error = %v, want context.Canceled", err)
+			}
+			if buckets != nil {
+				t.Errorf("got %d buckets from a cancelled scan", len(buckets))
+			}
+			if calls := s3Client.callCount(); calls > tt.wantCalls {
+				t.Errorf("made %d S3 calls, want at most %d of the %d a full scan makes", calls, tt.wantCalls, full.callCount())
+			}
+			if tt.cancelAt == 0 && cw.metricCalls()+cw.statsCalls != 0 {
+				t.Errorf("made %d CloudWatch calls", cw.metricCalls()+cw.statsCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Sampling limits of the reclaimable storage of a bucket, so a bucket with millions of
+// uploads or versions cannot use up its collection timeout
+const (
+	s3MaxUploadPages    = 5  // pages of 1000 incomplete multipart uploads
+	s3MaxUploadsPriced  = 10 // uploads whose parts are listed to estimate their size
+	s3MaxVersionPages   = 5  // pages of 1000 object versions
+	s3AbortUploadsAfter = 7  // days after which the recommended lifecycle rule aborts uploads
+)
+
+// ReclaimableBytes is the storage of a bucket that can be deleted without touching a current
+// object: the parts of incomplete multipart uploads and noncurrent object versions
+func (b S3Bucket) ReclaimableBytes() int64 {
+	return b.IncompleteUploadBytes + b.NoncurrentVersionBytes
+}
+
+// abortsIncompleteUploads reports whether an enabled lifecycle rule aborts incomplete uploads
+func (b S3Bucket) abortsIncompleteUploads() bool {
+	for _, rule := range b.LifecycleRules {
+		if rule.Status == "Enabled" && rule.AbortIncompleteUploadDays > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// expiresNoncurrentVersions reports whether an enabled lifecycle rule expires noncurrent versions
+func (b S3Bucket) expiresNoncurrentVersions() bool {
+	for _, rule := range b.LifecycleRules {
+		if rule.Status == "Enabled" && rule.NoncurrentExpirationDays > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// collectIncompleteUploads counts the incomplete multipart uploads of a bucket and their age,
+// and estimates their size from the parts of the first few. Past s3MaxUploadPages the count
+// is a lower bound and the bucket is marked ReclaimableEstimated.
+func collectIncompleteUploads(ctx context.Context, client S3BucketAPI, bucket *S3Bucket) error {
+	now := time.Now()
+	var keyMarker, uploadMarker *string
+	var totalAgeDays float64
+	var pricedUploads, pricedBytes int64
+
+	for page := 0; page < s3MaxUploadPages; page++ {
+		result, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+			Bucket:         aws.String(bucket.BucketName),
+			KeyMarker:      keyMarker,
+			UploadIdMarker: uploadMarker,
+			MaxUploads:     aws.Int32(1000),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, upload := range result.Uploads {
+			bucket.IncompleteUploadCount++
+			if upload.Initiated != nil {
+				ageDays := now.Sub(*upload.Initiated).Hours() / 24
+				totalAgeDays += ageDays
+				bucket.IncompleteUploadOldestDays = max(bucket.IncompleteUploadOldestDays, int(ageDays))
+			}
+			if pricedUploads < s3MaxUploadsPriced {
+				size, complete, err := incompleteUploadSize(ctx, client, bucket.BucketName, aws.ToString(upload.Key), aws.ToString(upload.UploadId))
+				if err != nil {
+					return err
+				}
+				if !complete {
+					bucket.ReclaimableEstimated = true
+				}
+				pricedUploads++
+				pricedBytes += size
+			}
+		}
+
+		if !aws.ToBool(result.IsTruncated) {
+			break
+		}
+		if page == s3MaxUploadPages-1 {
+			bucket.ReclaimableEstimated = true
+		}
+		keyMarker, uploadMarker = result.NextKeyMarker, result.NextUploadIdMarker
+	}
+
+	if bucket.IncompleteUploadCount == 0 {
+		return nil
+	}
+	bucket.IncompleteUploadAvgAgeDays = totalAgeDays / float64(bucket.IncompleteUploadCount)
+	if pricedUploads > 0 {
+		// Uploads beyond the priced ones are assumed to be of the same average size
+		bucket.IncompleteUploadBytes = pricedBytes * bucket.IncompleteUploadCount / pricedUploads
+		if pricedUploads < bucket.IncompleteUploadCount {
+			bucket.ReclaimableEstimated = true
+		}
+	}
+	return nil
+}
+
+// incompleteUploadSize sums the parts of an incomplete upload from the first page of its
+// parts; complete is false when it has more
+func incompleteUploadSize(ctx context.Context, client S3BucketAPI, bucketName, key, uploadID string) (size int64, complete bool, err error) {
+	result, err := client.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MaxParts: aws.Int32(1000),
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	for _, part := range result.Parts {
+		size += aws.ToInt64(part.Size)
+	}
+	return size, !aws.ToBool(result.IsTruncated), nil
+}
+
+// collectNoncurrentVersions measures the noncurrent object versions of a versioned bucket from
+// up to s3MaxVersionPages of its versions. When there are more, and the bucket size comes from
+// CloudWatch (which counts every version), the noncurrent share of the sample is scaled to it.
+func collectNoncurrentVersions(ctx context.Context, client S3BucketAPI, bucket *S3Bucket) error {
+	var keyMarker, versionMarker *string
+	var sampledBytes int64
+	truncated := false
+
+	for page := 0; page < s3MaxVersionPages; page++ {
+		result, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+			Bucket:          aws.String(bucket.BucketName),
+			KeyMarker:       keyMarker,
+			VersionIdMarker: versionMarker,
+			MaxKeys:         aws.Int32(1000),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, version := range result.Versions {
+			size := aws.ToInt64(version.Size)
+			sampledBytes += size
+			if !aws.ToBool(version.IsLatest) {
+				bucket.NoncurrentVersionCount++
+				bucket.NoncurrentVersionBytes += size
+			}
+		}
+
+		truncated = aws.ToBool(result.IsTruncated)
+		if !truncated {
+			break
+		}
+		keyMarker, versionMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+
+	if truncated {
+		bucket.ReclaimableEstimated = true
+		if bucket.MetricsSource == S3MetricsSourceCloudWatch && sampledBytes > 0 && bucket.SizeBytes > sampledBytes {
+			share := float64(bucket.NoncurrentVersionBytes) / float64(sampledBytes)
+			bucket.NoncurrentVersionBytes = int64(share * float64(bucket.SizeBytes))
+		}
+	}
+	return nil
+}
+
+// EstimateS3ReclaimableCost prices the reclaimable storage of a bucket for a month at the
+// Standard rate of its region, the class multipart uploads and most versions are stored in
+func EstimateS3ReclaimableCost(bucket S3Bucket) (float64, bool) {
+	price, ok := LookupS3Price("STANDARD", bucket.Region)
+	if !ok {
+		return 0, false
+	}
+	return float64(bucket.ReclaimableBytes()) / (1024 * 1024 * 1024) * price, true
+}
+
+// applyReclaimableSavings raises the savings of an S3 bucket to at least the cost of its
+// reclaimable storage, which deleting it saves in full
+func (r *ReportItem) applyReclaimableSavings() {
+	reclaimable, ok := EstimateS3ReclaimableCost(r.S3Bucket)
+	if !ok || r.MonthlyCost <= 0 {
+		return
+	}
+	reclaimable = min(reclaimable, r.MonthlyCost)
+	if reclaimable <= r.MonthlySavings {
+		return
+	}
+	r.MonthlySavings = reclaimable
+	r.OptimizedCost = r.MonthlyCost - reclaimable
+	r.SavingsPct = reclaimable / r.MonthlyCost * 100
+}
+
+// describeReclaimable describes the reclaimable storage of a bucket in one line, e.g.
+// "12 incomplete multipart uploads holding 3.20 GB (oldest 45 days), 10.50 GB in 830
+// noncurrent versions", or "" when there is none
+func describeReclaimable(bucket S3Bucket) string {
+	var parts []string
+	if bucket.IncompleteUploadCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d incomplete multipart uploads holding %.2f GB (oldest %d days)",
+			bucket.IncompleteUploadCount, float64(bucket.IncompleteUploadBytes)/(1024*1024*1024), bucket.IncompleteUploadOldestDays))
+	}
+	if bucket.NoncurrentVersionBytes > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f GB in %d noncurrent versions",
+			float64(bucket.NoncurrentVersionBytes)/(1024*1024*1024), bucket.NoncurrentVersionCount))
+	}
+	description := strings.Join(parts, ", ")
+	if description != "" && bucket.ReclaimableEstimated {
+		description += ", estimated from a sample"
+	}
+	return description
+}
+
+// reclaimableNote tells the analysis prompt what the reclaimable storage of a bucket costs
+// and which lifecycle rules would remove it, or returns "" when there is none
+func reclaimableNote(bucket S3Bucket) string {
+	if bucket.ReclaimableBytes() == 0 && bucket.IncompleteUploadCount == 0 {
+		return ""
+	}
+	note := "count all of it as savings"
+	if cost, ok := EstimateS3ReclaimableCost(bucket); ok {
+		note = fmt.Sprintf("count all of it, about $%.2f per month, as savings", cost)
+	}
+
+	var rules []string
+	if bucket.IncompleteUploadCount > 0 && !bucket.abortsIncompleteUploads() {
+		rules = append(rules, fmt.Sprintf("AbortIncompleteMultipartUpload after %d days", s3AbortUploadsAfter))
+	}
+	if bucket.NoncurrentVersionBytes > 0 && !bucket.expiresNoncurrentVersions() {
+		rules = append(rules, "NoncurrentVersionExpiration")
+	}
+	if len(rules) > 0 {
+		note += ". Recommend a lifecycle rule with " + strings.Join(rules, " and ")
+	}
+	return note
+}
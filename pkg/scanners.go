@@ -3,13 +3,18 @@ package pkg
 import (
 	"context"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	elb "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
@@ -24,31 +29,49 @@ type ResourceScanner interface {
 
 // EC2Scanner scans EC2 instances
 type EC2Scanner struct {
-	EC2Client *ec2.Client
-	CWClient  *cloudwatch.Client
-	DaysBack  int
-	MaxItems  int
+	EC2Client      EC2DescribeAPI
+	CWClient       CloudWatchMetricsAPI
+	DaysBack       int
+	MaxItems       int
+	TagFilters     TagFilterSet
+	IdleThresholds IdleThresholds
 }
 
+// RDSScanner scans RDS instances
 type RDSScanner struct {
-	RDSClient *rds.Client
-	CWClient  *cloudwatch.Client
-	DaysBack  int
-	MaxItems  int
+	RDSClient  RDSDescribeAPI
+	CWClient   CloudWatchMetricsAPI
+	DaysBack   int
+	MaxItems   int
+	TagFilters TagFilterSet
+}
+
+// rankingPoolFactor is how many times the limit a collector gathers, so that the limit can
+// pick the most promising resources without collecting every one in a large account
+const rankingPoolFactor = 4
+
+// collectLimit returns the limit to pass to a collector. Tag filters run after
+// collection, so the limit can only be pushed down when no filters are set.
+func collectLimit(maxItems int, filters TagFilterSet) int {
+	if filters.IsEmpty() {
+		return maxItems * rankingPoolFactor
+	}
+	return 0
 }
 
 // Scan implements ResourceScanner interface
 func (s *EC2Scanner) Scan(ctx context.Context) (interface{}, error) {
-	log.Printf("Scanning EC2 instances (past %d days)...", s.DaysBack)
-	instances, err := ListInstances(ctx, s.EC2Client, s.CWClient)
+	Infof("Scanning EC2 instances (past %d days)...", s.DaysBack)
+	instances, err := ListInstances(ctx, s.EC2Client, s.CWClient, s.DaysBack, s.IdleThresholds)
 	if err != nil {
 		return nil, err
 	}
 
-	// Apply limit if specified
-	if s.MaxItems > 0 && len(instances) > s.MaxItems {
-		log.Printf("Limiting EC2 scan to %d instances (found %d)", s.MaxItems, len(instances))
-		instances = instances[:s.MaxItems]
+	// Apply tag filters before the limit so the limit counts matching instances only
+	instances, filtered := filterByTags(instances, func(i Instance) map[string]string { return i.Tags }, s.TagFilters)
+	redactResourceTags(instances, func(i Instance) map[string]string { return i.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d EC2 instances", filtered)
 	}
 
 	return instances, nil
@@ -61,20 +84,28 @@ func (s *EC2Scanner) Name() string {
 
 // S3Scanner scans S3 buckets
 type S3Scanner struct {
-	S3Client *s3.Client
-	CWClient *cloudwatch.Client
-	MaxItems int
+	S3Client   S3BucketAPI
+	CWClient   CloudWatchMetricsAPI
+	DaysBack   int
+	MaxItems   int
+	TagFilters TagFilterSet
 }
 
 // Scan implements ResourceScanner interface
 func (s *S3Scanner) Scan(ctx context.Context) (interface{}, error) {
-	log.Printf("Scanning S3 buckets...")
-	buckets, err := ListBuckets(ctx, s.S3Client, s.CWClient, s.MaxItems)
+	Infof("Scanning S3 buckets (past %d days)...", s.DaysBack)
+	buckets, err := ListBuckets(ctx, s.S3Client, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("S3 scan completed: found %d buckets", len(buckets))
+	buckets, filtered := filterByTags(buckets, func(b S3Bucket) map[string]string { return b.Tags }, s.TagFilters)
+	redactResourceTags(buckets, func(b S3Bucket) map[string]string { return b.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d S3 buckets", filtered)
+	}
+
+	Infof("S3 scan completed: found %d buckets", len(buckets))
 	return buckets, nil
 }
 
@@ -83,17 +114,31 @@ func (s *S3Scanner) Name() string {
 	return "s3"
 }
 
-// EBSScanner scans EBS volumes (placeholder for future implementation)
+// EBSScanner scans EBS volumes
 type EBSScanner struct {
-	EC2Client *ec2.Client
-	CWClient  *cloudwatch.Client
-	DaysBack  int
+	EC2Client  EC2DescribeAPI
+	CWClient   CloudWatchMetricsAPI
+	DaysBack   int
+	MaxItems   int
+	TagFilters TagFilterSet
 }
 
 // Scan implements ResourceScanner interface
 func (s *EBSScanner) Scan(ctx context.Context) (interface{}, error) {
-	log.Println("EBS volume scanning not yet implemented")
-	return nil, fmt.Errorf("EBS scanning not implemented")
+	Infof("Scanning EBS volumes (past %d days)...", s.DaysBack)
+	volumes, err := ListVolumes(ctx, s.EC2Client, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, filtered := filterByTags(volumes, func(v EBSVolume) map[string]string { return v.Tags }, s.TagFilters)
+	redactResourceTags(volumes, func(v EBSVolume) map[string]string { return v.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d EBS volumes", filtered)
+	}
+
+	Infof("EBS scan completed: found %d volumes", len(volumes))
+	return volumes, nil
 }
 
 // Name implements ResourceScanner interface
@@ -101,21 +146,212 @@ func (s *EBSScanner) Name() string {
 	return "ebs"
 }
 
+// LambdaScanner scans Lambda functions
+type LambdaScanner struct {
+	LambdaClient LambdaFunctionsAPI
+	CWClient     CloudWatchMetricsAPI
+	DaysBack     int
+	MaxItems     int
+	TagFilters   TagFilterSet
+}
+
+// Scan implements ResourceScanner interface
+func (s *LambdaScanner) Scan(ctx context.Context) (interface{}, error) {
+	Infof("Scanning Lambda functions (past %d days)...", s.DaysBack)
+	functions, err := ListLambdaFunctions(ctx, s.LambdaClient, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
+	if err != nil {
+		return nil, err
+	}
+
+	functions, filtered := filterByTags(functions, func(f LambdaFunction) map[string]string { return f.Tags }, s.TagFilters)
+	redactResourceTags(functions, func(f LambdaFunction) map[string]string { return f.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d Lambda functions", filtered)
+	}
+
+	Infof("Lambda scan completed: found %d functions", len(functions))
+	return functions, nil
+}
+
+// Name implements ResourceScanner interface
+func (s *LambdaScanner) Name() string {
+	return "lambda"
+}
+
+// ELBScanner scans Application, Network and Gateway Load Balancers
+type ELBScanner struct {
+	ELBClient  ELBDescribeAPI
+	CWClient   CloudWatchMetricsAPI
+	DaysBack   int
+	MaxItems   int
+	TagFilters TagFilterSet
+}
+
+// Scan implements ResourceScanner interface
+func (s *ELBScanner) Scan(ctx context.Context) (interface{}, error) {
+	Infof("Scanning load balancers (past %d days)...", s.DaysBack)
+	loadBalancers, err := ListLoadBalancers(ctx, s.ELBClient, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
+	if err != nil {
+		return nil, err
+	}
+
+	loadBalancers, filtered := filterByTags(loadBalancers, func(lb LoadBalancer) map[string]string { return lb.Tags }, s.TagFilters)
+	redactResourceTags(loadBalancers, func(lb LoadBalancer) map[string]string { return lb.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d load balancers", filtered)
+	}
+
+	Infof("ELB scan completed: found %d load balancers", len(loadBalancers))
+	return loadBalancers, nil
+}
+
+// Name implements ResourceScanner interface
+func (s *ELBScanner) Name() string {
+	return "elb"
+}
+
+// DynamoDBScanner scans DynamoDB tables
+type DynamoDBScanner struct {
+	DynamoClient DynamoTablesAPI
+	CWClient     CloudWatchMetricsAPI
+	DaysBack     int
+	MaxItems     int
+	TagFilters   TagFilterSet
+}
+
+// Scan implements ResourceScanner interface
+func (s *DynamoDBScanner) Scan(ctx context.Context) (interface{}, error) {
+	Infof("Scanning DynamoDB tables (past %d days)...", s.DaysBack)
+	tables, err := ListDynamoTables(ctx, s.DynamoClient, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, filtered := filterByTags(tables, func(t DynamoTable) map[string]string { return t.Tags }, s.TagFilters)
+	redactResourceTags(tables, func(t DynamoTable) map[string]string { return t.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d DynamoDB tables", filtered)
+	}
+
+	Infof("DynamoDB scan completed: found %d tables", len(tables))
+	return tables, nil
+}
+
+// Name implements ResourceScanner interface
+func (s *DynamoDBScanner) Name() string {
+	return "dynamodb"
+}
+
+// ElastiCacheScanner scans Redis and Memcached clusters
+type ElastiCacheScanner struct {
+	CacheClient ElastiCacheDescribeAPI
+	CWClient    CloudWatchMetricsAPI
+	DaysBack    int
+	MaxItems    int
+	TagFilters  TagFilterSet
+}
+
+// Scan implements ResourceScanner interface
+func (s *ElastiCacheScanner) Scan(ctx context.Context) (interface{}, error) {
+	Infof("Scanning ElastiCache clusters (past %d days)...", s.DaysBack)
+	clusters, err := ListElastiCacheClusters(ctx, s.CacheClient, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters, filtered := filterByTags(clusters, func(c ElastiCacheCluster) map[string]string { return c.Tags }, s.TagFilters)
+	redactResourceTags(clusters, func(c ElastiCacheCluster) map[string]string { return c.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d ElastiCache clusters", filtered)
+	}
+
+	Infof("ElastiCache scan completed: found %d clusters", len(clusters))
+	return clusters, nil
+}
+
+// Name implements ResourceScanner interface
+func (s *ElastiCacheScanner) Name() string {
+	return "elasticache"
+}
+
+// NetworkScanner finds unassociated Elastic IPs and idle NAT gateways
+type NetworkScanner struct {
+	EC2Client  EC2DescribeAPI
+	CWClient   CloudWatchMetricsAPI
+	DaysBack   int
+	MaxItems   int
+	TagFilters TagFilterSet
+}
+
+// Scan implements ResourceScanner interface
+func (s *NetworkScanner) Scan(ctx context.Context) (interface{}, error) {
+	Infof("Scanning Elastic IPs and NAT gateways (past %d days)...", s.DaysBack)
+	resources, err := ListNetworkResources(ctx, s.EC2Client, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, filtered := filterByTags(resources, func(r NetworkResource) map[string]string { return r.Tags }, s.TagFilters)
+	redactResourceTags(resources, func(r NetworkResource) map[string]string { return r.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d network resources", filtered)
+	}
+
+	Infof("Network scan completed: found %d idle resources", len(resources))
+	return resources, nil
+}
+
+// Name implements ResourceScanner interface
+func (s *NetworkScanner) Name() string {
+	return "network"
+}
+
+// SnapshotScanner finds EBS snapshots that outlived their source volume and back no AMI
+type SnapshotScanner struct {
+	EC2Client  EC2DescribeAPI
+	MinAgeDays int
+	MaxItems   int
+	TagFilters TagFilterSet
+}
+
+// Scan implements ResourceScanner interface
+func (s *SnapshotScanner) Scan(ctx context.Context) (interface{}, error) {
+	Infof("Scanning EBS snapshots (older than %d days)...", s.MinAgeDays)
+	snapshots, err := ListStaleSnapshots(ctx, s.EC2Client, collectLimit(s.MaxItems, s.TagFilters), s.MinAgeDays)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, filtered := filterByTags(snapshots, func(snap EBSSnapshot) map[string]string { return snap.Tags }, s.TagFilters)
+	redactResourceTags(snapshots, func(snap EBSSnapshot) map[string]string { return snap.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d snapshots", filtered)
+	}
+
+	Infof("Snapshot scan completed: found %d stale snapshots", len(snapshots))
+	return snapshots, nil
+}
+
+// Name implements ResourceScanner interface
+func (s *SnapshotScanner) Name() string {
+	return "snapshots"
+}
+
 // Scan implements ResourceScanner interface
 func (s *RDSScanner) Scan(ctx context.Context) (interface{}, error) {
-	log.Printf("Scanning RDS instances (past %d days)...", s.DaysBack)
-	instances, err := ListRDSInstances(ctx, s.RDSClient, s.CWClient, s.MaxItems)
+	Infof("Scanning RDS instances (past %d days)...", s.DaysBack)
+	instances, err := ListRDSInstances(ctx, s.RDSClient, s.CWClient, collectLimit(s.MaxItems, s.TagFilters), s.DaysBack)
 	if err != nil {
 		return nil, err
 	}
 
-	// Apply limit if specified and not already applied
-	if s.MaxItems > 0 && len(instances) > s.MaxItems {
-		log.Printf("Limiting RDS scan to %d instances (found %d)", s.MaxItems, len(instances))
-		instances = instances[:s.MaxItems]
+	instances, filtered := filterByTags(instances, func(i RDSInstance) map[string]string { return i.Tags }, s.TagFilters)
+	redactResourceTags(instances, func(i RDSInstance) map[string]string { return i.Tags })
+	if filtered > 0 {
+		Infof("Tag filters excluded %d RDS instances", filtered)
 	}
 
-	log.Printf("RDS scan completed: found %d instances", len(instances))
+	Infof("RDS scan completed: found %d instances", len(instances))
 	return instances, nil
 }
 
@@ -124,70 +360,203 @@ func (s *RDSScanner) Name() string {
 	return "rds"
 }
 
-// ScanResources scans multiple resource types in parallel
-func ScanResources(ctx context.Context, cfg aws.Config, resourceTypes []string, maxItems int, daysBack int) (map[string]interface{}, error) {
-	results := make(map[string]interface{})
+// ScanOptions configures ScanResources
+type ScanOptions struct {
+	MaxItems           int            // resources across all types and regions, shared by AllocateLimits; 0 for no limit
+	TypeLimits         map[string]int // optional cap per resource type, within MaxItems
+	DaysBack           int            // CloudWatch lookback window
+	TagFilters         TagFilterSet   // only resources passing these filters are kept
+	Regions            []string       // regions to scan, see ResolveRegions
+	SnapshotMinAgeDays int            // snapshots younger than this are not reported
+	IdleThresholds     IdleThresholds // EC2 instances below these are classified as idle
+}
 
-	// Early return if no resource types specified
-	if len(resourceTypes) == 0 {
-		return results, nil
+// typeLimit returns the most resources of one type a scanner may return: its own cap when
+// set, and never more than the total limit
+func (o ScanOptions) typeLimit(resType string) int {
+	limit := o.TypeLimits[resType]
+	if limit <= 0 || (o.MaxItems > 0 && o.MaxItems < limit) {
+		return o.MaxItems
 	}
+	return limit
+}
 
-	// Create clients
+// newRegionScanners creates the scanners for a single region
+func newRegionScanners(cfg aws.Config, opts ScanOptions) map[string]ResourceScanner {
 	ec2Client := ec2.NewFromConfig(cfg)
 	cwClient := cloudwatch.NewFromConfig(cfg)
 	rdsClient := rds.NewFromConfig(cfg)
 	s3Client := s3.NewFromConfig(cfg)
+	lambdaClient := lambda.NewFromConfig(cfg)
+	elbClient := elb.NewFromConfig(cfg)
+	dynamoClient := dynamodb.NewFromConfig(cfg)
+	cacheClient := elasticache.NewFromConfig(cfg)
 
-	// Create scanners map
-	scanners := map[string]ResourceScanner{
+	return map[string]ResourceScanner{
 		"ec2": &EC2Scanner{
-			EC2Client: ec2Client,
-			CWClient:  cwClient,
-			DaysBack:  daysBack,
-			MaxItems:  maxItems,
+			EC2Client:      ec2Client,
+			CWClient:       cwClient,
+			DaysBack:       opts.DaysBack,
+			MaxItems:       opts.typeLimit("ec2"),
+			TagFilters:     opts.TagFilters,
+			IdleThresholds: opts.IdleThresholds,
+		},
+		"dynamodb": &DynamoDBScanner{
+			DynamoClient: dynamoClient,
+			CWClient:     cwClient,
+			DaysBack:     opts.DaysBack,
+			MaxItems:     opts.typeLimit("dynamodb"),
+			TagFilters:   opts.TagFilters,
 		},
 		"ebs": &EBSScanner{
-			EC2Client: ec2Client,
-			CWClient:  cwClient,
-			DaysBack:  daysBack,
+			EC2Client:  ec2Client,
+			CWClient:   cwClient,
+			DaysBack:   opts.DaysBack,
+			MaxItems:   opts.typeLimit("ebs"),
+			TagFilters: opts.TagFilters,
+		},
+		"elasticache": &ElastiCacheScanner{
+			CacheClient: cacheClient,
+			CWClient:    cwClient,
+			DaysBack:    opts.DaysBack,
+			MaxItems:    opts.typeLimit("elasticache"),
+			TagFilters:  opts.TagFilters,
+		},
+		"elb": &ELBScanner{
+			ELBClient:  elbClient,
+			CWClient:   cwClient,
+			DaysBack:   opts.DaysBack,
+			MaxItems:   opts.typeLimit("elb"),
+			TagFilters: opts.TagFilters,
+		},
+		"lambda": &LambdaScanner{
+			LambdaClient: lambdaClient,
+			CWClient:     cwClient,
+			DaysBack:     opts.DaysBack,
+			MaxItems:     opts.typeLimit("lambda"),
+			TagFilters:   opts.TagFilters,
+		},
+		"network": &NetworkScanner{
+			EC2Client:  ec2Client,
+			CWClient:   cwClient,
+			DaysBack:   opts.DaysBack,
+			MaxItems:   opts.typeLimit("network"),
+			TagFilters: opts.TagFilters,
 		},
 		"rds": &RDSScanner{
-			RDSClient: rdsClient,
-			CWClient:  cwClient,
-			DaysBack:  daysBack,
-			MaxItems:  maxItems,
+			RDSClient:  rdsClient,
+			CWClient:   cwClient,
+			DaysBack:   opts.DaysBack,
+			MaxItems:   opts.typeLimit("rds"),
+			TagFilters: opts.TagFilters,
+		},
+		"snapshots": &SnapshotScanner{
+			EC2Client:  ec2Client,
+			MinAgeDays: opts.SnapshotMinAgeDays,
+			MaxItems:   opts.typeLimit("snapshots"),
+			TagFilters: opts.TagFilters,
 		},
 		"s3": &S3Scanner{
-			S3Client: s3Client,
-			CWClient: cwClient,
-			MaxItems: maxItems,
+			S3Client:   s3Client,
+			CWClient:   cwClient,
+			DaysBack:   opts.DaysBack,
+			MaxItems:   opts.typeLimit("s3"),
+			TagFilters: opts.TagFilters,
 		},
 	}
+}
+
+// ResolveRegions expands the requested region list. "all" is replaced by every
+// region enabled for the account; an empty list means the configured region.
+func ResolveRegions(ctx context.Context, cfg aws.Config, regions []string) ([]string, error) {
+	if len(regions) == 0 {
+		return []string{cfg.Region}, nil
+	}
+
+	for _, r := range regions {
+		if r != "all" {
+			continue
+		}
+
+		resp, err := ec2.NewFromConfig(cfg).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list regions: %w", err)
+		}
+
+		all := make([]string, 0, len(resp.Regions))
+		for _, region := range resp.Regions {
+			all = append(all, aws.ToString(region.RegionName))
+		}
+		sort.Strings(all)
+		return all, nil
+	}
+
+	return regions, nil
+}
+
+// ScanResources scans multiple resource types across regions in parallel, keeping only
+// resources that pass the tag filters. Results from all regions are merged per resource
+// type, then the item limit is shared between the types by AllocateLimits, keeping the
+// largest or most expensive resources of each.
+func ScanResources(ctx context.Context, cfg aws.Config, resourceTypes []string, opts ScanOptions) (map[string]interface{}, error) {
+	results := make(map[string]interface{})
+
+	// Early return if no resource types specified
+	if len(resourceTypes) == 0 {
+		return results, nil
+	}
 
-	// Filter scanners to requested resource types
-	var selectedScanners []ResourceScanner
-	for _, resType := range resourceTypes {
-		if scanner, ok := scanners[resType]; ok {
-			selectedScanners = append(selectedScanners, scanner)
-		} else {
-			log.Printf("Warning: Unknown resource type '%s'", resType)
+	regions, err := ResolveRegions(ctx, cfg, opts.Regions)
+	if err != nil {
+		return results, err
+	}
+
+	// Pair each selected scanner with the region it runs in
+	type regionScan struct {
+		region  string
+		scanner ResourceScanner
+		result  interface{}
+	}
+	var scans []*regionScan
+
+	for i, region := range regions {
+		regionCfg := cfg.Copy()
+		regionCfg.Region = region
+		scanners := newRegionScanners(regionCfg, opts)
+
+		for _, resType := range resourceTypes {
+			scanner, ok := scanners[resType]
+			if !ok {
+				if i == 0 {
+					Warnf("Unknown resource type '%s'", resType)
+				}
+				continue
+			}
+			// Bucket listing is global, so S3 only needs to be scanned once
+			if resType == "s3" && i > 0 {
+				continue
+			}
+			scans = append(scans, &regionScan{region: region, scanner: scanner})
 		}
 	}
 
 	// Early return if no valid resource types
-	if len(selectedScanners) == 0 {
+	if len(scans) == 0 {
 		return results, fmt.Errorf("no valid resource types specified")
 	}
 
+	if len(regions) > 1 {
+		Infof("Scanning %d regions: %s", len(regions), strings.Join(regions, ", "))
+	}
+
 	// Run scanners in parallel
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	errCount := 0
 
-	for _, scanner := range selectedScanners {
+	for _, scan := range scans {
 		wg.Add(1)
-		go func(s ResourceScanner) {
+		go func(rs *regionScan) {
 			defer wg.Done()
 
 			// Create timeout context for this scan
@@ -195,27 +564,76 @@ func ScanResources(ctx context.Context, cfg aws.Config, resourceTypes []string,
 			defer cancel()
 
 			// Run the scan
-			result, err := s.Scan(scanCtx)
+			result, err := rs.scanner.Scan(scanCtx)
 
 			mu.Lock()
 			defer mu.Unlock()
 
 			if err != nil {
-				log.Printf("Error scanning %s: %v", s.Name(), err)
+				// A cancelled scan is reported once by the caller, not per scanner
+				if ctx.Err() == nil {
+					Errorf("Error scanning %s in %s: %v", rs.scanner.Name(), rs.region, err)
+				}
 				errCount++
-			} else if result != nil {
-				results[s.Name()] = result
+			} else {
+				rs.result = result
 			}
-		}(scanner)
+		}(scan)
 	}
 
 	// Wait for all scanners to complete
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
 
 	// Return error if all scanners failed
-	if errCount == len(selectedScanners) {
+	if errCount == len(scans) {
 		return results, fmt.Errorf("all resource scans failed")
 	}
 
+	// Merge in region order so the global limit keeps a deterministic subset
+	for _, scan := range scans {
+		if scan.result == nil {
+			continue
+		}
+		name := scan.scanner.Name()
+		results[name] = mergeScanResults(results[name], scan.result)
+	}
+
+	limitScanResults(results, resourceTypes, opts.MaxItems, opts.TypeLimits)
+
 	return results, nil
 }
+
+// mergeScanResults appends the resources in next to those in existing
+func mergeScanResults(existing, next interface{}) interface{} {
+	if existing == nil {
+		return next
+	}
+
+	switch e := existing.(type) {
+	case []Instance:
+		return append(e, next.([]Instance)...)
+	case []S3Bucket:
+		return append(e, next.([]S3Bucket)...)
+	case []RDSInstance:
+		return append(e, next.([]RDSInstance)...)
+	case []EBSVolume:
+		return append(e, next.([]EBSVolume)...)
+	case []LambdaFunction:
+		return append(e, next.([]LambdaFunction)...)
+	case []LoadBalancer:
+		return append(e, next.([]LoadBalancer)...)
+	case []NetworkResource:
+		return append(e, next.([]NetworkResource)...)
+	case []EBSSnapshot:
+		return append(e, next.([]EBSSnapshot)...)
+	case []DynamoTable:
+		return append(e, next.([]DynamoTable)...)
+	case []ElastiCacheCluster:
+		return append(e, next.([]ElastiCacheCluster)...)
+	default:
+		return existing
+	}
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// searchExcerptLength bounds the analysis excerpt printed for each search match, in characters
+const searchExcerptLength = 200
+
+// SearchMatch is a report item ranked by the similarity of its embedding to a query
+type SearchMatch struct {
+	Item  ReportItem
+	Score float64 // cosine similarity to the query, 1 being identical
+}
+
+// CosineSimilarity compares two embeddings; ok is false when they cannot be compared because
+// their lengths differ or one of them is all zeros
+func CosineSimilarity(a, b []float64) (similarity float64, ok bool) {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0, false
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0, false
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), true
+}
+
+// TopKByEmbedding returns the k report items most similar to the query embedding, best first.
+// Items without an embedding comparable to the query are left out, and ties are broken by
+// type, region and ID so the ranking is stable. A k of 0 or less returns every match.
+func TopKByEmbedding(query []float64, report []ReportItem, k int) []SearchMatch {
+	matches := make([]SearchMatch, 0, len(report))
+	for _, item := range report {
+		if score, ok := CosineSimilarity(query, item.Embedding); ok {
+			matches = append(matches, SearchMatch{Item: item, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return lessByName(matches[i].Item, matches[j].Item)
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// searchResultJSON is one match in the JSON output of greenops search; the embedding is left out
+type searchResultJSON struct {
+	Rank           int          `json:"rank"`
+	Score          float64      `json:"score"`
+	ResourceType   ResourceType `json:"resource_type"`
+	ResourceID     string       `json:"resource_id"`
+	Region         string       `json:"region"`
+	MonthlyCost    float64      `json:"monthly_cost"`
+	MonthlySavings float64      `json:"monthly_savings"`
+	Analysis       string       `json:"analysis"`
+}
+
+// FormatSearchResultsJSON writes the query and its matches as a single JSON document
+func FormatSearchResultsJSON(w io.Writer, query string, matches []SearchMatch) error {
+	results := make([]searchResultJSON, 0, len(matches))
+	for i, match := range matches {
+		_, cost, savings := extractItemMetrics(match.Item)
+		resourceID, region, _, _ := describeItem(match.Item)
+		results = append(results, searchResultJSON{
+			Rank:           i + 1,
+			Score:          match.Score,
+			ResourceType:   match.Item.GetResourceType(),
+			ResourceID:     resourceID,
+			Region:         region,
+			MonthlyCost:    cost,
+			MonthlySavings: savings,
+			Analysis:       match.Item.Analysis,
+		})
+	}
+
+	output := struct {
+		Query   string             `json:"query"`
+		Results []searchResultJSON `json:"results"`
+	}{query, results}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// FormatSearchResults prints each match with its score, resource and an excerpt of its analysis
+func FormatSearchResults(w io.Writer, query string, matches []SearchMatch, colorize bool) {
+	printHeader(w, "GreenOps Search Results", colorize)
+	fmt.Fprintf(w, "Query: %s\n", query)
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "\nNo resources matched.")
+		return
+	}
+
+	for i, match := range matches {
+		_, _, savings := extractItemMetrics(match.Item)
+		resourceID, region, _, _ := describeItem(match.Item)
+		title := fmt.Sprintf("%d. %s %s (%s)", i+1, match.Item.GetResourceType(), resourceID, region)
+		if colorize {
+			title = ColorBold + title + ColorReset
+		}
+		fmt.Fprintf(w, "\n%s  score %.3f, potential savings $%.2f/month\n", title, match.Score, savings)
+		fmt.Fprintf(w, "   %s\n", analysisExcerpt(match.Item.Analysis, searchExcerptLength))
+	}
+}
+
+// analysisExcerpt returns the start of an analysis as one line of plain text, skipping the
+// markdown headings, rules and emphasis the model writes
+func analysisExcerpt(analysis string, maxLen int) string {
+	var words []string
+	for _, line := range strings.Split(analysis, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.Trim(line, "-=*_ ") == "" {
+			continue
+		}
+		line = strings.TrimLeft(line, "-*• ")
+		line = strings.NewReplacer("**", "", "__", "", "`", "").Replace(line)
+		words = append(words, strings.Fields(line)...)
+	}
+
+	excerpt := []rune(strings.Join(words, " "))
+	if len(excerpt) <= maxLen {
+		return string(excerpt)
+	}
+	return strings.TrimSpace(string(excerpt[:maxLen])) + "..."
+}
@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   []float64
+		want   float64
+		wantOK bool
+	}{
+		{name: "identical", a: []float64{1, 2, 3}, b: []float64{1, 2, 3}, want: 1, wantOK: true},
+		{name: "same direction", a: []float64{1, 2, 3}, b: []float64{2, 4, 6}, want: 1, wantOK: true},
+		{name: "orthogonal", a: []float64{1, 0}, b: []float64{0, 1}, want: 0, wantOK: true},
+		{name: "opposite", a: []float64{1, -1}, b: []float64{-1, 1}, want: -1, wantOK: true},
+		{name: "45 degrees", a: []float64{1, 0}, b: []float64{1, 1}, want: 1 / math.Sqrt2, wantOK: true},
+		{name: "different lengths", a: []float64{1, 2}, b: []float64{1, 2, 3}},
+		{name: "empty", a: nil, b: nil},
+		{name: "zero vector", a: []float64{0, 0}, b: []float64{1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CosineSimilarity(tt.a, tt.b)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("CosineSimilarity(%v, %v) = %v, %v; want %v, %v", tt.a, tt.b, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// embeddedInstance is an EC2 report item with an embedding
+func embeddedInstance(id, region string, embedding ...float64) ReportItem {
+	return ReportItem{ResourceType: ResourceTypeEC2, Instance: Instance{InstanceID: id, Region: region}, Embedding: embedding}
+}
+
+func TestTopKByEmbedding(t *testing.T) {
+	query := []float64{1, 0}
+	report := []ReportItem{
+		embeddedInstance("i-far", "eu-west-1", 0, 1),
+		embeddedInstance("i-close", "eu-west-1", 1, 0.1),
+		embeddedInstance("i-exact", "eu-west-1", 2, 0),
+		embeddedInstance("i-none", "eu-west-1"),
+		embeddedInstance("i-wrong-length", "eu-west-1", 1, 0, 0),
+		embeddedInstance("i-mid", "eu-west-1", 1, 1),
+	}
+
+	tests := []struct {
+		k    int
+		want string
+	}{
+		{k: 0, want: "[i-exact i-close i-mid i-far]"},
+		{k: -1, want: "[i-exact i-close i-mid i-far]"},
+		{k: 2, want: "[i-exact i-close]"},
+		{k: 10, want: "[i-exact i-close i-mid i-far]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.k), func(t *testing.T) {
+			matches := TopKByEmbedding(query, report, tt.k)
+			var ids []string
+			for i, match := range matches {
+				ids = append(ids, match.Item.Instance.InstanceID)
+				if i > 0 && match.Score > matches[i-1].Score {
+					t.Errorf("match %d scores %v, above the %v before it", i, match.Score, matches[i-1].Score)
+				}
+			}
+			if fmt.Sprint(ids) != tt.want {
+				t.Errorf("TopKByEmbedding(k=%d) = %v, want %s", tt.k, ids, tt.want)
+			}
+		})
+	}
+}
+
+// Equal scores are ordered by type, region and ID whatever order the report is in
+func TestTopKByEmbeddingBreaksTies(t *testing.T) {
+	report := []ReportItem{
+		embeddedInstance("i-b", "us-east-1", 1, 0),
+		embeddedInstance("i-b", "eu-west-1", 1, 0),
+		{ResourceType: ResourceTypeS3, S3Bucket: S3Bucket{BucketName: "bucket", Region: "eu-west-1"}, Embedding: []float64{1, 0}},
+		embeddedInstance("i-a", "eu-west-1", 1, 0),
+	}
+
+	matches := TopKByEmbedding([]float64{1, 0}, report, 0)
+	var got []string
+	for _, match := range matches {
+		id, region, _, _ := describeItem(match.Item)
+		got = append(got, string(match.Item.GetResourceType())+"/"+region+"/"+id)
+	}
+	if want := "[ec2/eu-west-1/i-a ec2/eu-west-1/i-b ec2/us-east-1/i-b s3/eu-west-1/bucket]"; fmt.Sprint(got) != want {
+		t.Errorf("TopKByEmbedding() order = %v, want %s", got, want)
+	}
+}
+
+func TestTopKByEmbeddingWithoutEmbeddings(t *testing.T) {
+	report := []ReportItem{embeddedInstance("i-1", "eu-west-1"), embeddedInstance("i-2", "eu-west-1")}
+	if matches := TopKByEmbedding([]float64{1, 0}, report, 5); len(matches) != 0 {
+		t.Errorf("TopKByEmbedding() = %v, want no matches for a report without embeddings", matches)
+	}
+}
@@ -0,0 +1,166 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot storage is priced at the us-east-1 standard tier rate. The CO2 factor assumes
+// 0.65 Wh per TB-hour for replicated object storage, 3 copies, a PUE of 1.135 and
+// 0.4 kg CO2 per kWh, giving kg CO2 per GB-month.
+const (
+	snapshotGBMonthPrice  = 0.05
+	snapshotCO2PerGBMonth = 0.65 * 3 * 1.135 * hoursPerMonth / 1024 * 0.0004
+)
+
+// snapshotPromptLimit caps how many snapshots are listed individually in the prompt and report
+const snapshotPromptLimit = 10
+
+// SnapshotSummary holds the aggregate figures for the stale snapshots of a scan
+type SnapshotSummary struct {
+	Count          int
+	TotalGiB       int64
+	OldestAgeDays  float64
+	MonthlyCost    float64
+	CO2KgMonthly   float64
+	StaleAfterDays int
+}
+
+// SummarizeSnapshots totals the reclaimable storage of the given snapshots. All of them are
+// deletion candidates, so the monthly cost is also the savings.
+func SummarizeSnapshots(snapshots []EBSSnapshot) SnapshotSummary {
+	var summary SnapshotSummary
+	summary.Count = len(snapshots)
+	for _, s := range snapshots {
+		summary.TotalGiB += int64(s.SizeGiB)
+		summary.OldestAgeDays = max(summary.OldestAgeDays, s.AgeDays)
+		summary.StaleAfterDays = s.StaleAfterDays
+	}
+	summary.MonthlyCost = float64(summary.TotalGiB) * snapshotGBMonthPrice
+	summary.CO2KgMonthly = float64(summary.TotalGiB) * snapshotCO2PerGBMonth
+	return summary
+}
+
+// TopSnapshots returns up to n snapshots, largest first
+func TopSnapshots(snapshots []EBSSnapshot, n int) []EBSSnapshot {
+	sorted := make([]EBSSnapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SizeGiB > sorted[j].SizeGiB
+	})
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// AnalyzeSnapshotsWithBedrock summarizes all stale snapshots in a single Bedrock call rather
+// than one call per snapshot. Callers fall back to AnalyzeSnapshotsLocally when Bedrock is unavailable.
+func AnalyzeSnapshotsWithBedrock(
+	ctx context.Context,
+	client BedrockInvoker,
+	modelID string,
+	snapshots []EBSSnapshot,
+) (InvokeResult, error) {
+	summary := SummarizeSnapshots(snapshots)
+	snapshotText := formatSnapshotsForPrompt(snapshots, summary)
+
+	// Construct the prompt with an example to ensure consistent formatting
+	prompt := fmt.Sprintf(`Here is a summary of stale EBS snapshots. This is a cloud optimisation tool called GreenOps that's also helping with sustainability efforts:
+%[1]s
+
+Every snapshot listed is older than %[2]d days, its source volume has been deleted and no registered AMI uses it.
+Together they hold %[3]d GiB, costing an estimated $%.2[4]f per month with a CO2 footprint of %.2[5]f kg CO2 per month; use these figures.
+Your analysis must include:
+1) Summarize the findings and what to check before deleting (retention policies, compliance or backup requirements in the tags and descriptions)
+2) Recommend deleting the snapshots, or archiving them to the archive tier if they must be kept
+3) Recommend a lifecycle policy (e.g. Amazon Data Lifecycle Manager) so snapshots stop accumulating
+4) Provide SUSTAINABILITY TIPS for this finding
+
+FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:
+
+# Snapshot Analysis: [NUMBER] stale snapshots
+
+## Analysis
+
+[1 paragraph general description]
+
+### Optimization Recommendations
+
+1. [RECOMMENDATION 1]: [DESCRIPTION]
+2. [RECOMMENDATION 2]: [DESCRIPTION]
+
+## Cost & Environmental Impact
+- Estimated Monthly Cost: $X.XX
+- Potential Optimized Cost: $X.XX
+- Monthly Savings Potential: $X.XX (XX.X%%)
+- CO2 Footprint: X.XX kg CO2 per month
+
+## Sustainability Tips
+
+1. [TIP 1]: [DESCRIPTION]
+`, snapshotText, summary.StaleAfterDays, summary.TotalGiB, summary.MonthlyCost, summary.CO2KgMonthly)
+
+	// Generate the analysis in the configured format
+	return generateAnalysis(ctx, client, modelID, ResourceTypeSnapshots, prompt)
+}
+
+// AnalyzeSnapshotsLocally builds the snapshot summary from fixed pricing without calling Bedrock.
+// It follows the prompt template so the cost and CO2 figures are extracted like any other analysis.
+func AnalyzeSnapshotsLocally(snapshots []EBSSnapshot) string {
+	summary := SummarizeSnapshots(snapshots)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Snapshot Analysis: %d stale snapshots\n\n## Analysis\n\n", summary.Count))
+	sb.WriteString(fmt.Sprintf("%d snapshots older than %d days hold %d GiB. Their source volumes have been deleted and no registered AMI uses them, so they are kept only for as long as nobody deletes them.\n\n",
+		summary.Count, summary.StaleAfterDays, summary.TotalGiB))
+	sb.WriteString("### Optimization Recommendations\n\n")
+	sb.WriteString("1. Delete the snapshots: confirm no retention or compliance requirement applies, then delete them.\n")
+	sb.WriteString("2. Archive what must be kept: the archive tier costs far less for snapshots that are rarely restored.\n")
+	sb.WriteString("3. Add a lifecycle policy: Amazon Data Lifecycle Manager can expire snapshots so they stop accumulating.\n\n")
+
+	savingsPct := 0.0
+	if summary.MonthlyCost > 0 {
+		savingsPct = 100
+	}
+	sb.WriteString("## Cost & Environmental Impact\n")
+	sb.WriteString(fmt.Sprintf("- Estimated Monthly Cost: $%.2f\n", summary.MonthlyCost))
+	sb.WriteString("- Potential Optimized Cost: $0.00\n")
+	sb.WriteString(fmt.Sprintf("- Monthly Savings Potential: $%.2f (%.1f%%)\n", summary.MonthlyCost, savingsPct))
+	sb.WriteString(fmt.Sprintf("- CO2 Footprint: %.2f kg CO2 per month\n", summary.CO2KgMonthly))
+
+	return sb.String()
+}
+
+// formatSnapshotsForPrompt converts the snapshot aggregate to a human-readable format for the LLM prompt
+func formatSnapshotsForPrompt(snapshots []EBSSnapshot, summary SnapshotSummary) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Stale Snapshots: %d\n", summary.Count))
+	sb.WriteString(fmt.Sprintf("Total Size: %d GiB\n", summary.TotalGiB))
+	sb.WriteString(fmt.Sprintf("Oldest: %.0f days\n", summary.OldestAgeDays))
+
+	// Only the largest snapshots are listed so the prompt stays small
+	sb.WriteString(fmt.Sprintf("\nLargest %d snapshots:\n", min(snapshotPromptLimit, len(snapshots))))
+	for _, s := range TopSnapshots(snapshots, snapshotPromptLimit) {
+		sb.WriteString(fmt.Sprintf("- %s (%s): %d GiB, created %s (%.0f days ago), source volume %s",
+			s.SnapshotID, s.Region, s.SizeGiB, s.StartTime.Format(time.DateOnly), s.AgeDays, s.VolumeID))
+		if s.Description != "" {
+			sb.WriteString(fmt.Sprintf(", description %q", s.Description))
+		}
+		if len(s.Tags) > 0 {
+			tags := make([]string, 0, len(s.Tags))
+			for k, v := range s.Tags {
+				tags = append(tags, k+"="+v)
+			}
+			sort.Strings(tags)
+			sb.WriteString(fmt.Sprintf(", tags %s", strings.Join(tags, " ")))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
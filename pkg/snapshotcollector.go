@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// EBSSnapshot holds metadata for an EBS snapshot owned by the account
+// - SizeGiB: size of the source volume; the stored incremental data may be smaller
+// - SourceVolumeExists: whether the volume the snapshot was taken from still exists
+// - AMIID: registered AMI backed by the snapshot, if any
+// - StaleAfterDays: age threshold the snapshot was flagged with
+type EBSSnapshot struct {
+	SnapshotID         string            `json:"snapshotId"`
+	VolumeID           string            `json:"volumeId"`
+	SizeGiB            int32             `json:"sizeGiB"`
+	StorageTier        string            `json:"storageTier"`
+	Description        string            `json:"description,omitempty"`
+	Region             string            `json:"region"`
+	AccountID          string            `json:"accountId,omitempty"` // set when scanning an organization with --org
+	StartTime          time.Time         `json:"startTime"`
+	AgeDays            float64           `json:"ageDays"`
+	Tags               map[string]string `json:"tags"`
+	SourceVolumeExists bool              `json:"sourceVolumeExists"`
+	AMIID              string            `json:"amiId,omitempty"`
+	StaleAfterDays     int               `json:"staleAfterDays"`
+}
+
+// ListStaleSnapshots retrieves the snapshots owned by the account that are older than minAgeDays,
+// whose source volume was deleted and which back no registered AMI. The largest come first.
+func ListStaleSnapshots(
+	ctx context.Context,
+	ec2Client EC2DescribeAPI,
+	maxSnapshots int,
+	minAgeDays int,
+) ([]EBSSnapshot, error) {
+	if minAgeDays <= 0 {
+		minAgeDays = DefaultSnapshotMinAgeDays
+	}
+
+	// Get list of snapshots
+	var snapshots []ec2Types.Snapshot
+	var nextToken *string
+	for {
+		resp, err := ec2Client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{
+			OwnerIds:   []string{"self"},
+			NextToken:  nextToken,
+			MaxResults: aws.Int32(1000),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, resp.Snapshots...)
+
+		// Check if there are more pages
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+
+	amiSnapshots, err := listAMISnapshots(ctx, ec2Client)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := listVolumeIDs(ctx, ec2Client)
+	if err != nil {
+		return nil, err
+	}
+
+	region := ec2Client.Options().Region
+	results := make([]EBSSnapshot, 0)
+	for _, s := range snapshots {
+		snapshot := EBSSnapshot{
+			SnapshotID:     aws.ToString(s.SnapshotId),
+			VolumeID:       aws.ToString(s.VolumeId),
+			SizeGiB:        aws.ToInt32(s.VolumeSize),
+			StorageTier:    string(s.StorageTier),
+			Description:    aws.ToString(s.Description),
+			Region:         region,
+			Tags:           parseTags(s.Tags),
+			StaleAfterDays: minAgeDays,
+		}
+		if s.StartTime != nil {
+			snapshot.StartTime = *s.StartTime
+			snapshot.AgeDays = time.Since(*s.StartTime).Hours() / 24
+		}
+		snapshot.SourceVolumeExists = volumes[snapshot.VolumeID]
+		snapshot.AMIID = amiSnapshots[snapshot.SnapshotID]
+
+		if snapshot.AgeDays < float64(minAgeDays) || snapshot.SourceVolumeExists || snapshot.AMIID != "" {
+			continue
+		}
+		results = append(results, snapshot)
+	}
+	Infof("Found %d stale snapshots of %d (older than %d days, source volume deleted, no AMI)", len(results), len(snapshots), minAgeDays)
+
+	// Report the largest snapshots first so the limit keeps the biggest offenders
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].SizeGiB != results[j].SizeGiB {
+			return results[i].SizeGiB > results[j].SizeGiB
+		}
+		return results[i].SnapshotID < results[j].SnapshotID
+	})
+
+	// Apply limit if specified
+	if maxSnapshots > 0 && len(results) > maxSnapshots {
+		Infof("Limiting snapshot scan to %d snapshots (found %d)", maxSnapshots, len(results))
+		results = results[:maxSnapshots]
+	}
+
+	return results, nil
+}
+
+// listAMISnapshots maps the snapshots backing the account's registered AMIs to the AMI ID
+func listAMISnapshots(ctx context.Context, ec2Client EC2DescribeAPI) (map[string]string, error) {
+	amiSnapshots := make(map[string]string)
+	var nextToken *string
+	for {
+		resp, err := ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+			Owners:     []string{"self"},
+			NextToken:  nextToken,
+			MaxResults: aws.Int32(1000),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, image := range resp.Images {
+			for _, mapping := range image.BlockDeviceMappings {
+				if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+					amiSnapshots[*mapping.Ebs.SnapshotId] = aws.ToString(image.ImageId)
+				}
+			}
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	return amiSnapshots, nil
+}
+
+// listVolumeIDs returns the IDs of every EBS volume in the region
+func listVolumeIDs(ctx context.Context, ec2Client EC2DescribeAPI) (map[string]bool, error) {
+	volumes := make(map[string]bool)
+	var nextToken *string
+	for {
+		resp, err := ec2Client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
+			NextToken:  nextToken,
+			MaxResults: aws.Int32(500),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, volume := range resp.Volumes {
+			volumes[aws.ToString(volume.VolumeId)] = true
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	return volumes, nil
+}
@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Analysis formats: the markdown template of the prompts, whose figures are parsed with
+// regular expressions, or a strict JSON object decoded into a StructuredAnalysis
+const (
+	AnalysisFormatMarkdown = "markdown"
+	AnalysisFormatJSON     = "json"
+)
+
+var (
+	analysisFormatMu sync.RWMutex
+	analysisFormat   string
+)
+
+// SetAnalysisFormat sets the format analyses are generated in, overriding ANALYSIS_FORMAT
+func SetAnalysisFormat(format string) {
+	analysisFormatMu.Lock()
+	defer analysisFormatMu.Unlock()
+	analysisFormat = format
+}
+
+// AnalysisFormat returns the format analyses are generated in: the one set with
+// SetAnalysisFormat, else ANALYSIS_FORMAT, else AnalysisFormatMarkdown. The API and the
+// worker must agree on it, since the API fingerprints cached analyses with it.
+func AnalysisFormat() string {
+	analysisFormatMu.RLock()
+	format := analysisFormat
+	analysisFormatMu.RUnlock()
+	if format == "" {
+		format = os.Getenv("ANALYSIS_FORMAT")
+	}
+	if format == AnalysisFormatJSON {
+		return AnalysisFormatJSON
+	}
+	if format != "" && format != AnalysisFormatMarkdown {
+		Warnf("Ignoring unknown analysis format %q, using %s", format, AnalysisFormatMarkdown)
+	}
+	return AnalysisFormatMarkdown
+}
+
+// StructuredAnalysis is an analysis the model returned as a JSON object rather than
+// markdown. Costs are in USD per month and CO2Kg in kg CO2 per month.
+type StructuredAnalysis struct {
+	Findings        []string `json:"findings" dynamodbav:"findings"`
+	Recommendations []string `json:"recommendations" dynamodbav:"recommendations"`
+	Cost            struct {
+		Current   float64 `json:"current" dynamodbav:"current"`
+		Optimized float64 `json:"optimized" dynamodbav:"optimized"`
+		Savings   float64 `json:"savings" dynamodbav:"savings"`
+	} `json:"cost" dynamodbav:"cost"`
+	CO2Kg              float64  `json:"co2Kg" dynamodbav:"co2Kg"`
+	SecurityNotes      []string `json:"securityNotes,omitempty" dynamodbav:"securityNotes,omitempty"`
+	SustainabilityTips []string `json:"sustainabilityTips,omitempty" dynamodbav:"sustainabilityTips,omitempty"`
+}
+
+// Metrics returns the cost and CO2 figures of the analysis
+func (s *StructuredAnalysis) Metrics() AnalysisMetrics {
+	metrics := AnalysisMetrics{
+		CO2KgMonthly:   s.CO2Kg,
+		MonthlyCost:    s.Cost.Current,
+		OptimizedCost:  s.Cost.Optimized,
+		MonthlySavings: s.Cost.Savings,
+	}
+	if s.Cost.Current > 0 {
+		metrics.SavingsPct = s.Cost.Savings / s.Cost.Current * 100
+	}
+	return metrics
+}
+
+// validate checks what the JSON schema cannot: that the analysis says something and that
+// its figures are plausible
+func (s *StructuredAnalysis) validate() error {
+	switch {
+	case len(s.Findings) == 0 && len(s.Recommendations) == 0:
+		return errors.New("findings and recommendations are both empty")
+	case s.Cost.Current < 0 || s.Cost.Optimized < 0 || s.Cost.Savings < 0 || s.CO2Kg < 0:
+		return errors.New("cost and co2Kg must not be negative")
+	case s.Cost.Savings > s.Cost.Current:
+		return fmt.Errorf("savings of $%.2f exceed the current cost of $%.2f", s.Cost.Savings, s.Cost.Current)
+	}
+	return nil
+}
+
+// Markdown renders the analysis in the sections of the markdown template, so reports that
+// show Analysis as text, and ExtractAnalysisMetrics, work the same in both formats
+func (s *StructuredAnalysis) Markdown() string {
+	var sb strings.Builder
+	writeList := func(heading string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "## %s\n\n", heading)
+		for i, item := range items {
+			fmt.Fprintf(&sb, "%d. %s\n", i+1, item)
+		}
+		sb.WriteString("\n")
+	}
+
+	writeList("Inefficiencies Identified", s.Findings)
+	writeList("Recommendations", s.Recommendations)
+	metrics := s.Metrics()
+	fmt.Fprintf(&sb, "## %s\n", costImpactSectionName)
+	fmt.Fprintf(&sb, "- Estimated Monthly Cost: $%.2f\n", metrics.MonthlyCost)
+	fmt.Fprintf(&sb, "- Potential Optimized Cost: $%.2f\n", metrics.OptimizedCost)
+	fmt.Fprintf(&sb, "- Monthly Savings Potential: $%.2f (%.1f%%)\n", metrics.MonthlySavings, metrics.SavingsPct)
+	fmt.Fprintf(&sb, "- CO2 Footprint: %.2f kg CO2 per month\n\n", metrics.CO2KgMonthly)
+	writeList("Security Considerations", s.SecurityNotes)
+	writeList("Sustainability Tips", s.SustainabilityTips)
+	return strings.TrimSpace(sb.String())
+}
+
+// formatSectionMarker starts the output format section of every analysis prompt, which the
+// JSON format replaces with jsonAnalysisInstructions
+const formatSectionMarker = "FOLLOW THIS EXACT FORMAT FOR YOUR ANALYSIS:"
+
+const jsonAnalysisInstructions = `Respond with ONLY a JSON object, without markdown code fences or any text before or after it, in exactly this shape:
+{
+  "findings": ["[ISSUE]: [DESCRIPTION]"],
+  "recommendations": ["[ACTION]: [DESCRIPTION AND ESTIMATED IMPACT]"],
+  "cost": {"current": 0.00, "optimized": 0.00, "savings": 0.00},
+  "co2Kg": 0.00,
+  "securityNotes": ["[SECURITY ITEM]: [DESCRIPTION]"],
+  "sustainabilityTips": ["[TIP]: [DESCRIPTION]"]
+}
+cost.current is the estimated monthly cost, cost.optimized the monthly cost after the recommendations and cost.savings the difference, in USD; co2Kg is the monthly CO2 footprint in kg. Write them as plain numbers.
+`
+
+// jsonAnalysisPrompt turns a prompt written for the markdown template into one asking for
+// a StructuredAnalysis. Prompts without the template's format section, such as overridden
+// templates, get the JSON instructions appended.
+func jsonAnalysisPrompt(prompt string) string {
+	if index := strings.Index(prompt, formatSectionMarker); index != -1 {
+		prompt = prompt[:index]
+	}
+	return strings.TrimRight(prompt, "\n") + "\n\n" + jsonAnalysisInstructions
+}
+
+// parseStructuredAnalysis decodes the JSON object of a reply, ignoring code fences or text
+// around it, and rejects fields the schema does not have
+func parseStructuredAnalysis(text string) (*StructuredAnalysis, error) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start == -1 || end < start {
+		return nil, errors.New("the reply contains no JSON object")
+	}
+	decoder := json.NewDecoder(strings.NewReader(text[start : end+1]))
+	decoder.DisallowUnknownFields()
+	var analysis StructuredAnalysis
+	if err := decoder.Decode(&analysis); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := analysis.validate(); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// generateAnalysis sends an analysis prompt, written for the markdown template, to a model.
+// In AnalysisFormatJSON the prompt asks for a JSON object instead, which is decoded into
+// the result's Structured field with Text holding its markdown rendering. A reply that does
+// not decode is sent back once to be fixed; when that fails too the markdown prompt is used.
+// The result carries the completeness of the analysis and counts the tokens of every call.
+func generateAnalysis(ctx context.Context, client BedrockInvoker, modelID string, resourceType ResourceType, prompt string) (InvokeResult, error) {
+	if AnalysisFormat() != AnalysisFormatJSON {
+		return generateMarkdownAnalysis(ctx, client, modelID, resourceType, prompt)
+	}
+
+	var usage InvokeResult
+	jsonPrompt := jsonAnalysisPrompt(prompt)
+	request := jsonPrompt
+	for attempt := 1; attempt <= 2; attempt++ {
+		result, err := InvokeBedrockModel(ctx, client, modelID, request)
+		if err != nil {
+			return InvokeResult{}, err
+		}
+		usage.addTokens(result)
+
+		structured, err := parseStructuredAnalysis(result.Text)
+		if err == nil {
+			result.Text = structured.Markdown()
+			result.Structured = structured
+			quality := ValidateAnalysis(result.Text, resourceType)
+			result.Quality = &quality
+			result.InputTokens, result.OutputTokens, result.Estimated = usage.InputTokens, usage.OutputTokens, usage.Estimated
+			return result, nil
+		}
+		Warnf("Unusable JSON analysis from %s (attempt %d): %v", modelID, attempt, err)
+		request = fmt.Sprintf("%s\nYour previous reply could not be used: %v. It was:\n%s\n\nReply again with only the corrected JSON object.", jsonPrompt, err, result.Text)
+	}
+
+	Warnf("Falling back to a markdown analysis from %s", modelID)
+	result, err := generateMarkdownAnalysis(ctx, client, modelID, resourceType, prompt)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+	usage.addTokens(result)
+	result.InputTokens, result.OutputTokens, result.Estimated = usage.InputTokens, usage.OutputTokens, usage.Estimated
+	return result, nil
+}
+
+// generateMarkdownAnalysis sends an analysis prompt and scores the reply with
+// ValidateAnalysis. A reply scoring below MinAnalysisScore is asked for once more, with a
+// nudge naming what it lacked, and the more complete of the two is returned.
+func generateMarkdownAnalysis(ctx context.Context, client BedrockInvoker, modelID string, resourceType ResourceType, prompt string) (InvokeResult, error) {
+	result, err := InvokeBedrockModel(ctx, client, modelID, prompt)
+	if err != nil {
+		return InvokeResult{}, err
+	}
+	quality := ValidateAnalysis(result.Text, resourceType)
+	if quality.Score < MinAnalysisScore {
+		Warnf("Incomplete %s analysis from %s (score %d), asking again; missing: %s",
+			resourceType, modelID, quality.Score, strings.Join(quality.Missing, ", "))
+		retry, err := InvokeBedrockModel(ctx, client, modelID, prompt+analysisNudge(quality))
+		if err != nil {
+			// The first analysis is still usable, only less complete
+			Warnf("Retry of the %s analysis failed: %v", resourceType, err)
+		} else {
+			retry.addTokens(result)
+			retryQuality := ValidateAnalysis(retry.Text, resourceType)
+			if retryQuality.Score > quality.Score {
+				result, quality = retry, retryQuality
+			} else {
+				result.InputTokens, result.OutputTokens, result.Estimated = retry.InputTokens, retry.OutputTokens, retry.Estimated
+			}
+		}
+	}
+	result.Quality = &quality
+	return result, nil
+}
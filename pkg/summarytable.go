@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// Severity badges of the resource summary, derived from the savings percentage
+const (
+	SeverityHigh   = "HIGH"   // at least severityHighPct of the cost can be saved
+	SeverityMedium = "MEDIUM" // at least severityMediumPct of the cost can be saved
+	SeverityLow    = "LOW"    // some savings
+	SeverityOK     = "OK"     // no savings found
+)
+
+const (
+	severityHighPct   = 40.0
+	severityMediumPct = 15.0
+)
+
+// ResourceSummaryRow is one line of the resource summary table
+// - KeyMetric: the figure that best describes the resource's usage, e.g. CPU % or size; EC2 adds the suggested type change
+// - SavingsPct: potential savings as a share of the monthly cost
+// - Idle: an EC2 instance the scan classified as idle, shown with IdleBadge
+type ResourceSummaryRow struct {
+	ResourceType   ResourceType
+	ResourceID     string
+	Region         string
+	KeyMetric      string
+	CO2KgMonthly   float64
+	MonthlyCost    float64
+	MonthlySavings float64
+	SavingsPct     float64
+	Severity       string
+	Idle           bool
+}
+
+// IdleBadge marks idle resources next to their severity
+const IdleBadge = "IDLE"
+
+// SummarizeResources builds one summary row per report item, sorted by potential savings
+// so the biggest wins come first
+func SummarizeResources(report []ReportItem) []ResourceSummaryRow {
+	rows := make([]ResourceSummaryRow, 0, len(report))
+	for _, item := range report {
+		rows = append(rows, summarizeResource(item))
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].MonthlySavings > rows[j].MonthlySavings
+	})
+	return rows
+}
+
+// summarizeResource builds the summary row of a single report item
+func summarizeResource(item ReportItem) ResourceSummaryRow {
+	co2, cost, savings := extractItemMetrics(item)
+	resourceID, region, size, utilization := describeItem(item)
+
+	// Storage is sized rather than utilized, so its size says more than its object count
+	keyMetric := utilization
+	switch item.GetResourceType() {
+	case ResourceTypeS3, ResourceTypeSnapshots:
+		keyMetric = size
+	case ResourceTypeEC2:
+		if suggestion := item.RightsizeSuggestion; suggestion != nil {
+			keyMetric += fmt.Sprintf(", %s→%s", suggestion.CurrentType, suggestion.SuggestedType)
+		}
+	}
+
+	var pct float64
+	if cost > 0 {
+		pct = savings / cost * 100
+	}
+	return ResourceSummaryRow{
+		ResourceType:   item.GetResourceType(),
+		ResourceID:     resourceID,
+		Region:         region,
+		KeyMetric:      keyMetric,
+		CO2KgMonthly:   co2,
+		MonthlyCost:    cost,
+		MonthlySavings: savings,
+		SavingsPct:     pct,
+		Severity:       savingsSeverity(savings, pct),
+		Idle:           item.IsIdle(),
+	}
+}
+
+// savingsSeverity grades a resource by the share of its cost that could be saved
+func savingsSeverity(savings, pct float64) string {
+	switch {
+	case savings <= 0:
+		return SeverityOK
+	case pct >= severityHighPct:
+		return SeverityHigh
+	case pct >= severityMediumPct:
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
+
+// severityColor is the terminal color of a severity badge
+func severityColor(severity string) string {
+	switch severity {
+	case SeverityHigh:
+		return ColorRed
+	case SeverityMedium:
+		return ColorYellow
+	default:
+		return ColorGreen
+	}
+}
+
+// printResourceSummaryTable prints one line per resource, biggest potential savings first
+func printResourceSummaryTable(w io.Writer, report []ReportItem, colorize bool) {
+	if colorize {
+		fmt.Fprintf(w, "\n%sRESOURCE SUMMARY%s\n", ColorBold, ColorReset)
+	} else {
+		fmt.Fprintf(w, "\nRESOURCE SUMMARY\n")
+	}
+	fmt.Fprintf(w, "────────────────\n")
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tRESOURCE\tKEY METRIC\tCO2 (kg/mo)\tCOST ($/mo)\tSAVINGS ($/mo)\tSEVERITY")
+	for _, row := range SummarizeResources(report) {
+		badge := row.Severity
+		if colorize {
+			badge = severityColor(row.Severity) + badge + ColorReset
+		}
+		if row.Idle && colorize {
+			badge += " " + ColorMagenta + IdleBadge + ColorReset
+		} else if row.Idle {
+			badge += " " + IdleBadge
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%.2f\t%.2f (%.0f%%)\t%s\n",
+			row.ResourceType, row.ResourceID, row.KeyMetric, row.CO2KgMonthly, row.MonthlyCost, row.MonthlySavings, row.SavingsPct, badge)
+	}
+	tw.Flush()
+}
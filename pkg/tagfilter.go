@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagFilter matches a single tag. A Value of "*" matches any value, i.e. key presence.
+type TagFilter struct {
+	Key   string
+	Value string
+}
+
+// TagFilterSet holds the include and exclude filters applied to scanned resources
+// - Include: filters with the same key are OR-ed, different keys are AND-ed
+// - Exclude: a resource matching any exclude filter is dropped
+type TagFilterSet struct {
+	Include []TagFilter
+	Exclude []TagFilter
+}
+
+// ParseTagFilter parses "key=value" or "key" (shorthand for "key=*")
+func ParseTagFilter(s string) (TagFilter, error) {
+	key, value, hasValue := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q: missing key", s)
+	}
+	if !hasValue || value == "" {
+		value = "*"
+	}
+	return TagFilter{Key: key, Value: value}, nil
+}
+
+// ParseTagFilterSet parses include and exclude filter strings into a TagFilterSet
+func ParseTagFilterSet(include, exclude []string) (TagFilterSet, error) {
+	var set TagFilterSet
+	for _, s := range include {
+		f, err := ParseTagFilter(s)
+		if err != nil {
+			return set, err
+		}
+		set.Include = append(set.Include, f)
+	}
+	for _, s := range exclude {
+		f, err := ParseTagFilter(s)
+		if err != nil {
+			return set, err
+		}
+		set.Exclude = append(set.Exclude, f)
+	}
+	return set, nil
+}
+
+// IsEmpty reports whether the set has no filters
+func (s TagFilterSet) IsEmpty() bool {
+	return len(s.Include) == 0 && len(s.Exclude) == 0
+}
+
+// Matches reports whether a resource with the given tags passes the filters
+func (s TagFilterSet) Matches(tags map[string]string) bool {
+	for _, f := range s.Exclude {
+		if f.matches(tags) {
+			return false
+		}
+	}
+
+	// Group include filters by key so alternatives for the same key are OR-ed
+	matchedKeys := make(map[string]bool)
+	for _, f := range s.Include {
+		if _, seen := matchedKeys[f.Key]; !seen {
+			matchedKeys[f.Key] = false
+		}
+		if f.matches(tags) {
+			matchedKeys[f.Key] = true
+		}
+	}
+	for _, matched := range matchedKeys {
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matches reports whether the tag map satisfies a single filter
+func (f TagFilter) matches(tags map[string]string) bool {
+	value, ok := tags[f.Key]
+	if !ok {
+		return false
+	}
+	return f.Value == "*" || f.Value == value
+}
+
+// filterByTags drops items that do not pass the filters and returns the number removed
+func filterByTags[T any](items []T, tagsOf func(T) map[string]string, filters TagFilterSet) ([]T, int) {
+	if filters.IsEmpty() {
+		return items, 0
+	}
+
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		if filters.Matches(tagsOf(item)) {
+			kept = append(kept, item)
+		}
+	}
+	return kept, len(items) - len(kept)
+}
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    TagFilter
+		wantErr bool
+	}{
+		{in: "env=prod", want: TagFilter{Key: "env", Value: "prod"}},
+		{in: "env", want: TagFilter{Key: "env", Value: "*"}},
+		{in: "env=", want: TagFilter{Key: "env", Value: "*"}},
+		{in: " env =prod", want: TagFilter{Key: "env", Value: "prod"}},
+		{in: "url=a=b", want: TagFilter{Key: "url", Value: "a=b"}},
+		{in: "", wantErr: true},
+		{in: "=prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTagFilter(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTagFilter(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseTagFilter(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTagFilterSet(t *testing.T) {
+	set, err := ParseTagFilterSet([]string{"env=prod", "team"}, []string{"keep=true"})
+	if err != nil {
+		t.Fatalf("ParseTagFilterSet() error = %v", err)
+	}
+	if len(set.Include) != 2 || len(set.Exclude) != 1 {
+		t.Errorf("ParseTagFilterSet() = %+v, want 2 includes and 1 exclude", set)
+	}
+	if set.IsEmpty() {
+		t.Error("IsEmpty() = true for a set with filters")
+	}
+	if !(TagFilterSet{}).IsEmpty() {
+		t.Error("IsEmpty() = false for the zero set")
+	}
+
+	if _, err := ParseTagFilterSet([]string{"env=prod"}, []string{"=x"}); err == nil {
+		t.Error("ParseTagFilterSet() accepted an exclude filter without a key")
+	}
+}
+
+func TestTagFilterSetMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		tags    map[string]string
+		want    bool
+	}{
+		{name: "no filters", tags: nil, want: true},
+		{name: "include value", include: []string{"env=prod"}, tags: map[string]string{"env": "prod"}, want: true},
+		{name: "include other value", include: []string{"env=prod"}, tags: map[string]string{"env": "dev"}, want: false},
+		{name: "include missing key", include: []string{"env=prod"}, tags: map[string]string{"team": "a"}, want: false},
+		{name: "include any value", include: []string{"env"}, tags: map[string]string{"env": "dev"}, want: true},
+		{name: "same key is OR-ed", include: []string{"env=prod", "env=staging"}, tags: map[string]string{"env": "staging"}, want: true},
+		{name: "different keys are AND-ed", include: []string{"env=prod", "team=a"}, tags: map[string]string{"env": "prod"}, want: false},
+		{name: "different keys all match", include: []string{"env=prod", "team=a"}, tags: map[string]string{"env": "prod", "team": "a"}, want: true},
+		{name: "exclude value", exclude: []string{"keep=true"}, tags: map[string]string{"keep": "true"}, want: false},
+		{name: "exclude other value", exclude: []string{"keep=true"}, tags: map[string]string{"keep": "false"}, want: true},
+		{name: "exclude wins over include", include: []string{"env=prod"}, exclude: []string{"keep"}, tags: map[string]string{"env": "prod", "keep": "yes"}, want: false},
+		{name: "values are case sensitive", include: []string{"env=prod"}, tags: map[string]string{"env": "Prod"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParseTagFilterSet(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("ParseTagFilterSet() error = %v", err)
+			}
+			if got := set.Matches(tt.tags); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByTags(t *testing.T) {
+	instances := []Instance{
+		{InstanceID: "i-1", Tags: map[string]string{"env": "prod"}},
+		{InstanceID: "i-2", Tags: map[string]string{"env": "dev"}},
+		{InstanceID: "i-3"},
+		{InstanceID: "i-4", Tags: map[string]string{"env": "prod", "keep": "true"}},
+	}
+	tagsOf := func(i Instance) map[string]string { return i.Tags }
+
+	kept, removed := filterByTags(instances, tagsOf, TagFilterSet{})
+	if len(kept) != len(instances) || removed != 0 {
+		t.Errorf("without filters kept %d and removed %d, want all kept", len(kept), removed)
+	}
+
+	set, err := ParseTagFilterSet([]string{"env=prod"}, []string{"keep"})
+	if err != nil {
+		t.Fatalf("ParseTagFilterSet() error = %v", err)
+	}
+	kept, removed = filterByTags(instances, tagsOf, set)
+	var ids []string
+	for _, i := range kept {
+		ids = append(ids, i.InstanceID)
+	}
+	if fmt.Sprint(ids) != "[i-1]" || removed != 3 {
+		t.Errorf("kept %v and removed %d, want [i-1] and 3", ids, removed)
+	}
+}
@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxRequestItems is how many resources one analyze request may carry unless MAX_ITEMS says otherwise
+const DefaultMaxRequestItems = 200
+
+// MaxTagsPerResource matches the AWS limit of user tags on a resource
+const MaxTagsPerResource = 50
+
+// FieldError describes one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists every invalid field of a request, so a client can fix them all at once
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// PayloadTooLargeError is returned when a request carries more resources than allowed
+type PayloadTooLargeError struct {
+	Count int
+	Max   int
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("request contains %d resources, more than the limit of %d; scan fewer resources (--limit) or split them across several requests", e.Count, e.Max)
+}
+
+// MaxRequestItems returns MAX_ITEMS, or DefaultMaxRequestItems when it is unset or invalid
+func MaxRequestItems() int {
+	if limit, err := strconv.Atoi(os.Getenv("MAX_ITEMS")); err == nil && limit > 0 {
+		return limit
+	}
+	return DefaultMaxRequestItems
+}
+
+// payloadResource is one resource of a payload, reduced to what validation looks at
+type payloadResource struct {
+	collection  string // JSON name of the payload section, e.g. "instances"
+	index       int
+	idField     string
+	id          string
+	accountID   string // resources of different accounts may share an identifier
+	region      string
+	tags        map[string]string
+	percentages []namedValue // metrics that must lie between 0 and 100
+}
+
+// namedValue is a numeric field and its JSON name
+type namedValue struct {
+	name  string
+	value float64
+}
+
+func (r payloadResource) field(name string) string {
+	return fmt.Sprintf("%s[%d].%s", r.collection, r.index, name)
+}
+
+// regionalCollections are the payload sections identified by names, which are only unique
+// within a region. Instance, volume, snapshot and network IDs and bucket names are unique
+// across regions.
+var regionalCollections = map[string]bool{
+	"rds_instances":        true,
+	"lambda_functions":     true,
+	"dynamo_tables":        true,
+	"load_balancers":       true,
+	"elasticache_clusters": true,
+}
+
+// key identifies the resource among the others of the payload, to find duplicates
+func (r payloadResource) key() string {
+	if regionalCollections[r.collection] {
+		return r.collection + "/" + r.accountID + "/" + r.region + "/" + r.id
+	}
+	return r.collection + "/" + r.accountID + "/" + r.id
+}
+
+// resources lists every resource of the payload in payload order
+func (p ScanPayload) resources() []payloadResource {
+	resources := make([]payloadResource, 0, p.Count())
+	for i, instance := range p.Instances {
+		resources = append(resources, payloadResource{collection: "instances", index: i, idField: "instanceId", id: instance.InstanceID, accountID: instance.AccountID, region: instance.Region, tags: instance.Tags,
+			percentages: []namedValue{{"cpuAvg7d", instance.CPUAvg7d}, {"memAvg7d", instance.MemAvg7d}}})
+	}
+	for i, bucket := range p.S3Buckets {
+		resources = append(resources, payloadResource{collection: "s3_buckets", index: i, idField: "bucketName", id: bucket.BucketName, accountID: bucket.AccountID, region: bucket.Region, tags: bucket.Tags})
+	}
+	for i, instance := range p.RDSInstances {
+		resources = append(resources, payloadResource{collection: "rds_instances", index: i, idField: "instanceId", id: instance.InstanceID, accountID: instance.AccountID, region: instance.Region, tags: instance.Tags,
+			percentages: []namedValue{{"cpuAvg7d", instance.CPUAvg7d}}})
+	}
+	for i, volume := range p.EBSVolumes {
+		resources = append(resources, payloadResource{collection: "ebs_volumes", index: i, idField: "volumeId", id: volume.VolumeID, accountID: volume.AccountID, region: volume.Region, tags: volume.Tags})
+	}
+	for i, function := range p.LambdaFunctions {
+		resources = append(resources, payloadResource{collection: "lambda_functions", index: i, idField: "functionName", id: function.FunctionName, accountID: function.AccountID, region: function.Region, tags: function.Tags})
+	}
+	for i, loadBalancer := range p.LoadBalancers {
+		resources = append(resources, payloadResource{collection: "load_balancers", index: i, idField: "name", id: loadBalancer.Name, accountID: loadBalancer.AccountID, region: loadBalancer.Region, tags: loadBalancer.Tags})
+	}
+	for i, resource := range p.NetworkResources {
+		resources = append(resources, payloadResource{collection: "network_resources", index: i, idField: "resourceId", id: resource.ResourceID, accountID: resource.AccountID, region: resource.Region, tags: resource.Tags})
+	}
+	for i, table := range p.DynamoTables {
+		resources = append(resources, payloadResource{collection: "dynamo_tables", index: i, idField: "tableName", id: table.TableName, accountID: table.AccountID, region: table.Region, tags: table.Tags})
+	}
+	for i, cluster := range p.ElastiCacheClusters {
+		resources = append(resources, payloadResource{collection: "elasticache_clusters", index: i, idField: "clusterId", id: cluster.ClusterID, accountID: cluster.AccountID, region: cluster.Region, tags: cluster.Tags,
+			percentages: []namedValue{{"cpuAvg7d", cluster.CPUAvg7d}, {"memoryUsageAvg7d", cluster.MemoryUsageAvg7d}}})
+	}
+	for i, snapshot := range p.Snapshots {
+		resources = append(resources, payloadResource{collection: "snapshots", index: i, idField: "snapshotId", id: snapshot.SnapshotID, accountID: snapshot.AccountID, region: snapshot.Region, tags: snapshot.Tags})
+	}
+	return resources
+}
+
+// ValidateRequest checks a payload submitted to the analyze API. It returns a
+// *PayloadTooLargeError when there are more than maxItems resources, and otherwise
+// ValidationErrors listing every missing identifier, duplicate resource, out-of-range
+// percentage and oversized tag set, a ttl_days outside MinJobTTLDays to MaxJobTTLDays and
+// a model missing from AllowedModelOverrides.
+func (p ScanPayload) ValidateRequest(maxItems int) error {
+	if count := p.Count(); count > maxItems {
+		return &PayloadTooLargeError{Count: count, Max: maxItems}
+	}
+
+	var errs ValidationErrors
+	seen := make(map[string]int) // resource key -> index of first occurrence
+	for _, r := range p.resources() {
+		if r.id == "" {
+			errs = append(errs, FieldError{Field: r.field(r.idField), Message: "must not be empty"})
+		} else {
+			key := r.key()
+			if first, dup := seen[key]; dup {
+				errs = append(errs, FieldError{Field: r.field(r.idField), Message: fmt.Sprintf("duplicates %s[%d]", r.collection, first)})
+			} else {
+				seen[key] = r.index
+			}
+		}
+
+		for _, pct := range r.percentages {
+			if pct.value < 0 || pct.value > 100 {
+				errs = append(errs, FieldError{Field: r.field(pct.name), Message: fmt.Sprintf("%g is not a percentage between 0 and 100", pct.value)})
+			}
+		}
+		if len(r.tags) > MaxTagsPerResource {
+			errs = append(errs, FieldError{Field: r.field("tags"), Message: fmt.Sprintf("has %d tags, more than the limit of %d", len(r.tags), MaxTagsPerResource)})
+		}
+	}
+
+	if p.TTLDays != 0 && (p.TTLDays < MinJobTTLDays || p.TTLDays > MaxJobTTLDays) {
+		errs = append(errs, FieldError{Field: "ttl_days", Message: fmt.Sprintf("%d is outside the allowed range of %d to %d days", p.TTLDays, MinJobTTLDays, MaxJobTTLDays)})
+	}
+	if p.Model != "" {
+		if problem := checkModelOverride(p.Model); problem != "" {
+			errs = append(errs, FieldError{Field: "model", Message: problem})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// manyTags returns n distinct tags
+func manyTags(n int) map[string]string {
+	tags := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		tags[fmt.Sprintf("tag-%d", i)] = "value"
+	}
+	return tags
+}
+
+func TestValidateRequest(t *testing.T) {
+	t.Setenv("ALLOWED_GEN_MODELS", "model-a, model-b")
+
+	tests := []struct {
+		name       string
+		payload    ScanPayload
+		wantFields []string
+	}{
+		{
+			name: "valid",
+			payload: ScanPayload{
+				Instances: []Instance{{InstanceID: "i-1", CPUAvg7d: 0}, {InstanceID: "i-2", CPUAvg7d: 100, MemAvg7d: 55}},
+				S3Buckets: []S3Bucket{{BucketName: "logs", Tags: manyTags(MaxTagsPerResource)}},
+				TTLDays:   MaxJobTTLDays,
+				Model:     "model-b",
+			},
+		},
+		{
+			name:       "missing identifiers",
+			payload:    ScanPayload{Instances: []Instance{{InstanceID: "i-1"}, {}}, S3Buckets: []S3Bucket{{}}, Snapshots: []EBSSnapshot{{}}},
+			wantFields: []string{"instances[1].instanceId", "s3_buckets[0].bucketName", "snapshots[0].snapshotId"},
+		},
+		{
+			name:       "percentages out of range",
+			payload:    ScanPayload{Instances: []Instance{{InstanceID: "i-1", CPUAvg7d: -1, MemAvg7d: 100.5}}, RDSInstances: []RDSInstance{{InstanceID: "db-1", CPUAvg7d: 250}}},
+			wantFields: []string{"instances[0].cpuAvg7d", "instances[0].memAvg7d", "rds_instances[0].cpuAvg7d"},
+		},
+		{
+			name:       "too many tags",
+			payload:    ScanPayload{LambdaFunctions: []LambdaFunction{{FunctionName: "f", Tags: manyTags(MaxTagsPerResource + 1)}}},
+			wantFields: []string{"lambda_functions[0].tags"},
+		},
+		{
+			name:       "duplicates",
+			payload:    ScanPayload{Instances: []Instance{{InstanceID: "i-1"}, {InstanceID: "i-2"}, {InstanceID: "i-1"}, {InstanceID: "i-1"}}},
+			wantFields: []string{"instances[2].instanceId", "instances[3].instanceId"},
+		},
+		{
+			name: "same ID in different collections",
+			payload: ScanPayload{
+				Instances:    []Instance{{InstanceID: "x"}},
+				RDSInstances: []RDSInstance{{InstanceID: "x"}},
+			},
+		},
+		{
+			// Names are only unique within a region, while instance IDs are unique across them
+			name: "same name in different regions",
+			payload: ScanPayload{
+				Instances:       []Instance{{InstanceID: "i-1", Region: "eu-west-1"}, {InstanceID: "i-1", Region: "us-east-1"}},
+				RDSInstances:    []RDSInstance{{InstanceID: "db", Region: "eu-west-1"}, {InstanceID: "db", Region: "us-east-1"}, {InstanceID: "db", Region: "eu-west-1"}},
+				LambdaFunctions: []LambdaFunction{{FunctionName: "api", Region: "eu-west-1"}, {FunctionName: "api", Region: "us-east-1"}},
+			},
+			wantFields: []string{"instances[1].instanceId", "rds_instances[2].instanceId"},
+		},
+		{
+			name: "same ID in different accounts",
+			payload: ScanPayload{
+				Instances:    []Instance{{InstanceID: "i-1", AccountID: "111111111111"}, {InstanceID: "i-1", AccountID: "222222222222"}},
+				RDSInstances: []RDSInstance{{InstanceID: "db", AccountID: "111111111111"}, {InstanceID: "db", AccountID: "111111111111"}},
+			},
+			wantFields: []string{"rds_instances[1].instanceId"},
+		},
+		{
+			name:       "ttl out of range",
+			payload:    ScanPayload{Instances: []Instance{{InstanceID: "i-1"}}, TTLDays: MaxJobTTLDays + 1},
+			wantFields: []string{"ttl_days"},
+		},
+		{
+			name:       "model not allowed",
+			payload:    ScanPayload{Instances: []Instance{{InstanceID: "i-1"}}, Model: "model-c"},
+			wantFields: []string{"model"},
+		},
+		{
+			name:       "every error at once",
+			payload:    ScanPayload{Instances: []Instance{{CPUAvg7d: 101}}, LambdaFunctions: []LambdaFunction{{Tags: manyTags(MaxTagsPerResource + 1)}}},
+			wantFields: []string{"instances[0].instanceId", "instances[0].cpuAvg7d", "lambda_functions[0].functionName", "lambda_functions[0].tags"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.payload.ValidateRequest(DefaultMaxRequestItems)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateRequest() error = %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ValidateRequest() error = %v (%T), want ValidationErrors", err, err)
+			}
+			var fields []string
+			for _, fieldErr := range errs {
+				fields = append(fields, fieldErr.Field)
+				if fieldErr.Message == "" {
+					t.Errorf("%s has no message", fieldErr.Field)
+				}
+			}
+			if fmt.Sprint(fields) != fmt.Sprint(tt.wantFields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidateRequestDuplicateMessage(t *testing.T) {
+	payload := ScanPayload{S3Buckets: []S3Bucket{{BucketName: "a"}, {BucketName: "a"}}}
+	err := payload.ValidateRequest(DefaultMaxRequestItems)
+	if err == nil || err.Error() != "s3_buckets[1].bucketName: duplicates s3_buckets[0]" {
+		t.Errorf("ValidateRequest() error = %v", err)
+	}
+}
+
+func TestValidateRequestTooLarge(t *testing.T) {
+	tests := []struct {
+		instances int
+		maxItems  int
+		wantErr   bool
+	}{
+		{instances: 10, maxItems: 10},
+		{instances: 11, maxItems: 10, wantErr: true},
+		{instances: 10000, maxItems: DefaultMaxRequestItems, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d of %d", tt.instances, tt.maxItems), func(t *testing.T) {
+			// Identifiers are left empty: the size is checked before the fields
+			payload := ScanPayload{Instances: make([]Instance, tt.instances)}
+			err := payload.ValidateRequest(tt.maxItems)
+
+			var tooLarge *PayloadTooLargeError
+			if errors.As(err, &tooLarge) != tt.wantErr {
+				t.Fatalf("ValidateRequest() error = %v, want too large %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (tooLarge.Count != tt.instances || tooLarge.Max != tt.maxItems) {
+				t.Errorf("error = %+v", tooLarge)
+			}
+		})
+	}
+}
+
+func TestMaxRequestItems(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{env: "", want: DefaultMaxRequestItems},
+		{env: "500", want: 500},
+		{env: "0", want: DefaultMaxRequestItems},
+		{env: "-5", want: DefaultMaxRequestItems},
+		{env: "many", want: DefaultMaxRequestItems},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("MAX_ITEMS", tt.env)
+			if got := MaxRequestItems(); got != tt.want {
+				t.Errorf("MaxRequestItems() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// BuildInfo identifies the build of a GreenOps binary
+type BuildInfo struct {
+	Version string
+	Commit  string
+	Date    string
+}
+
+// String formats the build as "v1.2.0 (commit 1a2b3c4, built 2025-05-01T12:00:00Z)"
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", b.Version, b.Commit, b.Date)
+}
+
+// NewBuildInfo returns the build described by a binary's -ldflags variables. A commit or date
+// left at "none" or "unknown" is filled from the VCS stamp Go records in the binary, if any.
+func NewBuildInfo(version, commit, date string) BuildInfo {
+	b := BuildInfo{Version: version, Commit: commit, Date: date}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && b.Commit == "none":
+			b.Commit = setting.Value
+			if len(b.Commit) > 12 {
+				b.Commit = b.Commit[:12]
+			}
+		case setting.Key == "vcs.time" && b.Date == "unknown":
+			b.Date = setting.Value
+		}
+	}
+	return b
+}
+
+// currentBuild is the build of the running binary, as recorded by SetBuildInfo
+var currentBuild = BuildInfo{Version: "dev", Commit: "none", Date: "unknown"}
+
+// SetBuildInfo records the build of the running binary; reports name it as their generator
+func SetBuildInfo(b BuildInfo) {
+	currentBuild = b
+}
+
+// CurrentBuild returns the build recorded by SetBuildInfo
+func CurrentBuild() BuildInfo {
+	return currentBuild
+}
+
+// ReportGenerator names the program and build that produced a report, e.g. "greenops v1.2.0 (commit 1a2b3c4)"
+func ReportGenerator() string {
+	return fmt.Sprintf("greenops %s (commit %s)", currentBuild.Version, currentBuild.Commit)
+}